@@ -158,6 +158,14 @@ func New(
 			"/debug/stack", "DEBUG: Returns a snapshot of the current service stack trace.",
 			handleStackTrace,
 		)
+		t.RegisterEndpoint(
+			"/debug/pprof/", "DEBUG: Lists the available pprof profiles.",
+			pprof.Index,
+		)
+		t.RegisterEndpoint(
+			"/debug/pprof/cmdline", "DEBUG: Responds with the command line of the running process.",
+			pprof.Cmdline,
+		)
 		t.RegisterEndpoint(
 			"/debug/pprof/profile", "DEBUG: Responds with a pprof-formatted cpu profile.",
 			pprof.Profile,
@@ -166,6 +174,18 @@ func New(
 			"/debug/pprof/heap", "DEBUG: Responds with a pprof-formatted heap profile.",
 			pprof.Index,
 		)
+		t.RegisterEndpoint(
+			"/debug/pprof/allocs", "DEBUG: Responds with a pprof-formatted profile of all past memory allocations.",
+			pprof.Index,
+		)
+		t.RegisterEndpoint(
+			"/debug/pprof/goroutine", "DEBUG: Responds with a pprof-formatted profile of all current goroutines.",
+			pprof.Index,
+		)
+		t.RegisterEndpoint(
+			"/debug/pprof/threadcreate", "DEBUG: Responds with a pprof-formatted profile of stack traces that led to the creation of new OS threads.",
+			pprof.Index,
+		)
 		t.RegisterEndpoint(
 			"/debug/pprof/block", "DEBUG: Responds with a pprof-formatted block profile.",
 			pprof.Index,
@@ -204,6 +224,40 @@ func New(
 			wHandlerFunc.HandlerFunc(),
 		)
 	}
+	if jHandlerFunc, ok := metrics.JSONHandlerFunc(stats); ok {
+		t.RegisterEndpoint(
+			"/metrics/json", "Returns an array of JSON documents of service metrics grouped by component.",
+			jHandlerFunc,
+		)
+	}
+	t.RegisterEndpoint(
+		"/metrics/flush", "Forces push based metrics types to send metrics immediately, must be a POST request.",
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if !metrics.Flush(stats) {
+				http.Error(w, "Metrics type does not push metrics", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("OK"))
+		},
+	)
+	t.RegisterEndpoint(
+		"/metrics/reset", "Resets the counters and timers of metrics types that aggregate metrics locally to zero, must be a POST request.",
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if !metrics.Reset(stats) {
+				http.Error(w, "Metrics type does not aggregate metrics locally", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("OK"))
+		},
+	)
 
 	return t, nil
 }
@@ -25,7 +25,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 )
 
@@ -33,7 +36,8 @@ import (
 
 // Try is a broker that implements types.Consumer and attempts to send each
 // message to a single output, but on failure will attempt the next output in
-// the list.
+// the list. When an output reports which messages of a batch failed only those
+// messages are sent to the next output.
 type Try struct {
 	running int32
 
@@ -126,10 +130,15 @@ func (t *Try) loop() {
 		}
 		mMsgsRcvd.Incr(1)
 
+		// The messages yet to be sent successfully along with their indexes
+		// within the original batch.
+		pending, indexes := ts.Payload, allIndexes(ts.Payload.Len())
+		var pendingErrs []error
+
 	triesLoop:
 		for i, ot := range t.outputTsChans {
 			select {
-			case ot <- types.NewTransaction(ts.Payload, resChan):
+			case ot <- types.NewTransaction(pending, resChan):
 			case <-t.closeChan:
 				return
 			}
@@ -138,15 +147,23 @@ func (t *Try) loop() {
 				if !open {
 					return
 				}
-				if res.Error() != nil {
-					mErrs[i].Incr(1)
-				} else {
+				if res.Error() == nil {
+					pending = nil
 					break triesLoop
 				}
+				mErrs[i].Incr(1)
+				pending, indexes, pendingErrs = failedParts(pending, indexes, res.Error())
 			case <-t.closeChan:
 				return
 			}
 		}
+		if pending != nil && pending.Len() < ts.Payload.Len() {
+			bErr := batch.NewError(ts.Payload, res.Error())
+			for j, index := range indexes {
+				bErr.Failed(index, pendingErrs[j])
+			}
+			res = response.NewError(bErr)
+		}
 		select {
 		case ts.ResponseChan <- res:
 		case <-t.closeChan:
@@ -155,6 +172,41 @@ func (t *Try) loop() {
 	}
 }
 
+func allIndexes(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// failedParts returns the messages of a sent batch that failed according to an
+// error, along with their indexes within the original batch and their errors.
+// Unless the error is a batch error identifying individual messages the entire
+// batch is considered failed.
+func failedParts(msg types.Message, indexes []int, err error) (types.Message, []int, []error) {
+	bErr, ok := err.(*batch.Error)
+	if !ok || bErr.IndexedErrors() == 0 || bErr.Len() != msg.Len() {
+		errs := make([]error, len(indexes))
+		for i := range errs {
+			errs[i] = err
+		}
+		return msg, indexes, errs
+	}
+	failed := message.New(nil)
+	var failedIndexes []int
+	var errs []error
+	bErr.WalkParts(func(i int, _ types.Part, pErr error) bool {
+		if pErr != nil {
+			failed.Append(msg.Get(i))
+			failedIndexes = append(failedIndexes, indexes[i])
+			errs = append(errs, pErr)
+		}
+		return true
+	})
+	return failed, failedIndexes, errs
+}
+
 // CloseAsync shuts down the Try broker and stops processing requests.
 func (t *Try) CloseAsync() {
 	if atomic.CompareAndSwapInt32(&t.running, 1, 0) {
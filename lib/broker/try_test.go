@@ -23,10 +23,12 @@ package broker
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -315,4 +317,103 @@ func TestTryAllFail(t *testing.T) {
 	}
 }
 
+func TestTryBatchErrors(t *testing.T) {
+	mockOutputs := []*MockOutputType{{}, {}, {}}
+	outputs := []types.Output{}
+	for _, o := range mockOutputs {
+		outputs = append(outputs, o)
+	}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewTry(outputs, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = oTM.Consume(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"), []byte("buz"),
+	})
+	select {
+	case readChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+
+	// Each output only receives the messages that failed with the previous
+	// output, and the final output fails all messages.
+	expContents := [][]string{
+		{"foo", "bar", "baz", "buz"},
+		{"foo", "baz"},
+		{"foo"},
+	}
+	go func() {
+		for i, o := range mockOutputs {
+			var ts types.Transaction
+			select {
+			case ts = <-o.TChan:
+			case <-time.After(time.Second):
+				t.Errorf("Timed out waiting for broker propagate")
+				return
+			}
+			var contents []string
+			for _, b := range message.GetAllBytes(ts.Payload) {
+				contents = append(contents, string(b))
+			}
+			if exp, act := expContents[i], contents; !reflect.DeepEqual(exp, act) {
+				t.Errorf("Wrong contents sent to output %v: %v != %v", i, act, exp)
+			}
+
+			var res types.Response
+			if i == 0 {
+				res = response.NewError(batch.NewError(ts.Payload, errors.New("first err")).
+					Failed(0, errors.New("foo err")).
+					Failed(2, errors.New("baz err")))
+			} else if i == 1 {
+				res = response.NewError(batch.NewError(ts.Payload, errors.New("second err")).
+					Failed(0, errors.New("foo err")))
+			} else {
+				res = response.NewError(errors.New("third err"))
+			}
+			select {
+			case ts.ResponseChan <- res:
+			case <-time.After(time.Second):
+				t.Errorf("Timed out responding to broker")
+				return
+			}
+		}
+	}()
+
+	var res types.Response
+	select {
+	case res = <-resChan:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker response")
+	}
+
+	bErr, ok := res.Error().(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", res.Error())
+	}
+	var failed []string
+	bErr.WalkParts(func(i int, p types.Part, err error) bool {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", p.Get(), err))
+		}
+		return true
+	})
+	if exp, act := []string{"foo: third err"}, failed; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong failed messages: %v != %v", act, exp)
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+}
+
 //------------------------------------------------------------------------------
@@ -77,6 +77,7 @@ type ParallelWrapper struct {
 
 	buffer      Parallel
 	errThrottle *throttle.Type
+	pending     *pendingCount
 
 	running   int32
 	consuming int32
@@ -103,6 +104,7 @@ func NewParallelWrapper(
 		log:               log,
 		conf:              conf,
 		buffer:            buffer,
+		pending:           newPendingCount(stats),
 		running:           1,
 		consuming:         1,
 		messagesOut:       make(chan types.Transaction),
@@ -144,6 +146,7 @@ func (m *ParallelWrapper) inputLoop() {
 		if err == nil {
 			mWriteCount.Incr(1)
 			mWriteBacklog.Set(int64(backlog))
+			m.pending.add(1)
 		} else {
 			mWriteErr.Incr(1)
 		}
@@ -203,6 +206,7 @@ func (m *ParallelWrapper) batchedInputLoop() {
 		if err == nil {
 			mWriteCount.Incr(int64(len(transactions)))
 			mWriteBacklog.Set(int64(backlog))
+			m.pending.add(int64(len(transactions)))
 		} else {
 			mWriteErr.Incr(1)
 		}
@@ -280,6 +284,9 @@ func (m *ParallelWrapper) outputLoop() {
 				}
 			} else {
 				mBacklog.Set(int64(blog))
+				if doAck {
+					m.pending.add(-1)
+				}
 			}
 		}(resChan, ackFunc)
 	}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package buffer
+
+import (
+	"sync/atomic"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+// pendingCount tracks the number of message batches written to a buffer that
+// are yet to be acknowledged by the output, and exposes it as a gauge.
+//
+// Persisted buffers might contain messages written before Benthos started,
+// which aren't counted, and therefore the count never drops below zero.
+type pendingCount struct {
+	n     int64
+	gauge metrics.StatGauge
+}
+
+func newPendingCount(stats metrics.Type) *pendingCount {
+	return &pendingCount{
+		gauge: stats.GetGauge("pending"),
+	}
+}
+
+func (p *pendingCount) add(delta int64) {
+	for {
+		n := atomic.LoadInt64(&p.n)
+		next := n + delta
+		if next < 0 {
+			next = 0
+		}
+		if atomic.CompareAndSwapInt64(&p.n, n, next) {
+			p.gauge.Set(next)
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
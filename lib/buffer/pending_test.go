@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package buffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/buffer/single"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestPendingCount(t *testing.T) {
+	stats := metrics.NewLocal()
+	p := newPendingCount(stats)
+
+	p.add(2)
+	if exp, act := int64(2), stats.GetCounters()["pending"]; exp != act {
+		t.Errorf("Wrong pending count: %v != %v", act, exp)
+	}
+	p.add(-3)
+	if exp, act := int64(0), stats.GetCounters()["pending"]; exp != act {
+		t.Errorf("Wrong pending count: %v != %v", act, exp)
+	}
+	p.add(1)
+	if exp, act := int64(1), stats.GetCounters()["pending"]; exp != act {
+		t.Errorf("Wrong pending count: %v != %v", act, exp)
+	}
+}
+
+func TestSingleWrapperPending(t *testing.T) {
+	stats := metrics.NewLocal()
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	b := NewSingleWrapper(NewConfig(), single.NewMemory(single.NewMemoryConfig()), log.Noop(), stats)
+	if err := b.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		b.CloseAsync()
+		if err := b.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Fatal(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	if exp, act := int64(2), stats.GetCounters()["pending"]; exp != act {
+		t.Errorf("Wrong pending count: %v != %v", act, exp)
+	}
+
+	var tran types.Transaction
+	select {
+	case tran = <-b.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	// The next message being read ensures the ack has been processed.
+	select {
+	case tran = <-b.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	if exp, act := int64(1), stats.GetCounters()["pending"]; exp != act {
+		t.Errorf("Wrong pending count: %v != %v", act, exp)
+	}
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -71,6 +71,7 @@ type SingleWrapper struct {
 
 	buffer      Single
 	errThrottle *throttle.Type
+	pending     *pendingCount
 
 	running   int32
 	consuming int32
@@ -98,6 +99,7 @@ func NewSingleWrapper(
 		log:               log,
 		conf:              conf,
 		buffer:            buffer,
+		pending:           newPendingCount(stats),
 		running:           1,
 		consuming:         1,
 		messagesOut:       make(chan types.Transaction),
@@ -141,6 +143,7 @@ func (m *SingleWrapper) inputLoop() {
 		if err == nil {
 			mWriteCount.Incr(1)
 			mWriteBacklog.Set(int64(backlog))
+			m.pending.add(1)
 		} else {
 			mWriteErr.Incr(1)
 		}
@@ -214,6 +217,7 @@ func (m *SingleWrapper) outputLoop() {
 				msg = nil
 				backlog, _ := m.buffer.ShiftMessage()
 				mBacklog.Set(int64(backlog))
+				m.pending.add(-1)
 				mSendSuccess.Incr(1)
 			} else {
 				mSendErr.Incr(1)
@@ -134,6 +134,7 @@ func (r *AsyncReader) loop() {
 		mFailedConn = r.stats.GetCounter("connection.failed")
 		mLostConn   = r.stats.GetCounter("connection.lost")
 		mLatency    = r.stats.GetTimer("latency")
+		mBlocked    = r.stats.GetTimer("blocked")
 	)
 
 	defer func() {
@@ -226,11 +227,13 @@ func (r *AsyncReader) loop() {
 
 		resChan := make(chan types.Response)
 		tracing.InitSpans("input_"+r.typeStr, msg)
+		tBlocked := time.Now()
 		select {
 		case r.transactions <- types.NewTransaction(msg, resChan):
 		case <-r.ctx.Done():
 			return
 		}
+		mBlocked.Timing(time.Since(tBlocked).Nanoseconds())
 
 		pendingAcks.Add(1)
 		go func(
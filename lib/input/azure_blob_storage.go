@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeAzureBlobStorage] = TypeSpec{
+		constructor: NewAzureBlobStorage,
+		description: `
+Downloads blobs from an Azure Blob Storage container, optionally filtered by a
+prefix. If a ` + "`storage_queue`" + ` has been configured then only blobs
+created by events read from the queue will be downloaded. Otherwise, the entire
+list of blobs found when this input is created will be downloaded.
+
+### Credentials
+
+Authentication uses either the ` + "`storage_access_key`" + ` or the
+` + "`storage_sas_token`" + ` of the ` + "`storage_account`" + `, or a
+` + "`storage_connection_string`" + ` which may also specify custom endpoints
+or ` + "`UseDevelopmentStorage=true`" + ` for the storage emulator. When
+running within Azure it's also possible to authenticate with a managed identity
+by setting ` + "`managed_identity.enabled`" + ` to ` + "`true`" + `.
+
+### Event Grid
+
+Blob events are consumed from an Azure Storage queue that is subscribed to the
+` + "`Microsoft.Storage.BlobCreated`" + ` events of the storage account via
+Event Grid, in either the Event Grid or CloudEvents schema. Events of blobs
+outside of the container or prefix are ignored.
+
+Queue messages are deleted once all of the blobs that they refer to have been
+processed, which ensures at-least-once delivery. If a blob takes longer to
+process than the ` + "`queue_visibility_timeout`" + ` then the message becomes
+visible again and the blob may be processed multiple times.
+
+` + reader.CodecDocumentation + `
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- blob_storage_key
+- blob_storage_container
+- blob_storage_last_modified (RFC3339)
+- blob_storage_last_modified_unix
+- blob_storage_content_type*
+- blob_storage_content_encoding*
+- All user defined metadata
+
+* Only added when set on the blob
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewAzureBlobStorage creates a new Azure Blob Storage input type.
+func NewAzureBlobStorage(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewAzureBlobStorage(conf.AzureBlobStorage, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(
+		TypeAzureBlobStorage,
+		true,
+		reader.NewAsyncPreserver(r),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
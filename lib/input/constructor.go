@@ -79,119 +79,182 @@ var Constructors = map[string]TypeSpec{}
 
 // String constants representing each input type.
 const (
-	TypeAMQP            = "amqp"
-	TypeAMQP09          = "amqp_0_9"
-	TypeBroker          = "broker"
-	TypeDynamic         = "dynamic"
-	TypeFile            = "file"
-	TypeFiles           = "files"
-	TypeGCPPubSub       = "gcp_pubsub"
-	TypeHDFS            = "hdfs"
-	TypeHTTPClient      = "http_client"
-	TypeHTTPServer      = "http_server"
-	TypeInproc          = "inproc"
-	TypeKafka           = "kafka"
-	TypeKafkaBalanced   = "kafka_balanced"
-	TypeKinesis         = "kinesis"
-	TypeKinesisBalanced = "kinesis_balanced"
-	TypeMQTT            = "mqtt"
-	TypeNanomsg         = "nanomsg"
-	TypeNATS            = "nats"
-	TypeNATSStream      = "nats_stream"
-	TypeNSQ             = "nsq"
-	TypeReadUntil       = "read_until"
-	TypeRedisList       = "redis_list"
-	TypeRedisPubSub     = "redis_pubsub"
-	TypeRedisStreams    = "redis_streams"
-	TypeS3              = "s3"
-	TypeSQS             = "sqs"
-	TypeSTDIN           = "stdin"
-	TypeTCP             = "tcp"
-	TypeTCPServer       = "tcp_server"
-	TypeUDPServer       = "udp_server"
-	TypeWebsocket       = "websocket"
-	TypeZMQ4            = "zmq4"
+	TypeAMQP                = "amqp"
+	TypeAMQP09              = "amqp_0_9"
+	TypeAzureBlobStorage    = "azure_blob_storage"
+	TypeBroker              = "broker"
+	TypeCron                = "cron"
+	TypeCSV                 = "csv"
+	TypeDynamic             = "dynamic"
+	TypeDynamoDBStreams     = "dynamodb_streams"
+	TypeFile                = "file"
+	TypeFiles               = "files"
+	TypeGCPCloudStorage     = "gcp_cloud_storage"
+	TypeGCPPubSub           = "gcp_pubsub"
+	TypeGenerate            = "generate"
+	TypeGraphQLSubscription = "graphql_subscription"
+	TypeGRPCServer          = "grpc_server"
+	TypeHDFS                = "hdfs"
+	TypeHTTPClient          = "http_client"
+	TypeHTTPServer          = "http_server"
+	TypeIMAP                = "imap"
+	TypeInproc              = "inproc"
+	TypeKafka               = "kafka"
+	TypeKafkaBalanced       = "kafka_balanced"
+	TypeKinesis             = "kinesis"
+	TypeKinesisBalanced     = "kinesis_balanced"
+	TypeMongoDBChangeStream = "mongodb_changestream"
+	TypeMQTT                = "mqtt"
+	TypeMySQLCDC            = "mysql_cdc"
+	TypeNanomsg             = "nanomsg"
+	TypeNATS                = "nats"
+	TypeNATSStream          = "nats_stream"
+	TypeNSQ                 = "nsq"
+	TypeParquet             = "parquet"
+	TypePostgresCDC         = "postgres_cdc"
+	TypePulsar              = "pulsar"
+	TypeReadUntil           = "read_until"
+	TypeRedisList           = "redis_list"
+	TypeRedisPubSub         = "redis_pubsub"
+	TypeRedisStreams        = "redis_streams"
+	TypeS3                  = "s3"
+	TypeSequence            = "sequence"
+	TypeSFTP                = "sftp"
+	TypeSQLSelect           = "sql_select"
+	TypeSQS                 = "sqs"
+	TypeSSE                 = "sse"
+	TypeSTDIN               = "stdin"
+	TypeSubprocess          = "subprocess"
+	TypeSyslogServer        = "syslog_server"
+	TypeTCP                 = "tcp"
+	TypeTCPServer           = "tcp_server"
+	TypeUDPServer           = "udp_server"
+	TypeWebsocket           = "websocket"
+	TypeZMQ4                = "zmq4"
+	TypeZMQ4N               = "zmq4n"
 )
 
 //------------------------------------------------------------------------------
 
 // Config is the all encompassing configuration struct for all input types.
 type Config struct {
-	Type            string                       `json:"type" yaml:"type"`
-	AMQP            reader.AMQPConfig            `json:"amqp" yaml:"amqp"`
-	AMQP09          reader.AMQP09Config          `json:"amqp_0_9" yaml:"amqp_0_9"`
-	Broker          BrokerConfig                 `json:"broker" yaml:"broker"`
-	Dynamic         DynamicConfig                `json:"dynamic" yaml:"dynamic"`
-	File            FileConfig                   `json:"file" yaml:"file"`
-	Files           reader.FilesConfig           `json:"files" yaml:"files"`
-	GCPPubSub       reader.GCPPubSubConfig       `json:"gcp_pubsub" yaml:"gcp_pubsub"`
-	HDFS            reader.HDFSConfig            `json:"hdfs" yaml:"hdfs"`
-	HTTPClient      HTTPClientConfig             `json:"http_client" yaml:"http_client"`
-	HTTPServer      HTTPServerConfig             `json:"http_server" yaml:"http_server"`
-	Inproc          InprocConfig                 `json:"inproc" yaml:"inproc"`
-	Kafka           reader.KafkaConfig           `json:"kafka" yaml:"kafka"`
-	KafkaBalanced   reader.KafkaBalancedConfig   `json:"kafka_balanced" yaml:"kafka_balanced"`
-	Kinesis         reader.KinesisConfig         `json:"kinesis" yaml:"kinesis"`
-	KinesisBalanced reader.KinesisBalancedConfig `json:"kinesis_balanced" yaml:"kinesis_balanced"`
-	MQTT            reader.MQTTConfig            `json:"mqtt" yaml:"mqtt"`
-	Nanomsg         reader.ScaleProtoConfig      `json:"nanomsg" yaml:"nanomsg"`
-	NATS            reader.NATSConfig            `json:"nats" yaml:"nats"`
-	NATSStream      reader.NATSStreamConfig      `json:"nats_stream" yaml:"nats_stream"`
-	NSQ             reader.NSQConfig             `json:"nsq" yaml:"nsq"`
-	Plugin          interface{}                  `json:"plugin,omitempty" yaml:"plugin,omitempty"`
-	ReadUntil       ReadUntilConfig              `json:"read_until" yaml:"read_until"`
-	RedisList       reader.RedisListConfig       `json:"redis_list" yaml:"redis_list"`
-	RedisPubSub     reader.RedisPubSubConfig     `json:"redis_pubsub" yaml:"redis_pubsub"`
-	RedisStreams    reader.RedisStreamsConfig    `json:"redis_streams" yaml:"redis_streams"`
-	S3              reader.AmazonS3Config        `json:"s3" yaml:"s3"`
-	SQS             reader.AmazonSQSConfig       `json:"sqs" yaml:"sqs"`
-	STDIN           STDINConfig                  `json:"stdin" yaml:"stdin"`
-	TCP             TCPConfig                    `json:"tcp" yaml:"tcp"`
-	TCPServer       TCPServerConfig              `json:"tcp_server" yaml:"tcp_server"`
-	UDPServer       UDPServerConfig              `json:"udp_server" yaml:"udp_server"`
-	Websocket       reader.WebsocketConfig       `json:"websocket" yaml:"websocket"`
-	ZMQ4            *reader.ZMQ4Config           `json:"zmq4,omitempty" yaml:"zmq4,omitempty"`
-	Processors      []processor.Config           `json:"processors" yaml:"processors"`
+	Type                string                           `json:"type" yaml:"type"`
+	AMQP                reader.AMQPConfig                `json:"amqp" yaml:"amqp"`
+	AMQP09              reader.AMQP09Config              `json:"amqp_0_9" yaml:"amqp_0_9"`
+	AzureBlobStorage    reader.AzureBlobStorageConfig    `json:"azure_blob_storage" yaml:"azure_blob_storage"`
+	Broker              BrokerConfig                     `json:"broker" yaml:"broker"`
+	Cron                CronConfig                       `json:"cron" yaml:"cron"`
+	CSV                 reader.CSVConfig                 `json:"csv" yaml:"csv"`
+	Dynamic             DynamicConfig                    `json:"dynamic" yaml:"dynamic"`
+	DynamoDBStreams     reader.DynamoDBStreamsConfig     `json:"dynamodb_streams" yaml:"dynamodb_streams"`
+	File                FileConfig                       `json:"file" yaml:"file"`
+	Files               reader.FilesConfig               `json:"files" yaml:"files"`
+	GCPCloudStorage     reader.GCPCloudStorageConfig     `json:"gcp_cloud_storage" yaml:"gcp_cloud_storage"`
+	GCPPubSub           reader.GCPPubSubConfig           `json:"gcp_pubsub" yaml:"gcp_pubsub"`
+	Generate            GenerateConfig                   `json:"generate" yaml:"generate"`
+	GraphQLSubscription reader.GraphQLSubscriptionConfig `json:"graphql_subscription" yaml:"graphql_subscription"`
+	GRPCServer          GRPCServerConfig                 `json:"grpc_server" yaml:"grpc_server"`
+	HDFS                reader.HDFSConfig                `json:"hdfs" yaml:"hdfs"`
+	HTTPClient          HTTPClientConfig                 `json:"http_client" yaml:"http_client"`
+	HTTPServer          HTTPServerConfig                 `json:"http_server" yaml:"http_server"`
+	IMAP                reader.IMAPConfig                `json:"imap" yaml:"imap"`
+	Inproc              InprocConfig                     `json:"inproc" yaml:"inproc"`
+	Kafka               reader.KafkaConfig               `json:"kafka" yaml:"kafka"`
+	KafkaBalanced       reader.KafkaBalancedConfig       `json:"kafka_balanced" yaml:"kafka_balanced"`
+	Kinesis             reader.KinesisConfig             `json:"kinesis" yaml:"kinesis"`
+	KinesisBalanced     reader.KinesisBalancedConfig     `json:"kinesis_balanced" yaml:"kinesis_balanced"`
+	MongoDBChangeStream reader.MongoDBChangeStreamConfig `json:"mongodb_changestream" yaml:"mongodb_changestream"`
+	MQTT                reader.MQTTConfig                `json:"mqtt" yaml:"mqtt"`
+	MySQLCDC            reader.MySQLCDCConfig            `json:"mysql_cdc" yaml:"mysql_cdc"`
+	Nanomsg             reader.ScaleProtoConfig          `json:"nanomsg" yaml:"nanomsg"`
+	NATS                reader.NATSConfig                `json:"nats" yaml:"nats"`
+	NATSStream          reader.NATSStreamConfig          `json:"nats_stream" yaml:"nats_stream"`
+	NSQ                 reader.NSQConfig                 `json:"nsq" yaml:"nsq"`
+	Parquet             reader.ParquetConfig             `json:"parquet" yaml:"parquet"`
+	Plugin              interface{}                      `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	PostgresCDC         reader.PostgresCDCConfig         `json:"postgres_cdc" yaml:"postgres_cdc"`
+	Pulsar              reader.PulsarConfig              `json:"pulsar" yaml:"pulsar"`
+	ReadUntil           ReadUntilConfig                  `json:"read_until" yaml:"read_until"`
+	RedisList           reader.RedisListConfig           `json:"redis_list" yaml:"redis_list"`
+	RedisPubSub         reader.RedisPubSubConfig         `json:"redis_pubsub" yaml:"redis_pubsub"`
+	RedisStreams        reader.RedisStreamsConfig        `json:"redis_streams" yaml:"redis_streams"`
+	S3                  reader.AmazonS3Config            `json:"s3" yaml:"s3"`
+	Sequence            SequenceConfig                   `json:"sequence" yaml:"sequence"`
+	SFTP                reader.SFTPConfig                `json:"sftp" yaml:"sftp"`
+	SQLSelect           reader.SQLSelectConfig           `json:"sql_select" yaml:"sql_select"`
+	SQS                 reader.AmazonSQSConfig           `json:"sqs" yaml:"sqs"`
+	SSE                 reader.SSEConfig                 `json:"sse" yaml:"sse"`
+	STDIN               STDINConfig                      `json:"stdin" yaml:"stdin"`
+	Subprocess          reader.SubprocessConfig          `json:"subprocess" yaml:"subprocess"`
+	SyslogServer        SyslogServerConfig               `json:"syslog_server" yaml:"syslog_server"`
+	TCP                 TCPConfig                        `json:"tcp" yaml:"tcp"`
+	TCPServer           TCPServerConfig                  `json:"tcp_server" yaml:"tcp_server"`
+	UDPServer           UDPServerConfig                  `json:"udp_server" yaml:"udp_server"`
+	Websocket           reader.WebsocketConfig           `json:"websocket" yaml:"websocket"`
+	ZMQ4                *reader.ZMQ4Config               `json:"zmq4,omitempty" yaml:"zmq4,omitempty"`
+	ZMQ4N               reader.ZMQ4NConfig               `json:"zmq4n" yaml:"zmq4n"`
+	Processors          []processor.Config               `json:"processors" yaml:"processors"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:            "stdin",
-		AMQP:            reader.NewAMQPConfig(),
-		AMQP09:          reader.NewAMQP09Config(),
-		Broker:          NewBrokerConfig(),
-		Dynamic:         NewDynamicConfig(),
-		File:            NewFileConfig(),
-		Files:           reader.NewFilesConfig(),
-		GCPPubSub:       reader.NewGCPPubSubConfig(),
-		HDFS:            reader.NewHDFSConfig(),
-		HTTPClient:      NewHTTPClientConfig(),
-		HTTPServer:      NewHTTPServerConfig(),
-		Inproc:          NewInprocConfig(),
-		Kafka:           reader.NewKafkaConfig(),
-		KafkaBalanced:   reader.NewKafkaBalancedConfig(),
-		Kinesis:         reader.NewKinesisConfig(),
-		KinesisBalanced: reader.NewKinesisBalancedConfig(),
-		MQTT:            reader.NewMQTTConfig(),
-		Nanomsg:         reader.NewScaleProtoConfig(),
-		NATS:            reader.NewNATSConfig(),
-		NATSStream:      reader.NewNATSStreamConfig(),
-		NSQ:             reader.NewNSQConfig(),
-		Plugin:          nil,
-		ReadUntil:       NewReadUntilConfig(),
-		RedisList:       reader.NewRedisListConfig(),
-		RedisPubSub:     reader.NewRedisPubSubConfig(),
-		RedisStreams:    reader.NewRedisStreamsConfig(),
-		S3:              reader.NewAmazonS3Config(),
-		SQS:             reader.NewAmazonSQSConfig(),
-		STDIN:           NewSTDINConfig(),
-		TCP:             NewTCPConfig(),
-		TCPServer:       NewTCPServerConfig(),
-		UDPServer:       NewUDPServerConfig(),
-		Websocket:       reader.NewWebsocketConfig(),
-		ZMQ4:            reader.NewZMQ4Config(),
-		Processors:      []processor.Config{},
+		Type:                "stdin",
+		AMQP:                reader.NewAMQPConfig(),
+		AMQP09:              reader.NewAMQP09Config(),
+		AzureBlobStorage:    reader.NewAzureBlobStorageConfig(),
+		Broker:              NewBrokerConfig(),
+		Cron:                NewCronConfig(),
+		CSV:                 reader.NewCSVConfig(),
+		Dynamic:             NewDynamicConfig(),
+		DynamoDBStreams:     reader.NewDynamoDBStreamsConfig(),
+		File:                NewFileConfig(),
+		Files:               reader.NewFilesConfig(),
+		GCPCloudStorage:     reader.NewGCPCloudStorageConfig(),
+		GCPPubSub:           reader.NewGCPPubSubConfig(),
+		Generate:            NewGenerateConfig(),
+		GraphQLSubscription: reader.NewGraphQLSubscriptionConfig(),
+		GRPCServer:          NewGRPCServerConfig(),
+		HDFS:                reader.NewHDFSConfig(),
+		HTTPClient:          NewHTTPClientConfig(),
+		HTTPServer:          NewHTTPServerConfig(),
+		IMAP:                reader.NewIMAPConfig(),
+		Inproc:              NewInprocConfig(),
+		Kafka:               reader.NewKafkaConfig(),
+		KafkaBalanced:       reader.NewKafkaBalancedConfig(),
+		Kinesis:             reader.NewKinesisConfig(),
+		KinesisBalanced:     reader.NewKinesisBalancedConfig(),
+		MongoDBChangeStream: reader.NewMongoDBChangeStreamConfig(),
+		MQTT:                reader.NewMQTTConfig(),
+		MySQLCDC:            reader.NewMySQLCDCConfig(),
+		Nanomsg:             reader.NewScaleProtoConfig(),
+		NATS:                reader.NewNATSConfig(),
+		NATSStream:          reader.NewNATSStreamConfig(),
+		NSQ:                 reader.NewNSQConfig(),
+		Parquet:             reader.NewParquetConfig(),
+		Plugin:              nil,
+		PostgresCDC:         reader.NewPostgresCDCConfig(),
+		Pulsar:              reader.NewPulsarConfig(),
+		ReadUntil:           NewReadUntilConfig(),
+		RedisList:           reader.NewRedisListConfig(),
+		RedisPubSub:         reader.NewRedisPubSubConfig(),
+		RedisStreams:        reader.NewRedisStreamsConfig(),
+		S3:                  reader.NewAmazonS3Config(),
+		Sequence:            NewSequenceConfig(),
+		SFTP:                reader.NewSFTPConfig(),
+		SQLSelect:           reader.NewSQLSelectConfig(),
+		SQS:                 reader.NewAmazonSQSConfig(),
+		SSE:                 reader.NewSSEConfig(),
+		STDIN:               NewSTDINConfig(),
+		Subprocess:          reader.NewSubprocessConfig(),
+		SyslogServer:        NewSyslogServerConfig(),
+		TCP:                 NewTCPConfig(),
+		TCPServer:           NewTCPServerConfig(),
+		UDPServer:           NewUDPServerConfig(),
+		Websocket:           reader.NewWebsocketConfig(),
+		ZMQ4:                reader.NewZMQ4Config(),
+		ZMQ4N:               reader.NewZMQ4NConfig(),
+		Processors:          []processor.Config{},
 	}
 }
 
@@ -0,0 +1,189 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package input
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/cron"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeCron] = TypeSpec{
+		constructor: NewCron,
+		description: `
+Emits a message each time a cron expression is satisfied, which is useful for
+triggering scheduled pipelines, such as those that extract data with the
+` + "`http`" + ` or ` + "`sql`" + ` processors, without an external scheduler.
+
+The ` + "`expression`" + ` consists of five space separated fields (minute,
+hour, day of month, month and day of week) with an optional leading seconds
+field. Fields support lists, ranges, steps and the names of months and days,
+and the descriptors ` + "`@yearly`, `@monthly`, `@weekly`, `@daily` and `@hourly`" + `
+are also supported.
+
+The ` + "`timezone`" + ` is the name of an IANA time zone, such as
+` + "`Europe/London`" + `, in which the expression is evaluated, or ` + "`Local`" + `
+for the time zone of the host.
+
+Each message is created from a ` + "`content`" + ` string, which can contain
+[function interpolations](../config_interpolation.md#functions) that are
+resolved for each message. If the pipeline is still busy when a trigger is due
+then the trigger is skipped rather than queued.
+
+` + "``` yaml" + `
+input:
+  cron:
+    expression: '0 */6 * * *'
+    timezone: UTC
+    content: '{"triggered_at":"${!timestamp_utc}"}'
+` + "```" + `
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- cron_scheduled_time
+` + "```" + `
+
+Where ` + "`cron_scheduled_time`" + ` is the RFC3339 time at which the trigger
+was scheduled.
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// CronConfig contains configuration for the Cron input type.
+type CronConfig struct {
+	Expression string `json:"expression" yaml:"expression"`
+	Timezone   string `json:"timezone" yaml:"timezone"`
+	Content    string `json:"content" yaml:"content"`
+}
+
+// NewCronConfig creates a new CronConfig with default values.
+func NewCronConfig() CronConfig {
+	return CronConfig{
+		Expression: "",
+		Timezone:   "UTC",
+		Content:    "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewCron creates a new Cron input type.
+func NewCron(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	c, err := newCronReader(conf.Cron)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeCron, true, c, log, stats)
+}
+
+//------------------------------------------------------------------------------
+
+type cronReader struct {
+	content  *text.InterpolatedBytes
+	schedule *cron.Schedule
+	location *time.Location
+
+	next  time.Time
+	timer *time.Timer
+}
+
+func newCronReader(conf CronConfig) (*cronReader, error) {
+	c := &cronReader{
+		content:  text.NewInterpolatedBytes([]byte(conf.Content)),
+		location: time.UTC,
+	}
+	if len(conf.Expression) == 0 {
+		return nil, fmt.Errorf("an expression must be provided")
+	}
+	var err error
+	if c.schedule, err = cron.Parse(conf.Expression); err != nil {
+		return nil, fmt.Errorf("failed to parse expression: %v", err)
+	}
+	if len(conf.Timezone) > 0 {
+		if c.location, err = time.LoadLocation(conf.Timezone); err != nil {
+			return nil, fmt.Errorf("failed to load timezone: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// ConnectWithContext does nothing as no connection is required.
+func (c *cronReader) ConnectWithContext(ctx context.Context) error {
+	return nil
+}
+
+// ReadWithContext waits for the next time the expression is satisfied and
+// emits a message.
+func (c *cronReader) ReadWithContext(ctx context.Context) (types.Message, reader.AsyncAckFn, error) {
+	if c.timer == nil {
+		if c.next = c.schedule.Next(time.Now().In(c.location)); c.next.IsZero() {
+			return nil, nil, types.ErrTypeClosed
+		}
+		c.timer = time.NewTimer(time.Until(c.next))
+	}
+
+	select {
+	case <-c.timer.C:
+	case <-ctx.Done():
+		return nil, nil, types.ErrTimeout
+	}
+	c.timer = nil
+
+	part := message.NewPart(c.content.Get(message.New(nil)))
+	part.Metadata().Set("cron_scheduled_time", c.next.Format(time.RFC3339))
+
+	msg := message.New(nil)
+	msg.Append(part)
+	return msg, func(context.Context, types.Response) error {
+		return nil
+	}, nil
+}
+
+// CloseAsync stops the scheduling of messages.
+func (c *cronReader) CloseAsync() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// WaitForClose blocks until the reader has closed.
+func (c *cronReader) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,100 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package input
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestCronBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeCron
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing expression")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeCron
+	conf.Cron.Expression = "* * *"
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad expression")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeCron
+	conf.Cron.Expression = "* * * * *"
+	conf.Cron.Timezone = "Nowhere/Special"
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad timezone")
+	}
+}
+
+func TestCronTriggers(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeCron
+	conf.Cron.Expression = "* * * * * *"
+	conf.Cron.Content = "foo ${!count:cron_test}"
+
+	in, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prev time.Time
+	for i, exp := range []string{"foo 1", "foo 2"} {
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-in.TransactionChan():
+			if !open {
+				t.Fatal("Transaction chan closed early")
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+		if act := string(tran.Payload.Get(0).Get()); act != exp {
+			t.Errorf("Wrong content %v: %v != %v", i, act, exp)
+		}
+		scheduled, err := time.Parse(time.RFC3339, tran.Payload.Get(0).Metadata().Get("cron_scheduled_time"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !scheduled.After(prev) {
+			t.Errorf("Scheduled time %v not after previous %v", scheduled, prev)
+		}
+		prev = scheduled
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+	}
+
+	in.CloseAsync()
+	if err := in.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
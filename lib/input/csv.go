@@ -0,0 +1,78 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeCSV] = TypeSpec{
+		constructor: NewCSV,
+		description: `
+Reads one or more CSV files as structured records. Each path can be a glob
+pattern such as ` + "`/data/*.csv`" + `, and files are consumed in the order
+that they are listed and matched.
+
+When ` + "`parse_header_row`" + ` is ` + "`true`" + ` the first row of each
+file is read as the names of the columns, and each following record becomes a
+JSON object keyed by those names. Otherwise, each record becomes a JSON array.
+
+Fields that parse as integers, floats or booleans are emitted as JSON numbers
+and booleans, and all other fields are emitted as strings. Records that cannot
+be parsed, or that have a different number of fields to the header row, are
+logged and skipped.
+
+The ` + "`delimiter`" + ` must be a single character. When
+` + "`lazy_quotes`" + ` is ` + "`true`" + ` a quote may appear in an unquoted
+field and a non-doubled quote may appear in a quoted field.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- path
+- csv_record_index
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewCSV creates a new CSV input type.
+func NewCSV(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewCSV(conf.CSV, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeCSV, true, reader.NewAsyncPreserver(r), log, stats)
+}
+
+//------------------------------------------------------------------------------
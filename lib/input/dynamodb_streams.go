@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeDynamoDBStreams] = TypeSpec{
+		constructor: NewDynamoDBStreams,
+		description: `
+Receive change records from a DynamoDB stream. The stream can either be
+specified by the name of its ` + "`table`" + `, in which case the latest stream
+of the table is consumed, or its ` + "`stream_arn`" + `.
+
+All shards of the stream are consumed, and the records of each call to
+` + "`GetRecords`" + ` (up to ` + "`limit`" + `) are emitted as a batch. When a
+shard is closed its children are only consumed once the parent has been consumed
+entirely, which preserves the ordering of changes to each item.
+
+Each record is converted into a JSON document of the following form, where the
+keys and images of the item are converted from DynamoDB attribute values into
+regular JSON values:
+
+` + "``` json" + `
+{
+  "event_id": "...",
+  "event_name": "MODIFY",
+  "sequence_number": "...",
+  "approximate_creation_date_time": 1580000000,
+  "keys": { "id": "foo" },
+  "new_image": { "id": "foo", "count": 2 },
+  "old_image": { "id": "foo", "count": 1 }
+}
+` + "```" + `
+
+Images are only included when the stream view type of the table provides them,
+therefore the table should be configured with the ` + "`NEW_AND_OLD_IMAGES`" + `
+view type.
+
+### Checkpoints
+
+It's possible to use DynamoDB for persisting the sequence of each shard by
+setting ` + "`checkpoint_table`" + `. Sequences will then be tracked per
+` + "`client_id`" + ` per shard. When using this mode you should create a table
+with ` + "`namespace`" + ` as the primary key and ` + "`shard_id`" + ` as a sort
+key. Shards that have been consumed entirely are marked with the sequence
+` + "`SHARD_END`" + `. Shards are not balanced across instances, therefore each
+instance should use a distinct ` + "`client_id`" + `.
+
+Use the ` + "`batching`" + ` fields to configure an optional
+[batching policy](../batching.md#batch-policy).
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- dynamodb_streams_shard
+- dynamodb_streams_stream_arn
+- dynamodb_streams_event_id
+- dynamodb_streams_event_name
+- dynamodb_streams_sequence_number
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).
+
+### Credentials
+
+By default Benthos will use a shared credentials file when connecting to AWS
+services. It's also possible to set them explicitly at the component level,
+allowing you to transfer data across accounts. You can find out more
+[in this document](../aws.md).`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			return sanitiseWithBatch(conf.DynamoDBStreams, conf.DynamoDBStreams.Batching)
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewDynamoDBStreams creates a new DynamoDB Streams input type.
+func NewDynamoDBStreams(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	var a reader.Async
+	var err error
+	if a, err = reader.NewDynamoDBStreams(conf.DynamoDBStreams, log, stats); err != nil {
+		return nil, err
+	}
+	a = reader.NewAsyncPreserver(a)
+	if a, err = reader.NewAsyncBatcher(conf.DynamoDBStreams.Batching, a, mgr, log, stats); err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeDynamoDBStreams, true, a, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -21,6 +21,7 @@
 package input
 
 import (
+	"errors"
 	"io"
 	"os"
 
@@ -40,7 +41,38 @@ The file type reads input from a file. If multipart is set to false each line
 is read as a separate message. If multipart is set to true each line is read as
 a message part, and an empty line indicates the end of a message.
 
-If the delimiter field is left empty then line feed (\n) is used.`,
+If the delimiter field is left empty then line feed (\n) is used.
+
+The fields ` + "`multipart`, `max_buffer` and `delimiter`" + ` only apply to
+the default codec ` + "`lines`" + `. When a different ` + "`codec`" + ` is
+set each part it produces is read as a separate message.
+
+` + reader.CodecDocumentation + `
+
+### Tailing
+
+When the field ` + "`tail`" + ` is set to ` + "`true`" + ` the input follows
+files as they grow rather than shutting down once the end of a file is
+reached. In this mode the field ` + "`path`" + ` may be a glob pattern such as
+` + "`/var/log/*.log`" + `, and is rescanned every ` + "`poll_period`" + ` in
+order to pick up new files.
+
+Files that are rotated (moved and replaced by a new file) are read until the
+end before switching to the replacement, and files that are truncated are read
+again from the beginning.
+
+Files that exist when tailing begins are read from the beginning if
+` + "`start_from_beginning`" + ` is ` + "`true`" + `, otherwise only new data
+is read. Files discovered afterwards are always read from the beginning.
+
+If the field ` + "`cache`" + ` is set to the name of a
+[cache resource](../caches/README.md) then the offset of each file is stored
+within it, keyed by the file path, once all prior messages of the file have
+been acknowledged. These offsets are used in order to resume where the input
+left off after a restart.
+
+Each message read in tail mode has the metadata field ` + "`path`" + ` set to
+the file it was read from.`,
 	}
 }
 
@@ -49,18 +81,30 @@ If the delimiter field is left empty then line feed (\n) is used.`,
 // FileConfig contains configuration values for the File input type.
 type FileConfig struct {
 	Path      string `json:"path" yaml:"path"`
+	Codec     string `json:"codec" yaml:"codec"`
 	Multipart bool   `json:"multipart" yaml:"multipart"`
 	MaxBuffer int    `json:"max_buffer" yaml:"max_buffer"`
 	Delim     string `json:"delimiter" yaml:"delimiter"`
+
+	Tail               bool   `json:"tail" yaml:"tail"`
+	PollPeriod         string `json:"poll_period" yaml:"poll_period"`
+	Cache              string `json:"cache" yaml:"cache"`
+	StartFromBeginning bool   `json:"start_from_beginning" yaml:"start_from_beginning"`
 }
 
 // NewFileConfig creates a new FileConfig with default values.
 func NewFileConfig() FileConfig {
 	return FileConfig{
 		Path:      "",
+		Codec:     "lines",
 		Multipart: false,
 		MaxBuffer: 1000000,
 		Delim:     "",
+
+		Tail:               false,
+		PollPeriod:         "1s",
+		Cache:              "",
+		StartFromBeginning: true,
 	}
 }
 
@@ -68,10 +112,37 @@ func NewFileConfig() FileConfig {
 
 // NewFile creates a new File input type.
 func NewFile(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	if conf.File.Tail {
+		if len(conf.File.Codec) > 0 && conf.File.Codec != "lines" {
+			return nil, errors.New("only the lines codec is supported when tailing files")
+		}
+		return newFileTail(conf, mgr, log, stats)
+	}
+
 	file, err := os.Open(conf.File.Path)
 	if err != nil {
 		return nil, err
 	}
+
+	if len(conf.File.Codec) > 0 && conf.File.Codec != "lines" {
+		rdr, err := reader.NewCodecStream(
+			conf.File.Codec, conf.File.Path,
+			func() (io.Reader, error) {
+				if file == nil {
+					return nil, io.EOF
+				}
+				sendFile := file
+				file = nil
+				return sendFile, nil
+			},
+			func() {},
+		)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return NewAsyncReader(TypeFile, true, reader.NewAsyncPreserver(rdr), log, stats)
+	}
 	delim := conf.File.Delim
 	if len(delim) == 0 {
 		delim = "\n"
@@ -100,4 +171,20 @@ func NewFile(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type
 	return NewAsyncReader(TypeFile, true, reader.NewAsyncPreserver(rdr), log, stats)
 }
 
+func newFileTail(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	rdr, err := reader.NewFileTail(reader.FileTailConfig{
+		Path:               conf.File.Path,
+		Multipart:          conf.File.Multipart,
+		MaxBuffer:          conf.File.MaxBuffer,
+		Delim:              conf.File.Delim,
+		PollPeriod:         conf.File.PollPeriod,
+		Cache:              conf.File.Cache,
+		StartFromBeginning: conf.File.StartFromBeginning,
+	}, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeFile, true, reader.NewAsyncPreserver(rdr), log, stats)
+}
+
 //------------------------------------------------------------------------------
@@ -180,3 +180,118 @@ func TestFileMultiPart(t *testing.T) {
 		t.Error("Timed out waiting for channel close")
 	}
 }
+
+func TestFileCodec(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "benthos_file_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpfile.Write([]byte(`[{"id":1},"second message",3]`))
+
+	conf := NewConfig()
+	conf.File.Path = tmpfile.Name()
+	conf.File.Codec = "json_array"
+
+	f, err := NewFile(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		f.CloseAsync()
+		if err := f.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for _, msg := range []string{`{"id":1}`, `"second message"`, `3`} {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-f.TransactionChan():
+			if !open {
+				t.Fatal("channel closed early")
+			} else if res := string(ts.Payload.Get(0).Get()); res != msg {
+				t.Errorf("Wrong result, %v != %v", res, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message")
+		}
+		select {
+		case ts.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Error("Timed out waiting for response")
+		}
+	}
+
+	select {
+	case _, open := <-f.TransactionChan():
+		if open {
+			t.Error("Channel not closed at end of messages")
+		}
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for channel close")
+	}
+}
+
+func TestFileCodecTail(t *testing.T) {
+	conf := NewConfig()
+	conf.File.Path = "/tmp/foo.json"
+	conf.File.Codec = "json_array"
+	conf.File.Tail = true
+
+	if _, err := NewFile(conf, nil, log.Noop(), metrics.DudType{}); err == nil {
+		t.Error("Expected error from tailing with a non-lines codec")
+	}
+}
+
+func TestFileTail(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "benthos_file_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpfile.Write([]byte("first message\n"))
+
+	conf := NewConfig()
+	conf.File.Path = tmpfile.Name()
+	conf.File.Tail = true
+	conf.File.PollPeriod = "10ms"
+
+	f, err := NewFile(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		f.CloseAsync()
+		if err := f.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	go func() {
+		<-time.After(time.Millisecond * 100)
+		tmpfile.Write([]byte("second message\n"))
+	}()
+
+	for _, msg := range []string{"first message", "second message"} {
+		var ts types.Transaction
+		select {
+		case ts = <-f.TransactionChan():
+			if res := string(ts.Payload.Get(0).Get()); res != msg {
+				t.Errorf("Wrong result, %v != %v", res, msg)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out waiting for message")
+		}
+		select {
+		case ts.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Error("Timed out waiting for response")
+		}
+	}
+}
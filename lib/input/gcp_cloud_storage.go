@@ -0,0 +1,94 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeGCPCloudStorage] = TypeSpec{
+		constructor: NewGCPCloudStorage,
+		description: `
+Downloads objects in a Google Cloud Storage bucket, optionally filtered by a
+prefix. If a ` + "`pubsub_subscription`" + ` has been configured then only
+objects read from its notifications will be downloaded. Otherwise, the entire
+list of objects found when this input is created will be downloaded.
+
+Credentials are found using
+[Application Default Credentials](https://cloud.google.com/docs/authentication/production).
+
+### Pub/Sub Notifications
+
+The subscription must belong to a topic that receives
+[Pub/Sub notifications](https://cloud.google.com/storage/docs/pubsub-notifications)
+of the bucket. Only ` + "`OBJECT_FINALIZE`" + ` notifications of objects within
+the bucket and prefix are processed, and all others are acknowledged and
+ignored. Notifications are acknowledged once their objects have been processed,
+which ensures at-least-once delivery.
+
+When ` + "`delete_objects`" + ` is true objects are deleted once all of their
+messages have been successfully processed.
+
+` + reader.CodecDocumentation + `
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- gcs_key
+- gcs_bucket
+- gcs_last_modified (RFC3339)
+- gcs_last_modified_unix
+- gcs_content_type*
+- gcs_content_encoding*
+
+* Only added when set on the object
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewGCPCloudStorage creates a new GCP Cloud Storage input type.
+func NewGCPCloudStorage(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewGCPCloudStorage(conf.GCPCloudStorage, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(
+		TypeGCPCloudStorage,
+		true,
+		reader.NewAsyncPreserver(r),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
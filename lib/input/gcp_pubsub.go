@@ -42,12 +42,21 @@ instance of this input can utilise any number of threads within a
 Use the ` + "`batching`" + ` fields to configure an optional
 [batching policy](../batching.md#batch-policy).
 
+### Exactly-Once Delivery
+
+When consuming from a subscription with exactly-once delivery enabled set the
+field ` + "`exactly_once_delivery`" + ` to ` + "`true`" + `. Messages are then
+pulled and acknowledged synchronously, and a message is only considered
+delivered once its acknowledgement has been confirmed by the service. A failed
+acknowledgement is reported as an error and the message will be redelivered.
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
 
 ` + "``` text" + `
 - gcp_pubsub_publish_time_unix
+- gcp_pubsub_ordering_key (when set)
 - All message attributes
 ` + "```" + `
 
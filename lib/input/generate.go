@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeGenerate] = TypeSpec{
+		constructor: NewGenerate,
+		description: `
+Generates messages at a given interval from a ` + "`content`" + ` string, which
+can contain
+[function interpolations](../config_interpolation.md#functions) that are
+resolved for each message. This is useful for load testing pipelines and for
+triggering pipelines on a schedule, such as those that poll an API with the
+` + "`http`" + ` processor.
+
+The ` + "`interval`" + ` is a duration that determines the period between
+messages, where the first message is generated immediately. If the interval is
+empty messages are generated as fast as the pipeline is able to consume them.
+
+When ` + "`count`" + ` is greater than zero the input closes once that number
+of messages have been generated, which shuts down the pipeline once they have
+been processed.
+
+` + "``` yaml" + `
+input:
+  generate:
+    content: '{"id":"${!uuid_v4}","at":${!timestamp_unix}}'
+    interval: 5s
+    count: 0
+` + "```" + ``,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// GenerateConfig contains configuration for the Generate input type.
+type GenerateConfig struct {
+	Content  string `json:"content" yaml:"content"`
+	Interval string `json:"interval" yaml:"interval"`
+	Count    int    `json:"count" yaml:"count"`
+}
+
+// NewGenerateConfig creates a new GenerateConfig with default values.
+func NewGenerateConfig() GenerateConfig {
+	return GenerateConfig{
+		Content:  "",
+		Interval: "1s",
+		Count:    0,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewGenerate creates a new Generate input type.
+func NewGenerate(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	g, err := newGenerateReader(conf.Generate)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeGenerate, true, g, log, stats)
+}
+
+//------------------------------------------------------------------------------
+
+type generateReader struct {
+	content   *text.InterpolatedBytes
+	interval  time.Duration
+	limit     int
+	remaining int
+	timer     *time.Timer
+}
+
+func newGenerateReader(conf GenerateConfig) (*generateReader, error) {
+	g := &generateReader{
+		content:   text.NewInterpolatedBytes([]byte(conf.Content)),
+		limit:     conf.Count,
+		remaining: conf.Count,
+	}
+	if len(conf.Interval) > 0 {
+		var err error
+		if g.interval, err = time.ParseDuration(conf.Interval); err != nil {
+			return nil, fmt.Errorf("failed to parse interval: %v", err)
+		}
+	}
+	if conf.Count < 0 {
+		return nil, fmt.Errorf("count must not be negative: %v", conf.Count)
+	}
+	return g, nil
+}
+
+// ConnectWithContext does nothing as no connection is required.
+func (g *generateReader) ConnectWithContext(ctx context.Context) error {
+	return nil
+}
+
+// ReadWithContext generates a message once the interval since the previous
+// message has elapsed.
+func (g *generateReader) ReadWithContext(ctx context.Context) (types.Message, reader.AsyncAckFn, error) {
+	if g.limit > 0 && g.remaining <= 0 {
+		return nil, nil, types.ErrTypeClosed
+	}
+
+	if g.timer != nil {
+		select {
+		case <-g.timer.C:
+		case <-ctx.Done():
+			return nil, nil, types.ErrTimeout
+		}
+	}
+	if g.interval > 0 {
+		g.timer = time.NewTimer(g.interval)
+	}
+
+	// A fresh message is used for each interpolation so that functions such
+	// as count resolve independently.
+	msg := message.New(nil)
+	msg.Append(message.NewPart(g.content.Get(message.New(nil))))
+	if g.limit > 0 {
+		g.remaining--
+	}
+	return msg, func(context.Context, types.Response) error {
+		return nil
+	}, nil
+}
+
+// CloseAsync stops the generation of messages.
+func (g *generateReader) CloseAsync() {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+}
+
+// WaitForClose blocks until the reader has closed.
+func (g *generateReader) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
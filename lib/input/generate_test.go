@@ -0,0 +1,128 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestGenerateBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeGenerate
+	conf.Generate.Interval = "nope"
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad interval")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeGenerate
+	conf.Generate.Count = -1
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad count")
+	}
+}
+
+func TestGenerateCount(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeGenerate
+	conf.Generate.Content = `{"id":"${!count:generate_test}","host":"${!hostname}"}`
+	conf.Generate.Interval = ""
+	conf.Generate.Count = 3
+
+	in, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, exp := range []string{`{"id":"1",`, `{"id":"2",`, `{"id":"3",`} {
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-in.TransactionChan():
+			if !open {
+				t.Fatal("Transaction chan closed early")
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+		if act := string(tran.Payload.Get(0).Get()); !strings.HasPrefix(act, exp) {
+			t.Errorf("Wrong content %v: %v != %v", i, act, exp)
+		}
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+	}
+
+	select {
+	case _, open := <-in.TransactionChan():
+		if open {
+			t.Error("Expected input to close after count")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out")
+	}
+	if err = in.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGenerateInterval(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeGenerate
+	conf.Generate.Content = "foo"
+	conf.Generate.Interval = "50ms"
+
+	in, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		var tran types.Transaction
+		select {
+		case tran = <-in.TransactionChan():
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+		if exp, act := "foo", string(tran.Payload.Get(0).Get()); exp != act {
+			t.Errorf("Wrong content: %v != %v", act, exp)
+		}
+		tran.ResponseChan <- response.NewAck()
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*100 {
+		t.Errorf("Messages generated faster than interval: %v", elapsed)
+	}
+
+	in.CloseAsync()
+	if err = in.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeGraphQLSubscription] = TypeSpec{
+		constructor: NewGraphQLSubscription,
+		description: `
+Connects to a GraphQL server over a websocket, runs a subscription ` + "`query`" + `
+and emits each payload received from the subscription as a message, where the
+payload is a JSON object containing the ` + "`data`" + ` and any
+` + "`errors`" + ` of the result.
+
+The ` + "`protocol`" + ` can be either ` + "`graphql-ws`" + `, the protocol
+of the ` + "`subscriptions-transport-ws`" + ` library, or
+` + "`graphql-transport-ws`" + `, the protocol of the newer
+` + "`graphql-ws`" + ` library.
+
+The ` + "`variables`" + ` of the query can be set as a JSON object, e.g.
+` + "`{\"id\":\"foo\"}`" + `.
+
+### Authentication
+
+Credentials can be provided either as headers of the websocket handshake, by
+setting ` + "`headers`" + ` or one of the authentication methods, or as the
+` + "`connection_params`" + ` payload of the connection initialisation
+message, depending on which the server expects.
+
+If the connection is lost, or the subscription is ended by the server, the
+input reconnects and runs the subscription again.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewGraphQLSubscription creates a new GraphQLSubscription input type.
+func NewGraphQLSubscription(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewGraphQLSubscription(conf.GraphQLSubscription, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(
+		TypeGraphQLSubscription,
+		true,
+		reader.NewAsyncPreserver(r),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
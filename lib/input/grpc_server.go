@@ -0,0 +1,626 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/roundtrip"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	protov1 "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcmeta "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeGRPCServer] = TypeSpec{
+		constructor: NewGRPCServer,
+		description: `
+Receive messages sent over gRPC. The input hosts a single service with a unary
+method and a streaming method, which clients call in order to push messages into
+Benthos. TLS is enabled when key and cert files are specified.
+
+### Methods
+
+#### ` + "`unary_method` (defaults to `Send`)" + `
+
+Each request is consumed as a single message, and the call returns once the
+message has been acknowledged by the pipeline. If the message is rejected, or
+is not acknowledged before ` + "`timeout`" + `, an error status is returned.
+
+#### ` + "`stream_method` (defaults to `Stream`)" + `
+
+Each request received on the stream is consumed as a single message. The next
+request is only read once the previous message has been acknowledged. For
+client streaming methods a single response is sent when the client closes the
+stream, for bidirectional methods a response is sent for each message.
+
+Either method can be disabled by setting it to an empty string.
+
+### Descriptors
+
+By default the raw bytes of each request are used as the message contents and
+empty responses are returned, in which case clients are free to send any
+protobuf message type (or any payload at all when using a custom codec).
+
+When ` + "`descriptor_set`" + ` is set to the path of a file descriptor set,
+which can be created with ` + "`protoc --include_imports --descriptor_set_out`" + `,
+the service and its methods are loaded from it. Requests are then decoded using
+the method input type and converted to JSON documents.
+
+Setting ` + "`reflection`" + ` to ` + "`true`" + ` registers the gRPC server
+reflection service, allowing tools such as ` + "`grpcurl`" + ` to discover the
+service. Reflection requires a descriptor set.
+
+### Responses
+
+It's possible to return a response for each message received using
+[synchronous responses](../sync_responses.md). When a descriptor set is used
+the response is parsed as a JSON document of the method output type.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- grpc_server_method
+- All request metadata (only first values are taken)
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// GRPCServerConfig contains configuration for the GRPCServer input type.
+type GRPCServerConfig struct {
+	Address       string `json:"address" yaml:"address"`
+	Service       string `json:"service" yaml:"service"`
+	UnaryMethod   string `json:"unary_method" yaml:"unary_method"`
+	StreamMethod  string `json:"stream_method" yaml:"stream_method"`
+	DescriptorSet string `json:"descriptor_set" yaml:"descriptor_set"`
+	Reflection    bool   `json:"reflection" yaml:"reflection"`
+	Timeout       string `json:"timeout" yaml:"timeout"`
+	CertFile      string `json:"cert_file" yaml:"cert_file"`
+	KeyFile       string `json:"key_file" yaml:"key_file"`
+}
+
+// NewGRPCServerConfig creates a new GRPCServerConfig with default values.
+func NewGRPCServerConfig() GRPCServerConfig {
+	return GRPCServerConfig{
+		Address:       "0.0.0.0:50051",
+		Service:       "benthos.Ingest",
+		UnaryMethod:   "Send",
+		StreamMethod:  "Stream",
+		DescriptorSet: "",
+		Reflection:    false,
+		Timeout:       "5s",
+		CertFile:      "",
+		KeyFile:       "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// grpcFrame is a raw gRPC message, the payload is passed through the codec of
+// the server untouched.
+type grpcFrame struct {
+	payload []byte
+}
+
+// grpcFrameCodec passes grpcFrame payloads through as they are and falls back
+// to protobuf encoding for all other types, which is required by services such
+// as reflection.
+type grpcFrameCodec struct{}
+
+func (grpcFrameCodec) Marshal(v interface{}) ([]byte, error) {
+	if f, ok := v.(*grpcFrame); ok {
+		return f.payload, nil
+	}
+	if m, ok := v.(protov1.Message); ok {
+		return protov1.Marshal(m)
+	}
+	return nil, fmt.Errorf("unsupported message type: %T", v)
+}
+
+func (grpcFrameCodec) Unmarshal(data []byte, v interface{}) error {
+	if f, ok := v.(*grpcFrame); ok {
+		f.payload = append([]byte(nil), data...)
+		return nil
+	}
+	if m, ok := v.(protov1.Message); ok {
+		return protov1.Unmarshal(data, m)
+	}
+	return fmt.Errorf("unsupported message type: %T", v)
+}
+
+func (grpcFrameCodec) String() string {
+	return "proto"
+}
+
+//------------------------------------------------------------------------------
+
+// grpcMethod describes a method hosted by the GRPCServer input, the input and
+// output descriptors are nil when payloads are raw.
+type grpcMethod struct {
+	name   string
+	input  protoreflect.MessageDescriptor
+	output protoreflect.MessageDescriptor
+	bidi   bool
+}
+
+// GRPCServer is an input type that hosts a gRPC service where clients can send
+// messages through Benthos.
+type GRPCServer struct {
+	running int32
+
+	conf  Config
+	stats metrics.Type
+	log   log.Modular
+
+	listener net.Listener
+	server   *grpc.Server
+	timeout  time.Duration
+
+	unary  *grpcMethod
+	stream *grpcMethod
+
+	transactions chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+
+	mCount     metrics.StatCounter
+	mRcvd      metrics.StatCounter
+	mPartsRcvd metrics.StatCounter
+	mStreams   metrics.StatCounter
+	mTimeout   metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSucc      metrics.StatCounter
+	mAsyncErr  metrics.StatCounter
+	mAsyncSucc metrics.StatCounter
+}
+
+// NewGRPCServer creates a new GRPCServer input type.
+func NewGRPCServer(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	gConf := conf.GRPCServer
+	if len(gConf.Service) == 0 {
+		return nil, errors.New("a service name must be specified")
+	}
+	if len(gConf.UnaryMethod) == 0 && len(gConf.StreamMethod) == 0 {
+		return nil, errors.New("at least one of unary_method or stream_method must be specified")
+	}
+	if gConf.Reflection && len(gConf.DescriptorSet) == 0 {
+		return nil, errors.New("reflection requires a descriptor_set")
+	}
+
+	var timeout time.Duration
+	if len(gConf.Timeout) > 0 {
+		var err error
+		if timeout, err = time.ParseDuration(gConf.Timeout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout string: %v", err)
+		}
+	}
+
+	g := GRPCServer{
+		running:      1,
+		conf:         conf,
+		stats:        stats,
+		log:          log,
+		timeout:      timeout,
+		transactions: make(chan types.Transaction),
+		closeChan:    make(chan struct{}),
+		closedChan:   make(chan struct{}),
+
+		mCount:     stats.GetCounter("count"),
+		mRcvd:      stats.GetCounter("batch.received"),
+		mPartsRcvd: stats.GetCounter("received"),
+		mStreams:   stats.GetCounter("stream.count"),
+		mTimeout:   stats.GetCounter("send.timeout"),
+		mErr:       stats.GetCounter("send.error"),
+		mSucc:      stats.GetCounter("send.success"),
+		mAsyncErr:  stats.GetCounter("send.async_error"),
+		mAsyncSucc: stats.GetCounter("send.async_success"),
+	}
+
+	if len(gConf.UnaryMethod) > 0 {
+		g.unary = &grpcMethod{name: gConf.UnaryMethod}
+	}
+	if len(gConf.StreamMethod) > 0 {
+		g.stream = &grpcMethod{name: gConf.StreamMethod}
+	}
+
+	var svcMetadata interface{}
+	if len(gConf.DescriptorSet) > 0 {
+		var err error
+		if svcMetadata, err = g.loadDescriptors(gConf.DescriptorSet); err != nil {
+			return nil, fmt.Errorf("failed to load descriptor set: %v", err)
+		}
+	}
+
+	opts := []grpc.ServerOption{grpc.CustomCodec(grpcFrameCodec{})}
+	if len(gConf.KeyFile) > 0 || len(gConf.CertFile) > 0 {
+		creds, err := credentials.NewServerTLSFromFile(gConf.CertFile, gConf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS credentials: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	g.server = grpc.NewServer(opts...)
+	g.server.RegisterService(g.serviceDesc(svcMetadata), &g)
+	if gConf.Reflection {
+		reflection.Register(g.server)
+	}
+
+	var err error
+	if g.listener, err = net.Listen("tcp", gConf.Address); err != nil {
+		return nil, err
+	}
+
+	go g.loop()
+	return &g, nil
+}
+
+//------------------------------------------------------------------------------
+
+// loadDescriptors reads a file descriptor set and resolves the service and
+// methods of the input from it. Returns the service metadata to register,
+// which is the gzipped file descriptor containing the service.
+func (g *GRPCServer) loadDescriptors(path string) (interface{}, error) {
+	setBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err = proto.Unmarshal(setBytes, &set); err != nil {
+		return nil, err
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(g.conf.GRPCServer.Service))
+	if err != nil {
+		return nil, fmt.Errorf("service '%v' not found: %v", g.conf.GRPCServer.Service, err)
+	}
+	svc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("descriptor '%v' is not a service", g.conf.GRPCServer.Service)
+	}
+
+	resolve := func(m *grpcMethod, streaming bool) error {
+		if m == nil {
+			return nil
+		}
+		md := svc.Methods().ByName(protoreflect.Name(m.name))
+		if md == nil {
+			return fmt.Errorf("method '%v' not found in service '%v'", m.name, svc.FullName())
+		}
+		if streaming && !md.IsStreamingClient() {
+			return fmt.Errorf("method '%v' is not a client streaming method", m.name)
+		}
+		if !streaming && (md.IsStreamingClient() || md.IsStreamingServer()) {
+			return fmt.Errorf("method '%v' is not a unary method", m.name)
+		}
+		m.input = md.Input()
+		m.output = md.Output()
+		m.bidi = md.IsStreamingServer()
+		return nil
+	}
+	if err = resolve(g.unary, false); err != nil {
+		return nil, err
+	}
+	if err = resolve(g.stream, true); err != nil {
+		return nil, err
+	}
+
+	if g.conf.GRPCServer.Reflection {
+		// Reflection clients resolve the dependencies of the service file by
+		// name from the global registry.
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			if _, ferr := protoregistry.GlobalFiles.FindFileByPath(fd.Path()); ferr != nil {
+				if rerr := protoregistry.GlobalFiles.RegisterFile(fd); rerr != nil {
+					g.log.Warnf("Failed to register file '%v' for reflection: %v\n", fd.Path(), rerr)
+				}
+			}
+			return true
+		})
+	}
+
+	fdBytes, err := proto.Marshal(protodesc.ToFileDescriptorProto(svc.ParentFile()))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(fdBytes); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *GRPCServer) serviceDesc(metadata interface{}) *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: g.conf.GRPCServer.Service,
+		HandlerType: (*interface{})(nil),
+		Metadata:    metadata,
+	}
+	if g.unary != nil {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: g.unary.name,
+			Handler:    g.unaryHandler,
+		})
+	}
+	if g.stream != nil {
+		desc.Streams = append(desc.Streams, grpc.StreamDesc{
+			StreamName:    g.stream.name,
+			Handler:       g.streamHandler,
+			ClientStreams: true,
+			ServerStreams: g.stream.bidi,
+		})
+	}
+	return desc
+}
+
+//------------------------------------------------------------------------------
+
+func (g *GRPCServer) unaryHandler(
+	srv interface{},
+	ctx context.Context,
+	dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor,
+) (interface{}, error) {
+	var req grpcFrame
+	if err := dec(&req); err != nil {
+		return nil, err
+	}
+	fullMethod := "/" + g.conf.GRPCServer.Service + "/" + g.unary.name
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		resBytes, err := g.process(ctx, fullMethod, g.unary, req.(*grpcFrame).payload)
+		if err != nil {
+			return nil, err
+		}
+		return &grpcFrame{payload: resBytes}, nil
+	}
+	if interceptor == nil {
+		return handler(ctx, &req)
+	}
+	return interceptor(ctx, &req, &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fullMethod,
+	}, handler)
+}
+
+func (g *GRPCServer) streamHandler(srv interface{}, stream grpc.ServerStream) error {
+	g.mStreams.Incr(1)
+	fullMethod, _ := grpc.MethodFromServerStream(stream)
+	for {
+		var req grpcFrame
+		if err := stream.RecvMsg(&req); err != nil {
+			if err == io.EOF {
+				if g.stream.bidi {
+					return nil
+				}
+				return stream.SendMsg(&grpcFrame{})
+			}
+			return err
+		}
+		resBytes, err := g.process(stream.Context(), fullMethod, g.stream, req.payload)
+		if err != nil {
+			return err
+		}
+		if g.stream.bidi {
+			if err = stream.SendMsg(&grpcFrame{payload: resBytes}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// process sends a single request through the pipeline and returns the encoded
+// response once it has been acknowledged.
+func (g *GRPCServer) process(ctx context.Context, fullMethod string, method *grpcMethod, payload []byte) ([]byte, error) {
+	if atomic.LoadInt32(&g.running) != 1 {
+		return nil, status.Error(codes.Unavailable, "server closing")
+	}
+
+	if method.input != nil {
+		req := dynamicpb.NewMessage(method.input)
+		if err := proto.Unmarshal(payload, req); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to decode request: %v", err)
+		}
+		var err error
+		if payload, err = protojson.Marshal(req); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to convert request to JSON: %v", err)
+		}
+	}
+
+	msg := message.New([][]byte{payload})
+	meta := msg.Get(0).Metadata()
+	meta.Set("grpc_server_method", fullMethod)
+	if md, ok := grpcmeta.FromIncomingContext(ctx); ok {
+		for k, v := range md {
+			if len(v) > 0 && !strings.HasPrefix(k, ":") {
+				meta.Set(k, v[0])
+			}
+		}
+	}
+
+	tracing.InitSpans("input_grpc_server", msg)
+	defer tracing.FinishSpans(msg)
+
+	store := roundtrip.NewResultStore()
+	roundtrip.AddResultStore(msg, store)
+
+	g.mCount.Incr(1)
+	g.mPartsRcvd.Incr(1)
+	g.mRcvd.Incr(1)
+
+	resChan := make(chan types.Response)
+	select {
+	case g.transactions <- types.NewTransaction(msg, resChan):
+	case <-time.After(g.timeout):
+		g.mTimeout.Incr(1)
+		return nil, status.Error(codes.DeadlineExceeded, "request timed out")
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case <-g.closeChan:
+		return nil, status.Error(codes.Unavailable, "server closing")
+	}
+
+	select {
+	case res, open := <-resChan:
+		if !open {
+			return nil, status.Error(codes.Unavailable, "server closing")
+		} else if res.Error() != nil {
+			g.mErr.Incr(1)
+			return nil, status.Error(codes.Internal, res.Error().Error())
+		}
+		g.mSucc.Incr(1)
+	case <-time.After(g.timeout):
+		g.mTimeout.Incr(1)
+		go func() {
+			// Even if the request times out, we still need to drain a response.
+			resAsync := <-resChan
+			if resAsync.Error() != nil {
+				g.mAsyncErr.Incr(1)
+				g.mErr.Incr(1)
+			} else {
+				g.mAsyncSucc.Incr(1)
+				g.mSucc.Incr(1)
+			}
+		}()
+		return nil, status.Error(codes.DeadlineExceeded, "request timed out")
+	}
+
+	var resBytes []byte
+	for _, responseMsg := range store.Get() {
+		if responseMsg.Len() > 0 {
+			resBytes = responseMsg.Get(0).Get()
+			break
+		}
+	}
+	if method.output != nil && len(resBytes) > 0 {
+		res := dynamicpb.NewMessage(method.output)
+		if err := protojson.Unmarshal(resBytes, res); err != nil {
+			g.log.Errorf("Failed to parse sync response: %v\n", err)
+			return nil, status.Errorf(codes.Internal, "failed to parse response: %v", err)
+		}
+		var err error
+		if resBytes, err = proto.Marshal(res); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to encode response: %v", err)
+		}
+	}
+	return resBytes, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (g *GRPCServer) loop() {
+	mRunning := g.stats.GetGauge("running")
+
+	defer func() {
+		atomic.StoreInt32(&g.running, 0)
+
+		g.server.Stop()
+
+		mRunning.Decr(1)
+
+		close(g.transactions)
+		close(g.closedChan)
+	}()
+	mRunning.Incr(1)
+
+	go func() {
+		g.log.Infof("Receiving gRPC messages at: %v\n", g.listener.Addr())
+		if err := g.server.Serve(g.listener); err != nil && err != grpc.ErrServerStopped {
+			g.log.Errorf("Server error: %v\n", err)
+		}
+	}()
+
+	<-g.closeChan
+}
+
+// TransactionChan returns a transactions channel for consuming messages from
+// this input.
+func (g *GRPCServer) TransactionChan() <-chan types.Transaction {
+	return g.transactions
+}
+
+// Connected returns a boolean indicating whether this input is currently
+// connected to its target.
+func (g *GRPCServer) Connected() bool {
+	return true
+}
+
+// CloseAsync shuts down the GRPCServer input and stops processing requests.
+func (g *GRPCServer) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&g.running, 1, 0) {
+		close(g.closeChan)
+	}
+}
+
+// WaitForClose blocks until the GRPCServer input has closed down.
+func (g *GRPCServer) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-g.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
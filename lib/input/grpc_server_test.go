@@ -0,0 +1,365 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package input
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message/roundtrip"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcmeta "google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+//------------------------------------------------------------------------------
+
+func newTestGRPCServer(t *testing.T, gConf GRPCServerConfig) (*GRPCServer, *grpc.ClientConn) {
+	t.Helper()
+
+	conf := NewConfig()
+	conf.GRPCServer = gConf
+
+	i, err := NewGRPCServer(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := i.(*GRPCServer)
+
+	conn, err := grpc.Dial(
+		g.listener.Addr().String(), grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(grpc.CallCustomCodec(grpcFrameCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, conn
+}
+
+func closeTestGRPCServer(t *testing.T, g *GRPCServer, conn *grpc.ClientConn) {
+	t.Helper()
+	conn.Close()
+	g.CloseAsync()
+	if err := g.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func ackNextGRPCTransaction(t *testing.T, g *GRPCServer, res types.Response, f func(ts types.Transaction)) {
+	t.Helper()
+
+	var ts types.Transaction
+	select {
+	case ts = <-g.TransactionChan():
+	case <-time.After(time.Second * 5):
+		t.Error("Timed out waiting for message")
+		return
+	}
+	if f != nil {
+		f(ts)
+	}
+	select {
+	case ts.ResponseChan <- res:
+	case <-time.After(time.Second * 5):
+		t.Error("Timed out waiting for response")
+	}
+}
+
+func writeTestDescriptorSet(t *testing.T, dir string) string {
+	t.Helper()
+
+	strField := func(name string, num int32) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("benthos_grpc_server_test.proto"),
+			Package: proto.String("benthostest"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("Request"), Field: []*descriptorpb.FieldDescriptorProto{strField("name", 1)}},
+				{Name: proto.String("Response"), Field: []*descriptorpb.FieldDescriptorProto{strField("reply", 1)}},
+			},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Ingest"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					{
+						Name:       proto.String("Send"),
+						InputType:  proto.String(".benthostest.Request"),
+						OutputType: proto.String(".benthostest.Response"),
+					},
+					{
+						Name:            proto.String("Stream"),
+						InputType:       proto.String(".benthostest.Request"),
+						OutputType:      proto.String(".benthostest.Response"),
+						ClientStreaming: proto.Bool(true),
+						ServerStreaming: proto.Bool(true),
+					},
+				},
+			}},
+		}},
+	}
+
+	setBytes, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "descriptors.pb")
+	if err = ioutil.WriteFile(path, setBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+//------------------------------------------------------------------------------
+
+func TestGRPCServerRawUnary(t *testing.T) {
+	gConf := NewGRPCServerConfig()
+	gConf.Address = "127.0.0.1:0"
+
+	g, conn := newTestGRPCServer(t, gConf)
+	defer closeTestGRPCServer(t, g, conn)
+
+	go ackNextGRPCTransaction(t, g, response.NewAck(), func(ts types.Transaction) {
+		if exp, act := "hello world", string(ts.Payload.Get(0).Get()); exp != act {
+			t.Errorf("Wrong payload: %v != %v", act, exp)
+		}
+		meta := ts.Payload.Get(0).Metadata()
+		if exp, act := "/benthos.Ingest/Send", meta.Get("grpc_server_method"); exp != act {
+			t.Errorf("Wrong method metadata: %v != %v", act, exp)
+		}
+		if exp, act := "bar", meta.Get("foo"); exp != act {
+			t.Errorf("Wrong request metadata: %v != %v", act, exp)
+		}
+		ts.Payload.Get(0).Set([]byte("hello response"))
+		roundtrip.SetAsResponse(ts.Payload)
+	})
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+	ctx = grpcmeta.AppendToOutgoingContext(ctx, "foo", "bar")
+
+	var res grpcFrame
+	if err := conn.Invoke(ctx, "/benthos.Ingest/Send", &grpcFrame{payload: []byte("hello world")}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "hello response", string(res.payload); exp != act {
+		t.Errorf("Wrong response: %v != %v", act, exp)
+	}
+
+	go ackNextGRPCTransaction(t, g, response.NewError(errors.New("nope")), nil)
+
+	err := conn.Invoke(ctx, "/benthos.Ingest/Send", &grpcFrame{payload: []byte("hello again")}, &res)
+	if exp, act := codes.Internal, status.Code(err); exp != act {
+		t.Errorf("Wrong error code: %v != %v", act, exp)
+	}
+}
+
+func TestGRPCServerRawStream(t *testing.T) {
+	gConf := NewGRPCServerConfig()
+	gConf.Address = "127.0.0.1:0"
+
+	g, conn := newTestGRPCServer(t, gConf)
+	defer closeTestGRPCServer(t, g, conn)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{
+		ClientStreams: true,
+	}, "/benthos.Ingest/Stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"foo", "bar", "baz"}
+	go func() {
+		for _, e := range exp {
+			if serr := stream.SendMsg(&grpcFrame{payload: []byte(e)}); serr != nil {
+				t.Error(serr)
+			}
+		}
+		if serr := stream.CloseSend(); serr != nil {
+			t.Error(serr)
+		}
+	}()
+
+	for _, e := range exp {
+		ackNextGRPCTransaction(t, g, response.NewAck(), func(ts types.Transaction) {
+			if act := string(ts.Payload.Get(0).Get()); e != act {
+				t.Errorf("Wrong payload: %v != %v", act, e)
+			}
+		})
+	}
+
+	var res grpcFrame
+	if err = stream.RecvMsg(&res); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGRPCServerDescriptors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_grpc_server_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gConf := NewGRPCServerConfig()
+	gConf.Address = "127.0.0.1:0"
+	gConf.Service = "benthostest.Ingest"
+	gConf.DescriptorSet = writeTestDescriptorSet(t, dir)
+	gConf.Reflection = true
+
+	g, conn := newTestGRPCServer(t, gConf)
+	defer closeTestGRPCServer(t, g, conn)
+
+	if !g.stream.bidi {
+		t.Error("Expected stream method to be bidirectional")
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	reqDesc, resDesc := g.unary.input, g.unary.output
+
+	req := dynamicpb.NewMessage(reqDesc)
+	req.Set(reqDesc.Fields().ByName("name"), protoreflect.ValueOfString("foo"))
+	reqBytes, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go ackNextGRPCTransaction(t, g, response.NewAck(), func(ts types.Transaction) {
+		if exp, act := `{"name":"foo"}`, string(ts.Payload.Get(0).Get()); exp != act {
+			t.Errorf("Wrong payload: %v != %v", act, exp)
+		}
+		ts.Payload.Get(0).Set([]byte(`{"reply":"bar"}`))
+		roundtrip.SetAsResponse(ts.Payload)
+	})
+
+	var resFrame grpcFrame
+	if err = conn.Invoke(ctx, "/benthostest.Ingest/Send", &grpcFrame{payload: reqBytes}, &resFrame); err != nil {
+		t.Fatal(err)
+	}
+	res := dynamicpb.NewMessage(resDesc)
+	if err = proto.Unmarshal(resFrame.payload, res); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "bar", res.Get(resDesc.Fields().ByName("reply")).String(); exp != act {
+		t.Errorf("Wrong response: %v != %v", act, exp)
+	}
+
+	err = conn.Invoke(ctx, "/benthostest.Ingest/Send", &grpcFrame{payload: []byte("not a protobuf")}, &resFrame)
+	if exp, act := codes.InvalidArgument, status.Code(err); exp != act {
+		t.Errorf("Wrong error code: %v != %v", act, exp)
+	}
+
+	refConn, err := grpc.Dial(g.listener.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer refConn.Close()
+
+	refStream, err := rpb.NewServerReflectionClient(refConn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = refStream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{
+			FileContainingSymbol: "benthostest.Ingest",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	refRes, err := refStream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdRes := refRes.GetFileDescriptorResponse()
+	if fdRes == nil || len(fdRes.FileDescriptorProto) != 1 {
+		t.Fatalf("Unexpected reflection response: %v", refRes)
+	}
+	var fd descriptorpb.FileDescriptorProto
+	if err = proto.Unmarshal(fdRes.FileDescriptorProto[0], &fd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = protodesc.NewFile(&fd, nil); err != nil {
+		t.Error(err)
+	}
+	if exp, act := "benthos_grpc_server_test.proto", fd.GetName(); exp != act {
+		t.Errorf("Wrong reflected file: %v != %v", act, exp)
+	}
+}
+
+func TestGRPCServerBadConfig(t *testing.T) {
+	tests := map[string]func(c *GRPCServerConfig){
+		"no service": func(c *GRPCServerConfig) {
+			c.Service = ""
+		},
+		"no methods": func(c *GRPCServerConfig) {
+			c.UnaryMethod = ""
+			c.StreamMethod = ""
+		},
+		"reflection without descriptors": func(c *GRPCServerConfig) {
+			c.Reflection = true
+		},
+		"bad timeout": func(c *GRPCServerConfig) {
+			c.Timeout = "nope"
+		},
+		"missing descriptors": func(c *GRPCServerConfig) {
+			c.DescriptorSet = "/does/not/exist.pb"
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewConfig()
+		conf.GRPCServer.Address = "127.0.0.1:0"
+		test(&conf.GRPCServer)
+		if _, err := NewGRPCServer(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -27,6 +27,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kerberos"
 )
 
 //------------------------------------------------------------------------------
@@ -52,7 +53,12 @@ This input adds the following metadata fields to each message:
 ` + "```" + `
 
 You can access these metadata fields using
-[function interpolation](../config_interpolation.md#metadata).`,
+[function interpolation](../config_interpolation.md#metadata).
+
+` + kerberos.Documentation + `
+
+When Kerberos is enabled the field ` + "`user`" + ` can be left empty in order to
+act as the authenticated principal.`,
 	}
 }
 
@@ -30,6 +30,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -68,6 +69,32 @@ When the rate limit is breached HTTP requests will have a 429 response returned
 with a Retry-After header. Websocket payloads will be dropped and an optional
 response payload will be sent as per ` + "`ws_rate_limit_message`" + `.
 
+### Signatures
+
+When ` + "`signature.enabled`" + ` is true requests posted to ` + "`path`" + `
+are only accepted when they carry a valid HMAC signature of their body, created
+with the shared ` + "`signature.secret`" + `, and all other requests are
+rejected with a 401 response. The ` + "`signature.scheme`" + ` selects how the
+signature is presented:
+
+- ` + "`github`" + `: The ` + "`X-Hub-Signature-256`" + ` header, falling
+  back to the legacy ` + "`X-Hub-Signature`" + ` header.
+- ` + "`stripe`" + `: The ` + "`Stripe-Signature`" + ` header.
+- ` + "`slack`" + `: The ` + "`X-Slack-Signature`" + ` and
+  ` + "`X-Slack-Request-Timestamp`" + ` headers.
+- ` + "`hmac`" + `: The header ` + "`signature.header`" + `, containing the
+  ` + "`signature.prefix`" + ` followed by the ` + "`signature.encoding`" + `
+  (` + "`hex` or `base64`" + `) of the HMAC of the body using
+  ` + "`signature.algorithm`" + ` (` + "`sha1`, `sha256` or `sha512`" + `).
+
+The ` + "`stripe`" + ` and ` + "`slack`" + ` schemes also sign a timestamp,
+and requests with a timestamp further than ` + "`signature.tolerance`" + `
+from the current time are rejected in order to prevent replays.
+
+Signatures are not checked for websocket connections, and therefore
+` + "`ws_path`" + ` should be set to an empty string when accepting
+webhooks.
+
 ### Responses
 
 It's possible to return a response for each message received using
@@ -85,7 +112,9 @@ a single message.
 If the request contains a multipart ` + "`content-type`" + ` header as per
 [rfc1341](https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html) then the
 multiple parts are consumed as a batch of messages, where each body part is a
-message of the batch.
+message of the batch. This includes ` + "`multipart/form-data`" + ` requests,
+allowing HTML forms and file uploads to be posted directly, where the form field
+name, file name and content type of each part are added to its metadata.
 
 #### ` + "`ws_path` (defaults to `/post/ws`)" + `
 
@@ -99,6 +128,12 @@ established.
 It's also possible to specify a ` + "`ws_rate_limit_message`" + `, which is a
 static payload to be sent to clients that have triggered the servers rate limit.
 
+By default websocket connections from browsers are only accepted when the
+` + "`Origin`" + ` header of the request matches its host. In order to accept
+connections from pages served elsewhere list their origins (e.g.
+` + "`https://example.com`" + `) in ` + "`ws_allowed_origins`" + `, or use
+` + "`*`" + ` to accept any origin.
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -110,6 +145,15 @@ This input adds the following metadata fields to each message:
 - All cookies
 ` + "```" + `
 
+Parts of a multipart request also have the following metadata fields added:
+
+` + "``` text" + `
+- http_server_part_name
+- http_server_part_filename
+- http_server_part_content_type
+- All part headers (only first values are taken)
+` + "```" + `
+
 You can access these metadata fields using
 [function interpolation](../config_interpolation.md#metadata).`,
 	}
@@ -119,15 +163,18 @@ You can access these metadata fields using
 
 // HTTPServerConfig contains configuration for the HTTPServer input type.
 type HTTPServerConfig struct {
-	Address            string `json:"address" yaml:"address"`
-	Path               string `json:"path" yaml:"path"`
-	WSPath             string `json:"ws_path" yaml:"ws_path"`
-	WSWelcomeMessage   string `json:"ws_welcome_message" yaml:"ws_welcome_message"`
-	WSRateLimitMessage string `json:"ws_rate_limit_message" yaml:"ws_rate_limit_message"`
-	Timeout            string `json:"timeout" yaml:"timeout"`
-	RateLimit          string `json:"rate_limit" yaml:"rate_limit"`
-	CertFile           string `json:"cert_file" yaml:"cert_file"`
-	KeyFile            string `json:"key_file" yaml:"key_file"`
+	Address            string   `json:"address" yaml:"address"`
+	Path               string   `json:"path" yaml:"path"`
+	WSPath             string   `json:"ws_path" yaml:"ws_path"`
+	WSWelcomeMessage   string   `json:"ws_welcome_message" yaml:"ws_welcome_message"`
+	WSRateLimitMessage string   `json:"ws_rate_limit_message" yaml:"ws_rate_limit_message"`
+	WSAllowedOrigins   []string `json:"ws_allowed_origins" yaml:"ws_allowed_origins"`
+	Timeout            string   `json:"timeout" yaml:"timeout"`
+	RateLimit          string   `json:"rate_limit" yaml:"rate_limit"`
+	CertFile           string   `json:"cert_file" yaml:"cert_file"`
+	KeyFile            string   `json:"key_file" yaml:"key_file"`
+
+	Signature HTTPServerSignatureConfig `json:"signature" yaml:"signature"`
 }
 
 // NewHTTPServerConfig creates a new HTTPServerConfig with default values.
@@ -138,10 +185,13 @@ func NewHTTPServerConfig() HTTPServerConfig {
 		WSPath:             "/post/ws",
 		WSWelcomeMessage:   "",
 		WSRateLimitMessage: "",
+		WSAllowedOrigins:   []string{},
 		Timeout:            "5s",
 		RateLimit:          "",
 		CertFile:           "",
 		KeyFile:            "",
+
+		Signature: NewHTTPServerSignatureConfig(),
 	}
 }
 
@@ -160,6 +210,7 @@ type HTTPServer struct {
 	log   log.Modular
 
 	ratelimit types.RateLimit
+	verifier  *signatureVerifier
 
 	mux     *http.ServeMux
 	server  *http.Server
@@ -172,6 +223,7 @@ type HTTPServer struct {
 
 	mCount         metrics.StatCounter
 	mRateLimited   metrics.StatCounter
+	mSigRejected   metrics.StatCounter
 	mWSRateLimited metrics.StatCounter
 	mRcvd          metrics.StatCounter
 	mPartsRcvd     metrics.StatCounter
@@ -214,6 +266,14 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		}
 	}
 
+	var verifier *signatureVerifier
+	if conf.HTTPServer.Signature.Enabled {
+		var err error
+		if verifier, err = newSignatureVerifier(conf.HTTPServer.Signature); err != nil {
+			return nil, fmt.Errorf("failed to create signature verifier: %v", err)
+		}
+	}
+
 	h := HTTPServer{
 		running:      1,
 		conf:         conf,
@@ -221,6 +281,7 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 		log:          log,
 		mux:          mux,
 		ratelimit:    ratelimit,
+		verifier:     verifier,
 		server:       server,
 		timeout:      timeout,
 		transactions: make(chan types.Transaction),
@@ -229,6 +290,7 @@ func NewHTTPServer(conf Config, mgr types.Manager, log log.Modular, stats metric
 
 		mCount:         stats.GetCounter("count"),
 		mRateLimited:   stats.GetCounter("rate_limited"),
+		mSigRejected:   stats.GetCounter("signature.rejected"),
 		mWSRateLimited: stats.GetCounter("ws.rate_limited"),
 		mRcvd:          stats.GetCounter("batch.received"),
 		mPartsRcvd:     stats.GetCounter("received"),
@@ -278,6 +340,7 @@ func extractMessageFromRequest(r *http.Request) (types.Message, error) {
 		return nil, err
 	}
 
+	var partHeaders []textproto.MIMEHeader
 	if strings.HasPrefix(mediaType, "multipart/") {
 		mr := multipart.NewReader(r.Body, params["boundary"])
 		for {
@@ -294,6 +357,7 @@ func extractMessageFromRequest(r *http.Request) (types.Message, error) {
 				return nil, err
 			}
 			msg.Append(message.NewPart(msgBytes))
+			partHeaders = append(partHeaders, p.Header)
 		}
 	} else {
 		var msgBytes []byte
@@ -320,15 +384,50 @@ func extractMessageFromRequest(r *http.Request) (types.Message, error) {
 	}
 	message.SetAllMetadata(msg, meta)
 
-	// Try to either extract parent span from headers, or create a new one.
+	for i, h := range partHeaders {
+		partMeta := meta.Copy()
+		setMultipartMetadata(partMeta, h)
+		msg.Get(i).SetMetadata(partMeta)
+	}
+
+	initSpansFromHeaders("input_http_server_post", r, msg)
+
+	return msg, nil
+}
+
+// setMultipartMetadata adds the headers of a multipart body part to the
+// metadata of its message part, along with the form field name, file name and
+// content type of the part when present.
+func setMultipartMetadata(meta types.Metadata, h textproto.MIMEHeader) {
+	for k, v := range h {
+		if len(v) > 0 {
+			meta.Set(k, v[0])
+		}
+	}
+	if v := h.Get("Content-Type"); len(v) > 0 {
+		meta.Set("http_server_part_content_type", v)
+	}
+	_, params, err := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if err != nil {
+		return
+	}
+	if v := params["name"]; len(v) > 0 {
+		meta.Set("http_server_part_name", v)
+	}
+	if v := params["filename"]; len(v) > 0 {
+		meta.Set("http_server_part_filename", v)
+	}
+}
+
+// initSpansFromHeaders creates the spans of a message, which are children of a
+// span extracted from the headers of a request when present.
+func initSpansFromHeaders(operationName string, r *http.Request, msg types.Message) {
 	carrier := opentracing.HTTPHeadersCarrier(r.Header)
 	if clientSpanContext, serr := opentracing.GlobalTracer().Extract(opentracing.HTTPHeaders, carrier); serr == nil {
-		tracing.InitSpansFromParent("input_http_server_post", clientSpanContext, msg)
+		tracing.InitSpansFromParent(operationName, clientSpanContext, msg)
 	} else {
-		tracing.InitSpans("input_http_server_post", msg)
+		tracing.InitSpans(operationName, msg)
 	}
-
-	return msg, nil
 }
 
 func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
@@ -357,6 +456,15 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if h.verifier != nil {
+		if err := h.verifier.verifyRequest(r); err != nil {
+			h.mSigRejected.Incr(1)
+			h.log.Warnf("Rejected request with invalid signature: %v\n", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	var err error
 	defer func() {
 		if err != nil {
@@ -451,6 +559,24 @@ func (h *HTTPServer) postHandler(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// wsCheckOrigin returns true if the origin of a websocket upgrade request
+// matches its host, or is listed in ws_allowed_origins.
+func (h *HTTPServer) wsCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range h.conf.HTTPServer.WSAllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 	var err error
 	defer func() {
@@ -461,7 +587,9 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	upgrader := websocket.Upgrader{}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: h.wsCheckOrigin,
+	}
 
 	var ws *websocket.Conn
 	if ws, err = upgrader.Upgrade(w, r, nil); err != nil {
@@ -520,7 +648,7 @@ func (h *HTTPServer) wsHandler(w http.ResponseWriter, r *http.Request) {
 		for _, c := range r.Cookies() {
 			meta.Set(c.Name, c.Value)
 		}
-		tracing.InitSpans("input_http_server_websocket", msg)
+		initSpansFromHeaders("input_http_server_websocket", r, msg)
 
 		store := roundtrip.NewResultStore()
 		roundtrip.AddResultStore(msg, store)
@@ -0,0 +1,254 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// HTTPServerSignatureConfig contains configuration fields for verifying the
+// HMAC signatures of requests posted to the HTTPServer input type.
+type HTTPServerSignatureConfig struct {
+	Enabled   bool   `json:"enabled" yaml:"enabled"`
+	Scheme    string `json:"scheme" yaml:"scheme"`
+	Secret    string `json:"secret" yaml:"secret"`
+	Header    string `json:"header" yaml:"header"`
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	Encoding  string `json:"encoding" yaml:"encoding"`
+	Prefix    string `json:"prefix" yaml:"prefix"`
+	Tolerance string `json:"tolerance" yaml:"tolerance"`
+}
+
+// NewHTTPServerSignatureConfig creates a new HTTPServerSignatureConfig with
+// default values.
+func NewHTTPServerSignatureConfig() HTTPServerSignatureConfig {
+	return HTTPServerSignatureConfig{
+		Enabled:   false,
+		Scheme:    "github",
+		Secret:    "",
+		Header:    "",
+		Algorithm: "sha256",
+		Encoding:  "hex",
+		Prefix:    "",
+		Tolerance: "5m",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+var errSignatureMissing = errors.New("signature header is missing")
+var errSignatureMismatch = errors.New("signature does not match")
+
+// signatureVerifier checks that the body of a request was signed with a shared
+// secret.
+type signatureVerifier struct {
+	conf      HTTPServerSignatureConfig
+	hashFn    func() hash.Hash
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+func newSignatureVerifier(conf HTTPServerSignatureConfig) (*signatureVerifier, error) {
+	if len(conf.Secret) == 0 {
+		return nil, errors.New("a secret must be specified")
+	}
+	v := &signatureVerifier{
+		conf:   conf,
+		hashFn: sha256.New,
+		now:    time.Now,
+	}
+	if len(conf.Tolerance) > 0 {
+		var err error
+		if v.tolerance, err = time.ParseDuration(conf.Tolerance); err != nil {
+			return nil, fmt.Errorf("failed to parse tolerance: %v", err)
+		}
+	}
+
+	switch conf.Scheme {
+	case "github", "stripe", "slack":
+	case "hmac":
+		if len(conf.Header) == 0 {
+			return nil, errors.New("a header must be specified")
+		}
+		switch conf.Algorithm {
+		case "sha1":
+			v.hashFn = sha1.New
+		case "sha256":
+		case "sha512":
+			v.hashFn = sha512.New
+		default:
+			return nil, fmt.Errorf("unrecognised algorithm: %v", conf.Algorithm)
+		}
+		switch conf.Encoding {
+		case "hex", "base64":
+		default:
+			return nil, fmt.Errorf("unrecognised encoding: %v", conf.Encoding)
+		}
+	default:
+		return nil, fmt.Errorf("unrecognised scheme: %v", conf.Scheme)
+	}
+	return v, nil
+}
+
+func (v *signatureVerifier) sign(hashFn func() hash.Hash, data ...[]byte) []byte {
+	mac := hmac.New(hashFn, []byte(v.conf.Secret))
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// matchesHex compares a hex encoded signature against the expected signature
+// in constant time.
+func matchesHex(sig string, expected []byte) bool {
+	decoded, err := hex.DecodeString(strings.TrimSpace(sig))
+	return err == nil && hmac.Equal(decoded, expected)
+}
+
+// checkTimestamp rejects requests with a unix timestamp outside of the
+// tolerance, which prevents replays of previously signed requests.
+func (v *signatureVerifier) checkTimestamp(ts string) error {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse timestamp: %v", err)
+	}
+	if v.tolerance <= 0 {
+		return nil
+	}
+	diff := v.now().Sub(time.Unix(secs, 0))
+	if diff > v.tolerance || diff < -v.tolerance {
+		return errors.New("timestamp is outside of the tolerance")
+	}
+	return nil
+}
+
+// verify checks the signature of a request body.
+func (v *signatureVerifier) verify(h http.Header, body []byte) error {
+	switch v.conf.Scheme {
+	case "github":
+		if sig := h.Get("X-Hub-Signature-256"); len(sig) > 0 {
+			if !strings.HasPrefix(sig, "sha256=") || !matchesHex(sig[7:], v.sign(sha256.New, body)) {
+				return errSignatureMismatch
+			}
+			return nil
+		}
+		if sig := h.Get("X-Hub-Signature"); len(sig) > 0 {
+			if !strings.HasPrefix(sig, "sha1=") || !matchesHex(sig[5:], v.sign(sha1.New, body)) {
+				return errSignatureMismatch
+			}
+			return nil
+		}
+		return errSignatureMissing
+
+	case "stripe":
+		sigHeader := h.Get("Stripe-Signature")
+		if len(sigHeader) == 0 {
+			return errSignatureMissing
+		}
+		var ts string
+		var sigs []string
+		for _, kv := range strings.Split(sigHeader, ",") {
+			kv = strings.TrimSpace(kv)
+			if strings.HasPrefix(kv, "t=") {
+				ts = kv[2:]
+			} else if strings.HasPrefix(kv, "v1=") {
+				sigs = append(sigs, kv[3:])
+			}
+		}
+		if len(ts) == 0 || len(sigs) == 0 {
+			return errors.New("signature header is malformed")
+		}
+		if err := v.checkTimestamp(ts); err != nil {
+			return err
+		}
+		expected := v.sign(sha256.New, []byte(ts), []byte("."), body)
+		for _, sig := range sigs {
+			if matchesHex(sig, expected) {
+				return nil
+			}
+		}
+		return errSignatureMismatch
+
+	case "slack":
+		sig, ts := h.Get("X-Slack-Signature"), h.Get("X-Slack-Request-Timestamp")
+		if len(sig) == 0 || len(ts) == 0 {
+			return errSignatureMissing
+		}
+		if err := v.checkTimestamp(ts); err != nil {
+			return err
+		}
+		expected := v.sign(sha256.New, []byte("v0:"+ts+":"), body)
+		if !strings.HasPrefix(sig, "v0=") || !matchesHex(sig[3:], expected) {
+			return errSignatureMismatch
+		}
+		return nil
+	}
+
+	sig := h.Get(v.conf.Header)
+	if len(sig) == 0 {
+		return errSignatureMissing
+	}
+	if !strings.HasPrefix(sig, v.conf.Prefix) {
+		return errSignatureMismatch
+	}
+	sig = strings.TrimPrefix(sig, v.conf.Prefix)
+	expected := v.sign(v.hashFn, body)
+	if v.conf.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sig))
+		if err != nil || !hmac.Equal(decoded, expected) {
+			return errSignatureMismatch
+		}
+		return nil
+	}
+	if !matchesHex(sig, expected) {
+		return errSignatureMismatch
+	}
+	return nil
+}
+
+// verifyRequest reads the body of a request and checks its signature, the
+// body is replaced so that it can be read again.
+func (v *signatureVerifier) verifyRequest(r *http.Request) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return v.verify(r.Header, body)
+}
+
+//------------------------------------------------------------------------------
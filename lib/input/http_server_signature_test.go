@@ -0,0 +1,307 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func testHMAC(hashFn func() hash.Hash, secret string, data ...string) []byte {
+	mac := hmac.New(hashFn, []byte(secret))
+	for _, d := range data {
+		mac.Write([]byte(d))
+	}
+	return mac.Sum(nil)
+}
+
+func TestHTTPSignatureVerify(t *testing.T) {
+	body := `{"hello":"world"}`
+	now := time.Unix(1600000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	staleTS := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+
+	hexSig := func(hashFn func() hash.Hash, data ...string) string {
+		return hex.EncodeToString(testHMAC(hashFn, "foo", data...))
+	}
+
+	tests := []struct {
+		name    string
+		conf    func(c *HTTPServerSignatureConfig)
+		headers map[string]string
+		err     error
+		errAny  bool
+	}{
+		{
+			name: "github sha256",
+			headers: map[string]string{
+				"X-Hub-Signature-256": "sha256=" + hexSig(sha256.New, body),
+			},
+		},
+		{
+			name: "github sha1",
+			headers: map[string]string{
+				"X-Hub-Signature": "sha1=" + hexSig(sha1.New, body),
+			},
+		},
+		{
+			name: "github wrong secret",
+			headers: map[string]string{
+				"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(testHMAC(sha256.New, "bar", body)),
+			},
+			err: errSignatureMismatch,
+		},
+		{
+			name: "github missing",
+			err:  errSignatureMissing,
+		},
+		{
+			name: "stripe",
+			conf: func(c *HTTPServerSignatureConfig) { c.Scheme = "stripe" },
+			headers: map[string]string{
+				"Stripe-Signature": "t=" + ts + ",v1=nope,v1=" + hexSig(sha256.New, ts, ".", body),
+			},
+		},
+		{
+			name: "stripe mismatch",
+			conf: func(c *HTTPServerSignatureConfig) { c.Scheme = "stripe" },
+			headers: map[string]string{
+				"Stripe-Signature": "t=" + ts + ",v1=" + hexSig(sha256.New, body),
+			},
+			err: errSignatureMismatch,
+		},
+		{
+			name: "stripe stale",
+			conf: func(c *HTTPServerSignatureConfig) { c.Scheme = "stripe" },
+			headers: map[string]string{
+				"Stripe-Signature": "t=" + staleTS + ",v1=" + hexSig(sha256.New, staleTS, ".", body),
+			},
+			errAny: true,
+		},
+		{
+			name: "slack",
+			conf: func(c *HTTPServerSignatureConfig) { c.Scheme = "slack" },
+			headers: map[string]string{
+				"X-Slack-Request-Timestamp": ts,
+				"X-Slack-Signature":         "v0=" + hexSig(sha256.New, "v0:", ts, ":", body),
+			},
+		},
+		{
+			name: "slack stale",
+			conf: func(c *HTTPServerSignatureConfig) { c.Scheme = "slack" },
+			headers: map[string]string{
+				"X-Slack-Request-Timestamp": staleTS,
+				"X-Slack-Signature":         "v0=" + hexSig(sha256.New, "v0:", staleTS, ":", body),
+			},
+			errAny: true,
+		},
+		{
+			name: "slack missing timestamp",
+			conf: func(c *HTTPServerSignatureConfig) { c.Scheme = "slack" },
+			headers: map[string]string{
+				"X-Slack-Signature": "v0=" + hexSig(sha256.New, "v0:", ts, ":", body),
+			},
+			err: errSignatureMissing,
+		},
+		{
+			name: "hmac hex",
+			conf: func(c *HTTPServerSignatureConfig) {
+				c.Scheme = "hmac"
+				c.Header = "X-Signature"
+				c.Prefix = "sha1="
+				c.Algorithm = "sha1"
+			},
+			headers: map[string]string{
+				"X-Signature": "sha1=" + hexSig(sha1.New, body),
+			},
+		},
+		{
+			name: "hmac base64",
+			conf: func(c *HTTPServerSignatureConfig) {
+				c.Scheme = "hmac"
+				c.Header = "X-Signature"
+				c.Encoding = "base64"
+			},
+			headers: map[string]string{
+				"X-Signature": base64.StdEncoding.EncodeToString(testHMAC(sha256.New, "foo", body)),
+			},
+		},
+		{
+			name: "hmac missing prefix",
+			conf: func(c *HTTPServerSignatureConfig) {
+				c.Scheme = "hmac"
+				c.Header = "X-Signature"
+				c.Prefix = "sha256="
+			},
+			headers: map[string]string{
+				"X-Signature": hexSig(sha256.New, body),
+			},
+			err: errSignatureMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(tt *testing.T) {
+			conf := NewHTTPServerSignatureConfig()
+			conf.Enabled = true
+			conf.Secret = "foo"
+			if test.conf != nil {
+				test.conf(&conf)
+			}
+			v, err := newSignatureVerifier(conf)
+			if err != nil {
+				tt.Fatal(err)
+			}
+			v.now = func() time.Time { return now }
+
+			h := http.Header{}
+			for k, val := range test.headers {
+				h.Set(k, val)
+			}
+			err = v.verify(h, []byte(body))
+			if test.errAny {
+				if err == nil {
+					tt.Error("Expected error")
+				}
+			} else if err != test.err {
+				tt.Errorf("Wrong error: %v != %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestHTTPSignatureBadConfig(t *testing.T) {
+	tests := map[string]func(c *HTTPServerSignatureConfig){
+		"no secret":  func(c *HTTPServerSignatureConfig) { c.Secret = "" },
+		"bad scheme": func(c *HTTPServerSignatureConfig) { c.Scheme = "nope" },
+		"no header":  func(c *HTTPServerSignatureConfig) { c.Scheme = "hmac" },
+		"bad algorithm": func(c *HTTPServerSignatureConfig) {
+			c.Scheme = "hmac"
+			c.Header = "X-Signature"
+			c.Algorithm = "md5"
+		},
+		"bad tolerance": func(c *HTTPServerSignatureConfig) { c.Tolerance = "nope" },
+	}
+	for name, fn := range tests {
+		conf := NewHTTPServerSignatureConfig()
+		conf.Secret = "foo"
+		fn(&conf)
+		if _, err := newSignatureVerifier(conf); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestHTTPSignatureRejected(t *testing.T) {
+	t.Parallel()
+
+	reg := apiRegMutWrapper{mut: &http.ServeMux{}}
+	mgr, err := manager.New(manager.NewConfig(), reg, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.HTTPServer.Path = "/testpost"
+	conf.HTTPServer.Signature.Enabled = true
+	conf.HTTPServer.Signature.Secret = "foo"
+
+	h, err := NewHTTPServer(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(reg.mut)
+	defer server.Close()
+
+	req, err := http.NewRequest("POST", server.URL+"/testpost", bytes.NewBufferString("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(testHMAC(sha256.New, "bar", "hello world")))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := http.StatusUnauthorized, res.StatusCode; exp != act {
+		t.Errorf("unexpected HTTP response code: %v != %v", exp, act)
+	}
+
+	go func() {
+		req, err := http.NewRequest("POST", server.URL+"/testpost", bytes.NewBufferString("hello world"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(testHMAC(sha256.New, "foo", "hello world")))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if exp, act := http.StatusOK, res.StatusCode; exp != act {
+			t.Errorf("unexpected HTTP response code: %v != %v", exp, act)
+		}
+	}()
+
+	var ts types.Transaction
+	select {
+	case ts = <-h.TransactionChan():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for message")
+	}
+	if exp, act := "hello world", string(ts.Payload.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out sending response")
+	}
+
+	h.CloseAsync()
+	if err := h.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -166,6 +167,105 @@ func TestHTTPBasic(t *testing.T) {
 	h.CloseAsync()
 }
 
+func TestHTTPMultipartFormData(t *testing.T) {
+	t.Parallel()
+
+	reg := apiRegMutWrapper{mut: &http.ServeMux{}}
+	mgr, err := manager.New(manager.NewConfig(), reg, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.HTTPServer.Path = "/testpost"
+
+	h, err := NewHTTPServer(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(reg.mut)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err = mw.WriteField("title", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	fw, err := mw.CreateFormFile("upload", "foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fw.Write([]byte("file contents")); err != nil {
+		t.Fatal(err)
+	}
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		if res, err := http.Post(
+			server.URL+"/testpost?foo=bar",
+			mw.FormDataContentType(),
+			&buf,
+		); err != nil {
+			t.Error(err)
+		} else if res.StatusCode != 200 {
+			t.Errorf("Wrong error code returned: %v", res.StatusCode)
+		}
+	}()
+
+	var ts types.Transaction
+	select {
+	case ts = <-h.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+
+	if exp, act := 2, ts.Payload.Len(); exp != act {
+		t.Fatalf("Wrong number of parts: %v != %v", act, exp)
+	}
+	if exp, act := "hello world", string(ts.Payload.Get(0).Get()); exp != act {
+		t.Errorf("Wrong result, %v != %v", act, exp)
+	}
+	if exp, act := "file contents", string(ts.Payload.Get(1).Get()); exp != act {
+		t.Errorf("Wrong result, %v != %v", act, exp)
+	}
+
+	meta := ts.Payload.Get(0).Metadata()
+	if exp, act := "title", meta.Get("http_server_part_name"); exp != act {
+		t.Errorf("Wrong part name: %v != %v", act, exp)
+	}
+	if exp, act := "", meta.Get("http_server_part_filename"); exp != act {
+		t.Errorf("Wrong part filename: %v != %v", act, exp)
+	}
+	if exp, act := "bar", meta.Get("foo"); exp != act {
+		t.Errorf("Wrong query metadata: %v != %v", act, exp)
+	}
+
+	meta = ts.Payload.Get(1).Metadata()
+	if exp, act := "upload", meta.Get("http_server_part_name"); exp != act {
+		t.Errorf("Wrong part name: %v != %v", act, exp)
+	}
+	if exp, act := "foo.txt", meta.Get("http_server_part_filename"); exp != act {
+		t.Errorf("Wrong part filename: %v != %v", act, exp)
+	}
+	if exp, act := "application/octet-stream", meta.Get("http_server_part_content_type"); exp != act {
+		t.Errorf("Wrong part content type: %v != %v", act, exp)
+	}
+	if exp, act := "bar", meta.Get("foo"); exp != act {
+		t.Errorf("Wrong query metadata: %v != %v", act, exp)
+	}
+
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Error("Timed out waiting for response")
+	}
+
+	h.CloseAsync()
+}
+
 func TestHTTPBadRequests(t *testing.T) {
 	t.Parallel()
 
@@ -493,3 +593,58 @@ func TestHTTPServerWSRateLimit(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHTTPServerWSAllowedOrigins(t *testing.T) {
+	t.Parallel()
+
+	reg := apiRegMutWrapper{mut: &http.ServeMux{}}
+
+	mgr, err := manager.New(manager.NewConfig(), reg, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.HTTPServer.WSPath = "/testws"
+	conf.HTTPServer.WSAllowedOrigins = []string{"https://example.com"}
+
+	h, err := NewHTTPServer(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.CloseAsync()
+
+	server := httptest.NewServer(reg.mut)
+	defer server.Close()
+
+	purl, err := url.Parse(server.URL + "/testws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	purl.Scheme = "ws"
+
+	tests := map[string]bool{
+		"":                         true,
+		server.URL:                 true,
+		"https://example.com":      true,
+		"https://EXAMPLE.com":      true,
+		"https://evil.example.com": false,
+		"http://example.com":       false,
+	}
+
+	for origin, allowed := range tests {
+		header := http.Header{}
+		if len(origin) > 0 {
+			header.Set("Origin", origin)
+		}
+		client, _, err := websocket.DefaultDialer.Dial(purl.String(), header)
+		if allowed && err != nil {
+			t.Errorf("Expected origin '%v' to be allowed: %v", origin, err)
+		} else if !allowed && err == nil {
+			t.Errorf("Expected origin '%v' to be rejected", origin)
+		}
+		if client != nil {
+			client.Close()
+		}
+	}
+}
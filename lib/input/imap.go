@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeIMAP] = TypeSpec{
+		constructor: NewIMAP,
+		description: `
+Polls a mailbox of an IMAP server for emails matching the ` + "`search`" + `
+criteria, such as ` + "`UNSEEN`" + ` or ` + "`FROM \"alerts@example.com\"`" + `,
+and emits each email as a message. The criteria follow the syntax of the IMAP
+` + "`SEARCH`" + ` command.
+
+The first part of each message is the text body of the email, preferring plain
+text over HTML, and each following part is an attachment. Emails that cannot be
+parsed are emitted as a single part containing the raw email.
+
+Emails are read without being flagged as seen. Once a message has been
+successfully processed its email is flagged as seen when
+` + "`mark_seen`" + ` is true, deleted when ` + "`delete_on_finish`" + ` is
+true, or moved into the mailbox ` + "`move_to`" + ` when set. Failed messages
+are left untouched and are therefore read again after the next poll if they
+still match the search criteria.
+
+### Metadata
+
+This input adds the following metadata fields to each message part:
+
+` + "```" + `
+- imap_uid
+- imap_mailbox
+- email_content_type
+- email_attachment_filename*
+- email_<header>
+
+* Only added to attachment parts
+` + "```" + `
+
+Each header of the email is added as a metadata field with the lower cased name
+of the header, where dashes are replaced with underscores, such as
+` + "`email_subject`" + `, ` + "`email_from`" + ` and
+` + "`email_message_id`" + `.
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewIMAP creates a new IMAP input type.
+func NewIMAP(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewIMAP(conf.IMAP, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(
+		TypeIMAP,
+		true,
+		reader.NewAsyncPreserver(r),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/v3/lib/util/tls"
 )
 
@@ -52,8 +53,17 @@ that the server will be backwards compatible. In order to support newer client
 features you should increase this version up to the known version of the target
 server.
 
+When a ` + "`rack_id`" + ` is set messages are fetched from the closest replica
+of the partition within the same rack, which reduces cross-zone traffic in
+multi-zone deployments. This requires a ` + "`target_version`" + ` of at least
+2.4.0 and brokers configured with a ` + "`broker.rack`" + ` and the
+` + "`replica.selector.class`" + ` of
+` + "`org.apache.kafka.common.replica.RackAwareReplicaSelector`" + `.
+
 ` + tls.Documentation + `
 
+` + sasl.Documentation + `
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -73,7 +83,11 @@ water mark offset of the partition at the time of ingestion and the current
 message offset.
 
 You can access these metadata fields using
-[function interpolation](../config_interpolation.md#metadata).`,
+[function interpolation](../config_interpolation.md#metadata).
+
+When a [tracer](../tracers/README.md) is configured and the headers of a message
+contain a span context, such as those written by the ` + "`kafka`" + ` output,
+the span of the message is created as a child of it.`,
 		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
 			return sanitiseWithBatch(conf.Kafka, conf.Kafka.Batching)
 		},
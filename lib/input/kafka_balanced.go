@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/v3/lib/util/tls"
 )
 
@@ -52,6 +53,8 @@ estimated time taken to process a message.
 
 ` + tls.Documentation + `
 
+` + sasl.Documentation + `
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
@@ -71,7 +74,11 @@ water mark offset of the partition at the time of ingestion and the current
 message offset.
 
 You can access these metadata fields using
-[function interpolation](../config_interpolation.md#metadata).`,
+[function interpolation](../config_interpolation.md#metadata).
+
+When a [tracer](../tracers/README.md) is configured and the headers of a message
+contain a span context, such as those written by the ` + "`kafka`" + ` output,
+the span of the message is created as a child of it.`,
 		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
 			return sanitiseWithBatch(conf.KafkaBalanced, conf.KafkaBalanced.Batching)
 		},
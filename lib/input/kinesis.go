@@ -45,6 +45,22 @@ Use the ` + "`batching`" + ` fields to configure an optional
 use [broker based batching](../batching.md#combined-batching) with this input
 type.
 
+### Enhanced Fan-Out
+
+When ` + "`enhanced_fan_out.enabled`" + ` is set to ` + "`true`" + ` the input
+registers a stream consumer (named ` + "`enhanced_fan_out.consumer_name`" + `,
+or ` + "`client_id`" + ` when empty) and subscribes to shards with
+` + "`SubscribeToShard`" + `, which gives each consumer dedicated read
+throughput. In this mode the ` + "`shard`" + ` and ` + "`limit`" + ` fields are
+ignored and all shards of the stream are consumed. When a stream is resharded
+the child shards are consumed once their parents have been consumed entirely,
+which preserves the ordering of records with the same partition key.
+
+Shards are checkpointed in the DynamoDB table when one is specified, using the
+same table layout described above. Shards that have been consumed entirely are
+marked with the sequence ` + "`SHARD_END`" + `. Shards are not balanced across
+instances, therefore each instance should use a distinct consumer name.
+
 ### Credentials
 
 By default Benthos will use a shared credentials file when connecting to AWS
@@ -61,6 +77,9 @@ allowing you to transfer data across accounts. You can find out more
 
 // NewKinesis creates a new AWS Kinesis input type.
 func NewKinesis(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	if conf.Kinesis.EnhancedFanOut.Enabled {
+		return newKinesisEFO(conf, mgr, log, stats)
+	}
 	k, err := reader.NewKinesis(conf.Kinesis, log, stats)
 	if err != nil {
 		return nil, err
@@ -78,4 +97,17 @@ func NewKinesis(conf Config, mgr types.Manager, log log.Modular, stats metrics.T
 	)
 }
 
+func newKinesisEFO(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	var a reader.Async
+	var err error
+	if a, err = reader.NewKinesisEFO(conf.Kinesis, log, stats); err != nil {
+		return nil, err
+	}
+	a = reader.NewAsyncPreserver(a)
+	if a, err = reader.NewAsyncBatcher(conf.Kinesis.Batching, a, mgr, log, stats); err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeKinesis, true, a, log, stats)
+}
+
 //------------------------------------------------------------------------------
@@ -0,0 +1,92 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeMongoDBChangeStream] = TypeSpec{
+		constructor: NewMongoDBChangeStream,
+		description: `
+Watches a MongoDB change stream of a collection, or of all collections within a
+database when ` + "`collection`" + ` is empty, and emits each change event as a
+message of relaxed Extended JSON:
+
+` + "```json" + `
+{
+  "_id": {"_data": "825E..."},
+  "operationType": "update",
+  "ns": {"db": "shop", "coll": "users"},
+  "documentKey": {"_id": {"$oid": "5e8f8f8f8f8f8f8f8f8f8f8f"}},
+  "updateDescription": {"updatedFields": {"name": "bar"}, "removedFields": []},
+  "fullDocument": {"_id": {"$oid": "5e8f8f8f8f8f8f8f8f8f8f8f"}, "name": "bar"}
+}
+` + "```" + `
+
+When ` + "`full_document`" + ` is ` + "`updateLookup`" + ` update events include
+the current state of the whole document in ` + "`fullDocument`" + `, otherwise
+only inserts and replacements include it.
+
+Change streams require a replica set or sharded cluster running MongoDB 3.6 or
+later. The ` + "`url`" + ` is a MongoDB connection string, which may list several
+hosts and include credentials and options such as ` + "`authSource`" + `.
+
+The resume token of the most recent event for which it and all prior events
+have been acknowledged is stored as a checkpoint under the key
+` + "`cache_key`" + ` of a [cache resource](../caches/README.md), and the stream
+resumes after the checkpoint on restart. When no checkpoint exists the stream
+begins with events that occur after the first connection. Failed messages are
+retried until they succeed, giving at-least-once delivery.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- mongodb_operation_type
+- mongodb_database
+- mongodb_collection
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoDBChangeStream creates a new MongoDBChangeStream input type.
+func NewMongoDBChangeStream(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewMongoDBChangeStream(conf.MongoDBChangeStream, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeMongoDBChangeStream, false, reader.NewAsyncPreserver(r), log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -39,16 +39,32 @@ Messages consumed by this input can be processed in parallel, meaning a single
 instance of this input can utilise any number of threads within a
 ` + "`pipeline`" + ` section of a config.
 
+The ` + "`protocol_version`" + ` can be either ` + "`3.1.1`" + ` (the default) or
+` + "`5`" + `. With version 5 the field ` + "`session_expiry_interval`" + ` sets
+how long the broker keeps the session of the client after it disconnects, which
+requires ` + "`clean_session`" + ` to be false in order for the session to be
+resumed.
+
+When a ` + "`shared_group`" + ` is set the topics are subscribed to as shared
+subscriptions (` + "`$share/<group>/<topic>`" + `), and the broker distributes
+messages across all clients subscribed with the same group. This allows
+multiple instances of Benthos to consume from the same topics without receiving
+duplicate messages. Brokers that implement MQTT 3.1.1 often also support shared
+subscriptions.
+
 ### Metadata
 
 This input adds the following metadata fields to each message:
 
 ` + "``` text" + `
-- mqtt_duplicate
+- mqtt_duplicate (3.1.1 only)
 - mqtt_qos
 - mqtt_retained
 - mqtt_topic
-- mqtt_message_id
+- mqtt_message_id (3.1.1 only)
+- mqtt_content_type (5 only)
+- mqtt_response_topic (5 only)
+- All user properties (5 only)
 ` + "```" + `
 
 You can access these metadata fields using
@@ -0,0 +1,96 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeMySQLCDC] = TypeSpec{
+		constructor: NewMySQLCDC,
+		description: `
+Streams row changes from the binlog of a MySQL server by acting as a replica,
+and emits each change as a JSON document:
+
+` + "```json" + `
+{
+  "operation": "update",
+  "schema": "shop",
+  "table": "users",
+  "gtid": "3e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+  "before": {"id": 1, "name": "foo"},
+  "after": {"id": 1, "name": "bar"}
+}
+` + "```" + `
+
+The ` + "`operation`" + ` is one of ` + "`insert`, `update` or `delete`" + `, and the
+changes of each transaction are emitted as a single batch. The server must have
+` + "`gtid_mode`" + ` set to ` + "`ON`" + `, ` + "`binlog_format`" + ` set to
+` + "`ROW`" + ` and, for complete before and after images,
+` + "`binlog_row_image`" + ` set to ` + "`FULL`" + `. The user requires the
+` + "`REPLICATION SLAVE`" + ` and ` + "`REPLICATION CLIENT`" + ` privileges, as well
+as read access to ` + "`information_schema`" + ` which is used for resolving
+column names. The ` + "`server_id`" + ` must be unique amongst all replicas of the
+server.
+
+The GTID set of acknowledged transactions is stored as a checkpoint under the
+key ` + "`cache_key`" + ` of a [cache resource](../caches/README.md), and the
+stream resumes after the checkpoint on restart. When no checkpoint exists the
+stream begins with transactions executed after the first connection. Failed
+batches are retried until they succeed, giving at-least-once delivery.
+
+Only the ` + "`mysql_native_password`" + ` and ` + "`caching_sha2_password`" + `
+authentication plugins are supported, and TLS connections are not.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- mysql_cdc_operation
+- mysql_cdc_schema
+- mysql_cdc_table
+- mysql_cdc_gtid
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewMySQLCDC creates a new MySQLCDC input type.
+func NewMySQLCDC(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewMySQLCDC(conf.MySQLCDC, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeMySQLCDC, false, reader.NewAsyncPreserver(r), log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -40,7 +40,24 @@ instance of this input can utilise any number of threads within a
 ` + "`pipeline`" + ` section of a config.
 
 Use the ` + "`batching`" + ` fields to configure an optional
-[batching policy](../batching.md#batch-policy).`,
+[batching policy](../batching.md#batch-policy).
+
+### Flow Control
+
+The field ` + "`max_in_flight`" + ` is the total number of messages that can be
+in flight at any given time, which is divided evenly across each nsqd
+connection. When ` + "`max_in_flight_per_connection`" + ` is greater than zero
+it is used instead, and the total is scaled with the number of connections as
+nsqd instances are discovered or lost.
+
+Messages that are rejected downstream are requeued, which causes the consumer to
+back off with an exponential delay that is capped at
+` + "`max_backoff_duration`" + `. Set it to ` + "`0s`" + ` in order to disable
+back off for bursty consumption patterns.
+
+When ` + "`ephemeral_channel`" + ` is ` + "`true`" + ` the channel is created as
+ephemeral, meaning it is deleted by nsqd once the last consumer disconnects and
+messages are never written to disk.`,
 		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
 			return sanitiseWithBatch(conf.NSQ, conf.NSQ.Batching)
 		},
@@ -0,0 +1,80 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeParquet] = TypeSpec{
+		constructor: NewParquet,
+		description: `
+Reads one or more Parquet files, where each row becomes a message containing a
+JSON object. Each path can be a glob pattern such as ` + "`/data/*.parquet`" + `,
+and files are consumed in the order that they are listed and matched.
+
+Values are converted according to the logical type of their column. Strings,
+enums and UUIDs become JSON strings, decimals become exact JSON numbers, dates
+are formatted as ` + "`2006-01-02`" + ` and timestamps (including legacy
+` + "`INT96`" + ` timestamps) are formatted as RFC3339 in UTC. Nested groups
+become objects, repeated fields and ` + "`LIST`" + ` groups become arrays and
+` + "`MAP`" + ` groups become objects keyed by the string form of their keys.
+
+Pages encoded with ` + "`PLAIN`" + `, ` + "`RLE`" + ` or dictionary encodings
+and compressed with snappy, gzip, zstd or not at all are supported.
+
+Parquet files read by other inputs, such as ` + "`s3`" + `, can be expanded
+into rows with the ` + "`parquet`" + ` format of the
+[` + "`unarchive`" + `](../processors/README.md#unarchive) processor, and the
+` + "`gcp_cloud_storage`" + ` and ` + "`azure_blob_storage`" + ` inputs
+support a ` + "`parquet`" + ` codec.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- path
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewParquet creates a new Parquet input type.
+func NewParquet(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewParquet(conf.Parquet, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeParquet, true, reader.NewAsyncPreserver(r), log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,97 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypePostgresCDC] = TypeSpec{
+		constructor: NewPostgresCDC,
+		description: `
+Consumes row changes from a PostgreSQL logical replication slot and emits each
+change as a JSON document:
+
+` + "```json" + `
+{
+  "operation": "update",
+  "schema": "public",
+  "table": "users",
+  "lsn": "0/16B3748",
+  "xid": 563,
+  "before": {"id": 1, "name": "foo"},
+  "after": {"id": 1, "name": "bar"}
+}
+` + "```" + `
+
+The ` + "`operation`" + ` is one of ` + "`insert`, `update`, `delete` or `truncate`" + `.
+The ` + "`before`" + ` image is only populated for updates and deletes, and only
+contains the replica identity columns of a table unless it has been configured
+with ` + "`REPLICA IDENTITY FULL`" + `.
+
+The ` + "`plugin`" + ` field specifies the output plugin of the slot, and can be
+either ` + "`wal2json`" + ` (which must be installed on the server) or the
+built-in ` + "`pgoutput`" + `, which also requires a list of ` + "`publications`" + `.
+When ` + "`create_slot`" + ` is true the slot is created with this plugin if it does
+not already exist.
+
+Changes are read in batches of complete transactions of up to ` + "`max_changes`" + `
+changes, and the slot is only advanced past a batch once it has been
+acknowledged downstream. If a batch fails to be delivered it is read again,
+and if Benthos restarts before acknowledging a batch it is replayed, giving
+at-least-once delivery. Changes are read using the SQL functions of logical
+decoding, which requires PostgreSQL 11 or later and a user with the
+` + "`REPLICATION`" + ` attribute.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- postgres_cdc_operation
+- postgres_cdc_schema
+- postgres_cdc_table
+- postgres_cdc_lsn
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewPostgresCDC creates a new PostgresCDC input type.
+func NewPostgresCDC(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewPostgresCDC(conf.PostgresCDC, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypePostgresCDC, false, r, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypePulsar] = TypeSpec{
+		constructor: NewPulsar,
+		description: `
+Consumes messages from one or more Apache Pulsar topics using a named
+subscription.
+
+The ` + "`subscription_type`" + ` can be one of ` + "`shared`" + ` (the
+default), ` + "`failover`" + `, ` + "`exclusive`" + ` or
+` + "`key_shared`" + `. Shared and key shared subscriptions distribute
+messages across all consumers of the subscription, where key shared
+subscriptions deliver messages of the same key to the same consumer. Failover
+and exclusive subscriptions deliver all messages of a topic to a single
+consumer.
+
+Messages are acknowledged once they have been successfully processed, and
+messages that fail are negatively acknowledged, causing the broker to redeliver
+them after a delay. New subscriptions begin at the latest message unless
+` + "`start_from_oldest`" + ` is true.
+
+### Authentication
+
+Connections are encrypted when the ` + "`url`" + ` uses the
+` + "`pulsar+ssl://`" + ` scheme, in which case a custom certificate
+authority can be set with ` + "`tls.root_cas_file`" + `. Clients can be
+authenticated either with a JSON Web Token, set within ` + "`auth.token`" + `,
+or with a client certificate, set within ` + "`auth.tls`" + `.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- pulsar_topic
+- pulsar_key
+- pulsar_ordering_key
+- pulsar_producer_name
+- pulsar_redelivery_count
+- pulsar_publish_time_unix
+- pulsar_event_time_unix
+- All message properties
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewPulsar creates a new Pulsar input type.
+func NewPulsar(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewPulsar(conf.Pulsar, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypePulsar, true, r, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -50,6 +50,20 @@ type reaches the end of a file it will shut down. By default this type will also
 shut down. If you wish for the input type to be restarted every time it shuts
 down until the condition is met then set ` + "`restart_input` to `true`." + `
 
+### Bounded Reads
+
+The field ` + "`max_count`" + `, when greater than zero, ends the stream once that
+many messages have been consumed, where the last message is treated the same as
+one that triggered the condition. The field ` + "`idle_timeout`" + `, when set to
+a non-empty duration string, ends the stream once no messages have been consumed
+for that period. These can be combined with the condition, and the stream ends
+on whichever occurs first. In order to end the stream only on a count or idle
+timeout set the condition to ` + "`static: false`" + `.
+
+Conditions are not limited to message contents, for example the
+` + "[`metadata`](../conditions/README.md#metadata)" + ` condition can be used
+to end the stream based on the metadata of a message.
+
 ### Metadata
 
 A metadata key ` + "`benthos_read_until` containing the value `final`" + ` is
@@ -69,6 +83,8 @@ added to the first part of the message that triggers to input to stop.`,
 				"input":         inputSanit,
 				"restart_input": conf.ReadUntil.Restart,
 				"condition":     condSanit,
+				"max_count":     conf.ReadUntil.MaxCount,
+				"idle_timeout":  conf.ReadUntil.IdleTimeout,
 			}, nil
 		},
 	}
@@ -78,34 +94,42 @@ added to the first part of the message that triggers to input to stop.`,
 
 // ReadUntilConfig contains configuration values for the ReadUntil input type.
 type ReadUntilConfig struct {
-	Input     *Config          `json:"input" yaml:"input"`
-	Restart   bool             `json:"restart_input" yaml:"restart_input"`
-	Condition condition.Config `json:"condition" yaml:"condition"`
+	Input       *Config          `json:"input" yaml:"input"`
+	Restart     bool             `json:"restart_input" yaml:"restart_input"`
+	Condition   condition.Config `json:"condition" yaml:"condition"`
+	MaxCount    int              `json:"max_count" yaml:"max_count"`
+	IdleTimeout string           `json:"idle_timeout" yaml:"idle_timeout"`
 }
 
 // NewReadUntilConfig creates a new ReadUntilConfig with default values.
 func NewReadUntilConfig() ReadUntilConfig {
 	return ReadUntilConfig{
-		Input:     nil,
-		Restart:   false,
-		Condition: condition.NewConfig(),
+		Input:       nil,
+		Restart:     false,
+		Condition:   condition.NewConfig(),
+		MaxCount:    0,
+		IdleTimeout: "",
 	}
 }
 
 //------------------------------------------------------------------------------
 
 type dummyReadUntilConfig struct {
-	Input     interface{}      `json:"input" yaml:"input"`
-	Restart   bool             `json:"restart_input" yaml:"restart_input"`
-	Condition condition.Config `json:"condition" yaml:"condition"`
+	Input       interface{}      `json:"input" yaml:"input"`
+	Restart     bool             `json:"restart_input" yaml:"restart_input"`
+	Condition   condition.Config `json:"condition" yaml:"condition"`
+	MaxCount    int              `json:"max_count" yaml:"max_count"`
+	IdleTimeout string           `json:"idle_timeout" yaml:"idle_timeout"`
 }
 
 // MarshalJSON prints an empty object instead of nil.
 func (r ReadUntilConfig) MarshalJSON() ([]byte, error) {
 	dummy := dummyReadUntilConfig{
-		Input:     r.Input,
-		Restart:   r.Restart,
-		Condition: r.Condition,
+		Input:       r.Input,
+		Restart:     r.Restart,
+		Condition:   r.Condition,
+		MaxCount:    r.MaxCount,
+		IdleTimeout: r.IdleTimeout,
 	}
 	if r.Input == nil {
 		dummy.Input = struct{}{}
@@ -116,9 +140,11 @@ func (r ReadUntilConfig) MarshalJSON() ([]byte, error) {
 // MarshalYAML prints an empty object instead of nil.
 func (r ReadUntilConfig) MarshalYAML() (interface{}, error) {
 	dummy := dummyReadUntilConfig{
-		Input:     r.Input,
-		Restart:   r.Restart,
-		Condition: r.Condition,
+		Input:       r.Input,
+		Restart:     r.Restart,
+		Condition:   r.Condition,
+		MaxCount:    r.MaxCount,
+		IdleTimeout: r.IdleTimeout,
 	}
 	if r.Input == nil {
 		dummy.Input = struct{}{}
@@ -134,8 +160,9 @@ type ReadUntil struct {
 	running int32
 	conf    ReadUntilConfig
 
-	wrapped Type
-	cond    condition.Type
+	wrapped     Type
+	cond        condition.Type
+	idleTimeout time.Duration
 
 	wrapperMgr   types.Manager
 	wrapperLog   log.Modular
@@ -161,6 +188,14 @@ func NewReadUntil(
 		return nil, errors.New("cannot create read_until input without a child")
 	}
 
+	var idleTimeout time.Duration
+	if len(conf.ReadUntil.IdleTimeout) > 0 {
+		var err error
+		if idleTimeout, err = time.ParseDuration(conf.ReadUntil.IdleTimeout); err != nil {
+			return nil, fmt.Errorf("failed to parse idle_timeout string: %v", err)
+		}
+	}
+
 	wrapped, err := New(
 		*conf.ReadUntil.Input, mgr, log, stats,
 	)
@@ -189,6 +224,7 @@ func NewReadUntil(
 		stats:        metrics.Namespaced(stats, "read_until"),
 		wrapped:      wrapped,
 		cond:         cond,
+		idleTimeout:  idleTimeout,
 		transactions: make(chan types.Transaction),
 		closeChan:    make(chan struct{}),
 		closedChan:   make(chan struct{}),
@@ -212,6 +248,7 @@ func (r *ReadUntil) loop() {
 		mFinalResSent    = r.stats.GetCounter("final.response.sent")
 		mFinalResSucc    = r.stats.GetCounter("final.response.success")
 		mFinalResErr     = r.stats.GetCounter("final.response.error")
+		mIdleTimeout     = r.stats.GetCounter("idle.timeout")
 	)
 
 	defer func() {
@@ -229,6 +266,7 @@ func (r *ReadUntil) loop() {
 	mRunning.Incr(1)
 
 	var open bool
+	var count int
 
 runLoop:
 	for atomic.LoadInt32(&r.running) == 1 {
@@ -248,6 +286,11 @@ runLoop:
 			}
 		}
 
+		var idleChan <-chan time.Time
+		if r.idleTimeout > 0 {
+			idleChan = time.After(r.idleTimeout)
+		}
+
 		var tran types.Transaction
 		select {
 		case tran, open = <-r.wrapped.TransactionChan():
@@ -256,12 +299,18 @@ runLoop:
 				r.wrapped = nil
 				continue runLoop
 			}
+		case <-idleChan:
+			mIdleTimeout.Incr(1)
+			r.log.Infof("No messages consumed within idle timeout of %v, shutting down\n", r.idleTimeout)
+			return
 		case <-r.closeChan:
 			return
 		}
 		mCount.Incr(1)
+		count++
 
-		if !r.cond.Check(tran.Payload) {
+		countReached := r.conf.MaxCount > 0 && count >= r.conf.MaxCount
+		if !countReached && !r.cond.Check(tran.Payload) {
 			select {
 			case r.transactions <- tran:
 				mPropagated.Incr(1)
@@ -29,6 +29,7 @@ import (
 
 	"github.com/Jeffail/benthos/v3/lib/condition"
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -73,6 +74,9 @@ baz`)
 	t.Run("ReadUntilInputCloseRestart", func(te *testing.T) {
 		testReadUntilInputCloseRestart(inconf, te)
 	})
+	t.Run("ReadUntilMaxCount", func(te *testing.T) {
+		testReadUntilMaxCount(inconf, te)
+	})
 }
 
 func testReadUntilBasic(inConf Config, t *testing.T) {
@@ -410,3 +414,127 @@ func testReadUntilInputCloseRestart(inConf Config, t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func testReadUntilMaxCount(inConf Config, t *testing.T) {
+	cond := condition.NewConfig()
+	cond.Type = "static"
+	cond.Static = false
+
+	rConf := NewConfig()
+	rConf.Type = "read_until"
+	rConf.ReadUntil.Input = &inConf
+	rConf.ReadUntil.Condition = cond
+	rConf.ReadUntil.Restart = true
+	rConf.ReadUntil.MaxCount = 5
+
+	in, err := New(rConf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expMsgs := []string{
+		"foo",
+		"bar",
+		"baz",
+		"foo",
+		"bar",
+	}
+
+	for i, expMsg := range expMsgs {
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-in.TransactionChan():
+			if !open {
+				t.Fatal("transaction chan closed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+
+		if exp, act := expMsg, string(tran.Payload.Get(0).Get()); exp != act {
+			t.Errorf("Wrong message contents: %v != %v", act, exp)
+		}
+		if i == len(expMsgs)-1 {
+			if exp, act := "final", tran.Payload.Get(0).Metadata().Get("benthos_read_until"); exp != act {
+				t.Errorf("Metadata missing from final message: %v != %v", act, exp)
+			}
+		} else if exp, act := "", tran.Payload.Get(0).Metadata().Get("benthos_read_until"); exp != act {
+			t.Errorf("Metadata final message metadata added to non-final message: %v", act)
+		}
+
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	// Should close automatically now
+	select {
+	case _, open := <-in.TransactionChan():
+		if open {
+			t.Fatal("transaction chan not closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	if err = in.WaitForClose(time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadUntilIdleTimeout(t *testing.T) {
+	inConf := NewConfig()
+	inConf.Type = "inproc"
+	inConf.Inproc = "read_until_idle_timeout_test"
+
+	cond := condition.NewConfig()
+	cond.Type = "static"
+	cond.Static = false
+
+	rConf := NewConfig()
+	rConf.Type = "read_until"
+	rConf.ReadUntil.Input = &inConf
+	rConf.ReadUntil.Condition = cond
+	rConf.ReadUntil.IdleTimeout = "50ms"
+
+	mgr, err := manager.New(manager.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := New(rConf, mgr, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case _, open := <-in.TransactionChan():
+		if open {
+			t.Fatal("transaction chan not closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	if err = in.WaitForClose(time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadUntilBadIdleTimeout(t *testing.T) {
+	inConf := NewConfig()
+	inConf.Type = "inproc"
+	inConf.Inproc = "read_until_bad_idle_timeout_test"
+
+	rConf := NewConfig()
+	rConf.Type = "read_until"
+	rConf.ReadUntil.Input = &inConf
+	rConf.ReadUntil.IdleTimeout = "not a duration"
+
+	if _, err := New(rConf, nil, log.Noop(), metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad idle_timeout")
+	}
+}
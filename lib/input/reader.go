@@ -97,6 +97,7 @@ func (r *Reader) loop() {
 		mFailedConn = r.stats.GetCounter("connection.failed")
 		mLostConn   = r.stats.GetCounter("connection.lost")
 		mLatency    = r.stats.GetTimer("latency")
+		mBlocked    = r.stats.GetTimer("blocked")
 	)
 
 	defer func() {
@@ -180,11 +181,13 @@ func (r *Reader) loop() {
 		}
 
 		tracing.InitSpans("input_"+r.typeStr, msg)
+		tBlocked := time.Now()
 		select {
 		case r.transactions <- types.NewTransaction(msg, r.responses):
 		case <-r.closeChan:
 			return
 		}
+		mBlocked.Timing(time.Since(tBlocked).Nanoseconds())
 
 		var res types.Response
 		var open bool
@@ -21,10 +21,12 @@
 package reader
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strconv"
 	"strings"
@@ -57,6 +59,7 @@ type AmazonS3Config struct {
 	sess.Config        `json:",inline" yaml:",inline"`
 	Bucket             string                  `json:"bucket" yaml:"bucket"`
 	Prefix             string                  `json:"prefix" yaml:"prefix"`
+	Codec              string                  `json:"codec" yaml:"codec"`
 	Retries            int                     `json:"retries" yaml:"retries"`
 	ForcePathStyleURLs bool                    `json:"force_path_style_urls" yaml:"force_path_style_urls"`
 	DownloadManager    S3DownloadManagerConfig `json:"download_manager" yaml:"download_manager"`
@@ -77,6 +80,7 @@ func NewAmazonS3Config() AmazonS3Config {
 		Config:             sess.NewConfig(),
 		Bucket:             "",
 		Prefix:             "",
+		Codec:              "all-bytes",
 		Retries:            3,
 		ForcePathStyleURLs: false,
 		DownloadManager: S3DownloadManagerConfig{
@@ -117,6 +121,8 @@ type AmazonS3 struct {
 	targetKeysMut sync.Mutex
 
 	readMethod func() (types.Part, objKey, error)
+	codecCtor  partCodecCtor
+	current    *s3Object
 
 	session    *session.Session
 	s3         *s3.S3
@@ -152,8 +158,13 @@ func NewAmazonS3(
 	if conf.MaxBatchCount < 1 {
 		return nil, fmt.Errorf("max_batch_count '%v' must be > 0", conf.MaxBatchCount)
 	}
+	codecCtor, err := getPartCodec(conf.Codec)
+	if err != nil {
+		return nil, err
+	}
 	s := &AmazonS3{
 		conf:          conf,
+		codecCtor:     codecCtor,
 		sqsBodyPath:   conf.SQSBodyPath,
 		sqsEnvPath:    conf.SQSEnvelopePath,
 		sqsBucketPath: conf.SQSBucketPath,
@@ -491,6 +502,52 @@ func (a *AmazonS3) popTargetKey() {
 	}
 }
 
+// s3Object is an object that is being decoded into messages, which is
+// finished once all of its parts have been read and acknowledged.
+type s3Object struct {
+	key      objKey
+	meta     types.Part
+	parts    partCodec
+	next     []byte
+	nextErr  error
+	pending  int
+	finished bool
+	failed   bool
+}
+
+// advance reads ahead to the next part of the object, so that the object is
+// known to be finished as soon as its last part has been read.
+func (o *s3Object) advance() {
+	o.next, o.nextErr = o.parts.Next()
+}
+
+// finishParts closes the parts of the current object once they have all been
+// read. The mutex must be held when calling.
+func (a *AmazonS3) finishParts(o *s3Object) {
+	if o.nextErr != io.EOF {
+		a.log.Errorf("Failed to decode object '%v': %v\n", o.key.s3Key, o.nextErr)
+	}
+	o.parts.Close()
+	o.finished = true
+	a.current = nil
+}
+
+// resolveObject either deletes or rejects an object once all of its parts have
+// been acknowledged.
+func (a *AmazonS3) resolveObject(o *s3Object) {
+	if !o.failed {
+		a.deleteObjects([]objKey{o.key})
+		return
+	}
+	if len(a.conf.SQSURL) == 0 {
+		a.targetKeysMut.Lock()
+		a.targetKeys = append(a.targetKeys, o.key)
+		a.targetKeysMut.Unlock()
+	} else {
+		a.rejectObjects([]objKey{o.key})
+	}
+}
+
 // ReadWithContext attempts to read a new message from the target S3 bucket.
 func (a *AmazonS3) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
 	a.targetKeysMut.Lock()
@@ -500,39 +557,69 @@ func (a *AmazonS3) ReadWithContext(ctx context.Context) (types.Message, AsyncAck
 		return nil, nil, types.ErrNotConnected
 	}
 
-	if len(a.targetKeys) == 0 {
-		if a.sqs != nil {
-			if err := a.readSQSEvents(); err != nil {
-				return nil, nil, err
+	for a.current == nil {
+		if len(a.targetKeys) == 0 {
+			if a.sqs != nil {
+				if err := a.readSQSEvents(); err != nil {
+					return nil, nil, err
+				}
+			} else {
+				// If we aren't using SQS but exhausted our targets we are done.
+				return nil, nil, types.ErrTypeClosed
 			}
-		} else {
-			// If we aren't using SQS but exhausted our targets we are done.
-			return nil, nil, types.ErrTypeClosed
 		}
-	}
-	if len(a.targetKeys) == 0 {
-		return nil, nil, types.ErrTimeout
-	}
+		if len(a.targetKeys) == 0 {
+			return nil, nil, types.ErrTimeout
+		}
 
-	msg := message.New(nil)
+		part, obj, err := a.readMethod()
+		if err != nil {
+			return nil, nil, err
+		}
 
-	part, obj, err := a.readMethod()
-	if err != nil {
-		return nil, nil, err
+		parts, err := a.codecCtor(partCodecHint{
+			path:        obj.s3Key,
+			contentType: part.Metadata().Get("s3_content_type"),
+		}, ioutil.NopCloser(bytes.NewReader(part.Get())))
+		if err != nil {
+			a.log.Errorf("Failed to decode object '%v': %v\n", obj.s3Key, err)
+			if a.sqs != nil {
+				a.rejectObjects([]objKey{obj})
+			}
+			continue
+		}
+
+		o := &s3Object{key: obj, meta: part, parts: parts}
+		if o.advance(); o.nextErr != nil {
+			// The object has no parts and so is consumed straight away.
+			a.current = o
+			a.finishParts(o)
+			go a.deleteObjects([]objKey{obj})
+			continue
+		}
+		a.current = o
+	}
+
+	o := a.current
+	part := o.meta.Copy()
+	part.Set(o.next)
+	o.pending++
+	if o.advance(); o.nextErr != nil {
+		a.finishParts(o)
 	}
 
+	msg := message.New(nil)
 	msg.Append(part)
 	return msg, func(rctx context.Context, res types.Response) error {
-		if res.Error() == nil {
-			a.deleteObjects([]objKey{obj})
-		} else {
-			if len(a.conf.SQSURL) == 0 {
-				a.targetKeysMut.Lock()
-				a.targetKeys = append(a.readKeys, obj)
-				a.targetKeysMut.Unlock()
-			} else {
-				a.rejectObjects([]objKey{obj})
-			}
+		a.targetKeysMut.Lock()
+		o.pending--
+		if res.Error() != nil {
+			o.failed = true
+		}
+		done := o.pending == 0 && o.finished
+		a.targetKeysMut.Unlock()
+		if done {
+			a.resolveObject(o)
 		}
 		return nil
 	}, nil
@@ -0,0 +1,431 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/azure/storage"
+)
+
+//------------------------------------------------------------------------------
+
+// AzureBlobStorageConfig contains configuration fields for the
+// AzureBlobStorage input type.
+type AzureBlobStorageConfig struct {
+	storage.Config         `json:",inline" yaml:",inline"`
+	Container              string `json:"container" yaml:"container"`
+	Prefix                 string `json:"prefix" yaml:"prefix"`
+	Codec                  string `json:"codec" yaml:"codec"`
+	StorageQueue           string `json:"storage_queue" yaml:"storage_queue"`
+	QueueMaxMessages       int    `json:"queue_max_messages" yaml:"queue_max_messages"`
+	QueueVisibilityTimeout string `json:"queue_visibility_timeout" yaml:"queue_visibility_timeout"`
+	Timeout                string `json:"timeout" yaml:"timeout"`
+}
+
+// NewAzureBlobStorageConfig creates a new AzureBlobStorageConfig with default
+// values.
+func NewAzureBlobStorageConfig() AzureBlobStorageConfig {
+	return AzureBlobStorageConfig{
+		Config:                 storage.NewConfig(),
+		Container:              "",
+		Prefix:                 "",
+		Codec:                  "all-bytes",
+		StorageQueue:           "",
+		QueueMaxMessages:       10,
+		QueueVisibilityTimeout: "30s",
+		Timeout:                "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// azureQueuePollInterval is how long to wait before polling the storage queue
+// again after receiving no messages, as the REST API does not long poll.
+const azureQueuePollInterval = time.Second
+
+// azureBlobEvent is a blob event received from the storage queue of an Event
+// Grid subscription, where the message is attributed back to the queue message
+// once all of its events have been processed.
+type azureBlobEvent struct {
+	name  string
+	queue *azureQueueMessage
+}
+
+type azureQueueMessage struct {
+	msg     storage.QueueMessage
+	pending int
+}
+
+// azureBlob is a blob that is currently being read.
+type azureBlob struct {
+	event    azureBlobEvent
+	props    storage.BlobProperties
+	parts    partCodec
+	next     []byte
+	nextErr  error
+	pending  int
+	finished bool
+}
+
+// advance reads ahead to the next part of the blob, so that the blob is known
+// to be finished as soon as its last part has been read.
+func (b *azureBlob) advance() {
+	b.next, b.nextErr = b.parts.Next()
+}
+
+// AzureBlobStorage is an input type that downloads blobs from an Azure Blob
+// Storage container.
+type AzureBlobStorage struct {
+	conf              AzureBlobStorageConfig
+	codecCtor         partCodecCtor
+	timeout           time.Duration
+	visibilityTimeout int
+
+	mut     sync.Mutex
+	client  *storage.Client
+	targets []azureBlobEvent
+	current *azureBlob
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewAzureBlobStorage creates a new AzureBlobStorage input type.
+func NewAzureBlobStorage(conf AzureBlobStorageConfig, log log.Modular, stats metrics.Type) (*AzureBlobStorage, error) {
+	a := &AzureBlobStorage{
+		conf:  conf,
+		log:   log,
+		stats: stats,
+	}
+	if len(conf.Container) == 0 {
+		return nil, errors.New("a container must be specified")
+	}
+
+	var err error
+	if a.codecCtor, err = getPartCodec(conf.Codec); err != nil {
+		return nil, err
+	}
+	if a.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if len(conf.StorageQueue) > 0 {
+		if conf.QueueMaxMessages < 1 || conf.QueueMaxMessages > 32 {
+			return nil, fmt.Errorf("queue max messages must be between 1 and 32: %v", conf.QueueMaxMessages)
+		}
+		visibilityTimeout, err := time.ParseDuration(conf.QueueVisibilityTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse queue visibility timeout: %v", err)
+		}
+		a.visibilityTimeout = int(visibilityTimeout.Seconds())
+	}
+	if a.client, err = conf.Config.NewClient(a.timeout); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext lists the blobs of the container, unless blobs are
+// consumed from storage queue events.
+func (a *AzureBlobStorage) ConnectWithContext(ctx context.Context) error {
+	if len(a.conf.StorageQueue) > 0 {
+		a.log.Infof("Receiving Azure Blob Storage events from queue: %v\n", a.conf.StorageQueue)
+		return nil
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	if a.targets != nil {
+		return nil
+	}
+
+	targets := []azureBlobEvent{}
+	marker := ""
+	for {
+		items, next, err := a.client.ListBlobs(ctx, a.conf.Container, a.conf.Prefix, marker)
+		if err != nil {
+			return fmt.Errorf("failed to list blobs: %v", err)
+		}
+		for _, item := range items {
+			targets = append(targets, azureBlobEvent{name: item.Name})
+		}
+		if marker = next; len(marker) == 0 {
+			break
+		}
+	}
+	a.targets = targets
+
+	a.log.Infof("Downloading %v blobs from Azure Blob Storage container: %v\n", len(targets), a.conf.Container)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// blobNameFromEvent extracts the container and name of a created blob from an
+// Event Grid event in either the Event Grid or CloudEvents schema.
+func blobNameFromEvent(event map[string]interface{}) (container, name string, ok bool) {
+	eventType, _ := event["eventType"].(string)
+	if len(eventType) == 0 {
+		eventType, _ = event["type"].(string)
+	}
+	if eventType != "Microsoft.Storage.BlobCreated" {
+		return "", "", false
+	}
+	subject, _ := event["subject"].(string)
+	const prefix = "/blobServices/default/containers/"
+	if !strings.HasPrefix(subject, prefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(subject, prefix), "/blobs/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// parseQueueMessage returns the events of blobs within the container that were
+// created, as described by a storage queue message.
+func (a *AzureBlobStorage) parseQueueMessage(msg storage.QueueMessage) []azureBlobEvent {
+	text := []byte(msg.Text)
+	if decoded, err := base64.StdEncoding.DecodeString(msg.Text); err == nil {
+		text = decoded
+	}
+
+	var events []map[string]interface{}
+	if err := json.Unmarshal(text, &events); err != nil {
+		var event map[string]interface{}
+		if err = json.Unmarshal(text, &event); err != nil {
+			a.log.Errorf("Failed to parse storage queue message as JSON: %v\n", err)
+			return nil
+		}
+		events = append(events, event)
+	}
+
+	var blobEvents []azureBlobEvent
+	for _, event := range events {
+		container, name, ok := blobNameFromEvent(event)
+		if !ok || container != a.conf.Container || !strings.HasPrefix(name, a.conf.Prefix) {
+			continue
+		}
+		blobEvents = append(blobEvents, azureBlobEvent{name: name})
+	}
+	return blobEvents
+}
+
+// receiveEvents queues blob events from the storage queue. The mutex must be
+// held when calling.
+func (a *AzureBlobStorage) receiveEvents(ctx context.Context) error {
+	msgs, err := a.client.GetMessages(ctx, a.conf.StorageQueue, a.conf.QueueMaxMessages, a.visibilityTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to receive storage queue messages: %v", err)
+	}
+	for _, msg := range msgs {
+		events := a.parseQueueMessage(msg)
+		if len(events) == 0 {
+			// Messages without relevant events are removed immediately.
+			if err = a.client.DeleteMessage(ctx, a.conf.StorageQueue, msg); err != nil {
+				a.log.Errorf("Failed to delete storage queue message: %v\n", err)
+			}
+			continue
+		}
+		qMsg := &azureQueueMessage{msg: msg, pending: len(events)}
+		for _, e := range events {
+			e.queue = qMsg
+			a.targets = append(a.targets, e)
+		}
+	}
+	return nil
+}
+
+// nextBlob begins reading the next target blob. The mutex must be held when
+// calling.
+func (a *AzureBlobStorage) nextBlob(ctx context.Context) error {
+	for len(a.targets) > 0 {
+		event := a.targets[0]
+		a.targets = a.targets[1:]
+
+		props, body, err := a.client.GetBlob(ctx, a.conf.Container, event.name)
+		if err != nil {
+			if storage.IsNotFound(err) {
+				a.log.Warnf("Blob '%v' no longer exists\n", event.name)
+				a.blobDone(event)
+				continue
+			}
+			a.targets = append([]azureBlobEvent{event}, a.targets...)
+			return fmt.Errorf("failed to download blob '%v': %v", event.name, err)
+		}
+		parts, err := a.codecCtor(partCodecHint{path: event.name, contentType: props.ContentType}, body)
+		if err != nil {
+			a.log.Errorf("Failed to decode blob '%v': %v\n", event.name, err)
+			a.blobDone(event)
+			continue
+		}
+		a.current = &azureBlob{
+			event: event,
+			props: props,
+			parts: parts,
+		}
+		a.current.advance()
+		return nil
+	}
+	return nil
+}
+
+// blobDone is called once all messages of a blob have been acknowledged, and
+// deletes the queue message of the blob once all of its blobs are done.
+func (a *AzureBlobStorage) blobDone(event azureBlobEvent) {
+	if event.queue == nil {
+		return
+	}
+	if event.queue.pending--; event.queue.pending > 0 {
+		return
+	}
+	ctx, done := context.WithTimeout(context.Background(), a.timeout)
+	defer done()
+	if err := a.client.DeleteMessage(ctx, a.conf.StorageQueue, event.queue.msg); err != nil {
+		a.log.Errorf("Failed to delete storage queue message: %v\n", err)
+	}
+}
+
+// finishBlob closes the current blob once all of its parts have been read. The
+// mutex must be held when calling.
+func (a *AzureBlobStorage) finishBlob(blob *azureBlob) {
+	if blob.nextErr != io.EOF {
+		a.log.Errorf("Failed to read blob '%v': %v\n", blob.event.name, blob.nextErr)
+	}
+	blob.parts.Close()
+	blob.finished = true
+	a.current = nil
+}
+
+// readNext reads the next part from the blobs. A nil part is returned when
+// there are no blobs to read.
+func (a *AzureBlobStorage) readNext(ctx context.Context) (types.Part, *azureBlob, error) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	for {
+		if a.current == nil {
+			if len(a.targets) == 0 {
+				if len(a.conf.StorageQueue) == 0 {
+					return nil, nil, types.ErrTypeClosed
+				}
+				if err := a.receiveEvents(ctx); err != nil {
+					return nil, nil, err
+				}
+			}
+			if err := a.nextBlob(ctx); err != nil {
+				return nil, nil, err
+			}
+			if a.current == nil {
+				return nil, nil, nil
+			}
+		}
+
+		blob := a.current
+		if blob.nextErr != nil {
+			a.finishBlob(blob)
+			a.blobDone(blob.event)
+			continue
+		}
+		data := blob.next
+		blob.pending++
+		if blob.advance(); blob.nextErr != nil {
+			a.finishBlob(blob)
+		}
+
+		part := message.NewPart(data)
+		meta := part.Metadata()
+		meta.Set("blob_storage_key", blob.event.name)
+		meta.Set("blob_storage_container", a.conf.Container)
+		meta.Set("blob_storage_last_modified", blob.props.LastModified.Format(time.RFC3339))
+		meta.Set("blob_storage_last_modified_unix", strconv.FormatInt(blob.props.LastModified.Unix(), 10))
+		if len(blob.props.ContentType) > 0 {
+			meta.Set("blob_storage_content_type", blob.props.ContentType)
+		}
+		if len(blob.props.ContentEncoding) > 0 {
+			meta.Set("blob_storage_content_encoding", blob.props.ContentEncoding)
+		}
+		for k, v := range blob.props.Metadata {
+			meta.Set(k, v)
+		}
+		return part, blob, nil
+	}
+}
+
+// ReadWithContext reads the next part of the blobs as a message.
+func (a *AzureBlobStorage) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	part, blob, err := a.readNext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if part == nil {
+		select {
+		case <-time.After(azureQueuePollInterval):
+		case <-ctx.Done():
+		}
+		return nil, nil, types.ErrTimeout
+	}
+
+	msg := message.New(nil)
+	msg.Append(part)
+	return msg, func(rctx context.Context, res types.Response) error {
+		a.mut.Lock()
+		defer a.mut.Unlock()
+		if blob.pending--; blob.pending == 0 && blob.finished {
+			a.blobDone(blob.event)
+		}
+		return nil
+	}, nil
+}
+
+// CloseAsync shuts down the AzureBlobStorage input and stops processing
+// requests.
+func (a *AzureBlobStorage) CloseAsync() {
+	a.mut.Lock()
+	if a.current != nil {
+		a.current.parts.Close()
+		a.current = nil
+	}
+	a.mut.Unlock()
+}
+
+// WaitForClose blocks until the AzureBlobStorage input has closed down.
+func (a *AzureBlobStorage) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,315 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/azure/storage"
+)
+
+//------------------------------------------------------------------------------
+
+// azureTestServer serves the blobs of a single container and the messages of a
+// single queue.
+type azureTestServer struct {
+	mut     sync.Mutex
+	blobs   map[string]string
+	queue   []storage.QueueMessage
+	deleted []string
+}
+
+func (s *azureTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	switch {
+	case r.URL.Path == "/foo" && r.URL.Query().Get("comp") == "list":
+		var names []string
+		for k := range s.blobs {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+
+		// Return one blob per page in order to exercise pagination.
+		marker := r.URL.Query().Get("marker")
+		var result struct {
+			XMLName    xml.Name `xml:"EnumerationResults"`
+			Names      []string `xml:"Blobs>Blob>Name"`
+			NextMarker string   `xml:"NextMarker"`
+		}
+		for i, n := range names {
+			if n >= marker {
+				result.Names = []string{n}
+				if i+1 < len(names) {
+					result.NextMarker = names[i+1]
+				}
+				break
+			}
+		}
+		xml.NewEncoder(w).Encode(result)
+	case strings.HasPrefix(r.URL.Path, "/foo/"):
+		data, exists := s.blobs[strings.TrimPrefix(r.URL.Path, "/foo/")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", "Mon, 06 Jan 2020 00:00:00 GMT")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("x-ms-meta-owner", "bar")
+		w.Write([]byte(data))
+	case r.URL.Path == "/events/messages" && r.Method == http.MethodGet:
+		var result struct {
+			XMLName  xml.Name               `xml:"QueueMessagesList"`
+			Messages []storage.QueueMessage `xml:"QueueMessage"`
+		}
+		result.Messages = s.queue
+		s.queue = nil
+		xml.NewEncoder(w).Encode(result)
+	case strings.HasPrefix(r.URL.Path, "/events/messages/") && r.Method == http.MethodDelete:
+		s.deleted = append(s.deleted, strings.TrimPrefix(r.URL.Path, "/events/messages/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (s *azureTestServer) deletedMessages() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string{}, s.deleted...)
+}
+
+func azureTestConfig(server *httptest.Server) AzureBlobStorageConfig {
+	conf := NewAzureBlobStorageConfig()
+	conf.StorageConnectionString = "AccountName=foo;AccountKey=Zm9vYmFy;BlobEndpoint=" + server.URL + ";QueueEndpoint=" + server.URL
+	conf.Container = "foo"
+	return conf
+}
+
+func azureTestEvent(container, name string) string {
+	return `{"eventType":"Microsoft.Storage.BlobCreated","subject":"/blobServices/default/containers/` + container + `/blobs/` + name + `"}`
+}
+
+func azureTestRead(t *testing.T, a *AzureBlobStorage) (types.Message, AsyncAckFn) {
+	t.Helper()
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+	for {
+		msg, ackFn, err := a.ReadWithContext(ctx)
+		if err == types.ErrTimeout && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg, ackFn
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestAzureBlobStorageBadConfig(t *testing.T) {
+	server := httptest.NewServer(&azureTestServer{})
+	defer server.Close()
+
+	conf := azureTestConfig(server)
+	conf.Container = ""
+	if _, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing container")
+	}
+
+	conf = azureTestConfig(server)
+	conf.Codec = "nope"
+	if _, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad codec")
+	}
+
+	conf = azureTestConfig(server)
+	conf.StorageQueue = "events"
+	conf.QueueMaxMessages = 100
+	if _, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad max messages")
+	}
+
+	conf = azureTestConfig(server)
+	conf.StorageConnectionString = ""
+	if _, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing credentials")
+	}
+}
+
+func TestAzureBlobStorageList(t *testing.T) {
+	server := httptest.NewServer(&azureTestServer{
+		blobs: map[string]string{
+			"in/a.txt":  "foo\nbar",
+			"in/b.txt":  "baz",
+			"out/c.txt": "buz",
+		},
+	})
+	defer server.Close()
+
+	conf := azureTestConfig(server)
+	conf.Prefix = "in/"
+	conf.Codec = "lines"
+
+	a, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer a.CloseAsync()
+
+	var contents, keys []string
+	for i := 0; i < 3; i++ {
+		msg, ackFn := azureTestRead(t, a)
+		contents = append(contents, string(msg.Get(0).Get()))
+		keys = append(keys, msg.Get(0).Metadata().Get("blob_storage_key"))
+		if err = ackFn(context.Background(), response.NewAck()); err != nil {
+			t.Error(err)
+		}
+	}
+	if exp, act := []string{"foo", "bar", "baz"}, contents; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong contents: %v != %v", act, exp)
+	}
+	if exp, act := []string{"in/a.txt", "in/a.txt", "in/b.txt"}, keys; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong keys: %v != %v", act, exp)
+	}
+
+	if _, _, err = a.ReadWithContext(context.Background()); err != types.ErrTypeClosed {
+		t.Errorf("Expected closed error, got: %v", err)
+	}
+}
+
+func TestAzureBlobStorageMetadata(t *testing.T) {
+	server := httptest.NewServer(&azureTestServer{
+		blobs: map[string]string{"a.txt": "foo"},
+	})
+	defer server.Close()
+
+	a, err := NewAzureBlobStorage(azureTestConfig(server), log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer a.CloseAsync()
+
+	msg, _ := azureTestRead(t, a)
+	if exp, act := [][]byte{[]byte("foo")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong contents: %s != %s", act, exp)
+	}
+	exp := map[string]string{
+		"blob_storage_key":                "a.txt",
+		"blob_storage_container":          "foo",
+		"blob_storage_last_modified":      "2020-01-06T00:00:00Z",
+		"blob_storage_last_modified_unix": "1578268800",
+		"blob_storage_content_type":       "text/plain",
+		"owner":                           "bar",
+	}
+	act := map[string]string{}
+	msg.Get(0).Metadata().Iter(func(k, v string) error {
+		act[k] = v
+		return nil
+	})
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+}
+
+func TestAzureBlobStorageQueue(t *testing.T) {
+	events := "[" + azureTestEvent("foo", "a.txt") + "," + azureTestEvent("foo", "missing.txt") + "," + azureTestEvent("foo", "b.txt") + "]"
+	fs := &azureTestServer{
+		blobs: map[string]string{
+			"a.txt": "foo",
+			"b.txt": "bar",
+			"c.txt": "baz",
+		},
+		queue: []storage.QueueMessage{
+			{ID: "msg1", PopReceipt: "r1", Text: base64.StdEncoding.EncodeToString([]byte(events))},
+			{ID: "msg2", PopReceipt: "r2", Text: azureTestEvent("other", "c.txt")},
+			{ID: "msg3", PopReceipt: "r3", Text: azureTestEvent("foo", "c.txt")},
+		},
+	}
+	server := httptest.NewServer(fs)
+	defer server.Close()
+
+	conf := azureTestConfig(server)
+	conf.StorageQueue = "events"
+
+	a, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer a.CloseAsync()
+
+	var acks []AsyncAckFn
+	var contents []string
+	for i := 0; i < 3; i++ {
+		msg, ackFn := azureTestRead(t, a)
+		contents = append(contents, string(msg.Get(0).Get()))
+		acks = append(acks, ackFn)
+	}
+	if exp, act := []string{"foo", "bar", "baz"}, contents; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong contents: %v != %v", act, exp)
+	}
+
+	// Messages without events of the container are deleted immediately.
+	if exp, act := []string{"msg2"}, fs.deletedMessages(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong deleted messages: %v != %v", act, exp)
+	}
+
+	acks[0](context.Background(), response.NewAck())
+	acks[2](context.Background(), response.NewAck())
+	if exp, act := []string{"msg2", "msg3"}, fs.deletedMessages(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong deleted messages: %v != %v", act, exp)
+	}
+
+	acks[1](context.Background(), response.NewAck())
+	if exp, act := []string{"msg2", "msg3", "msg1"}, fs.deletedMessages(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong deleted messages: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,376 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Jeffail/benthos/v3/lib/util/parquet"
+)
+
+//------------------------------------------------------------------------------
+
+// CodecDocumentation is a markdown description of the codecs available to
+// inputs that decode files, objects or streams into messages.
+const CodecDocumentation = `### Codecs
+
+The ` + "`codec`" + ` determines how the contents are split into messages:
+
+- ` + "`all-bytes`" + `: The whole contents as a single message.
+- ` + "`lines`" + `: Each line as a message, skipping empty lines.
+- ` + "`gzip`" + `: The gzip decompressed contents as a single message.
+- ` + "`tar`" + `: Each file of a tar archive as a message.
+- ` + "`csv`" + `: Each record of a CSV document as a JSON object, keyed by the
+  columns of its header row.
+- ` + "`parquet`" + `: Each row of a Parquet file as a JSON object.
+- ` + "`json_array`" + `: Each element of a JSON array as a message, which is
+  decoded as a stream and so the array is never held in memory as a whole.
+- ` + "`chunked:<size>`" + `: Consecutive chunks of at most ` + "`<size>`" + `
+  bytes as messages, for example ` + "`chunked:1048576`" + `.
+- ` + "`auto`" + `: A codec chosen per file or object. Gzip compressed contents
+  are detected and decompressed, and the codec of the decompressed contents is
+  chosen from the content type when known and otherwise the file extension,
+  where ` + "`.tar`, `.csv`, `.parquet`" + ` and line based formats such as
+  ` + "`.jsonl`, `.ndjson`, `.txt` and `.log`" + ` are recognised. Anything
+  else is read with ` + "`all-bytes`" + `.
+
+Any codec can be prefixed with ` + "`gzip/`" + ` in order to decompress the
+contents before decoding them, for example ` + "`gzip/lines`" + ` or
+` + "`gzip/tar`" + `.`
+
+//------------------------------------------------------------------------------
+
+// partCodec splits the contents of a file or object into message parts.
+type partCodec interface {
+	// Next returns the next part, or io.EOF once all parts have been read.
+	Next() ([]byte, error)
+
+	// Close releases the underlying reader.
+	Close() error
+}
+
+// partCodecHint describes the contents being decoded, which the auto codec
+// uses in order to select a codec. Fields are empty when unknown.
+type partCodecHint struct {
+	path        string
+	contentType string
+}
+
+// partCodecCtor creates a partCodec that reads from r.
+type partCodecCtor func(hint partCodecHint, r io.ReadCloser) (partCodec, error)
+
+// getPartCodec returns the constructor of a named codec, which is one of:
+//
+// - all-bytes: The whole contents as a single part.
+// - lines: Each line as a part, where empty lines are skipped.
+// - gzip: The gzip decompressed contents as a single part.
+// - tar: Each regular file of a tar archive as a part.
+// - csv: Each record of a CSV document with a header row as a JSON object.
+// - parquet: Each row of a Parquet file as a JSON object.
+// - json_array: Each element of a JSON array.
+// - chunked:<size>: Consecutive chunks of at most size bytes.
+// - auto: A codec selected from the hint and contents.
+//
+// Any codec prefixed with gzip/ is applied to the decompressed contents.
+func getPartCodec(name string) (partCodecCtor, error) {
+	if strings.HasPrefix(name, "gzip/") {
+		inner, err := getPartCodec(strings.TrimPrefix(name, "gzip/"))
+		if err != nil {
+			return nil, err
+		}
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			gr, err := gzip.NewReader(r)
+			if err != nil {
+				r.Close()
+				return nil, fmt.Errorf("failed to read gzip header: %v", err)
+			}
+			return inner(hint, readCloser{Reader: gr, Closer: r})
+		}, nil
+	}
+	if strings.HasPrefix(name, "chunked:") {
+		size, err := strconv.Atoi(strings.TrimPrefix(name, "chunked:"))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid chunk size: %v", strings.TrimPrefix(name, "chunked:"))
+		}
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			return &chunkedCodec{r: r, size: size}, nil
+		}, nil
+	}
+	switch name {
+	case "all-bytes":
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			return &allBytesCodec{r: r}, nil
+		}, nil
+	case "lines":
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			return &linesCodec{r: r, buf: bufio.NewReader(r)}, nil
+		}, nil
+	case "gzip":
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			gr, err := gzip.NewReader(r)
+			if err != nil {
+				r.Close()
+				return nil, fmt.Errorf("failed to read gzip header: %v", err)
+			}
+			return &allBytesCodec{r: r, src: gr}, nil
+		}, nil
+	case "tar":
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			return &tarCodec{r: r, tr: tar.NewReader(r)}, nil
+		}, nil
+	case "csv":
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			return newCSVCodec(r, ',', false, true), nil
+		}, nil
+	case "parquet":
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			b, err := ioutil.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+			pr, err := parquet.NewReaderFromBytes(b)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read parquet footer: %v", err)
+			}
+			return &parquetCodec{r: pr}, nil
+		}, nil
+	case "json_array":
+		return func(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+			return &jsonArrayCodec{r: r, dec: json.NewDecoder(r)}, nil
+		}, nil
+	case "auto":
+		return autoPartCodec, nil
+	}
+	return nil, fmt.Errorf("unrecognised codec: %v", name)
+}
+
+//------------------------------------------------------------------------------
+
+// autoPartCodec decompresses gzip contents, detected by their magic bytes, and
+// decodes the contents with a codec chosen from the hint.
+func autoPartCodec(hint partCodecHint, r io.ReadCloser) (partCodec, error) {
+	buf := bufio.NewReader(r)
+	var src io.ReadCloser = readCloser{Reader: buf, Closer: r}
+
+	name := autoCodecName(hint)
+	if magic, _ := buf.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		if name == "all-bytes" {
+			name = "gzip"
+		} else {
+			name = "gzip/" + name
+		}
+	}
+
+	ctor, err := getPartCodec(name)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return ctor(hint, src)
+}
+
+// autoCodecName selects the codec of decompressed contents from the content
+// type of a hint, falling back to the extension of its path.
+func autoCodecName(hint partCodecHint) string {
+	contentType := strings.ToLower(hint.contentType)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	switch strings.TrimSpace(contentType) {
+	case "application/x-tar":
+		return "tar"
+	case "text/csv":
+		return "csv"
+	case "application/vnd.apache.parquet", "application/x-parquet":
+		return "parquet"
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines", "text/plain":
+		return "lines"
+	}
+
+	p := strings.ToLower(hint.path)
+	if strings.HasSuffix(p, ".tgz") {
+		return "tar"
+	}
+	switch path.Ext(strings.TrimSuffix(p, ".gz")) {
+	case ".tar":
+		return "tar"
+	case ".csv":
+		return "csv"
+	case ".parquet":
+		return "parquet"
+	case ".jsonl", ".ndjson", ".txt", ".log":
+		return "lines"
+	}
+	return "all-bytes"
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+//------------------------------------------------------------------------------
+
+type allBytesCodec struct {
+	r    io.ReadCloser
+	src  io.Reader
+	done bool
+}
+
+func (a *allBytesCodec) Next() ([]byte, error) {
+	if a.done {
+		return nil, io.EOF
+	}
+	a.done = true
+	src := a.src
+	if src == nil {
+		src = a.r
+	}
+	return ioutil.ReadAll(src)
+}
+
+func (a *allBytesCodec) Close() error {
+	return a.r.Close()
+}
+
+type linesCodec struct {
+	r   io.ReadCloser
+	buf *bufio.Reader
+}
+
+func (l *linesCodec) Next() ([]byte, error) {
+	for {
+		line, err := l.buf.ReadBytes('\n')
+		if line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r")); len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *linesCodec) Close() error {
+	return l.r.Close()
+}
+
+type tarCodec struct {
+	r  io.ReadCloser
+	tr *tar.Reader
+}
+
+func (t *tarCodec) Next() ([]byte, error) {
+	for {
+		header, err := t.tr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			return ioutil.ReadAll(t.tr)
+		}
+	}
+}
+
+func (t *tarCodec) Close() error {
+	return t.r.Close()
+}
+
+type parquetCodec struct {
+	r *parquet.Reader
+}
+
+func (p *parquetCodec) Next() ([]byte, error) {
+	row, err := p.r.Next()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(row)
+}
+
+func (p *parquetCodec) Close() error {
+	return nil
+}
+
+type jsonArrayCodec struct {
+	r       io.ReadCloser
+	dec     *json.Decoder
+	started bool
+}
+
+func (j *jsonArrayCodec) Next() ([]byte, error) {
+	if !j.started {
+		tok, err := j.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			return nil, errors.New("expected a JSON array")
+		}
+		j.started = true
+	}
+	if !j.dec.More() {
+		return nil, io.EOF
+	}
+	var element json.RawMessage
+	if err := j.dec.Decode(&element); err != nil {
+		return nil, err
+	}
+	return element, nil
+}
+
+func (j *jsonArrayCodec) Close() error {
+	return j.r.Close()
+}
+
+type chunkedCodec struct {
+	r    io.ReadCloser
+	size int
+}
+
+func (c *chunkedCodec) Next() ([]byte, error) {
+	chunk := make([]byte, c.size)
+	n, err := io.ReadFull(c.r, chunk)
+	if n > 0 {
+		return chunk[:n], nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return nil, err
+}
+
+func (c *chunkedCodec) Close() error {
+	return c.r.Close()
+}
+
+//------------------------------------------------------------------------------
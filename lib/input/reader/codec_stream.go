@@ -0,0 +1,226 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// CodecStream is a reader implementation that continuously reads messages from
+// an io.Reader type, where the contents are split into messages by a codec.
+type CodecStream struct {
+	codecCtor  partCodecCtor
+	hint       partCodecHint
+	handleCtor func(ctx context.Context) (io.Reader, error)
+	onClose    func(ctx context.Context)
+
+	mut        sync.Mutex
+	parts      partCodec
+	shutdownFn func()
+	errChan    chan error
+	msgChan    chan types.Message
+	readDone   chan struct{}
+
+	closeOnce  sync.Once
+	closedChan chan struct{}
+}
+
+// NewCodecStream creates a new reader input type able to create a feed of
+// messages from an io.Reader, where the contents are split into messages by a
+// named codec. The path, which may be empty, is used by codecs that select how
+// to decode contents from their file extension.
+//
+// Callers must provide a constructor function for the target io.Reader, which
+// is called on start up and again each time a reader is exhausted. If the
+// constructor is called but there is no more content to create a Reader for
+// then the error `io.EOF` should be returned and the CodecStream will close.
+//
+// Callers must also provide an onClose function, which will be called if the
+// CodecStream has been instructed to shut down. This function should unblock
+// any blocked Read calls.
+func NewCodecStream(
+	codec, path string,
+	handleCtor func() (io.Reader, error),
+	onClose func(),
+) (*CodecStream, error) {
+	ctor, err := getPartCodec(codec)
+	if err != nil {
+		return nil, err
+	}
+	return &CodecStream{
+		codecCtor: ctor,
+		hint:      partCodecHint{path: path},
+		handleCtor: func(ctx context.Context) (io.Reader, error) {
+			return handleCtor()
+		},
+		onClose: func(ctx context.Context) {
+			onClose()
+		},
+		shutdownFn: func() {},
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (r *CodecStream) closeParts() {
+	if r.parts != nil {
+		r.parts.Close()
+		r.parts = nil
+	}
+	r.shutdownFn()
+}
+
+// Connect attempts to establish a new codec for an io.Reader.
+func (r *CodecStream) Connect() error {
+	return r.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext attempts to establish a new codec for an io.Reader.
+func (r *CodecStream) ConnectWithContext(ctx context.Context) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.closeParts()
+
+	handle, err := r.handleCtor(ctx)
+	if err != nil {
+		if err == io.EOF {
+			return types.ErrTypeClosed
+		}
+		return err
+	}
+
+	rc, ok := handle.(io.ReadCloser)
+	if !ok {
+		rc = readCloser{Reader: handle, Closer: noopCloser{}}
+	}
+	parts, err := r.codecCtor(r.hint, rc)
+	if err != nil {
+		return err
+	}
+
+	partsCtx, shutdownFn := context.WithCancel(context.Background())
+	msgChan := make(chan types.Message)
+	errChan := make(chan error)
+	readDone := make(chan struct{})
+
+	go func() {
+		defer func() {
+			shutdownFn()
+			close(errChan)
+			close(msgChan)
+			close(readDone)
+		}()
+
+		for {
+			data, err := parts.Next()
+			if err != nil {
+				if err != io.EOF {
+					select {
+					case errChan <- err:
+					case <-partsCtx.Done():
+					}
+				}
+				return
+			}
+			select {
+			case msgChan <- message.New([][]byte{data}):
+			case <-partsCtx.Done():
+				return
+			}
+		}
+	}()
+
+	r.parts = parts
+	r.msgChan = msgChan
+	r.errChan = errChan
+	r.shutdownFn = shutdownFn
+	r.readDone = readDone
+	return nil
+}
+
+// ReadWithContext attempts to read a new message from the io.Reader.
+func (r *CodecStream) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	r.mut.Lock()
+	msgChan := r.msgChan
+	errChan := r.errChan
+	r.mut.Unlock()
+
+	select {
+	case msg, open := <-msgChan:
+		if !open {
+			return nil, nil, types.ErrNotConnected
+		}
+		return msg, noopAsyncAckFn, nil
+	case err, open := <-errChan:
+		if !open {
+			return nil, nil, types.ErrNotConnected
+		}
+		return nil, nil, err
+	case <-ctx.Done():
+	}
+	return nil, nil, types.ErrTimeout
+}
+
+// CloseAsync shuts down the reader input and stops processing requests.
+func (r *CodecStream) CloseAsync() {
+	r.closeOnce.Do(func() {
+		go func() {
+			r.mut.Lock()
+			r.onClose(context.Background())
+			r.closeParts()
+			readDone := r.readDone
+			r.mut.Unlock()
+
+			if readDone != nil {
+				<-readDone
+			}
+			close(r.closedChan)
+		}()
+	})
+}
+
+// WaitForClose blocks until the reader input has closed down.
+func (r *CodecStream) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-r.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+type noopCloser struct{}
+
+func (noopCloser) Close() error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestCodecStreamWaitForClose(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ctored := false
+	r, err := NewCodecStream("lines", "", func() (io.Reader, error) {
+		if ctored {
+			return nil, io.EOF
+		}
+		ctored = true
+		return pr, nil
+	}, func() {
+		pr.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = r.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.CloseAsync()
+	if err = r.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCodecStreamWaitForCloseTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	release := make(chan struct{})
+	ctored := false
+	r, err := NewCodecStream("lines", "", func() (io.Reader, error) {
+		if ctored {
+			return nil, io.EOF
+		}
+		ctored = true
+		return pr, nil
+	}, func() {
+		<-release
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = r.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.CloseAsync()
+	if err = r.WaitForClose(time.Millisecond * 50); err != types.ErrTimeout {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrTimeout)
+	}
+
+	close(release)
+	pr.Close()
+	if err = r.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func testCodecParts(t *testing.T, codec string, data []byte) []string {
+	t.Helper()
+	return testCodecPartsWithHint(t, codec, partCodecHint{}, data)
+}
+
+func testCodecPartsWithHint(t *testing.T, codec string, hint partCodecHint, data []byte) []string {
+	t.Helper()
+	ctor, err := getPartCodec(codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ctor(hint, ioutil.NopCloser(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var parts []string
+	for {
+		p, err := c.Next()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, string(p))
+	}
+}
+
+func TestPartCodecs(t *testing.T) {
+	var gzipBuf bytes.Buffer
+	zw := gzip.NewWriter(&gzipBuf)
+	zw.Write([]byte("foo\nbar"))
+	zw.Close()
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	tw.WriteHeader(&tar.Header{Name: "dir", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, f := range []string{"foo", "bar"} {
+		tw.WriteHeader(&tar.Header{Name: "dir/" + f, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f))})
+		tw.Write([]byte(f))
+	}
+	tw.Close()
+
+	parquetFile, err := base64.StdEncoding.DecodeString(testParquetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		codec string
+		input []byte
+		exp   []string
+	}{
+		{codec: "all-bytes", input: []byte("foo\nbar"), exp: []string{"foo\nbar"}},
+		{codec: "all-bytes", input: []byte{}, exp: []string{""}},
+		{codec: "lines", input: []byte("foo\r\n\nbar\nbaz"), exp: []string{"foo", "bar", "baz"}},
+		{codec: "lines", input: []byte("foo\n"), exp: []string{"foo"}},
+		{codec: "lines", input: []byte{}, exp: nil},
+		{codec: "gzip", input: gzipBuf.Bytes(), exp: []string{"foo\nbar"}},
+		{codec: "tar", input: tarBuf.Bytes(), exp: []string{"foo", "bar"}},
+		{codec: "parquet", input: parquetFile, exp: []string{`{"id":1,"name":"foo"}`, `{"id":2,"name":null}`}},
+		{codec: "csv", input: []byte("a,b\nfoo,1\nbar,2.5\n"), exp: []string{`{"a":"foo","b":1}`, `{"a":"bar","b":2.5}`}},
+		{codec: "gzip/lines", input: gzipBuf.Bytes(), exp: []string{"foo", "bar"}},
+		{codec: "json_array", input: []byte(` [{"a":1}, "foo", [2] ] `), exp: []string{`{"a":1}`, `"foo"`, `[2]`}},
+		{codec: "json_array", input: []byte(`[]`), exp: nil},
+		{codec: "chunked:3", input: []byte("foobarba"), exp: []string{"foo", "bar", "ba"}},
+		{codec: "chunked:3", input: []byte{}, exp: nil},
+	}
+	for _, test := range tests {
+		if act := testCodecParts(t, test.codec, test.input); !reflect.DeepEqual(test.exp, act) {
+			t.Errorf("Wrong parts for codec %v: %q != %q", test.codec, act, test.exp)
+		}
+	}
+}
+
+func TestPartCodecErrors(t *testing.T) {
+	if _, err := getPartCodec("nope"); err == nil {
+		t.Error("Expected error from unrecognised codec")
+	}
+	ctor, err := getPartCodec("gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ctor(partCodecHint{}, ioutil.NopCloser(bytes.NewReader([]byte("not gzip")))); err == nil {
+		t.Error("Expected error from bad gzip data")
+	}
+	for _, name := range []string{"gzip/nope", "chunked:", "chunked:0", "chunked:nope"} {
+		if _, err := getPartCodec(name); err == nil {
+			t.Errorf("Expected error from codec: %v", name)
+		}
+	}
+
+	if ctor, err = getPartCodec("json_array"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ctor(partCodecHint{}, ioutil.NopCloser(bytes.NewReader([]byte(`{"foo":"bar"}`))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Next(); err == nil {
+		t.Error("Expected error from JSON object")
+	}
+}
+
+func TestPartCodecAuto(t *testing.T) {
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for _, f := range []string{"foo", "bar"} {
+		tw.WriteHeader(&tar.Header{Name: f, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f))})
+		tw.Write([]byte(f))
+	}
+	tw.Close()
+
+	gzipped := func(b []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(b)
+		zw.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		hint  partCodecHint
+		input []byte
+		exp   []string
+	}{
+		{hint: partCodecHint{path: "foo.json"}, input: []byte("foo\nbar"), exp: []string{"foo\nbar"}},
+		{hint: partCodecHint{path: "foo.jsonl"}, input: []byte("foo\nbar"), exp: []string{"foo", "bar"}},
+		{hint: partCodecHint{path: "foo.jsonl.gz"}, input: gzipped([]byte("foo\nbar")), exp: []string{"foo", "bar"}},
+		{hint: partCodecHint{path: "foo.gz"}, input: gzipped([]byte("foo\nbar")), exp: []string{"foo\nbar"}},
+		{hint: partCodecHint{path: "foo.tar"}, input: tarBuf.Bytes(), exp: []string{"foo", "bar"}},
+		{hint: partCodecHint{path: "foo.tgz"}, input: gzipped(tarBuf.Bytes()), exp: []string{"foo", "bar"}},
+		{hint: partCodecHint{path: "foo.csv"}, input: []byte("a\nfoo\n"), exp: []string{`{"a":"foo"}`}},
+		{hint: partCodecHint{path: "foo", contentType: "text/csv; charset=utf-8"}, input: []byte("a\nfoo\n"), exp: []string{`{"a":"foo"}`}},
+		{hint: partCodecHint{path: "foo.bin", contentType: "application/x-ndjson"}, input: gzipped([]byte("foo\nbar")), exp: []string{"foo", "bar"}},
+		{hint: partCodecHint{}, input: []byte{0x1f}, exp: []string{"\x1f"}},
+	}
+	for i, test := range tests {
+		if act := testCodecPartsWithHint(t, "auto", test.hint, test.input); !reflect.DeepEqual(test.exp, act) {
+			t.Errorf("Wrong parts for test %v: %q != %q", i, act, test.exp)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,303 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// CSVConfig contains configuration fields for the CSV input type.
+type CSVConfig struct {
+	Paths          []string `json:"paths" yaml:"paths"`
+	ParseHeaderRow bool     `json:"parse_header_row" yaml:"parse_header_row"`
+	Delimiter      string   `json:"delimiter" yaml:"delimiter"`
+	LazyQuotes     bool     `json:"lazy_quotes" yaml:"lazy_quotes"`
+}
+
+// NewCSVConfig creates a new CSVConfig with default values.
+func NewCSVConfig() CSVConfig {
+	return CSVConfig{
+		Paths:          []string{},
+		ParseHeaderRow: true,
+		Delimiter:      ",",
+		LazyQuotes:     false,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// csvCodec reads records from a CSV document and encodes each as a JSON
+// document. When a header row is parsed each record becomes an object keyed by
+// the column names, otherwise each record becomes an array.
+type csvCodec struct {
+	r           io.ReadCloser
+	cr          *csv.Reader
+	parseHeader bool
+	headers     []string
+}
+
+func newCSVCodec(r io.ReadCloser, delim rune, lazyQuotes, parseHeader bool) *csvCodec {
+	cr := csv.NewReader(r)
+	cr.Comma = delim
+	cr.LazyQuotes = lazyQuotes
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return &csvCodec{
+		r:           r,
+		cr:          cr,
+		parseHeader: parseHeader,
+	}
+}
+
+var errCSVFieldCount = errors.New("number of fields does not match the header row")
+
+// csvValue converts a CSV field into a number or boolean where possible.
+func csvValue(field string) interface{} {
+	if i, err := strconv.ParseInt(field, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(field, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(field); err == nil {
+		return b
+	}
+	return field
+}
+
+// NextRecord returns the next record as a structured document, or io.EOF once
+// all records have been read.
+func (c *csvCodec) NextRecord() (interface{}, error) {
+	if c.parseHeader && c.headers == nil {
+		headers, err := c.cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		c.headers = make([]string, len(headers))
+		copy(c.headers, headers)
+	}
+
+	record, err := c.cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.parseHeader {
+		arr := make([]interface{}, len(record))
+		for i, field := range record {
+			arr[i] = csvValue(field)
+		}
+		return arr, nil
+	}
+
+	if len(record) != len(c.headers) {
+		return nil, errCSVFieldCount
+	}
+	obj := make(map[string]interface{}, len(record))
+	for i, field := range record {
+		obj[c.headers[i]] = csvValue(field)
+	}
+	return obj, nil
+}
+
+func (c *csvCodec) Next() ([]byte, error) {
+	record, err := c.NextRecord()
+	if err != nil {
+		return nil, err
+	}
+	part := message.NewPart(nil)
+	if err = part.SetJSON(record); err != nil {
+		return nil, err
+	}
+	return part.Get(), nil
+}
+
+func (c *csvCodec) Close() error {
+	return c.r.Close()
+}
+
+//------------------------------------------------------------------------------
+
+// CSV is an input type that reads records from CSV files as JSON documents.
+type CSV struct {
+	conf  CSVConfig
+	delim rune
+
+	mut     sync.Mutex
+	targets []string
+	path    string
+	codec   *csvCodec
+	index   int64
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewCSV creates a new CSV input type.
+func NewCSV(conf CSVConfig, log log.Modular, stats metrics.Type) (*CSV, error) {
+	if len(conf.Paths) == 0 {
+		return nil, errors.New("at least one path must be specified")
+	}
+	delim, size := utf8.DecodeRuneInString(conf.Delimiter)
+	if size == 0 || size != len(conf.Delimiter) || delim == utf8.RuneError {
+		return nil, fmt.Errorf("delimiter must be a single character, found: %v", conf.Delimiter)
+	}
+
+	c := &CSV{
+		conf:  conf,
+		delim: delim,
+		log:   log,
+		stats: stats,
+	}
+	for _, pattern := range conf.Paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse path pattern '%v': %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files found matching path '%v'", pattern)
+		}
+		c.targets = append(c.targets, matches...)
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a connection.
+func (c *CSV) Connect() error {
+	return c.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext opens the next target file if one is not already open.
+func (c *CSV) ConnectWithContext(ctx context.Context) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.codec != nil {
+		return nil
+	}
+	if len(c.targets) == 0 {
+		return types.ErrTypeClosed
+	}
+
+	path := c.targets[0]
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file '%v': %v", path, err)
+	}
+	c.targets = c.targets[1:]
+	c.path = path
+	c.index = 0
+	c.codec = newCSVCodec(file, c.delim, c.conf.LazyQuotes, c.conf.ParseHeaderRow)
+	c.log.Infof("Reading CSV file '%v'\n", path)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// ReadWithContext attempts to read a new record from the current CSV file.
+func (c *CSV) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	msg, err := c.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, noopAsyncAckFn, nil
+}
+
+// Read attempts to read a new record from the current CSV file.
+func (c *CSV) Read() (types.Message, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.codec == nil {
+		return nil, types.ErrNotConnected
+	}
+
+	record, err := c.codec.NextRecord()
+	if err != nil {
+		if _, isParseErr := err.(*csv.ParseError); isParseErr || err == errCSVFieldCount {
+			// Malformed records are skipped.
+			c.index++
+			return nil, fmt.Errorf("failed to parse record of file '%v': %v", c.path, err)
+		}
+		c.codec.Close()
+		c.codec = nil
+		if err == io.EOF {
+			return nil, types.ErrNotConnected
+		}
+		return nil, fmt.Errorf("failed to read file '%v': %v", c.path, err)
+	}
+
+	part := message.NewPart(nil)
+	if err = part.SetJSON(record); err != nil {
+		return nil, err
+	}
+	part.Metadata().
+		Set("path", c.path).
+		Set("csv_record_index", strconv.FormatInt(c.index, 10))
+	c.index++
+
+	msg := message.New(nil)
+	msg.Append(part)
+	return msg, nil
+}
+
+// Acknowledge instructs whether unacknowledged messages have been successfully
+// propagated.
+func (c *CSV) Acknowledge(err error) error {
+	return nil
+}
+
+// CloseAsync shuts down the CSV input and stops processing requests.
+func (c *CSV) CloseAsync() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.codec != nil {
+		c.codec.Close()
+		c.codec = nil
+	}
+}
+
+// WaitForClose blocks until the CSV input has closed down.
+func (c *CSV) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func readAllCSV(t *testing.T, c *CSV) (records, paths []string) {
+	t.Helper()
+	for {
+		if err := c.Connect(); err == types.ErrTypeClosed {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := c.Read()
+		if err == types.ErrNotConnected {
+			continue
+		}
+		if err != nil {
+			records = append(records, "error")
+			continue
+		}
+		records = append(records, string(msg.Get(0).Get()))
+		paths = append(paths, filepath.Base(msg.Get(0).Metadata().Get("path"))+":"+msg.Get(0).Metadata().Get("csv_record_index"))
+	}
+}
+
+func TestCSVHeaderRow(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_csv_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		"a.csv": "name,age,active\nfoo,21,true\n\"bar, baz\",3.5,no\nbad\nqux,,false\n",
+		"b.csv": "name,age,active\nquz,10,false\n",
+		"c.txt": "ignored",
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := NewCSVConfig()
+	conf.Paths = []string{filepath.Join(tmpDir, "*.csv")}
+
+	c, err := NewCSV(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseAsync()
+
+	records, paths := readAllCSV(t, c)
+	expRecords := []string{
+		`{"active":true,"age":21,"name":"foo"}`,
+		`{"active":"no","age":3.5,"name":"bar, baz"}`,
+		"error",
+		`{"active":false,"age":"","name":"qux"}`,
+		`{"active":false,"age":10,"name":"quz"}`,
+	}
+	if !reflect.DeepEqual(expRecords, records) {
+		t.Errorf("Wrong records: %s != %s", records, expRecords)
+	}
+	expPaths := []string{"a.csv:0", "a.csv:1", "a.csv:3", "b.csv:0"}
+	if !reflect.DeepEqual(expPaths, paths) {
+		t.Errorf("Wrong paths: %s != %s", paths, expPaths)
+	}
+}
+
+func TestCSVNoHeaderRow(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "benthos_csv_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err = tmpFile.Write([]byte("foo|1\nbar|\"2|3\"|4\n")); err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+
+	conf := NewCSVConfig()
+	conf.Paths = []string{tmpFile.Name()}
+	conf.ParseHeaderRow = false
+	conf.Delimiter = "|"
+
+	c, err := NewCSV(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseAsync()
+
+	records, _ := readAllCSV(t, c)
+	exp := []string{`["foo",1]`, `["bar","2|3",4]`}
+	if !reflect.DeepEqual(exp, records) {
+		t.Errorf("Wrong records: %s != %s", records, exp)
+	}
+}
+
+func TestCSVBadConfig(t *testing.T) {
+	conf := NewCSVConfig()
+	if _, err := NewCSV(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing paths")
+	}
+
+	conf.Paths = []string{"/does/not/exist/*.csv"}
+	if _, err := NewCSV(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from unmatched path")
+	}
+
+	conf.Paths = []string{os.TempDir()}
+	conf.Delimiter = ";;"
+	if _, err := NewCSV(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad delimiter")
+	}
+}
+
+//------------------------------------------------------------------------------
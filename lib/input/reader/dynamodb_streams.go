@@ -0,0 +1,731 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	sess "github.com/Jeffail/benthos/v3/lib/util/aws/session"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+)
+
+//------------------------------------------------------------------------------
+
+// DynamoDBStreamsConfig contains configuration for the DynamoDBStreams input
+// type.
+type DynamoDBStreamsConfig struct {
+	sess.Config     `json:",inline" yaml:",inline"`
+	Table           string             `json:"table" yaml:"table"`
+	StreamARN       string             `json:"stream_arn" yaml:"stream_arn"`
+	CheckpointTable string             `json:"checkpoint_table" yaml:"checkpoint_table"`
+	ClientID        string             `json:"client_id" yaml:"client_id"`
+	CommitPeriod    string             `json:"commit_period" yaml:"commit_period"`
+	StartFromOldest bool               `json:"start_from_oldest" yaml:"start_from_oldest"`
+	Limit           int64              `json:"limit" yaml:"limit"`
+	PollPeriod      string             `json:"poll_period" yaml:"poll_period"`
+	Timeout         string             `json:"timeout" yaml:"timeout"`
+	Batching        batch.PolicyConfig `json:"batching" yaml:"batching"`
+}
+
+// NewDynamoDBStreamsConfig creates a new DynamoDBStreamsConfig with default
+// values.
+func NewDynamoDBStreamsConfig() DynamoDBStreamsConfig {
+	return DynamoDBStreamsConfig{
+		Config:          sess.NewConfig(),
+		Table:           "",
+		StreamARN:       "",
+		CheckpointTable: "",
+		ClientID:        "benthos_consumer",
+		CommitPeriod:    "1s",
+		StartFromOldest: true,
+		Limit:           100,
+		PollPeriod:      "1s",
+		Timeout:         "5s",
+		Batching:        batch.NewPolicyConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// dynamoDBStreamsShardEnd is the sequence checkpointed for shards that have
+// been consumed in their entirety, which allows their children to be consumed.
+const dynamoDBStreamsShardEnd = "SHARD_END"
+
+// dynamoDBStreamsRefreshPeriod is the period at which the shards of a stream
+// are described in order to find shards that are ready to be consumed.
+var dynamoDBStreamsRefreshPeriod = time.Minute
+
+type dynamoDBStreamsPending struct {
+	sequence string
+	acked    bool
+}
+
+type dynamoDBStreamsShard struct {
+	id string
+
+	pending   []*dynamoDBStreamsPending
+	acked     string
+	committed string
+	ended     bool
+	finished  bool
+}
+
+type dynamoDBStreamsBatch struct {
+	shard   *dynamoDBStreamsShard
+	pending *dynamoDBStreamsPending
+	msg     types.Message
+}
+
+// DynamoDBStreams is a benthos reader.Async implementation that consumes all
+// shards of a DynamoDB stream.
+type DynamoDBStreams struct {
+	conf DynamoDBStreamsConfig
+
+	namespace    string
+	commitPeriod time.Duration
+	pollPeriod   time.Duration
+	timeout      time.Duration
+
+	dynamo    dynamodbiface.DynamoDBAPI
+	streams   dynamodbstreamsiface.DynamoDBStreamsAPI
+	streamARN string
+
+	cMut      sync.Mutex
+	connected bool
+
+	mut       sync.Mutex
+	shards    map[string]*dynamoDBStreamsShard
+	batchChan chan dynamoDBStreamsBatch
+	shardDone chan struct{}
+
+	ctx        context.Context
+	done       func()
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+	closedChan chan struct{}
+
+	log   log.Modular
+	stats metrics.Type
+
+	mShardsActive metrics.StatGauge
+	mShardsEnded  metrics.StatCounter
+	mReadErr      metrics.StatCounter
+	mCommitErr    metrics.StatCounter
+}
+
+// NewDynamoDBStreams creates a new DynamoDB Streams reader.Async.
+func NewDynamoDBStreams(
+	conf DynamoDBStreamsConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*DynamoDBStreams, error) {
+	if len(conf.Table) == 0 && len(conf.StreamARN) == 0 {
+		return nil, errors.New("either a table or a stream_arn must be specified")
+	}
+	if len(conf.Table) > 0 && len(conf.StreamARN) > 0 {
+		return nil, errors.New("cannot specify both a table and a stream_arn")
+	}
+	if conf.Limit < 1 || conf.Limit > 1000 {
+		return nil, fmt.Errorf("limit must be between 1 and 1000, got %v", conf.Limit)
+	}
+	var commitPeriod, pollPeriod, timeout time.Duration
+	var err error
+	if tout := conf.CommitPeriod; len(tout) > 0 {
+		if commitPeriod, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse commit period string: %v", err)
+		}
+	}
+	if tout := conf.PollPeriod; len(tout) > 0 {
+		if pollPeriod, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse poll period string: %v", err)
+		}
+	}
+	if tout := conf.Timeout; len(tout) > 0 {
+		if timeout, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout string: %v", err)
+		}
+	}
+	ctx, done := context.WithCancel(context.Background())
+	return &DynamoDBStreams{
+		conf:         conf,
+		namespace:    fmt.Sprintf("%v-%v%v", conf.ClientID, conf.Table, conf.StreamARN),
+		commitPeriod: commitPeriod,
+		pollPeriod:   pollPeriod,
+		timeout:      timeout,
+		shards:       map[string]*dynamoDBStreamsShard{},
+		batchChan:    make(chan dynamoDBStreamsBatch),
+		shardDone:    make(chan struct{}, 1),
+		ctx:          ctx,
+		done:         done,
+		closedChan:   make(chan struct{}),
+		log:          log,
+		stats:        stats,
+
+		mShardsActive: stats.GetGauge("shards.active"),
+		mShardsEnded:  stats.GetCounter("shards.ended"),
+		mReadErr:      stats.GetCounter("read.error"),
+		mCommitErr:    stats.GetCounter("commit.error"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext resolves the stream to consume and begins consuming its
+// shards.
+func (d *DynamoDBStreams) ConnectWithContext(ctx context.Context) error {
+	d.cMut.Lock()
+	defer d.cMut.Unlock()
+
+	if d.connected {
+		return nil
+	}
+	if d.ctx.Err() != nil {
+		return types.ErrTypeClosed
+	}
+
+	if d.streams == nil {
+		sess, err := d.conf.GetSession()
+		if err != nil {
+			return err
+		}
+		d.streams = dynamodbstreams.New(sess)
+		d.dynamo = dynamodb.New(sess)
+	}
+
+	d.streamARN = d.conf.StreamARN
+	if len(d.streamARN) == 0 {
+		res, err := d.dynamo.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(d.conf.Table),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe table: %v", err)
+		}
+		if res.Table.LatestStreamArn == nil {
+			return fmt.Errorf("table %v does not have a stream enabled", d.conf.Table)
+		}
+		if view := aws.StringValue(res.Table.StreamSpecification.StreamViewType); view != dynamodb.StreamViewTypeNewAndOldImages {
+			d.log.Warnf("Stream of table %v has view type %v, records will not contain both new and old images\n", d.conf.Table, view)
+		}
+		d.streamARN = *res.Table.LatestStreamArn
+	}
+
+	d.wg.Add(1)
+	go d.loop()
+
+	d.connected = true
+	d.log.Infof("Receiving DynamoDB Streams records from stream: %v\n", d.streamARN)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func (d *DynamoDBStreams) getCheckpoint(shardID string) (string, error) {
+	if len(d.conf.CheckpointTable) == 0 {
+		return "", nil
+	}
+	ctx, done := context.WithTimeout(d.ctx, d.timeout)
+	defer done()
+	resp, err := d.dynamo.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.conf.CheckpointTable),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"namespace": {
+				S: aws.String(d.namespace),
+			},
+			"shard_id": {
+				S: aws.String(shardID),
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if seqAttr := resp.Item["sequence"]; seqAttr != nil && seqAttr.S != nil {
+		return *seqAttr.S, nil
+	}
+	return "", nil
+}
+
+func (d *DynamoDBStreams) setCheckpoint(shardID, sequence string) error {
+	if len(d.conf.CheckpointTable) == 0 {
+		return nil
+	}
+	ctx, done := context.WithTimeout(context.Background(), d.timeout)
+	defer done()
+	_, err := d.dynamo.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.conf.CheckpointTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"namespace": {
+				S: aws.String(d.namespace),
+			},
+			"shard_id": {
+				S: aws.String(shardID),
+			},
+			"sequence": {
+				S: aws.String(sequence),
+			},
+		},
+	})
+	return err
+}
+
+// commit checkpoints the latest acknowledged sequence of each shard that has
+// progressed since the last commit.
+func (d *DynamoDBStreams) commit() {
+	d.mut.Lock()
+	toCommit := map[string]string{}
+	for id, s := range d.shards {
+		if !s.finished && s.acked != s.committed {
+			toCommit[id] = s.acked
+		}
+	}
+	d.mut.Unlock()
+
+	for id, seq := range toCommit {
+		if err := d.setCheckpoint(id, seq); err != nil {
+			d.mCommitErr.Incr(1)
+			d.log.Errorf("Failed to checkpoint shard %v: %v\n", id, err)
+			continue
+		}
+		d.mut.Lock()
+		if s := d.shards[id]; !s.finished {
+			s.committed = seq
+		}
+		d.mut.Unlock()
+	}
+}
+
+// finishShard checkpoints a shard as having been fully consumed, which allows
+// its children to be consumed.
+func (d *DynamoDBStreams) finishShard(s *dynamoDBStreamsShard) {
+	if err := d.setCheckpoint(s.id, dynamoDBStreamsShardEnd); err != nil {
+		d.mCommitErr.Incr(1)
+		d.log.Errorf("Failed to checkpoint end of shard %v: %v\n", s.id, err)
+	}
+	d.mut.Lock()
+	s.finished = true
+	s.committed = dynamoDBStreamsShardEnd
+	d.mut.Unlock()
+
+	d.mShardsActive.Decr(1)
+	d.mShardsEnded.Incr(1)
+	d.log.Debugf("Finished consuming shard %v\n", s.id)
+
+	select {
+	case d.shardDone <- struct{}{}:
+	default:
+	}
+}
+
+// ack marks a batch of records as acknowledged, and moves the acknowledged
+// sequence of the shard forward to the last batch that has no unacknowledged
+// predecessors.
+func (d *DynamoDBStreams) ack(shard *dynamoDBStreamsShard, pending *dynamoDBStreamsPending) {
+	d.mut.Lock()
+	pending.acked = true
+	for len(shard.pending) > 0 && shard.pending[0].acked {
+		shard.acked = shard.pending[0].sequence
+		shard.pending = shard.pending[1:]
+	}
+	finished := shard.ended && !shard.finished && len(shard.pending) == 0
+	d.mut.Unlock()
+
+	if finished {
+		d.finishShard(shard)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// refreshShards describes the shards of the stream and begins consuming any
+// shard that isn't already being consumed, has not been consumed entirely,
+// and has no parent that is still being consumed.
+func (d *DynamoDBStreams) refreshShards() error {
+	var shards []*dynamodbstreams.Shard
+	input := &dynamodbstreams.DescribeStreamInput{
+		StreamArn: aws.String(d.streamARN),
+	}
+	for {
+		ctx, done := context.WithTimeout(d.ctx, d.timeout)
+		res, err := d.streams.DescribeStreamWithContext(ctx, input)
+		done()
+		if err != nil {
+			return err
+		}
+		shards = append(shards, res.StreamDescription.Shards...)
+		if res.StreamDescription.LastEvaluatedShardId == nil {
+			break
+		}
+		input.ExclusiveStartShardId = res.StreamDescription.LastEvaluatedShardId
+	}
+
+	listed := map[string]struct{}{}
+	for _, s := range shards {
+		listed[aws.StringValue(s.ShardId)] = struct{}{}
+	}
+
+	checkpoints := map[string]string{}
+	for _, s := range shards {
+		id := aws.StringValue(s.ShardId)
+		d.mut.Lock()
+		_, exists := d.shards[id]
+		d.mut.Unlock()
+		if exists {
+			continue
+		}
+		seq, err := d.getCheckpoint(id)
+		if err != nil {
+			return fmt.Errorf("failed to obtain checkpoint of shard %v: %v", id, err)
+		}
+		if seq == dynamoDBStreamsShardEnd {
+			d.mut.Lock()
+			d.shards[id] = &dynamoDBStreamsShard{id: id, ended: true, finished: true}
+			d.mut.Unlock()
+			continue
+		}
+		checkpoints[id] = seq
+	}
+
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	for _, s := range shards {
+		id := aws.StringValue(s.ShardId)
+		seq, pending := checkpoints[id]
+		if !pending {
+			continue
+		}
+		if parent := s.ParentShardId; parent != nil {
+			if _, exists := listed[*parent]; exists {
+				if p := d.shards[*parent]; p == nil || !p.finished {
+					continue
+				}
+			}
+		}
+
+		shard := &dynamoDBStreamsShard{
+			id:        id,
+			acked:     seq,
+			committed: seq,
+		}
+		d.shards[id] = shard
+		d.mShardsActive.Incr(1)
+
+		d.wg.Add(1)
+		go d.consumeShard(shard, seq)
+	}
+	return nil
+}
+
+func (d *DynamoDBStreams) loop() {
+	defer d.wg.Done()
+
+	refreshTicker := time.NewTicker(dynamoDBStreamsRefreshPeriod)
+	defer refreshTicker.Stop()
+
+	var commitChan <-chan time.Time
+	if len(d.conf.CheckpointTable) > 0 && d.commitPeriod > 0 {
+		commitTicker := time.NewTicker(d.commitPeriod)
+		defer commitTicker.Stop()
+		commitChan = commitTicker.C
+	}
+
+	refresh := func() {
+		if err := d.refreshShards(); err != nil && d.ctx.Err() == nil {
+			d.log.Errorf("Failed to refresh shards of stream %v: %v\n", d.streamARN, err)
+		}
+	}
+
+	refresh()
+	for {
+		select {
+		case <-refreshTicker.C:
+			refresh()
+		case <-d.shardDone:
+			refresh()
+		case <-commitChan:
+			d.commit()
+		case <-d.ctx.Done():
+			d.commit()
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (d *DynamoDBStreams) getIterator(shardID, sequence string) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn: aws.String(d.streamARN),
+		ShardId:   aws.String(shardID),
+	}
+	if len(sequence) > 0 {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = aws.String(sequence)
+	} else if d.conf.StartFromOldest {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon)
+	} else {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeLatest)
+	}
+	ctx, done := context.WithTimeout(d.ctx, d.timeout)
+	defer done()
+	res, err := d.streams.GetShardIteratorWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return res.ShardIterator, nil
+}
+
+// consumeShard polls the records of a shard and forwards them until either the
+// end of the shard is reached or the reader is closed.
+func (d *DynamoDBStreams) consumeShard(shard *dynamoDBStreamsShard, sequence string) {
+	defer d.wg.Done()
+
+	wait := func() bool {
+		select {
+		case <-time.After(d.pollPeriod):
+			return true
+		case <-d.ctx.Done():
+			return false
+		}
+	}
+
+	var iter *string
+	for d.ctx.Err() == nil {
+		if iter == nil {
+			var err error
+			if iter, err = d.getIterator(shard.id, sequence); err != nil {
+				if d.ctx.Err() != nil {
+					return
+				}
+				d.mReadErr.Incr(1)
+				if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodbstreams.ErrCodeTrimmedDataAccessException {
+					d.log.Warnf("Records of shard %v have been trimmed, resuming from oldest record\n", shard.id)
+					sequence = ""
+				} else if ok && aerr.Code() == dynamodbstreams.ErrCodeResourceNotFoundException {
+					// The shard has expired beyond the retention period of
+					// the stream.
+					iter = nil
+					break
+				} else {
+					d.log.Errorf("Failed to obtain iterator for shard %v: %v\n", shard.id, err)
+				}
+				if !wait() {
+					return
+				}
+				continue
+			}
+			if iter == nil {
+				break
+			}
+		}
+
+		ctx, done := context.WithTimeout(d.ctx, d.timeout)
+		res, err := d.streams.GetRecordsWithContext(ctx, &dynamodbstreams.GetRecordsInput{
+			Limit:         aws.Int64(d.conf.Limit),
+			ShardIterator: iter,
+		})
+		done()
+		if err != nil {
+			if d.ctx.Err() != nil {
+				return
+			}
+			d.mReadErr.Incr(1)
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodbstreams.ErrCodeExpiredIteratorException {
+				d.log.Debugf("Iterator of shard %v expired, refreshing\n", shard.id)
+			} else {
+				d.log.Errorf("Failed to read records from shard %v: %v\n", shard.id, err)
+			}
+			iter = nil
+			if !wait() {
+				return
+			}
+			continue
+		}
+
+		if len(res.Records) > 0 {
+			msg, lastSequence := d.recordsToMessage(shard.id, res.Records)
+			pending := &dynamoDBStreamsPending{sequence: lastSequence}
+			d.mut.Lock()
+			shard.pending = append(shard.pending, pending)
+			d.mut.Unlock()
+
+			select {
+			case d.batchChan <- dynamoDBStreamsBatch{shard: shard, pending: pending, msg: msg}:
+			case <-d.ctx.Done():
+				return
+			}
+			sequence = lastSequence
+		}
+
+		if iter = res.NextShardIterator; iter == nil {
+			break
+		}
+		if len(res.Records) == 0 && !wait() {
+			return
+		}
+	}
+
+	if d.ctx.Err() != nil {
+		return
+	}
+
+	d.mut.Lock()
+	shard.ended = true
+	finished := len(shard.pending) == 0
+	d.mut.Unlock()
+	if finished {
+		d.finishShard(shard)
+	}
+}
+
+func streamImageToJSON(image map[string]*dynamodb.AttributeValue) (interface{}, error) {
+	if image == nil {
+		return nil, nil
+	}
+	var v map[string]interface{}
+	if err := dynamodbattribute.UnmarshalMap(image, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// recordsToMessage converts stream records into a message batch, with each
+// record a JSON document containing its keys and images. Returns the sequence
+// of the last record.
+func (d *DynamoDBStreams) recordsToMessage(shardID string, records []*dynamodbstreams.Record) (types.Message, string) {
+	msg := message.New(nil)
+	var lastSequence string
+	for _, rec := range records {
+		doc := map[string]interface{}{
+			"event_id":   aws.StringValue(rec.EventID),
+			"event_name": aws.StringValue(rec.EventName),
+		}
+
+		part := message.NewPart(nil)
+		meta := part.Metadata()
+		meta.Set("dynamodb_streams_shard", shardID)
+		meta.Set("dynamodb_streams_stream_arn", d.streamARN)
+		meta.Set("dynamodb_streams_event_id", aws.StringValue(rec.EventID))
+		meta.Set("dynamodb_streams_event_name", aws.StringValue(rec.EventName))
+
+		if sr := rec.Dynamodb; sr != nil {
+			if sr.SequenceNumber != nil {
+				lastSequence = *sr.SequenceNumber
+				doc["sequence_number"] = *sr.SequenceNumber
+				meta.Set("dynamodb_streams_sequence_number", *sr.SequenceNumber)
+			}
+			if sr.ApproximateCreationDateTime != nil {
+				doc["approximate_creation_date_time"] = sr.ApproximateCreationDateTime.Unix()
+			}
+			for k, image := range map[string]map[string]*dynamodb.AttributeValue{
+				"keys":      sr.Keys,
+				"new_image": sr.NewImage,
+				"old_image": sr.OldImage,
+			} {
+				v, err := streamImageToJSON(image)
+				if err != nil {
+					d.log.Errorf("Failed to convert %v of record %v: %v\n", k, aws.StringValue(rec.EventID), err)
+					continue
+				}
+				if v != nil {
+					doc[k] = v
+				}
+			}
+		}
+
+		if docBytes, err := json.Marshal(doc); err != nil {
+			d.log.Errorf("Failed to marshal record %v: %v\n", aws.StringValue(rec.EventID), err)
+		} else {
+			part.Set(docBytes)
+		}
+		msg.Append(part)
+	}
+	return msg, lastSequence
+}
+
+//------------------------------------------------------------------------------
+
+// ReadWithContext attempts to read a new message from the shards of the
+// stream.
+func (d *DynamoDBStreams) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	d.cMut.Lock()
+	connected := d.connected
+	d.cMut.Unlock()
+	if !connected {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	var b dynamoDBStreamsBatch
+	select {
+	case b = <-d.batchChan:
+	case <-ctx.Done():
+		return nil, nil, types.ErrTimeout
+	case <-d.ctx.Done():
+		return nil, nil, types.ErrTypeClosed
+	}
+
+	return b.msg, func(rctx context.Context, res types.Response) error {
+		if res.Error() == nil {
+			d.ack(b.shard, b.pending)
+		}
+		return nil
+	}, nil
+}
+
+// CloseAsync begins cleaning up resources used by this reader asynchronously.
+func (d *DynamoDBStreams) CloseAsync() {
+	d.closeOnce.Do(func() {
+		d.done()
+		go func() {
+			d.wg.Wait()
+			close(d.closedChan)
+		}()
+	})
+}
+
+// WaitForClose will block until either the reader is closed or a specified
+// timeout occurs.
+func (d *DynamoDBStreams) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-d.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
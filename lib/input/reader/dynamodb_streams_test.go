@@ -0,0 +1,349 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+)
+
+//------------------------------------------------------------------------------
+
+type mockDynamoDBStreamsAPI struct {
+	dynamodbstreamsiface.DynamoDBStreamsAPI
+
+	mut       sync.Mutex
+	shards    []*dynamodbstreams.Shard
+	records   map[string][]*dynamodbstreams.Record
+	closed    map[string]bool
+	iterators map[string]*dynamodbstreams.GetShardIteratorInput
+}
+
+func (m *mockDynamoDBStreamsAPI) DescribeStreamWithContext(aws.Context, *dynamodbstreams.DescribeStreamInput, ...request.Option) (*dynamodbstreams.DescribeStreamOutput, error) {
+	return &dynamodbstreams.DescribeStreamOutput{
+		StreamDescription: &dynamodbstreams.StreamDescription{
+			Shards: m.shards,
+		},
+	}, nil
+}
+
+func (m *mockDynamoDBStreamsAPI) GetShardIteratorWithContext(ctx aws.Context, input *dynamodbstreams.GetShardIteratorInput, opts ...request.Option) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	m.mut.Lock()
+	m.iterators[*input.ShardId] = input
+	m.mut.Unlock()
+	return &dynamodbstreams.GetShardIteratorOutput{
+		ShardIterator: input.ShardId,
+	}, nil
+}
+
+func (m *mockDynamoDBStreamsAPI) GetRecordsWithContext(ctx aws.Context, input *dynamodbstreams.GetRecordsInput, opts ...request.Option) (*dynamodbstreams.GetRecordsOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	shardID := *input.ShardIterator
+	out := &dynamodbstreams.GetRecordsOutput{
+		Records: m.records[shardID],
+	}
+	delete(m.records, shardID)
+	if !m.closed[shardID] || len(out.Records) > 0 {
+		out.NextShardIterator = aws.String(shardID)
+	}
+	return out, nil
+}
+
+func (m *mockDynamoDBStreamsAPI) iterator(shardID string) *dynamodbstreams.GetShardIteratorInput {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.iterators[shardID]
+}
+
+type mockDynamoDBStreamsTable struct {
+	dynamodbiface.DynamoDBAPI
+
+	mut   sync.Mutex
+	items map[string]string
+}
+
+func (m *mockDynamoDBStreamsTable) DescribeTableWithContext(ctx aws.Context, input *dynamodb.DescribeTableInput, opts ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{
+		Table: &dynamodb.TableDescription{
+			LatestStreamArn: aws.String("stream-arn-" + *input.TableName),
+			StreamSpecification: &dynamodb.StreamSpecification{
+				StreamEnabled:  aws.Bool(true),
+				StreamViewType: aws.String(dynamodb.StreamViewTypeNewAndOldImages),
+			},
+		},
+	}, nil
+}
+
+func (m *mockDynamoDBStreamsTable) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	out := &dynamodb.GetItemOutput{}
+	if seq, exists := m.items[*input.Key["shard_id"].S]; exists {
+		out.Item = map[string]*dynamodb.AttributeValue{
+			"sequence": {S: aws.String(seq)},
+		}
+	}
+	return out, nil
+}
+
+func (m *mockDynamoDBStreamsTable) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.mut.Lock()
+	m.items[*input.Item["shard_id"].S] = *input.Item["sequence"].S
+	m.mut.Unlock()
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockDynamoDBStreamsTable) checkpoint(shardID string) string {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.items[shardID]
+}
+
+func newMockStreamRecord(name, id, seq string, oldCount, newCount string) *dynamodbstreams.Record {
+	rec := &dynamodbstreams.Record{
+		EventID:   aws.String(seq),
+		EventName: aws.String(name),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			SequenceNumber: aws.String(seq),
+			Keys: map[string]*dynamodb.AttributeValue{
+				"id": {S: aws.String(id)},
+			},
+		},
+	}
+	if len(oldCount) > 0 {
+		rec.Dynamodb.OldImage = map[string]*dynamodb.AttributeValue{
+			"id":    {S: aws.String(id)},
+			"count": {N: aws.String(oldCount)},
+		}
+	}
+	if len(newCount) > 0 {
+		rec.Dynamodb.NewImage = map[string]*dynamodb.AttributeValue{
+			"id":    {S: aws.String(id)},
+			"count": {N: aws.String(newCount)},
+		}
+	}
+	return rec
+}
+
+//------------------------------------------------------------------------------
+
+func TestDynamoDBStreamsBadConfig(t *testing.T) {
+	tests := map[string]func(c *DynamoDBStreamsConfig){
+		"no stream": func(c *DynamoDBStreamsConfig) {},
+		"both table and arn": func(c *DynamoDBStreamsConfig) {
+			c.Table = "foo"
+			c.StreamARN = "bar"
+		},
+		"bad limit": func(c *DynamoDBStreamsConfig) {
+			c.Table = "foo"
+			c.Limit = 0
+		},
+		"bad poll period": func(c *DynamoDBStreamsConfig) {
+			c.Table = "foo"
+			c.PollPeriod = "nope"
+		},
+	}
+	for name, test := range tests {
+		conf := NewDynamoDBStreamsConfig()
+		test(&conf)
+		if _, err := NewDynamoDBStreams(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestDynamoDBStreamsShards(t *testing.T) {
+	streams := &mockDynamoDBStreamsAPI{
+		shards: []*dynamodbstreams.Shard{
+			{ShardId: aws.String("shard-0")},
+			{ShardId: aws.String("shard-1"), ParentShardId: aws.String("shard-0")},
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"shard-0": {
+				newMockStreamRecord("INSERT", "foo", "100000000000000000001", "", "1"),
+				newMockStreamRecord("MODIFY", "foo", "100000000000000000002", "1", "2"),
+			},
+			"shard-1": {
+				newMockStreamRecord("REMOVE", "foo", "100000000000000000003", "2", ""),
+			},
+		},
+		closed:    map[string]bool{"shard-0": true},
+		iterators: map[string]*dynamodbstreams.GetShardIteratorInput{},
+	}
+	table := &mockDynamoDBStreamsTable{items: map[string]string{}}
+
+	conf := NewDynamoDBStreamsConfig()
+	conf.Table = "foo"
+	conf.CheckpointTable = "checkpoints"
+	conf.CommitPeriod = "1h"
+	conf.PollPeriod = "1ms"
+
+	r, err := NewDynamoDBStreams(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.streams = streams
+	r.dynamo = table
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	if err = r.ConnectWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ackFn, err := r.ReadWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, msg.Len(); exp != act {
+		t.Fatalf("Wrong batch size: %v != %v", act, exp)
+	}
+	if exp, act := `{"event_id":"100000000000000000001","event_name":"INSERT","keys":{"id":"foo"},"new_image":{"count":1,"id":"foo"},"sequence_number":"100000000000000000001"}`, string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong record: %v != %v", act, exp)
+	}
+	if exp, act := `{"event_id":"100000000000000000002","event_name":"MODIFY","keys":{"id":"foo"},"new_image":{"count":2,"id":"foo"},"old_image":{"count":1,"id":"foo"},"sequence_number":"100000000000000000002"}`, string(msg.Get(1).Get()); exp != act {
+		t.Errorf("Wrong record: %v != %v", act, exp)
+	}
+	meta := msg.Get(1).Metadata()
+	for k, exp := range map[string]string{
+		"dynamodb_streams_shard":           "shard-0",
+		"dynamodb_streams_stream_arn":      "stream-arn-foo",
+		"dynamodb_streams_event_name":      "MODIFY",
+		"dynamodb_streams_sequence_number": "100000000000000000002",
+	} {
+		if act := meta.Get(k); exp != act {
+			t.Errorf("Wrong metadata %v: %v != %v", k, act, exp)
+		}
+	}
+
+	if streams.iterator("shard-1") != nil {
+		t.Error("Child shard consumed before its parent was finished")
+	}
+	if err = ackFn(ctx, response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg, ackFn, err = r.ReadWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"event_id":"100000000000000000003","event_name":"REMOVE","keys":{"id":"foo"},"old_image":{"count":2,"id":"foo"},"sequence_number":"100000000000000000003"}`, string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong record: %v != %v", act, exp)
+	}
+	if exp, act := dynamoDBStreamsShardEnd, table.checkpoint("shard-0"); exp != act {
+		t.Errorf("Wrong checkpoint: %v != %v", act, exp)
+	}
+	if err = ackFn(ctx, response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+
+	r.CloseAsync()
+	if err = r.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "100000000000000000003", table.checkpoint("shard-1"); exp != act {
+		t.Errorf("Wrong checkpoint: %v != %v", act, exp)
+	}
+	if exp, act := dynamodbstreams.ShardIteratorTypeTrimHorizon, *streams.iterator("shard-1").ShardIteratorType; exp != act {
+		t.Errorf("Wrong iterator type: %v != %v", act, exp)
+	}
+}
+
+func TestDynamoDBStreamsCheckpoints(t *testing.T) {
+	streams := &mockDynamoDBStreamsAPI{
+		shards: []*dynamodbstreams.Shard{
+			{ShardId: aws.String("shard-0")},
+			{ShardId: aws.String("shard-1"), ParentShardId: aws.String("shard-0")},
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"shard-1": {
+				newMockStreamRecord("INSERT", "bar", "100000000000000000005", "", "1"),
+			},
+		},
+		closed:    map[string]bool{},
+		iterators: map[string]*dynamodbstreams.GetShardIteratorInput{},
+	}
+	table := &mockDynamoDBStreamsTable{items: map[string]string{
+		"shard-0": dynamoDBStreamsShardEnd,
+		"shard-1": "100000000000000000004",
+	}}
+
+	conf := NewDynamoDBStreamsConfig()
+	conf.StreamARN = "stream-arn"
+	conf.CheckpointTable = "checkpoints"
+	conf.PollPeriod = "1ms"
+
+	r, err := NewDynamoDBStreams(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.streams = streams
+	r.dynamo = table
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	if err = r.ConnectWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, _, err := r.ReadWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "100000000000000000005", msg.Get(0).Metadata().Get("dynamodb_streams_sequence_number"); exp != act {
+		t.Errorf("Wrong record: %v != %v", act, exp)
+	}
+
+	r.CloseAsync()
+	if err = r.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if streams.iterator("shard-0") != nil {
+		t.Error("Finished shard was consumed")
+	}
+	iter := streams.iterator("shard-1")
+	if exp, act := dynamodbstreams.ShardIteratorTypeAfterSequenceNumber, *iter.ShardIteratorType; exp != act {
+		t.Errorf("Wrong iterator type: %v != %v", act, exp)
+	}
+	if exp, act := "100000000000000000004", *iter.SequenceNumber; exp != act {
+		t.Errorf("Wrong iterator sequence: %v != %v", act, exp)
+	}
+	if exp, act := "100000000000000000004", table.checkpoint("shard-1"); exp != act {
+		t.Errorf("Unacknowledged records were checkpointed: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,487 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// FileTailConfig contains configuration fields for the FileTail input type.
+type FileTailConfig struct {
+	Path               string
+	Multipart          bool
+	MaxBuffer          int
+	Delim              string
+	PollPeriod         string
+	Cache              string
+	StartFromBeginning bool
+}
+
+//------------------------------------------------------------------------------
+
+// fileTailCheckpointer tracks the offsets of messages read from a file and
+// commits the highest offset for which all prior messages have been
+// acknowledged.
+type fileTailCheckpointer struct {
+	mut     sync.Mutex
+	pending []*fileTailPending
+	stale   bool
+}
+
+type fileTailPending struct {
+	offset int64
+	acked  bool
+}
+
+type fileTailTarget struct {
+	path   string
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+	buf    []byte
+	parts  [][]byte
+	eof    bool
+
+	checkpoints *fileTailCheckpointer
+}
+
+// FileTail is an input type that follows a set of files matching a glob
+// pattern, reading data as it is appended to them.
+type FileTail struct {
+	conf       FileTailConfig
+	delim      []byte
+	pollPeriod time.Duration
+	cache      types.Cache
+
+	mut     sync.Mutex
+	initial bool
+	targets map[string]*fileTailTarget
+	order   []string
+	next    int
+
+	log   log.Modular
+	stats metrics.Type
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+}
+
+// NewFileTail creates a new FileTail input type.
+func NewFileTail(
+	conf FileTailConfig, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (*FileTail, error) {
+	if len(conf.Path) == 0 {
+		return nil, errors.New("a path must be specified")
+	}
+	if _, err := filepath.Match(conf.Path, ""); err != nil {
+		return nil, fmt.Errorf("failed to parse path pattern: %v", err)
+	}
+	if conf.MaxBuffer <= 0 {
+		return nil, errors.New("max_buffer must be greater than zero")
+	}
+
+	f := &FileTail{
+		conf:      conf,
+		delim:     []byte("\n"),
+		initial:   true,
+		targets:   map[string]*fileTailTarget{},
+		log:       log,
+		stats:     stats,
+		closeChan: make(chan struct{}),
+	}
+	if len(conf.Delim) > 0 {
+		f.delim = []byte(conf.Delim)
+	}
+
+	var err error
+	if f.pollPeriod, err = time.ParseDuration(conf.PollPeriod); err != nil {
+		return nil, fmt.Errorf("failed to parse poll period: %v", err)
+	}
+	if len(conf.Cache) > 0 {
+		if f.cache, err = mgr.GetCache(conf.Cache); err != nil {
+			return nil, fmt.Errorf("failed to obtain cache '%v': %v", conf.Cache, err)
+		}
+	}
+	return f, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a connection to the target files.
+func (f *FileTail) Connect() error {
+	return f.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext scans for files matching the target path.
+func (f *FileTail) ConnectWithContext(ctx context.Context) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.refresh()
+}
+
+// refresh checks watched files for rotation, truncation and removal and opens
+// any new files matching the path pattern. Must be called with the mutex held.
+func (f *FileTail) refresh() error {
+	for _, path := range f.order {
+		t := f.targets[path]
+		if !t.eof {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				f.log.Debugf("File '%v' was removed\n", path)
+				f.closeTarget(t)
+			}
+			continue
+		}
+		if !os.SameFile(info, t.info) {
+			f.log.Debugf("File '%v' was rotated\n", path)
+			f.closeTarget(t)
+			continue
+		}
+		if info.Size() < t.offset {
+			f.log.Debugf("File '%v' was truncated\n", path)
+			if err = f.reset(t); err != nil {
+				f.log.Errorf("Failed to reset truncated file '%v': %v\n", path, err)
+				f.closeTarget(t)
+			}
+		}
+	}
+
+	matches, err := filepath.Glob(f.conf.Path)
+	if err != nil {
+		return err
+	}
+	sort.Strings(matches)
+
+	for _, path := range matches {
+		if _, exists := f.targets[path]; exists {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			f.log.Errorf("Failed to open file '%v': %v\n", path, err)
+			continue
+		}
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			file.Close()
+			continue
+		}
+
+		t := &fileTailTarget{
+			path:        path,
+			file:        file,
+			info:        info,
+			checkpoints: &fileTailCheckpointer{},
+		}
+		if t.offset, err = f.startOffset(path, info, f.initial); err != nil {
+			f.log.Errorf("Failed to obtain offset of file '%v': %v\n", path, err)
+			file.Close()
+			continue
+		}
+		if t.offset > 0 {
+			if _, err = file.Seek(t.offset, io.SeekStart); err != nil {
+				f.log.Errorf("Failed to seek file '%v': %v\n", path, err)
+				file.Close()
+				continue
+			}
+		}
+
+		f.log.Infof("Tailing file '%v' from offset %v\n", path, t.offset)
+		f.targets[path] = t
+		f.order = append(f.order, path)
+	}
+
+	f.initial = false
+	return nil
+}
+
+// startOffset returns the offset to begin reading a newly discovered file
+// from, which is the offset stored within the cache if present.
+func (f *FileTail) startOffset(path string, info os.FileInfo, initial bool) (int64, error) {
+	if f.cache != nil {
+		offsetBytes, err := f.cache.Get(path)
+		if err == nil {
+			offset, err := strconv.ParseInt(string(offsetBytes), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse cached offset: %v", err)
+			}
+			if offset > info.Size() {
+				// The file has been replaced or truncated since the offset
+				// was stored.
+				return 0, nil
+			}
+			return offset, nil
+		}
+		if err != types.ErrKeyNotFound {
+			return 0, err
+		}
+	}
+	if initial && !f.conf.StartFromBeginning {
+		return info.Size(), nil
+	}
+	return 0, nil
+}
+
+// reset rewinds a target to the beginning of its file, abandoning any pending
+// checkpoints. Must be called with the mutex held.
+func (f *FileTail) reset(t *fileTailTarget) error {
+	t.checkpoints.abandon()
+	t.checkpoints = &fileTailCheckpointer{}
+	t.offset = 0
+	t.buf = nil
+	t.parts = nil
+	t.eof = false
+	if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return f.commit(t.path, 0)
+}
+
+// closeTarget stops following a file. When the file was rotated or removed its
+// stored offset is reset so that a replacement file at the same path is read
+// from the beginning. Must be called with the mutex held.
+func (f *FileTail) closeTarget(t *fileTailTarget) {
+	t.checkpoints.abandon()
+	t.file.Close()
+	if err := f.commit(t.path, 0); err != nil {
+		f.log.Errorf("Failed to reset offset of file '%v': %v\n", t.path, err)
+	}
+	delete(f.targets, t.path)
+	for i, p := range f.order {
+		if p == t.path {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (f *FileTail) commit(path string, offset int64) error {
+	if f.cache == nil {
+		return nil
+	}
+	return f.cache.Set(path, []byte(strconv.FormatInt(offset, 10)))
+}
+
+//------------------------------------------------------------------------------
+
+func (c *fileTailCheckpointer) track(offset int64) *fileTailPending {
+	c.mut.Lock()
+	p := &fileTailPending{offset: offset}
+	c.pending = append(c.pending, p)
+	c.mut.Unlock()
+	return p
+}
+
+// ack marks a pending offset as acknowledged and returns the highest offset
+// that can be committed, or -1 if none can be.
+func (c *fileTailCheckpointer) ack(p *fileTailPending) int64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	p.acked = true
+	offset := int64(-1)
+	for len(c.pending) > 0 && c.pending[0].acked {
+		offset = c.pending[0].offset
+		c.pending = c.pending[1:]
+	}
+	if c.stale {
+		return -1
+	}
+	return offset
+}
+
+func (c *fileTailCheckpointer) abandon() {
+	c.mut.Lock()
+	c.stale = true
+	c.mut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// nextLine attempts to extract the next delimited line from a target, reading
+// more data from the file when required. Returns false when no complete line
+// is currently available.
+func (f *FileTail) nextLine(t *fileTailTarget) ([]byte, bool, error) {
+	chunk := make([]byte, 32*1024)
+	for {
+		if i := bytes.Index(t.buf, f.delim); i >= 0 {
+			line := t.buf[:i]
+			t.buf = t.buf[i+len(f.delim):]
+			t.offset += int64(i + len(f.delim))
+			return line, true, nil
+		}
+		if len(t.buf) >= f.conf.MaxBuffer {
+			f.log.Warnf("Line from file '%v' exceeded max_buffer and was split\n", t.path)
+			line := t.buf
+			t.buf = nil
+			t.offset += int64(len(line))
+			return line, true, nil
+		}
+
+		n, err := t.file.Read(chunk)
+		if n > 0 {
+			t.eof = false
+			t.buf = append(t.buf, chunk[:n]...)
+			continue
+		}
+		if err == io.EOF || err == nil {
+			t.eof = true
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+}
+
+// nextMessage attempts to read the next message from a target.
+func (f *FileTail) nextMessage(t *fileTailTarget) (types.Message, bool, error) {
+	for {
+		line, ok, err := f.nextLine(t)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		if len(line) == 0 {
+			if f.conf.Multipart && len(t.parts) > 0 {
+				break
+			}
+			continue
+		}
+		lineCopy := make([]byte, len(line))
+		copy(lineCopy, line)
+		t.parts = append(t.parts, lineCopy)
+		if !f.conf.Multipart {
+			break
+		}
+	}
+
+	msg := message.New(t.parts)
+	t.parts = nil
+	msg.Iter(func(i int, p types.Part) error {
+		p.Metadata().Set("path", t.path)
+		return nil
+	})
+	return msg, true, nil
+}
+
+// Read attempts to read a new message from the target files.
+func (f *FileTail) Read() (types.Message, error) {
+	msg, _, err := f.ReadWithContext(context.Background())
+	return msg, err
+}
+
+// ReadWithContext attempts to read a new message from the target files,
+// polling for new data until the context is cancelled.
+func (f *FileTail) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	for {
+		f.mut.Lock()
+		select {
+		case <-f.closeChan:
+			f.mut.Unlock()
+			return nil, nil, types.ErrTypeClosed
+		default:
+		}
+
+		for i := 0; i < len(f.order); i++ {
+			t := f.targets[f.order[(f.next+i)%len(f.order)]]
+			msg, ok, err := f.nextMessage(t)
+			if err != nil {
+				f.log.Errorf("Failed to read file '%v': %v\n", t.path, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			f.next = (f.next + i + 1) % len(f.order)
+
+			path, checkpoints := t.path, t.checkpoints
+			pending := checkpoints.track(t.offset)
+			f.mut.Unlock()
+
+			return msg, func(rctx context.Context, res types.Response) error {
+				if res.Error() != nil {
+					return nil
+				}
+				if offset := checkpoints.ack(pending); offset >= 0 {
+					return f.commit(path, offset)
+				}
+				return nil
+			}, nil
+		}
+
+		err := f.refresh()
+		f.mut.Unlock()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		select {
+		case <-time.After(f.pollPeriod):
+		case <-ctx.Done():
+			return nil, nil, types.ErrTimeout
+		case <-f.closeChan:
+			return nil, nil, types.ErrTypeClosed
+		}
+	}
+}
+
+// Acknowledge is a noop as offsets are committed via the async ack function.
+func (f *FileTail) Acknowledge(err error) error {
+	return nil
+}
+
+// CloseAsync shuts down the FileTail input and stops processing requests.
+func (f *FileTail) CloseAsync() {
+	f.closeOnce.Do(func() {
+		close(f.closeChan)
+		f.mut.Lock()
+		for _, t := range f.targets {
+			t.file.Close()
+		}
+		f.targets = map[string]*fileTailTarget{}
+		f.order = nil
+		f.mut.Unlock()
+	})
+}
+
+// WaitForClose blocks until the FileTail input has closed down.
+func (f *FileTail) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
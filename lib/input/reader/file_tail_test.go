@@ -0,0 +1,238 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+)
+
+func newTestFileTail(t *testing.T, path string, mgr *testCacheMgr) *FileTail {
+	t.Helper()
+
+	conf := FileTailConfig{
+		Path:               path,
+		MaxBuffer:          1000000,
+		PollPeriod:         "10ms",
+		StartFromBeginning: true,
+	}
+	if mgr != nil {
+		conf.Cache = "foocache"
+	} else {
+		mgr = &testCacheMgr{}
+	}
+
+	f, err := NewFileTail(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func readFileTail(t *testing.T, f *FileTail, exp ...string) {
+	t.Helper()
+
+	for _, e := range exp {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		msg, ackFn, err := f.ReadWithContext(ctx)
+		done()
+		if err != nil {
+			t.Fatalf("Failed to read '%v': %v", e, err)
+		}
+		if act := string(msg.Get(0).Get()); act != e {
+			t.Errorf("Wrong message: %v != %v", act, e)
+		}
+		if err = ackFn(context.Background(), response.NewAck()); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func appendToFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileTailGrowth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "foo.log")
+	appendToFile(t, path, "first\nsecond\n")
+
+	f := newTestFileTail(t, path, nil)
+	defer f.CloseAsync()
+
+	readFileTail(t, f, "first", "second")
+
+	ctx, done := context.WithTimeout(context.Background(), time.Millisecond*50)
+	_, _, err = f.ReadWithContext(ctx)
+	done()
+	if err == nil {
+		t.Error("Expected timeout")
+	}
+
+	appendToFile(t, path, "thi")
+	go func() {
+		<-time.After(time.Millisecond * 50)
+		appendToFile(t, path, "rd\n")
+	}()
+	readFileTail(t, f, "third")
+}
+
+func TestFileTailRotationAndTruncation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "foo.log")
+	appendToFile(t, path, "first\n")
+
+	f := newTestFileTail(t, path, nil)
+	defer f.CloseAsync()
+
+	readFileTail(t, f, "first")
+
+	appendToFile(t, path, "second\n")
+	if err = os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendToFile(t, path, "third\n")
+	readFileTail(t, f, "second", "third")
+
+	if err = ioutil.WriteFile(path, []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	readFileTail(t, f, "x")
+}
+
+func TestFileTailGlobWithCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pathA := filepath.Join(dir, "a.log")
+	pathB := filepath.Join(dir, "b.log")
+	appendToFile(t, pathA, "a1\na2\n")
+	appendToFile(t, filepath.Join(dir, "ignored.txt"), "nope\n")
+
+	cache := &testCache{values: map[string][]byte{}}
+	mgr := &testCacheMgr{cache: cache}
+
+	f := newTestFileTail(t, filepath.Join(dir, "*.log"), mgr)
+	readFileTail(t, f, "a1", "a2")
+
+	appendToFile(t, pathB, "b1\n")
+	readFileTail(t, f, "b1")
+
+	// Unacknowledged messages must not be committed.
+	appendToFile(t, pathA, "a3\n")
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	msg, _, err := f.ReadWithContext(ctx)
+	done()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := [][]byte{[]byte("a3")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message: %s != %s", act, exp)
+	}
+	if exp, act := "a.log", filepath.Base(msg.Get(0).Metadata().Get("path")); exp != act {
+		t.Errorf("Wrong path metadata: %v != %v", act, exp)
+	}
+	f.CloseAsync()
+
+	exp := map[string][]byte{
+		pathA: []byte("6"),
+		pathB: []byte("3"),
+	}
+	if !reflect.DeepEqual(exp, cache.values) {
+		t.Errorf("Wrong cached offsets: %s != %s", cache.values, exp)
+	}
+
+	f = newTestFileTail(t, filepath.Join(dir, "*.log"), mgr)
+	defer f.CloseAsync()
+	readFileTail(t, f, "a3")
+}
+
+func TestFileTailStartFromEnd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_tail_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "foo.log")
+	appendToFile(t, path, "old\n")
+
+	f, err := NewFileTail(FileTailConfig{
+		Path:       path,
+		MaxBuffer:  1000000,
+		Delim:      "|",
+		Multipart:  true,
+		PollPeriod: "10ms",
+	}, &testCacheMgr{}, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer f.CloseAsync()
+
+	appendToFile(t, path, "foo|bar||baz||")
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	msg, _, err := f.ReadWithContext(ctx)
+	done()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := [][]byte{[]byte("foo"), []byte("bar")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message: %s != %s", act, exp)
+	}
+}
@@ -0,0 +1,417 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/storage"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+//------------------------------------------------------------------------------
+
+// GCPCloudStorageConfig contains configuration fields for the GCPCloudStorage
+// input type.
+type GCPCloudStorageConfig struct {
+	Bucket             string `json:"bucket" yaml:"bucket"`
+	Prefix             string `json:"prefix" yaml:"prefix"`
+	Codec              string `json:"codec" yaml:"codec"`
+	DeleteObjects      bool   `json:"delete_objects" yaml:"delete_objects"`
+	PubSubProject      string `json:"pubsub_project" yaml:"pubsub_project"`
+	PubSubSubscription string `json:"pubsub_subscription" yaml:"pubsub_subscription"`
+}
+
+// NewGCPCloudStorageConfig creates a new GCPCloudStorageConfig with default
+// values.
+func NewGCPCloudStorageConfig() GCPCloudStorageConfig {
+	return GCPCloudStorageConfig{
+		Bucket:             "",
+		Prefix:             "",
+		Codec:              "all-bytes",
+		DeleteObjects:      false,
+		PubSubProject:      "",
+		PubSubSubscription: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// gcsTarget is an object to be downloaded, which optionally originates from a
+// Pub/Sub notification that is acknowledged once the object is processed.
+type gcsTarget struct {
+	name         string
+	notification *pubsub.Message
+}
+
+// gcsObject is an object that is currently being read.
+type gcsObject struct {
+	target   gcsTarget
+	attrs    storage.ReaderObjectAttrs
+	parts    partCodec
+	next     []byte
+	nextErr  error
+	pending  int
+	finished bool
+}
+
+// advance reads ahead to the next part of the object, so that the object is
+// known to be finished as soon as its last part has been read.
+func (o *gcsObject) advance() {
+	o.next, o.nextErr = o.parts.Next()
+}
+
+// GCPCloudStorage is an input type that downloads objects from a Google Cloud
+// Storage bucket.
+type GCPCloudStorage struct {
+	conf      GCPCloudStorageConfig
+	codecCtor partCodecCtor
+
+	// Options of the clients, set when testing.
+	storageOpts []option.ClientOption
+	pubsubOpts  []option.ClientOption
+
+	mut          sync.Mutex
+	client       *storage.Client
+	bucket       *storage.BucketHandle
+	pubsubClient *pubsub.Client
+	closeSub     context.CancelFunc
+	notifyChan   chan *pubsub.Message
+	targets      []gcsTarget
+	listed       bool
+	current      *gcsObject
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewGCPCloudStorage creates a new GCPCloudStorage input type.
+func NewGCPCloudStorage(conf GCPCloudStorageConfig, log log.Modular, stats metrics.Type) (*GCPCloudStorage, error) {
+	g := &GCPCloudStorage{
+		conf:  conf,
+		log:   log,
+		stats: stats,
+	}
+	if len(conf.Bucket) == 0 {
+		return nil, errors.New("a bucket must be specified")
+	}
+	if len(conf.PubSubSubscription) > 0 && len(conf.PubSubProject) == 0 {
+		return nil, errors.New("a pubsub project must be specified with the subscription")
+	}
+	var err error
+	if g.codecCtor, err = getPartCodec(conf.Codec); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext creates the storage client and either lists the objects
+// of the bucket or subscribes to object notifications.
+func (g *GCPCloudStorage) ConnectWithContext(ctx context.Context) error {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	if g.client == nil {
+		client, err := storage.NewClient(context.Background(), g.storageOpts...)
+		if err != nil {
+			return err
+		}
+		g.client = client
+		g.bucket = client.Bucket(g.conf.Bucket)
+	}
+
+	if len(g.conf.PubSubSubscription) > 0 {
+		return g.subscribe()
+	}
+	if g.listed {
+		return nil
+	}
+
+	var targets []gcsTarget
+	it := g.bucket.Objects(ctx, &storage.Query{Prefix: g.conf.Prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %v", err)
+		}
+		targets = append(targets, gcsTarget{name: attrs.Name})
+	}
+	g.targets = targets
+	g.listed = true
+
+	g.log.Infof("Downloading %v objects from GCP Cloud Storage bucket: %v\n", len(targets), g.conf.Bucket)
+	return nil
+}
+
+// subscribe begins receiving object notifications from the Pub/Sub
+// subscription. The mutex must be held when calling.
+func (g *GCPCloudStorage) subscribe() error {
+	if g.closeSub != nil {
+		return nil
+	}
+	if g.pubsubClient == nil {
+		client, err := pubsub.NewClient(context.Background(), g.conf.PubSubProject, g.pubsubOpts...)
+		if err != nil {
+			return err
+		}
+		g.pubsubClient = client
+	}
+
+	sub := g.pubsubClient.Subscription(g.conf.PubSubSubscription)
+	subCtx, cancel := context.WithCancel(context.Background())
+	notifyChan := make(chan *pubsub.Message)
+
+	g.closeSub = cancel
+	g.notifyChan = notifyChan
+
+	go func() {
+		rerr := sub.Receive(subCtx, func(ctx context.Context, m *pubsub.Message) {
+			select {
+			case notifyChan <- m:
+			case <-ctx.Done():
+				m.Nack()
+			}
+		})
+		if rerr != nil && rerr != context.Canceled {
+			g.log.Errorf("Subscription error: %v\n", rerr)
+		}
+		g.mut.Lock()
+		if g.notifyChan == notifyChan {
+			g.closeSub = nil
+			g.notifyChan = nil
+		}
+		g.mut.Unlock()
+		cancel()
+	}()
+
+	g.log.Infof("Receiving GCP Cloud Storage notifications from subscription: %v\n", g.conf.PubSubSubscription)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// addNotification queues the object of a notification if it was created within
+// the bucket and prefix, otherwise the notification is acknowledged.
+func (g *GCPCloudStorage) addNotification(m *pubsub.Message) {
+	if m.Attributes["eventType"] != "OBJECT_FINALIZE" ||
+		m.Attributes["bucketId"] != g.conf.Bucket ||
+		!strings.HasPrefix(m.Attributes["objectId"], g.conf.Prefix) {
+		m.Ack()
+		return
+	}
+	g.mut.Lock()
+	g.targets = append(g.targets, gcsTarget{
+		name:         m.Attributes["objectId"],
+		notification: m,
+	})
+	g.mut.Unlock()
+}
+
+// nextObject begins reading the next target object. The mutex must be held
+// when calling.
+func (g *GCPCloudStorage) nextObject(ctx context.Context) error {
+	for len(g.targets) > 0 {
+		target := g.targets[0]
+		g.targets = g.targets[1:]
+
+		r, err := g.bucket.Object(target.name).NewReader(ctx)
+		if err != nil {
+			if err == storage.ErrObjectNotExist {
+				g.log.Warnf("Object '%v' no longer exists\n", target.name)
+				g.objectDone(target, false)
+				continue
+			}
+			g.targets = append([]gcsTarget{target}, g.targets...)
+			return fmt.Errorf("failed to download object '%v': %v", target.name, err)
+		}
+		parts, err := g.codecCtor(partCodecHint{path: target.name, contentType: r.Attrs.ContentType}, r)
+		if err != nil {
+			g.log.Errorf("Failed to decode object '%v': %v\n", target.name, err)
+			g.objectDone(target, false)
+			continue
+		}
+		g.current = &gcsObject{
+			target: target,
+			attrs:  r.Attrs,
+			parts:  parts,
+		}
+		g.current.advance()
+		return nil
+	}
+	return nil
+}
+
+// finishObject closes the current object once all of its parts have been read.
+// The mutex must be held when calling.
+func (g *GCPCloudStorage) finishObject(obj *gcsObject) {
+	if obj.nextErr != io.EOF {
+		g.log.Errorf("Failed to read object '%v': %v\n", obj.target.name, obj.nextErr)
+	}
+	obj.parts.Close()
+	obj.finished = true
+	g.current = nil
+}
+
+// objectDone is called once all messages of an object have been acknowledged,
+// deleting the object if configured to and acknowledging its notification.
+func (g *GCPCloudStorage) objectDone(target gcsTarget, exists bool) {
+	if exists && g.conf.DeleteObjects {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*30)
+		err := g.bucket.Object(target.name).Delete(ctx)
+		done()
+		if err != nil && err != storage.ErrObjectNotExist {
+			g.log.Errorf("Failed to delete object '%v': %v\n", target.name, err)
+		}
+	}
+	if target.notification != nil {
+		target.notification.Ack()
+	}
+}
+
+// readNext reads the next part from the objects. A nil part is returned when
+// there are no objects to read.
+func (g *GCPCloudStorage) readNext(ctx context.Context) (types.Part, *gcsObject, error) {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	if g.client == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+	for {
+		if g.current == nil {
+			if len(g.targets) == 0 {
+				if len(g.conf.PubSubSubscription) == 0 {
+					return nil, nil, types.ErrTypeClosed
+				}
+				if g.notifyChan == nil {
+					return nil, nil, types.ErrNotConnected
+				}
+				return nil, nil, nil
+			}
+			if err := g.nextObject(ctx); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		obj := g.current
+		if obj.nextErr != nil {
+			g.finishObject(obj)
+			g.objectDone(obj.target, true)
+			continue
+		}
+		data := obj.next
+		obj.pending++
+		if obj.advance(); obj.nextErr != nil {
+			g.finishObject(obj)
+		}
+
+		part := message.NewPart(data)
+		meta := part.Metadata()
+		meta.Set("gcs_key", obj.target.name)
+		meta.Set("gcs_bucket", g.conf.Bucket)
+		meta.Set("gcs_last_modified", obj.attrs.LastModified.Format(time.RFC3339))
+		meta.Set("gcs_last_modified_unix", strconv.FormatInt(obj.attrs.LastModified.Unix(), 10))
+		if len(obj.attrs.ContentType) > 0 {
+			meta.Set("gcs_content_type", obj.attrs.ContentType)
+		}
+		if len(obj.attrs.ContentEncoding) > 0 {
+			meta.Set("gcs_content_encoding", obj.attrs.ContentEncoding)
+		}
+		return part, obj, nil
+	}
+}
+
+// ReadWithContext reads the next part of the objects as a message.
+func (g *GCPCloudStorage) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	for {
+		part, obj, err := g.readNext(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if part != nil {
+			msg := message.New(nil)
+			msg.Append(part)
+			return msg, func(rctx context.Context, res types.Response) error {
+				g.mut.Lock()
+				defer g.mut.Unlock()
+				if obj.pending--; obj.pending == 0 && obj.finished {
+					g.objectDone(obj.target, true)
+				}
+				return nil
+			}, nil
+		}
+
+		g.mut.Lock()
+		notifyChan := g.notifyChan
+		g.mut.Unlock()
+
+		select {
+		case m := <-notifyChan:
+			g.addNotification(m)
+		case <-time.After(time.Second):
+			return nil, nil, types.ErrTimeout
+		case <-ctx.Done():
+			return nil, nil, types.ErrTimeout
+		}
+	}
+}
+
+// CloseAsync shuts down the GCPCloudStorage input and stops processing
+// requests.
+func (g *GCPCloudStorage) CloseAsync() {
+	g.mut.Lock()
+	if g.closeSub != nil {
+		g.closeSub()
+		g.closeSub = nil
+		g.notifyChan = nil
+	}
+	if g.current != nil {
+		g.current.parts.Close()
+		g.current = nil
+	}
+	g.mut.Unlock()
+}
+
+// WaitForClose blocks until the GCPCloudStorage input has closed down.
+func (g *GCPCloudStorage) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
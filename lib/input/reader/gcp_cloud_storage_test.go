@@ -0,0 +1,331 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+//------------------------------------------------------------------------------
+
+// gcsTestServer serves the objects of a single bucket through both the JSON
+// API and the download host used by the storage emulator.
+type gcsTestServer struct {
+	mut     sync.Mutex
+	objects map[string]string
+	deleted []string
+}
+
+func (s *gcsTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	switch {
+	case r.URL.Path == "/storage/v1/b/bkt/o" && r.Method == http.MethodGet:
+		var names []string
+		for k := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		var items []map[string]string
+		for _, n := range names {
+			items = append(items, map[string]string{"name": n, "bucket": "bkt"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	case strings.HasPrefix(r.URL.Path, "/storage/v1/b/bkt/o/") && r.Method == http.MethodDelete:
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bkt/o/")
+		delete(s.objects, name)
+		s.deleted = append(s.deleted, name)
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(r.URL.Path, "/bkt/"):
+		data, exists := s.objects[strings.TrimPrefix(r.URL.Path, "/bkt/")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", "Mon, 06 Jan 2020 00:00:00 GMT")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(data))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (s *gcsTestServer) deletedObjects() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string{}, s.deleted...)
+}
+
+// gcsTestReader creates a reader of a fake bucket. The storage emulator
+// environment variable is set until the returned func is called.
+func gcsTestReader(t *testing.T, fs *gcsTestServer, conf GCPCloudStorageConfig) (*GCPCloudStorage, func()) {
+	t.Helper()
+
+	server := httptest.NewServer(fs)
+	u, _ := url.Parse(server.URL)
+	os.Setenv("STORAGE_EMULATOR_HOST", u.Host)
+
+	g, err := NewGCPCloudStorage(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.storageOpts = []option.ClientOption{
+		option.WithEndpoint(server.URL + "/storage/v1/"),
+		option.WithoutAuthentication(),
+	}
+	return g, func() {
+		g.CloseAsync()
+		os.Unsetenv("STORAGE_EMULATOR_HOST")
+		server.Close()
+	}
+}
+
+func gcsTestRead(t *testing.T, g *GCPCloudStorage) (types.Message, AsyncAckFn) {
+	t.Helper()
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+	for {
+		msg, ackFn, err := g.ReadWithContext(ctx)
+		if err == types.ErrTimeout && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg, ackFn
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestGCPCloudStorageBadConfig(t *testing.T) {
+	conf := NewGCPCloudStorageConfig()
+	if _, err := NewGCPCloudStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing bucket")
+	}
+
+	conf.Bucket = "bkt"
+	conf.PubSubSubscription = "foo"
+	if _, err := NewGCPCloudStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing project")
+	}
+
+	conf.PubSubSubscription = ""
+	conf.Codec = "nope"
+	if _, err := NewGCPCloudStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad codec")
+	}
+}
+
+func TestGCPCloudStorageList(t *testing.T) {
+	fs := &gcsTestServer{
+		objects: map[string]string{
+			"in/a.txt":  "foo\nbar",
+			"in/b.txt":  "baz",
+			"out/c.txt": "buz",
+		},
+	}
+
+	conf := NewGCPCloudStorageConfig()
+	conf.Bucket = "bkt"
+	conf.Prefix = "in/"
+	conf.Codec = "lines"
+	conf.DeleteObjects = true
+
+	g, done := gcsTestReader(t, fs, conf)
+	defer done()
+
+	if err := g.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []string
+	var acks []AsyncAckFn
+	for i := 0; i < 3; i++ {
+		msg, ackFn := gcsTestRead(t, g)
+		contents = append(contents, string(msg.Get(0).Get()))
+		acks = append(acks, ackFn)
+	}
+	if exp, act := []string{"foo", "bar", "baz"}, contents; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong contents: %v != %v", act, exp)
+	}
+	if _, _, err := g.ReadWithContext(context.Background()); err != types.ErrTypeClosed {
+		t.Errorf("Expected closed error, got: %v", err)
+	}
+
+	acks[0](context.Background(), response.NewAck())
+	acks[2](context.Background(), response.NewAck())
+	if exp, act := []string{"in/b.txt"}, fs.deletedObjects(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong deleted objects: %v != %v", act, exp)
+	}
+	acks[1](context.Background(), response.NewAck())
+	if exp, act := []string{"in/b.txt", "in/a.txt"}, fs.deletedObjects(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong deleted objects: %v != %v", act, exp)
+	}
+}
+
+func TestGCPCloudStorageMetadata(t *testing.T) {
+	fs := &gcsTestServer{
+		objects: map[string]string{"a.txt": "foo"},
+	}
+
+	conf := NewGCPCloudStorageConfig()
+	conf.Bucket = "bkt"
+
+	g, done := gcsTestReader(t, fs, conf)
+	defer done()
+
+	if err := g.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, _ := gcsTestRead(t, g)
+	exp := map[string]string{
+		"gcs_key":                "a.txt",
+		"gcs_bucket":             "bkt",
+		"gcs_last_modified":      "2020-01-06T00:00:00Z",
+		"gcs_last_modified_unix": "1578268800",
+		"gcs_content_type":       "text/plain",
+	}
+	act := map[string]string{}
+	msg.Get(0).Metadata().Iter(func(k, v string) error {
+		act[k] = v
+		return nil
+	})
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+}
+
+func TestGCPCloudStorageNotifications(t *testing.T) {
+	psServer := pstest.NewServer()
+	defer psServer.Close()
+
+	conn, err := grpc.Dial(psServer.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	psClient, err := pubsub.NewClient(ctx, "proj", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic, err := psClient.CreateTopic(ctx, "notifications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = psClient.CreateSubscription(ctx, "sub", pubsub.SubscriptionConfig{Topic: topic}); err != nil {
+		t.Fatal(err)
+	}
+
+	notify := func(eventType, bucket, object string) string {
+		return psServer.Publish("projects/proj/topics/notifications", nil, map[string]string{
+			"eventType": eventType,
+			"bucketId":  bucket,
+			"objectId":  object,
+		})
+	}
+	ignoredID := notify("OBJECT_DELETE", "bkt", "a.txt")
+	otherID := notify("OBJECT_FINALIZE", "other", "a.txt")
+	missingID := notify("OBJECT_FINALIZE", "bkt", "missing.txt")
+	createdID := notify("OBJECT_FINALIZE", "bkt", "a.txt")
+
+	fs := &gcsTestServer{
+		objects: map[string]string{"a.txt": "foo"},
+	}
+
+	conf := NewGCPCloudStorageConfig()
+	conf.Bucket = "bkt"
+	conf.PubSubProject = "proj"
+	conf.PubSubSubscription = "sub"
+
+	g, done := gcsTestReader(t, fs, conf)
+	defer done()
+	g.pubsubOpts = []option.ClientOption{option.WithGRPCConn(conn)}
+
+	if err = g.ConnectWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ackFn := gcsTestRead(t, g)
+	if exp, act := "foo", string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong contents: %v != %v", act, exp)
+	}
+	if exp, act := 0, psServer.Message(createdID).Acks; exp != act {
+		t.Errorf("Wrong count of acks: %v != %v", act, exp)
+	}
+	if err = ackFn(ctx, response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notifications are delivered in any order, and so reading continues until
+	// all have been consumed.
+	ids := []string{ignoredID, otherID, missingID, createdID}
+	tEnd := time.Now().Add(time.Second * 5)
+	for time.Now().Before(tEnd) {
+		acked := 0
+		for _, id := range ids {
+			if psServer.Message(id).Acks > 0 {
+				acked++
+			}
+		}
+		if acked == len(ids) {
+			break
+		}
+		rctx, rdone := context.WithTimeout(ctx, time.Millisecond*50)
+		if _, _, err = g.ReadWithContext(rctx); err != types.ErrTimeout {
+			t.Errorf("Expected timeout error, got: %v", err)
+		}
+		rdone()
+	}
+	for _, id := range ids {
+		if psServer.Message(id).Acks == 0 {
+			t.Errorf("Expected notification %v to be acknowledged", id)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
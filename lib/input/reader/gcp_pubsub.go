@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
@@ -43,6 +44,7 @@ type GCPPubSubConfig struct {
 	SubscriptionID         string `json:"subscription" yaml:"subscription"`
 	MaxOutstandingMessages int    `json:"max_outstanding_messages" yaml:"max_outstanding_messages"`
 	MaxOutstandingBytes    int    `json:"max_outstanding_bytes" yaml:"max_outstanding_bytes"`
+	ExactlyOnceDelivery    bool   `json:"exactly_once_delivery" yaml:"exactly_once_delivery"`
 	// TODO: V4 Remove this.
 	MaxBatchCount int                `json:"max_batch_count" yaml:"max_batch_count"`
 	Batching      batch.PolicyConfig `json:"batching" yaml:"batching"`
@@ -57,6 +59,7 @@ func NewGCPPubSubConfig() GCPPubSubConfig {
 		SubscriptionID:         "",
 		MaxOutstandingMessages: pubsub.DefaultReceiveSettings.MaxOutstandingMessages,
 		MaxOutstandingBytes:    pubsub.DefaultReceiveSettings.MaxOutstandingBytes,
+		ExactlyOnceDelivery:    false,
 		MaxBatchCount:          1,
 		Batching:               batchConf,
 	}
@@ -70,12 +73,13 @@ type GCPPubSub struct {
 	conf GCPPubSubConfig
 
 	subscription *pubsub.Subscription
-	msgsChan     chan *pubsub.Message
+	msgsChan     chan *gcpPubSubMessage
 	closeFunc    context.CancelFunc
 	subMut       sync.Mutex
 
 	client      *pubsub.Client
-	pendingMsgs []*pubsub.Message
+	subClient   *vkit.SubscriberClient
+	pendingMsgs []*gcpPubSubMessage
 
 	log   log.Modular
 	stats metrics.Type
@@ -93,12 +97,56 @@ func NewGCPPubSub(
 	if err != nil {
 		return nil, err
 	}
-	return &GCPPubSub{
+	c := &GCPPubSub{
 		conf:   conf,
 		log:    log,
 		stats:  stats,
 		client: client,
-	}, nil
+	}
+	if conf.ExactlyOnceDelivery {
+		if c.subClient, err = newGCPPubSubSubscriberClient(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// gcpPubSubMessage is a message consumed from a subscription along with the
+// means of acknowledging it.
+type gcpPubSubMessage struct {
+	data        []byte
+	attributes  map[string]string
+	publishTime time.Time
+	orderingKey string
+
+	ack  func(ctx context.Context) error
+	nack func()
+}
+
+func newGCPPubSubMessage(m *pubsub.Message) *gcpPubSubMessage {
+	return &gcpPubSubMessage{
+		data:        m.Data,
+		attributes:  m.Attributes,
+		publishTime: m.PublishTime,
+		orderingKey: m.OrderingKey,
+		ack: func(context.Context) error {
+			m.Ack()
+			return nil
+		},
+		nack: m.Nack,
+	}
+}
+
+func (m *gcpPubSubMessage) toPart() types.Part {
+	part := message.NewPart(m.data)
+	part.SetMetadata(metadata.New(m.attributes))
+	part.Metadata().Set("gcp_pubsub_publish_time_unix", strconv.FormatInt(m.publishTime.Unix(), 10))
+	if len(m.orderingKey) > 0 {
+		part.Metadata().Set("gcp_pubsub_ordering_key", m.orderingKey)
+	}
+	return part
 }
 
 // Connect attempts to establish a connection to the target subscription.
@@ -120,19 +168,24 @@ func (c *GCPPubSub) ConnectWithContext(ignored context.Context) error {
 	sub.ReceiveSettings.MaxOutstandingBytes = c.conf.MaxOutstandingBytes
 
 	subCtx, cancel := context.WithCancel(context.Background())
-	msgsChan := make(chan *pubsub.Message, c.conf.MaxBatchCount)
+	msgsChan := make(chan *gcpPubSubMessage, c.conf.MaxBatchCount)
 
 	c.subscription = sub
 	c.msgsChan = msgsChan
 	c.closeFunc = cancel
 
 	go func() {
-		rerr := sub.Receive(subCtx, func(ctx context.Context, m *pubsub.Message) {
-			select {
-			case msgsChan <- m:
-			case <-ctx.Done():
-			}
-		})
+		var rerr error
+		if c.subClient != nil {
+			rerr = c.pullExactlyOnce(subCtx, msgsChan)
+		} else {
+			rerr = sub.Receive(subCtx, func(ctx context.Context, m *pubsub.Message) {
+				select {
+				case msgsChan <- newGCPPubSubMessage(m):
+				case <-ctx.Done():
+				}
+			})
+		}
 		if rerr != context.Canceled {
 			c.log.Errorf("Subscription error: %v\n", rerr)
 		}
@@ -159,7 +212,7 @@ func (c *GCPPubSub) ReadWithContext(ctx context.Context) (types.Message, AsyncAc
 
 	msg := message.New(nil)
 
-	var gmsg *pubsub.Message
+	var gmsg *gcpPubSubMessage
 	var open bool
 	select {
 	case gmsg, open = <-msgsChan:
@@ -170,18 +223,14 @@ func (c *GCPPubSub) ReadWithContext(ctx context.Context) (types.Message, AsyncAc
 		return nil, nil, types.ErrNotConnected
 	}
 
-	part := message.NewPart(gmsg.Data)
-	part.SetMetadata(metadata.New(gmsg.Attributes))
-	part.Metadata().Set("gcp_pubsub_publish_time_unix", strconv.FormatInt(gmsg.PublishTime.Unix(), 10))
-	msg.Append(part)
+	msg.Append(gmsg.toPart())
 
 	return msg, func(ctx context.Context, res types.Response) error {
 		if res.Error() != nil {
-			gmsg.Nack()
-		} else {
-			gmsg.Ack()
+			gmsg.nack()
+			return nil
 		}
-		return nil
+		return gmsg.ack(ctx)
 	}, nil
 }
 
@@ -201,10 +250,7 @@ func (c *GCPPubSub) Read() (types.Message, error) {
 		return nil, types.ErrNotConnected
 	}
 	c.pendingMsgs = append(c.pendingMsgs, gmsg)
-	part := message.NewPart(gmsg.Data)
-	part.SetMetadata(metadata.New(gmsg.Attributes))
-	part.Metadata().Set("gcp_pubsub_publish_time_unix", strconv.FormatInt(gmsg.PublishTime.Unix(), 10))
-	msg.Append(part)
+	msg.Append(gmsg.toPart())
 
 batchLoop:
 	for msg.Len() < c.conf.MaxBatchCount {
@@ -218,10 +264,7 @@ batchLoop:
 			return nil, types.ErrNotConnected
 		}
 		c.pendingMsgs = append(c.pendingMsgs, gmsg)
-		part := message.NewPart(gmsg.Data)
-		part.SetMetadata(metadata.New(gmsg.Attributes))
-		part.Metadata().Set("gcp_pubsub_publish_time_unix", strconv.FormatInt(gmsg.PublishTime.Unix(), 10))
-		msg.Append(part)
+		msg.Append(gmsg.toPart())
 	}
 
 	return msg, nil
@@ -230,15 +273,18 @@ batchLoop:
 // Acknowledge confirms whether or not our unacknowledged messages have been
 // successfully propagated or not.
 func (c *GCPPubSub) Acknowledge(err error) error {
+	var ackErr error
 	for _, msg := range c.pendingMsgs {
 		if err == nil {
-			msg.Ack()
+			if aerr := msg.ack(context.Background()); aerr != nil {
+				ackErr = aerr
+			}
 		} else {
-			msg.Nack()
+			msg.nack()
 		}
 	}
 	c.pendingMsgs = nil
-	return nil
+	return ackErr
 }
 
 // CloseAsync begins cleaning up resources used by this reader asynchronously.
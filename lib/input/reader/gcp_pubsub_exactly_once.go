@@ -0,0 +1,184 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/api/option"
+	pubsubpb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc"
+)
+
+//------------------------------------------------------------------------------
+
+const (
+	// The ack deadline set on pulled messages, which is extended for as long
+	// as each message remains outstanding.
+	gcpPubSubAckDeadline    = 60 * time.Second
+	gcpPubSubLeaseExtension = 20 * time.Second
+)
+
+// newGCPPubSubSubscriberClient creates a low level subscriber client, which
+// connects to an emulator when one is configured in the same way as the high
+// level client.
+func newGCPPubSubSubscriberClient(ctx context.Context) (*vkit.SubscriberClient, error) {
+	var opts []option.ClientOption
+	if addr := os.Getenv("PUBSUB_EMULATOR_HOST"); addr != "" {
+		conn, err := grpc.Dial(addr, grpc.WithInsecure())
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial emulator: %v", err)
+		}
+		opts = append(opts, option.WithGRPCConn(conn))
+	}
+	return vkit.NewSubscriberClient(ctx, opts...)
+}
+
+// pullExactlyOnce pulls messages from a subscription with exactly-once
+// delivery enabled. Unlike the streaming receiver each acknowledgement is sent
+// synchronously, so that a failure to acknowledge a message, which results in
+// redelivery, is reported rather than silently ignored.
+func (c *GCPPubSub) pullExactlyOnce(ctx context.Context, msgsChan chan<- *gcpPubSubMessage) error {
+	subName := fmt.Sprintf("projects/%v/subscriptions/%v", c.conf.ProjectID, c.conf.SubscriptionID)
+
+	maxOutstanding := c.conf.MaxOutstandingMessages
+	if maxOutstanding <= 0 {
+		maxOutstanding = 1000
+	}
+	slots := make(chan struct{}, maxOutstanding)
+
+	var leaseMut sync.Mutex
+	leases := map[string]struct{}{}
+
+	release := func(ackID string) {
+		leaseMut.Lock()
+		if _, exists := leases[ackID]; exists {
+			delete(leases, ackID)
+			<-slots
+		}
+		leaseMut.Unlock()
+	}
+
+	leaseCtx, leaseDone := context.WithCancel(ctx)
+	defer leaseDone()
+	go func() {
+		ticker := time.NewTicker(gcpPubSubLeaseExtension)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-leaseCtx.Done():
+				return
+			}
+			leaseMut.Lock()
+			ackIDs := make([]string, 0, len(leases))
+			for id := range leases {
+				ackIDs = append(ackIDs, id)
+			}
+			leaseMut.Unlock()
+			if len(ackIDs) == 0 {
+				continue
+			}
+			if err := c.subClient.ModifyAckDeadline(leaseCtx, &pubsubpb.ModifyAckDeadlineRequest{
+				Subscription:       subName,
+				AckIds:             ackIDs,
+				AckDeadlineSeconds: int32(gcpPubSubAckDeadline / time.Second),
+			}); err != nil && leaseCtx.Err() == nil {
+				c.log.Warnf("Failed to extend message ack deadlines: %v\n", err)
+			}
+		}
+	}()
+
+	for {
+		// Wait for at least one slot to become free before pulling.
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		free := 1 + cap(slots) - len(slots)
+		<-slots
+
+		res, err := c.subClient.Pull(ctx, &pubsubpb.PullRequest{
+			Subscription: subName,
+			MaxMessages:  int32(free),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		for _, rmsg := range res.ReceivedMessages {
+			ackID := rmsg.AckId
+			leaseMut.Lock()
+			leases[ackID] = struct{}{}
+			slots <- struct{}{}
+			leaseMut.Unlock()
+
+			gmsg := &gcpPubSubMessage{
+				ack: func(ctx context.Context) error {
+					defer release(ackID)
+					if err := c.subClient.Acknowledge(ctx, &pubsubpb.AcknowledgeRequest{
+						Subscription: subName,
+						AckIds:       []string{ackID},
+					}); err != nil {
+						return fmt.Errorf("failed to acknowledge message: %v", err)
+					}
+					return nil
+				},
+				nack: func() {
+					defer release(ackID)
+					if err := c.subClient.ModifyAckDeadline(context.Background(), &pubsubpb.ModifyAckDeadlineRequest{
+						Subscription:       subName,
+						AckIds:             []string{ackID},
+						AckDeadlineSeconds: 0,
+					}); err != nil {
+						c.log.Warnf("Failed to nack message: %v\n", err)
+					}
+				},
+			}
+			if m := rmsg.Message; m != nil {
+				gmsg.data = m.Data
+				gmsg.attributes = m.Attributes
+				gmsg.orderingKey = m.OrderingKey
+				if m.PublishTime != nil {
+					gmsg.publishTime, _ = ptypes.Timestamp(m.PublishTime)
+				}
+			}
+
+			select {
+			case msgsChan <- gmsg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,367 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/http/auth"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/gorilla/websocket"
+)
+
+//------------------------------------------------------------------------------
+
+// GraphQLSubscriptionConfig contains configuration fields for the
+// GraphQLSubscription input type.
+type GraphQLSubscriptionConfig struct {
+	URL              string            `json:"url" yaml:"url"`
+	Protocol         string            `json:"protocol" yaml:"protocol"`
+	Query            string            `json:"query" yaml:"query"`
+	OperationName    string            `json:"operation_name" yaml:"operation_name"`
+	Variables        string            `json:"variables" yaml:"variables"`
+	Headers          map[string]string `json:"headers" yaml:"headers"`
+	ConnectionParams map[string]string `json:"connection_params" yaml:"connection_params"`
+	Timeout          string            `json:"timeout" yaml:"timeout"`
+	TLS              btls.Config       `json:"tls" yaml:"tls"`
+	auth.Config      `json:",inline" yaml:",inline"`
+}
+
+// NewGraphQLSubscriptionConfig creates a new GraphQLSubscriptionConfig with
+// default values.
+func NewGraphQLSubscriptionConfig() GraphQLSubscriptionConfig {
+	return GraphQLSubscriptionConfig{
+		URL:              "ws://localhost:4000/graphql",
+		Protocol:         "graphql-ws",
+		Query:            "",
+		OperationName:    "",
+		Variables:        "",
+		Headers:          map[string]string{},
+		ConnectionParams: map[string]string{},
+		Timeout:          "10s",
+		TLS:              btls.NewConfig(),
+		Config:           auth.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// The message types of the legacy graphql-ws protocol and the newer
+// graphql-transport-ws protocol.
+const (
+	gqlConnectionInit      = "connection_init"
+	gqlConnectionAck       = "connection_ack"
+	gqlConnectionError     = "connection_error"
+	gqlConnectionKeepAlive = "ka"
+	gqlStart               = "start"
+	gqlSubscribe           = "subscribe"
+	gqlData                = "data"
+	gqlNext                = "next"
+	gqlError               = "error"
+	gqlComplete            = "complete"
+	gqlPing                = "ping"
+	gqlPong                = "pong"
+)
+
+const gqlSubscriptionID = "1"
+
+type gqlMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+//------------------------------------------------------------------------------
+
+// GraphQLSubscription is an input type that runs a GraphQL subscription over
+// a websocket and reads each payload as a message.
+type GraphQLSubscription struct {
+	conf      GraphQLSubscriptionConfig
+	dialer    *websocket.Dialer
+	timeout   time.Duration
+	initMsg   []byte
+	subscribe []byte
+
+	mut    sync.Mutex
+	client *websocket.Conn
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewGraphQLSubscription creates a new GraphQLSubscription input type.
+func NewGraphQLSubscription(
+	conf GraphQLSubscriptionConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*GraphQLSubscription, error) {
+	g := &GraphQLSubscription{
+		conf:  conf,
+		log:   log,
+		stats: stats,
+	}
+
+	if len(conf.Query) == 0 {
+		return nil, errors.New("a subscription query must be specified")
+	}
+
+	startType := gqlStart
+	switch conf.Protocol {
+	case "graphql-ws":
+	case "graphql-transport-ws":
+		startType = gqlSubscribe
+	default:
+		return nil, fmt.Errorf("unrecognised protocol: %v", conf.Protocol)
+	}
+
+	var err error
+	if g.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	g.dialer = &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: g.timeout,
+		Subprotocols:     []string{conf.Protocol},
+	}
+	if conf.TLS.Enabled {
+		if g.dialer.TLSClientConfig, err = conf.TLS.Get(); err != nil {
+			return nil, err
+		}
+	}
+
+	initMsg := gqlMessage{Type: gqlConnectionInit}
+	if len(conf.ConnectionParams) > 0 {
+		if initMsg.Payload, err = json.Marshal(conf.ConnectionParams); err != nil {
+			return nil, err
+		}
+	}
+	if g.initMsg, err = json.Marshal(initMsg); err != nil {
+		return nil, err
+	}
+
+	payload := struct {
+		Query         string          `json:"query"`
+		OperationName string          `json:"operationName,omitempty"`
+		Variables     json.RawMessage `json:"variables,omitempty"`
+	}{
+		Query:         conf.Query,
+		OperationName: conf.OperationName,
+	}
+	if len(conf.Variables) > 0 {
+		var vars map[string]interface{}
+		if err = json.Unmarshal([]byte(conf.Variables), &vars); err != nil {
+			return nil, fmt.Errorf("failed to parse variables: %v", err)
+		}
+		payload.Variables = json.RawMessage(conf.Variables)
+	}
+	subMsg := gqlMessage{ID: gqlSubscriptionID, Type: startType}
+	if subMsg.Payload, err = json.Marshal(payload); err != nil {
+		return nil, err
+	}
+	if g.subscribe, err = json.Marshal(subMsg); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (g *GraphQLSubscription) getWS() *websocket.Conn {
+	g.mut.Lock()
+	ws := g.client
+	g.mut.Unlock()
+	return ws
+}
+
+func (g *GraphQLSubscription) drop(ws *websocket.Conn) {
+	g.mut.Lock()
+	if g.client == ws {
+		g.client.Close()
+		g.client = nil
+	}
+	g.mut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a connection to a GraphQL server and starts the
+// subscription.
+func (g *GraphQLSubscription) Connect() error {
+	return g.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext establishes a connection to a GraphQL server and starts
+// the subscription.
+func (g *GraphQLSubscription) ConnectWithContext(ctx context.Context) error {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	if g.client != nil {
+		return nil
+	}
+
+	purl, err := url.Parse(g.conf.URL)
+	if err != nil {
+		return err
+	}
+
+	headers := http.Header{}
+	for k, v := range g.conf.Headers {
+		headers.Set(k, v)
+	}
+	if err = g.conf.Sign(&http.Request{
+		URL:    purl,
+		Header: headers,
+	}); err != nil {
+		return err
+	}
+
+	client, res, err := g.dialer.DialContext(ctx, g.conf.URL, headers)
+	if err != nil {
+		if res != nil {
+			err = fmt.Errorf("%v: %v", err, res.Status)
+		}
+		return err
+	}
+
+	if err = g.handshake(client); err != nil {
+		client.Close()
+		return err
+	}
+
+	g.client = client
+	g.log.Infof("Receiving GraphQL subscription payloads from: %v\n", g.conf.URL)
+	return nil
+}
+
+// handshake initialises the connection and waits for it to be accepted
+// before starting the subscription.
+func (g *GraphQLSubscription) handshake(client *websocket.Conn) error {
+	if err := client.WriteMessage(websocket.TextMessage, g.initMsg); err != nil {
+		return err
+	}
+
+	client.SetReadDeadline(time.Now().Add(g.timeout))
+	for {
+		var msg gqlMessage
+		if err := client.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("failed to receive connection acknowledgement: %v", err)
+		}
+		if msg.Type == gqlConnectionAck {
+			break
+		}
+		switch msg.Type {
+		case gqlConnectionError, gqlError:
+			return fmt.Errorf("connection rejected: %s", msg.Payload)
+		case gqlPing:
+			if err := client.WriteJSON(gqlMessage{Type: gqlPong}); err != nil {
+				return err
+			}
+		}
+	}
+	client.SetReadDeadline(time.Time{})
+
+	return client.WriteMessage(websocket.TextMessage, g.subscribe)
+}
+
+//------------------------------------------------------------------------------
+
+// Read attempts to read a new payload from the subscription.
+func (g *GraphQLSubscription) Read() (types.Message, error) {
+	msg, _, err := g.ReadWithContext(context.Background())
+	return msg, err
+}
+
+// ReadWithContext attempts to read a new payload from the subscription.
+func (g *GraphQLSubscription) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	client := g.getWS()
+	if client == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	for {
+		var msg gqlMessage
+		if err := client.ReadJSON(&msg); err != nil {
+			if g.getWS() == client {
+				g.log.Errorf("Failed to read subscription: %v\n", err)
+			}
+			g.drop(client)
+			return nil, nil, types.ErrNotConnected
+		}
+
+		switch msg.Type {
+		case gqlData, gqlNext:
+			if msg.ID != gqlSubscriptionID {
+				continue
+			}
+			return message.New([][]byte{[]byte(msg.Payload)}), noopAsyncAckFn, nil
+		case gqlPing:
+			if err := client.WriteJSON(gqlMessage{Type: gqlPong}); err != nil {
+				g.drop(client)
+				return nil, nil, types.ErrNotConnected
+			}
+		case gqlError, gqlConnectionError:
+			g.log.Errorf("Subscription failed: %s\n", msg.Payload)
+			g.drop(client)
+			return nil, nil, types.ErrNotConnected
+		case gqlComplete:
+			g.log.Warnln("Subscription completed by server, resubscribing")
+			g.drop(client)
+			return nil, nil, types.ErrNotConnected
+		}
+	}
+}
+
+// Acknowledge instructs whether the pending messages were propagated
+// successfully.
+func (g *GraphQLSubscription) Acknowledge(err error) error {
+	return nil
+}
+
+// CloseAsync shuts down the GraphQLSubscription input and stops reading
+// messages.
+func (g *GraphQLSubscription) CloseAsync() {
+	g.mut.Lock()
+	if g.client != nil {
+		g.client.Close()
+		g.client = nil
+	}
+	g.mut.Unlock()
+}
+
+// WaitForClose blocks until the GraphQLSubscription input has closed down.
+func (g *GraphQLSubscription) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,192 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/gorilla/websocket"
+)
+
+//------------------------------------------------------------------------------
+
+func testGraphQLServer(t *testing.T, protocol string, payloads []string) *httptest.Server {
+	t.Helper()
+
+	dataType, startType := gqlData, gqlStart
+	if protocol == "graphql-transport-ws" {
+		dataType, startType = gqlNext, gqlSubscribe
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer foo" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		upgrader := websocket.Upgrader{Subprotocols: []string{protocol}}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+
+		if ws.Subprotocol() != protocol {
+			t.Errorf("Wrong subprotocol: %v", ws.Subprotocol())
+			return
+		}
+
+		var msg gqlMessage
+		if err = ws.ReadJSON(&msg); err != nil {
+			t.Error(err)
+			return
+		}
+		if exp, act := gqlConnectionInit, msg.Type; exp != act {
+			t.Errorf("Wrong message type: %v != %v", act, exp)
+		}
+		var params map[string]string
+		if err = json.Unmarshal(msg.Payload, &params); err != nil {
+			t.Error(err)
+		} else if exp, act := "bar", params["token"]; exp != act {
+			t.Errorf("Wrong connection param: %v != %v", act, exp)
+		}
+		if protocol == "graphql-transport-ws" {
+			ws.WriteJSON(gqlMessage{Type: gqlPing})
+		}
+		ws.WriteJSON(gqlMessage{Type: gqlConnectionAck})
+
+		for {
+			if err = ws.ReadJSON(&msg); err != nil {
+				t.Error(err)
+				return
+			}
+			if msg.Type != gqlPong {
+				break
+			}
+		}
+		if exp, act := startType, msg.Type; exp != act {
+			t.Errorf("Wrong message type: %v != %v", act, exp)
+		}
+		if exp, act := `{"query":"subscription { foo }","variables":{"bar":1}}`, string(msg.Payload); exp != act {
+			t.Errorf("Wrong subscribe payload: %v != %v", act, exp)
+		}
+
+		ws.WriteJSON(gqlMessage{Type: gqlConnectionKeepAlive})
+		for _, p := range payloads {
+			ws.WriteJSON(gqlMessage{ID: msg.ID, Type: dataType, Payload: json.RawMessage(p)})
+		}
+		ws.WriteJSON(gqlMessage{ID: msg.ID, Type: gqlComplete})
+	}))
+}
+
+func TestGraphQLSubscription(t *testing.T) {
+	for _, protocol := range []string{"graphql-ws", "graphql-transport-ws"} {
+		protocol := protocol
+		t.Run(protocol, func(tt *testing.T) {
+			payloads := []string{
+				`{"data":{"foo":"first"}}`,
+				`{"data":{"foo":"second"}}`,
+			}
+			server := testGraphQLServer(tt, protocol, payloads)
+			defer server.Close()
+
+			conf := NewGraphQLSubscriptionConfig()
+			conf.URL = "ws" + strings.TrimPrefix(server.URL, "http")
+			conf.Protocol = protocol
+			conf.Query = "subscription { foo }"
+			conf.Variables = `{"bar":1}`
+			conf.Headers["Authorization"] = "Bearer foo"
+			conf.ConnectionParams["token"] = "bar"
+
+			g, err := NewGraphQLSubscription(conf, log.Noop(), metrics.Noop())
+			if err != nil {
+				tt.Fatal(err)
+			}
+			if err = g.Connect(); err != nil {
+				tt.Fatal(err)
+			}
+
+			for _, exp := range payloads {
+				msg, err := g.Read()
+				if err != nil {
+					tt.Fatal(err)
+				}
+				if act := string(msg.Get(0).Get()); exp != act {
+					tt.Errorf("Wrong payload: %v != %v", act, exp)
+				}
+			}
+			if _, err = g.Read(); err != types.ErrNotConnected {
+				tt.Errorf("Wrong error: %v != %v", err, types.ErrNotConnected)
+			}
+
+			g.CloseAsync()
+			if err = g.WaitForClose(time.Second); err != nil {
+				tt.Error(err)
+			}
+		})
+	}
+}
+
+func TestGraphQLSubscriptionUnauthorized(t *testing.T) {
+	server := testGraphQLServer(t, "graphql-ws", nil)
+	defer server.Close()
+
+	conf := NewGraphQLSubscriptionConfig()
+	conf.URL = "ws" + strings.TrimPrefix(server.URL, "http")
+	conf.Query = "subscription { foo }"
+
+	g, err := NewGraphQLSubscription(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Connect(); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestGraphQLSubscriptionBadConfig(t *testing.T) {
+	conf := NewGraphQLSubscriptionConfig()
+	if _, err := NewGraphQLSubscription(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing query")
+	}
+
+	conf.Query = "subscription { foo }"
+	conf.Variables = "not json"
+	if _, err := NewGraphQLSubscription(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad variables")
+	}
+
+	conf.Variables = ""
+	conf.Protocol = "nope"
+	if _, err := NewGraphQLSubscription(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad protocol")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -22,6 +22,7 @@ package reader
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"time"
 
@@ -29,24 +30,29 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
-	"github.com/colinmarc/hdfs"
+	"github.com/Jeffail/benthos/v3/lib/util/kerberos"
+	"github.com/colinmarc/hdfs/v2"
 )
 
 //------------------------------------------------------------------------------
 
 // HDFSConfig contains configuration fields for the HDFS input type.
 type HDFSConfig struct {
-	Hosts     []string `json:"hosts" yaml:"hosts"`
-	User      string   `json:"user" yaml:"user"`
-	Directory string   `json:"directory" yaml:"directory"`
+	Hosts     []string        `json:"hosts" yaml:"hosts"`
+	User      string          `json:"user" yaml:"user"`
+	Directory string          `json:"directory" yaml:"directory"`
+	Kerberos  kerberos.Config `json:"kerberos" yaml:"kerberos"`
 }
 
 // NewHDFSConfig creates a new Config with default values.
 func NewHDFSConfig() HDFSConfig {
+	kerb := kerberos.NewConfig()
+	kerb.ServicePrincipalName = "nn/_HOST"
 	return HDFSConfig{
 		Hosts:     []string{"localhost:9000"},
 		User:      "benthos_hdfs",
 		Directory: "",
+		Kerberos:  kerb,
 	}
 }
 
@@ -92,11 +98,24 @@ func (h *HDFS) ConnectWithContext(ctx context.Context) error {
 		return nil
 	}
 
-	client, err := hdfs.NewClient(hdfs.ClientOptions{
+	opts := hdfs.ClientOptions{
 		Addresses: h.conf.Hosts,
 		User:      h.conf.User,
-	})
+	}
+	if h.conf.Kerberos.Enabled {
+		krbClient, err := h.conf.Kerberos.Client()
+		if err != nil {
+			return fmt.Errorf("failed to create kerberos client: %v", err)
+		}
+		opts.KerberosClient = krbClient
+		opts.KerberosServicePrincipleName = h.conf.Kerberos.ServicePrincipalName
+	}
+
+	client, err := hdfs.NewClient(opts)
 	if err != nil {
+		if opts.KerberosClient != nil {
+			opts.KerberosClient.Destroy()
+		}
 		return err
 	}
 
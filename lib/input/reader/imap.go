@@ -0,0 +1,489 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+// IMAPConfig contains configuration fields for the IMAP input type.
+type IMAPConfig struct {
+	Address        string      `json:"address" yaml:"address"`
+	TLS            btls.Config `json:"tls" yaml:"tls"`
+	Username       string      `json:"username" yaml:"username"`
+	Password       string      `json:"password" yaml:"password"`
+	Mailbox        string      `json:"mailbox" yaml:"mailbox"`
+	Search         string      `json:"search" yaml:"search"`
+	PollInterval   string      `json:"poll_interval" yaml:"poll_interval"`
+	MarkSeen       bool        `json:"mark_seen" yaml:"mark_seen"`
+	DeleteOnFinish bool        `json:"delete_on_finish" yaml:"delete_on_finish"`
+	MoveTo         string      `json:"move_to" yaml:"move_to"`
+	Timeout        string      `json:"timeout" yaml:"timeout"`
+}
+
+// NewIMAPConfig creates a new IMAPConfig with default values.
+func NewIMAPConfig() IMAPConfig {
+	tlsConf := btls.NewConfig()
+	tlsConf.Enabled = true
+	return IMAPConfig{
+		Address:        "localhost:993",
+		TLS:            tlsConf,
+		Username:       "",
+		Password:       "",
+		Mailbox:        "INBOX",
+		Search:         "UNSEEN",
+		PollInterval:   "30s",
+		MarkSeen:       true,
+		DeleteOnFinish: false,
+		MoveTo:         "",
+		Timeout:        "10s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// IMAP is an input type that polls a mailbox of an IMAP server and reads each
+// matching email as a message.
+type IMAP struct {
+	conf         IMAPConfig
+	tlsConf      *tls.Config
+	pollInterval time.Duration
+	timeout      time.Duration
+
+	mut      sync.Mutex
+	client   *imapClient
+	queue    []uint32
+	inFlight map[uint32]struct{}
+	nextPoll time.Time
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewIMAP creates a new IMAP input type.
+func NewIMAP(conf IMAPConfig, log log.Modular, stats metrics.Type) (*IMAP, error) {
+	i := &IMAP{
+		conf:     conf,
+		inFlight: map[uint32]struct{}{},
+		log:      log,
+		stats:    stats,
+	}
+
+	if len(conf.Mailbox) == 0 {
+		return nil, errors.New("a mailbox must be specified")
+	}
+	if len(conf.Search) == 0 {
+		return nil, errors.New("search criteria must be specified")
+	}
+	if conf.DeleteOnFinish && len(conf.MoveTo) > 0 {
+		return nil, errors.New("cannot both delete and move emails on finish")
+	}
+
+	var err error
+	if i.pollInterval, err = time.ParseDuration(conf.PollInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse poll interval: %v", err)
+	}
+	if i.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if conf.TLS.Enabled {
+		if i.tlsConf, err = conf.TLS.Get(); err != nil {
+			return nil, err
+		}
+		if len(i.tlsConf.ServerName) == 0 {
+			if i.tlsConf.ServerName, _, err = net.SplitHostPort(conf.Address); err != nil {
+				return nil, fmt.Errorf("failed to parse address: %v", err)
+			}
+		}
+	}
+	return i, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext logs into the IMAP server and selects the mailbox.
+func (i *IMAP) ConnectWithContext(ctx context.Context) error {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	if i.client != nil {
+		return nil
+	}
+
+	dialer := net.Dialer{Timeout: i.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", i.conf.Address)
+	if err != nil {
+		return err
+	}
+	if i.tlsConf != nil {
+		conn = tls.Client(conn, i.tlsConf)
+	}
+
+	client, err := newIMAPClient(conn, i.timeout)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to initialise imap session: %v", err)
+	}
+	if err = client.login(i.conf.Username, i.conf.Password); err != nil {
+		client.close()
+		return fmt.Errorf("failed to login: %v", err)
+	}
+	if err = client.selectMailbox(i.conf.Mailbox); err != nil {
+		client.close()
+		return fmt.Errorf("failed to select mailbox '%v': %v", i.conf.Mailbox, err)
+	}
+
+	i.client = client
+	i.queue = nil
+	i.nextPoll = time.Time{}
+	i.log.Infof("Polling IMAP mailbox '%v' on %v\n", i.conf.Mailbox, i.conf.Address)
+	return nil
+}
+
+// disconnect closes the current session after a transport error. The mutex
+// must be held when calling.
+func (i *IMAP) disconnect() {
+	if i.client != nil {
+		i.client.close()
+		i.client = nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// readNext reads the next queued email, searching the mailbox when the queue
+// is empty and the poll interval has passed. A nil message is returned when
+// there are no emails to read.
+func (i *IMAP) readNext() (types.Message, uint32, error) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	if i.client == nil {
+		return nil, 0, types.ErrNotConnected
+	}
+	if len(i.queue) == 0 {
+		if time.Now().Before(i.nextPoll) {
+			return nil, 0, nil
+		}
+		i.nextPoll = time.Now().Add(i.pollInterval)
+		uids, err := i.client.search(i.conf.Search)
+		if err != nil {
+			if _, ok := err.(*imapStatusError); !ok {
+				i.disconnect()
+			}
+			return nil, 0, fmt.Errorf("failed to search mailbox: %v", err)
+		}
+		sort.Slice(uids, func(a, b int) bool { return uids[a] < uids[b] })
+		for _, uid := range uids {
+			if _, exists := i.inFlight[uid]; !exists {
+				i.queue = append(i.queue, uid)
+			}
+		}
+	}
+
+	for len(i.queue) > 0 {
+		uid := i.queue[0]
+		i.queue = i.queue[1:]
+
+		raw, err := i.client.fetch(uid)
+		if err != nil {
+			if err == errIMAPNoMessage {
+				continue
+			}
+			if _, ok := err.(*imapStatusError); !ok {
+				i.disconnect()
+			}
+			return nil, 0, fmt.Errorf("failed to fetch email %v: %v", uid, err)
+		}
+
+		msg, err := parseEmail(raw)
+		if err != nil {
+			i.log.Errorf("Failed to parse email %v, reading it as raw bytes: %v\n", uid, err)
+			msg = message.New([][]byte{raw})
+		}
+		uidStr := strconv.FormatUint(uint64(uid), 10)
+		msg.Iter(func(_ int, p types.Part) error {
+			p.Metadata().Set("imap_uid", uidStr).Set("imap_mailbox", i.conf.Mailbox)
+			return nil
+		})
+		i.inFlight[uid] = struct{}{}
+		return msg, uid, nil
+	}
+	return nil, 0, nil
+}
+
+// ReadWithContext reads the next email as a message.
+func (i *IMAP) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	msg, uid, err := i.readNext()
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg == nil {
+		i.mut.Lock()
+		wait := time.Until(i.nextPoll)
+		i.mut.Unlock()
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+		}
+		return nil, nil, types.ErrTimeout
+	}
+	return msg, func(rctx context.Context, res types.Response) error {
+		return i.finish(uid, res.Error())
+	}, nil
+}
+
+// finish releases an email once its message has been processed, flagging,
+// deleting or moving it when configured to.
+func (i *IMAP) finish(uid uint32, resErr error) error {
+	i.mut.Lock()
+	client := i.client
+	i.mut.Unlock()
+
+	var err error
+	if resErr == nil {
+		if client == nil {
+			err = types.ErrNotConnected
+		} else if i.conf.DeleteOnFinish {
+			if err = client.remove(uid); err != nil {
+				err = fmt.Errorf("failed to delete email %v: %v", uid, err)
+			}
+		} else if len(i.conf.MoveTo) > 0 {
+			if i.conf.MarkSeen {
+				err = client.addFlags(uid, `\Seen`)
+			}
+			if err == nil {
+				err = client.move(uid, i.conf.MoveTo)
+			}
+			if err != nil {
+				err = fmt.Errorf("failed to move email %v to '%v': %v", uid, i.conf.MoveTo, err)
+			}
+		} else if i.conf.MarkSeen {
+			if err = client.addFlags(uid, `\Seen`); err != nil {
+				err = fmt.Errorf("failed to mark email %v as seen: %v", uid, err)
+			}
+		}
+	}
+
+	i.mut.Lock()
+	delete(i.inFlight, uid)
+	i.mut.Unlock()
+	return err
+}
+
+// CloseAsync shuts down the IMAP input and stops processing requests.
+func (i *IMAP) CloseAsync() {
+	i.mut.Lock()
+	i.disconnect()
+	i.mut.Unlock()
+}
+
+// WaitForClose blocks until the IMAP input has closed down.
+func (i *IMAP) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+var imapWordDecoder = mime.WordDecoder{}
+
+func decodeTransfer(r io.Reader, encoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	return ioutil.ReadAll(r)
+}
+
+// base64Cleaner strips the line breaks found within base64 encoded bodies.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (b *base64Cleaner) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	j := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' && c != ' ' && c != '\t' {
+			p[j] = c
+			j++
+		}
+	}
+	return j, err
+}
+
+// emailPart is a decoded leaf of a MIME document.
+type emailPart struct {
+	contentType string
+	filename    string
+	attachment  bool
+	body        []byte
+}
+
+func walkMIME(header map[string][]string, body io.Reader, parts *[]emailPart) error {
+	get := func(key string) string {
+		if v := header[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	contentType := get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err = walkMIME(p.Header, p, parts); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := decodeTransfer(body, get("Content-Transfer-Encoding"))
+	if err != nil {
+		return fmt.Errorf("failed to decode %v part: %v", mediaType, err)
+	}
+	part := emailPart{
+		contentType: mediaType,
+		filename:    params["name"],
+		body:        data,
+	}
+	if disposition, dparams, err := mime.ParseMediaType(get("Content-Disposition")); err == nil {
+		part.attachment = disposition == "attachment"
+		if filename := dparams["filename"]; len(filename) > 0 {
+			part.filename = filename
+		}
+	}
+	if decoded, err := imapWordDecoder.DecodeHeader(part.filename); err == nil {
+		part.filename = decoded
+	}
+	if len(part.filename) > 0 || !strings.HasPrefix(mediaType, "text/") {
+		part.attachment = true
+	}
+	*parts = append(*parts, part)
+	return nil
+}
+
+// parseEmail converts an RFC 5322 email into a message, where the first part
+// is the text body, preferring plain text over HTML, and each following part
+// is an attachment. The headers of the email are added to each part as
+// metadata.
+func parseEmail(raw []byte) (types.Message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	var leaves []emailPart
+	if err = walkMIME(m.Header, m.Body, &leaves); err != nil {
+		return nil, err
+	}
+
+	bodyIndex := -1
+	for i, p := range leaves {
+		if p.attachment {
+			continue
+		}
+		if p.contentType == "text/plain" {
+			bodyIndex = i
+			break
+		}
+		if bodyIndex < 0 {
+			bodyIndex = i
+		}
+	}
+
+	msg := message.New(nil)
+	if bodyIndex >= 0 {
+		part := message.NewPart(leaves[bodyIndex].body)
+		part.Metadata().Set("email_content_type", leaves[bodyIndex].contentType)
+		msg.Append(part)
+	} else {
+		msg.Append(message.NewPart(nil))
+	}
+	for i, p := range leaves {
+		if i == bodyIndex || !p.attachment {
+			continue
+		}
+		part := message.NewPart(p.body)
+		part.Metadata().
+			Set("email_attachment_filename", p.filename).
+			Set("email_content_type", p.contentType)
+		msg.Append(part)
+	}
+
+	msg.Iter(func(_ int, p types.Part) error {
+		meta := p.Metadata()
+		for k, v := range m.Header {
+			if k == "Content-Type" {
+				continue
+			}
+			value := strings.Join(v, ", ")
+			if decoded, err := imapWordDecoder.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+			meta.Set("email_"+strings.ToLower(strings.Replace(k, "-", "_", -1)), value)
+		}
+		return nil
+	})
+	return msg, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,278 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+const imapMaxLiteralBytes = 1 << 26
+
+// imapStatusError is returned when the server completes a command with a NO or
+// BAD status.
+type imapStatusError struct {
+	status string
+	msg    string
+}
+
+func (e *imapStatusError) Error() string {
+	return fmt.Sprintf("imap status %v: %v", e.status, e.msg)
+}
+
+// imapResponse is a single untagged response from the server, where the
+// contents of literals are removed from the line and stored in order.
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+// imapQuote formats a string as an IMAP quoted string.
+func imapQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+//------------------------------------------------------------------------------
+
+// imapClient is a minimal IMAP4rev1 client, supporting only the commands that
+// are needed for consuming a mailbox. Commands are made one at a time.
+type imapClient struct {
+	mut     sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+	nextTag int
+	caps    map[string]struct{}
+}
+
+// newIMAPClient reads the greeting of the server and its capabilities.
+func newIMAPClient(conn net.Conn, timeout time.Duration) (*imapClient, error) {
+	c := &imapClient{
+		conn:    conn,
+		r:       bufio.NewReader(conn),
+		timeout: timeout,
+		caps:    map[string]struct{}{},
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	greeting, err := c.readResponse()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting.line, "* OK") && !strings.HasPrefix(greeting.line, "* PREAUTH") {
+		return nil, fmt.Errorf("unexpected greeting: %v", greeting.line)
+	}
+	return c, nil
+}
+
+func (c *imapClient) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readResponse reads a response line along with any literals it contains.
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var res imapResponse
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return res, err
+		}
+		if !strings.HasSuffix(line, "}") {
+			res.line += line
+			return res, nil
+		}
+		i := strings.LastIndexByte(line, '{')
+		if i < 0 {
+			res.line += line
+			return res, nil
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(line[i+1:len(line)-1], "+"))
+		if err != nil {
+			res.line += line
+			return res, nil
+		}
+		if size < 0 || size > imapMaxLiteralBytes {
+			return res, fmt.Errorf("literal size exceeds limit: %v", size)
+		}
+		literal := make([]byte, size)
+		if _, err = io.ReadFull(c.r, literal); err != nil {
+			return res, err
+		}
+		res.line += line[:i] + "{}"
+		res.literals = append(res.literals, literal)
+	}
+}
+
+// command sends a tagged command and returns the untagged responses that were
+// received before its completion.
+func (c *imapClient) command(cmd string) ([]imapResponse, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.nextTag++
+	tag := "b" + strconv.Itoa(c.nextTag)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		res, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(res.line, "* ") {
+			responses = append(responses, res)
+			continue
+		}
+		if !strings.HasPrefix(res.line, tag+" ") {
+			continue
+		}
+		status := strings.TrimPrefix(res.line, tag+" ")
+		if strings.HasPrefix(status, "OK") {
+			return responses, nil
+		}
+		parts := strings.SplitN(status, " ", 2)
+		msg := ""
+		if len(parts) > 1 {
+			msg = parts[1]
+		}
+		return nil, &imapStatusError{status: parts[0], msg: msg}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (c *imapClient) login(username, password string) error {
+	if _, err := c.command("LOGIN " + imapQuote(username) + " " + imapQuote(password)); err != nil {
+		return err
+	}
+	responses, err := c.command("CAPABILITY")
+	if err != nil {
+		return err
+	}
+	for _, res := range responses {
+		if strings.HasPrefix(res.line, "* CAPABILITY ") {
+			for _, capability := range strings.Fields(strings.TrimPrefix(res.line, "* CAPABILITY ")) {
+				c.caps[strings.ToUpper(capability)] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *imapClient) hasCapability(name string) bool {
+	_, exists := c.caps[name]
+	return exists
+}
+
+func (c *imapClient) selectMailbox(mailbox string) error {
+	_, err := c.command("SELECT " + imapQuote(mailbox))
+	return err
+}
+
+// search returns the UIDs of messages matching search criteria.
+func (c *imapClient) search(criteria string) ([]uint32, error) {
+	responses, err := c.command("UID SEARCH " + criteria)
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, res := range responses {
+		if !strings.HasPrefix(res.line, "* SEARCH") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(res.line, "* SEARCH")) {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse search result: %v", err)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids, nil
+}
+
+var errIMAPNoMessage = errors.New("message does not exist")
+
+// fetch returns the full contents of a message without setting its seen flag.
+func (c *imapClient) fetch(uid uint32) ([]byte, error) {
+	responses, err := c.command("UID FETCH " + strconv.FormatUint(uint64(uid), 10) + " (UID BODY.PEEK[])")
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range responses {
+		if strings.Contains(res.line, " FETCH ") && len(res.literals) > 0 {
+			return res.literals[0], nil
+		}
+	}
+	return nil, errIMAPNoMessage
+}
+
+func (c *imapClient) addFlags(uid uint32, flags string) error {
+	_, err := c.command("UID STORE " + strconv.FormatUint(uint64(uid), 10) + " +FLAGS.SILENT (" + flags + ")")
+	return err
+}
+
+// remove flags a message as deleted and expunges the mailbox.
+func (c *imapClient) remove(uid uint32) error {
+	if err := c.addFlags(uid, `\Deleted`); err != nil {
+		return err
+	}
+	_, err := c.command("EXPUNGE")
+	return err
+}
+
+// move transfers a message to another mailbox, using the MOVE extension when
+// the server supports it.
+func (c *imapClient) move(uid uint32, mailbox string) error {
+	uidStr := strconv.FormatUint(uint64(uid), 10)
+	if c.hasCapability("MOVE") {
+		_, err := c.command("UID MOVE " + uidStr + " " + imapQuote(mailbox))
+		return err
+	}
+	if _, err := c.command("UID COPY " + uidStr + " " + imapQuote(mailbox)); err != nil {
+		return err
+	}
+	return c.remove(uid)
+}
+
+func (c *imapClient) close() error {
+	c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+//------------------------------------------------------------------------------
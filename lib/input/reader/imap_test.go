@@ -0,0 +1,443 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type imapTestEmail struct {
+	uid   uint32
+	flags map[string]bool
+	raw   string
+}
+
+// imapTestServer is an in-memory IMAP server supporting the commands used by
+// the IMAP input.
+type imapTestServer struct {
+	mut       sync.Mutex
+	mailboxes map[string][]*imapTestEmail
+	nextUID   uint32
+	move      bool
+}
+
+func (s *imapTestServer) add(mailbox, raw string) {
+	s.mut.Lock()
+	s.nextUID++
+	s.mailboxes[mailbox] = append(s.mailboxes[mailbox], &imapTestEmail{
+		uid: s.nextUID, flags: map[string]bool{}, raw: raw,
+	})
+	s.mut.Unlock()
+}
+
+func (s *imapTestServer) summary() map[string][]string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	sum := map[string][]string{}
+	for name, emails := range s.mailboxes {
+		for _, e := range emails {
+			var flags []string
+			for f := range e.flags {
+				flags = append(flags, f)
+			}
+			sum[name] = append(sum[name], fmt.Sprintf("%v%v", e.uid, flags))
+		}
+	}
+	return sum
+}
+
+func (s *imapTestServer) find(mailbox string, uid uint32) *imapTestEmail {
+	for _, e := range s.mailboxes[mailbox] {
+		if e.uid == uid {
+			return e
+		}
+	}
+	return nil
+}
+
+func unquoteIMAP(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+func (s *imapTestServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK test server ready\r\n")
+
+	selected := ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.SplitN(strings.TrimRight(line, "\r\n"), " ", 2)
+		tag, cmd := fields[0], fields[1]
+
+		s.mut.Lock()
+		var out []string
+		status := "OK done"
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN "):
+			args := strings.Fields(strings.TrimPrefix(cmd, "LOGIN "))
+			if unquoteIMAP(args[0]) != "foo" || unquoteIMAP(args[1]) != "bar" {
+				status = "NO bad credentials"
+			}
+		case cmd == "CAPABILITY":
+			caps := "IMAP4rev1"
+			if s.move {
+				caps += " MOVE"
+			}
+			out = append(out, "* CAPABILITY "+caps)
+		case strings.HasPrefix(cmd, "SELECT "):
+			selected = unquoteIMAP(strings.TrimPrefix(cmd, "SELECT "))
+			out = append(out, fmt.Sprintf("* %v EXISTS", len(s.mailboxes[selected])))
+		case strings.HasPrefix(cmd, "UID SEARCH "):
+			res := "* SEARCH"
+			for _, e := range s.mailboxes[selected] {
+				if strings.HasSuffix(cmd, "ALL") || !e.flags[`\Seen`] {
+					res += " " + strconv.Itoa(int(e.uid))
+				}
+			}
+			out = append(out, res)
+		case strings.HasPrefix(cmd, "UID FETCH "):
+			uid, _ := strconv.Atoi(strings.Fields(cmd)[2])
+			if e := s.find(selected, uint32(uid)); e != nil {
+				out = append(out, fmt.Sprintf("* 1 FETCH (UID %v BODY[] {%v}\r\n%v)", uid, len(e.raw), e.raw))
+			}
+		case strings.HasPrefix(cmd, "UID STORE "):
+			args := strings.Fields(cmd)
+			uid, _ := strconv.Atoi(args[2])
+			if e := s.find(selected, uint32(uid)); e != nil {
+				e.flags[strings.Trim(args[4], "()")] = true
+			}
+		case strings.HasPrefix(cmd, "UID MOVE "), strings.HasPrefix(cmd, "UID COPY "):
+			args := strings.SplitN(cmd, " ", 4)
+			uid, _ := strconv.Atoi(args[2])
+			target := unquoteIMAP(args[3])
+			if e := s.find(selected, uint32(uid)); e != nil {
+				flags := map[string]bool{}
+				for k, v := range e.flags {
+					flags[k] = v
+				}
+				s.nextUID++
+				s.mailboxes[target] = append(s.mailboxes[target], &imapTestEmail{uid: s.nextUID, flags: flags, raw: e.raw})
+				if args[1] == "MOVE" {
+					e.flags[`\Deleted`] = true
+					cmd = "EXPUNGE"
+				}
+			}
+		case strings.HasPrefix(cmd, "LOGOUT"):
+			s.mut.Unlock()
+			fmt.Fprintf(conn, "* BYE\r\n%v OK done\r\n", tag)
+			return
+		}
+		if cmd == "EXPUNGE" {
+			var kept []*imapTestEmail
+			for _, e := range s.mailboxes[selected] {
+				if !e.flags[`\Deleted`] {
+					kept = append(kept, e)
+				}
+			}
+			s.mailboxes[selected] = kept
+		}
+		s.mut.Unlock()
+
+		for _, o := range out {
+			fmt.Fprint(conn, o+"\r\n")
+		}
+		fmt.Fprintf(conn, "%v %v\r\n", tag, status)
+	}
+}
+
+func imapTestListen(t *testing.T, s *imapTestServer) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return ln
+}
+
+const imapTestMultipart = "From: =?UTF-8?q?J=C3=B6rg?= <jorg@example.com>\r\n" +
+	"To: bar@example.com\r\n" +
+	"Subject: Report\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	"<p>hello</p>\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"hello =\r\nworld\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/csv; name=report.csv\r\n" +
+	"Content-Disposition: attachment; filename=report.csv\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"YSxi\r\nCjEsMgo=\r\n" +
+	"--outer--\r\n"
+
+func TestParseEmail(t *testing.T) {
+	msg, err := parseEmail([]byte(imapTestMultipart))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := [][]byte{[]byte("hello world"), []byte("a,b\n1,2\n")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong parts: %q != %q", act, exp)
+	}
+
+	exp := map[string]string{
+		"email_from":         "Jörg <jorg@example.com>",
+		"email_to":           "bar@example.com",
+		"email_subject":      "Report",
+		"email_message_id":   "<1@example.com>",
+		"email_mime_version": "1.0",
+		"email_content_type": "text/plain",
+	}
+	act := map[string]string{}
+	msg.Get(0).Metadata().Iter(func(k, v string) error {
+		act[k] = v
+		return nil
+	})
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+
+	meta := msg.Get(1).Metadata()
+	if exp, act := "report.csv", meta.Get("email_attachment_filename"); exp != act {
+		t.Errorf("Wrong attachment filename: %v != %v", act, exp)
+	}
+	if exp, act := "text/csv", meta.Get("email_content_type"); exp != act {
+		t.Errorf("Wrong attachment content type: %v != %v", act, exp)
+	}
+	if exp, act := "Report", meta.Get("email_subject"); exp != act {
+		t.Errorf("Wrong attachment subject: %v != %v", act, exp)
+	}
+}
+
+func readIMAPTest(t *testing.T, i *IMAP) (types.Message, AsyncAckFn) {
+	t.Helper()
+	ctx, done := context.WithTimeout(context.Background(), time.Second)
+	defer done()
+	msg, ackFn, err := i.ReadWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg, ackFn
+}
+
+func TestIMAPMarkSeen(t *testing.T) {
+	s := &imapTestServer{mailboxes: map[string][]*imapTestEmail{}}
+	s.add("INBOX", "Subject: first\r\n\r\nfoo\r\n")
+	s.add("INBOX", "Subject: second\r\n\r\nbar\r\n")
+	ln := imapTestListen(t, s)
+	defer ln.Close()
+
+	conf := NewIMAPConfig()
+	conf.Address = ln.Addr().String()
+	conf.TLS.Enabled = false
+	conf.Username = "foo"
+	conf.Password = "bar"
+	conf.PollInterval = "10ms"
+
+	i, err := NewIMAP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.CloseAsync()
+	if err = i.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ackFn := readIMAPTest(t, i)
+	if exp, act := "foo\r\n", string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong body: %v != %v", act, exp)
+	}
+	if exp, act := "1", msg.Get(0).Metadata().Get("imap_uid"); exp != act {
+		t.Errorf("Wrong uid: %v != %v", act, exp)
+	}
+	if exp, act := "INBOX", msg.Get(0).Metadata().Get("imap_mailbox"); exp != act {
+		t.Errorf("Wrong mailbox: %v != %v", act, exp)
+	}
+	if err = ackFn(context.Background(), response.NewError(fmt.Errorf("nope"))); err != nil {
+		t.Error(err)
+	}
+
+	msg, ackFn = readIMAPTest(t, i)
+	if exp, act := "second", msg.Get(0).Metadata().Get("email_subject"); exp != act {
+		t.Errorf("Wrong subject: %v != %v", act, exp)
+	}
+	if err = ackFn(context.Background(), response.NewAck()); err != nil {
+		t.Error(err)
+	}
+
+	// The rejected email is unseen and therefore read again.
+	<-time.After(time.Millisecond * 20)
+	for {
+		msg, ackFn, err = i.ReadWithContext(context.Background())
+		if err != types.ErrTimeout {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "first", msg.Get(0).Metadata().Get("email_subject"); exp != act {
+		t.Errorf("Wrong subject: %v != %v", act, exp)
+	}
+	if err = ackFn(context.Background(), response.NewAck()); err != nil {
+		t.Error(err)
+	}
+
+	if exp, act := map[string][]string{"INBOX": {`1[\Seen]`, `2[\Seen]`}}, s.summary(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong mailboxes: %v != %v", act, exp)
+	}
+}
+
+func TestIMAPMoveAndDelete(t *testing.T) {
+	for _, move := range []bool{true, false} {
+		s := &imapTestServer{mailboxes: map[string][]*imapTestEmail{}, move: move}
+		s.add("INBOX", "Subject: first\r\n\r\nfoo\r\n")
+		s.add("INBOX", "Subject: second\r\n\r\nbar\r\n")
+		ln := imapTestListen(t, s)
+
+		conf := NewIMAPConfig()
+		conf.Address = ln.Addr().String()
+		conf.TLS.Enabled = false
+		conf.Username = "foo"
+		conf.Password = "bar"
+		conf.MoveTo = "Done"
+
+		i, err := NewIMAP(conf, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = i.ConnectWithContext(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		_, ackFn := readIMAPTest(t, i)
+		if err = ackFn(context.Background(), response.NewAck()); err != nil {
+			t.Error(err)
+		}
+
+		exp := map[string][]string{"INBOX": {`2[]`}, "Done": {`3[\Seen]`}}
+		if act := s.summary(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong mailboxes with move %v: %v != %v", move, act, exp)
+		}
+		i.CloseAsync()
+		ln.Close()
+	}
+
+	s := &imapTestServer{mailboxes: map[string][]*imapTestEmail{}}
+	s.add("INBOX", "Subject: first\r\n\r\nfoo\r\n")
+	ln := imapTestListen(t, s)
+	defer ln.Close()
+
+	conf := NewIMAPConfig()
+	conf.Address = ln.Addr().String()
+	conf.TLS.Enabled = false
+	conf.Username = "foo"
+	conf.Password = "bar"
+	conf.DeleteOnFinish = true
+
+	i, err := NewIMAP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.CloseAsync()
+	if err = i.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, ackFn := readIMAPTest(t, i)
+	if err = ackFn(context.Background(), response.NewAck()); err != nil {
+		t.Error(err)
+	}
+	if act := s.summary(); len(act["INBOX"]) != 0 {
+		t.Errorf("Expected empty inbox: %v", act)
+	}
+}
+
+func TestIMAPBadLogin(t *testing.T) {
+	s := &imapTestServer{mailboxes: map[string][]*imapTestEmail{}}
+	ln := imapTestListen(t, s)
+	defer ln.Close()
+
+	conf := NewIMAPConfig()
+	conf.Address = ln.Addr().String()
+	conf.TLS.Enabled = false
+	conf.Username = "foo"
+	conf.Password = "nope"
+
+	i, err := NewIMAP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = i.ConnectWithContext(context.Background()); err == nil {
+		t.Error("Expected error from bad credentials")
+	}
+
+	conf.DeleteOnFinish = true
+	conf.MoveTo = "Done"
+	if _, err = NewIMAP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from both delete and move")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -32,8 +32,10 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
 	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
 	"github.com/Shopify/sarama"
 )
@@ -44,6 +46,7 @@ import (
 type KafkaConfig struct {
 	Addresses           []string `json:"addresses" yaml:"addresses"`
 	ClientID            string   `json:"client_id" yaml:"client_id"`
+	RackID              string   `json:"rack_id" yaml:"rack_id"`
 	ConsumerGroup       string   `json:"consumer_group" yaml:"consumer_group"`
 	CommitPeriod        string   `json:"commit_period" yaml:"commit_period"`
 	MaxProcessingPeriod string   `json:"max_processing_period" yaml:"max_processing_period"`
@@ -66,6 +69,7 @@ func NewKafkaConfig() KafkaConfig {
 	return KafkaConfig{
 		Addresses:           []string{"localhost:9092"},
 		ClientID:            "benthos_kafka_input",
+		RackID:              "",
 		ConsumerGroup:       "benthos_consumer_group",
 		CommitPeriod:        "1s",
 		MaxProcessingPeriod: "100ms",
@@ -76,6 +80,7 @@ func NewKafkaConfig() KafkaConfig {
 		TargetVersion:       sarama.V1_0_0_0.String(),
 		MaxBatchCount:       1,
 		TLS:                 btls.NewConfig(),
+		SASL:                sasl.NewConfig(),
 		Batching:            batchConf,
 	}
 }
@@ -89,7 +94,8 @@ type Kafka struct {
 	partConsumer sarama.PartitionConsumer
 	version      sarama.KafkaVersion
 
-	tlsConf *tls.Config
+	tlsConf  *tls.Config
+	saslAuth *sasl.Auth
 
 	sMut sync.Mutex
 
@@ -129,9 +135,15 @@ func NewKafka(
 	}
 
 	var err error
+	if k.saslAuth, err = conf.SASL.Get(); err != nil {
+		return nil, err
+	}
 	if k.version, err = sarama.ParseKafkaVersion(conf.TargetVersion); err != nil {
 		return nil, err
 	}
+	if len(conf.RackID) > 0 && !k.version.IsAtLeast(sarama.V2_4_0_0) {
+		return nil, fmt.Errorf("a rack_id requires a target_version of at least %v", sarama.V2_4_0_0)
+	}
 
 	for _, addr := range conf.Addresses {
 		for _, splitAddr := range strings.Split(addr, ",") {
@@ -216,6 +228,7 @@ func (k *Kafka) ConnectWithContext(ctx context.Context) error {
 	config := sarama.NewConfig()
 	config.Version = k.version
 	config.ClientID = k.conf.ClientID
+	config.RackID = k.conf.RackID
 	config.Net.DialTimeout = time.Second
 	config.Consumer.Return.Errors = true
 	config.Consumer.MaxProcessingTime = k.maxProcPeriod
@@ -224,11 +237,7 @@ func (k *Kafka) ConnectWithContext(ctx context.Context) error {
 	if k.conf.TLS.Enabled {
 		config.Net.TLS.Config = k.tlsConf
 	}
-	if k.conf.SASL.Enabled {
-		config.Net.SASL.Enable = true
-		config.Net.SASL.User = k.conf.SASL.User
-		config.Net.SASL.Password = k.conf.SASL.Password
-	}
+	k.saslAuth.Apply(config)
 
 	k.client, err = sarama.NewClient(k.addresses, config)
 	if err != nil {
@@ -361,6 +370,7 @@ func (k *Kafka) ReadNextWithContext(ctx context.Context) (types.Message, error)
 	if msg.Len() == 0 {
 		return nil, types.ErrTimeout
 	}
+	tracing.InitSpansFromParentMetadata("input_kafka", msg)
 	return msg, nil
 }
 
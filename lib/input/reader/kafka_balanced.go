@@ -34,8 +34,10 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
 	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
 	"github.com/Shopify/sarama"
 )
@@ -79,11 +81,7 @@ type KafkaBalancedConfig struct {
 }
 
 // SASLConfig contains configuration for SASL based authentication.
-type SASLConfig struct {
-	Enabled  bool   `json:"enabled" yaml:"enabled"`
-	User     string `json:"user" yaml:"user"`
-	Password string `json:"password" yaml:"password"`
-}
+type SASLConfig = sasl.Config
 
 // NewKafkaBalancedConfig creates a new KafkaBalancedConfig with default values.
 // TODO: V4 Remove this unused implementation.
@@ -104,6 +102,7 @@ func NewKafkaBalancedConfig() KafkaBalancedConfig {
 		Batching:            batchConf,
 		MaxBatchCount:       1,
 		TLS:                 btls.NewConfig(),
+		SASL:                sasl.NewConfig(),
 	}
 }
 
@@ -119,6 +118,7 @@ type consumerMessage struct {
 type KafkaBalanced struct {
 	version   sarama.KafkaVersion
 	tlsConf   *tls.Config
+	saslAuth  *sasl.Auth
 	addresses []string
 	topics    []string
 
@@ -212,6 +212,9 @@ func NewKafkaBalanced(
 	if k.version, err = sarama.ParseKafkaVersion(conf.TargetVersion); err != nil {
 		return nil, err
 	}
+	if k.saslAuth, err = conf.SASL.Get(); err != nil {
+		return nil, err
+	}
 	return &k, nil
 }
 
@@ -321,11 +324,7 @@ func (k *KafkaBalanced) Connect() error {
 		config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	}
 
-	if k.conf.SASL.Enabled {
-		config.Net.SASL.Enable = true
-		config.Net.SASL.User = k.conf.SASL.User
-		config.Net.SASL.Password = k.conf.SASL.Password
-	}
+	k.saslAuth.Apply(config)
 
 	// Start a new consumer group
 	group, err := sarama.NewConsumerGroup(k.addresses, k.conf.ConsumerGroup, config)
@@ -455,6 +454,7 @@ batchLoop:
 	if msg.Len() == 0 {
 		return nil, types.ErrTimeout
 	}
+	tracing.InitSpansFromParentMetadata("input_kafka_balanced", msg)
 	return msg, nil
 }
 
@@ -33,8 +33,10 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
 	"github.com/Shopify/sarama"
 )
 
@@ -50,6 +52,7 @@ type asyncMessage struct {
 type KafkaCG struct {
 	version   sarama.KafkaVersion
 	tlsConf   *tls.Config
+	saslAuth  *sasl.Auth
 	addresses []string
 	topics    []string
 
@@ -139,6 +142,9 @@ func NewKafkaCG(
 	if k.version, err = sarama.ParseKafkaVersion(conf.TargetVersion); err != nil {
 		return nil, err
 	}
+	if k.saslAuth, err = conf.SASL.Get(); err != nil {
+		return nil, err
+	}
 	return &k, nil
 }
 
@@ -317,11 +323,7 @@ func (k *KafkaCG) ConnectWithContext(ctx context.Context) error {
 		config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	}
 
-	if k.conf.SASL.Enabled {
-		config.Net.SASL.Enable = true
-		config.Net.SASL.User = k.conf.SASL.User
-		config.Net.SASL.Password = k.conf.SASL.Password
-	}
+	k.saslAuth.Apply(config)
 
 	// Start a new consumer group
 	group, err := sarama.NewConsumerGroup(k.addresses, k.conf.ConsumerGroup, config)
@@ -406,6 +408,7 @@ func (k *KafkaCG) ReadWithContext(ctx context.Context) (types.Message, AsyncAckF
 		if !open {
 			return nil, nil, types.ErrNotConnected
 		}
+		tracing.InitSpansFromParentMetadata("input_kafka_balanced", m.msg)
 		return m.msg, m.ackFn, nil
 	case <-ctx.Done():
 	}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func TestKafkaRackIDVersion(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.RackID = "zone-a"
+	if _, err := NewKafka(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from rack id with old target version")
+	}
+
+	conf.TargetVersion = "2.4.0"
+	if _, err := NewKafka(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKafkaBadSASL(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.SASL.Enabled = true
+	conf.SASL.Mechanism = "nope"
+	if _, err := NewKafka(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad SASL mechanism")
+	}
+
+	cgConf := NewKafkaBalancedConfig()
+	cgConf.SASL = conf.SASL
+	if _, err := NewKafkaCG(cgConf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad SASL mechanism")
+	}
+	if _, err := NewKafkaBalanced(cgConf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad SASL mechanism")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,685 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+)
+
+//------------------------------------------------------------------------------
+
+// KinesisEnhancedFanOutConfig contains configuration for consuming a Kinesis
+// stream with enhanced fan-out.
+type KinesisEnhancedFanOutConfig struct {
+	Enabled      bool   `json:"enabled" yaml:"enabled"`
+	ConsumerName string `json:"consumer_name" yaml:"consumer_name"`
+}
+
+// NewKinesisEnhancedFanOutConfig creates a new KinesisEnhancedFanOutConfig
+// with default values.
+func NewKinesisEnhancedFanOutConfig() KinesisEnhancedFanOutConfig {
+	return KinesisEnhancedFanOutConfig{
+		Enabled:      false,
+		ConsumerName: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// kinesisEFOShardEnd is the sequence checkpointed for shards that have been
+// consumed in their entirety, which allows their children to be consumed.
+const kinesisEFOShardEnd = "SHARD_END"
+
+// kinesisEFORefreshPeriod is the period at which the shards of a stream are
+// listed in order to find shards that are ready to be consumed.
+var kinesisEFORefreshPeriod = time.Minute
+
+// kinesisEFORetryPeriod is the period to wait before retrying a failed shard
+// subscription.
+var kinesisEFORetryPeriod = time.Second
+
+type kinesisEFOPending struct {
+	sequence string
+	acked    bool
+}
+
+type kinesisEFOShard struct {
+	id string
+
+	pending   []*kinesisEFOPending
+	acked     string
+	committed string
+	ended     bool
+	finished  bool
+}
+
+type kinesisEFOBatch struct {
+	shard   *kinesisEFOShard
+	pending *kinesisEFOPending
+	msg     types.Message
+}
+
+// KinesisEFO is a benthos reader.Async implementation that consumes all shards
+// of an Amazon Kinesis stream using enhanced fan-out subscriptions.
+type KinesisEFO struct {
+	conf KinesisConfig
+
+	consumerName string
+	namespace    string
+	commitPeriod time.Duration
+	timeout      time.Duration
+
+	kinesis     kinesisiface.KinesisAPI
+	dynamo      dynamodbiface.DynamoDBAPI
+	consumerARN string
+
+	cMut      sync.Mutex
+	connected bool
+
+	mut       sync.Mutex
+	shards    map[string]*kinesisEFOShard
+	batchChan chan kinesisEFOBatch
+	shardDone chan struct{}
+
+	ctx        context.Context
+	done       func()
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+	closedChan chan struct{}
+
+	log   log.Modular
+	stats metrics.Type
+
+	mShardsActive metrics.StatGauge
+	mShardsEnded  metrics.StatCounter
+	mSubscribeErr metrics.StatCounter
+	mCommitErr    metrics.StatCounter
+}
+
+// NewKinesisEFO creates a new Amazon Kinesis enhanced fan-out reader.Async.
+func NewKinesisEFO(
+	conf KinesisConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*KinesisEFO, error) {
+	if len(conf.Stream) == 0 {
+		return nil, errors.New("a stream must be specified")
+	}
+	var timeout, commitPeriod time.Duration
+	if tout := conf.Timeout; len(tout) > 0 {
+		var err error
+		if timeout, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout string: %v", err)
+		}
+	}
+	if tout := conf.CommitPeriod; len(tout) > 0 {
+		var err error
+		if commitPeriod, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse commit period string: %v", err)
+		}
+	}
+	consumerName := conf.EnhancedFanOut.ConsumerName
+	if len(consumerName) == 0 {
+		consumerName = conf.ClientID
+	}
+	ctx, done := context.WithCancel(context.Background())
+	return &KinesisEFO{
+		conf:         conf,
+		consumerName: consumerName,
+		namespace:    fmt.Sprintf("%v-%v", conf.ClientID, conf.Stream),
+		commitPeriod: commitPeriod,
+		timeout:      timeout,
+		shards:       map[string]*kinesisEFOShard{},
+		batchChan:    make(chan kinesisEFOBatch),
+		shardDone:    make(chan struct{}, 1),
+		ctx:          ctx,
+		done:         done,
+		closedChan:   make(chan struct{}),
+		log:          log,
+		stats:        stats,
+
+		mShardsActive: stats.GetGauge("shards.active"),
+		mShardsEnded:  stats.GetCounter("shards.ended"),
+		mSubscribeErr: stats.GetCounter("subscribe.error"),
+		mCommitErr:    stats.GetCounter("commit.error"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext registers the stream consumer, waits for it to become
+// active and begins consuming the shards of the stream.
+func (k *KinesisEFO) ConnectWithContext(ctx context.Context) error {
+	k.cMut.Lock()
+	defer k.cMut.Unlock()
+
+	if k.connected {
+		return nil
+	}
+	if k.ctx.Err() != nil {
+		return types.ErrTypeClosed
+	}
+
+	if k.kinesis == nil {
+		sess, err := k.conf.GetSession()
+		if err != nil {
+			return err
+		}
+		k.kinesis = kinesis.New(sess)
+		if len(k.conf.DynamoDBTable) > 0 {
+			k.dynamo = dynamodb.New(sess)
+		}
+	}
+
+	arn, err := k.registerConsumer(ctx)
+	if err != nil {
+		return err
+	}
+	k.consumerARN = arn
+
+	k.wg.Add(1)
+	go k.loop()
+
+	k.connected = true
+	k.log.Infof("Receiving Amazon Kinesis messages from stream %v using enhanced fan-out consumer: %v\n", k.conf.Stream, k.consumerName)
+	return nil
+}
+
+// registerConsumer obtains the ARN of the stream consumer, registering it if
+// it does not already exist, and waits for the consumer to become active.
+func (k *KinesisEFO) registerConsumer(ctx context.Context) (string, error) {
+	summary, err := k.kinesis.DescribeStreamSummaryWithContext(ctx, &kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(k.conf.Stream),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe stream: %v", err)
+	}
+	streamARN := summary.StreamDescriptionSummary.StreamARN
+
+	for {
+		desc, err := k.kinesis.DescribeStreamConsumerWithContext(ctx, &kinesis.DescribeStreamConsumerInput{
+			ConsumerName: aws.String(k.consumerName),
+			StreamARN:    streamARN,
+		})
+		if err != nil {
+			aerr, ok := err.(awserr.Error)
+			if !ok || aerr.Code() != kinesis.ErrCodeResourceNotFoundException {
+				return "", fmt.Errorf("failed to describe stream consumer: %v", err)
+			}
+			k.log.Infof("Registering enhanced fan-out consumer: %v\n", k.consumerName)
+			if _, err = k.kinesis.RegisterStreamConsumerWithContext(ctx, &kinesis.RegisterStreamConsumerInput{
+				ConsumerName: aws.String(k.consumerName),
+				StreamARN:    streamARN,
+			}); err != nil {
+				if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != kinesis.ErrCodeResourceInUseException {
+					return "", fmt.Errorf("failed to register stream consumer: %v", err)
+				}
+			}
+		} else if status := aws.StringValue(desc.ConsumerDescription.ConsumerStatus); status == kinesis.ConsumerStatusActive {
+			return aws.StringValue(desc.ConsumerDescription.ConsumerARN), nil
+		} else if status == kinesis.ConsumerStatusDeleting {
+			return "", fmt.Errorf("stream consumer %v is being deleted", k.consumerName)
+		}
+
+		select {
+		case <-time.After(kinesisEFORetryPeriod):
+		case <-ctx.Done():
+			return "", types.ErrTimeout
+		case <-k.ctx.Done():
+			return "", types.ErrTypeClosed
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (k *KinesisEFO) getCheckpoint(shardID string) (string, error) {
+	if k.dynamo == nil {
+		return "", nil
+	}
+	ctx, done := context.WithTimeout(k.ctx, k.timeout)
+	defer done()
+	resp, err := k.dynamo.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(k.conf.DynamoDBTable),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"namespace": {
+				S: aws.String(k.namespace),
+			},
+			"shard_id": {
+				S: aws.String(shardID),
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if seqAttr := resp.Item["sequence"]; seqAttr != nil && seqAttr.S != nil {
+		return *seqAttr.S, nil
+	}
+	return "", nil
+}
+
+func (k *KinesisEFO) setCheckpoint(shardID, sequence string) error {
+	if k.dynamo == nil {
+		return nil
+	}
+	ctx, done := context.WithTimeout(context.Background(), k.timeout)
+	defer done()
+	_, err := k.dynamo.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(k.conf.DynamoDBTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"namespace": {
+				S: aws.String(k.namespace),
+			},
+			"shard_id": {
+				S: aws.String(shardID),
+			},
+			"sequence": {
+				S: aws.String(sequence),
+			},
+		},
+	})
+	return err
+}
+
+// commit checkpoints the latest acknowledged sequence of each shard that has
+// progressed since the last commit.
+func (k *KinesisEFO) commit() {
+	k.mut.Lock()
+	toCommit := map[string]string{}
+	for id, s := range k.shards {
+		if !s.finished && s.acked != s.committed {
+			toCommit[id] = s.acked
+		}
+	}
+	k.mut.Unlock()
+
+	for id, seq := range toCommit {
+		if err := k.setCheckpoint(id, seq); err != nil {
+			k.mCommitErr.Incr(1)
+			k.log.Errorf("Failed to checkpoint shard %v: %v\n", id, err)
+			continue
+		}
+		k.mut.Lock()
+		if s := k.shards[id]; !s.finished {
+			s.committed = seq
+		}
+		k.mut.Unlock()
+	}
+}
+
+// finishShard checkpoints a shard as having been fully consumed, which allows
+// its children to be consumed.
+func (k *KinesisEFO) finishShard(s *kinesisEFOShard) {
+	if err := k.setCheckpoint(s.id, kinesisEFOShardEnd); err != nil {
+		k.mCommitErr.Incr(1)
+		k.log.Errorf("Failed to checkpoint end of shard %v: %v\n", s.id, err)
+	}
+	k.mut.Lock()
+	s.finished = true
+	s.committed = kinesisEFOShardEnd
+	k.mut.Unlock()
+
+	k.mShardsActive.Decr(1)
+	k.mShardsEnded.Incr(1)
+	k.log.Infof("Finished consuming shard %v\n", s.id)
+
+	select {
+	case k.shardDone <- struct{}{}:
+	default:
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// refreshShards lists the shards of the stream and begins consuming any shard
+// that isn't already being consumed, has not been consumed entirely, and has
+// no parents that are still being consumed.
+func (k *KinesisEFO) refreshShards() error {
+	var shards []*kinesis.Shard
+	input := &kinesis.ListShardsInput{
+		StreamName: aws.String(k.conf.Stream),
+	}
+	for {
+		ctx, done := context.WithTimeout(k.ctx, k.timeout)
+		res, err := k.kinesis.ListShardsWithContext(ctx, input)
+		done()
+		if err != nil {
+			return err
+		}
+		shards = append(shards, res.Shards...)
+		if res.NextToken == nil {
+			break
+		}
+		input = &kinesis.ListShardsInput{
+			NextToken: res.NextToken,
+		}
+	}
+
+	listed := map[string]struct{}{}
+	for _, s := range shards {
+		listed[aws.StringValue(s.ShardId)] = struct{}{}
+	}
+
+	checkpoints := map[string]string{}
+	for _, s := range shards {
+		id := aws.StringValue(s.ShardId)
+		k.mut.Lock()
+		_, exists := k.shards[id]
+		k.mut.Unlock()
+		if exists {
+			continue
+		}
+		seq, err := k.getCheckpoint(id)
+		if err != nil {
+			return fmt.Errorf("failed to obtain checkpoint of shard %v: %v", id, err)
+		}
+		if seq == kinesisEFOShardEnd {
+			k.mut.Lock()
+			k.shards[id] = &kinesisEFOShard{id: id, ended: true, finished: true}
+			k.mut.Unlock()
+			continue
+		}
+		checkpoints[id] = seq
+	}
+
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	parentFinished := func(parent *string) bool {
+		if parent == nil {
+			return true
+		}
+		if _, exists := listed[*parent]; !exists {
+			// The parent has expired beyond the retention period of the
+			// stream.
+			return true
+		}
+		p, exists := k.shards[*parent]
+		return exists && p.finished
+	}
+
+	for _, s := range shards {
+		id := aws.StringValue(s.ShardId)
+		seq, pending := checkpoints[id]
+		if !pending {
+			continue
+		}
+		if !parentFinished(s.ParentShardId) || !parentFinished(s.AdjacentParentShardId) {
+			continue
+		}
+
+		shard := &kinesisEFOShard{
+			id:        id,
+			acked:     seq,
+			committed: seq,
+		}
+		k.shards[id] = shard
+		k.mShardsActive.Incr(1)
+
+		k.wg.Add(1)
+		go k.consumeShard(shard, seq)
+	}
+	return nil
+}
+
+func (k *KinesisEFO) loop() {
+	defer k.wg.Done()
+
+	refreshTicker := time.NewTicker(kinesisEFORefreshPeriod)
+	defer refreshTicker.Stop()
+
+	var commitChan <-chan time.Time
+	if k.dynamo != nil && k.commitPeriod > 0 {
+		commitTicker := time.NewTicker(k.commitPeriod)
+		defer commitTicker.Stop()
+		commitChan = commitTicker.C
+	}
+
+	refresh := func() {
+		if err := k.refreshShards(); err != nil && k.ctx.Err() == nil {
+			k.log.Errorf("Failed to refresh shards of stream %v: %v\n", k.conf.Stream, err)
+		}
+	}
+
+	refresh()
+	for {
+		select {
+		case <-refreshTicker.C:
+			refresh()
+		case <-k.shardDone:
+			refresh()
+		case <-commitChan:
+			k.commit()
+		case <-k.ctx.Done():
+			k.commit()
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// consumeShard subscribes to a shard and forwards the records received until
+// either the end of the shard is reached or the reader is closed.
+// Subscriptions expire after five minutes, at which point they are renewed
+// from the last sequence received.
+func (k *KinesisEFO) consumeShard(shard *kinesisEFOShard, sequence string) {
+	defer k.wg.Done()
+
+	for k.ctx.Err() == nil {
+		position := &kinesis.StartingPosition{}
+		if len(sequence) > 0 {
+			position.SetType(kinesis.ShardIteratorTypeAfterSequenceNumber)
+			position.SetSequenceNumber(sequence)
+		} else if k.conf.StartFromOldest {
+			position.SetType(kinesis.ShardIteratorTypeTrimHorizon)
+		} else {
+			position.SetType(kinesis.ShardIteratorTypeLatest)
+		}
+
+		res, err := k.kinesis.SubscribeToShardWithContext(k.ctx, &kinesis.SubscribeToShardInput{
+			ConsumerARN:      aws.String(k.consumerARN),
+			ShardId:          aws.String(shard.id),
+			StartingPosition: position,
+		})
+		if err != nil {
+			if k.ctx.Err() != nil {
+				return
+			}
+			k.mSubscribeErr.Incr(1)
+			k.log.Errorf("Failed to subscribe to shard %v: %v\n", shard.id, err)
+			select {
+			case <-time.After(kinesisEFORetryPeriod):
+			case <-k.ctx.Done():
+			}
+			continue
+		}
+
+		var ended bool
+		if sequence, ended, err = k.readEvents(shard, res.EventStream, sequence); err != nil && k.ctx.Err() == nil {
+			k.mSubscribeErr.Incr(1)
+			k.log.Errorf("Subscription to shard %v failed: %v\n", shard.id, err)
+		}
+		if ended {
+			k.mut.Lock()
+			shard.ended = true
+			finished := len(shard.pending) == 0
+			k.mut.Unlock()
+			if finished {
+				k.finishShard(shard)
+			}
+			return
+		}
+	}
+}
+
+// readEvents reads the events of a subscription and returns the sequence to
+// resume from, and whether the end of the shard was reached.
+func (k *KinesisEFO) readEvents(
+	shard *kinesisEFOShard,
+	stream *kinesis.SubscribeToShardEventStream,
+	sequence string,
+) (string, bool, error) {
+	defer stream.Close()
+
+	for {
+		var e kinesis.SubscribeToShardEventStreamEvent
+		var open bool
+		select {
+		case e, open = <-stream.Events():
+		case <-k.ctx.Done():
+			return sequence, false, nil
+		}
+		if !open {
+			return sequence, false, stream.Err()
+		}
+
+		event, ok := e.(*kinesis.SubscribeToShardEvent)
+		if !ok {
+			continue
+		}
+
+		msg := message.New(nil)
+		var lastSequence string
+		for _, rec := range event.Records {
+			if rec.SequenceNumber != nil {
+				lastSequence = *rec.SequenceNumber
+			}
+			if rec.Data == nil {
+				continue
+			}
+			part := message.NewPart(rec.Data)
+			part.Metadata().Set("kinesis_shard", shard.id)
+			part.Metadata().Set("kinesis_stream", k.conf.Stream)
+			msg.Append(part)
+		}
+
+		if len(lastSequence) > 0 {
+			pending := &kinesisEFOPending{sequence: lastSequence}
+			k.mut.Lock()
+			shard.pending = append(shard.pending, pending)
+			k.mut.Unlock()
+
+			if msg.Len() > 0 {
+				select {
+				case k.batchChan <- kinesisEFOBatch{shard: shard, pending: pending, msg: msg}:
+				case <-k.ctx.Done():
+					return sequence, false, nil
+				}
+			} else {
+				k.ack(shard, pending)
+			}
+			sequence = lastSequence
+		}
+
+		if event.ContinuationSequenceNumber == nil {
+			return sequence, true, nil
+		}
+		sequence = *event.ContinuationSequenceNumber
+	}
+}
+
+// ack marks a batch of records as acknowledged, and moves the acknowledged
+// sequence of the shard forward to the last batch that has no unacknowledged
+// predecessors.
+func (k *KinesisEFO) ack(shard *kinesisEFOShard, pending *kinesisEFOPending) {
+	k.mut.Lock()
+	pending.acked = true
+	for len(shard.pending) > 0 && shard.pending[0].acked {
+		shard.acked = shard.pending[0].sequence
+		shard.pending = shard.pending[1:]
+	}
+	finished := shard.ended && !shard.finished && len(shard.pending) == 0
+	k.mut.Unlock()
+
+	if finished {
+		k.finishShard(shard)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ReadWithContext attempts to read a new message from the shards of the
+// stream.
+func (k *KinesisEFO) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	k.cMut.Lock()
+	connected := k.connected
+	k.cMut.Unlock()
+	if !connected {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	var b kinesisEFOBatch
+	select {
+	case b = <-k.batchChan:
+	case <-ctx.Done():
+		return nil, nil, types.ErrTimeout
+	case <-k.ctx.Done():
+		return nil, nil, types.ErrTypeClosed
+	}
+
+	return b.msg, func(rctx context.Context, res types.Response) error {
+		if res.Error() == nil {
+			k.ack(b.shard, b.pending)
+		}
+		return nil
+	}, nil
+}
+
+// CloseAsync begins cleaning up resources used by this reader asynchronously.
+func (k *KinesisEFO) CloseAsync() {
+	k.closeOnce.Do(func() {
+		k.done()
+		go func() {
+			k.wg.Wait()
+			close(k.closedChan)
+		}()
+	})
+}
+
+// WaitForClose will block until either the reader is closed or a specified
+// timeout occurs.
+func (k *KinesisEFO) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-k.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,389 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+)
+
+//------------------------------------------------------------------------------
+
+type mockEFOEventReader struct {
+	events    chan kinesis.SubscribeToShardEventStreamEvent
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (m *mockEFOEventReader) Events() <-chan kinesis.SubscribeToShardEventStreamEvent {
+	return m.events
+}
+
+func (m *mockEFOEventReader) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.closed)
+	})
+	return nil
+}
+
+func (m *mockEFOEventReader) Err() error {
+	return nil
+}
+
+type mockEFOCloser struct{}
+
+func (mockEFOCloser) Close() error {
+	return nil
+}
+
+type mockEFOKinesis struct {
+	kinesisiface.KinesisAPI
+
+	mut           sync.Mutex
+	shards        []*kinesis.Shard
+	events        map[string][]*kinesis.SubscribeToShardEvent
+	consumerState string
+	subscriptions []*kinesis.SubscribeToShardInput
+}
+
+func (m *mockEFOKinesis) DescribeStreamSummaryWithContext(aws.Context, *kinesis.DescribeStreamSummaryInput, ...request.Option) (*kinesis.DescribeStreamSummaryOutput, error) {
+	return &kinesis.DescribeStreamSummaryOutput{
+		StreamDescriptionSummary: &kinesis.StreamDescriptionSummary{
+			StreamARN: aws.String("stream-arn"),
+		},
+	}, nil
+}
+
+func (m *mockEFOKinesis) DescribeStreamConsumerWithContext(aws.Context, *kinesis.DescribeStreamConsumerInput, ...request.Option) (*kinesis.DescribeStreamConsumerOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	state := m.consumerState
+	switch state {
+	case "":
+		return nil, awserr.New(kinesis.ErrCodeResourceNotFoundException, "not found", nil)
+	case kinesis.ConsumerStatusCreating:
+		m.consumerState = kinesis.ConsumerStatusActive
+	}
+	return &kinesis.DescribeStreamConsumerOutput{
+		ConsumerDescription: &kinesis.ConsumerDescription{
+			ConsumerARN:    aws.String("consumer-arn"),
+			ConsumerStatus: aws.String(state),
+		},
+	}, nil
+}
+
+func (m *mockEFOKinesis) RegisterStreamConsumerWithContext(aws.Context, *kinesis.RegisterStreamConsumerInput, ...request.Option) (*kinesis.RegisterStreamConsumerOutput, error) {
+	m.mut.Lock()
+	m.consumerState = kinesis.ConsumerStatusCreating
+	m.mut.Unlock()
+	return &kinesis.RegisterStreamConsumerOutput{}, nil
+}
+
+func (m *mockEFOKinesis) ListShardsWithContext(aws.Context, *kinesis.ListShardsInput, ...request.Option) (*kinesis.ListShardsOutput, error) {
+	return &kinesis.ListShardsOutput{Shards: m.shards}, nil
+}
+
+func (m *mockEFOKinesis) SubscribeToShardWithContext(ctx aws.Context, input *kinesis.SubscribeToShardInput, opts ...request.Option) (*kinesis.SubscribeToShardOutput, error) {
+	m.mut.Lock()
+	m.subscriptions = append(m.subscriptions, input)
+	events := m.events[*input.ShardId]
+	delete(m.events, *input.ShardId)
+	m.mut.Unlock()
+
+	r := &mockEFOEventReader{
+		events: make(chan kinesis.SubscribeToShardEventStreamEvent),
+		closed: make(chan struct{}),
+	}
+	go func() {
+		for _, e := range events {
+			select {
+			case r.events <- e:
+			case <-r.closed:
+				return
+			}
+		}
+		// Remaining subscriptions stay open until closed.
+		if len(events) == 0 || events[len(events)-1].ContinuationSequenceNumber != nil {
+			<-r.closed
+		}
+		close(r.events)
+	}()
+
+	return &kinesis.SubscribeToShardOutput{
+		EventStream: &kinesis.SubscribeToShardEventStream{
+			Reader:       r,
+			StreamCloser: mockEFOCloser{},
+		},
+	}, nil
+}
+
+func (m *mockEFOKinesis) subscribedShards() map[string]*kinesis.StartingPosition {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	shards := map[string]*kinesis.StartingPosition{}
+	for _, s := range m.subscriptions {
+		shards[*s.ShardId] = s.StartingPosition
+	}
+	return shards
+}
+
+type mockEFODynamo struct {
+	dynamodbiface.DynamoDBAPI
+
+	mut   sync.Mutex
+	items map[string]string
+}
+
+func (m *mockEFODynamo) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	out := &dynamodb.GetItemOutput{}
+	if seq, exists := m.items[*input.Key["shard_id"].S]; exists {
+		out.Item = map[string]*dynamodb.AttributeValue{
+			"sequence": {S: aws.String(seq)},
+		}
+	}
+	return out, nil
+}
+
+func (m *mockEFODynamo) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.mut.Lock()
+	m.items[*input.Item["shard_id"].S] = *input.Item["sequence"].S
+	m.mut.Unlock()
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockEFODynamo) checkpoint(shardID string) string {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.items[shardID]
+}
+
+func newMockEFOEvent(cont string, records ...string) *kinesis.SubscribeToShardEvent {
+	e := &kinesis.SubscribeToShardEvent{}
+	if len(cont) > 0 {
+		e.ContinuationSequenceNumber = aws.String(cont)
+	}
+	for _, r := range records {
+		e.Records = append(e.Records, &kinesis.Record{
+			Data:           []byte(r),
+			SequenceNumber: aws.String(r),
+		})
+	}
+	return e
+}
+
+func newTestKinesisEFO(t *testing.T, k *mockEFOKinesis, d *mockEFODynamo) *KinesisEFO {
+	t.Helper()
+
+	conf := NewKinesisConfig()
+	conf.Stream = "foo"
+	conf.DynamoDBTable = "bar"
+	conf.CommitPeriod = "1h"
+	conf.EnhancedFanOut.Enabled = true
+
+	r, err := NewKinesisEFO(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.kinesis = k
+	r.dynamo = d
+	return r
+}
+
+//------------------------------------------------------------------------------
+
+func TestKinesisEFOResharding(t *testing.T) {
+	kinesisEFORetryPeriod = time.Millisecond
+
+	k := &mockEFOKinesis{
+		shards: []*kinesis.Shard{
+			{ShardId: aws.String("shard-0")},
+			{ShardId: aws.String("shard-1"), ParentShardId: aws.String("shard-0")},
+			{ShardId: aws.String("shard-2"), ParentShardId: aws.String("shard-0")},
+		},
+		events: map[string][]*kinesis.SubscribeToShardEvent{
+			"shard-0": {
+				newMockEFOEvent("0b", "0a"),
+				newMockEFOEvent("", "0b"),
+			},
+			"shard-1": {newMockEFOEvent("1b", "1a")},
+			"shard-2": {newMockEFOEvent("2b", "2a")},
+		},
+	}
+	d := &mockEFODynamo{items: map[string]string{}}
+
+	r := newTestKinesisEFO(t, k, d)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	if err := r.ConnectWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "consumer-arn", r.consumerARN; exp != act {
+		t.Errorf("Wrong consumer ARN: %v != %v", act, exp)
+	}
+
+	var acks []AsyncAckFn
+	for _, exp := range []string{"0a", "0b"} {
+		msg, ackFn, err := r.ReadWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := string(msg.Get(0).Get()); exp != act {
+			t.Errorf("Wrong message: %v != %v", act, exp)
+		}
+		if exp, act := "shard-0", msg.Get(0).Metadata().Get("kinesis_shard"); exp != act {
+			t.Errorf("Wrong shard metadata: %v != %v", act, exp)
+		}
+		acks = append(acks, ackFn)
+	}
+
+	if _, exists := k.subscribedShards()["shard-1"]; exists {
+		t.Error("Child shard consumed before its parent was finished")
+	}
+
+	for i := len(acks) - 1; i >= 0; i-- {
+		if err := acks[i](ctx, response.NewAck()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	remaining := map[string]struct{}{"1a": {}, "2a": {}}
+	for len(remaining) > 0 {
+		msg, ackFn, err := r.ReadWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act := string(msg.Get(0).Get())
+		if _, exists := remaining[act]; !exists {
+			t.Errorf("Unexpected message: %v", act)
+		}
+		delete(remaining, act)
+		if err = ackFn(ctx, response.NewAck()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Child shards are only consumed once the end of the parent has been
+	// checkpointed.
+	if exp, act := kinesisEFOShardEnd, d.checkpoint("shard-0"); exp != act {
+		t.Errorf("Wrong checkpoint: %v != %v", act, exp)
+	}
+
+	r.CloseAsync()
+	if err := r.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "1a", d.checkpoint("shard-1"); exp != act {
+		t.Errorf("Wrong checkpoint: %v != %v", act, exp)
+	}
+	if exp, act := "2a", d.checkpoint("shard-2"); exp != act {
+		t.Errorf("Wrong checkpoint: %v != %v", act, exp)
+	}
+}
+
+func TestKinesisEFOCheckpoints(t *testing.T) {
+	kinesisEFORetryPeriod = time.Millisecond
+
+	k := &mockEFOKinesis{
+		consumerState: kinesis.ConsumerStatusActive,
+		shards: []*kinesis.Shard{
+			{ShardId: aws.String("shard-0")},
+			{ShardId: aws.String("shard-1"), ParentShardId: aws.String("shard-0")},
+			{ShardId: aws.String("shard-2"), ParentShardId: aws.String("shard-0")},
+		},
+		events: map[string][]*kinesis.SubscribeToShardEvent{
+			"shard-1": {newMockEFOEvent("1d", "1c")},
+		},
+	}
+	d := &mockEFODynamo{items: map[string]string{
+		"shard-0": kinesisEFOShardEnd,
+		"shard-1": "1b",
+	}}
+
+	r := newTestKinesisEFO(t, k, d)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	if err := r.ConnectWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ackFn, err := r.ReadWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "1c", string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	if err = ackFn(ctx, response.NewError(errors.New("nope"))); err != nil {
+		t.Error(err)
+	}
+
+	for len(k.subscribedShards()) < 2 && ctx.Err() == nil {
+		<-time.After(time.Millisecond)
+	}
+
+	r.CloseAsync()
+	if err = r.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	subs := k.subscribedShards()
+	if _, exists := subs["shard-0"]; exists {
+		t.Error("Finished shard was consumed")
+	}
+	if pos := subs["shard-1"]; pos == nil {
+		t.Error("Expected shard-1 to be consumed")
+	} else if exp, act := kinesis.ShardIteratorTypeAfterSequenceNumber, *pos.Type; exp != act {
+		t.Errorf("Wrong starting position: %v != %v", act, exp)
+	} else if exp, act := "1b", *pos.SequenceNumber; exp != act {
+		t.Errorf("Wrong starting sequence: %v != %v", act, exp)
+	}
+	if pos := subs["shard-2"]; pos == nil {
+		t.Error("Expected shard-2 to be consumed")
+	} else if exp, act := kinesis.ShardIteratorTypeTrimHorizon, *pos.Type; exp != act {
+		t.Errorf("Wrong starting position: %v != %v", act, exp)
+	}
+
+	if exp, act := "1b", d.checkpoint("shard-1"); exp != act {
+		t.Errorf("Unacknowledged messages were checkpointed: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
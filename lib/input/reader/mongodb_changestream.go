@@ -0,0 +1,328 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//------------------------------------------------------------------------------
+
+// MongoDBChangeStreamConfig contains configuration fields for the
+// MongoDBChangeStream input type.
+type MongoDBChangeStreamConfig struct {
+	URL          string `json:"url" yaml:"url"`
+	Database     string `json:"database" yaml:"database"`
+	Collection   string `json:"collection" yaml:"collection"`
+	FullDocument string `json:"full_document" yaml:"full_document"`
+	Cache        string `json:"cache" yaml:"cache"`
+	CacheKey     string `json:"cache_key" yaml:"cache_key"`
+	BatchSize    int    `json:"batch_size" yaml:"batch_size"`
+	MaxWait      string `json:"max_wait" yaml:"max_wait"`
+}
+
+// NewMongoDBChangeStreamConfig creates a new MongoDBChangeStreamConfig with
+// default values.
+func NewMongoDBChangeStreamConfig() MongoDBChangeStreamConfig {
+	return MongoDBChangeStreamConfig{
+		URL:          "mongodb://localhost:27017",
+		Database:     "",
+		Collection:   "",
+		FullDocument: "updateLookup",
+		Cache:        "",
+		CacheKey:     "mongodb_changestream_resume_token",
+		BatchSize:    100,
+		MaxWait:      "1s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// mongoPendingEvent is an event that has been read and not yet acknowledged.
+type mongoPendingEvent struct {
+	token bson.Raw
+	acked bool
+}
+
+// MongoDBChangeStream is an input type that watches a MongoDB change stream
+// and checkpoints the resume tokens of acknowledged events in a cache.
+type MongoDBChangeStream struct {
+	conf       MongoDBChangeStreamConfig
+	clientOpts *options.ClientOptions
+	maxWait    time.Duration
+	cache      types.Cache
+
+	connMut sync.Mutex
+	client  *mongo.Client
+	stream  *mongo.ChangeStream
+	closed  bool
+
+	// lastToken is the resume token of the last event read, from which the
+	// stream is resumed after losing a connection.
+	lastToken bson.Raw
+
+	// pending tracks unacknowledged events in the order they were read, as a
+	// checkpoint can only be moved past an event once all events before it
+	// are acknowledged.
+	pendingMut sync.Mutex
+	pending    []*mongoPendingEvent
+
+	stats metrics.Type
+	log   log.Modular
+}
+
+// NewMongoDBChangeStream creates a new MongoDBChangeStream input type.
+func NewMongoDBChangeStream(
+	conf MongoDBChangeStreamConfig, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (*MongoDBChangeStream, error) {
+	m := &MongoDBChangeStream{
+		conf:  conf,
+		stats: stats,
+		log:   log,
+	}
+
+	m.clientOpts = options.Client().ApplyURI(conf.URL)
+	if err := m.clientOpts.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to parse url: %v", err)
+	}
+	if len(conf.Database) == 0 {
+		return nil, errors.New("a database must be specified")
+	}
+	if conf.FullDocument != string(options.Default) && conf.FullDocument != string(options.UpdateLookup) {
+		return nil, fmt.Errorf("unrecognised full document option: %v", conf.FullDocument)
+	}
+	if conf.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be greater than zero: %v", conf.BatchSize)
+	}
+	var err error
+	if m.maxWait, err = time.ParseDuration(conf.MaxWait); err != nil {
+		return nil, fmt.Errorf("failed to parse max wait: %v", err)
+	}
+	if len(conf.CacheKey) == 0 {
+		return nil, errors.New("a cache key must be specified")
+	}
+	if m.cache, err = mgr.GetCache(conf.Cache); err != nil {
+		return nil, fmt.Errorf("failed to obtain cache '%v': %v", conf.Cache, err)
+	}
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
+
+// resumeToken returns the token to resume the stream after, which is the last
+// read event if there is one, otherwise the checkpoint within the cache.
+func (m *MongoDBChangeStream) resumeToken() (bson.Raw, error) {
+	if m.lastToken != nil {
+		return m.lastToken, nil
+	}
+	checkpoint, err := m.cache.Get(m.conf.CacheKey)
+	if err != nil {
+		if err == types.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	token := bson.Raw(checkpoint)
+	if err = token.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %v", err)
+	}
+	return token, nil
+}
+
+// ConnectWithContext opens a change stream, resuming after the last
+// checkpoint if there is one.
+func (m *MongoDBChangeStream) ConnectWithContext(ctx context.Context) error {
+	m.connMut.Lock()
+	defer m.connMut.Unlock()
+
+	if m.closed {
+		return types.ErrTypeClosed
+	}
+	if m.stream != nil {
+		return nil
+	}
+
+	token, err := m.resumeToken()
+	if err != nil {
+		return err
+	}
+
+	client, err := mongo.NewClient(m.clientOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create client: %v", err)
+	}
+	if err = client.Connect(ctx); err != nil {
+		return err
+	}
+
+	opts := options.ChangeStream().
+		SetFullDocument(options.FullDocument(m.conf.FullDocument)).
+		SetBatchSize(int32(m.conf.BatchSize)).
+		SetMaxAwaitTime(m.maxWait)
+	if token != nil {
+		opts = opts.SetResumeAfter(token)
+	}
+
+	var stream *mongo.ChangeStream
+	db := client.Database(m.conf.Database)
+	if len(m.conf.Collection) > 0 {
+		stream, err = db.Collection(m.conf.Collection).Watch(ctx, mongo.Pipeline{}, opts)
+	} else {
+		stream, err = db.Watch(ctx, mongo.Pipeline{}, opts)
+	}
+	if err != nil {
+		client.Disconnect(context.Background())
+		return fmt.Errorf("failed to open change stream: %v", err)
+	}
+
+	m.client = client
+	m.stream = stream
+
+	target := m.conf.Database
+	if len(m.conf.Collection) > 0 {
+		target = target + "." + m.conf.Collection
+	}
+	m.log.Infof("Receiving MongoDB change stream events from: %v\n", target)
+	return nil
+}
+
+func (m *MongoDBChangeStream) disconnect() {
+	if m.stream != nil {
+		m.stream.Close(context.Background())
+		m.stream = nil
+	}
+	if m.client != nil {
+		m.client.Disconnect(context.Background())
+		m.client = nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ReadWithContext reads the next change stream event.
+func (m *MongoDBChangeStream) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	m.connMut.Lock()
+	defer m.connMut.Unlock()
+
+	if m.stream == nil {
+		if m.closed {
+			return nil, nil, types.ErrTypeClosed
+		}
+		return nil, nil, types.ErrNotConnected
+	}
+
+	if !m.stream.TryNext(ctx) {
+		if err := m.stream.Err(); err != nil {
+			m.log.Errorf("Failed to read change stream: %v\n", err)
+			m.disconnect()
+			return nil, nil, types.ErrNotConnected
+		}
+		if m.stream.ID() == 0 {
+			// The cursor has been closed by the server, which happens when
+			// the watched collection is dropped or renamed.
+			m.disconnect()
+			return nil, nil, types.ErrNotConnected
+		}
+		return nil, nil, types.ErrTimeout
+	}
+
+	event := m.stream.Current
+	tokenDoc, ok := event.Lookup("_id").DocumentOK()
+	if !ok {
+		return nil, nil, errors.New("received change stream event without a resume token")
+	}
+	token := make(bson.Raw, len(tokenDoc))
+	copy(token, tokenDoc)
+	m.lastToken = token
+
+	jBytes, err := bson.MarshalExtJSON(event, false, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialise event: %v", err)
+	}
+
+	opType, _ := event.Lookup("operationType").StringValueOK()
+	db, _ := event.Lookup("ns", "db").StringValueOK()
+	coll, _ := event.Lookup("ns", "coll").StringValueOK()
+
+	part := message.NewPart(jBytes)
+	part.Metadata().
+		Set("mongodb_operation_type", opType).
+		Set("mongodb_database", db).
+		Set("mongodb_collection", coll)
+	msg := message.New(nil)
+	msg.Append(part)
+
+	p := &mongoPendingEvent{token: token}
+	m.pendingMut.Lock()
+	m.pending = append(m.pending, p)
+	m.pendingMut.Unlock()
+
+	return msg, func(actx context.Context, res types.Response) error {
+		if res.Error() != nil {
+			return nil
+		}
+		return m.ack(p)
+	}, nil
+}
+
+func (m *MongoDBChangeStream) ack(p *mongoPendingEvent) error {
+	m.pendingMut.Lock()
+	defer m.pendingMut.Unlock()
+
+	p.acked = true
+	var checkpoint bson.Raw
+	for len(m.pending) > 0 && m.pending[0].acked {
+		checkpoint = m.pending[0].token
+		m.pending = m.pending[1:]
+	}
+	if checkpoint == nil {
+		return nil
+	}
+	return m.cache.Set(m.conf.CacheKey, checkpoint)
+}
+
+// CloseAsync shuts down the MongoDBChangeStream input and stops processing
+// requests.
+func (m *MongoDBChangeStream) CloseAsync() {
+	m.connMut.Lock()
+	m.closed = true
+	m.disconnect()
+	m.connMut.Unlock()
+}
+
+// WaitForClose blocks until the MongoDBChangeStream input has closed down.
+func (m *MongoDBChangeStream) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
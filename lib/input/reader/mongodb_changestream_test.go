@@ -0,0 +1,159 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//------------------------------------------------------------------------------
+
+func mongoTestToken(t *testing.T, data string) bson.Raw {
+	t.Helper()
+	token, err := bson.Marshal(bson.D{{Key: "_data", Value: data}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+//------------------------------------------------------------------------------
+
+func TestMongoDBChangeStreamBadConfig(t *testing.T) {
+	mgr := &testCacheMgr{cache: &testCache{values: map[string][]byte{}}}
+
+	conf := NewMongoDBChangeStreamConfig()
+	conf.Database = "shop"
+	if _, err := NewMongoDBChangeStream(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+
+	conf.Cache = "foocache"
+	conf.FullDocument = "nope"
+	if _, err := NewMongoDBChangeStream(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad full document option")
+	}
+
+	conf = NewMongoDBChangeStreamConfig()
+	conf.Cache = "foocache"
+	if _, err := NewMongoDBChangeStream(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing database")
+	}
+
+	conf.Database = "shop"
+	conf.URL = "nope://localhost"
+	if _, err := NewMongoDBChangeStream(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad url")
+	}
+}
+
+func TestMongoDBChangeStreamResumeToken(t *testing.T) {
+	cache := &testCache{values: map[string][]byte{}}
+
+	conf := NewMongoDBChangeStreamConfig()
+	conf.Database = "shop"
+	conf.Cache = "foocache"
+	conf.CacheKey = "token"
+
+	m, err := NewMongoDBChangeStream(conf, &testCacheMgr{cache: cache}, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := m.resumeToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != nil {
+		t.Errorf("Expected no resume token, got: %v", token)
+	}
+
+	cache.Set("token", []byte("nope"))
+	if _, err = m.resumeToken(); err == nil {
+		t.Error("Expected error from bad checkpoint")
+	}
+
+	exp := mongoTestToken(t, "t0")
+	cache.Set("token", exp)
+	if token, err = m.resumeToken(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, token) {
+		t.Errorf("Wrong resume token: %v != %v", token, exp)
+	}
+
+	// The last read event takes precedence over the checkpoint.
+	exp = mongoTestToken(t, "t1")
+	m.lastToken = exp
+	if token, err = m.resumeToken(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, token) {
+		t.Errorf("Wrong resume token: %v != %v", token, exp)
+	}
+}
+
+func TestMongoDBChangeStreamCheckpoint(t *testing.T) {
+	initToken := mongoTestToken(t, "t0")
+	cache := &testCache{values: map[string][]byte{"token": initToken}}
+
+	conf := NewMongoDBChangeStreamConfig()
+	conf.Database = "shop"
+	conf.Cache = "foocache"
+	conf.CacheKey = "token"
+
+	m, err := NewMongoDBChangeStream(conf, &testCacheMgr{cache: cache}, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []*mongoPendingEvent
+	for _, data := range []string{"t1", "t2"} {
+		p := &mongoPendingEvent{token: mongoTestToken(t, data)}
+		m.pending = append(m.pending, p)
+		events = append(events, p)
+	}
+
+	// The checkpoint must not move past an unacknowledged event.
+	if err = m.ack(events[1]); err != nil {
+		t.Error(err)
+	}
+	if checkpoint, _ := cache.Get("token"); !reflect.DeepEqual([]byte(initToken), checkpoint) {
+		t.Errorf("Checkpoint moved past unacknowledged event: %v", checkpoint)
+	}
+	if err = m.ack(events[0]); err != nil {
+		t.Error(err)
+	}
+	checkpoint, _ := cache.Get("token")
+	if exp := mongoTestToken(t, "t2"); !reflect.DeepEqual([]byte(exp), checkpoint) {
+		t.Errorf("Wrong checkpoint: %v != %v", bson.Raw(checkpoint), exp)
+	}
+	if len(m.pending) != 0 {
+		t.Errorf("Expected no pending events, got: %v", len(m.pending))
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -22,6 +22,7 @@ package reader
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,6 +32,8 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bmqtt "github.com/Jeffail/benthos/v3/lib/util/mqtt"
+	"github.com/eclipse/paho.golang/paho"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -38,25 +41,31 @@ import (
 
 // MQTTConfig contains configuration fields for the MQTT input type.
 type MQTTConfig struct {
-	URLs         []string `json:"urls" yaml:"urls"`
-	QoS          uint8    `json:"qos" yaml:"qos"`
-	Topics       []string `json:"topics" yaml:"topics"`
-	ClientID     string   `json:"client_id" yaml:"client_id"`
-	CleanSession bool     `json:"clean_session" yaml:"clean_session"`
-	User         string   `json:"user" yaml:"user"`
-	Password     string   `json:"password" yaml:"password"`
+	URLs            []string `json:"urls" yaml:"urls"`
+	ProtocolVersion string   `json:"protocol_version" yaml:"protocol_version"`
+	QoS             uint8    `json:"qos" yaml:"qos"`
+	Topics          []string `json:"topics" yaml:"topics"`
+	SharedGroup     string   `json:"shared_group" yaml:"shared_group"`
+	ClientID        string   `json:"client_id" yaml:"client_id"`
+	CleanSession    bool     `json:"clean_session" yaml:"clean_session"`
+	SessionExpiry   string   `json:"session_expiry_interval" yaml:"session_expiry_interval"`
+	User            string   `json:"user" yaml:"user"`
+	Password        string   `json:"password" yaml:"password"`
 }
 
 // NewMQTTConfig creates a new MQTTConfig with default values.
 func NewMQTTConfig() MQTTConfig {
 	return MQTTConfig{
-		URLs:         []string{"tcp://localhost:1883"},
-		QoS:          1,
-		Topics:       []string{"benthos_topic"},
-		ClientID:     "benthos_input",
-		CleanSession: true,
-		User:         "",
-		Password:     "",
+		URLs:            []string{"tcp://localhost:1883"},
+		ProtocolVersion: "3.1.1",
+		QoS:             1,
+		Topics:          []string{"benthos_topic"},
+		SharedGroup:     "",
+		ClientID:        "benthos_input",
+		CleanSession:    true,
+		SessionExpiry:   "0s",
+		User:            "",
+		Password:        "",
 	}
 }
 
@@ -64,15 +73,19 @@ func NewMQTTConfig() MQTTConfig {
 
 // MQTT is an input type that reads MQTT Pub/Sub messages.
 type MQTT struct {
-	client mqtt.Client
-	cMut   sync.Mutex
+	client   mqtt.Client
+	clientV5 *bmqtt.V5Client
+	cMut     sync.Mutex
 
-	conf MQTTConfig
+	conf          MQTTConfig
+	v5            bool
+	sessionExpiry time.Duration
 
-	msgChan       chan mqtt.Message
+	msgChan       chan types.Message
 	interruptChan chan struct{}
 
-	urls []string
+	urls   []string
+	topics []string
 
 	stats metrics.Type
 	log   log.Modular
@@ -84,12 +97,32 @@ func NewMQTT(
 ) (*MQTT, error) {
 	m := &MQTT{
 		conf:          conf,
-		msgChan:       make(chan mqtt.Message),
+		msgChan:       make(chan types.Message),
 		interruptChan: make(chan struct{}),
 		stats:         stats,
 		log:           log,
 	}
 
+	var err error
+	if m.v5, err = bmqtt.IsV5(conf.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	if len(conf.SessionExpiry) > 0 {
+		if m.sessionExpiry, err = time.ParseDuration(conf.SessionExpiry); err != nil {
+			return nil, fmt.Errorf("failed to parse session expiry interval: %v", err)
+		}
+	}
+	if m.sessionExpiry > 0 && !m.v5 {
+		return nil, fmt.Errorf("session_expiry_interval requires protocol version 5")
+	}
+
+	for _, topic := range conf.Topics {
+		if len(conf.SharedGroup) > 0 {
+			topic = "$share/" + conf.SharedGroup + "/" + topic
+		}
+		m.topics = append(m.topics, topic)
+	}
+
 	for _, u := range conf.URLs {
 		for _, splitURL := range strings.Split(u, ",") {
 			if len(splitURL) > 0 {
@@ -113,16 +146,19 @@ func (m *MQTT) ConnectWithContext(ctx context.Context) error {
 	m.cMut.Lock()
 	defer m.cMut.Unlock()
 
-	if m.client != nil {
+	if m.client != nil || m.clientV5 != nil {
 		return nil
 	}
+	if m.v5 {
+		return m.connectV5(ctx)
+	}
 
 	conf := mqtt.NewClientOptions().
 		SetAutoReconnect(true).
 		SetClientID(m.conf.ClientID).
 		SetCleanSession(m.conf.CleanSession).
 		SetOnConnectHandler(func(c mqtt.Client) {
-			for _, topic := range m.conf.Topics {
+			for _, topic := range m.topics {
 				tok := c.Subscribe(topic, byte(m.conf.QoS), m.msgHandler)
 				tok.Wait()
 				if err := tok.Error(); err != nil {
@@ -155,27 +191,101 @@ func (m *MQTT) ConnectWithContext(ctx context.Context) error {
 	return nil
 }
 
+func (m *MQTT) connectV5(ctx context.Context) error {
+	client, err := bmqtt.ConnectV5(ctx, bmqtt.V5Options{
+		URLs:           m.urls,
+		ClientID:       m.conf.ClientID,
+		User:           m.conf.User,
+		Password:       m.conf.Password,
+		CleanStart:     m.conf.CleanSession,
+		SessionExpiry:  m.sessionExpiry,
+		KeepAlive:      time.Second * 30,
+		ConnectTimeout: time.Second * 10,
+		Router:         paho.NewSingleHandlerRouter(m.msgHandlerV5),
+	})
+	if err != nil {
+		return err
+	}
+
+	sub := &paho.Subscribe{
+		Subscriptions: map[string]paho.SubscribeOptions{},
+	}
+	for _, topic := range m.topics {
+		sub.Subscriptions[topic] = paho.SubscribeOptions{QoS: byte(m.conf.QoS)}
+	}
+	if _, err = client.Subscribe(ctx, sub); err != nil {
+		client.Disconnect(&paho.Disconnect{})
+		return err
+	}
+
+	m.clientV5 = client
+	m.log.Infof("Receiving MQTT v5 messages from topics: %v\n", m.topics)
+	return nil
+}
+
 func (m *MQTT) msgHandler(c mqtt.Client, msg mqtt.Message) {
+	message := message.New([][]byte{[]byte(msg.Payload())})
+
+	meta := message.Get(0).Metadata()
+	meta.Set("mqtt_duplicate", strconv.FormatBool(bool(msg.Duplicate())))
+	meta.Set("mqtt_qos", strconv.Itoa(int(msg.Qos())))
+	meta.Set("mqtt_retained", strconv.FormatBool(bool(msg.Retained())))
+	meta.Set("mqtt_topic", string(msg.Topic()))
+	meta.Set("mqtt_message_id", strconv.Itoa(int(msg.MessageID())))
+
 	select {
-	case m.msgChan <- msg:
+	case m.msgChan <- message:
+	case <-m.interruptChan:
+	}
+}
+
+func (m *MQTT) msgHandlerV5(pub *paho.Publish) {
+	message := message.New([][]byte{pub.Payload})
+
+	meta := message.Get(0).Metadata()
+	if pub.Properties != nil {
+		for k, v := range pub.Properties.User {
+			meta.Set(k, v)
+		}
+		if len(pub.Properties.ContentType) > 0 {
+			meta.Set("mqtt_content_type", pub.Properties.ContentType)
+		}
+		if len(pub.Properties.ResponseTopic) > 0 {
+			meta.Set("mqtt_response_topic", pub.Properties.ResponseTopic)
+		}
+	}
+	meta.Set("mqtt_qos", strconv.Itoa(int(pub.QoS)))
+	meta.Set("mqtt_retained", strconv.FormatBool(pub.Retain))
+	meta.Set("mqtt_topic", pub.Topic)
+
+	select {
+	case m.msgChan <- message:
 	case <-m.interruptChan:
 	}
 }
 
 // ReadWithContext attempts to read a new message from an MQTT broker.
 func (m *MQTT) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	var lostChan <-chan struct{}
+	if m.v5 {
+		m.cMut.Lock()
+		clientV5 := m.clientV5
+		m.cMut.Unlock()
+		if clientV5 == nil {
+			return nil, nil, types.ErrNotConnected
+		}
+		lostChan = clientV5.Done()
+	}
+
 	select {
 	case msg := <-m.msgChan:
-		message := message.New([][]byte{[]byte(msg.Payload())})
-
-		meta := message.Get(0).Metadata()
-		meta.Set("mqtt_duplicate", strconv.FormatBool(bool(msg.Duplicate())))
-		meta.Set("mqtt_qos", strconv.Itoa(int(msg.Qos())))
-		meta.Set("mqtt_retained", strconv.FormatBool(bool(msg.Retained())))
-		meta.Set("mqtt_topic", string(msg.Topic()))
-		meta.Set("mqtt_message_id", strconv.Itoa(int(msg.MessageID())))
-
-		return message, noopAsyncAckFn, nil
+		return msg, noopAsyncAckFn, nil
+	case <-lostChan:
+		m.log.Errorln("Lost connection to MQTT broker")
+		m.cMut.Lock()
+		m.clientV5 = nil
+		m.cMut.Unlock()
+		return nil, nil, types.ErrNotConnected
 	case <-ctx.Done():
 	case <-m.interruptChan:
 		return nil, nil, types.ErrTypeClosed
@@ -200,6 +310,14 @@ func (m *MQTT) CloseAsync() {
 	if m.client != nil {
 		m.client.Disconnect(0)
 		m.client = nil
+	}
+	if m.clientV5 != nil {
+		m.clientV5.Disconnect(&paho.Disconnect{})
+		m.clientV5 = nil
+	}
+	select {
+	case <-m.interruptChan:
+	default:
 		close(m.interruptChan)
 	}
 	m.cMut.Unlock()
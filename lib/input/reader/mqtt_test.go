@@ -23,6 +23,7 @@ package reader
 import (
 	"fmt"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -196,3 +197,44 @@ func testMQTTDisconnect(urls []string, t *testing.T) {
 
 	wg.Wait()
 }
+
+//------------------------------------------------------------------------------
+
+func TestMQTTSharedGroupTopics(t *testing.T) {
+	conf := NewMQTTConfig()
+	conf.Topics = []string{"foo", "bar/#"}
+	conf.SharedGroup = "benthos"
+
+	m, err := NewMQTT(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []string{"$share/benthos/foo", "$share/benthos/bar/#"}, m.topics; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong topics: %v != %v", act, exp)
+	}
+}
+
+func TestMQTTBadConfig(t *testing.T) {
+	tests := map[string]func(c *MQTTConfig){
+		"bad protocol version": func(c *MQTTConfig) {
+			c.ProtocolVersion = "4"
+		},
+		"bad session expiry": func(c *MQTTConfig) {
+			c.ProtocolVersion = "5"
+			c.SessionExpiry = "nope"
+		},
+		"session expiry without v5": func(c *MQTTConfig) {
+			c.SessionExpiry = "1h"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewMQTTConfig()
+		fn(&conf)
+		if _, err := NewMQTT(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
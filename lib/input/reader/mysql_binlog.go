@@ -0,0 +1,762 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//------------------------------------------------------------------------------
+
+var errMySQLShort = errors.New("data is too short")
+
+// mysqlReader reads little-endian fields from MySQL packets and binlog events,
+// where the first error encountered is kept and all later reads return zero
+// values.
+type mysqlReader struct {
+	b   []byte
+	err error
+}
+
+func (r *mysqlReader) len() int {
+	return len(r.b)
+}
+
+func (r *mysqlReader) next(n int) []byte {
+	if r.err != nil || n < 0 || len(r.b) < n {
+		if r.err == nil {
+			r.err = errMySQLShort
+		}
+		// A zero byte is returned so that single byte reads remain safe.
+		return []byte{0}
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *mysqlReader) rest() []byte {
+	v := r.b
+	r.b = nil
+	return v
+}
+
+func (r *mysqlReader) uintLE(n int) uint64 {
+	var v uint64
+	for i, c := range r.next(n) {
+		v |= uint64(c) << (8 * uint(i))
+	}
+	return v
+}
+
+func (r *mysqlReader) uintBE(n int) uint64 {
+	var v uint64
+	for _, c := range r.next(n) {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func (r *mysqlReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *mysqlReader) uint16() uint16 {
+	return uint16(r.uintLE(2))
+}
+
+func (r *mysqlReader) uint32() uint32 {
+	return uint32(r.uintLE(4))
+}
+
+func (r *mysqlReader) lenencInt() uint64 {
+	switch b := r.byte(); b {
+	case 0xfc:
+		return r.uintLE(2)
+	case 0xfd:
+		return r.uintLE(3)
+	case 0xfe:
+		return r.uintLE(8)
+	default:
+		return uint64(b)
+	}
+}
+
+// nulString reads a NUL terminated string, or the remaining data if there is
+// no terminator.
+func (r *mysqlReader) nulString() string {
+	for i, c := range r.b {
+		if c == 0 {
+			s := string(r.b[:i])
+			r.b = r.b[i+1:]
+			return s
+		}
+	}
+	return string(r.rest())
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	mysqlEventQuery         = 2
+	mysqlEventXID           = 16
+	mysqlEventTableMap      = 19
+	mysqlEventWriteRowsV1   = 23
+	mysqlEventUpdateRowsV1  = 24
+	mysqlEventDeleteRowsV1  = 25
+	mysqlEventWriteRowsV2   = 30
+	mysqlEventUpdateRowsV2  = 31
+	mysqlEventDeleteRowsV2  = 32
+	mysqlEventGTID          = 33
+	mysqlEventPartialUpdate = 39
+
+	mysqlEventHeaderSize = 19
+)
+
+const (
+	mysqlTypeDecimal    = 0
+	mysqlTypeTiny       = 1
+	mysqlTypeShort      = 2
+	mysqlTypeLong       = 3
+	mysqlTypeFloat      = 4
+	mysqlTypeDouble     = 5
+	mysqlTypeNull       = 6
+	mysqlTypeTimestamp  = 7
+	mysqlTypeLongLong   = 8
+	mysqlTypeInt24      = 9
+	mysqlTypeDate       = 10
+	mysqlTypeTime       = 11
+	mysqlTypeDateTime   = 12
+	mysqlTypeYear       = 13
+	mysqlTypeVarchar    = 15
+	mysqlTypeBit        = 16
+	mysqlTypeTimestamp2 = 17
+	mysqlTypeDateTime2  = 18
+	mysqlTypeTime2      = 19
+	mysqlTypeJSON       = 245
+	mysqlTypeNewDecimal = 246
+	mysqlTypeEnum       = 247
+	mysqlTypeSet        = 248
+	mysqlTypeBlob       = 252
+	mysqlTypeVarString  = 253
+	mysqlTypeString     = 254
+	mysqlTypeGeometry   = 255
+)
+
+// mysqlColumn describes a table column as reported by information_schema,
+// which the binlog does not include.
+type mysqlColumn struct {
+	name     string
+	unsigned bool
+	values   []string // Members of ENUM and SET columns
+}
+
+// mysqlTable is a table described by a TABLE_MAP event.
+type mysqlTable struct {
+	schema  string
+	name    string
+	types   []byte
+	meta    []uint16
+	columns []mysqlColumn
+}
+
+func (t *mysqlTable) column(i int) mysqlColumn {
+	if i < len(t.columns) {
+		return t.columns[i]
+	}
+	return mysqlColumn{name: strconv.Itoa(i)}
+}
+
+// mysqlChange is a row change event emitted by the MySQLCDC input.
+type mysqlChange struct {
+	Operation string                 `json:"operation"`
+	Schema    string                 `json:"schema"`
+	Table     string                 `json:"table"`
+	GTID      string                 `json:"gtid"`
+	Before    map[string]interface{} `json:"before"`
+	After     map[string]interface{} `json:"after"`
+}
+
+//------------------------------------------------------------------------------
+
+func parseMySQLGTIDEvent(body []byte) (string, int64, error) {
+	r := &mysqlReader{b: body}
+	r.byte() // Flags
+	sid := mysqlUUIDString(r.next(16))
+	gno := int64(r.uintLE(8))
+	return sid, gno, r.err
+}
+
+func parseMySQLQueryEvent(body []byte) (string, error) {
+	r := &mysqlReader{b: body}
+	r.uint32() // Thread ID
+	r.uint32() // Execution time
+	schemaLen := int(r.byte())
+	r.uint16() // Error code
+	r.next(int(r.uint16()))
+	r.next(schemaLen + 1)
+	return string(r.rest()), r.err
+}
+
+func parseMySQLTableMap(body []byte) (uint64, *mysqlTable, error) {
+	r := &mysqlReader{b: body}
+	id := r.uintLE(6)
+	r.uint16() // Flags
+
+	t := &mysqlTable{}
+	t.schema = string(r.next(int(r.byte())))
+	r.byte()
+	t.name = string(r.next(int(r.byte())))
+	r.byte()
+
+	n := int(r.lenencInt())
+	t.types = append([]byte(nil), r.next(n)...)
+	t.meta = make([]uint16, n)
+
+	mr := &mysqlReader{b: r.next(int(r.lenencInt()))}
+	for i, typ := range t.types {
+		switch typ {
+		case mysqlTypeFloat, mysqlTypeDouble, mysqlTypeBlob, mysqlTypeGeometry, mysqlTypeJSON,
+			mysqlTypeTime2, mysqlTypeDateTime2, mysqlTypeTimestamp2:
+			t.meta[i] = uint16(mr.byte())
+		case mysqlTypeVarchar, mysqlTypeVarString, mysqlTypeBit:
+			t.meta[i] = mr.uint16()
+		case mysqlTypeNewDecimal, mysqlTypeString, mysqlTypeEnum, mysqlTypeSet:
+			t.meta[i] = uint16(mr.uintBE(2))
+		}
+	}
+	if r.err == nil {
+		r.err = mr.err
+	}
+	return id, t, r.err
+}
+
+// parseMySQLRows parses the rows of a WRITE_ROWS, UPDATE_ROWS or DELETE_ROWS
+// event into changes.
+func parseMySQLRows(evType byte, body []byte, tables map[uint64]*mysqlTable) ([]mysqlChange, error) {
+	r := &mysqlReader{b: body}
+	id := r.uintLE(6)
+	r.uint16() // Flags
+	if evType >= mysqlEventWriteRowsV2 {
+		r.next(int(r.uint16()) - 2)
+	}
+
+	t, exists := tables[id]
+	if !exists {
+		return nil, fmt.Errorf("unknown table ID: %v", id)
+	}
+	n := int(r.lenencInt())
+	if n != len(t.types) {
+		return nil, fmt.Errorf("row of %v columns does not match table %v.%v", n, t.schema, t.name)
+	}
+
+	present := r.next((n + 7) / 8)
+	presentAfter := present
+	if evType == mysqlEventUpdateRowsV1 || evType == mysqlEventUpdateRowsV2 {
+		presentAfter = r.next((n + 7) / 8)
+	}
+
+	var changes []mysqlChange
+	for r.len() > 0 && r.err == nil {
+		c := mysqlChange{Schema: t.schema, Table: t.name}
+		switch evType {
+		case mysqlEventWriteRowsV1, mysqlEventWriteRowsV2:
+			c.Operation = "insert"
+			c.After = r.row(t, present)
+		case mysqlEventUpdateRowsV1, mysqlEventUpdateRowsV2:
+			c.Operation = "update"
+			c.Before = r.row(t, present)
+			c.After = r.row(t, presentAfter)
+		default:
+			c.Operation = "delete"
+			c.Before = r.row(t, present)
+		}
+		changes = append(changes, c)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to parse row of table %v.%v: %v", t.schema, t.name, r.err)
+	}
+	return changes, nil
+}
+
+func mysqlBitSet(bitmap []byte, i int) bool {
+	return bitmap[i/8]&(1<<(uint(i)%8)) != 0
+}
+
+func (r *mysqlReader) row(t *mysqlTable, present []byte) map[string]interface{} {
+	count := 0
+	for i := range t.types {
+		if mysqlBitSet(present, i) {
+			count++
+		}
+	}
+	nulls := r.next((count + 7) / 8)
+
+	image := make(map[string]interface{}, count)
+	idx := 0
+	for i, typ := range t.types {
+		if !mysqlBitSet(present, i) {
+			continue
+		}
+		col := t.column(i)
+		if mysqlBitSet(nulls, idx) {
+			image[col.name] = nil
+		} else {
+			image[col.name] = r.value(typ, t.meta[i], col)
+		}
+		idx++
+	}
+	return image
+}
+
+//------------------------------------------------------------------------------
+
+func mysqlText(b []byte) interface{} {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return append([]byte(nil), b...)
+}
+
+// value reads a column value of the binlog row format.
+func (r *mysqlReader) value(typ byte, meta uint16, col mysqlColumn) interface{} {
+	switch typ {
+	case mysqlTypeNull:
+		return nil
+	case mysqlTypeTiny:
+		v := r.byte()
+		if col.unsigned {
+			return int64(v)
+		}
+		return int64(int8(v))
+	case mysqlTypeShort:
+		v := r.uint16()
+		if col.unsigned {
+			return int64(v)
+		}
+		return int64(int16(v))
+	case mysqlTypeInt24:
+		v := int64(r.uintLE(3))
+		if !col.unsigned && v&0x800000 != 0 {
+			v -= 0x1000000
+		}
+		return v
+	case mysqlTypeLong:
+		v := r.uint32()
+		if col.unsigned {
+			return int64(v)
+		}
+		return int64(int32(v))
+	case mysqlTypeLongLong:
+		v := r.uintLE(8)
+		if col.unsigned {
+			return v
+		}
+		return int64(v)
+	case mysqlTypeFloat:
+		f := math.Float32frombits(r.uint32())
+		return json.Number(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	case mysqlTypeDouble:
+		return math.Float64frombits(r.uintLE(8))
+	case mysqlTypeNewDecimal:
+		return json.Number(r.decimal(int(meta>>8), int(meta&0xff)))
+	case mysqlTypeYear:
+		if v := int64(r.byte()); v > 0 {
+			return 1900 + v
+		}
+		return int64(0)
+	case mysqlTypeDate:
+		v := r.uintLE(3)
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, (v>>5)&15, v&31)
+	case mysqlTypeTime:
+		v := int64(r.uintLE(3))
+		sign := ""
+		if v&0x800000 != 0 {
+			sign, v = "-", 0x1000000-v
+		}
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, v/10000, (v%10000)/100, v%100)
+	case mysqlTypeTime2:
+		return r.time2(meta)
+	case mysqlTypeDateTime:
+		v := r.uintLE(8)
+		d, t := v/1000000, v%1000000
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d/10000, (d%10000)/100, d%100, t/10000, (t%10000)/100, t%100)
+	case mysqlTypeDateTime2:
+		return r.datetime2(meta)
+	case mysqlTypeTimestamp:
+		return time.Unix(int64(r.uint32()), 0).UTC().Format("2006-01-02 15:04:05")
+	case mysqlTypeTimestamp2:
+		sec := int64(r.uintBE(4))
+		return time.Unix(sec, 0).UTC().Format("2006-01-02 15:04:05") + mysqlFraction(r.fraction(meta), meta)
+	case mysqlTypeVarchar, mysqlTypeVarString:
+		if meta < 256 {
+			return mysqlText(r.next(int(r.byte())))
+		}
+		return mysqlText(r.next(int(r.uint16())))
+	case mysqlTypeString:
+		realType, length := byte(meta>>8), int(meta&0xff)
+		if meta >= 256 && realType&0x30 != 0x30 {
+			length |= int((realType&0x30)^0x30) << 4
+			realType |= 0x30
+		}
+		switch realType {
+		case mysqlTypeEnum:
+			return r.enum(length, col)
+		case mysqlTypeSet:
+			return r.set(length, col)
+		}
+		if length < 256 {
+			return mysqlText(r.next(int(r.byte())))
+		}
+		return mysqlText(r.next(int(r.uint16())))
+	case mysqlTypeEnum:
+		return r.enum(int(meta&0xff), col)
+	case mysqlTypeSet:
+		return r.set(int(meta&0xff), col)
+	case mysqlTypeBit:
+		bits := int(meta>>8)*8 + int(meta&0xff)
+		return r.uintBE((bits + 7) / 8)
+	case mysqlTypeBlob:
+		return mysqlText(r.next(int(r.uintLE(int(meta)))))
+	case mysqlTypeGeometry:
+		return append([]byte(nil), r.next(int(r.uintLE(int(meta))))...)
+	case mysqlTypeJSON:
+		data := r.next(int(r.uintLE(int(meta))))
+		if r.err != nil {
+			return nil
+		}
+		v, err := decodeMySQLJSON(data)
+		if err != nil {
+			r.err = err
+		}
+		return v
+	}
+	if r.err == nil {
+		r.err = fmt.Errorf("unsupported column type: %v", typ)
+	}
+	return nil
+}
+
+func (r *mysqlReader) enum(size int, col mysqlColumn) interface{} {
+	i := int(r.uintLE(size))
+	if i > 0 && i <= len(col.values) {
+		return col.values[i-1]
+	}
+	if i == 0 && len(col.values) > 0 {
+		return ""
+	}
+	return int64(i)
+}
+
+func (r *mysqlReader) set(size int, col mysqlColumn) interface{} {
+	bits := r.uintLE(size)
+	if len(col.values) == 0 {
+		return bits
+	}
+	var members []string
+	for i, v := range col.values {
+		if bits&(1<<uint(i)) != 0 {
+			members = append(members, v)
+		}
+	}
+	return strings.Join(members, ",")
+}
+
+// fraction reads the fractional seconds of a temporal type as microseconds.
+func (r *mysqlReader) fraction(fsp uint16) int64 {
+	switch fsp {
+	case 1, 2:
+		return int64(r.uintBE(1)) * 10000
+	case 3, 4:
+		return int64(r.uintBE(2)) * 100
+	case 5, 6:
+		return int64(r.uintBE(3))
+	}
+	return 0
+}
+
+func mysqlFraction(micros int64, fsp uint16) string {
+	if fsp == 0 || fsp > 6 {
+		return ""
+	}
+	return "." + fmt.Sprintf("%06d", micros)[:fsp]
+}
+
+func (r *mysqlReader) time2(fsp uint16) string {
+	var packed int64
+	switch fsp {
+	case 1, 2:
+		intPart, frac := int64(r.uintBE(3))-0x800000, int64(r.uintBE(1))
+		if intPart < 0 && frac > 0 {
+			intPart, frac = intPart+1, frac-0x100
+		}
+		packed = intPart<<24 + frac*10000
+	case 3, 4:
+		intPart, frac := int64(r.uintBE(3))-0x800000, int64(r.uintBE(2))
+		if intPart < 0 && frac > 0 {
+			intPart, frac = intPart+1, frac-0x10000
+		}
+		packed = intPart<<24 + frac*100
+	case 5, 6:
+		packed = int64(r.uintBE(6)) - 0x800000000000
+	default:
+		packed = (int64(r.uintBE(3)) - 0x800000) << 24
+	}
+
+	sign := ""
+	if packed < 0 {
+		sign, packed = "-", -packed
+	}
+	hms, micros := packed>>24, packed%(1<<24)
+	return fmt.Sprintf("%s%02d:%02d:%02d%s", sign, (hms>>12)%(1<<10), (hms>>6)%(1<<6), hms%(1<<6), mysqlFraction(micros, fsp))
+}
+
+func (r *mysqlReader) datetime2(fsp uint16) string {
+	intPart := int64(r.uintBE(5)) - 0x8000000000
+	micros := r.fraction(fsp)
+
+	ymd, hms := intPart>>17, intPart%(1<<17)
+	ym := ymd >> 5
+	return fmt.Sprintf(
+		"%04d-%02d-%02d %02d:%02d:%02d%s",
+		ym/13, ym%13, ymd%(1<<5), hms>>12, (hms>>6)%(1<<6), hms%(1<<6), mysqlFraction(micros, fsp),
+	)
+}
+
+var mysqlDecimalDigitBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decimal reads a DECIMAL value, which is stored as groups of nine digits in
+// four bytes with the sign encoded in the highest bit.
+func (r *mysqlReader) decimal(precision, scale int) string {
+	intg := precision - scale
+	intg0, intg0x := intg/9, intg%9
+	frac0, frac0x := scale/9, scale%9
+	size := intg0*4 + mysqlDecimalDigitBytes[intg0x] + frac0*4 + mysqlDecimalDigitBytes[frac0x]
+
+	b := append([]byte(nil), r.next(size)...)
+	if r.err != nil || size == 0 {
+		return "0"
+	}
+
+	var mask byte
+	negative := b[0]&0x80 == 0
+	if negative {
+		mask = 0xff
+	}
+	b[0] ^= 0x80
+	for i := range b {
+		b[i] ^= mask
+	}
+	dr := &mysqlReader{b: b}
+
+	var intDigits strings.Builder
+	if n := mysqlDecimalDigitBytes[intg0x]; n > 0 {
+		intDigits.WriteString(strconv.FormatUint(dr.uintBE(n), 10))
+	}
+	for i := 0; i < intg0; i++ {
+		fmt.Fprintf(&intDigits, "%09d", dr.uintBE(4))
+	}
+
+	var s strings.Builder
+	if negative {
+		s.WriteByte('-')
+	}
+	if digits := strings.TrimLeft(intDigits.String(), "0"); len(digits) > 0 {
+		s.WriteString(digits)
+	} else {
+		s.WriteByte('0')
+	}
+	if scale > 0 {
+		s.WriteByte('.')
+		for i := 0; i < frac0; i++ {
+			fmt.Fprintf(&s, "%09d", dr.uintBE(4))
+		}
+		if n := mysqlDecimalDigitBytes[frac0x]; n > 0 {
+			fmt.Fprintf(&s, "%0*d", frac0x, dr.uintBE(n))
+		}
+	}
+	return s.String()
+}
+
+//------------------------------------------------------------------------------
+
+var errMySQLJSON = errors.New("invalid binary JSON value")
+
+// decodeMySQLJSON parses the binary format of MySQL JSON columns.
+func decodeMySQLJSON(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return mysqlJSONValue(b[0], b[1:])
+}
+
+func mysqlJSONValue(typ byte, b []byte) (interface{}, error) {
+	r := &mysqlReader{b: b}
+	var v interface{}
+	switch typ {
+	case 0x00, 0x01, 0x02, 0x03:
+		return mysqlJSONContainer(b, typ == 0x01 || typ == 0x03, typ < 0x02)
+	case 0x04:
+		switch r.byte() {
+		case 0x01:
+			v = true
+		case 0x02:
+			v = false
+		}
+	case 0x05:
+		v = int64(int16(r.uint16()))
+	case 0x06:
+		v = int64(r.uint16())
+	case 0x07:
+		v = int64(int32(r.uint32()))
+	case 0x08:
+		v = int64(r.uint32())
+	case 0x09:
+		v = int64(r.uintLE(8))
+	case 0x0a:
+		v = r.uintLE(8)
+	case 0x0b:
+		v = math.Float64frombits(r.uintLE(8))
+	case 0x0c:
+		l, n, err := mysqlJSONLength(b)
+		if err != nil {
+			return nil, err
+		}
+		r.next(n)
+		v = string(r.next(l))
+	case 0x0f:
+		// Opaque values hold other MySQL types, of which decimals are decoded
+		// and the rest are kept as raw strings.
+		opaqueType := r.byte()
+		l, n, err := mysqlJSONLength(r.b)
+		if err != nil {
+			return nil, err
+		}
+		r.next(n)
+		data := r.next(l)
+		if opaqueType == mysqlTypeNewDecimal && len(data) > 2 {
+			dr := &mysqlReader{b: data[2:]}
+			v = json.Number(dr.decimal(int(data[0]), int(data[1])))
+			r.err = dr.err
+		} else {
+			v = string(data)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported binary JSON type: %v", typ)
+	}
+	if r.err != nil {
+		return nil, errMySQLJSON
+	}
+	return v, nil
+}
+
+// mysqlJSONLength reads a variable length integer of seven bits per byte.
+func mysqlJSONLength(b []byte) (int, int, error) {
+	l := 0
+	for i := 0; i < 5 && i < len(b); i++ {
+		l |= int(b[i]&0x7f) << (7 * uint(i))
+		if b[i]&0x80 == 0 {
+			return l, i + 1, nil
+		}
+	}
+	return 0, 0, errMySQLJSON
+}
+
+func mysqlJSONContainer(b []byte, large, object bool) (interface{}, error) {
+	size := 2
+	if large {
+		size = 4
+	}
+	read := func(pos, n int) (int, error) {
+		if pos < 0 || pos+n > len(b) {
+			return 0, errMySQLJSON
+		}
+		r := &mysqlReader{b: b[pos : pos+n]}
+		return int(r.uintLE(n)), nil
+	}
+
+	count, err := read(0, size)
+	if err != nil {
+		return nil, err
+	}
+	pos := 2 * size
+
+	keys := make([]string, 0, count)
+	if object {
+		for i := 0; i < count; i++ {
+			off, err := read(pos, size)
+			if err != nil {
+				return nil, err
+			}
+			l, err := read(pos+size, 2)
+			if err != nil {
+				return nil, err
+			}
+			if off+l > len(b) {
+				return nil, errMySQLJSON
+			}
+			keys = append(keys, string(b[off:off+l]))
+			pos += size + 2
+		}
+	}
+
+	values := make([]interface{}, count)
+	for i := range values {
+		if pos+1+size > len(b) {
+			return nil, errMySQLJSON
+		}
+		typ := b[pos]
+		inlined := typ == 0x04 || typ == 0x05 || typ == 0x06 || (large && (typ == 0x07 || typ == 0x08))
+		if inlined {
+			values[i], err = mysqlJSONValue(typ, b[pos+1:pos+1+size])
+		} else {
+			var off int
+			if off, err = read(pos+1, size); err == nil {
+				if off >= len(b) {
+					return nil, errMySQLJSON
+				}
+				values[i], err = mysqlJSONValue(typ, b[off:])
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		pos += 1 + size
+	}
+
+	if !object {
+		return values, nil
+	}
+	obj := make(map[string]interface{}, count)
+	for i, k := range keys {
+		obj[k] = values[i]
+	}
+	return obj, nil
+}
+
+//------------------------------------------------------------------------------
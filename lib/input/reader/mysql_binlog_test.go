@@ -0,0 +1,244 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func TestMySQLGTIDSet(t *testing.T) {
+	set, err := parseMySQLGTIDSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:7,\n8c3cfe5a-1bba-11ea-8b1f-0242ac110002:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,8c3cfe5a-1bba-11ea-8b1f-0242ac110002:1", set.String(); exp != act {
+		t.Errorf("Wrong GTID set: %v != %v", act, exp)
+	}
+
+	set.add("3e11fa47-71ca-11e1-9e33-c80aa9429562", 6)
+	set.add("3e11fa47-71ca-11e1-9e33-c80aa9429562", 9)
+	set.add("8c3cfe5a-1bba-11ea-8b1f-0242ac110002", 1)
+	if exp, act := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7:9,8c3cfe5a-1bba-11ea-8b1f-0242ac110002:1", set.String(); exp != act {
+		t.Errorf("Wrong GTID set: %v != %v", act, exp)
+	}
+
+	b, err := set.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Number of SIDs, then each SID with its number of intervals and the
+	// start and end of each interval.
+	if exp, act := 8+(16+8+2*16)+(16+8+16), len(b); exp != act {
+		t.Errorf("Wrong encoded length: %v != %v", act, exp)
+	}
+
+	for _, s := range []string{"nope:1-5", "3e11fa47-71ca-11e1-9e33-c80aa9429562:a-5"} {
+		if _, err = parseMySQLGTIDSet(s); err == nil {
+			t.Errorf("Expected error from GTID set: %v", s)
+		}
+	}
+}
+
+func TestMySQLDecimal(t *testing.T) {
+	tests := []struct {
+		data      []byte
+		precision int
+		scale     int
+		exp       string
+	}{
+		{[]byte{0x81, 0x0d, 0xfb, 0x38, 0xd2, 0x04, 0xd2}, 14, 4, "1234567890.1234"},
+		{[]byte{0x7e, 0xf2, 0x04, 0xc7, 0x2d, 0xfb, 0x2d}, 14, 4, "-1234567890.1234"},
+		{[]byte{0x80, 0x00, 0x00, 0x00, 0x01}, 10, 2, "0.01"},
+		{[]byte{0xaa}, 2, 0, "42"},
+	}
+	for _, test := range tests {
+		r := &mysqlReader{b: test.data}
+		if act := r.decimal(test.precision, test.scale); act != test.exp {
+			t.Errorf("Wrong decimal: %v != %v", act, test.exp)
+		}
+		if r.err != nil {
+			t.Error(r.err)
+		}
+	}
+}
+
+func TestMySQLTemporal(t *testing.T) {
+	ymd := int64((2020*13+3)<<5 | 4)
+	hms := int64(5<<12 | 6<<6 | 7)
+	dt := uint64(ymd<<17|hms) + 0x8000000000
+
+	r := &mysqlReader{b: []byte{byte(dt >> 32), byte(dt >> 24), byte(dt >> 16), byte(dt >> 8), byte(dt), 0x30, 0x39}}
+	if exp, act := "2020-03-04 05:06:07.1234", r.value(mysqlTypeDateTime2, 4, mysqlColumn{}); exp != act {
+		t.Errorf("Wrong datetime: %v != %v", act, exp)
+	}
+
+	date := uint32(2020<<9 | 3<<5 | 4)
+	r = &mysqlReader{b: []byte{byte(date), byte(date >> 8), byte(date >> 16)}}
+	if exp, act := "2020-03-04", r.value(mysqlTypeDate, 0, mysqlColumn{}); exp != act {
+		t.Errorf("Wrong date: %v != %v", act, exp)
+	}
+
+	tm := uint32(hms) + 0x800000
+	r = &mysqlReader{b: []byte{byte(tm >> 16), byte(tm >> 8), byte(tm)}}
+	if exp, act := "05:06:07", r.value(mysqlTypeTime2, 0, mysqlColumn{}); exp != act {
+		t.Errorf("Wrong time: %v != %v", act, exp)
+	}
+
+	tm = 0x800000 - uint32(hms)
+	r = &mysqlReader{b: []byte{byte(tm >> 16), byte(tm >> 8), byte(tm)}}
+	if exp, act := "-05:06:07", r.value(mysqlTypeTime2, 0, mysqlColumn{}); exp != act {
+		t.Errorf("Wrong time: %v != %v", act, exp)
+	}
+}
+
+func TestMySQLJSON(t *testing.T) {
+	data := []byte{
+		0x00,       // Small object
+		0x02, 0x00, // Count
+		0x20, 0x00, // Size
+		0x12, 0x00, 0x01, 0x00, // Key "a"
+		0x13, 0x00, 0x01, 0x00, // Key "b"
+		0x05, 0x01, 0x00, // Inlined int16
+		0x02, 0x14, 0x00, // Small array
+		'a', 'b',
+		0x02, 0x00, // Count
+		0x0c, 0x00, // Size
+		0x04, 0x01, 0x00, // Inlined true
+		0x0c, 0x0a, 0x00, // String
+		0x01, 'x',
+	}
+
+	v, err := decodeMySQLJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]interface{}{
+		"a": int64(1),
+		"b": []interface{}{true, "x"},
+	}
+	if !reflect.DeepEqual(exp, v) {
+		t.Errorf("Wrong JSON value: %v != %v", v, exp)
+	}
+
+	if _, err = decodeMySQLJSON(data[:20]); err == nil {
+		t.Error("Expected error from truncated value")
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func mysqlTestTableMap() []byte {
+	return []byte{
+		0x01, 0, 0, 0, 0, 0, // Table ID
+		0, 0, // Flags
+		4, 's', 'h', 'o', 'p', 0,
+		5, 'u', 's', 'e', 'r', 's', 0,
+		3, // Column count
+		mysqlTypeLong, mysqlTypeVarchar, mysqlTypeString,
+		4,          // Metadata length
+		0x80, 0x00, // Varchar max length
+		mysqlTypeEnum, 0x01, // Enum of one byte
+		0x02, // Null bitmap
+	}
+}
+
+func TestMySQLRows(t *testing.T) {
+	id, table, err := parseMySQLTableMap(mysqlTestTableMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := uint64(1), id; exp != act {
+		t.Errorf("Wrong table ID: %v != %v", act, exp)
+	}
+	table.columns = []mysqlColumn{
+		{name: "id", unsigned: true},
+		{name: "name"},
+		{name: "state", values: parseMySQLMembers("enum('a','b''c')")},
+	}
+	tables := map[uint64]*mysqlTable{id: table}
+
+	write := []byte{
+		0x01, 0, 0, 0, 0, 0, 0, 0,
+		0x02, 0x00, // Extra data length
+		3, 0x07,
+		0x00, 0x01, 0x00, 0x00, 0x00, 3, 'f', 'o', 'o', 0x02,
+		0x02, 0x02, 0x00, 0x00, 0x00, 0x01,
+	}
+	update := []byte{
+		0x01, 0, 0, 0, 0, 0, 0, 0,
+		0x02, 0x00,
+		3, 0x07, 0x03,
+		0x00, 0x01, 0x00, 0x00, 0x00, 3, 'f', 'o', 'o', 0x02,
+		0x00, 0x01, 0x00, 0x00, 0x00, 3, 'b', 'a', 'r',
+	}
+	del := []byte{
+		0x01, 0, 0, 0, 0, 0, 0, 0,
+		0x02, 0x00,
+		3, 0x01,
+		0x00, 0xff, 0xff, 0xff, 0xff,
+	}
+
+	var docs []string
+	for _, ev := range []struct {
+		typ  byte
+		body []byte
+	}{
+		{mysqlEventWriteRowsV2, write},
+		{mysqlEventUpdateRowsV2, update},
+		{mysqlEventDeleteRowsV2, del},
+	} {
+		changes, err := parseMySQLRows(ev.typ, ev.body, tables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range changes {
+			b, err := json.Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs = append(docs, string(b))
+		}
+	}
+
+	exp := []string{
+		`{"operation":"insert","schema":"shop","table":"users","gtid":"","before":null,"after":{"id":1,"name":"foo","state":"b'c"}}`,
+		`{"operation":"insert","schema":"shop","table":"users","gtid":"","before":null,"after":{"id":2,"name":null,"state":"a"}}`,
+		`{"operation":"update","schema":"shop","table":"users","gtid":"","before":{"id":1,"name":"foo","state":"b'c"},"after":{"id":1,"name":"bar"}}`,
+		`{"operation":"delete","schema":"shop","table":"users","gtid":"","before":{"id":4294967295},"after":null}`,
+	}
+	if !reflect.DeepEqual(exp, docs) {
+		t.Errorf("Wrong changes: %s != %s", docs, exp)
+	}
+
+	if _, err = parseMySQLRows(mysqlEventWriteRowsV2, write[:len(write)-2], tables); err == nil {
+		t.Error("Expected error from truncated rows")
+	}
+	write[0] = 0x02
+	if _, err = parseMySQLRows(mysqlEventWriteRowsV2, write, tables); err == nil {
+		t.Error("Expected error from unknown table")
+	}
+}
+
+//------------------------------------------------------------------------------
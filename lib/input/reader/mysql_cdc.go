@@ -0,0 +1,495 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/go-sql-driver/mysql"
+)
+
+//------------------------------------------------------------------------------
+
+// MySQLCDCConfig contains configuration fields for the MySQLCDC input type.
+type MySQLCDCConfig struct {
+	DSN               string `json:"dsn" yaml:"dsn"`
+	ServerID          uint32 `json:"server_id" yaml:"server_id"`
+	Cache             string `json:"cache" yaml:"cache"`
+	CacheKey          string `json:"cache_key" yaml:"cache_key"`
+	HeartbeatInterval string `json:"heartbeat_interval" yaml:"heartbeat_interval"`
+}
+
+// NewMySQLCDCConfig creates a new MySQLCDCConfig with default values.
+func NewMySQLCDCConfig() MySQLCDCConfig {
+	return MySQLCDCConfig{
+		DSN:               "root@tcp(localhost:3306)/",
+		ServerID:          1000,
+		Cache:             "",
+		CacheKey:          "mysql_cdc_gtid_set",
+		HeartbeatInterval: "30s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// mysqlTransaction is a committed transaction read from the binlog.
+type mysqlTransaction struct {
+	sid     string
+	gno     int64
+	changes []mysqlChange
+}
+
+// MySQLCDC is an input type that consumes row changes from the binlog of a
+// MySQL server, and checkpoints the GTIDs of acknowledged transactions in a
+// cache.
+type MySQLCDC struct {
+	conf      MySQLCDCConfig
+	dsn       *mysql.Config
+	heartbeat time.Duration
+	cache     types.Cache
+
+	// executed is the GTID set of transactions that have either been
+	// acknowledged or contained no changes, and is where the binlog stream is
+	// resumed from.
+	gtidMut  sync.Mutex
+	executed mysqlGTIDSet
+
+	connMut   sync.Mutex
+	stream    *mysqlConn
+	meta      *mysqlConn
+	checksum  bool
+	txns      chan mysqlTransaction
+	closeChan chan struct{}
+	closeOnce sync.Once
+
+	stats metrics.Type
+	log   log.Modular
+}
+
+// NewMySQLCDC creates a new MySQLCDC input type.
+func NewMySQLCDC(
+	conf MySQLCDCConfig, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (*MySQLCDC, error) {
+	m := &MySQLCDC{
+		conf:      conf,
+		closeChan: make(chan struct{}),
+		stats:     stats,
+		log:       log,
+	}
+
+	var err error
+	if m.dsn, err = mysql.ParseDSN(conf.DSN); err != nil {
+		return nil, fmt.Errorf("failed to parse dsn: %v", err)
+	}
+	if len(conf.HeartbeatInterval) > 0 {
+		if m.heartbeat, err = time.ParseDuration(conf.HeartbeatInterval); err != nil {
+			return nil, fmt.Errorf("failed to parse heartbeat interval: %v", err)
+		}
+	}
+	if conf.ServerID == 0 {
+		return nil, errors.New("server id must be non-zero")
+	}
+	if len(conf.CacheKey) == 0 {
+		return nil, errors.New("a cache key must be specified")
+	}
+	if m.cache, err = mgr.GetCache(conf.Cache); err != nil {
+		return nil, fmt.Errorf("failed to obtain cache '%v': %v", conf.Cache, err)
+	}
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (m *MySQLCDC) dial(ctx context.Context) (*mysqlConn, error) {
+	// Reads are allowed to wait for a few missed heartbeats before the
+	// connection is considered lost.
+	return dialMySQL(ctx, m.dsn.Net, m.dsn.Addr, m.dsn.User, m.dsn.Passwd, m.heartbeat*3)
+}
+
+// loadExecuted obtains the GTID set to resume from, which is the checkpoint
+// within the cache when present, otherwise the transactions executed by the
+// server so far.
+func (m *MySQLCDC) loadExecuted(serverExecuted string) error {
+	m.gtidMut.Lock()
+	defer m.gtidMut.Unlock()
+
+	if m.executed != nil {
+		return nil
+	}
+
+	checkpoint, err := m.cache.Get(m.conf.CacheKey)
+	if err == nil {
+		if m.executed, err = parseMySQLGTIDSet(string(checkpoint)); err != nil {
+			return fmt.Errorf("failed to parse checkpoint: %v", err)
+		}
+		return nil
+	}
+	if err != types.ErrKeyNotFound {
+		return fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+
+	if m.executed, err = parseMySQLGTIDSet(serverExecuted); err != nil {
+		return err
+	}
+	if err = m.cache.Set(m.conf.CacheKey, []byte(m.executed.String())); err != nil {
+		m.executed = nil
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	return nil
+}
+
+// ConnectWithContext establishes a binlog stream from the server starting
+// after the last checkpoint.
+func (m *MySQLCDC) ConnectWithContext(ctx context.Context) error {
+	m.connMut.Lock()
+	defer m.connMut.Unlock()
+
+	select {
+	case <-m.closeChan:
+		return types.ErrTypeClosed
+	default:
+	}
+	if m.txns != nil {
+		return nil
+	}
+
+	meta, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := meta.query("SELECT @@GLOBAL.gtid_mode, @@GLOBAL.binlog_format, @@GLOBAL.binlog_checksum, @@GLOBAL.gtid_executed")
+	if err == nil && len(rows) != 1 {
+		err = errors.New("no server variables returned")
+	}
+	if err != nil {
+		meta.close()
+		return fmt.Errorf("failed to query server variables: %v", err)
+	}
+	if vars := rows[0]; vars[0] != "ON" {
+		err = fmt.Errorf("gtid_mode must be ON, got %v", vars[0])
+	} else if vars[1] != "ROW" {
+		err = fmt.Errorf("binlog_format must be ROW, got %v", vars[1])
+	} else {
+		m.checksum = vars[2] != "NONE"
+		err = m.loadExecuted(vars[3])
+	}
+	if err != nil {
+		meta.close()
+		return err
+	}
+
+	stream, err := m.dial(ctx)
+	if err != nil {
+		meta.close()
+		return err
+	}
+	if err = m.startDump(stream); err != nil {
+		meta.close()
+		stream.close()
+		return fmt.Errorf("failed to start binlog stream: %v", err)
+	}
+
+	m.meta, m.stream = meta, stream
+	m.txns = make(chan mysqlTransaction)
+	go m.loop(meta, stream, m.txns)
+
+	m.log.Infof("Receiving changes from MySQL binlog: %v\n", m.dsn.Addr)
+	return nil
+}
+
+func (m *MySQLCDC) startDump(stream *mysqlConn) error {
+	if err := stream.exec("SET @master_binlog_checksum = @@GLOBAL.binlog_checksum"); err != nil {
+		return err
+	}
+	if m.heartbeat > 0 {
+		if err := stream.exec(fmt.Sprintf("SET @master_heartbeat_period = %d", m.heartbeat.Nanoseconds())); err != nil {
+			return err
+		}
+	}
+	m.gtidMut.Lock()
+	err := stream.dumpGTID(m.conf.ServerID, m.executed)
+	m.gtidMut.Unlock()
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+func (m *MySQLCDC) loop(meta, stream *mysqlConn, txns chan<- mysqlTransaction) {
+	defer func() {
+		meta.close()
+		stream.close()
+		close(txns)
+	}()
+
+	tables := map[uint64]*mysqlTable{}
+	columns := map[string][]mysqlColumn{}
+
+	var txn mysqlTransaction
+	for {
+		ev, err := stream.readEvent()
+		if err == nil {
+			var commit bool
+			if commit, err = m.handleEvent(meta, ev, tables, columns, &txn); err == nil && commit {
+				if len(txn.changes) == 0 {
+					m.markExecuted(txn.sid, txn.gno)
+				} else {
+					select {
+					case txns <- txn:
+					case <-m.closeChan:
+						return
+					}
+				}
+				txn = mysqlTransaction{}
+			}
+		}
+		if err != nil {
+			select {
+			case <-m.closeChan:
+			default:
+				m.log.Errorf("Lost binlog stream: %v\n", err)
+			}
+			return
+		}
+	}
+}
+
+// handleEvent applies a binlog event to the current transaction, and returns
+// true if the transaction was committed.
+func (m *MySQLCDC) handleEvent(
+	meta *mysqlConn,
+	ev []byte,
+	tables map[uint64]*mysqlTable,
+	columns map[string][]mysqlColumn,
+	txn *mysqlTransaction,
+) (bool, error) {
+	minSize := mysqlEventHeaderSize
+	if m.checksum {
+		minSize += 4
+	}
+	if len(ev) < minSize {
+		return false, errors.New("binlog event is too short")
+	}
+	body := ev[mysqlEventHeaderSize:]
+	if m.checksum {
+		body = body[:len(body)-4]
+	}
+
+	switch evType := ev[4]; evType {
+	case mysqlEventGTID:
+		sid, gno, err := parseMySQLGTIDEvent(body)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse GTID event: %v", err)
+		}
+		*txn = mysqlTransaction{sid: sid, gno: gno}
+	case mysqlEventTableMap:
+		id, table, err := parseMySQLTableMap(body)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse table map event: %v", err)
+		}
+		key := table.schema + "." + table.name
+		cols, exists := columns[key]
+		if !exists {
+			if cols, err = m.tableColumns(meta, table.schema, table.name); err != nil {
+				return false, fmt.Errorf("failed to obtain columns of %v: %v", key, err)
+			}
+			columns[key] = cols
+		}
+		if len(cols) == len(table.types) {
+			table.columns = cols
+		} else {
+			m.log.Warnf("Columns of table %v do not match the binlog, column indexes will be used as names\n", key)
+		}
+		tables[id] = table
+	case mysqlEventWriteRowsV1, mysqlEventUpdateRowsV1, mysqlEventDeleteRowsV1,
+		mysqlEventWriteRowsV2, mysqlEventUpdateRowsV2, mysqlEventDeleteRowsV2:
+		changes, err := parseMySQLRows(evType, body, tables)
+		if err != nil {
+			return false, err
+		}
+		gtid := fmt.Sprintf("%v:%v", txn.sid, txn.gno)
+		for i := range changes {
+			changes[i].GTID = gtid
+		}
+		txn.changes = append(txn.changes, changes...)
+	case mysqlEventPartialUpdate:
+		return false, errors.New("partial JSON updates are not supported, binlog_row_value_options must be empty")
+	case mysqlEventXID:
+		return len(txn.sid) > 0, nil
+	case mysqlEventQuery:
+		query, err := parseMySQLQueryEvent(body)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse query event: %v", err)
+		}
+		if query == "BEGIN" {
+			return false, nil
+		}
+		if query != "COMMIT" {
+			// Statements other than transaction boundaries are DDL, which
+			// might change the columns of any table.
+			for k := range columns {
+				delete(columns, k)
+			}
+		}
+		return len(txn.sid) > 0, nil
+	}
+	return false, nil
+}
+
+func (m *MySQLCDC) tableColumns(meta *mysqlConn, schema, table string) ([]mysqlColumn, error) {
+	rows, err := meta.query(fmt.Sprintf(
+		"SELECT COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = %v AND TABLE_NAME = %v ORDER BY ORDINAL_POSITION",
+		mysqlQuote(schema), mysqlQuote(table),
+	))
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]mysqlColumn, len(rows))
+	for i, row := range rows {
+		colType := strings.ToLower(row[1])
+		cols[i] = mysqlColumn{
+			name:     row[0],
+			unsigned: strings.Contains(colType, "unsigned"),
+		}
+		if strings.HasPrefix(colType, "enum(") || strings.HasPrefix(colType, "set(") {
+			cols[i].values = parseMySQLMembers(row[1][strings.Index(row[1], "(")+1:])
+		}
+	}
+	return cols, nil
+}
+
+// parseMySQLMembers parses the quoted members of an ENUM or SET column type.
+func parseMySQLMembers(def string) []string {
+	var members []string
+	var current []byte
+	quoted := false
+	for i := 0; i < len(def); i++ {
+		c := def[i]
+		switch {
+		case quoted && c == '\'' && i+1 < len(def) && def[i+1] == '\'':
+			current = append(current, c)
+			i++
+		case c == '\'':
+			if quoted {
+				members = append(members, string(current))
+				current = nil
+			}
+			quoted = !quoted
+		case quoted:
+			current = append(current, c)
+		}
+	}
+	return members
+}
+
+func (m *MySQLCDC) markExecuted(sid string, gno int64) {
+	if len(sid) == 0 {
+		return
+	}
+	m.gtidMut.Lock()
+	m.executed.add(sid, gno)
+	m.gtidMut.Unlock()
+}
+
+func (m *MySQLCDC) checkpoint(sid string, gno int64) error {
+	m.gtidMut.Lock()
+	defer m.gtidMut.Unlock()
+	m.executed.add(sid, gno)
+	return m.cache.Set(m.conf.CacheKey, []byte(m.executed.String()))
+}
+
+//------------------------------------------------------------------------------
+
+// ReadWithContext reads the row changes of the next committed transaction from
+// the binlog as a message batch.
+func (m *MySQLCDC) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	m.connMut.Lock()
+	txns := m.txns
+	m.connMut.Unlock()
+	if txns == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	var txn mysqlTransaction
+	var open bool
+	select {
+	case txn, open = <-txns:
+	case <-ctx.Done():
+		return nil, nil, types.ErrTimeout
+	}
+	if !open {
+		m.connMut.Lock()
+		if m.txns == txns {
+			m.txns, m.meta, m.stream = nil, nil, nil
+		}
+		m.connMut.Unlock()
+		return nil, nil, types.ErrNotConnected
+	}
+
+	msg := message.New(nil)
+	for _, c := range txn.changes {
+		part := message.NewPart(nil)
+		if err := part.SetJSON(c); err != nil {
+			return nil, nil, fmt.Errorf("failed to serialise change: %v", err)
+		}
+		part.Metadata().
+			Set("mysql_cdc_operation", c.Operation).
+			Set("mysql_cdc_schema", c.Schema).
+			Set("mysql_cdc_table", c.Table).
+			Set("mysql_cdc_gtid", c.GTID)
+		msg.Append(part)
+	}
+
+	return msg, func(actx context.Context, res types.Response) error {
+		if res.Error() != nil {
+			return nil
+		}
+		return m.checkpoint(txn.sid, txn.gno)
+	}, nil
+}
+
+// CloseAsync shuts down the MySQLCDC input and stops processing requests.
+func (m *MySQLCDC) CloseAsync() {
+	m.closeOnce.Do(func() {
+		close(m.closeChan)
+	})
+	m.connMut.Lock()
+	if m.stream != nil {
+		m.stream.close()
+		m.meta.close()
+	}
+	m.connMut.Unlock()
+}
+
+// WaitForClose blocks until the MySQLCDC input has closed down.
+func (m *MySQLCDC) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,342 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type testCache struct {
+	mut    sync.Mutex
+	values map[string][]byte
+}
+
+func (c *testCache) Get(key string) ([]byte, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if v, exists := c.values[key]; exists {
+		return v, nil
+	}
+	return nil, types.ErrKeyNotFound
+}
+
+func (c *testCache) Set(key string, value []byte) error {
+	c.mut.Lock()
+	c.values[key] = value
+	c.mut.Unlock()
+	return nil
+}
+
+func (c *testCache) SetMulti(items map[string][]byte) error {
+	for k, v := range items {
+		c.Set(k, v)
+	}
+	return nil
+}
+
+func (c *testCache) Add(key string, value []byte) error {
+	return c.Set(key, value)
+}
+
+func (c *testCache) Delete(key string) error {
+	c.mut.Lock()
+	delete(c.values, key)
+	c.mut.Unlock()
+	return nil
+}
+
+func (c *testCache) CloseAsync() {}
+
+func (c *testCache) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+type testCacheMgr struct {
+	cache types.Cache
+}
+
+func (m *testCacheMgr) RegisterEndpoint(path, desc string, h http.HandlerFunc) {}
+func (m *testCacheMgr) GetCache(name string) (types.Cache, error) {
+	if name == "foocache" {
+		return m.cache, nil
+	}
+	return nil, types.ErrCacheNotFound
+}
+func (m *testCacheMgr) GetCondition(name string) (types.Condition, error) {
+	return nil, types.ErrConditionNotFound
+}
+func (m *testCacheMgr) GetRateLimit(name string) (types.RateLimit, error) {
+	return nil, types.ErrRateLimitNotFound
+}
+func (m *testCacheMgr) GetPlugin(name string) (interface{}, error) {
+	return nil, types.ErrPluginNotFound
+}
+func (m *testCacheMgr) GetPipe(name string) (<-chan types.Transaction, error) {
+	return nil, types.ErrPipeNotFound
+}
+func (m *testCacheMgr) SetPipe(name string, prod <-chan types.Transaction)   {}
+func (m *testCacheMgr) UnsetPipe(name string, prod <-chan types.Transaction) {}
+
+//------------------------------------------------------------------------------
+
+const mysqlTestSID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+// mysqlTestServer serves the parts of the MySQL protocol used by the MySQLCDC
+// input and streams a fixed list of binlog events.
+type mysqlTestServer struct {
+	t        *testing.T
+	l        net.Listener
+	events   [][]byte
+	dumpReqs chan []byte
+}
+
+func newMySQLTestServer(t *testing.T, events [][]byte) *mysqlTestServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mysqlTestServer{
+		t:        t,
+		l:        l,
+		events:   events,
+		dumpReqs: make(chan []byte, 10),
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *mysqlTestServer) handle(conn net.Conn) {
+	defer conn.Close()
+	c := &mysqlConn{conn: conn}
+
+	scramble := []byte("abcdefghijklmnopqrst")
+	var hs []byte
+	hs = append(hs, 10)
+	hs = append(append(hs, "8.0.20"...), 0)
+	hs = appendUint32(hs, 1)
+	hs = append(append(hs, scramble[:8]...), 0)
+	caps := uint32(mysqlClientProtocol41 | mysqlClientSecureConn | mysqlClientPluginAuth)
+	hs = append(hs, byte(caps), byte(caps>>8), 45, 2, 0, byte(caps>>16), byte(caps>>24), 21)
+	hs = append(hs, make([]byte, 10)...)
+	hs = append(append(hs, scramble[8:]...), 0)
+	hs = append(append(hs, mysqlNativePassword...), 0)
+	if err := c.writePacket(hs); err != nil {
+		return
+	}
+
+	pkt, err := c.readPacket()
+	if err != nil {
+		return
+	}
+	r := &mysqlReader{b: pkt[32:]}
+	user := r.nulString()
+	auth := r.next(int(r.byte()))
+	exp, _ := mysqlAuthResponse(mysqlNativePassword, scramble, "foopass")
+	if user != "foouser" || !bytes.Equal(auth, exp) {
+		c.writePacket([]byte{0xff, 0x15, 0x04, '#', '2', '8', '0', '0', '0', 'n', 'o', 'p', 'e'})
+		return
+	}
+	ok := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	c.writePacket(ok)
+
+	for {
+		c.seq = 0
+		if pkt, err = c.readPacket(); err != nil {
+			return
+		}
+		switch pkt[0] {
+		case mysqlComQuery:
+			query := string(pkt[1:])
+			switch {
+			case strings.HasPrefix(query, "SELECT @@GLOBAL"):
+				s.writeRows(c, [][]string{{"ON", "ROW", "NONE", mysqlTestSID + ":1-100"}})
+			case strings.HasPrefix(query, "SELECT COLUMN_NAME"):
+				s.writeRows(c, [][]string{{"id", "int(10) unsigned"}, {"name", "varchar(32)"}, {"state", "enum('a','b''c')"}})
+			default:
+				c.writePacket(ok)
+			}
+		case mysqlComBinlogDumpGTID:
+			s.dumpReqs <- pkt[1:]
+			for _, ev := range s.events {
+				if err = c.writePacket(append([]byte{0}, ev...)); err != nil {
+					return
+				}
+			}
+			io.Copy(ioutil.Discard, conn)
+			return
+		}
+	}
+}
+
+func (s *mysqlTestServer) writeRows(c *mysqlConn, rows [][]string) {
+	c.writePacket([]byte{byte(len(rows[0]))})
+	for range rows[0] {
+		c.writePacket([]byte{3, 'd', 'e', 'f'})
+	}
+	eof := []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+	c.writePacket(eof)
+	for _, row := range rows {
+		var pkt []byte
+		for _, v := range row {
+			pkt = append(append(pkt, byte(len(v))), v...)
+		}
+		c.writePacket(pkt)
+	}
+	c.writePacket(eof)
+}
+
+func mysqlTestEvent(typ byte, body []byte) []byte {
+	ev := make([]byte, mysqlEventHeaderSize)
+	ev[4] = typ
+	return append(ev, body...)
+}
+
+func mysqlTestGTID(gno byte) []byte {
+	sid, _ := mysqlUUIDBytes(mysqlTestSID)
+	body := append([]byte{0x01}, sid...)
+	body = append(body, gno, 0, 0, 0, 0, 0, 0, 0)
+	return mysqlTestEvent(mysqlEventGTID, body)
+}
+
+//------------------------------------------------------------------------------
+
+func TestMySQLCDCBadConfig(t *testing.T) {
+	mgr := &testCacheMgr{cache: &testCache{values: map[string][]byte{}}}
+
+	conf := NewMySQLCDCConfig()
+	if _, err := NewMySQLCDC(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+
+	conf.Cache = "foocache"
+	conf.DSN = "nope"
+	if _, err := NewMySQLCDC(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad dsn")
+	}
+}
+
+func TestMySQLCDCStream(t *testing.T) {
+	xid := mysqlTestEvent(mysqlEventXID, make([]byte, 8))
+	write := []byte{
+		0x01, 0, 0, 0, 0, 0, 0, 0,
+		0x02, 0x00,
+		3, 0x07,
+		0x00, 0x01, 0x00, 0x00, 0x00, 3, 'f', 'o', 'o', 0x02,
+	}
+	server := newMySQLTestServer(t, [][]byte{
+		mysqlTestGTID(6),
+		mysqlTestEvent(mysqlEventTableMap, mysqlTestTableMap()),
+		mysqlTestEvent(mysqlEventWriteRowsV2, write),
+		xid,
+		mysqlTestGTID(7),
+		xid,
+		mysqlTestGTID(8),
+		mysqlTestEvent(mysqlEventTableMap, mysqlTestTableMap()),
+		mysqlTestEvent(mysqlEventDeleteRowsV2, write),
+		xid,
+	})
+	defer server.l.Close()
+
+	cache := &testCache{values: map[string][]byte{
+		"gtids": []byte(mysqlTestSID + ":1-5"),
+	}}
+
+	conf := NewMySQLCDCConfig()
+	conf.DSN = "foouser:foopass@tcp(" + server.l.Addr().String() + ")/"
+	conf.Cache = "foocache"
+	conf.CacheKey = "gtids"
+
+	m, err := NewMySQLCDC(conf, &testCacheMgr{cache: cache}, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.CloseAsync()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	if err = m.ConnectWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case req := <-server.dumpReqs:
+		set, _ := parseMySQLGTIDSet(mysqlTestSID + ":1-5")
+		data, _ := set.encode()
+		if exp, act := data, req[22:]; !bytes.Equal(exp, act) {
+			t.Errorf("Wrong dump GTID set: %v != %v", act, exp)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out")
+	}
+
+	exp := []string{
+		`{"operation":"insert","schema":"shop","table":"users","gtid":"` + mysqlTestSID + `:6","before":null,"after":{"id":1,"name":"foo","state":"b'c"}}`,
+		`{"operation":"delete","schema":"shop","table":"users","gtid":"` + mysqlTestSID + `:8","before":{"id":1,"name":"foo","state":"b'c"},"after":null}`,
+	}
+	for _, e := range exp {
+		msg, ackFn, err := m.ReadWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := string(msg.Get(0).Get()); act != e {
+			t.Errorf("Wrong change: %v != %v", act, e)
+		}
+		if exp, act := "shop", msg.Get(0).Metadata().Get("mysql_cdc_schema"); exp != act {
+			t.Errorf("Wrong metadata: %v != %v", act, exp)
+		}
+		if err = ackFn(ctx, response.NewAck()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	checkpoint, _ := cache.Get("gtids")
+	if exp, act := mysqlTestSID+":1-8", string(checkpoint); exp != act {
+		t.Errorf("Wrong checkpoint: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,587 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+const (
+	mysqlMaxPacketSize = 1<<24 - 1
+
+	mysqlClientLongPassword = 0x00000001
+	mysqlClientLongFlag     = 0x00000004
+	mysqlClientProtocol41   = 0x00000200
+	mysqlClientTransactions = 0x00002000
+	mysqlClientSecureConn   = 0x00008000
+	mysqlClientPluginAuth   = 0x00080000
+
+	mysqlComQuery          = 0x03
+	mysqlComBinlogDumpGTID = 0x1e
+
+	mysqlBinlogThroughGTID = 0x04
+
+	mysqlNativePassword = "mysql_native_password"
+	mysqlCachingSHA2    = "caching_sha2_password"
+)
+
+// mysqlError is an error packet returned by a MySQL server.
+type mysqlError struct {
+	code    uint16
+	message string
+}
+
+func (e *mysqlError) Error() string {
+	return fmt.Sprintf("mysql error %v: %v", e.code, e.message)
+}
+
+func parseMySQLError(pkt []byte) error {
+	r := &mysqlReader{b: pkt[1:]}
+	e := &mysqlError{code: r.uint16()}
+	if rest := r.rest(); len(rest) > 0 && rest[0] == '#' && len(rest) >= 6 {
+		e.message = string(rest[6:])
+	} else {
+		e.message = string(rest)
+	}
+	return e
+}
+
+//------------------------------------------------------------------------------
+
+// mysqlConn is a minimal client of the MySQL protocol, supporting only what is
+// needed in order to run simple queries and read a binlog stream.
+type mysqlConn struct {
+	conn    net.Conn
+	seq     byte
+	timeout time.Duration
+}
+
+func dialMySQL(
+	ctx context.Context, network, addr, user, password string, timeout time.Duration,
+) (*mysqlConn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &mysqlConn{conn: conn, timeout: timeout}
+	if err = c.handshake(user, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *mysqlConn) close() error {
+	return c.conn.Close()
+}
+
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		if c.timeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+		}
+		var header [4]byte
+		if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+			return nil, err
+		}
+		l := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		c.seq = header[3] + 1
+
+		b := make([]byte, l)
+		if _, err := io.ReadFull(c.conn, b); err != nil {
+			return nil, err
+		}
+		payload = append(payload, b...)
+		if l < mysqlMaxPacketSize {
+			if len(payload) == 0 {
+				return nil, errors.New("received empty packet")
+			}
+			return payload, nil
+		}
+	}
+}
+
+func (c *mysqlConn) writePacket(payload []byte) error {
+	for {
+		n := len(payload)
+		if n > mysqlMaxPacketSize {
+			n = mysqlMaxPacketSize
+		}
+		pkt := make([]byte, 4, 4+n)
+		pkt[0], pkt[1], pkt[2], pkt[3] = byte(n), byte(n>>8), byte(n>>16), c.seq
+		c.seq++
+		if _, err := c.conn.Write(append(pkt, payload[:n]...)); err != nil {
+			return err
+		}
+		if payload = payload[n:]; n < mysqlMaxPacketSize {
+			return nil
+		}
+	}
+}
+
+func (c *mysqlConn) command(cmd byte, data []byte) error {
+	c.seq = 0
+	return c.writePacket(append([]byte{cmd}, data...))
+}
+
+//------------------------------------------------------------------------------
+
+func (c *mysqlConn) handshake(user, password string) error {
+	pkt, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if pkt[0] == 0xff {
+		return parseMySQLError(pkt)
+	}
+	if pkt[0] != 10 {
+		return fmt.Errorf("unsupported protocol version: %v", pkt[0])
+	}
+
+	r := &mysqlReader{b: pkt[1:]}
+	r.nulString() // Server version
+	r.uint32()    // Connection ID
+	scramble := append([]byte(nil), r.next(8)...)
+	r.byte()
+	caps := uint32(r.uint16())
+	plugin := mysqlNativePassword
+	if r.len() > 0 {
+		r.byte()   // Character set
+		r.uint16() // Status flags
+		caps |= uint32(r.uint16()) << 16
+		authLen := int(r.byte())
+		r.next(10)
+		if caps&mysqlClientSecureConn != 0 {
+			if authLen -= 8; authLen < 13 {
+				authLen = 13
+			}
+			scramble = append(scramble, r.next(authLen-1)...)
+			r.byte()
+		}
+		if caps&mysqlClientPluginAuth != 0 {
+			plugin = r.nulString()
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("failed to parse handshake: %v", r.err)
+	}
+	if caps&mysqlClientProtocol41 == 0 {
+		return errors.New("server does not support protocol 4.1")
+	}
+
+	auth, err := mysqlAuthResponse(plugin, scramble, password)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	buf = appendUint32(buf, mysqlClientLongPassword|mysqlClientLongFlag|mysqlClientProtocol41|
+		mysqlClientTransactions|mysqlClientSecureConn|mysqlClientPluginAuth)
+	buf = appendUint32(buf, 0)
+	buf = append(buf, 45) // utf8mb4_general_ci
+	buf = append(buf, make([]byte, 23)...)
+	buf = append(append(buf, user...), 0)
+	buf = append(append(buf, byte(len(auth))), auth...)
+	buf = append(append(buf, plugin...), 0)
+	if err = c.writePacket(buf); err != nil {
+		return err
+	}
+	return c.authResult(plugin, scramble, password)
+}
+
+func (c *mysqlConn) authResult(plugin string, scramble []byte, password string) error {
+	for {
+		pkt, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		switch pkt[0] {
+		case 0x00:
+			return nil
+		case 0xff:
+			return parseMySQLError(pkt)
+		case 0xfe:
+			r := &mysqlReader{b: pkt[1:]}
+			plugin = r.nulString()
+			scramble = append([]byte(nil), r.rest()...)
+			if l := len(scramble); l > 0 && scramble[l-1] == 0 {
+				scramble = scramble[:l-1]
+			}
+			auth, err := mysqlAuthResponse(plugin, scramble, password)
+			if err != nil {
+				return err
+			}
+			if err = c.writePacket(auth); err != nil {
+				return err
+			}
+		case 0x01:
+			if plugin != mysqlCachingSHA2 {
+				return fmt.Errorf("unexpected auth data for plugin: %v", plugin)
+			}
+			switch {
+			case len(pkt) == 2 && pkt[1] == 3:
+				// Fast authentication succeeded, an OK packet follows.
+			case len(pkt) == 2 && pkt[1] == 4:
+				// Full authentication is required, which without TLS means the
+				// password must be encrypted with the public key of the server.
+				if err = c.writePacket([]byte{2}); err != nil {
+					return err
+				}
+			default:
+				enc, err := mysqlEncryptPassword(password, scramble, pkt[1:])
+				if err != nil {
+					return err
+				}
+				if err = c.writePacket(enc); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected auth packet: %v", pkt[0])
+		}
+	}
+}
+
+func mysqlAuthResponse(plugin string, scramble []byte, password string) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, nil
+	}
+	switch plugin {
+	case mysqlNativePassword:
+		// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		h := sha1.Sum([]byte(password))
+		hh := sha1.Sum(h[:])
+		s := sha1.New()
+		s.Write(scramble)
+		s.Write(hh[:])
+		res := s.Sum(nil)
+		for i := range res {
+			res[i] ^= h[i]
+		}
+		return res, nil
+	case mysqlCachingSHA2:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		h := sha256.Sum256([]byte(password))
+		hh := sha256.Sum256(h[:])
+		s := sha256.New()
+		s.Write(hh[:])
+		s.Write(scramble)
+		res := s.Sum(nil)
+		for i := range res {
+			res[i] ^= h[i]
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("unsupported auth plugin: %v", plugin)
+}
+
+func mysqlEncryptPassword(password string, scramble, key []byte) ([]byte, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("failed to decode server public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("server public key is not an RSA key")
+	}
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaPub, plain, nil)
+}
+
+//------------------------------------------------------------------------------
+
+// exec runs a statement that returns no rows.
+func (c *mysqlConn) exec(query string) error {
+	if err := c.command(mysqlComQuery, []byte(query)); err != nil {
+		return err
+	}
+	pkt, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	switch pkt[0] {
+	case 0x00:
+		return nil
+	case 0xff:
+		return parseMySQLError(pkt)
+	}
+	return errors.New("unexpected result from statement")
+}
+
+// query runs a statement and returns its rows as strings, where NULL values
+// are empty.
+func (c *mysqlConn) query(query string) ([][]string, error) {
+	if err := c.command(mysqlComQuery, []byte(query)); err != nil {
+		return nil, err
+	}
+	pkt, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch pkt[0] {
+	case 0x00:
+		return nil, nil
+	case 0xff:
+		return nil, parseMySQLError(pkt)
+	}
+
+	r := &mysqlReader{b: pkt}
+	cols := int(r.lenencInt())
+
+	// Column definitions followed by an EOF packet.
+	for i := 0; i <= cols; i++ {
+		if _, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+	}
+
+	var rows [][]string
+	for {
+		if pkt, err = c.readPacket(); err != nil {
+			return nil, err
+		}
+		if pkt[0] == 0xfe && len(pkt) < 9 {
+			return rows, nil
+		}
+		if pkt[0] == 0xff {
+			return nil, parseMySQLError(pkt)
+		}
+		r = &mysqlReader{b: pkt}
+		row := make([]string, cols)
+		for i := range row {
+			if r.len() > 0 && r.b[0] == 0xfb {
+				r.byte()
+				continue
+			}
+			row[i] = string(r.next(int(r.lenencInt())))
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("failed to parse row: %v", r.err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// dumpGTID requests a binlog stream of all transactions not within a GTID set.
+func (c *mysqlConn) dumpGTID(serverID uint32, set mysqlGTIDSet) error {
+	data, err := set.encode()
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	buf = append(buf, mysqlBinlogThroughGTID, 0)
+	buf = appendUint32(buf, serverID)
+	buf = appendUint32(buf, 0) // Binlog name length
+	buf = appendUint64(buf, 4) // Binlog position
+	buf = appendUint32(buf, uint32(len(data)))
+	buf = append(buf, data...)
+	return c.command(mysqlComBinlogDumpGTID, buf)
+}
+
+// readEvent reads the next event of a binlog stream.
+func (c *mysqlConn) readEvent() ([]byte, error) {
+	pkt, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch pkt[0] {
+	case 0x00:
+		return pkt[1:], nil
+	case 0xff:
+		return nil, parseMySQLError(pkt)
+	case 0xfe:
+		return nil, io.EOF
+	}
+	return nil, fmt.Errorf("unexpected binlog packet: %v", pkt[0])
+}
+
+//------------------------------------------------------------------------------
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// mysqlQuote returns a string literal for use within a query.
+func mysqlQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+//------------------------------------------------------------------------------
+
+type mysqlGTIDInterval struct {
+	start, end int64 // End is exclusive
+}
+
+// mysqlGTIDSet is a set of transactions identified by the UUID of their
+// originating server and a sequence number.
+type mysqlGTIDSet map[string][]mysqlGTIDInterval
+
+func parseMySQLGTIDSet(s string) (mysqlGTIDSet, error) {
+	set := mysqlGTIDSet{}
+	for _, sidStr := range strings.Split(s, ",") {
+		if sidStr = strings.TrimSpace(sidStr); len(sidStr) == 0 {
+			continue
+		}
+		parts := strings.Split(sidStr, ":")
+		sid := strings.ToLower(parts[0])
+		if _, err := mysqlUUIDBytes(sid); err != nil {
+			return nil, fmt.Errorf("invalid GTID set '%v': %v", s, err)
+		}
+		for _, ivStr := range parts[1:] {
+			bounds := strings.SplitN(ivStr, "-", 2)
+			start, err := strconv.ParseInt(bounds[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GTID set '%v': %v", s, err)
+			}
+			end := start
+			if len(bounds) == 2 {
+				if end, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid GTID set '%v': %v", s, err)
+				}
+			}
+			set[sid] = append(set[sid], mysqlGTIDInterval{start: start, end: end + 1})
+		}
+		set.normalise(sid)
+	}
+	return set, nil
+}
+
+func (g mysqlGTIDSet) normalise(sid string) {
+	ivs := g[sid]
+	sort.Slice(ivs, func(i, j int) bool {
+		return ivs[i].start < ivs[j].start
+	})
+	var merged []mysqlGTIDInterval
+	for _, iv := range ivs {
+		if l := len(merged); l > 0 && merged[l-1].end >= iv.start {
+			if iv.end > merged[l-1].end {
+				merged[l-1].end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	g[sid] = merged
+}
+
+func (g mysqlGTIDSet) add(sid string, gno int64) {
+	for _, iv := range g[sid] {
+		if gno >= iv.start && gno < iv.end {
+			return
+		}
+	}
+	g[sid] = append(g[sid], mysqlGTIDInterval{start: gno, end: gno + 1})
+	g.normalise(sid)
+}
+
+func (g mysqlGTIDSet) String() string {
+	sids := make([]string, 0, len(g))
+	for sid := range g {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+
+	var sets []string
+	for _, sid := range sids {
+		s := sid
+		for _, iv := range g[sid] {
+			s += ":" + strconv.FormatInt(iv.start, 10)
+			if iv.end-1 > iv.start {
+				s += "-" + strconv.FormatInt(iv.end-1, 10)
+			}
+		}
+		sets = append(sets, s)
+	}
+	return strings.Join(sets, ",")
+}
+
+func (g mysqlGTIDSet) encode() ([]byte, error) {
+	sids := make([]string, 0, len(g))
+	for sid := range g {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+
+	buf := appendUint64(nil, uint64(len(sids)))
+	for _, sid := range sids {
+		uuid, err := mysqlUUIDBytes(sid)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, uuid...)
+		buf = appendUint64(buf, uint64(len(g[sid])))
+		for _, iv := range g[sid] {
+			buf = appendUint64(buf, uint64(iv.start))
+			buf = appendUint64(buf, uint64(iv.end))
+		}
+	}
+	return buf, nil
+}
+
+func mysqlUUIDBytes(sid string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.Replace(sid, "-", "", -1))
+	if err == nil && len(b) != 16 {
+		err = errors.New("wrong UUID length")
+	}
+	return b, err
+}
+
+func mysqlUUIDString(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+//------------------------------------------------------------------------------
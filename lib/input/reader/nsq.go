@@ -22,6 +22,7 @@ package reader
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
 	llog "log"
 	"strings"
@@ -40,13 +41,16 @@ import (
 
 // NSQConfig contains configuration fields for the NSQ input type.
 type NSQConfig struct {
-	Addresses       []string           `json:"nsqd_tcp_addresses" yaml:"nsqd_tcp_addresses"`
-	LookupAddresses []string           `json:"lookupd_http_addresses" yaml:"lookupd_http_addresses"`
-	Topic           string             `json:"topic" yaml:"topic"`
-	Channel         string             `json:"channel" yaml:"channel"`
-	UserAgent       string             `json:"user_agent" yaml:"user_agent"`
-	MaxInFlight     int                `json:"max_in_flight" yaml:"max_in_flight"`
-	Batching        batch.PolicyConfig `json:"batching" yaml:"batching"`
+	Addresses          []string           `json:"nsqd_tcp_addresses" yaml:"nsqd_tcp_addresses"`
+	LookupAddresses    []string           `json:"lookupd_http_addresses" yaml:"lookupd_http_addresses"`
+	Topic              string             `json:"topic" yaml:"topic"`
+	Channel            string             `json:"channel" yaml:"channel"`
+	EphemeralChannel   bool               `json:"ephemeral_channel" yaml:"ephemeral_channel"`
+	UserAgent          string             `json:"user_agent" yaml:"user_agent"`
+	MaxInFlight        int                `json:"max_in_flight" yaml:"max_in_flight"`
+	MaxInFlightPerConn int                `json:"max_in_flight_per_connection" yaml:"max_in_flight_per_connection"`
+	MaxBackoffDuration string             `json:"max_backoff_duration" yaml:"max_backoff_duration"`
+	Batching           batch.PolicyConfig `json:"batching" yaml:"batching"`
 }
 
 // NewNSQConfig creates a new NSQConfig with default values.
@@ -54,13 +58,16 @@ func NewNSQConfig() NSQConfig {
 	batching := batch.NewPolicyConfig()
 	batching.Count = 1
 	return NSQConfig{
-		Addresses:       []string{"localhost:4150"},
-		LookupAddresses: []string{"localhost:4161"},
-		Topic:           "benthos_messages",
-		Channel:         "benthos_stream",
-		UserAgent:       "benthos_consumer",
-		MaxInFlight:     100,
-		Batching:        batching,
+		Addresses:          []string{"localhost:4150"},
+		LookupAddresses:    []string{"localhost:4161"},
+		Topic:              "benthos_messages",
+		Channel:            "benthos_stream",
+		EphemeralChannel:   false,
+		UserAgent:          "benthos_consumer",
+		MaxInFlight:        100,
+		MaxInFlightPerConn: 0,
+		MaxBackoffDuration: "2m",
+		Batching:           batching,
 	}
 }
 
@@ -75,6 +82,8 @@ type NSQ struct {
 
 	addresses       []string
 	lookupAddresses []string
+	channel         string
+	maxBackoff      time.Duration
 	conf            NSQConfig
 	stats           metrics.Type
 	log             log.Modular
@@ -91,6 +100,19 @@ func NewNSQ(conf NSQConfig, log log.Modular, stats metrics.Type) (*NSQ, error) {
 		log:              log,
 		internalMessages: make(chan *nsq.Message),
 		interruptChan:    make(chan struct{}),
+		channel:          conf.Channel,
+	}
+	if conf.EphemeralChannel && !strings.HasSuffix(n.channel, "#ephemeral") {
+		n.channel += "#ephemeral"
+	}
+	if len(conf.MaxBackoffDuration) > 0 {
+		var err error
+		if n.maxBackoff, err = time.ParseDuration(conf.MaxBackoffDuration); err != nil {
+			return nil, fmt.Errorf("failed to parse max_backoff_duration string: %v", err)
+		}
+	}
+	if conf.MaxInFlightPerConn < 0 {
+		return nil, fmt.Errorf("max_in_flight_per_connection must not be negative: %v", conf.MaxInFlightPerConn)
 	}
 	for _, addr := range conf.Addresses {
 		for _, splitAddr := range strings.Split(addr, ",") {
@@ -143,9 +165,15 @@ func (n *NSQ) ConnectWithContext(ctx context.Context) (err error) {
 	cfg := nsq.NewConfig()
 	cfg.UserAgent = n.conf.UserAgent
 	cfg.MaxInFlight = n.conf.MaxInFlight
+	if len(n.conf.MaxBackoffDuration) > 0 {
+		cfg.MaxBackoffDuration = n.maxBackoff
+	}
+	if n.conf.MaxInFlightPerConn > 0 {
+		cfg.MaxInFlight = n.conf.MaxInFlightPerConn
+	}
 
 	var consumer *nsq.Consumer
-	if consumer, err = nsq.NewConsumer(n.conf.Topic, n.conf.Channel, cfg); err != nil {
+	if consumer, err = nsq.NewConsumer(n.conf.Topic, n.channel, cfg); err != nil {
 		return
 	}
 
@@ -161,11 +189,35 @@ func (n *NSQ) ConnectWithContext(ctx context.Context) (err error) {
 		return
 	}
 
+	if n.conf.MaxInFlightPerConn > 0 {
+		go n.scaleMaxInFlight(consumer)
+	}
+
 	n.consumer = consumer
 	n.log.Infof("Receiving NSQ messages from addresses: %s\n", n.addresses)
 	return
 }
 
+// scaleMaxInFlight keeps the total max in flight of a consumer proportional to
+// its number of connections, as the NSQ client otherwise divides the total
+// evenly across them, until the consumer is stopped.
+func (n *NSQ) scaleMaxInFlight(consumer *nsq.Consumer) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		conns := consumer.Stats().Connections
+		if conns < 1 {
+			conns = 1
+		}
+		consumer.ChangeMaxInFlight(n.conf.MaxInFlightPerConn * conns)
+		select {
+		case <-ticker.C:
+		case <-consumer.StopChan:
+			return
+		}
+	}
+}
+
 // disconnect safely closes a connection to an NSQ server.
 func (n *NSQ) disconnect() error {
 	n.cMut.Lock()
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+func TestNSQConfigFields(t *testing.T) {
+	conf := NewNSQConfig()
+	conf.Channel = "foo"
+	conf.EphemeralChannel = true
+	conf.MaxBackoffDuration = "5s"
+
+	n, err := NewNSQ(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "foo#ephemeral", n.channel; exp != act {
+		t.Errorf("Wrong channel: %v != %v", act, exp)
+	}
+	if exp, act := time.Second*5, n.maxBackoff; exp != act {
+		t.Errorf("Wrong max backoff: %v != %v", act, exp)
+	}
+
+	conf.Channel = "bar#ephemeral"
+	if n, err = NewNSQ(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "bar#ephemeral", n.channel; exp != act {
+		t.Errorf("Wrong channel: %v != %v", act, exp)
+	}
+}
+
+func TestNSQBadConfig(t *testing.T) {
+	conf := NewNSQConfig()
+	conf.MaxBackoffDuration = "nope"
+	if _, err := NewNSQ(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad max_backoff_duration")
+	}
+
+	conf = NewNSQConfig()
+	conf.MaxInFlightPerConn = -1
+	if _, err := NewNSQ(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from negative max_in_flight_per_connection")
+	}
+}
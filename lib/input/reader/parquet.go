@@ -0,0 +1,199 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/parquet"
+)
+
+//------------------------------------------------------------------------------
+
+// ParquetConfig contains configuration fields for the Parquet input type.
+type ParquetConfig struct {
+	Paths []string `json:"paths" yaml:"paths"`
+}
+
+// NewParquetConfig creates a new ParquetConfig with default values.
+func NewParquetConfig() ParquetConfig {
+	return ParquetConfig{
+		Paths: []string{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Parquet is an input type that reads rows from Parquet files as JSON
+// documents.
+type Parquet struct {
+	mut     sync.Mutex
+	targets []string
+	path    string
+	file    *os.File
+	rdr     *parquet.Reader
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewParquet creates a new Parquet input type.
+func NewParquet(conf ParquetConfig, log log.Modular, stats metrics.Type) (*Parquet, error) {
+	if len(conf.Paths) == 0 {
+		return nil, errors.New("at least one path must be specified")
+	}
+
+	p := &Parquet{
+		log:   log,
+		stats: stats,
+	}
+	for _, pattern := range conf.Paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse path pattern '%v': %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files found matching path '%v'", pattern)
+		}
+		p.targets = append(p.targets, matches...)
+	}
+	return p, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a connection.
+func (p *Parquet) Connect() error {
+	return p.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext opens the next target file if one is not already open.
+func (p *Parquet) ConnectWithContext(ctx context.Context) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.rdr != nil {
+		return nil
+	}
+	if len(p.targets) == 0 {
+		return types.ErrTypeClosed
+	}
+
+	path := p.targets[0]
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file '%v': %v", path, err)
+	}
+	p.targets = p.targets[1:]
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat file '%v': %v", path, err)
+	}
+	rdr, err := parquet.NewReader(file, info.Size())
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read file '%v': %v", path, err)
+	}
+
+	p.path, p.file, p.rdr = path, file, rdr
+	p.log.Infof("Reading %v rows from parquet file '%v'\n", rdr.NumRows(), path)
+	return nil
+}
+
+func (p *Parquet) closeFile() {
+	if p.file != nil {
+		p.file.Close()
+	}
+	p.file, p.rdr = nil, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ReadWithContext attempts to read a new row from the current Parquet file.
+func (p *Parquet) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	msg, err := p.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, noopAsyncAckFn, nil
+}
+
+// Read attempts to read a new row from the current Parquet file.
+func (p *Parquet) Read() (types.Message, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.rdr == nil {
+		return nil, types.ErrNotConnected
+	}
+
+	row, err := p.rdr.Next()
+	if err != nil {
+		p.closeFile()
+		if err == io.EOF {
+			return nil, types.ErrNotConnected
+		}
+		return nil, fmt.Errorf("failed to read file '%v': %v", p.path, err)
+	}
+
+	part := message.NewPart(nil)
+	if err = part.SetJSON(row); err != nil {
+		return nil, err
+	}
+	part.Metadata().Set("path", p.path)
+
+	msg := message.New(nil)
+	msg.Append(part)
+	return msg, nil
+}
+
+// Acknowledge instructs whether unacknowledged messages have been successfully
+// propagated.
+func (p *Parquet) Acknowledge(err error) error {
+	return nil
+}
+
+// CloseAsync shuts down the Parquet input and stops processing requests.
+func (p *Parquet) CloseAsync() {
+	p.mut.Lock()
+	p.closeFile()
+	p.mut.Unlock()
+}
+
+// WaitForClose blocks until the Parquet input has closed down.
+func (p *Parquet) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// testParquetFile is a parquet file with the columns id (int64) and name
+// (optional string) containing two rows.
+const testParquetFile = "UEFSMRUAFSAVICwVBBUAFQYVBgAAAQAAAAAAAAACAAAAAAAAABUAFRoVGiwVBBUAFQYVBgAAAgAAAAMBAwAAAGZvbxUCGTxIBnNjaGVtYRUEABUEJQAYAmlkABUMJQIYBG5hbWUlAAAWBBkcGSwmCBwVBBkVABkYAmlkFQAWBBZCFkImCAAAJkocFQwZFQAZGARuYW1lFQAWBBY8FjwmSgAAFoYBFgQAAGYAAABQQVIx"
+
+func TestParquetPaths(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "benthos_parquet_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	file, err := base64.StdEncoding.DecodeString(testParquetFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.parquet", "b.parquet"} {
+		if err = ioutil.WriteFile(filepath.Join(tmpDir, name), file, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := NewParquetConfig()
+	conf.Paths = []string{filepath.Join(tmpDir, "*.parquet")}
+
+	p, err := NewParquet(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.CloseAsync()
+
+	var rows, paths []string
+	for {
+		if err = p.Connect(); err == types.ErrTypeClosed {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := p.Read()
+		if err == types.ErrNotConnected {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, string(msg.Get(0).Get()))
+		paths = append(paths, filepath.Base(msg.Get(0).Metadata().Get("path")))
+	}
+
+	expRows := []string{
+		`{"id":1,"name":"foo"}`, `{"id":2,"name":null}`,
+		`{"id":1,"name":"foo"}`, `{"id":2,"name":null}`,
+	}
+	if !reflect.DeepEqual(expRows, rows) {
+		t.Errorf("Wrong rows: %s != %s", rows, expRows)
+	}
+	expPaths := []string{"a.parquet", "a.parquet", "b.parquet", "b.parquet"}
+	if !reflect.DeepEqual(expPaths, paths) {
+		t.Errorf("Wrong paths: %s != %s", paths, expPaths)
+	}
+}
+
+func TestParquetBadFile(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "benthos_parquet_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Write([]byte("not a parquet file"))
+	tmpFile.Close()
+
+	conf := NewParquetConfig()
+	conf.Paths = []string{tmpFile.Name()}
+
+	p, err := NewParquet(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Connect(); err == nil {
+		t.Error("Expected error from bad file")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,577 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// PostgresCDCConfig contains configuration fields for the PostgresCDC input
+// type.
+type PostgresCDCConfig struct {
+	DSN          string   `json:"dsn" yaml:"dsn"`
+	Slot         string   `json:"slot" yaml:"slot"`
+	CreateSlot   bool     `json:"create_slot" yaml:"create_slot"`
+	Plugin       string   `json:"plugin" yaml:"plugin"`
+	Publications []string `json:"publications" yaml:"publications"`
+	MaxChanges   int      `json:"max_changes" yaml:"max_changes"`
+	PollInterval string   `json:"poll_interval" yaml:"poll_interval"`
+}
+
+// NewPostgresCDCConfig creates a new PostgresCDCConfig with default values.
+func NewPostgresCDCConfig() PostgresCDCConfig {
+	return PostgresCDCConfig{
+		DSN:          "postgres://localhost:5432/postgres?sslmode=disable",
+		Slot:         "benthos",
+		CreateSlot:   false,
+		Plugin:       "wal2json",
+		Publications: []string{},
+		MaxChanges:   1000,
+		PollInterval: "1s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// cdcChange is a row change event emitted by the PostgresCDC input.
+type cdcChange struct {
+	Operation string                 `json:"operation"`
+	Schema    string                 `json:"schema"`
+	Table     string                 `json:"table"`
+	LSN       string                 `json:"lsn"`
+	XID       int64                  `json:"xid"`
+	Before    map[string]interface{} `json:"before"`
+	After     map[string]interface{} `json:"after"`
+}
+
+// cdcRow is a row returned by the logical decoding functions of a slot.
+type cdcRow struct {
+	lsn  string
+	xid  int64
+	data []byte
+}
+
+// PostgresCDC is an input type that consumes row changes from a PostgreSQL
+// logical replication slot.
+type PostgresCDC struct {
+	conf         PostgresCDCConfig
+	pollInterval time.Duration
+	peekQuery    string
+	peekArgs     []interface{}
+
+	dbMut sync.Mutex
+	db    *sql.DB
+
+	// pending holds a single token which is taken by a read and returned once
+	// the changes it read are acknowledged, as a slot can only be peeked from
+	// its confirmed position.
+	pending chan struct{}
+
+	// relations caches pgoutput relation messages by their ID.
+	relations map[uint32]pgRelation
+
+	stats metrics.Type
+	log   log.Modular
+}
+
+// NewPostgresCDC creates a new PostgresCDC input type.
+func NewPostgresCDC(
+	conf PostgresCDCConfig, log log.Modular, stats metrics.Type,
+) (*PostgresCDC, error) {
+	p := &PostgresCDC{
+		conf:      conf,
+		pending:   make(chan struct{}, 1),
+		relations: map[uint32]pgRelation{},
+		stats:     stats,
+		log:       log,
+	}
+	p.pending <- struct{}{}
+
+	if len(conf.Slot) == 0 {
+		return nil, errors.New("a slot must be specified")
+	}
+	if conf.MaxChanges <= 0 {
+		return nil, fmt.Errorf("max changes must be greater than zero: %v", conf.MaxChanges)
+	}
+
+	var err error
+	if p.pollInterval, err = time.ParseDuration(conf.PollInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse poll interval: %v", err)
+	}
+
+	switch conf.Plugin {
+	case "wal2json":
+		p.peekQuery = "SELECT lsn::text, xid::text::bigint, data::bytea FROM pg_logical_slot_peek_changes($1, NULL, $2, 'format-version', '2')"
+		p.peekArgs = []interface{}{conf.Slot, conf.MaxChanges}
+	case "pgoutput":
+		if len(conf.Publications) == 0 {
+			return nil, errors.New("at least one publication must be specified for the pgoutput plugin")
+		}
+		p.peekQuery = "SELECT lsn::text, xid::text::bigint, data FROM pg_logical_slot_peek_binary_changes($1, NULL, $2, 'proto_version', '1', 'publication_names', $3)"
+		p.peekArgs = []interface{}{conf.Slot, conf.MaxChanges, strings.Join(conf.Publications, ",")}
+	default:
+		return nil, fmt.Errorf("unrecognised plugin: %v", conf.Plugin)
+	}
+	return p, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext establishes a connection to the database, and creates the
+// replication slot if it does not exist and create_slot is true.
+func (p *PostgresCDC) ConnectWithContext(ctx context.Context) error {
+	p.dbMut.Lock()
+	defer p.dbMut.Unlock()
+
+	if p.db != nil {
+		return nil
+	}
+
+	db, err := sql.Open("postgres", p.conf.DSN)
+	if err != nil {
+		return err
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return err
+	}
+
+	if p.conf.CreateSlot {
+		var exists bool
+		if err = db.QueryRowContext(
+			ctx, "SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1)", p.conf.Slot,
+		).Scan(&exists); err != nil {
+			db.Close()
+			return fmt.Errorf("failed to check replication slot: %v", err)
+		}
+		if !exists {
+			if _, err = db.ExecContext(
+				ctx, "SELECT pg_create_logical_replication_slot($1, $2)", p.conf.Slot, p.conf.Plugin,
+			); err != nil {
+				db.Close()
+				return fmt.Errorf("failed to create replication slot: %v", err)
+			}
+			p.log.Infof("Created replication slot '%v'\n", p.conf.Slot)
+		}
+	}
+
+	p.db = db
+	p.log.Infof("Receiving changes from PostgreSQL replication slot: %v\n", p.conf.Slot)
+	return nil
+}
+
+func (p *PostgresCDC) release() {
+	p.pending <- struct{}{}
+}
+
+// ReadWithContext reads the next transactions of row changes from the slot.
+// Changes are not read again until the previous changes have been
+// acknowledged, and only then is the slot advanced past them.
+func (p *PostgresCDC) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	select {
+	case <-p.pending:
+	case <-ctx.Done():
+		return nil, nil, types.ErrTimeout
+	}
+
+	p.dbMut.Lock()
+	db := p.db
+	p.dbMut.Unlock()
+	if db == nil {
+		p.release()
+		return nil, nil, types.ErrNotConnected
+	}
+
+	rows, err := p.peek(ctx, db)
+	if err != nil {
+		p.release()
+		return nil, nil, err
+	}
+
+	changes, lastLSN, err := p.decodeRows(rows)
+	if err != nil {
+		p.release()
+		return nil, nil, err
+	}
+	if len(changes) == 0 {
+		// Transactions without changes to forward, such as those to tables
+		// outside of a publication, must still be confirmed in order for the
+		// slot to release their WAL.
+		if len(lastLSN) > 0 {
+			if err = p.advance(ctx, db, lastLSN); err != nil {
+				p.log.Errorf("Failed to advance replication slot: %v\n", err)
+			}
+		}
+		p.release()
+		select {
+		case <-time.After(p.pollInterval):
+		case <-ctx.Done():
+		}
+		return nil, nil, types.ErrTimeout
+	}
+
+	msg := message.New(nil)
+	for _, c := range changes {
+		part := message.NewPart(nil)
+		if err = part.SetJSON(c); err != nil {
+			p.release()
+			return nil, nil, fmt.Errorf("failed to serialise change: %v", err)
+		}
+		part.Metadata().
+			Set("postgres_cdc_operation", c.Operation).
+			Set("postgres_cdc_schema", c.Schema).
+			Set("postgres_cdc_table", c.Table).
+			Set("postgres_cdc_lsn", c.LSN)
+		msg.Append(part)
+	}
+
+	return msg, func(actx context.Context, res types.Response) error {
+		defer p.release()
+		if res.Error() != nil {
+			// The slot is left where it is and so the changes are read again.
+			return nil
+		}
+		return p.advance(actx, db, lastLSN)
+	}, nil
+}
+
+func (p *PostgresCDC) peek(ctx context.Context, db *sql.DB) ([]cdcRow, error) {
+	rows, err := db.QueryContext(ctx, p.peekQuery, p.peekArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes: %v", err)
+	}
+	defer rows.Close()
+
+	var result []cdcRow
+	for rows.Next() {
+		var r cdcRow
+		if err = rows.Scan(&r.lsn, &r.xid, &r.data); err != nil {
+			return nil, fmt.Errorf("failed to read changes: %v", err)
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+func (p *PostgresCDC) advance(ctx context.Context, db *sql.DB, lsn string) error {
+	_, err := db.ExecContext(ctx, "SELECT pg_replication_slot_advance($1, $2::pg_lsn)", p.conf.Slot, lsn)
+	return err
+}
+
+// decodeRows parses the rows of a slot into changes, returning the changes of
+// all complete transactions and the LSN of the last commit.
+func (p *PostgresCDC) decodeRows(rows []cdcRow) ([]cdcChange, string, error) {
+	var changes, txChanges []cdcChange
+	var lastLSN string
+
+	for _, r := range rows {
+		var c *cdcChange
+		var commit bool
+		var err error
+		if p.conf.Plugin == "pgoutput" {
+			c, commit, err = p.decodePGOutput(r.data)
+		} else {
+			c, commit, err = decodeWal2JSON(r.data)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode change at %v: %v", r.lsn, err)
+		}
+		if commit {
+			changes = append(changes, txChanges...)
+			txChanges = nil
+			lastLSN = r.lsn
+			continue
+		}
+		if c != nil {
+			c.LSN = r.lsn
+			c.XID = r.xid
+			txChanges = append(txChanges, *c)
+		}
+	}
+	return changes, lastLSN, nil
+}
+
+//------------------------------------------------------------------------------
+
+var wal2jsonOperations = map[string]string{
+	"I": "insert",
+	"U": "update",
+	"D": "delete",
+	"T": "truncate",
+}
+
+type wal2jsonColumn struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type wal2jsonRow struct {
+	Action   string           `json:"action"`
+	Schema   string           `json:"schema"`
+	Table    string           `json:"table"`
+	Columns  []wal2jsonColumn `json:"columns"`
+	Identity []wal2jsonColumn `json:"identity"`
+}
+
+func wal2jsonImage(cols []wal2jsonColumn) map[string]interface{} {
+	if len(cols) == 0 {
+		return nil
+	}
+	image := make(map[string]interface{}, len(cols))
+	for _, c := range cols {
+		image[c.Name] = c.Value
+	}
+	return image
+}
+
+// decodeWal2JSON parses a row of the wal2json format version 2.
+func decodeWal2JSON(data []byte) (*cdcChange, bool, error) {
+	var row wal2jsonRow
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&row); err != nil {
+		return nil, false, err
+	}
+	if row.Action == "C" {
+		return nil, true, nil
+	}
+	op, exists := wal2jsonOperations[row.Action]
+	if !exists {
+		return nil, false, nil
+	}
+	c := &cdcChange{
+		Operation: op,
+		Schema:    row.Schema,
+		Table:     row.Table,
+		Before:    wal2jsonImage(row.Identity),
+	}
+	if op != "delete" {
+		c.After = wal2jsonImage(row.Columns)
+	}
+	return c, false, nil
+}
+
+//------------------------------------------------------------------------------
+
+type pgColumn struct {
+	name    string
+	typeOID uint32
+}
+
+type pgRelation struct {
+	schema  string
+	table   string
+	columns []pgColumn
+}
+
+var errPGOutputShort = errors.New("message is too short")
+
+// pgReader reads the fields of a pgoutput message.
+type pgReader struct {
+	b   []byte
+	err error
+}
+
+func (r *pgReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.b) < n {
+		r.err = errPGOutputShort
+		return make([]byte, n)
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *pgReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *pgReader) uint16() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *pgReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(r.next(4))
+}
+
+func (r *pgReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, c := range r.b {
+		if c == 0 {
+			s := string(r.b[:i])
+			r.b = r.b[i+1:]
+			return s
+		}
+	}
+	r.err = errPGOutputShort
+	return ""
+}
+
+// tuple reads a TupleData structure, where columns with unchanged TOAST values
+// are omitted.
+func (r *pgReader) tuple(rel pgRelation) map[string]interface{} {
+	n := int(r.uint16())
+	image := make(map[string]interface{}, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		var col pgColumn
+		if i < len(rel.columns) {
+			col = rel.columns[i]
+		} else {
+			col.name = strconv.Itoa(i)
+		}
+		switch r.byte() {
+		case 'n':
+			image[col.name] = nil
+		case 'u':
+		case 't':
+			l := int(r.uint32())
+			image[col.name] = pgTextValue(col.typeOID, string(r.next(l)))
+		default:
+			r.err = errors.New("unrecognised tuple column kind")
+		}
+	}
+	return image
+}
+
+// pgTextValue converts the text representation of a value of common types
+// into its JSON equivalent, and leaves all others as strings.
+func pgTextValue(typeOID uint32, v string) interface{} {
+	switch typeOID {
+	case 16: // bool
+		return v == "t"
+	case 20, 21, 23: // int8, int2, int4
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	case 700, 701: // float4, float8
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case 114, 3802: // json, jsonb
+		if json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+	}
+	return v
+}
+
+// decodePGOutput parses a message of the pgoutput protocol version 1.
+func (p *PostgresCDC) decodePGOutput(data []byte) (*cdcChange, bool, error) {
+	r := &pgReader{b: data}
+
+	var c *cdcChange
+	var commit bool
+	switch r.byte() {
+	case 'C':
+		commit = true
+	case 'R':
+		id := r.uint32()
+		rel := pgRelation{schema: r.string(), table: r.string()}
+		r.byte() // Replica identity
+		n := int(r.uint16())
+		for i := 0; i < n && r.err == nil; i++ {
+			r.byte() // Flags
+			col := pgColumn{name: r.string(), typeOID: r.uint32()}
+			r.uint32() // Type modifier
+			rel.columns = append(rel.columns, col)
+		}
+		if r.err == nil {
+			p.relations[id] = rel
+		}
+	case 'I', 'U', 'D':
+		kind := data[0]
+		id := r.uint32()
+		rel, exists := p.relations[id]
+		if !exists {
+			return nil, false, fmt.Errorf("unknown relation: %v", id)
+		}
+		c = &cdcChange{Schema: rel.schema, Table: rel.table}
+		switch kind {
+		case 'I':
+			c.Operation = "insert"
+			r.byte() // N
+			c.After = r.tuple(rel)
+		case 'U':
+			c.Operation = "update"
+			if tupleKind := r.byte(); tupleKind == 'K' || tupleKind == 'O' {
+				c.Before = r.tuple(rel)
+				r.byte() // N
+			}
+			c.After = r.tuple(rel)
+		case 'D':
+			c.Operation = "delete"
+			r.byte() // K or O
+			c.Before = r.tuple(rel)
+		}
+	case 'T':
+		n := int(r.uint32())
+		r.byte() // Options
+		for i := 0; i < n && r.err == nil; i++ {
+			if rel, exists := p.relations[r.uint32()]; exists && n == 1 {
+				c = &cdcChange{Operation: "truncate", Schema: rel.schema, Table: rel.table}
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, false, r.err
+	}
+	return c, commit, nil
+}
+
+//------------------------------------------------------------------------------
+
+// CloseAsync shuts down the PostgresCDC input and stops processing requests.
+func (p *PostgresCDC) CloseAsync() {
+	p.dbMut.Lock()
+	if p.db != nil {
+		p.db.Close()
+		p.db = nil
+	}
+	p.dbMut.Unlock()
+}
+
+// WaitForClose blocks until the PostgresCDC input has closed down.
+func (p *PostgresCDC) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,195 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func TestPostgresCDCBadConfig(t *testing.T) {
+	conf := NewPostgresCDCConfig()
+	conf.Plugin = "nope"
+	if _, err := NewPostgresCDC(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad plugin")
+	}
+
+	conf = NewPostgresCDCConfig()
+	conf.Plugin = "pgoutput"
+	if _, err := NewPostgresCDC(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing publications")
+	}
+
+	conf = NewPostgresCDCConfig()
+	conf.PollInterval = "nope"
+	if _, err := NewPostgresCDC(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad poll interval")
+	}
+}
+
+func cdcChangesJSON(t *testing.T, changes []cdcChange) []string {
+	t.Helper()
+	var docs []string
+	for _, c := range changes {
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, string(b))
+	}
+	return docs
+}
+
+func TestPostgresCDCWal2JSON(t *testing.T) {
+	p, err := NewPostgresCDC(NewPostgresCDCConfig(), log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []cdcRow{
+		{lsn: "0/10", xid: 5, data: []byte(`{"action":"B"}`)},
+		{lsn: "0/11", xid: 5, data: []byte(`{"action":"I","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1},{"name":"name","type":"text","value":"foo"}]}`)},
+		{lsn: "0/12", xid: 5, data: []byte(`{"action":"U","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1},{"name":"name","type":"text","value":"bar"}],"identity":[{"name":"id","type":"integer","value":1}]}`)},
+		{lsn: "0/13", xid: 5, data: []byte(`{"action":"C"}`)},
+		{lsn: "0/14", xid: 6, data: []byte(`{"action":"B"}`)},
+		{lsn: "0/15", xid: 6, data: []byte(`{"action":"D","schema":"public","table":"users","identity":[{"name":"id","type":"integer","value":1}]}`)},
+		{lsn: "0/16", xid: 6, data: []byte(`{"action":"C"}`)},
+		{lsn: "0/17", xid: 7, data: []byte(`{"action":"B"}`)},
+		{lsn: "0/18", xid: 7, data: []byte(`{"action":"I","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":2}]}`)},
+	}
+
+	changes, lastLSN, err := p.decodeRows(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "0/16", lastLSN; exp != act {
+		t.Errorf("Wrong last LSN: %v != %v", act, exp)
+	}
+
+	exp := []string{
+		`{"operation":"insert","schema":"public","table":"users","lsn":"0/11","xid":5,"before":null,"after":{"id":1,"name":"foo"}}`,
+		`{"operation":"update","schema":"public","table":"users","lsn":"0/12","xid":5,"before":{"id":1},"after":{"id":1,"name":"bar"}}`,
+		`{"operation":"delete","schema":"public","table":"users","lsn":"0/15","xid":6,"before":{"id":1},"after":null}`,
+	}
+	if act := cdcChangesJSON(t, changes); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong changes: %s != %s", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type pgMessageBuilder []byte
+
+func (b pgMessageBuilder) byte(c byte) pgMessageBuilder {
+	return append(b, c)
+}
+
+func (b pgMessageBuilder) uint16(v uint16) pgMessageBuilder {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func (b pgMessageBuilder) uint32(v uint32) pgMessageBuilder {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func (b pgMessageBuilder) uint64(v uint64) pgMessageBuilder {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func (b pgMessageBuilder) string(s string) pgMessageBuilder {
+	return append(append(b, s...), 0)
+}
+
+func (b pgMessageBuilder) text(s string) pgMessageBuilder {
+	return b.byte('t').uint32(uint32(len(s))).append([]byte(s))
+}
+
+func (b pgMessageBuilder) append(v []byte) pgMessageBuilder {
+	return append(b, v...)
+}
+
+func TestPostgresCDCPGOutput(t *testing.T) {
+	conf := NewPostgresCDCConfig()
+	conf.Plugin = "pgoutput"
+	conf.Publications = []string{"foo"}
+	p, err := NewPostgresCDC(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	begin := pgMessageBuilder{}.byte('B').uint64(0x20).uint64(0).uint32(5)
+	relation := pgMessageBuilder{}.byte('R').uint32(1234).string("public").string("users").byte('f').uint16(4).
+		byte(1).string("id").uint32(23).uint32(0xffffffff).
+		byte(0).string("name").uint32(25).uint32(0xffffffff).
+		byte(0).string("active").uint32(16).uint32(0xffffffff).
+		byte(0).string("doc").uint32(3802).uint32(0xffffffff)
+	insert := pgMessageBuilder{}.byte('I').uint32(1234).byte('N').uint16(4).
+		text("1").text("foo").text("t").text(`{"a":1}`)
+	update := pgMessageBuilder{}.byte('U').uint32(1234).
+		byte('O').uint16(4).text("1").text("foo").text("t").byte('n').
+		byte('N').uint16(4).text("1").text("bar").text("f").byte('u')
+	del := pgMessageBuilder{}.byte('D').uint32(1234).byte('K').uint16(4).
+		text("1").byte('n').byte('n').byte('n')
+	commit := pgMessageBuilder{}.byte('C').byte(0).uint64(0x20).uint64(0x28).uint64(0)
+
+	rows := []cdcRow{
+		{lsn: "0/20", xid: 5, data: begin},
+		{lsn: "0/20", xid: 5, data: relation},
+		{lsn: "0/20", xid: 5, data: insert},
+		{lsn: "0/21", xid: 5, data: update},
+		{lsn: "0/22", xid: 5, data: del},
+		{lsn: "0/28", xid: 5, data: commit},
+	}
+
+	changes, lastLSN, err := p.decodeRows(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "0/28", lastLSN; exp != act {
+		t.Errorf("Wrong last LSN: %v != %v", act, exp)
+	}
+
+	exp := []string{
+		`{"operation":"insert","schema":"public","table":"users","lsn":"0/20","xid":5,"before":null,"after":{"active":true,"doc":{"a":1},"id":1,"name":"foo"}}`,
+		`{"operation":"update","schema":"public","table":"users","lsn":"0/21","xid":5,"before":{"active":true,"doc":null,"id":1,"name":"foo"},"after":{"active":false,"id":1,"name":"bar"}}`,
+		`{"operation":"delete","schema":"public","table":"users","lsn":"0/22","xid":5,"before":{"active":null,"doc":null,"id":1,"name":null},"after":null}`,
+	}
+	if act := cdcChangesJSON(t, changes); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong changes: %s != %s", act, exp)
+	}
+
+	if _, _, err = p.decodeRows([]cdcRow{{lsn: "0/30", data: insert[:8]}}); err == nil {
+		t.Error("Expected error from truncated message")
+	}
+}
+
+//------------------------------------------------------------------------------
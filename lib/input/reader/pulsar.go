@@ -0,0 +1,235 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	bpulsar "github.com/Jeffail/benthos/v3/lib/util/pulsar"
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+//------------------------------------------------------------------------------
+
+// PulsarConfig contains configuration for the Pulsar input type.
+type PulsarConfig struct {
+	bpulsar.ClientConfig `json:",inline" yaml:",inline"`
+	Topics               []string `json:"topics" yaml:"topics"`
+	SubscriptionName     string   `json:"subscription_name" yaml:"subscription_name"`
+	SubscriptionType     string   `json:"subscription_type" yaml:"subscription_type"`
+	StartFromOldest      bool     `json:"start_from_oldest" yaml:"start_from_oldest"`
+}
+
+// NewPulsarConfig creates a new PulsarConfig with default values.
+func NewPulsarConfig() PulsarConfig {
+	return PulsarConfig{
+		ClientConfig:     bpulsar.NewClientConfig(),
+		Topics:           []string{"benthos_stream"},
+		SubscriptionName: "benthos_subscription",
+		SubscriptionType: "shared",
+		StartFromOldest:  false,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func strToPulsarSubscriptionType(str string) (pulsar.SubscriptionType, error) {
+	switch str {
+	case "shared":
+		return pulsar.Shared, nil
+	case "failover":
+		return pulsar.Failover, nil
+	case "exclusive":
+		return pulsar.Exclusive, nil
+	case "key_shared":
+		return pulsar.KeyShared, nil
+	}
+	return pulsar.Shared, fmt.Errorf("subscription type not recognised: %v", str)
+}
+
+//------------------------------------------------------------------------------
+
+// Pulsar is a reader type that consumes messages from Apache Pulsar topics.
+type Pulsar struct {
+	conf    PulsarConfig
+	subType pulsar.SubscriptionType
+
+	client   pulsar.Client
+	consumer pulsar.Consumer
+	cMut     sync.Mutex
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewPulsar creates a new Pulsar reader type.
+func NewPulsar(conf PulsarConfig, log log.Modular, stats metrics.Type) (*Pulsar, error) {
+	if len(conf.Topics) == 0 {
+		return nil, errors.New("at least one topic must be provided")
+	}
+	if len(conf.SubscriptionName) == 0 {
+		return nil, errors.New("a subscription_name must be provided")
+	}
+	if _, err := conf.Options(log); err != nil {
+		return nil, err
+	}
+	subType, err := strToPulsarSubscriptionType(conf.SubscriptionType)
+	if err != nil {
+		return nil, err
+	}
+	return &Pulsar{
+		conf:    conf,
+		subType: subType,
+		log:     log,
+		stats:   stats,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext establishes a subscription to the Pulsar topics.
+func (p *Pulsar) ConnectWithContext(ctx context.Context) error {
+	p.cMut.Lock()
+	defer p.cMut.Unlock()
+
+	if p.consumer != nil {
+		return nil
+	}
+
+	opts, err := p.conf.Options(p.log)
+	if err != nil {
+		return err
+	}
+	client, err := pulsar.NewClient(opts)
+	if err != nil {
+		return err
+	}
+
+	initialPosition := pulsar.SubscriptionPositionLatest
+	if p.conf.StartFromOldest {
+		initialPosition = pulsar.SubscriptionPositionEarliest
+	}
+	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
+		Topics:                      p.conf.Topics,
+		SubscriptionName:            p.conf.SubscriptionName,
+		Type:                        p.subType,
+		SubscriptionInitialPosition: initialPosition,
+	})
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	p.client = client
+	p.consumer = consumer
+	p.log.Infof("Receiving Pulsar messages from topics %v with subscription '%v'\n", p.conf.Topics, p.conf.SubscriptionName)
+	return nil
+}
+
+func (p *Pulsar) disconnect() {
+	p.cMut.Lock()
+	defer p.cMut.Unlock()
+
+	if p.consumer != nil {
+		p.consumer.Close()
+		p.consumer = nil
+	}
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func pulsarMsgToPart(pMsg pulsar.Message) types.Part {
+	part := message.NewPart(pMsg.Payload())
+	meta := part.Metadata()
+	for k, v := range pMsg.Properties() {
+		meta.Set(k, v)
+	}
+	meta.Set("pulsar_topic", pMsg.Topic())
+	meta.Set("pulsar_key", pMsg.Key())
+	if ok := pMsg.OrderingKey(); len(ok) > 0 {
+		meta.Set("pulsar_ordering_key", ok)
+	}
+	meta.Set("pulsar_producer_name", pMsg.ProducerName())
+	meta.Set("pulsar_redelivery_count", strconv.FormatUint(uint64(pMsg.RedeliveryCount()), 10))
+	meta.Set("pulsar_publish_time_unix", strconv.FormatInt(pMsg.PublishTime().Unix(), 10))
+	if et := pMsg.EventTime(); !et.IsZero() {
+		meta.Set("pulsar_event_time_unix", strconv.FormatInt(et.Unix(), 10))
+	}
+	return part
+}
+
+// ReadWithContext attempts to read a message from the Pulsar subscription.
+func (p *Pulsar) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	p.cMut.Lock()
+	consumer := p.consumer
+	p.cMut.Unlock()
+
+	if consumer == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	pMsg, err := consumer.Receive(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, types.ErrTimeout
+		}
+		p.log.Errorf("Failed to receive message: %v\n", err)
+		p.disconnect()
+		return nil, nil, types.ErrNotConnected
+	}
+
+	msg := message.New(nil)
+	msg.Append(pulsarMsgToPart(pMsg))
+
+	return msg, func(ctx context.Context, res types.Response) error {
+		if res.Error() != nil {
+			consumer.Nack(pMsg)
+		} else {
+			consumer.Ack(pMsg)
+		}
+		return nil
+	}, nil
+}
+
+// CloseAsync shuts down the Pulsar input and stops processing requests.
+func (p *Pulsar) CloseAsync() {
+	go p.disconnect()
+}
+
+// WaitForClose blocks until the Pulsar input has closed down.
+func (p *Pulsar) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
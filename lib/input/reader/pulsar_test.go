@@ -0,0 +1,78 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+//------------------------------------------------------------------------------
+
+func TestPulsarSubscriptionTypes(t *testing.T) {
+	for str, exp := range map[string]pulsar.SubscriptionType{
+		"shared":     pulsar.Shared,
+		"failover":   pulsar.Failover,
+		"exclusive":  pulsar.Exclusive,
+		"key_shared": pulsar.KeyShared,
+	} {
+		conf := NewPulsarConfig()
+		conf.SubscriptionType = str
+
+		p, err := NewPulsar(conf, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := p.subType; act != exp {
+			t.Errorf("Wrong subscription type for %v: %v != %v", str, act, exp)
+		}
+	}
+}
+
+func TestPulsarBadConfig(t *testing.T) {
+	tests := map[string]func(c *PulsarConfig){
+		"bad subscription type": func(c *PulsarConfig) {
+			c.SubscriptionType = "nope"
+		},
+		"no topics": func(c *PulsarConfig) {
+			c.Topics = nil
+		},
+		"no subscription name": func(c *PulsarConfig) {
+			c.SubscriptionName = ""
+		},
+		"bad auth": func(c *PulsarConfig) {
+			c.Auth.Token.Enabled = true
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewPulsarConfig()
+		fn(&conf)
+		if _, err := NewPulsar(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
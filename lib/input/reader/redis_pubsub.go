@@ -151,7 +151,13 @@ func (r *RedisPubSub) ReadWithContext(ctx context.Context) (types.Message, Async
 			r.disconnect()
 			return nil, nil, types.ErrTypeClosed
 		}
-		return message.New([][]byte{[]byte(rMsg.Payload)}), noopAsyncAckFn, nil
+		msg := message.New([][]byte{[]byte(rMsg.Payload)})
+		meta := msg.Get(0).Metadata()
+		meta.Set("redis_pubsub_channel", rMsg.Channel)
+		if len(rMsg.Pattern) > 0 {
+			meta.Set("redis_pubsub_pattern", rMsg.Pattern)
+		}
+		return msg, noopAsyncAckFn, nil
 	case <-ctx.Done():
 	}
 
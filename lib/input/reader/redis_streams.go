@@ -50,6 +50,8 @@ type RedisStreamsConfig struct {
 	Batching        batch.PolicyConfig `json:"batching" yaml:"batching"`
 	StartFromOldest bool               `json:"start_from_oldest" yaml:"start_from_oldest"`
 	CommitPeriod    string             `json:"commit_period" yaml:"commit_period"`
+	ClaimPeriod     string             `json:"claim_period" yaml:"claim_period"`
+	ClaimMinIdle    string             `json:"claim_min_idle" yaml:"claim_min_idle"`
 	Timeout         string             `json:"timeout" yaml:"timeout"`
 }
 
@@ -67,6 +69,8 @@ func NewRedisStreamsConfig() RedisStreamsConfig {
 		Batching:        batchConf,
 		StartFromOldest: true,
 		CommitPeriod:    "1s",
+		ClaimPeriod:     "",
+		ClaimMinIdle:    "1m",
 		Timeout:         "5s",
 	}
 }
@@ -80,12 +84,17 @@ type RedisStreams struct {
 
 	timeout      time.Duration
 	commitPeriod time.Duration
+	claimPeriod  time.Duration
+	claimMinIdle time.Duration
 
 	url  *url.URL
 	conf RedisStreamsConfig
 
 	backlogs map[string]string
 
+	lastClaim    time.Time
+	claimCursors map[string]string
+
 	aMut       sync.Mutex
 	ackSend    map[string][]string // Acks that can be sent
 	ackPending map[string][]string // Acks that are pending
@@ -137,6 +146,20 @@ func NewRedisStreams(
 		}
 	}
 
+	if tout := conf.ClaimPeriod; len(tout) > 0 {
+		var err error
+		if r.claimPeriod, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse claim period string: %v", err)
+		}
+	}
+
+	if tout := conf.ClaimMinIdle; len(tout) > 0 {
+		var err error
+		if r.claimMinIdle, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse claim min idle string: %v", err)
+		}
+	}
+
 	go r.loop()
 	return r, nil
 }
@@ -276,6 +299,123 @@ func (r *RedisStreams) ConnectWithContext(ctx context.Context) error {
 	return nil
 }
 
+// parseXAutoClaim parses the reply of an XAUTOCLAIM command into the cursor to
+// continue claiming from, the claimed messages, and the IDs of pending entries
+// that no longer exist within the stream.
+func parseXAutoClaim(vals []interface{}) (string, []redis.XMessage, []string, error) {
+	if len(vals) < 2 {
+		return "", nil, nil, fmt.Errorf("unexpected reply length: %v", len(vals))
+	}
+	cursor, ok := vals[0].(string)
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unexpected cursor type: %T", vals[0])
+	}
+	entries, ok := vals[1].([]interface{})
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unexpected entries type: %T", vals[1])
+	}
+
+	var msgs []redis.XMessage
+	var deleted []string
+	for _, e := range entries {
+		entry, ok := e.([]interface{})
+		if !ok || len(entry) != 2 {
+			return "", nil, nil, fmt.Errorf("unexpected entry: %v", e)
+		}
+		id, ok := entry[0].(string)
+		if !ok {
+			return "", nil, nil, fmt.Errorf("unexpected entry ID type: %T", entry[0])
+		}
+		fields, _ := entry[1].([]interface{})
+		if fields == nil {
+			// Entries that were deleted from the stream whilst pending.
+			deleted = append(deleted, id)
+			continue
+		}
+		values := make(map[string]interface{}, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			k, ok := fields[i].(string)
+			if !ok {
+				return "", nil, nil, fmt.Errorf("unexpected field type: %T", fields[i])
+			}
+			values[k] = fields[i+1]
+		}
+		msgs = append(msgs, redis.XMessage{ID: id, Values: values})
+	}
+
+	// Since Redis 7 the IDs of deleted entries are returned separately.
+	if len(vals) > 2 {
+		if ids, ok := vals[2].([]interface{}); ok {
+			for _, id := range ids {
+				if idStr, ok := id.(string); ok {
+					deleted = append(deleted, idStr)
+				}
+			}
+		}
+	}
+	return cursor, msgs, deleted, nil
+}
+
+// claim transfers ownership of entries that have been pending within the
+// consumer group for longer than claim_min_idle, which were read by consumers
+// that are no longer processing them, to this consumer. Each stream is claimed
+// one page at a time until the pending entries of all streams have been
+// scanned, after which the next pass begins once claim_period has elapsed.
+func (r *RedisStreams) claim(client *redis.Client) ([]redis.XStream, map[string][]string, error) {
+	if r.claimPeriod <= 0 {
+		return nil, nil, nil
+	}
+	if len(r.claimCursors) == 0 {
+		if time.Since(r.lastClaim) < r.claimPeriod {
+			return nil, nil, nil
+		}
+		r.claimCursors = make(map[string]string, len(r.conf.Streams))
+		for _, str := range r.conf.Streams {
+			r.claimCursors[str] = "0-0"
+		}
+	}
+
+	var streams []redis.XStream
+	deletedAcks := map[string][]string{}
+	for _, str := range r.conf.Streams {
+		cursor, exists := r.claimCursors[str]
+		if !exists {
+			continue
+		}
+		cmd := redis.NewSliceCmd(
+			"XAUTOCLAIM", str, r.conf.ConsumerGroup, r.conf.ClientID,
+			int64(r.claimMinIdle/time.Millisecond), cursor, "COUNT", r.conf.Limit,
+		)
+		client.Process(cmd)
+		vals, err := cmd.Result()
+		if err != nil {
+			delete(r.claimCursors, str)
+			return nil, nil, err
+		}
+		next, msgs, deleted, err := parseXAutoClaim(vals)
+		if err != nil {
+			delete(r.claimCursors, str)
+			return nil, nil, fmt.Errorf("failed to parse claimed entries: %v", err)
+		}
+		if next == "0-0" {
+			delete(r.claimCursors, str)
+		} else {
+			r.claimCursors[str] = next
+		}
+		if len(msgs) > 0 {
+			r.log.Debugf("Claimed %v pending entries from stream %v\n", len(msgs), str)
+			streams = append(streams, redis.XStream{Stream: str, Messages: msgs})
+		}
+		if len(deleted) > 0 {
+			deletedAcks[str] = deleted
+		}
+	}
+	if len(r.claimCursors) == 0 {
+		r.lastClaim = time.Now()
+	}
+	return streams, deletedAcks, nil
+}
+
 func (r *RedisStreams) read() (types.Message, map[string][]string, error) {
 	var client *redis.Client
 
@@ -287,6 +427,17 @@ func (r *RedisStreams) read() (types.Message, map[string][]string, error) {
 		return nil, nil, types.ErrNotConnected
 	}
 
+	claimed, deleted, err := r.claim(client)
+	if err != nil {
+		r.log.Errorf("Failed to claim pending entries: %v\n", err)
+	}
+	for str, ids := range deleted {
+		r.addAsyncAcks(str, ids...)
+	}
+	if len(claimed) > 0 {
+		return r.toMessage(claimed, false)
+	}
+
 	strs := make([]string, len(r.conf.Streams)*2)
 	for i, str := range r.conf.Streams {
 		strs[i] = str
@@ -314,10 +465,16 @@ func (r *RedisStreams) read() (types.Message, map[string][]string, error) {
 		return nil, nil, types.ErrNotConnected
 	}
 
+	return r.toMessage(res, true)
+}
+
+// toMessage converts stream entries into a message batch along with the IDs
+// to acknowledge once the batch is delivered.
+func (r *RedisStreams) toMessage(res []redis.XStream, updateBacklogs bool) (types.Message, map[string][]string, error) {
 	pendingAcks := map[string][]string{}
 	msg := message.New(nil)
 	for _, strRes := range res {
-		if _, exists := r.backlogs[strRes.Stream]; exists {
+		if _, exists := r.backlogs[strRes.Stream]; exists && updateBacklogs {
 			if len(strRes.Messages) > 0 {
 				r.backlogs[strRes.Stream] = strRes.Messages[len(strRes.Messages)-1].ID
 			} else {
@@ -0,0 +1,101 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package reader
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+func TestParseXAutoClaim(t *testing.T) {
+	tests := map[string]struct {
+		vals    []interface{}
+		cursor  string
+		msgs    []redis.XMessage
+		deleted []string
+		err     bool
+	}{
+		"empty": {
+			vals:   []interface{}{"0-0", []interface{}{}},
+			cursor: "0-0",
+		},
+		"redis 6.2": {
+			vals: []interface{}{
+				"1526569498055-0",
+				[]interface{}{
+					[]interface{}{"1526569495631-0", []interface{}{"body", "foo", "bar", "baz"}},
+					[]interface{}{"1526569496631-0", nil},
+				},
+			},
+			cursor: "1526569498055-0",
+			msgs: []redis.XMessage{
+				{ID: "1526569495631-0", Values: map[string]interface{}{"body": "foo", "bar": "baz"}},
+			},
+			deleted: []string{"1526569496631-0"},
+		},
+		"redis 7": {
+			vals: []interface{}{
+				"0-0",
+				[]interface{}{
+					[]interface{}{"1526569495631-0", []interface{}{"body", "foo"}},
+				},
+				[]interface{}{"1526569496631-0"},
+			},
+			cursor: "0-0",
+			msgs: []redis.XMessage{
+				{ID: "1526569495631-0", Values: map[string]interface{}{"body": "foo"}},
+			},
+			deleted: []string{"1526569496631-0"},
+		},
+		"bad reply": {
+			vals: []interface{}{"0-0"},
+			err:  true,
+		},
+		"bad entry": {
+			vals: []interface{}{"0-0", []interface{}{"nope"}},
+			err:  true,
+		},
+	}
+
+	for name, test := range tests {
+		cursor, msgs, deleted, err := parseXAutoClaim(test.vals)
+		if test.err {
+			if err == nil {
+				t.Errorf("%v: expected error", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if exp, act := test.cursor, cursor; exp != act {
+			t.Errorf("%v: wrong cursor: %v != %v", name, act, exp)
+		}
+		if exp, act := test.msgs, msgs; !reflect.DeepEqual(exp, act) {
+			t.Errorf("%v: wrong messages: %v != %v", name, act, exp)
+		}
+		if exp, act := test.deleted, deleted; !reflect.DeepEqual(exp, act) {
+			t.Errorf("%v: wrong deleted IDs: %v != %v", name, act, exp)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+//------------------------------------------------------------------------------
+
+// SFTPConfig contains configuration fields for the SFTP input type.
+type SFTPConfig struct {
+	Address        string           `json:"address" yaml:"address"`
+	Paths          []string         `json:"paths" yaml:"paths"`
+	Codec          string           `json:"codec" yaml:"codec"`
+	Credentials    sftp.Credentials `json:"credentials" yaml:"credentials"`
+	KnownHostsFile string           `json:"known_hosts_file" yaml:"known_hosts_file"`
+	PollInterval   string           `json:"poll_interval" yaml:"poll_interval"`
+	DeleteOnFinish bool             `json:"delete_on_finish" yaml:"delete_on_finish"`
+	MoveTo         string           `json:"move_to" yaml:"move_to"`
+	Timeout        string           `json:"timeout" yaml:"timeout"`
+}
+
+// NewSFTPConfig creates a new SFTPConfig with default values.
+func NewSFTPConfig() SFTPConfig {
+	return SFTPConfig{
+		Address:        "localhost:22",
+		Paths:          []string{},
+		Codec:          "all-bytes",
+		Credentials:    sftp.NewCredentials(),
+		KnownHostsFile: "",
+		PollInterval:   "15s",
+		DeleteOnFinish: false,
+		MoveTo:         "",
+		Timeout:        "10s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SFTP is an input type that polls directories of an SFTP server and reads the
+// contents of matching files as messages.
+type SFTP struct {
+	conf         SFTPConfig
+	sshConf      *ssh.ClientConfig
+	pollInterval time.Duration
+	timeout      time.Duration
+	trackSeen    bool
+	codecCtor    partCodecCtor
+
+	mut      sync.Mutex
+	client   *sftp.Client
+	queue    []sftp.FileInfo
+	inFlight map[string]struct{}
+	seen     map[string]sftp.FileInfo
+	nextPoll time.Time
+	current  *sftpFile
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSFTP creates a new SFTP input type.
+func NewSFTP(conf SFTPConfig, log log.Modular, stats metrics.Type) (*SFTP, error) {
+	s := &SFTP{
+		conf:     conf,
+		inFlight: map[string]struct{}{},
+		seen:     map[string]sftp.FileInfo{},
+		log:      log,
+		stats:    stats,
+	}
+
+	// Files that are neither deleted nor moved remain in place, and are
+	// tracked in order to avoid reading them again until they're modified.
+	s.trackSeen = !conf.DeleteOnFinish && len(conf.MoveTo) == 0
+
+	if len(conf.Paths) == 0 {
+		return nil, errors.New("at least one path must be specified")
+	}
+	for _, p := range conf.Paths {
+		if _, err := path.Match(path.Base(p), ""); err != nil {
+			return nil, fmt.Errorf("failed to parse path pattern '%v': %v", p, err)
+		}
+	}
+	if conf.DeleteOnFinish && len(conf.MoveTo) > 0 {
+		return nil, errors.New("cannot both delete and move files on finish")
+	}
+
+	var err error
+	if s.codecCtor, err = getPartCodec(conf.Codec); err != nil {
+		return nil, err
+	}
+	if s.pollInterval, err = time.ParseDuration(conf.PollInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse poll interval: %v", err)
+	}
+	if s.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	if s.sshConf, err = conf.Credentials.SSHConfig(conf.KnownHostsFile, s.timeout, log); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext establishes an SFTP session with the server.
+func (s *SFTP) ConnectWithContext(ctx context.Context) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.client != nil {
+		return nil
+	}
+
+	var err error
+	if s.client, err = sftp.Dial(ctx, s.conf.Address, s.sshConf); err != nil {
+		return err
+	}
+
+	s.log.Infof("Polling SFTP paths on %v: %v\n", s.conf.Address, s.conf.Paths)
+	return nil
+}
+
+// disconnect closes the current session after a transport error. The mutex
+// must be held when calling.
+func (s *SFTP) disconnect() {
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// poll lists the target directories and queues all matching files that are
+// not currently being processed. The mutex must be held when calling.
+func (s *SFTP) poll() error {
+	seen := map[string]sftp.FileInfo{}
+
+	listed := map[string][]sftp.FileInfo{}
+	for _, pattern := range s.conf.Paths {
+		dir, base := path.Dir(pattern), path.Base(pattern)
+		infos, exists := listed[dir]
+		if !exists {
+			var err error
+			if infos, err = s.client.ReadDir(dir); err != nil {
+				if !sftp.IsNotExist(err) {
+					return fmt.Errorf("failed to list directory '%v': %v", dir, err)
+				}
+				s.log.Debugf("Directory '%v' does not exist\n", dir)
+			}
+			listed[dir] = infos
+		}
+		for _, info := range infos {
+			if !info.IsRegular() {
+				continue
+			}
+			if matched, _ := path.Match(base, info.Name); !matched {
+				continue
+			}
+			info.Name = path.Join(dir, info.Name)
+			if _, exists := seen[info.Name]; exists {
+				continue
+			}
+			seen[info.Name] = info
+			if _, exists := s.inFlight[info.Name]; exists {
+				continue
+			}
+			if prev, exists := s.seen[info.Name]; exists && s.trackSeen &&
+				prev.Size == info.Size && prev.ModTime.Equal(info.ModTime) {
+				continue
+			}
+			s.queue = append(s.queue, info)
+		}
+	}
+	sort.Slice(s.queue, func(i, j int) bool {
+		return s.queue[i].Name < s.queue[j].Name
+	})
+
+	if s.trackSeen {
+		// Forget files that no longer exist so that the map does not grow
+		// indefinitely.
+		for k := range s.seen {
+			if _, exists := seen[k]; !exists {
+				delete(s.seen, k)
+			}
+		}
+	}
+	return nil
+}
+
+// sftpFile is a file that is being decoded into messages, which is finished
+// once all of its parts have been read and acknowledged.
+type sftpFile struct {
+	info     sftp.FileInfo
+	parts    partCodec
+	next     []byte
+	nextErr  error
+	pending  int
+	finished bool
+	resErr   error
+}
+
+// advance reads ahead to the next part of the file, so that the file is known
+// to be finished as soon as its last part has been read.
+func (f *sftpFile) advance() {
+	f.next, f.nextErr = f.parts.Next()
+}
+
+// finishParts closes the parts of the current file once they have all been
+// read. The mutex must be held when calling.
+func (s *SFTP) finishParts(f *sftpFile) {
+	if f.nextErr != io.EOF {
+		s.log.Errorf("Failed to decode file '%v': %v\n", f.info.Name, f.nextErr)
+	}
+	f.parts.Close()
+	f.finished = true
+	s.current = nil
+}
+
+// readNext reads the next part of the current file, reading the contents of
+// the next queued file when there isn't one and polling when the queue is
+// empty and the poll interval has passed. A nil message is returned when there
+// are no files to read.
+func (s *SFTP) readNext() (types.Message, *sftpFile, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.client == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+	for s.current == nil {
+		if len(s.queue) == 0 {
+			if time.Now().Before(s.nextPoll) {
+				return nil, nil, nil
+			}
+			s.nextPoll = time.Now().Add(s.pollInterval)
+			if err := s.poll(); err != nil {
+				if _, ok := err.(*sftp.StatusError); !ok {
+					s.disconnect()
+				}
+				return nil, nil, err
+			}
+			if len(s.queue) == 0 {
+				return nil, nil, nil
+			}
+		}
+
+		info := s.queue[0]
+		s.queue = s.queue[1:]
+
+		data, err := s.client.ReadFile(info.Name)
+		if err != nil {
+			if sftp.IsNotExist(err) {
+				continue
+			}
+			if _, ok := err.(*sftp.StatusError); !ok {
+				s.disconnect()
+			}
+			return nil, nil, fmt.Errorf("failed to read file '%v': %v", info.Name, err)
+		}
+
+		parts, err := s.codecCtor(partCodecHint{path: info.Name}, ioutil.NopCloser(bytes.NewReader(data)))
+		if err != nil {
+			s.log.Errorf("Failed to decode file '%v': %v\n", info.Name, err)
+			continue
+		}
+		s.inFlight[info.Name] = struct{}{}
+
+		f := &sftpFile{info: info, parts: parts}
+		if f.advance(); f.nextErr != nil {
+			// The file has no parts and so is finished straight away.
+			s.current = f
+			s.finishParts(f)
+			go func() {
+				if err := s.finish(f.info, nil); err != nil {
+					s.log.Errorf("%v\n", err)
+				}
+			}()
+			continue
+		}
+		s.current = f
+	}
+
+	f := s.current
+	data := f.next
+	f.pending++
+	if f.advance(); f.nextErr != nil {
+		s.finishParts(f)
+	}
+
+	msg := message.New([][]byte{data})
+	meta := msg.Get(0).Metadata()
+	meta.Set("sftp_path", f.info.Name)
+	meta.Set("sftp_mod_time_unix", strconv.FormatInt(f.info.ModTime.Unix(), 10))
+	meta.Set("sftp_mod_time", f.info.ModTime.UTC().Format(time.RFC3339))
+	return msg, f, nil
+}
+
+// ReadWithContext reads the next part of a file as a message.
+func (s *SFTP) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	msg, f, err := s.readNext()
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg == nil {
+		s.mut.Lock()
+		wait := time.Until(s.nextPoll)
+		s.mut.Unlock()
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+		}
+		return nil, nil, types.ErrTimeout
+	}
+	return msg, func(rctx context.Context, res types.Response) error {
+		s.mut.Lock()
+		f.pending--
+		if res.Error() != nil && f.resErr == nil {
+			f.resErr = res.Error()
+		}
+		done := f.pending == 0 && f.finished
+		s.mut.Unlock()
+		if !done {
+			return nil
+		}
+		return s.finish(f.info, f.resErr)
+	}, nil
+}
+
+// finish releases a file once its messages have been processed, deleting or
+// moving it when configured to.
+func (s *SFTP) finish(info sftp.FileInfo, resErr error) error {
+	s.mut.Lock()
+	client := s.client
+	s.mut.Unlock()
+
+	var err error
+	if resErr == nil {
+		if client == nil && (s.conf.DeleteOnFinish || len(s.conf.MoveTo) > 0) {
+			err = types.ErrNotConnected
+		} else if s.conf.DeleteOnFinish {
+			if err = client.Remove(info.Name); err != nil {
+				err = fmt.Errorf("failed to delete file '%v': %v", info.Name, err)
+			}
+		} else if len(s.conf.MoveTo) > 0 {
+			target := path.Join(s.conf.MoveTo, path.Base(info.Name))
+			if err = client.Rename(info.Name, target); err != nil {
+				err = fmt.Errorf("failed to move file '%v' to '%v': %v", info.Name, target, err)
+			}
+		}
+	}
+
+	s.mut.Lock()
+	delete(s.inFlight, info.Name)
+	if s.trackSeen && resErr == nil && err == nil {
+		s.seen[info.Name] = info
+	}
+	s.mut.Unlock()
+	return err
+}
+
+// CloseAsync shuts down the SFTP input and stops processing requests.
+func (s *SFTP) CloseAsync() {
+	s.mut.Lock()
+	s.disconnect()
+	s.mut.Unlock()
+}
+
+// WaitForClose blocks until the SFTP input has closed down.
+func (s *SFTP) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,384 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/sftp/sftptest"
+	"golang.org/x/crypto/ssh"
+)
+
+//------------------------------------------------------------------------------
+
+// sftpTestSSHServer starts an SSH server that serves the sftp subsystem from
+// an in-memory server.
+func sftpTestSSHServer(t *testing.T, fs *sftptest.Server, conf *ssh.ServerConfig) net.Listener {
+	t.Helper()
+	ln, err := fs.ListenSSH(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func sftpTestPasswordServer(t *testing.T, fs *sftptest.Server) net.Listener {
+	t.Helper()
+	return sftpTestSSHServer(t, fs, &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "foo" && string(pass) == "bar" {
+				return nil, nil
+			}
+			return nil, errors.New("bad password")
+		},
+	})
+}
+
+func sftpTestRead(t *testing.T, s *SFTP) (types.Message, AsyncAckFn) {
+	t.Helper()
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+	for {
+		msg, ackFn, err := s.ReadWithContext(ctx)
+		if err == types.ErrTimeout && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg, ackFn
+	}
+}
+
+func sftpTestExpectEmpty(t *testing.T, s *SFTP) {
+	t.Helper()
+	if _, _, err := s.ReadWithContext(context.Background()); err != types.ErrTimeout {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestSFTPBadConfig(t *testing.T) {
+	conf := NewSFTPConfig()
+	conf.Credentials.Password = "bar"
+	if _, err := NewSFTP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing paths")
+	}
+
+	conf.Paths = []string{"/in/[*.txt"}
+	if _, err := NewSFTP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad pattern")
+	}
+
+	conf.Paths = []string{"/in/*.txt"}
+	conf.DeleteOnFinish = true
+	conf.MoveTo = "/done"
+	if _, err := NewSFTP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from both delete and move")
+	}
+
+	conf.MoveTo = ""
+	conf.Credentials.Password = ""
+	if _, err := NewSFTP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing credentials")
+	}
+}
+
+func TestSFTPPoll(t *testing.T) {
+	modTime := time.Unix(1577836800, 0)
+
+	fs := sftptest.NewServer()
+	fs.Put("/in/a.txt", "foo", modTime)
+	fs.Put("/in/b.txt", "bar", modTime)
+	fs.Put("/in/c.csv", "baz", modTime)
+
+	ln := sftpTestPasswordServer(t, fs)
+	defer ln.Close()
+
+	conf := NewSFTPConfig()
+	conf.Address = ln.Addr().String()
+	conf.Paths = []string{"/in/*.txt"}
+	conf.Credentials.Username = "foo"
+	conf.Credentials.Password = "bar"
+	conf.PollInterval = "1ms"
+
+	s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	msg, ackA := sftpTestRead(t, s)
+	if exp, act := [][]byte{[]byte("foo")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message contents: %s != %s", act, exp)
+	}
+	meta := msg.Get(0).Metadata()
+	if exp, act := "/in/a.txt", meta.Get("sftp_path"); exp != act {
+		t.Errorf("Wrong path metadata: %v != %v", act, exp)
+	}
+	if exp, act := "1577836800", meta.Get("sftp_mod_time_unix"); exp != act {
+		t.Errorf("Wrong mod time metadata: %v != %v", act, exp)
+	}
+	if exp, act := "2020-01-01T00:00:00Z", meta.Get("sftp_mod_time"); exp != act {
+		t.Errorf("Wrong mod time metadata: %v != %v", act, exp)
+	}
+
+	msg, ackB := sftpTestRead(t, s)
+	if exp, act := [][]byte{[]byte("bar")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message contents: %s != %s", act, exp)
+	}
+
+	// Files in flight are not read again.
+	<-time.After(time.Millisecond * 5)
+	sftpTestExpectEmpty(t, s)
+
+	if err = ackA(context.Background(), response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+	if err = ackB(context.Background(), response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Processed files are not read again until modified.
+	<-time.After(time.Millisecond * 5)
+	sftpTestExpectEmpty(t, s)
+
+	fs.Put("/in/b.txt", "bar2", modTime.Add(time.Second))
+	msg, _ = sftpTestRead(t, s)
+	if exp, act := [][]byte{[]byte("bar2")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message contents: %s != %s", act, exp)
+	}
+
+	if exp, act := []string{"/in/a.txt", "/in/b.txt", "/in/c.csv"}, fs.Names(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong remaining files: %v != %v", act, exp)
+	}
+}
+
+func TestSFTPDeleteOnFinish(t *testing.T) {
+	fs := sftptest.NewServer()
+	fs.Put("/in/a.txt", "foo", time.Now())
+	fs.Put("/in/b.txt", "bar", time.Now())
+
+	ln := sftpTestPasswordServer(t, fs)
+	defer ln.Close()
+
+	conf := NewSFTPConfig()
+	conf.Address = ln.Addr().String()
+	conf.Paths = []string{"/in/a.txt", "/in/*.txt"}
+	conf.Credentials.Username = "foo"
+	conf.Credentials.Password = "bar"
+	conf.PollInterval = "1ms"
+	conf.DeleteOnFinish = true
+
+	s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	for _, exp := range []string{"/in/a.txt", "/in/b.txt"} {
+		msg, ackFn := sftpTestRead(t, s)
+		if act := msg.Get(0).Metadata().Get("sftp_path"); exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+		if err = ackFn(context.Background(), response.NewAck()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if act := fs.Names(); len(act) > 0 {
+		t.Errorf("Expected all files to be deleted: %v", act)
+	}
+}
+
+func TestSFTPCodec(t *testing.T) {
+	fs := sftptest.NewServer()
+	fs.Put("/in/a.txt", "foo\nbar", time.Now())
+	fs.Put("/in/b.txt", "", time.Now())
+	fs.Put("/in/c.txt", "baz", time.Now())
+
+	ln := sftpTestPasswordServer(t, fs)
+	defer ln.Close()
+
+	conf := NewSFTPConfig()
+	conf.Address = ln.Addr().String()
+	conf.Paths = []string{"/in/*.txt"}
+	conf.Codec = "lines"
+	conf.Credentials.Username = "foo"
+	conf.Credentials.Password = "bar"
+	conf.PollInterval = "1ms"
+	conf.DeleteOnFinish = true
+
+	s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	var ackFns []AsyncAckFn
+	for _, exp := range []string{"foo", "bar", "baz"} {
+		msg, ackFn := sftpTestRead(t, s)
+		if act := string(msg.Get(0).Get()); exp != act {
+			t.Errorf("Wrong message contents: %v != %v", act, exp)
+		}
+		ackFns = append(ackFns, ackFn)
+	}
+
+	// A file is only deleted once all of its parts are acknowledged.
+	if err = ackFns[0](context.Background(), response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+	if act := fs.Names(); len(act) == 0 || act[0] != "/in/a.txt" {
+		t.Errorf("Expected partially acknowledged file to remain: %v", act)
+	}
+	for _, ackFn := range ackFns[1:] {
+		if err = ackFn(context.Background(), response.NewAck()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Files without any parts are deleted in the background.
+	deadline := time.Now().Add(time.Second * 5)
+	for len(fs.Names()) > 0 && time.Now().Before(deadline) {
+		<-time.After(time.Millisecond)
+	}
+	if act := fs.Names(); len(act) > 0 {
+		t.Errorf("Expected all files to be deleted: %v", act)
+	}
+}
+
+func TestSFTPMoveToWithKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "benthos_sftp_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "id_rsa")
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := sftptest.NewServer()
+	fs.Put("/in/a.txt", "foo", time.Now())
+
+	ln := sftpTestSSHServer(t, fs, &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, k ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(k.Marshal(), pubKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("bad key")
+		},
+	})
+	defer ln.Close()
+
+	conf := NewSFTPConfig()
+	conf.Address = ln.Addr().String()
+	conf.Paths = []string{"/in/*"}
+	conf.Credentials.Username = "foo"
+	conf.Credentials.PrivateKeyFile = keyFile
+	conf.PollInterval = "1ms"
+	conf.MoveTo = "/done"
+
+	s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	msg, ackFn := sftpTestRead(t, s)
+	if exp, act := [][]byte{[]byte("foo")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message contents: %s != %s", act, exp)
+	}
+	if err = ackFn(context.Background(), response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []string{"/done/a.txt"}, fs.Names(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong remaining files: %v != %v", act, exp)
+	}
+}
+
+func TestSFTPBadPassword(t *testing.T) {
+	fs := sftptest.NewServer()
+
+	ln := sftpTestPasswordServer(t, fs)
+	defer ln.Close()
+
+	conf := NewSFTPConfig()
+	conf.Address = ln.Addr().String()
+	conf.Paths = []string{"/in/*"}
+	conf.Credentials.Username = "foo"
+	conf.Credentials.Password = "nope"
+
+	s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.ConnectWithContext(context.Background()); err == nil {
+		t.Error("Expected error from bad password")
+		s.CloseAsync()
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,284 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+
+	// SQL Drivers
+	_ "github.com/go-sql-driver/mysql"
+)
+
+//------------------------------------------------------------------------------
+
+// SQLSelectConfig contains configuration fields for the SQLSelect input type.
+type SQLSelectConfig struct {
+	Driver        string   `json:"driver" yaml:"driver"`
+	DSN           string   `json:"dsn" yaml:"dsn"`
+	Table         string   `json:"table" yaml:"table"`
+	Columns       []string `json:"columns" yaml:"columns"`
+	Where         string   `json:"where" yaml:"where"`
+	CursorColumn  string   `json:"cursor_column" yaml:"cursor_column"`
+	CursorInitial string   `json:"cursor_initial" yaml:"cursor_initial"`
+	BatchSize     int      `json:"batch_size" yaml:"batch_size"`
+	Interval      string   `json:"interval" yaml:"interval"`
+}
+
+// NewSQLSelectConfig creates a new SQLSelectConfig with default values.
+func NewSQLSelectConfig() SQLSelectConfig {
+	return SQLSelectConfig{
+		Driver:        "mysql",
+		DSN:           "",
+		Table:         "",
+		Columns:       []string{"*"},
+		Where:         "",
+		CursorColumn:  "id",
+		CursorInitial: "",
+		BatchSize:     1000,
+		Interval:      "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SQLSelect is an input type that reads the rows of a table in pages ordered
+// by a cursor column.
+type SQLSelect struct {
+	conf        SQLSelectConfig
+	interval    time.Duration
+	firstQuery  string
+	cursorQuery string
+
+	dbMut sync.Mutex
+	db    *sql.DB
+
+	cursor    interface{}
+	hasCursor bool
+	done      bool
+
+	stats metrics.Type
+	log   log.Modular
+}
+
+// NewSQLSelect creates a new SQLSelect input type.
+func NewSQLSelect(conf SQLSelectConfig, log log.Modular, stats metrics.Type) (*SQLSelect, error) {
+	s := &SQLSelect{
+		conf:  conf,
+		stats: stats,
+		log:   log,
+	}
+
+	var placeholder string
+	switch conf.Driver {
+	case "mysql":
+		placeholder = "?"
+	case "postgres":
+		placeholder = "$1"
+	default:
+		return nil, fmt.Errorf("unrecognised driver: %v", conf.Driver)
+	}
+	if len(conf.Table) == 0 {
+		return nil, errors.New("a table must be specified")
+	}
+	if len(conf.CursorColumn) == 0 {
+		return nil, errors.New("a cursor column must be specified")
+	}
+	if conf.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be greater than zero: %v", conf.BatchSize)
+	}
+	if len(conf.Interval) > 0 {
+		var err error
+		if s.interval, err = time.ParseDuration(conf.Interval); err != nil {
+			return nil, fmt.Errorf("failed to parse interval: %v", err)
+		}
+	}
+
+	columns := "*"
+	if len(conf.Columns) > 0 {
+		columns = strings.Join(conf.Columns, ", ")
+	}
+	var conditions []string
+	if len(conf.Where) > 0 {
+		conditions = append(conditions, "("+conf.Where+")")
+	}
+	s.firstQuery = sqlSelectQuery(columns, conf.Table, conditions, conf.CursorColumn, conf.BatchSize)
+	conditions = append(conditions, conf.CursorColumn+" > "+placeholder)
+	s.cursorQuery = sqlSelectQuery(columns, conf.Table, conditions, conf.CursorColumn, conf.BatchSize)
+
+	if len(conf.CursorInitial) > 0 {
+		s.cursor, s.hasCursor = conf.CursorInitial, true
+	}
+	return s, nil
+}
+
+func sqlSelectQuery(columns, table string, conditions []string, cursorColumn string, limit int) string {
+	query := "SELECT " + columns + " FROM " + table
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return query + " ORDER BY " + cursorColumn + " ASC LIMIT " + strconv.Itoa(limit)
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext establishes a connection to the database.
+func (s *SQLSelect) ConnectWithContext(ctx context.Context) error {
+	s.dbMut.Lock()
+	defer s.dbMut.Unlock()
+
+	if s.db != nil {
+		return nil
+	}
+	db, err := sql.Open(s.conf.Driver, s.conf.DSN)
+	if err != nil {
+		return err
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return err
+	}
+	s.db = db
+	s.log.Infof("Selecting rows from SQL table: %v\n", s.conf.Table)
+	return nil
+}
+
+// ReadWithContext reads the next page of rows as a message batch, with a
+// message for each row.
+func (s *SQLSelect) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	msg, err := s.readPage(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg.Len() == 0 {
+		if s.interval == 0 {
+			return nil, nil, types.ErrTypeClosed
+		}
+		select {
+		case <-time.After(s.interval):
+		case <-ctx.Done():
+		}
+		return nil, nil, types.ErrTimeout
+	}
+	return msg, func(context.Context, types.Response) error {
+		return nil
+	}, nil
+}
+
+func (s *SQLSelect) readPage(ctx context.Context) (types.Message, error) {
+	s.dbMut.Lock()
+	defer s.dbMut.Unlock()
+
+	if s.done {
+		return nil, types.ErrTypeClosed
+	}
+	if s.db == nil {
+		return nil, types.ErrNotConnected
+	}
+
+	query, args := s.firstQuery, []interface{}{}
+	if s.hasCursor {
+		query, args = s.cursorQuery, []interface{}{s.cursor}
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select rows: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	cursorIndex := -1
+	for i, c := range columns {
+		if c == s.conf.CursorColumn {
+			cursorIndex = i
+		}
+	}
+	if cursorIndex < 0 {
+		return nil, fmt.Errorf("cursor column '%v' was not selected", s.conf.CursorColumn)
+	}
+
+	var cursor interface{}
+	msg := message.New(nil)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuesWrapped := make([]interface{}, len(columns))
+		for i := range values {
+			valuesWrapped[i] = &values[i]
+		}
+		if err = rows.Scan(valuesWrapped...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			row[columns[i]] = v
+		}
+		part := message.NewPart(nil)
+		if err = part.SetJSON(row); err != nil {
+			return nil, fmt.Errorf("failed to serialise row: %v", err)
+		}
+		msg.Append(part)
+		cursor = row[s.conf.CursorColumn]
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if msg.Len() > 0 {
+		s.cursor, s.hasCursor = cursor, true
+	} else if s.interval == 0 {
+		s.done = true
+	}
+	return msg, nil
+}
+
+// CloseAsync shuts down the SQLSelect input and stops processing requests.
+func (s *SQLSelect) CloseAsync() {
+	s.dbMut.Lock()
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+	s.dbMut.Unlock()
+}
+
+// WaitForClose blocks until the SQLSelect input has closed down.
+func (s *SQLSelect) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
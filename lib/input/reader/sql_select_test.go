@@ -0,0 +1,266 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// sqlSelectTestDriver serves the rows of a single table ordered by an integer
+// ID column, for queries of the form built by the SQLSelect input.
+type sqlSelectTestDriver struct {
+	mut     sync.Mutex
+	rows    [][]driver.Value
+	queries []string
+}
+
+func (d *sqlSelectTestDriver) Open(name string) (driver.Conn, error) {
+	return &sqlSelectTestConn{d: d}, nil
+}
+
+func (d *sqlSelectTestDriver) append(rows ...[]driver.Value) {
+	d.mut.Lock()
+	d.rows = append(d.rows, rows...)
+	d.mut.Unlock()
+}
+
+type sqlSelectTestConn struct {
+	d *sqlSelectTestDriver
+}
+
+func (c *sqlSelectTestConn) Prepare(query string) (driver.Stmt, error) {
+	return &sqlSelectTestStmt{d: c.d, query: query}, nil
+}
+func (c *sqlSelectTestConn) Close() error              { return nil }
+func (c *sqlSelectTestConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type sqlSelectTestStmt struct {
+	d     *sqlSelectTestDriver
+	query string
+}
+
+func (s *sqlSelectTestStmt) Close() error  { return nil }
+func (s *sqlSelectTestStmt) NumInput() int { return -1 }
+func (s *sqlSelectTestStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *sqlSelectTestStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mut.Lock()
+	defer s.d.mut.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+
+	limit, err := strconv.Atoi(s.query[strings.LastIndex(s.query, " ")+1:])
+	if err != nil {
+		return nil, err
+	}
+	var after int64 = -1
+	if len(args) > 0 {
+		switch t := args[0].(type) {
+		case int64:
+			after = t
+		case string:
+			if after, err = strconv.ParseInt(t, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var rows [][]driver.Value
+	for _, r := range s.d.rows {
+		if r[0].(int64) > after && len(rows) < limit {
+			rows = append(rows, r)
+		}
+	}
+	return &sqlSelectTestRows{rows: rows}, nil
+}
+
+type sqlSelectTestRows struct {
+	rows [][]driver.Value
+}
+
+func (r *sqlSelectTestRows) Columns() []string { return []string{"id", "name"} }
+func (r *sqlSelectTestRows) Close() error      { return nil }
+func (r *sqlSelectTestRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var sqlSelectTestDrivers = map[string]*sqlSelectTestDriver{
+	"sql_select_test_once":     {},
+	"sql_select_test_interval": {},
+}
+
+func init() {
+	for name, d := range sqlSelectTestDrivers {
+		sql.Register(name, d)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestSQLSelectBadConfig(t *testing.T) {
+	conf := NewSQLSelectConfig()
+	if _, err := NewSQLSelect(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing table")
+	}
+
+	conf.Table = "foo"
+	conf.Driver = "nope"
+	if _, err := NewSQLSelect(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad driver")
+	}
+}
+
+func TestSQLSelectQueries(t *testing.T) {
+	conf := NewSQLSelectConfig()
+	conf.Driver = "postgres"
+	conf.Table = "foo"
+	conf.Columns = []string{"id", "name"}
+	conf.Where = "deleted = false"
+	conf.BatchSize = 10
+
+	s, err := NewSQLSelect(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "SELECT id, name FROM foo WHERE (deleted = false) ORDER BY id ASC LIMIT 10", s.firstQuery; exp != act {
+		t.Errorf("Wrong first query: %v != %v", act, exp)
+	}
+	if exp, act := "SELECT id, name FROM foo WHERE (deleted = false) AND id > $1 ORDER BY id ASC LIMIT 10", s.cursorQuery; exp != act {
+		t.Errorf("Wrong cursor query: %v != %v", act, exp)
+	}
+}
+
+func sqlSelectTestRead(t *testing.T, s *SQLSelect) []string {
+	t.Helper()
+	msg, _, err := s.ReadWithContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []string
+	for _, p := range message.GetAllBytes(msg) {
+		rows = append(rows, string(p))
+	}
+	return rows
+}
+
+func TestSQLSelectPages(t *testing.T) {
+	d := sqlSelectTestDrivers["sql_select_test_once"]
+	d.append(
+		[]driver.Value{int64(1), []byte("foo")},
+		[]driver.Value{int64(2), []byte("bar")},
+		[]driver.Value{int64(3), []byte("baz")},
+	)
+
+	conf := NewSQLSelectConfig()
+	conf.Table = "foo"
+	conf.BatchSize = 2
+
+	s, err := NewSQLSelect(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conf.Driver = "sql_select_test_once"
+	if err = s.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	if exp, act := []string{`{"id":1,"name":"foo"}`, `{"id":2,"name":"bar"}`}, sqlSelectTestRead(t, s); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong rows: %v != %v", act, exp)
+	}
+	if exp, act := []string{`{"id":3,"name":"baz"}`}, sqlSelectTestRead(t, s); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong rows: %v != %v", act, exp)
+	}
+	if _, _, err = s.ReadWithContext(context.Background()); err != types.ErrTypeClosed {
+		t.Errorf("Expected closed error, got: %v", err)
+	}
+
+	exp := []string{
+		"SELECT * FROM foo ORDER BY id ASC LIMIT 2",
+		"SELECT * FROM foo WHERE id > ? ORDER BY id ASC LIMIT 2",
+		"SELECT * FROM foo WHERE id > ? ORDER BY id ASC LIMIT 2",
+	}
+	if !reflect.DeepEqual(exp, d.queries) {
+		t.Errorf("Wrong queries: %v != %v", d.queries, exp)
+	}
+}
+
+func TestSQLSelectInterval(t *testing.T) {
+	d := sqlSelectTestDrivers["sql_select_test_interval"]
+	d.append([]driver.Value{int64(1), []byte("foo")})
+
+	conf := NewSQLSelectConfig()
+	conf.Table = "foo"
+	conf.CursorInitial = "0"
+	conf.Interval = "10ms"
+
+	s, err := NewSQLSelect(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.conf.Driver = "sql_select_test_interval"
+	if err = s.ConnectWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	if exp, act := []string{`{"id":1,"name":"foo"}`}, sqlSelectTestRead(t, s); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong rows: %v != %v", act, exp)
+	}
+
+	tStarted := time.Now()
+	if _, _, err = s.ReadWithContext(context.Background()); err != types.ErrTimeout {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+	if time.Since(tStarted) < time.Millisecond*10 {
+		t.Error("Expected read to wait for interval")
+	}
+
+	d.append([]driver.Value{int64(2), []byte("bar")})
+	if exp, act := []string{`{"id":2,"name":"bar"}`}, sqlSelectTestRead(t, s); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong rows: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,353 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/http/auth"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+// SSEConfig contains configuration fields for the SSE input type.
+type SSEConfig struct {
+	URL            string            `json:"url" yaml:"url"`
+	Headers        map[string]string `json:"headers" yaml:"headers"`
+	EventTypes     []string          `json:"event_types" yaml:"event_types"`
+	LastEventID    string            `json:"last_event_id" yaml:"last_event_id"`
+	ReconnectDelay string            `json:"reconnect_delay" yaml:"reconnect_delay"`
+	TLS            btls.Config       `json:"tls" yaml:"tls"`
+	auth.Config    `json:",inline" yaml:",inline"`
+}
+
+// NewSSEConfig creates a new SSEConfig with default values.
+func NewSSEConfig() SSEConfig {
+	return SSEConfig{
+		URL:            "http://localhost:4195/events",
+		Headers:        map[string]string{},
+		EventTypes:     []string{},
+		LastEventID:    "",
+		ReconnectDelay: "1s",
+		TLS:            btls.NewConfig(),
+		Config:         auth.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// sseEvent is a single event dispatched from an event stream.
+type sseEvent struct {
+	event string
+	id    string
+	data  string
+}
+
+// sseDecoder parses events from an event stream following the EventSource
+// specification.
+type sseDecoder struct {
+	r           *bufio.Reader
+	first       bool
+	lastEventID string
+	retry       time.Duration
+}
+
+func newSSEDecoder(r io.Reader, lastEventID string) *sseDecoder {
+	return &sseDecoder{
+		r:           bufio.NewReader(r),
+		first:       true,
+		lastEventID: lastEventID,
+	}
+}
+
+// next blocks until the next event of the stream is dispatched.
+func (d *sseDecoder) next() (*sseEvent, error) {
+	var eventType string
+	var data strings.Builder
+	var hasData bool
+
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil {
+			// An incomplete event at the end of a stream is discarded.
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if d.first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			d.first = false
+		}
+
+		if len(line) == 0 {
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			if len(eventType) == 0 {
+				eventType = "message"
+			}
+			return &sseEvent{
+				event: eventType,
+				id:    d.lastEventID,
+				data:  strings.TrimSuffix(data.String(), "\n"),
+			}, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SSE is an input type that reads events from a Server-Sent Events endpoint.
+type SSE struct {
+	conf       SSEConfig
+	client     *http.Client
+	eventTypes map[string]struct{}
+
+	mut         sync.Mutex
+	body        io.ReadCloser
+	decoder     *sseDecoder
+	lastEventID string
+	retry       time.Duration
+	lastDrop    time.Time
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSSE creates a new SSE input type.
+func NewSSE(conf SSEConfig, log log.Modular, stats metrics.Type) (*SSE, error) {
+	s := &SSE{
+		conf:        conf,
+		client:      &http.Client{},
+		lastEventID: conf.LastEventID,
+		log:         log,
+		stats:       stats,
+	}
+	if len(conf.URL) == 0 {
+		return nil, errors.New("a url must be specified")
+	}
+	if len(conf.EventTypes) > 0 {
+		s.eventTypes = map[string]struct{}{}
+		for _, t := range conf.EventTypes {
+			s.eventTypes[t] = struct{}{}
+		}
+	}
+	if len(conf.ReconnectDelay) > 0 {
+		var err error
+		if s.retry, err = time.ParseDuration(conf.ReconnectDelay); err != nil {
+			return nil, fmt.Errorf("failed to parse reconnect delay: %v", err)
+		}
+	}
+	if conf.TLS.Enabled {
+		tlsConf, err := conf.TLS.Get()
+		if err != nil {
+			return nil, err
+		}
+		s.client.Transport = &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a connection to an SSE endpoint.
+func (s *SSE) Connect() error {
+	return s.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext establishes a connection to an SSE endpoint, resuming
+// from the last event ID received when reconnecting.
+func (s *SSE) ConnectWithContext(ctx context.Context) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.body != nil {
+		return nil
+	}
+
+	if !s.lastDrop.IsZero() {
+		if wait := time.Until(s.lastDrop.Add(s.retry)); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	req, err := http.NewRequest("GET", s.conf.URL, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range s.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if len(s.lastEventID) > 0 {
+		req.Header.Set("Last-Event-ID", s.lastEventID)
+	}
+	if err = s.conf.Sign(req); err != nil {
+		return err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		s.lastDrop = time.Now()
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		s.lastDrop = time.Now()
+		return fmt.Errorf("unexpected response status: %v", res.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		res.Body.Close()
+		s.lastDrop = time.Now()
+		return fmt.Errorf("unexpected response content type: %v", res.Header.Get("Content-Type"))
+	}
+
+	s.body = res.Body
+	s.decoder = newSSEDecoder(res.Body, s.lastEventID)
+	s.log.Infof("Receiving events from: %v\n", s.conf.URL)
+	return nil
+}
+
+func (s *SSE) drop(body io.ReadCloser) {
+	s.mut.Lock()
+	if s.body == body {
+		if s.decoder.retry > 0 {
+			s.retry = s.decoder.retry
+		}
+		s.body.Close()
+		s.body = nil
+		s.decoder = nil
+		s.lastDrop = time.Now()
+	}
+	s.mut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// Read attempts to read a new event from the SSE endpoint.
+func (s *SSE) Read() (types.Message, error) {
+	msg, _, err := s.ReadWithContext(context.Background())
+	return msg, err
+}
+
+// ReadWithContext attempts to read a new event from the SSE endpoint.
+func (s *SSE) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	s.mut.Lock()
+	body, decoder := s.body, s.decoder
+	s.mut.Unlock()
+
+	if body == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	for {
+		event, err := decoder.next()
+		if err != nil {
+			if err != io.EOF {
+				s.log.Errorf("Failed to read event stream: %v\n", err)
+			}
+			s.drop(body)
+			return nil, nil, types.ErrNotConnected
+		}
+
+		s.mut.Lock()
+		s.lastEventID = decoder.lastEventID
+		s.mut.Unlock()
+
+		if s.eventTypes != nil {
+			if _, exists := s.eventTypes[event.event]; !exists {
+				continue
+			}
+		}
+
+		msg := message.New([][]byte{[]byte(event.data)})
+		meta := msg.Get(0).Metadata()
+		meta.Set("sse_event", event.event)
+		if len(event.id) > 0 {
+			meta.Set("sse_id", event.id)
+		}
+		return msg, noopAsyncAckFn, nil
+	}
+}
+
+// CloseAsync shuts down the SSE input and stops reading events.
+func (s *SSE) CloseAsync() {
+	s.mut.Lock()
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+		s.decoder = nil
+	}
+	s.mut.Unlock()
+}
+
+// WaitForClose blocks until the SSE input has closed down.
+func (s *SSE) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
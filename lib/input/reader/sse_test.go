@@ -0,0 +1,166 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestSSEDecoder(t *testing.T) {
+	stream := "\ufeff: a comment\r\n" +
+		"data: foo\r\n\r\n" +
+		"event: update\n" +
+		"id: 1\n" +
+		"data: bar\n" +
+		"data:baz\n\n" +
+		"id: 2\n\n" +
+		"retry: 500\n" +
+		"event: nope\n" +
+		"data\n\n" +
+		"data: incomplete"
+
+	d := newSSEDecoder(strings.NewReader(stream), "")
+
+	var events []sseEvent
+	for {
+		e, err := d.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, *e)
+	}
+
+	exp := []sseEvent{
+		{event: "message", data: "foo"},
+		{event: "update", id: "1", data: "bar\nbaz"},
+		{event: "nope", id: "2", data: ""},
+	}
+	if !reflect.DeepEqual(exp, events) {
+		t.Errorf("Wrong events: %+v != %+v", events, exp)
+	}
+	if exp, act := 500*time.Millisecond, d.retry; exp != act {
+		t.Errorf("Wrong retry: %v != %v", act, exp)
+	}
+}
+
+func TestSSEReconnect(t *testing.T) {
+	var mut sync.Mutex
+	var lastIDs []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mut.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		n := len(lastIDs)
+		mut.Unlock()
+
+		if r.Header.Get("Accept") != "text/event-stream" {
+			http.Error(w, "bad accept header", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		fmt.Fprintf(w, "retry: 10\n")
+		fmt.Fprintf(w, "event: ping\ndata: ignored\n\n")
+		fmt.Fprintf(w, "id: %v\nevent: update\ndata: hello %v\n\n", n, n)
+	}))
+	defer server.Close()
+
+	conf := NewSSEConfig()
+	conf.URL = server.URL
+	conf.EventTypes = []string{"update"}
+	conf.LastEventID = "0"
+
+	s, err := NewSSE(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		s.CloseAsync()
+		if err := s.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for i := 1; i <= 2; i++ {
+		if err = s.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := s.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := fmt.Sprintf("hello %v", i), string(msg.Get(0).Get()); exp != act {
+			t.Errorf("Wrong message: %v != %v", act, exp)
+		}
+		if exp, act := "update", msg.Get(0).Metadata().Get("sse_event"); exp != act {
+			t.Errorf("Wrong event metadata: %v != %v", act, exp)
+		}
+		if exp, act := fmt.Sprintf("%v", i), msg.Get(0).Metadata().Get("sse_id"); exp != act {
+			t.Errorf("Wrong id metadata: %v != %v", act, exp)
+		}
+		if _, err = s.Read(); err != types.ErrNotConnected {
+			t.Errorf("Wrong error: %v != %v", err, types.ErrNotConnected)
+		}
+	}
+
+	mut.Lock()
+	if exp, act := []string{"0", "1"}, lastIDs; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong last event IDs: %v != %v", act, exp)
+	}
+	mut.Unlock()
+}
+
+func TestSSEBadContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	conf := NewSSEConfig()
+	conf.URL = server.URL
+
+	s, err := NewSSE(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Connect(); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+//------------------------------------------------------------------------------
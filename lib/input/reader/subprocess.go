@@ -0,0 +1,330 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// SubprocessConfig contains configuration fields for the Subprocess input
+// type.
+type SubprocessConfig struct {
+	Name         string   `json:"name" yaml:"name"`
+	Args         []string `json:"args" yaml:"args"`
+	Codec        string   `json:"codec" yaml:"codec"`
+	MaxBuffer    int      `json:"max_buffer" yaml:"max_buffer"`
+	Restart      string   `json:"restart" yaml:"restart"`
+	RestartDelay string   `json:"restart_delay" yaml:"restart_delay"`
+}
+
+// NewSubprocessConfig creates a new SubprocessConfig with default values.
+func NewSubprocessConfig() SubprocessConfig {
+	return SubprocessConfig{
+		Name:         "cat",
+		Args:         []string{},
+		Codec:        "lines",
+		MaxBuffer:    bufio.MaxScanTokenSize,
+		Restart:      "on_failure",
+		RestartDelay: "1s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Subprocess is an input type that runs a command and reads messages from its
+// stdout stream.
+type Subprocess struct {
+	conf         SubprocessConfig
+	restartDelay time.Duration
+
+	mut      sync.Mutex
+	cmd      *exec.Cmd
+	msgChan  chan []byte
+	procDone chan struct{}
+	started  bool
+	exitErr  error
+	lastExit time.Time
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSubprocess creates a new Subprocess input type.
+func NewSubprocess(conf SubprocessConfig, log log.Modular, stats metrics.Type) (*Subprocess, error) {
+	s := &Subprocess{
+		conf:      conf,
+		closeChan: make(chan struct{}),
+		log:       log,
+		stats:     stats,
+	}
+	if len(conf.Name) == 0 {
+		return nil, errors.New("a command name must be specified")
+	}
+	switch conf.Codec {
+	case "lines", "length_prefixed_uint32_be":
+	default:
+		return nil, fmt.Errorf("codec not recognised: %v", conf.Codec)
+	}
+	switch conf.Restart {
+	case "always", "on_failure", "never":
+	default:
+		return nil, fmt.Errorf("restart policy not recognised: %v", conf.Restart)
+	}
+	if len(conf.RestartDelay) > 0 {
+		var err error
+		if s.restartDelay, err = time.ParseDuration(conf.RestartDelay); err != nil {
+			return nil, fmt.Errorf("failed to parse restart delay: %v", err)
+		}
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect starts the subprocess.
+func (s *Subprocess) Connect() error {
+	return s.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext starts the subprocess, or restarts it after it has
+// exited when permitted by the restart policy.
+func (s *Subprocess) ConnectWithContext(ctx context.Context) error {
+	select {
+	case <-s.closeChan:
+		return types.ErrTypeClosed
+	default:
+	}
+
+	s.mut.Lock()
+	if s.msgChan != nil {
+		s.mut.Unlock()
+		return nil
+	}
+	if s.started {
+		if s.conf.Restart == "never" || (s.conf.Restart == "on_failure" && s.exitErr == nil) {
+			s.mut.Unlock()
+			return types.ErrTypeClosed
+		}
+	}
+	wait := time.Until(s.lastExit.Add(s.restartDelay))
+	s.mut.Unlock()
+
+	if s.started && wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closeChan:
+			return types.ErrTypeClosed
+		}
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	select {
+	case <-s.closeChan:
+		return types.ErrTypeClosed
+	default:
+	}
+
+	cmd := exec.Command(s.conf.Name, s.conf.Args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	msgChan := make(chan []byte)
+	procDone := make(chan struct{})
+
+	var stderrWG sync.WaitGroup
+	stderrWG.Add(1)
+	go func() {
+		defer stderrWG.Done()
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(nil, s.conf.MaxBuffer)
+		for scanner.Scan() {
+			s.log.Errorf("Subprocess stderr: %s\n", scanner.Bytes())
+		}
+	}()
+
+	go func() {
+		defer close(procDone)
+		if err := s.readStdout(stdout, msgChan); err != nil {
+			s.log.Errorf("Failed to read subprocess stdout: %v\n", err)
+			cmd.Process.Kill()
+		}
+		// Drain stdout in case reading was aborted so that the process can
+		// exit.
+		io.Copy(ioutil.Discard, stdout)
+		stderrWG.Wait()
+
+		exitErr := cmd.Wait()
+		if exitErr != nil {
+			s.log.Warnf("Subprocess exited: %v\n", exitErr)
+		} else {
+			s.log.Infoln("Subprocess exited")
+		}
+
+		s.mut.Lock()
+		s.exitErr = exitErr
+		s.lastExit = time.Now()
+		s.mut.Unlock()
+		close(msgChan)
+	}()
+
+	s.cmd = cmd
+	s.msgChan = msgChan
+	s.procDone = procDone
+	s.started = true
+	s.log.Infof("Reading messages from subprocess: %v\n", s.conf.Name)
+	return nil
+}
+
+// readStdout reads messages from the stdout of a subprocess according to the
+// codec until the stream ends.
+func (s *Subprocess) readStdout(stdout io.Reader, msgChan chan<- []byte) error {
+	send := func(b []byte) bool {
+		select {
+		case msgChan <- b:
+			return true
+		case <-s.closeChan:
+			return false
+		}
+	}
+
+	if s.conf.Codec == "lines" {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(nil, s.conf.MaxBuffer)
+		for scanner.Scan() {
+			if !send(append([]byte(nil), scanner.Bytes()...)) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	r := bufio.NewReader(stdout)
+	var lenBytes [4]byte
+	for {
+		if _, err := io.ReadFull(r, lenBytes[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := binary.BigEndian.Uint32(lenBytes[:])
+		if int64(size) > int64(s.conf.MaxBuffer) {
+			return fmt.Errorf("message size %v exceeds max buffer", size)
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+		if !send(b) {
+			return nil
+		}
+	}
+}
+
+// ReadWithContext attempts to read a new message from the subprocess.
+func (s *Subprocess) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	s.mut.Lock()
+	msgChan := s.msgChan
+	s.mut.Unlock()
+
+	if msgChan == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	select {
+	case b, open := <-msgChan:
+		if !open {
+			s.mut.Lock()
+			if s.msgChan == msgChan {
+				s.msgChan = nil
+				s.cmd = nil
+			}
+			s.mut.Unlock()
+			return nil, nil, types.ErrNotConnected
+		}
+		return message.New([][]byte{b}), noopAsyncAckFn, nil
+	case <-ctx.Done():
+	}
+	return nil, nil, types.ErrTimeout
+}
+
+// CloseAsync shuts down the Subprocess input, killing the subprocess.
+func (s *Subprocess) CloseAsync() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+		s.mut.Lock()
+		if s.cmd != nil {
+			s.cmd.Process.Kill()
+		}
+		s.mut.Unlock()
+	})
+}
+
+// WaitForClose blocks until the Subprocess input has closed down.
+func (s *Subprocess) WaitForClose(timeout time.Duration) error {
+	s.mut.Lock()
+	procDone := s.procDone
+	s.mut.Unlock()
+
+	if procDone == nil {
+		return nil
+	}
+	select {
+	case <-procDone:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
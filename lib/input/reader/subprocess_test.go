@@ -0,0 +1,176 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func readSubprocess(t *testing.T, s *Subprocess) ([]string, error) {
+	t.Helper()
+
+	var msgs []string
+	for {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		err := s.ConnectWithContext(ctx)
+		if err == nil {
+			var msg types.Message
+			if msg, _, err = s.ReadWithContext(ctx); err == nil {
+				msgs = append(msgs, string(msg.Get(0).Get()))
+			}
+		}
+		done()
+		if err == types.ErrNotConnected {
+			continue
+		}
+		if err != nil {
+			return msgs, err
+		}
+	}
+}
+
+func TestSubprocessLines(t *testing.T) {
+	conf := NewSubprocessConfig()
+	conf.Name = "sh"
+	conf.Args = []string{"-c", "echo foo; echo bar >&2; echo baz"}
+
+	s, err := NewSubprocess(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	msgs, err := readSubprocess(t, s)
+	if err != types.ErrTypeClosed {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrTypeClosed)
+	}
+	if exp, act := []string{"foo", "baz"}, msgs; len(act) != 2 || act[0] != exp[0] || act[1] != exp[1] {
+		t.Errorf("Wrong messages: %v != %v", act, exp)
+	}
+}
+
+func TestSubprocessLengthPrefixed(t *testing.T) {
+	conf := NewSubprocessConfig()
+	conf.Name = "printf"
+	conf.Args = []string{`\000\000\000\007foo\nbar\000\000\000\000\000\000\000\003baz`}
+	conf.Codec = "length_prefixed_uint32_be"
+
+	s, err := NewSubprocess(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	msgs, err := readSubprocess(t, s)
+	if err != types.ErrTypeClosed {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrTypeClosed)
+	}
+	exp := []string{"foo\nbar", "", "baz"}
+	if len(msgs) != len(exp) {
+		t.Fatalf("Wrong messages: %q != %q", msgs, exp)
+	}
+	for i := range exp {
+		if exp[i] != msgs[i] {
+			t.Errorf("Wrong message: %q != %q", msgs[i], exp[i])
+		}
+	}
+}
+
+func TestSubprocessRestartOnFailure(t *testing.T) {
+	conf := NewSubprocessConfig()
+	conf.Name = "sh"
+	conf.Args = []string{"-c", "echo foo; exit 1"}
+	conf.RestartDelay = "10ms"
+
+	s, err := NewSubprocess(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	for i := 0; i < 3; i++ {
+		if err = s.ConnectWithContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+		msg, _, err := s.ReadWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := "foo", string(msg.Get(0).Get()); exp != act {
+			t.Errorf("Wrong message: %v != %v", act, exp)
+		}
+		if _, _, err = s.ReadWithContext(ctx); err != types.ErrNotConnected {
+			t.Errorf("Wrong error: %v != %v", err, types.ErrNotConnected)
+		}
+	}
+
+	s.CloseAsync()
+	if err = s.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubprocessClose(t *testing.T) {
+	conf := NewSubprocessConfig()
+	conf.Name = "sh"
+	conf.Args = []string{"-c", "while true; do echo foo; sleep 0.01; done"}
+	conf.Restart = "always"
+
+	s, err := NewSubprocess(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = s.ReadWithContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	s.CloseAsync()
+	if err = s.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+	if err = s.Connect(); err != types.ErrTypeClosed {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrTypeClosed)
+	}
+}
+
+func TestSubprocessBadConfig(t *testing.T) {
+	conf := NewSubprocessConfig()
+	conf.Codec = "nope"
+	if _, err := NewSubprocess(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad codec")
+	}
+	conf = NewSubprocessConfig()
+	conf.Restart = "nope"
+	if _, err := NewSubprocess(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad restart policy")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/zmtp"
+)
+
+//------------------------------------------------------------------------------
+
+// ZMQ4NConfig contains configuration fields for the ZMQ4N input type.
+type ZMQ4NConfig struct {
+	URLs          []string `json:"urls" yaml:"urls"`
+	Bind          bool     `json:"bind" yaml:"bind"`
+	SocketType    string   `json:"socket_type" yaml:"socket_type"`
+	SubFilters    []string `json:"sub_filters" yaml:"sub_filters"`
+	HighWaterMark int      `json:"high_water_mark" yaml:"high_water_mark"`
+	PollTimeout   string   `json:"poll_timeout" yaml:"poll_timeout"`
+}
+
+// NewZMQ4NConfig creates a new ZMQ4NConfig with default values.
+func NewZMQ4NConfig() ZMQ4NConfig {
+	return ZMQ4NConfig{
+		URLs:          []string{"tcp://localhost:5555"},
+		Bind:          false,
+		SocketType:    "PULL",
+		SubFilters:    []string{},
+		HighWaterMark: 0,
+		PollTimeout:   "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ZMQ4N is an input type that consumes ZMQ messages using a pure Go
+// implementation of the ZMTP protocol.
+type ZMQ4N struct {
+	urls  []string
+	conf  ZMQ4NConfig
+	stats metrics.Type
+	log   log.Modular
+
+	typ         zmtp.SocketType
+	pollTimeout time.Duration
+
+	mut    sync.Mutex
+	socket *zmtp.Socket
+}
+
+// NewZMQ4N creates a new ZMQ4N input type.
+func NewZMQ4N(conf ZMQ4NConfig, log log.Modular, stats metrics.Type) (*ZMQ4N, error) {
+	z := ZMQ4N{
+		conf:  conf,
+		stats: stats,
+		log:   log,
+	}
+
+	for _, u := range conf.URLs {
+		for _, splitU := range strings.Split(u, ",") {
+			if len(splitU) > 0 {
+				z.urls = append(z.urls, splitU)
+			}
+		}
+	}
+
+	switch conf.SocketType {
+	case "SUB":
+		z.typ = zmtp.SUB
+	case "PULL":
+		z.typ = zmtp.PULL
+	default:
+		return nil, types.ErrInvalidZMQType
+	}
+
+	if tout := conf.PollTimeout; len(tout) > 0 {
+		var err error
+		if z.pollTimeout, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse poll timeout string: %v", err)
+		}
+	}
+
+	return &z, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a ZMQ4N socket.
+func (z *ZMQ4N) Connect() error {
+	return z.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext establishes a ZMQ4N socket.
+func (z *ZMQ4N) ConnectWithContext(ignored context.Context) (err error) {
+	z.mut.Lock()
+	defer z.mut.Unlock()
+
+	if z.socket != nil {
+		return nil
+	}
+
+	var socket *zmtp.Socket
+	if socket, err = zmtp.NewSocket(z.typ, z.conf.HighWaterMark); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			socket.Close()
+		}
+	}()
+
+	if z.typ == zmtp.SUB {
+		for _, filter := range z.conf.SubFilters {
+			if err = socket.Subscribe([]byte(filter)); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, address := range z.urls {
+		if z.conf.Bind {
+			err = socket.Bind(address)
+		} else {
+			err = socket.Connect(address)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	z.socket = socket
+
+	if z.conf.Bind {
+		z.log.Infof("Receiving ZMQ4N messages on bound URLs: %s\n", z.urls)
+	} else {
+		z.log.Infof("Receiving ZMQ4N messages on connected URLs: %s\n", z.urls)
+	}
+	return nil
+}
+
+// ReadWithContext attempts to read a new message from the ZMQ socket.
+func (z *ZMQ4N) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	z.mut.Lock()
+	socket := z.socket
+	z.mut.Unlock()
+
+	if socket == nil {
+		return nil, nil, types.ErrNotConnected
+	}
+
+	if z.pollTimeout > 0 {
+		var done func()
+		ctx, done = context.WithTimeout(ctx, z.pollTimeout)
+		defer done()
+	}
+
+	data, err := socket.Recv(ctx)
+	if err != nil {
+		if err == zmtp.ErrClosed {
+			return nil, nil, types.ErrNotConnected
+		}
+		if err == context.DeadlineExceeded {
+			return nil, nil, types.ErrTimeout
+		}
+		return nil, nil, err
+	}
+
+	return message.New(data), noopAsyncAckFn, nil
+}
+
+// CloseAsync shuts down the ZMQ4N input and stops processing requests.
+func (z *ZMQ4N) CloseAsync() {
+	z.mut.Lock()
+	if z.socket != nil {
+		go z.socket.Close()
+		z.socket = nil
+	}
+	z.mut.Unlock()
+}
+
+// WaitForClose blocks until the ZMQ4N input has closed down.
+func (z *ZMQ4N) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
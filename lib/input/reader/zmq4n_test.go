@@ -0,0 +1,94 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package reader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/zmtp"
+)
+
+func TestZMQ4NPull(t *testing.T) {
+	push, err := zmtp.NewSocket(zmtp.PUSH, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer push.Close()
+	if err = push.Bind("tcp://127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewZMQ4NConfig()
+	conf.URLs = []string{"tcp://" + push.Addrs()[0].String()}
+	conf.PollTimeout = "100ms"
+
+	z, err := NewZMQ4N(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = z.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		z.CloseAsync()
+		if err := z.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if _, _, err = z.ReadWithContext(context.Background()); err != types.ErrTimeout {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrTimeout)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+	if err = push.Send(ctx, [][]byte{[]byte("foo"), []byte("bar")}); err != nil {
+		t.Fatal(err)
+	}
+
+	z.pollTimeout = time.Second * 5
+	msg, _, err := z.ReadWithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, msg.Len(); exp != act {
+		t.Fatalf("Wrong count of parts: %v != %v", act, exp)
+	}
+	if exp, act := "foo", string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	if exp, act := "bar", string(msg.Get(1).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+}
+
+func TestZMQ4NBadType(t *testing.T) {
+	conf := NewZMQ4NConfig()
+	conf.SocketType = "PUSH"
+	if _, err := NewZMQ4N(conf, log.Noop(), metrics.Noop()); err != types.ErrInvalidZMQType {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrInvalidZMQType)
+	}
+}
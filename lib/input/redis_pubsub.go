@@ -49,7 +49,25 @@ patterns in your channel names. For example:
 - ` + "`h[ae]llo`" + ` subscribes to hello and hallo, but not hillo
 
 Use ` + "`\\`" + ` to escape special characters if you want to match them
-verbatim.`,
+verbatim.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- redis_pubsub_channel
+- redis_pubsub_pattern
+` + "```" + `
+
+The field ` + "`redis_pubsub_channel`" + ` is the channel the message was
+published to, which when ` + "`use_patterns`" + ` is enabled is the concrete
+channel that matched. The field ` + "`redis_pubsub_pattern`" + ` is only set
+when ` + "`use_patterns`" + ` is enabled and contains the pattern that matched
+the channel.
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
 	}
 }
 
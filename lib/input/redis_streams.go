@@ -49,7 +49,21 @@ Use the ` + "`batching`" + ` fields to configure an optional
 
 Redis stream entries are key/value pairs, as such it is necessary to specify the
 key that contains the body of the message. All other keys/value pairs are saved
-as metadata fields.`,
+as metadata fields.
+
+### Claiming Pending Entries
+
+When a consumer stops without acknowledging the entries it has read, for
+example when an instance crashes mid-batch, those entries remain pending within
+the consumer group and would otherwise never be delivered again. Setting
+` + "`claim_period`" + ` (e.g. ` + "`30s`" + `) causes this input to
+periodically claim, with the XAUTOCLAIM command, entries of the group that have
+been pending for longer than ` + "`claim_min_idle`" + ` and consume them as if
+they had been read by this consumer. This requires Redis v6.2+.
+
+The value of ` + "`claim_min_idle`" + ` should be comfortably longer than the
+time it takes to process and acknowledge a batch, otherwise entries still being
+processed by a healthy consumer may be claimed and delivered twice.`,
 		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
 			return sanitiseWithBatch(conf.RedisStreams, conf.RedisStreams.Batching)
 		},
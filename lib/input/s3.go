@@ -69,6 +69,12 @@ at-least-once crash resiliency, but also means that if the S3 item takes longer
 to process than the visibility timeout of your queue then the same items might
 be processed multiple times.
 
+When a ` + "`codec`" + ` other than ` + "`all-bytes`" + ` is set an object is
+only deleted, or its SQS message removed, once all of its messages have been
+sent onwards.
+
+` + reader.CodecDocumentation + `
+
 ### Credentials
 
 By default Benthos will use a shared credentials file when connecting to AWS
@@ -0,0 +1,455 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSequence] = TypeSpec{
+		constructor: NewSequence,
+		description: `
+Reads messages from a list of inputs one after another. Once an input has been
+exhausted and closed the next input in the list is started, and once the last
+input has closed this input also closes. This is useful for consuming a
+historical backlog before switching to a live source, such as reading archived
+files from S3 and then consuming from Kafka:
+
+` + "``` yaml" + `
+input:
+  sequence:
+    inputs:
+    - s3:
+        bucket: foo
+        prefix: archive/
+    - kafka:
+        addresses: [ localhost:9092 ]
+        topic: foo
+    deduplicate:
+      cache: seen
+      key: ${!json_field:id}
+resources:
+  caches:
+    seen:
+      memory:
+        ttl: 3600
+` + "```" + `
+
+### Deduplication
+
+When the records at the end of one input overlap with the beginning of the next
+it is possible to drop the duplicates by setting ` + "`deduplicate.cache`" + `
+to the name of a cache resource and ` + "`deduplicate.key`" + ` to an
+interpolated string that uniquely identifies a message, which supports
+[function interpolations](../config_interpolation.md#functions).
+
+The key of each message is added to the cache before it is delivered, where
+messages with keys already present are dropped and acknowledged, including
+duplicates within the same batch or of messages that are still being delivered.
+The keys of messages that fail to be delivered are removed from the cache again,
+and therefore those messages are not treated as duplicates when they are
+consumed again. Messages with an empty key are never dropped.
+
+The cache is shared across all inputs of the sequence and should therefore
+retain keys for at least as long as the overlap between them.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			inSlice := []interface{}{}
+			for _, input := range conf.Sequence.Inputs {
+				sanInput, err := SanitiseConfig(input)
+				if err != nil {
+					return nil, err
+				}
+				inSlice = append(inSlice, sanInput)
+			}
+			return map[string]interface{}{
+				"inputs": inSlice,
+				"deduplicate": map[string]interface{}{
+					"cache": conf.Sequence.Deduplicate.Cache,
+					"key":   conf.Sequence.Deduplicate.Key,
+				},
+			}, nil
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SequenceDeduplicateConfig contains configuration fields for deduplicating
+// messages consumed by a sequence input.
+type SequenceDeduplicateConfig struct {
+	Cache string `json:"cache" yaml:"cache"`
+	Key   string `json:"key" yaml:"key"`
+}
+
+// SequenceConfig contains configuration values for the Sequence input type.
+type SequenceConfig struct {
+	Inputs      brokerInputList           `json:"inputs" yaml:"inputs"`
+	Deduplicate SequenceDeduplicateConfig `json:"deduplicate" yaml:"deduplicate"`
+}
+
+// NewSequenceConfig creates a new SequenceConfig with default values.
+func NewSequenceConfig() SequenceConfig {
+	return SequenceConfig{
+		Inputs: brokerInputList{},
+		Deduplicate: SequenceDeduplicateConfig{
+			Cache: "",
+			Key:   "",
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Sequence is an input type that reads from a list of inputs in order, moving
+// onto the next input once the current one has closed.
+type Sequence struct {
+	running int32
+	conf    SequenceConfig
+
+	current    Type
+	currentMut sync.Mutex
+	index      int
+	cache    types.Cache
+	dedupKey *text.InterpolatedString
+
+	wrapperMgr   types.Manager
+	wrapperLog   log.Modular
+	wrapperStats metrics.Type
+
+	stats metrics.Type
+	log   log.Modular
+
+	transactions chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewSequence creates a new Sequence input type.
+func NewSequence(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if len(conf.Sequence.Inputs) == 0 {
+		return nil, errors.New("cannot create sequence input without any inputs")
+	}
+
+	s := &Sequence{
+		running: 1,
+		conf:    conf.Sequence,
+
+		wrapperLog:   log,
+		wrapperStats: stats,
+		wrapperMgr:   mgr,
+
+		log:          log.NewModule(".sequence"),
+		stats:        metrics.Namespaced(stats, "sequence"),
+		transactions: make(chan types.Transaction),
+		closeChan:    make(chan struct{}),
+		closedChan:   make(chan struct{}),
+	}
+
+	if len(conf.Sequence.Deduplicate.Cache) > 0 {
+		var err error
+		if s.cache, err = mgr.GetCache(conf.Sequence.Deduplicate.Cache); err != nil {
+			return nil, fmt.Errorf("failed to obtain cache '%v': %v", conf.Sequence.Deduplicate.Cache, err)
+		}
+		if len(conf.Sequence.Deduplicate.Key) == 0 {
+			return nil, errors.New("a deduplicate key must be specified along with a cache")
+		}
+		s.dedupKey = text.NewInterpolatedString(conf.Sequence.Deduplicate.Key)
+	}
+
+	var err error
+	if s.current, err = s.createInput(0); err != nil {
+		return nil, err
+	}
+
+	go s.loop()
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (s *Sequence) createInput(index int) (Type, error) {
+	conf := s.conf.Inputs[index]
+	ns := fmt.Sprintf("sequence.inputs.%v", index)
+	in, err := New(
+		conf, s.wrapperMgr,
+		s.wrapperLog.NewModule("."+ns),
+		metrics.Combine(s.wrapperStats, metrics.Namespaced(s.wrapperStats, ns)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input '%v' type '%v': %v", index, conf.Type, err)
+	}
+	return in, nil
+}
+
+// setCurrent sets the input currently being consumed from.
+func (s *Sequence) setCurrent(in Type) {
+	s.currentMut.Lock()
+	s.current = in
+	s.currentMut.Unlock()
+}
+
+// getCurrent returns the input currently being consumed from, or nil.
+func (s *Sequence) getCurrent() Type {
+	s.currentMut.Lock()
+	defer s.currentMut.Unlock()
+	return s.current
+}
+
+// dedupe removes the parts of a message with keys that have already been seen,
+// and reserves the keys of the remaining parts in the cache so that duplicates
+// in the same batch or still in flight are also removed. Returns the index of
+// each remaining part within the original message along with its reserved
+// key, which is empty when the part has none.
+func (s *Sequence) dedupe(msg types.Message, mCacheErr metrics.StatCounter) (types.Message, []int, []string) {
+	newMsg := message.New(nil)
+	var indexes []int
+	var keys []string
+	msg.Iter(func(i int, p types.Part) error {
+		key := s.dedupKey.Get(message.Lock(msg, i))
+		if len(key) > 0 {
+			if err := s.cache.Add(key, []byte{'t'}); err == types.ErrKeyAlreadyExists {
+				return nil
+			} else if err != nil {
+				mCacheErr.Incr(1)
+				s.log.Errorf("Cache error: %v\n", err)
+				key = ""
+			}
+		}
+		newMsg.Append(p)
+		indexes = append(indexes, i)
+		keys = append(keys, key)
+		return nil
+	})
+	return newMsg, indexes, keys
+}
+
+// release removes the reserved keys of parts that failed to be delivered from
+// the cache.
+func (s *Sequence) release(keys []string, err error, mCacheErr metrics.StatCounter) {
+	failed := func(int) bool { return true }
+	if bErr, ok := err.(*batch.Error); ok && bErr.IndexedErrors() > 0 && bErr.Len() == len(keys) {
+		failedIndexes := map[int]struct{}{}
+		bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+			if err != nil {
+				failedIndexes[i] = struct{}{}
+			}
+			return true
+		})
+		failed = func(i int) bool {
+			_, exists := failedIndexes[i]
+			return exists
+		}
+	}
+	for i, key := range keys {
+		if len(key) == 0 || !failed(i) {
+			continue
+		}
+		if dErr := s.cache.Delete(key); dErr != nil {
+			mCacheErr.Incr(1)
+			s.log.Errorf("Cache error: %v\n", dErr)
+		}
+	}
+}
+
+// remapError converts a batch error of a deduplicated message into an error of
+// the original message, where indexes maps each part of the deduplicated
+// message to its index within the original.
+func remapError(msg types.Message, indexes []int, err error) error {
+	bErr, ok := err.(*batch.Error)
+	if !ok || bErr.IndexedErrors() == 0 || bErr.Len() != len(indexes) {
+		return err
+	}
+	newErr := batch.NewError(msg, bErr.Unwrap())
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			newErr.Failed(indexes[i], err)
+		}
+		return true
+	})
+	return newErr
+}
+
+func (s *Sequence) loop() {
+	var (
+		mRunning     = s.stats.GetGauge("running")
+		mInputClosed = s.stats.GetCounter("input.closed")
+		mInputErr    = s.stats.GetCounter("input.error")
+		mCount       = s.stats.GetCounter("count")
+		mDuplicate   = s.stats.GetCounter("duplicate")
+		mPropagated  = s.stats.GetCounter("propagated")
+		mCacheErr    = s.stats.GetCounter("error.cache")
+	)
+
+	defer func() {
+		if s.current != nil {
+			s.current.CloseAsync()
+		}
+		mRunning.Decr(1)
+
+		close(s.transactions)
+		close(s.closedChan)
+	}()
+	mRunning.Incr(1)
+
+	for atomic.LoadInt32(&s.running) == 1 {
+		if s.current == nil {
+			if s.index++; s.index >= len(s.conf.Inputs) {
+				s.log.Infoln("Final input of sequence has closed")
+				return
+			}
+			in, err := s.createInput(s.index)
+			if err != nil {
+				mInputErr.Incr(1)
+				s.log.Errorf("%v\n", err)
+				return
+			}
+			s.setCurrent(in)
+			s.log.Infof("Moving onto input %v of sequence\n", s.index)
+		}
+
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-s.current.TransactionChan():
+			if !open {
+				mInputClosed.Incr(1)
+				s.setCurrent(nil)
+				continue
+			}
+		case <-s.closeChan:
+			return
+		}
+		mCount.Incr(1)
+
+		if s.cache == nil {
+			select {
+			case s.transactions <- tran:
+				mPropagated.Incr(1)
+			case <-s.closeChan:
+				return
+			}
+			continue
+		}
+
+		msg, indexes, keys := s.dedupe(tran.Payload, mCacheErr)
+		if dropped := tran.Payload.Len() - msg.Len(); dropped > 0 {
+			mDuplicate.Incr(int64(dropped))
+		}
+		if msg.Len() == 0 {
+			select {
+			case tran.ResponseChan <- response.NewAck():
+			case <-s.closeChan:
+				return
+			}
+			continue
+		}
+
+		resChan := make(chan types.Response)
+		select {
+		case s.transactions <- types.NewTransaction(msg, resChan):
+			mPropagated.Incr(1)
+		case <-s.closeChan:
+			return
+		}
+
+		go func(resChan <-chan types.Response, resChanOut chan<- types.Response, payload types.Message, indexes []int, keys []string) {
+			var res types.Response
+			var open bool
+			select {
+			case res, open = <-resChan:
+				if !open {
+					return
+				}
+			case <-s.closeChan:
+				return
+			}
+			if err := res.Error(); err != nil {
+				s.release(keys, err, mCacheErr)
+				if rErr := remapError(payload, indexes, err); rErr != err {
+					res = response.NewError(rErr)
+				}
+			}
+			select {
+			case resChanOut <- res:
+			case <-s.closeChan:
+			}
+		}(resChan, tran.ResponseChan, tran.Payload, indexes, keys)
+	}
+}
+
+// TransactionChan returns a transactions channel for consuming messages from
+// this input type.
+func (s *Sequence) TransactionChan() <-chan types.Transaction {
+	return s.transactions
+}
+
+// Connected returns a boolean indicating whether this input is currently
+// connected to its target.
+func (s *Sequence) Connected() bool {
+	if current := s.getCurrent(); current != nil {
+		return current.Connected()
+	}
+	return false
+}
+
+// CloseAsync shuts down the Sequence input and stops processing requests.
+func (s *Sequence) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&s.running, 1, 0) {
+		close(s.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Sequence input has closed down.
+func (s *Sequence) WaitForClose(timeout time.Duration) error {
+	stopBy := time.Now().Add(timeout)
+	select {
+	case <-s.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	if current := s.getCurrent(); current != nil {
+		return current.WaitForClose(time.Until(stopBy))
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,299 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func writeSequenceFiles(t *testing.T, contents ...string) []Config {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "benthos_sequence_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	var confs []Config
+	for i, content := range contents {
+		path := filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		conf := NewConfig()
+		conf.Type = TypeFile
+		conf.File.Path = path
+		confs = append(confs, conf)
+	}
+	return confs
+}
+
+func readSequence(t *testing.T, in Type, resErrs map[string]error) []string {
+	t.Helper()
+
+	var msgs []string
+	for {
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-in.TransactionChan():
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+		if !open {
+			return msgs
+		}
+		content := string(tran.Payload.Get(0).Get())
+		msgs = append(msgs, content)
+
+		var res types.Response = response.NewAck()
+		if err, exists := resErrs[content]; exists {
+			delete(resErrs, content)
+			res = response.NewError(err)
+		}
+		select {
+		case tran.ResponseChan <- res:
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestSequenceBasic(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeSequence
+	conf.Sequence.Inputs = writeSequenceFiles(t, "foo\nbar", "baz", "qux\nquz")
+
+	in, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Connected is called concurrently with the sequence moving between
+	// inputs.
+	doneChan := make(chan struct{})
+	defer close(doneChan)
+	go func() {
+		for {
+			select {
+			case <-doneChan:
+				return
+			default:
+			}
+			in.Connected()
+		}
+	}()
+
+	msgs := readSequence(t, in, nil)
+	exp := []string{"foo", "bar", "baz", "qux", "quz"}
+	if len(msgs) != len(exp) {
+		t.Fatalf("Wrong messages: %v != %v", msgs, exp)
+	}
+	for i, m := range exp {
+		if msgs[i] != m {
+			t.Errorf("Wrong message %v: %v != %v", i, msgs[i], m)
+		}
+	}
+
+	if err = in.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSequenceDeduplicate(t *testing.T) {
+	mgrConf := manager.NewConfig()
+	cacheConf := cache.NewConfig()
+	cacheConf.Type = cache.TypeMemory
+	mgrConf.Caches["seen"] = cacheConf
+
+	mgr, err := manager.New(mgrConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.Type = TypeSequence
+	conf.Sequence.Inputs = writeSequenceFiles(t, "foo\nbar\nbaz", "bar\nbaz\nqux")
+	conf.Sequence.Deduplicate.Cache = "seen"
+	conf.Sequence.Deduplicate.Key = "${!content}"
+
+	in, err := New(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed delivery of baz is retried and must not be treated as a
+	// duplicate.
+	msgs := readSequence(t, in, map[string]error{
+		"baz": errors.New("nope"),
+	})
+	exp := []string{"foo", "bar", "baz", "baz", "qux"}
+	if len(msgs) != len(exp) {
+		t.Fatalf("Wrong messages: %v != %v", msgs, exp)
+	}
+	for i, m := range exp {
+		if msgs[i] != m {
+			t.Errorf("Wrong message %v: %v != %v", i, msgs[i], m)
+		}
+	}
+
+	if err = in.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSequenceDeduplicateBatch(t *testing.T) {
+	mgrConf := manager.NewConfig()
+	cacheConf := cache.NewConfig()
+	cacheConf.Type = cache.TypeMemory
+	mgrConf.Caches["seen"] = cacheConf
+
+	mgr, err := manager.New(mgrConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.Type = TypeSequence
+	conf.Sequence.Inputs = writeSequenceFiles(t, "foo\nfoo\nbar\n")
+	conf.Sequence.Inputs[0].File.Multipart = true
+	conf.Sequence.Deduplicate.Cache = "seen"
+	conf.Sequence.Deduplicate.Key = "${!content}"
+
+	in, err := New(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen, err := mgr.GetCache("seen")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tran types.Transaction
+	select {
+	case tran = <-in.TransactionChan():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+
+	// Duplicates within a batch are dropped.
+	var contents []string
+	tran.Payload.Iter(func(_ int, p types.Part) error {
+		contents = append(contents, string(p.Get()))
+		return nil
+	})
+	if exp := []string{"foo", "bar"}; !reflect.DeepEqual(exp, contents) {
+		t.Errorf("Wrong batch: %v != %v", contents, exp)
+	}
+
+	// Only the keys of failed messages are released.
+	select {
+	case tran.ResponseChan <- response.NewError(batch.NewError(tran.Payload, errors.New("nope")).Failed(1, errors.New("nope"))):
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+	timeout := time.After(time.Second * 5)
+	for {
+		if _, err = seen.Get("bar"); err == types.ErrKeyNotFound {
+			break
+		}
+		select {
+		case <-time.After(time.Millisecond):
+		case <-timeout:
+			t.Fatal("timed out waiting for key to be released")
+		}
+	}
+	if _, err = seen.Get("foo"); err != nil {
+		t.Errorf("Expected key of delivered message to remain: %v", err)
+	}
+
+	in.CloseAsync()
+	if err = in.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSequenceRemapError(t *testing.T) {
+	msg := message.New([][]byte{[]byte("foo"), []byte("foo"), []byte("bar")})
+	deduped := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	indexes := []int{0, 2}
+
+	err := remapError(msg, indexes, batch.NewError(deduped, errors.New("nope")).Failed(1, errors.New("bad bar")))
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got %T", err)
+	}
+	if exp, act := 3, bErr.Len(); exp != act {
+		t.Errorf("Wrong batch size: %v != %v", act, exp)
+	}
+	var failed []int
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			failed = append(failed, i)
+		}
+		return true
+	})
+	if exp := []int{2}; !reflect.DeepEqual(exp, failed) {
+		t.Errorf("Wrong failed indexes: %v != %v", failed, exp)
+	}
+
+	genErr := errors.New("nope")
+	if act := remapError(msg, indexes, genErr); act != genErr {
+		t.Errorf("Expected general error unchanged: %v", act)
+	}
+}
+
+func TestSequenceBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeSequence
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from empty inputs")
+	}
+
+	mgr, err := manager.New(manager.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Sequence.Inputs = writeSequenceFiles(t, "foo")
+	conf.Sequence.Deduplicate.Cache = "nope"
+	if _, err := New(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSFTP] = TypeSpec{
+		constructor: NewSFTP,
+		description: `
+Polls directories of an SFTP server and downloads the files that match any of
+the ` + "`paths`" + `, emitting the contents of each file as a message. Paths
+are glob patterns such as ` + "`/uploads/*.csv`" + `, where only the file name
+may contain wildcards.
+
+The server can be authenticated with either a ` + "`password`" + `, a
+` + "`private_key_file`" + ` or both. When ` + "`known_hosts_file`" + ` is set
+the host key of the server is verified against it, otherwise it is not verified.
+
+By default files are left in place and are only read again when their size or
+modification time changes, which is tracked in memory and is therefore lost on
+restart. When ` + "`delete_on_finish`" + ` is true files are deleted once their
+messages have been successfully processed, and when ` + "`move_to`" + ` is set
+they are instead moved into that directory. Failed messages are retried until
+they succeed.
+
+When a ` + "`codec`" + ` other than ` + "`all-bytes`" + ` is set a file is
+only deleted or moved once all of its messages have been successfully
+processed.
+
+` + reader.CodecDocumentation + `
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "```" + `
+- sftp_path
+- sftp_mod_time_unix
+- sftp_mod_time (RFC3339)
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSFTP creates a new SFTP input type.
+func NewSFTP(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewSFTP(conf.SFTP, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(
+		TypeSFTP,
+		true,
+		reader.NewAsyncPreserver(r),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
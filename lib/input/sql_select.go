@@ -0,0 +1,87 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSQLSelect] = TypeSpec{
+		constructor: NewSQLSelect,
+		description: `
+Selects the rows of a table in pages using keyset pagination, where each page
+is emitted as a batch with a JSON object for each row, where the key is the
+column name and the value is that columns value in the row.
+
+Pages are selected with a query of the form:
+
+` + "```sql" + `
+SELECT <columns> FROM <table> WHERE (<where>) AND <cursor_column> > <cursor>
+ORDER BY <cursor_column> ASC LIMIT <batch_size>
+` + "```" + `
+
+Where the cursor is the value of ` + "`cursor_column`" + ` of the last row of
+the previous page. The first page is selected from ` + "`cursor_initial`" + `
+when set, otherwise from the start of the table. The cursor column must be
+selected by ` + "`columns`" + `, and should be unique and indexed, such as an
+auto incrementing primary key or, for tables that are only appended to, a
+creation timestamp.
+
+When ` + "`interval`" + ` is empty the input shuts down once all rows have been
+selected. Otherwise the input waits for the interval each time it catches up
+and then selects rows added since, which is useful for incremental extraction.
+The cursor is not persisted, and therefore restarting the input begins from
+` + "`cursor_initial`" + ` again.
+
+The ` + "`table`" + `, ` + "`columns`" + `, ` + "`where`" + ` and
+` + "`cursor_column`" + ` fields are inserted into the query as they are and
+must therefore not be derived from untrusted input.
+
+### Drivers
+
+The following is a list of supported drivers and their respective DSN formats:
+
+- ` + "`mysql`: `[username[:password]@][protocol[(address)]]/dbname[?param1=value1&...&paramN=valueN]`" + `
+- ` + "`postgres`: `postgresql://[user[:password]@][netloc][:port][/dbname][?param1=value1&...]`" + `
+
+Please note that the ` + "`postgres`" + ` driver enforces SSL by default, you
+can override this with the parameter ` + "`sslmode=disable`" + ` if required.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSQLSelect creates a new SQLSelect input type.
+func NewSQLSelect(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewSQLSelect(conf.SQLSelect, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeSQLSelect, true, reader.NewAsyncPreserver(r), log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSubprocess] = TypeSpec{
+		constructor: NewSubprocess,
+		description: `
+Runs a command as a subprocess and consumes messages from its stdout stream,
+which allows arbitrary programs such as metric collectors or log shippers to be
+embedded within a pipeline. Anything written by the subprocess to stderr is
+logged.
+
+### Codecs
+
+The ` + "`codec`" + ` determines how messages are read from stdout:
+
+- ` + "`lines`" + `: Each line is a message.
+- ` + "`length_prefixed_uint32_be`" + `: Each message is prefixed with its
+  length as a four byte unsigned big endian integer, which allows messages to
+  contain line breaks.
+
+The field ` + "`max_buffer`" + ` defines the maximum size of a message, and
+should be set significantly above the real expected maximum message size.
+
+### Restarts
+
+When the subprocess exits the ` + "`restart`" + ` policy determines whether
+it is started again after the ` + "`restart_delay`" + `:
+
+- ` + "`always`" + `: The subprocess is always restarted.
+- ` + "`on_failure`" + `: The subprocess is only restarted when it exits with
+  a non-zero status, and otherwise this input closes.
+- ` + "`never`" + `: This input closes as soon as the subprocess exits.
+
+When this input closes the subprocess is killed.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSubprocess creates a new Subprocess input type.
+func NewSubprocess(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	r, err := reader.NewSubprocess(conf.Subprocess, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(
+		TypeSubprocess,
+		true,
+		reader.NewAsyncPreserver(r),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,636 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSyslogServer] = TypeSpec{
+		constructor: NewSyslogServer,
+		description: `
+Creates a server that receives syslog messages over UDP, TCP or TLS and parses
+each message into a JSON document.
+
+The field ` + "`network`" + ` can be one of ` + "`udp`, `tcp` or `tls`" + `.
+When using ` + "`tls`" + ` the fields ` + "`cert_file` and `key_file`" + `
+must be set to a certificate and private key for the server.
+
+Over UDP each datagram is treated as a single syslog message. Over TCP and TLS
+both octet counting and non-transparent (line feed delimited) framing as
+described in [RFC6587](https://tools.ietf.org/html/rfc6587) are supported, and
+are detected on a per message basis.
+
+The field ` + "`format`" + ` can be one of ` + "`rfc5424`, `rfc3164` or `auto`" + `,
+where ` + "`auto`" + ` detects the format of each message from the presence of
+a version number following the priority.
+
+### Fields
+
+Messages are parsed into a JSON object with the following fields, where fields
+absent from (or nil within) the original message are omitted:
+
+` + "``` json" + `
+{
+  "priority": 165,
+  "facility": 20,
+  "severity": 5,
+  "version": 1,
+  "timestamp": "2003-10-11T22:14:15.003Z",
+  "hostname": "mymachine.example.com",
+  "app_name": "evntslog",
+  "proc_id": "1234",
+  "msg_id": "ID47",
+  "structured_data": {
+    "exampleSDID@32473": {
+      "iut": "3",
+      "eventSource": "Application"
+    }
+  },
+  "message": "An application event log entry..."
+}
+` + "```" + `
+
+The fields ` + "`version`, `msg_id` and `structured_data`" + ` are only
+present for RFC5424 messages. RFC3164 timestamps do not contain a year and are
+therefore interpreted as belonging to the current year in local time.
+
+Messages that fail to parse are forwarded with their raw contents and are
+flagged as having failed, allowing you to handle them with
+[error handling](../error_handling.md) patterns.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- syslog_remote_addr
+` + "```" + `
+
+You can access these metadata fields using
+[function interpolation](../config_interpolation.md#metadata).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SyslogServerConfig contains configuration for the SyslogServer input type.
+type SyslogServerConfig struct {
+	Network   string `json:"network" yaml:"network"`
+	Address   string `json:"address" yaml:"address"`
+	Format    string `json:"format" yaml:"format"`
+	MaxBuffer int    `json:"max_buffer" yaml:"max_buffer"`
+	CertFile  string `json:"cert_file" yaml:"cert_file"`
+	KeyFile   string `json:"key_file" yaml:"key_file"`
+}
+
+// NewSyslogServerConfig creates a new SyslogServerConfig with default values.
+func NewSyslogServerConfig() SyslogServerConfig {
+	return SyslogServerConfig{
+		Network:   "udp",
+		Address:   "0.0.0.0:514",
+		Format:    "auto",
+		MaxBuffer: 1000000,
+		CertFile:  "",
+		KeyFile:   "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SyslogServer is an input type that binds to an address and consumes syslog
+// messages over UDP, TCP or TLS.
+type SyslogServer struct {
+	running int32
+
+	conf  SyslogServerConfig
+	stats metrics.Type
+	log   log.Modular
+
+	parse    func(raw []byte) (map[string]interface{}, error)
+	listener net.Listener
+	conn     net.PacketConn
+
+	transactions chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewSyslogServer creates a new SyslogServer input type.
+func NewSyslogServer(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	sConf := conf.SyslogServer
+
+	s := SyslogServer{
+		running: 1,
+		conf:    sConf,
+		stats:   stats,
+		log:     log,
+
+		transactions: make(chan types.Transaction),
+		closeChan:    make(chan struct{}),
+		closedChan:   make(chan struct{}),
+	}
+
+	switch sConf.Format {
+	case "rfc5424":
+		s.parse = parseRFC5424
+	case "rfc3164":
+		s.parse = parseRFC3164
+	case "auto":
+		s.parse = parseSyslogAuto
+	default:
+		return nil, fmt.Errorf("format not recognised: %v", sConf.Format)
+	}
+
+	var err error
+	switch sConf.Network {
+	case "udp":
+		s.conn, err = net.ListenPacket("udp", sConf.Address)
+	case "tcp":
+		s.listener, err = net.Listen("tcp", sConf.Address)
+	case "tls":
+		if len(sConf.CertFile) == 0 || len(sConf.KeyFile) == 0 {
+			return nil, errors.New("both a cert_file and key_file must be specified for the tls network")
+		}
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(sConf.CertFile, sConf.KeyFile); err != nil {
+			return nil, err
+		}
+		s.listener, err = tls.Listen("tcp", sConf.Address, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		})
+	default:
+		return nil, fmt.Errorf("network not recognised: %v", sConf.Network)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go s.loop()
+	return &s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Addr returns the underlying listeners address.
+func (s *SyslogServer) Addr() net.Addr {
+	if s.conn != nil {
+		return s.conn.LocalAddr()
+	}
+	return s.listener.Addr()
+}
+
+func (s *SyslogServer) newMessage(raw []byte, remote net.Addr) types.Message {
+	part := message.NewPart(nil)
+	fields, err := s.parse(raw)
+	if err == nil {
+		err = part.SetJSON(fields)
+	}
+	if err != nil {
+		s.log.Debugf("Failed to parse syslog message: %v\n", err)
+		rawCopy := make([]byte, len(raw))
+		copy(rawCopy, raw)
+		part.Set(rawCopy)
+		processor.FlagErr(part, err)
+	}
+	if remote != nil {
+		part.Metadata().Set("syslog_remote_addr", remote.String())
+	}
+	msg := message.New(nil)
+	msg.Append(part)
+	return msg
+}
+
+// splitSyslogFrames is a bufio.SplitFunc that extracts syslog messages from a
+// TCP stream using either octet counting or non-transparent framing.
+func splitSyslogFrames(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if data[0] >= '0' && data[0] <= '9' {
+		i := bytes.IndexByte(data, ' ')
+		if i < 0 {
+			if atEOF {
+				return 0, nil, io.ErrUnexpectedEOF
+			}
+			return 0, nil, nil
+		}
+		msgLen, err := strconv.Atoi(string(data[:i]))
+		if err != nil || msgLen < 0 {
+			return 0, nil, fmt.Errorf("invalid octet count: %q", data[:i])
+		}
+		if end := i + 1 + msgLen; len(data) >= end {
+			return end, data[i+1 : end], nil
+		}
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, bytes.TrimSuffix(data[:i], []byte("\r")), nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func (s *SyslogServer) loop() {
+	var (
+		mCount     = s.stats.GetCounter("count")
+		mRcvd      = s.stats.GetCounter("batch.received")
+		mPartsRcvd = s.stats.GetCounter("received")
+		mLatency   = s.stats.GetTimer("latency")
+	)
+
+	defer func() {
+		atomic.StoreInt32(&s.running, 0)
+
+		if s.listener != nil {
+			s.listener.Close()
+		}
+		if s.conn != nil {
+			s.conn.Close()
+		}
+
+		close(s.transactions)
+		close(s.closedChan)
+	}()
+
+	s.log.Infof("Receiving syslog messages over %v from address: %v\n", s.conf.Network, s.Addr())
+
+	sendMsg := func(msg types.Message) error {
+		tStarted := time.Now()
+		mPartsRcvd.Incr(int64(msg.Len()))
+		mRcvd.Incr(1)
+
+		resChan := make(chan types.Response)
+		select {
+		case s.transactions <- types.NewTransaction(msg, resChan):
+		case <-s.closeChan:
+			return types.ErrTypeClosed
+		}
+
+		select {
+		case res, open := <-resChan:
+			if !open {
+				return types.ErrTypeClosed
+			}
+			if res != nil {
+				if res.Error() != nil {
+					return res.Error()
+				}
+			}
+		case <-s.closeChan:
+			return types.ErrTypeClosed
+		}
+		mLatency.Timing(time.Since(tStarted).Nanoseconds())
+		return nil
+	}
+
+	handle := func(raw []byte, remote net.Addr) {
+		mCount.Incr(1)
+		msg := s.newMessage(raw, remote)
+		for {
+			sendErr := sendMsg(msg)
+			if sendErr == nil || sendErr == types.ErrTypeClosed {
+				return
+			}
+			s.log.Errorf("Failed to send message: %v\n", sendErr)
+			<-time.After(time.Second)
+		}
+	}
+
+	if s.conn != nil {
+		go func() {
+			buf := make([]byte, s.conf.MaxBuffer)
+			for {
+				n, addr, err := s.conn.ReadFrom(buf)
+				if err != nil {
+					if !strings.Contains(err.Error(), "use of closed network connection") {
+						s.log.Errorf("Failed to read UDP datagram: %v\n", err)
+					}
+					return
+				}
+				if raw := bytes.TrimRight(buf[:n], "\r\n\x00"); len(raw) > 0 {
+					handle(raw, addr)
+				}
+			}
+		}()
+	} else {
+		go func() {
+			for {
+				conn, err := s.listener.Accept()
+				if err != nil {
+					if !strings.Contains(err.Error(), "use of closed network connection") {
+						s.log.Errorf("Failed to accept connection: %v\n", err)
+					}
+					return
+				}
+				go func(c net.Conn) {
+					defer c.Close()
+					scanner := bufio.NewScanner(c)
+					if s.conf.MaxBuffer != bufio.MaxScanTokenSize {
+						scanner.Buffer([]byte{}, s.conf.MaxBuffer)
+					}
+					scanner.Split(splitSyslogFrames)
+					for scanner.Scan() {
+						if len(scanner.Bytes()) == 0 {
+							continue
+						}
+						handle(scanner.Bytes(), c.RemoteAddr())
+					}
+					if cerr := scanner.Err(); cerr != nil {
+						if cerr != io.EOF {
+							s.log.Errorf("Connection error due to: %v\n", cerr)
+						}
+					}
+				}(conn)
+			}
+		}()
+	}
+	<-s.closeChan
+}
+
+// TransactionChan returns a transactions channel for consuming messages from
+// this input.
+func (s *SyslogServer) TransactionChan() <-chan types.Transaction {
+	return s.transactions
+}
+
+// Connected returns a boolean indicating whether this input is currently
+// connected to its target.
+func (s *SyslogServer) Connected() bool {
+	return true
+}
+
+// CloseAsync shuts down the SyslogServer input and stops processing requests.
+func (s *SyslogServer) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&s.running, 1, 0) {
+		close(s.closeChan)
+	}
+}
+
+// WaitForClose blocks until the SyslogServer input has closed down.
+func (s *SyslogServer) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-s.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func parseSyslogPriority(raw []byte) (map[string]interface{}, []byte, error) {
+	if len(raw) == 0 || raw[0] != '<' {
+		return nil, nil, errors.New("expected priority")
+	}
+	end := bytes.IndexByte(raw, '>')
+	if end < 2 || end > 4 {
+		return nil, nil, errors.New("malformed priority")
+	}
+	pri, err := strconv.Atoi(string(raw[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return nil, nil, fmt.Errorf("invalid priority: %q", raw[1:end])
+	}
+	return map[string]interface{}{
+		"priority": pri,
+		"facility": pri / 8,
+		"severity": pri % 8,
+	}, raw[end+1:], nil
+}
+
+// parseSyslogAuto parses a message as RFC5424 when the priority is followed by
+// a version number, and as RFC3164 otherwise.
+func parseSyslogAuto(raw []byte) (map[string]interface{}, error) {
+	if i := bytes.IndexByte(raw, '>'); i > 0 && len(raw) > i+2 &&
+		raw[i+1] >= '1' && raw[i+1] <= '9' && raw[i+2] == ' ' {
+		return parseRFC5424(raw)
+	}
+	return parseRFC3164(raw)
+}
+
+func nextSyslogToken(b []byte) (string, []byte, error) {
+	i := bytes.IndexByte(b, ' ')
+	if i < 0 {
+		return "", nil, errors.New("unexpected end of message")
+	}
+	if i == 0 {
+		return "", nil, errors.New("unexpected empty field")
+	}
+	return string(b[:i]), b[i+1:], nil
+}
+
+var syslogBOM = []byte("\xef\xbb\xbf")
+
+func parseRFC5424(raw []byte) (map[string]interface{}, error) {
+	fields, rest, err := parseSyslogPriority(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var tok string
+	if tok, rest, err = nextSyslogToken(rest); err != nil {
+		return nil, err
+	}
+	version, err := strconv.Atoi(tok)
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("invalid version: %q", tok)
+	}
+	fields["version"] = version
+
+	if tok, rest, err = nextSyslogToken(rest); err != nil {
+		return nil, err
+	}
+	if tok != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp: %v", err)
+		}
+		fields["timestamp"] = ts.Format(time.RFC3339Nano)
+	}
+
+	for _, k := range []string{"hostname", "app_name", "proc_id", "msg_id"} {
+		if tok, rest, err = nextSyslogToken(rest); err != nil {
+			return nil, err
+		}
+		if tok != "-" {
+			fields[k] = tok
+		}
+	}
+
+	switch {
+	case len(rest) == 0:
+		return nil, errors.New("missing structured data")
+	case rest[0] == '-':
+		rest = rest[1:]
+	case rest[0] == '[':
+		var sd map[string]interface{}
+		if sd, rest, err = parseSyslogStructuredData(rest); err != nil {
+			return nil, err
+		}
+		fields["structured_data"] = sd
+	default:
+		return nil, errors.New("malformed structured data")
+	}
+
+	if len(rest) > 0 {
+		if rest[0] != ' ' {
+			return nil, errors.New("malformed structured data")
+		}
+		fields["message"] = string(bytes.TrimPrefix(rest[1:], syslogBOM))
+	}
+	return fields, nil
+}
+
+func parseSyslogStructuredData(b []byte) (map[string]interface{}, []byte, error) {
+	sd := map[string]interface{}{}
+	for len(b) > 0 && b[0] == '[' {
+		b = b[1:]
+		i := bytes.IndexAny(b, " ]")
+		if i <= 0 {
+			return nil, nil, errors.New("malformed structured data element")
+		}
+		id := string(b[:i])
+		b = b[i:]
+
+		params := map[string]interface{}{}
+		for len(b) > 0 && b[0] == ' ' {
+			b = b[1:]
+			eq := bytes.IndexByte(b, '=')
+			if eq <= 0 || len(b) < eq+2 || b[eq+1] != '"' {
+				return nil, nil, fmt.Errorf("malformed structured data param in element %v", id)
+			}
+			name := string(b[:eq])
+			b = b[eq+2:]
+
+			var value []byte
+			closed := false
+			for j := 0; j < len(b); j++ {
+				if b[j] == '\\' && j+1 < len(b) && (b[j+1] == '"' || b[j+1] == '\\' || b[j+1] == ']') {
+					value = append(value, b[j+1])
+					j++
+					continue
+				}
+				if b[j] == '"' {
+					b = b[j+1:]
+					closed = true
+					break
+				}
+				value = append(value, b[j])
+			}
+			if !closed {
+				return nil, nil, fmt.Errorf("unterminated structured data param %v in element %v", name, id)
+			}
+			params[name] = string(value)
+		}
+		if len(b) == 0 || b[0] != ']' {
+			return nil, nil, fmt.Errorf("unterminated structured data element %v", id)
+		}
+		b = b[1:]
+		sd[id] = params
+	}
+	return sd, b, nil
+}
+
+func parseRFC3164Timestamp(b []byte) (time.Time, int, bool) {
+	if len(b) >= len(time.Stamp) {
+		if ts, err := time.ParseInLocation(time.Stamp, string(b[:len(time.Stamp)]), time.Local); err == nil {
+			now := time.Now()
+			ts = ts.AddDate(now.Year(), 0, 0)
+
+			// Timestamps from the future are most likely from the end of last
+			// year.
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			return ts, len(time.Stamp), true
+		}
+	}
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(b[:i])); err == nil {
+			return ts, i, true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+func parseRFC3164(raw []byte) (map[string]interface{}, error) {
+	fields, rest, err := parseSyslogPriority(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if ts, n, ok := parseRFC3164Timestamp(rest); ok {
+		fields["timestamp"] = ts.Format(time.RFC3339Nano)
+		rest = bytes.TrimLeft(rest[n:], " ")
+		if i := bytes.IndexByte(rest, ' '); i > 0 {
+			fields["hostname"] = string(rest[:i])
+			rest = rest[i+1:]
+		}
+	}
+
+	i := 0
+	for i < len(rest) && rest[i] > ' ' && rest[i] < 0x7f && rest[i] != '[' && rest[i] != ':' {
+		i++
+	}
+	if i > 0 && i < len(rest) && (rest[i] == '[' || rest[i] == ':') {
+		fields["app_name"] = string(rest[:i])
+		rest = rest[i:]
+		if rest[0] == '[' {
+			if j := bytes.IndexByte(rest, ']'); j > 0 {
+				fields["proc_id"] = string(rest[1:j])
+				rest = rest[j+1:]
+			}
+		}
+		rest = bytes.TrimPrefix(rest, []byte(":"))
+		rest = bytes.TrimPrefix(rest, []byte(" "))
+	}
+
+	fields["message"] = string(rest)
+	return fields, nil
+}
+
+//------------------------------------------------------------------------------
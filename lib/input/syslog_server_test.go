@@ -0,0 +1,424 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package input
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestSyslogParseRFC5424(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		output map[string]interface{}
+	}{
+		"rfc example with structured data": {
+			input: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] ` + "\xef\xbb\xbf" + `An application event log entry...`,
+			output: map[string]interface{}{
+				"priority":  165,
+				"facility":  20,
+				"severity":  5,
+				"version":   1,
+				"timestamp": "2003-10-11T22:14:15.003Z",
+				"hostname":  "mymachine.example.com",
+				"app_name":  "evntslog",
+				"msg_id":    "ID47",
+				"structured_data": map[string]interface{}{
+					"exampleSDID@32473": map[string]interface{}{
+						"iut":         "3",
+						"eventSource": "Application",
+						"eventID":     "1011",
+					},
+					"examplePriority@32473": map[string]interface{}{
+						"class": "high",
+					},
+				},
+				"message": "An application event log entry...",
+			},
+		},
+		"nil values and no message": {
+			input: `<34>1 - - - - - -`,
+			output: map[string]interface{}{
+				"priority": 34,
+				"facility": 4,
+				"severity": 2,
+				"version":  1,
+			},
+		},
+		"escaped param values": {
+			input: `<14>1 2020-01-02T03:04:05+01:00 host app 42 - [foo bar="a \"b\" \] c\\"] hello world`,
+			output: map[string]interface{}{
+				"priority":  14,
+				"facility":  1,
+				"severity":  6,
+				"version":   1,
+				"timestamp": "2020-01-02T03:04:05+01:00",
+				"hostname":  "host",
+				"app_name":  "app",
+				"proc_id":   "42",
+				"structured_data": map[string]interface{}{
+					"foo": map[string]interface{}{
+						"bar": `a "b" ] c\`,
+					},
+				},
+				"message": "hello world",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		act, err := parseRFC5424([]byte(test.input))
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.output, act) {
+			t.Errorf("%v: Wrong result: %v != %v", name, act, test.output)
+		}
+	}
+}
+
+func TestSyslogParseRFC5424Errors(t *testing.T) {
+	tests := map[string]string{
+		"no priority":        `1 - - - - - -`,
+		"bad priority":       `<900>1 - - - - - -`,
+		"bad version":        `<34>x - - - - - -`,
+		"bad timestamp":      `<34>1 yesterday - - - - -`,
+		"truncated":          `<34>1 - - -`,
+		"unterminated param": `<34>1 - - - - - [foo bar="baz]`,
+		"missing sd":         `<34>1 - - - - `,
+	}
+
+	for name, input := range tests {
+		if _, err := parseRFC5424([]byte(input)); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+func TestSyslogParseRFC3164(t *testing.T) {
+	now := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := map[string]struct {
+		input  string
+		output map[string]interface{}
+	}{
+		"full message": {
+			input: "<34>" + now.Format(time.Stamp) + " mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8",
+			output: map[string]interface{}{
+				"priority":  34,
+				"facility":  4,
+				"severity":  2,
+				"timestamp": now.Format(time.RFC3339Nano),
+				"hostname":  "mymachine",
+				"app_name":  "su",
+				"proc_id":   "123",
+				"message":   "'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		"rfc3339 timestamp no pid": {
+			input: "<13>2020-01-02T03:04:05Z host cron: job done",
+			output: map[string]interface{}{
+				"priority":  13,
+				"facility":  1,
+				"severity":  5,
+				"timestamp": "2020-01-02T03:04:05Z",
+				"hostname":  "host",
+				"app_name":  "cron",
+				"message":   "job done",
+			},
+		},
+		"no header": {
+			input: "<13>just some text",
+			output: map[string]interface{}{
+				"priority": 13,
+				"facility": 1,
+				"severity": 5,
+				"message":  "just some text",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		act, err := parseRFC3164([]byte(test.input))
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.output, act) {
+			t.Errorf("%v: Wrong result: %v != %v", name, act, test.output)
+		}
+	}
+}
+
+func TestSyslogSplitFrames(t *testing.T) {
+	data := []byte("11 <13>1 - - -\n<13>foo bar\r\n5 <13>a")
+	exp := []string{"<13>1 - - -", "<13>foo bar", "<13>a"}
+
+	var act []string
+	for len(data) > 0 {
+		adv, tok, err := splitSyslogFrames(data, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if adv == 0 {
+			t.Fatal("No progress")
+		}
+		if len(tok) > 0 {
+			act = append(act, string(tok))
+		}
+		data = data[adv:]
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong frames: %q != %q", act, exp)
+	}
+
+	if adv, _, err := splitSyslogFrames([]byte("20 <13>foo"), false); err != nil || adv != 0 {
+		t.Errorf("Expected request for more data: %v, %v", adv, err)
+	}
+	if _, _, err := splitSyslogFrames([]byte("20 <13>foo"), true); err == nil {
+		t.Error("Expected error on truncated frame")
+	}
+}
+
+func readSyslogMessage(t *testing.T, rdr Type) types.Message {
+	t.Helper()
+
+	var tr types.Transaction
+	select {
+	case tr = <-rdr.TransactionChan():
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for message")
+	}
+	select {
+	case tr.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out sending response")
+	}
+	return tr.Payload
+}
+
+func TestSyslogServerUDP(t *testing.T) {
+	conf := NewConfig()
+	conf.SyslogServer.Network = "udp"
+	conf.SyslogServer.Address = "127.0.0.1:0"
+
+	rdr, err := NewSyslogServer(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		rdr.CloseAsync()
+		if err := rdr.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := net.Dial("udp", rdr.(*SyslogServer).Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte("<165>1 2003-10-11T22:14:15.003Z host app - - - foo\n")); err != nil {
+		t.Fatal(err)
+	}
+	msg := readSyslogMessage(t, rdr)
+	if exp, act := `{"app_name":"app","facility":20,"hostname":"host","message":"foo","priority":165,"severity":5,"timestamp":"2003-10-11T22:14:15.003Z","version":1}`, string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	if act := msg.Get(0).Metadata().Get("syslog_remote_addr"); act != conn.LocalAddr().String() {
+		t.Errorf("Wrong remote addr: %v != %v", act, conn.LocalAddr())
+	}
+
+	if _, err = conn.Write([]byte("not syslog")); err != nil {
+		t.Fatal(err)
+	}
+	msg = readSyslogMessage(t, rdr)
+	if exp, act := "not syslog", string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	if !processor.HasFailed(msg.Get(0)) {
+		t.Error("Expected message to be flagged as failed")
+	}
+}
+
+func TestSyslogServerTCP(t *testing.T) {
+	conf := NewConfig()
+	conf.SyslogServer.Network = "tcp"
+	conf.SyslogServer.Address = "127.0.0.1:0"
+	conf.SyslogServer.Format = "rfc3164"
+
+	rdr, err := NewSyslogServer(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		rdr.CloseAsync()
+		if err := rdr.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", rdr.(*SyslogServer).Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		conn.Write([]byte("<13>foo: first\n16 <13>bar: sec\nond"))
+	}()
+
+	exp := []string{
+		`{"app_name":"foo","facility":1,"message":"first","priority":13,"severity":5}`,
+		`{"app_name":"bar","facility":1,"message":"sec\nond","priority":13,"severity":5}`,
+	}
+	for _, e := range exp {
+		msg := readSyslogMessage(t, rdr)
+		if act := string(msg.Get(0).Get()); e != act {
+			t.Errorf("Wrong message: %v != %v", act, e)
+		}
+	}
+}
+
+func createTestCertFiles(t *testing.T) (certFile, keyFile string, cleanup func()) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"Benthos Test"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "benthos_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSyslogServerTLS(t *testing.T) {
+	certFile, keyFile, cleanup := createTestCertFiles(t)
+	defer cleanup()
+
+	conf := NewConfig()
+	conf.SyslogServer.Network = "tls"
+	conf.SyslogServer.Address = "127.0.0.1:0"
+	conf.SyslogServer.CertFile = certFile
+	conf.SyslogServer.KeyFile = keyFile
+
+	rdr, err := NewSyslogServer(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		rdr.CloseAsync()
+		if err := rdr.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", rdr.(*SyslogServer).Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		conn.Write([]byte("<34>1 - host - - - [a b=\"c\"] secure\n"))
+	}()
+
+	msg := readSyslogMessage(t, rdr)
+	if exp, act := `{"facility":4,"hostname":"host","message":"secure","priority":34,"severity":2,"structured_data":{"a":{"b":"c"}},"version":1}`, string(msg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+}
+
+func TestSyslogServerBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.SyslogServer.Address = "127.0.0.1:0"
+
+	conf.SyslogServer.Network = "carrier_pigeon"
+	if _, err := NewSyslogServer(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad network")
+	}
+
+	conf.SyslogServer.Network = "tls"
+	if _, err := NewSyslogServer(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cert")
+	}
+
+	conf.SyslogServer.Network = "udp"
+	conf.SyslogServer.Format = "rfc9999"
+	if _, err := NewSyslogServer(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad format")
+	}
+}
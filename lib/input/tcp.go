@@ -46,7 +46,14 @@ Messages consumed by this input can be processed in parallel, meaning a single
 instance of this input can utilise any number of threads within a
 ` + "`pipeline`" + ` section of a config.
 
-If the delimiter field is left empty then line feed (\n) is used.`,
+If the delimiter field is left empty then line feed (\n) is used.
+
+The fields ` + "`multipart`, `max_buffer` and `delimiter`" + ` only apply to
+the default codec ` + "`lines`" + `. When a different ` + "`codec`" + ` is
+set each part it produces is read as a separate message, and once the
+connection is closed by the server the input reconnects.
+
+` + reader.CodecDocumentation,
 	}
 }
 
@@ -55,6 +62,7 @@ If the delimiter field is left empty then line feed (\n) is used.`,
 // TCPConfig contains configuration values for the TCP input type.
 type TCPConfig struct {
 	Address   string `json:"address" yaml:"address"`
+	Codec     string `json:"codec" yaml:"codec"`
 	Multipart bool   `json:"multipart" yaml:"multipart"`
 	MaxBuffer int    `json:"max_buffer" yaml:"max_buffer"`
 	Delim     string `json:"delimiter" yaml:"delimiter"`
@@ -64,6 +72,7 @@ type TCPConfig struct {
 func NewTCPConfig() TCPConfig {
 	return TCPConfig{
 		Address:   "localhost:4194",
+		Codec:     "lines",
 		Multipart: false,
 		MaxBuffer: 1000000,
 		Delim:     "",
@@ -79,26 +88,34 @@ func NewTCP(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type)
 		delim = "\n"
 	}
 	var conn net.Conn
-	rdr, err := reader.NewLines(
-		func() (io.Reader, error) {
-			if conn != nil {
-				conn.Close()
-				conn = nil
-			}
-			var err error
-			conn, err = net.Dial("tcp", conf.TCP.Address)
-			return conn, err
-		},
-		func() {
-			if conn != nil {
-				conn.Close()
-				conn = nil
-			}
-		},
-		reader.OptLinesSetDelimiter(delim),
-		reader.OptLinesSetMaxBuffer(conf.TCP.MaxBuffer),
-		reader.OptLinesSetMultipart(conf.TCP.Multipart),
-	)
+	connect := func() (io.Reader, error) {
+		if conn != nil {
+			conn.Close()
+			conn = nil
+		}
+		var err error
+		conn, err = net.Dial("tcp", conf.TCP.Address)
+		return conn, err
+	}
+	disconnect := func() {
+		if conn != nil {
+			conn.Close()
+			conn = nil
+		}
+	}
+
+	var rdr reader.Async
+	var err error
+	if len(conf.TCP.Codec) > 0 && conf.TCP.Codec != "lines" {
+		rdr, err = reader.NewCodecStream(conf.TCP.Codec, "", connect, disconnect)
+	} else {
+		rdr, err = reader.NewLines(
+			connect, disconnect,
+			reader.OptLinesSetDelimiter(delim),
+			reader.OptLinesSetMaxBuffer(conf.TCP.MaxBuffer),
+			reader.OptLinesSetMultipart(conf.TCP.Multipart),
+		)
+	}
 	if err != nil {
 		return nil, err
 	}
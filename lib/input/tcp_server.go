@@ -23,6 +23,7 @@ package input
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
 	"io"
 	"net"
 	"strings"
@@ -33,6 +34,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
 )
 
 //------------------------------------------------------------------------------
@@ -52,7 +54,9 @@ If the delimiter field is left empty then line feed (\n) is used.
 
 The field ` + "`max_buffer`" + ` specifies the maximum amount of memory to
 allocate _per connection_ for buffering lines of data. If a line of data from a
-connection exceeds this value then the connection will be closed.`,
+connection exceeds this value then the connection will be closed.
+
+` + btls.ServerDocumentation,
 	}
 }
 
@@ -60,10 +64,11 @@ connection exceeds this value then the connection will be closed.`,
 
 // TCPServerConfig contains configuration for the TCPServer input type.
 type TCPServerConfig struct {
-	Address   string `json:"address" yaml:"address"`
-	Multipart bool   `json:"multipart" yaml:"multipart"`
-	MaxBuffer int    `json:"max_buffer" yaml:"max_buffer"`
-	Delim     string `json:"delimiter" yaml:"delimiter"`
+	Address   string            `json:"address" yaml:"address"`
+	Multipart bool              `json:"multipart" yaml:"multipart"`
+	MaxBuffer int               `json:"max_buffer" yaml:"max_buffer"`
+	Delim     string            `json:"delimiter" yaml:"delimiter"`
+	TLS       btls.ServerConfig `json:"tls" yaml:"tls"`
 }
 
 // NewTCPServerConfig creates a new TCPServerConfig with default values.
@@ -73,6 +78,7 @@ func NewTCPServerConfig() TCPServerConfig {
 		Multipart: false,
 		MaxBuffer: 1000000,
 		Delim:     "",
+		TLS:       btls.NewServerConfig(),
 	}
 }
 
@@ -98,10 +104,20 @@ type TCPServer struct {
 
 // NewTCPServer creates a new TCPServer input type.
 func NewTCPServer(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	var tlsConf *tls.Config
+	if conf.TCPServer.TLS.Enabled {
+		var err error
+		if tlsConf, err = conf.TCPServer.TLS.Get(); err != nil {
+			return nil, err
+		}
+	}
 	ln, err := net.Listen("tcp", conf.TCPServer.Address)
 	if err != nil {
 		return nil, err
 	}
+	if tlsConf != nil {
+		ln = tls.NewListener(ln, tlsConf)
+	}
 	delim := []byte("\n")
 	if len(conf.TCPServer.Delim) > 0 {
 		delim = []byte(conf.TCPServer.Delim)
@@ -21,6 +21,7 @@
 package input
 
 import (
+	"crypto/tls"
 	"errors"
 	"net"
 	"reflect"
@@ -117,6 +118,144 @@ func TestTCPServerBasic(t *testing.T) {
 	conn.Close()
 }
 
+func TestTCPServerTLS(t *testing.T) {
+	certFile, keyFile, cleanup := createTestCertFiles(t)
+	defer cleanup()
+
+	conf := NewConfig()
+	conf.TCPServer.Address = "127.0.0.1:0"
+	conf.TCPServer.TLS.Enabled = true
+	conf.TCPServer.TLS.CertFile = certFile
+	conf.TCPServer.TLS.KeyFile = keyFile
+
+	rdr, err := NewTCPServer(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := rdr.(*TCPServer).Addr()
+
+	defer func() {
+		rdr.CloseAsync()
+		if err := rdr.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", addr.String(), &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		conn.SetWriteDeadline(time.Now().Add(time.Second * 5))
+		if _, cerr := conn.Write([]byte("foo\n")); cerr != nil {
+			t.Error(cerr)
+		}
+	}()
+
+	var tran types.Transaction
+	select {
+	case tran = <-rdr.TransactionChan():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+	if exp, act := [][]byte{[]byte("foo")}, message.GetAllBytes(tran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message contents: %s != %s", act, exp)
+	}
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+}
+
+func TestTCPServerMutualTLS(t *testing.T) {
+	certFile, keyFile, cleanup := createTestCertFiles(t)
+	defer cleanup()
+
+	conf := NewConfig()
+	conf.TCPServer.Address = "127.0.0.1:0"
+	conf.TCPServer.TLS.Enabled = true
+	conf.TCPServer.TLS.CertFile = certFile
+	conf.TCPServer.TLS.KeyFile = keyFile
+	conf.TCPServer.TLS.ClientAuth = "require_and_verify"
+	conf.TCPServer.TLS.ClientCAsFile = certFile
+
+	rdr, err := NewTCPServer(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := rdr.(*TCPServer).Addr()
+
+	defer func() {
+		rdr.CloseAsync()
+		if err := rdr.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// A client without a certificate must be rejected.
+	badConn, err := tls.Dial("tcp", addr.String(), &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err == nil {
+		badConn.SetDeadline(time.Now().Add(time.Second * 5))
+		badConn.Write([]byte("bad\n"))
+		if _, err = badConn.Read(make([]byte, 1)); err == nil {
+			t.Error("Expected connection without client certificate to fail")
+		}
+		badConn.Close()
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tls.Dial("tcp", addr.String(), &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{cert},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		conn.SetWriteDeadline(time.Now().Add(time.Second * 5))
+		if _, cerr := conn.Write([]byte("good\n")); cerr != nil {
+			t.Error(cerr)
+		}
+	}()
+
+	var tran types.Transaction
+	select {
+	case tran = <-rdr.TransactionChan():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+	if exp, act := [][]byte{[]byte("good")}, message.GetAllBytes(tran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong message contents: %s != %s", act, exp)
+	}
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out")
+	}
+}
+
+func TestTCPServerBadTLSConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.TCPServer.Address = "127.0.0.1:0"
+	conf.TCPServer.TLS.Enabled = true
+
+	if _, err := NewTCPServer(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cert")
+	}
+}
+
 func TestTCPServerReconnect(t *testing.T) {
 	conf := NewConfig()
 	conf.TCPServer.Address = "127.0.0.1:0"
@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package input
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeZMQ4N] = TypeSpec{
+		constructor: NewZMQ4N,
+		description: `
+Consumes messages from a ZeroMQ socket using a pure Go implementation of the
+ZeroMQ Message Transport Protocol, which unlike the ` + "`zmq4`" + ` input
+does not depend on C bindings and is therefore included in the default build.
+
+Version 3 of the protocol is supported with the NULL security mechanism, which
+is compatible with peers using libzmq 4.x. Endpoints may use the
+` + "`tcp`" + ` or ` + "`ipc`" + ` transports, e.g.
+` + "`tcp://localhost:5555`" + ` or ` + "`ipc:///tmp/benthos.sock`" + `.
+
+The ` + "`socket_type`" + ` can be either ` + "`PULL`" + ` or
+` + "`SUB`" + `. Connections to peers are established in the background and
+are reestablished when lost.
+
+The ` + "`high_water_mark`" + ` sets the maximum number of messages queued
+by the socket, where zero results in a default of 1000.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewZMQ4N creates a new ZMQ4N input type.
+func NewZMQ4N(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	z, err := reader.NewZMQ4N(conf.ZMQ4N, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(
+		TypeZMQ4N,
+		true,
+		reader.NewAsyncPreserver(z),
+		log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
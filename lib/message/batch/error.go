@@ -0,0 +1,103 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package batch
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+var errStopWalk = errors.New("walk stopped")
+
+// Error is an error that occurred when sending a message batch, which
+// optionally carries the errors of individual messages of the batch. When no
+// individual errors are recorded the error applies to the entire batch.
+type Error struct {
+	err        error
+	batch      types.Message
+	partErrors map[int]error
+}
+
+// NewError creates a batch error for a message batch with a general error.
+// Errors of individual messages can then be recorded with Failed.
+func NewError(msg types.Message, err error) *Error {
+	return &Error{
+		err:   err,
+		batch: msg,
+	}
+}
+
+// Failed records an error for the message of the batch at a given index.
+func (e *Error) Failed(i int, err error) *Error {
+	if e.partErrors == nil {
+		e.partErrors = map[int]error{}
+	}
+	e.partErrors[i] = err
+	return e
+}
+
+// IndexedErrors returns the number of messages of the batch with recorded
+// errors.
+func (e *Error) IndexedErrors() int {
+	return len(e.partErrors)
+}
+
+// Len returns the size of the batch the error was created for.
+func (e *Error) Len() int {
+	return e.batch.Len()
+}
+
+// WalkParts calls a closure for each message of the batch along with its
+// recorded error, which is nil for messages that were sent successfully. When
+// no individual errors were recorded the general error is provided for all
+// messages. Walking stops when the closure returns false.
+func (e *Error) WalkParts(fn func(int, types.Part, error) bool) {
+	e.batch.Iter(func(i int, p types.Part) error {
+		err := e.err
+		if e.partErrors != nil {
+			err = e.partErrors[i]
+		}
+		if !fn(i, p, err) {
+			return errStopWalk
+		}
+		return nil
+	})
+}
+
+// Unwrap returns the general error of the batch.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Error returns a description of the general error along with the number of
+// messages that failed.
+func (e *Error) Error() string {
+	if len(e.partErrors) == 0 {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%v: %v of %v messages failed", e.err, len(e.partErrors), e.batch.Len())
+}
+
+//------------------------------------------------------------------------------
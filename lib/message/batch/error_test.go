@@ -0,0 +1,98 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package batch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestErrorGeneral(t *testing.T) {
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	err := NewError(msg, errors.New("nope"))
+
+	if exp, act := "nope", err.Error(); exp != act {
+		t.Errorf("Wrong error message: %v != %v", act, exp)
+	}
+	if exp, act := 0, err.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of indexed errors: %v != %v", act, exp)
+	}
+	if exp, act := "nope", err.Unwrap().Error(); exp != act {
+		t.Errorf("Wrong unwrapped error: %v != %v", act, exp)
+	}
+
+	var walked int
+	err.WalkParts(func(i int, p types.Part, e error) bool {
+		walked++
+		if e == nil || e.Error() != "nope" {
+			t.Errorf("Wrong error for part %v: %v", i, e)
+		}
+		return true
+	})
+	if walked != 2 {
+		t.Errorf("Wrong count of walked parts: %v", walked)
+	}
+}
+
+func TestErrorIndexed(t *testing.T) {
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar"), []byte("baz")})
+	err := NewError(msg, errors.New("nope")).
+		Failed(0, errors.New("first")).
+		Failed(2, errors.New("third"))
+
+	if exp, act := "nope: 2 of 3 messages failed", err.Error(); exp != act {
+		t.Errorf("Wrong error message: %v != %v", act, exp)
+	}
+	if exp, act := 2, err.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of indexed errors: %v != %v", act, exp)
+	}
+
+	exp := []string{"first", "", "third"}
+	var act []string
+	err.WalkParts(func(i int, p types.Part, e error) bool {
+		if e == nil {
+			act = append(act, "")
+		} else {
+			act = append(act, e.Error())
+		}
+		return true
+	})
+	if len(act) != len(exp) {
+		t.Fatalf("Wrong walked errors: %v != %v", act, exp)
+	}
+	for i := range exp {
+		if act[i] != exp[i] {
+			t.Errorf("Wrong error for part %v: %v != %v", i, act[i], exp[i])
+		}
+	}
+
+	var walked int
+	err.WalkParts(func(i int, p types.Part, e error) bool {
+		walked++
+		return false
+	})
+	if walked != 1 {
+		t.Errorf("Expected walk to stop: %v", walked)
+	}
+}
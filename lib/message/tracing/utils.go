@@ -163,6 +163,56 @@ func InitSpansFromParent(operationName string, parent opentracing.SpanContext, m
 	msg.SetAll(tracedParts)
 }
 
+// metadataCarrier is an opentracing.TextMapReader over the metadata of a
+// message part.
+type metadataCarrier struct {
+	meta types.Metadata
+}
+
+func (m metadataCarrier) ForeachKey(handler func(key, val string) error) error {
+	return m.meta.Iter(handler)
+}
+
+// InitSpansFromParentMetadata sets up OpenTracing spans on each message part,
+// if one does not already exist, as children of a parent span extracted from
+// the metadata of the part. Metadata is extracted in the TextMap format of the
+// tracer, and parts where a parent span is not found are left untouched. This
+// allows spans to be propagated through the headers of protocols such as
+// Kafka.
+func InitSpansFromParentMetadata(operationName string, msg types.Message) {
+	tracedParts := make([]types.Part, msg.Len())
+	msg.Iter(func(i int, p types.Part) error {
+		tracedParts[i] = p
+		if GetSpan(p) != nil {
+			return nil
+		}
+		parent, err := opentracing.GlobalTracer().Extract(opentracing.TextMap, metadataCarrier{meta: p.Metadata()})
+		if err != nil {
+			return nil
+		}
+		span := opentracing.StartSpan(operationName, opentracing.ChildOf(parent))
+		ctx := opentracing.ContextWithSpan(message.GetContext(p), span)
+		tracedParts[i] = message.WithContext(ctx, p)
+		return nil
+	})
+	msg.SetAll(tracedParts)
+}
+
+// InjectSpanTextMap returns the span context of a message part in the TextMap
+// format of its tracer, which can be written as the headers of protocols such
+// as Kafka. Returns nil if the part does not have a span attached.
+func InjectSpanTextMap(p types.Part) opentracing.TextMapCarrier {
+	span := GetSpan(p)
+	if span == nil {
+		return nil
+	}
+	carrier := opentracing.TextMapCarrier{}
+	if err := span.Tracer().Inject(span.Context(), opentracing.TextMap, carrier); err != nil {
+		return nil
+	}
+	return carrier
+}
+
 // FinishSpans calls Finish on all message parts containing a span.
 func FinishSpans(msg types.Message) {
 	msg.Iter(func(i int, p types.Part) error {
@@ -0,0 +1,66 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracing
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestSpanMetadataPropagation(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	upstream := message.New([][]byte{[]byte("foo")})
+	InitSpans("upstream", upstream)
+
+	headers := InjectSpanTextMap(upstream.Get(0))
+	if len(headers) == 0 {
+		t.Fatal("Expected span headers")
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	for k, v := range headers {
+		msg.Get(0).Metadata().Set(k, v)
+	}
+	InitSpansFromParentMetadata("downstream", msg)
+
+	if GetSpan(msg.Get(1)) != nil {
+		t.Error("Expected part without span headers to be untouched")
+	}
+	span := GetSpan(msg.Get(0))
+	if span == nil {
+		t.Fatal("Expected span to be extracted")
+	}
+	exp := GetSpan(upstream.Get(0)).Context().(mocktracer.MockSpanContext)
+	act := span.(*mocktracer.MockSpan)
+	if exp.TraceID != act.SpanContext.TraceID || exp.SpanID != act.ParentID {
+		t.Errorf("Wrong span context: %+v != %+v", act.SpanContext, exp)
+	}
+
+	if InjectSpanTextMap(msg.Get(1)) != nil {
+		t.Error("Expected no headers from part without span")
+	}
+}
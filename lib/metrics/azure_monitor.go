@@ -0,0 +1,492 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeAzureMonitor] = TypeSpec{
+		constructor: NewAzureMonitor,
+		description: `
+Send custom metrics to [Azure Monitor](https://docs.microsoft.com/en-us/azure/azure-monitor/platform/metrics-custom-overview)
+for the Azure resource identified by ` + "`resource_id`" + `, which must be
+within the ` + "`region`" + ` configured.
+
+By default requests are authenticated using a managed identity, which is
+available when Benthos runs within Azure services such as AKS, virtual machines
+and Functions. The ` + "`client_id`" + ` field can be used to select a user
+assigned identity. Alternatively, setting ` + "`client_secret`" + ` along with
+` + "`tenant_id`" + ` and ` + "`client_id`" + ` authenticates as a service
+principal instead. In either case the identity requires the Monitoring Metrics
+Publisher role for the resource.
+
+Metrics are aggregated and sent each ` + "`flush_period`" + `, where Azure
+Monitor aggregates custom metrics at a granularity of one minute. Counters are
+sent as the change in value since the previous flush, gauges are sent with their
+current value and timers are sent as the count, sum, minimum and maximum of the
+timings (in milliseconds) recorded during the flush period.
+
+Metric labels, along with the static ` + "`dimensions`" + `, are sent as the
+dimensions of each metric. Azure Monitor supports at most 10 dimensions per
+metric and any beyond this limit are dropped.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// AzureMonitorConfig is config for the Azure Monitor metrics type.
+type AzureMonitorConfig struct {
+	Region       string            `json:"region" yaml:"region"`
+	ResourceID   string            `json:"resource_id" yaml:"resource_id"`
+	Namespace    string            `json:"namespace" yaml:"namespace"`
+	Dimensions   map[string]string `json:"dimensions" yaml:"dimensions"`
+	TenantID     string            `json:"tenant_id" yaml:"tenant_id"`
+	ClientID     string            `json:"client_id" yaml:"client_id"`
+	ClientSecret string            `json:"client_secret" yaml:"client_secret"`
+	FlushPeriod  string            `json:"flush_period" yaml:"flush_period"`
+	Timeout      string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewAzureMonitorConfig creates an AzureMonitorConfig struct with default
+// values.
+func NewAzureMonitorConfig() AzureMonitorConfig {
+	return AzureMonitorConfig{
+		Region:       "",
+		ResourceID:   "",
+		Namespace:    "Benthos",
+		Dimensions:   map[string]string{},
+		TenantID:     "",
+		ClientID:     "",
+		ClientSecret: "",
+		FlushPeriod:  "60s",
+		Timeout:      "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	azureMonitorMaxDimensions = 10
+	azureMonitorResource      = "https://monitoring.azure.com/"
+	azureLoginURL             = "https://login.microsoftonline.com"
+	azureIMDSTokenURL         = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// azureMonitorSeries is a single combination of dimension values of a custom
+// metric.
+type azureMonitorSeries struct {
+	DimValues []string `json:"dimValues,omitempty"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     int64    `json:"count"`
+}
+
+// azureMonitorBaseData is a custom metric with each series of its dimension
+// values.
+type azureMonitorBaseData struct {
+	Metric    string               `json:"metric"`
+	Namespace string               `json:"namespace"`
+	DimNames  []string             `json:"dimNames,omitempty"`
+	Series    []azureMonitorSeries `json:"series"`
+}
+
+// azureMonitorRequest is the body of a request sending a custom metric.
+type azureMonitorRequest struct {
+	Time string `json:"time"`
+	Data struct {
+		BaseData azureMonitorBaseData `json:"baseData"`
+	} `json:"data"`
+}
+
+// azureToken is the response of a request for an access token, where the
+// expiry fields are strings or numbers depending on the endpoint.
+type azureToken struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   interface{} `json:"expires_in"`
+	ExpiresOn   interface{} `json:"expires_on"`
+}
+
+func azureSeconds(v interface{}) int64 {
+	switch t := v.(type) {
+	case float64:
+		return int64(t)
+	case string:
+		i, _ := strconv.ParseInt(t, 10, 64)
+		return i
+	}
+	return 0
+}
+
+// expiry returns the time at which a token expires, or five minutes from now if
+// the expiry isn't known.
+func (a azureToken) expiry(now time.Time) time.Time {
+	if in := azureSeconds(a.ExpiresIn); in > 0 {
+		return now.Add(time.Duration(in) * time.Second)
+	}
+	if on := azureSeconds(a.ExpiresOn); on > 0 {
+		return time.Unix(on, 0)
+	}
+	return now.Add(time.Minute * 5)
+}
+
+//------------------------------------------------------------------------------
+
+// AzureMonitor is a stats object that periodically sends custom metrics to
+// Azure Monitor.
+type AzureMonitor struct {
+	*snapshotStore
+
+	config    AzureMonitorConfig
+	metricURL string
+	loginURL  string
+	imdsURL   string
+	client    *http.Client
+	log       log.Modular
+	closeOnce sync.Once
+
+	token       string
+	tokenExpiry time.Time
+
+	closedChan chan struct{}
+	doneChan   chan struct{}
+	flushChan  chan chan struct{}
+}
+
+// NewAzureMonitor creates and returns a new AzureMonitor object.
+func NewAzureMonitor(config Config, opts ...func(Type)) (Type, error) {
+	a, err := newAzureMonitor(config.AzureMonitor, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func newAzureMonitor(conf AzureMonitorConfig, opts ...func(Type)) (*AzureMonitor, error) {
+	if len(conf.Region) == 0 {
+		return nil, errors.New("a region must be specified")
+	}
+	if len(conf.ResourceID) == 0 {
+		return nil, errors.New("a resource_id must be specified")
+	}
+	if len(conf.ClientSecret) > 0 && (len(conf.TenantID) == 0 || len(conf.ClientID) == 0) {
+		return nil, errors.New("a tenant_id and client_id must be specified with a client_secret")
+	}
+	flushPeriod, err := time.ParseDuration(conf.FlushPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush period: %v", err)
+	}
+	if flushPeriod <= 0 {
+		return nil, fmt.Errorf("flush period must be greater than zero: %v", conf.FlushPeriod)
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	a := &AzureMonitor{
+		snapshotStore: newSnapshotStore(),
+		config:        conf,
+		metricURL: fmt.Sprintf(
+			"https://%v.monitoring.azure.com/%v/metrics",
+			conf.Region, strings.Trim(conf.ResourceID, "/"),
+		),
+		loginURL:   azureLoginURL,
+		imdsURL:    azureIMDSTokenURL,
+		client:     &http.Client{Timeout: timeout},
+		log:        log.Noop(),
+		closedChan: make(chan struct{}),
+		doneChan:   make(chan struct{}),
+		flushChan:  make(chan chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	go a.loop(flushPeriod)
+	return a, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (a *AzureMonitor) loop(flushPeriod time.Duration) {
+	defer close(a.doneChan)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.flush()
+		case done := <-a.flushChan:
+			a.flush()
+			close(done)
+		case <-a.closedChan:
+			a.flush()
+			return
+		}
+	}
+}
+
+// requestToken obtains a new access token, either as a service principal or
+// a managed identity.
+func (a *AzureMonitor) requestToken() (azureToken, error) {
+	var req *http.Request
+	var err error
+	if len(a.config.ClientSecret) > 0 {
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		form.Set("client_id", a.config.ClientID)
+		form.Set("client_secret", a.config.ClientSecret)
+		form.Set("resource", azureMonitorResource)
+		if req, err = http.NewRequest(
+			"POST", a.loginURL+"/"+url.PathEscape(a.config.TenantID)+"/oauth2/token",
+			strings.NewReader(form.Encode()),
+		); err != nil {
+			return azureToken{}, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := url.Values{}
+		query.Set("resource", azureMonitorResource)
+		if len(a.config.ClientID) > 0 {
+			query.Set("client_id", a.config.ClientID)
+		}
+		if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); len(endpoint) > 0 && len(header) > 0 {
+			// App Service and Functions expose managed identities through
+			// their own endpoint.
+			query.Set("api-version", "2019-08-01")
+			if req, err = http.NewRequest("GET", endpoint+"?"+query.Encode(), nil); err != nil {
+				return azureToken{}, err
+			}
+			req.Header.Set("X-IDENTITY-HEADER", header)
+		} else {
+			query.Set("api-version", "2018-02-01")
+			if req, err = http.NewRequest("GET", a.imdsURL+"?"+query.Encode(), nil); err != nil {
+				return azureToken{}, err
+			}
+			req.Header.Set("Metadata", "true")
+		}
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return azureToken{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return azureToken{}, fmt.Errorf("unexpected status code %v: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var token azureToken
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return azureToken{}, fmt.Errorf("failed to parse token: %v", err)
+	}
+	if len(token.AccessToken) == 0 {
+		return azureToken{}, errors.New("received an empty access token")
+	}
+	return token, nil
+}
+
+// accessToken returns a cached access token, obtaining a new one when it is
+// close to expiring.
+func (a *AzureMonitor) accessToken() (string, error) {
+	now := time.Now()
+	if len(a.token) > 0 && now.Add(time.Minute).Before(a.tokenExpiry) {
+		return a.token, nil
+	}
+	token, err := a.requestToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain access token: %v", err)
+	}
+	a.token, a.tokenExpiry = token.AccessToken, token.expiry(now)
+	return a.token, nil
+}
+
+// dimensions returns the sorted names and values of the dimensions of a
+// metric.
+func (a *AzureMonitor) dimensions(m metricSnapshot) ([]string, []string) {
+	labels := m.Labels()
+	for k, v := range a.config.Dimensions {
+		if _, exists := labels[k]; !exists {
+			labels[k] = v
+		}
+	}
+
+	var names, values []string
+	for _, k := range sortedKeys(labels) {
+		if len(labels[k]) == 0 {
+			continue
+		}
+		if len(names) == azureMonitorMaxDimensions {
+			a.log.Warnf("Dropping dimensions of metric '%v' beyond the limit of %v\n", m.Path, azureMonitorMaxDimensions)
+			break
+		}
+		names = append(names, k)
+		values = append(values, labels[k])
+	}
+	return names, values
+}
+
+// requests returns a snapshot of all metrics as requests, one for each metric
+// and set of dimension names.
+func (a *AzureMonitor) requests(t time.Time) []azureMonitorRequest {
+	counters, gauges, timers := a.snapshot()
+
+	var reqs []*azureMonitorRequest
+	byKey := map[string]*azureMonitorRequest{}
+	add := func(m metricSnapshot, series azureMonitorSeries) {
+		names, values := a.dimensions(m)
+		series.DimValues = values
+
+		key := m.Path + "\x00" + strings.Join(names, "\x00")
+		req, exists := byKey[key]
+		if !exists {
+			req = &azureMonitorRequest{Time: t.UTC().Format(time.RFC3339)}
+			req.Data.BaseData = azureMonitorBaseData{
+				Metric:    m.Path,
+				Namespace: a.config.Namespace,
+				DimNames:  names,
+			}
+			byKey[key] = req
+			reqs = append(reqs, req)
+		}
+		req.Data.BaseData.Series = append(req.Data.BaseData.Series, series)
+	}
+
+	for _, m := range counters {
+		if m.Delta == 0 {
+			continue
+		}
+		v := float64(m.Delta)
+		add(m, azureMonitorSeries{Min: v, Max: v, Sum: v, Count: 1})
+	}
+	for _, m := range gauges {
+		v := float64(m.Value)
+		add(m, azureMonitorSeries{Min: v, Max: v, Sum: v, Count: 1})
+	}
+	for _, m := range timers {
+		if m.Timing.Count == 0 {
+			continue
+		}
+		add(m, azureMonitorSeries{
+			Min:   float64(m.Timing.Min) / float64(time.Millisecond),
+			Max:   float64(m.Timing.Max) / float64(time.Millisecond),
+			Sum:   float64(m.Timing.Sum) / float64(time.Millisecond),
+			Count: m.Timing.Count,
+		})
+	}
+
+	res := make([]azureMonitorRequest, len(reqs))
+	for i, r := range reqs {
+		res[i] = *r
+	}
+	return res
+}
+
+func (a *AzureMonitor) flush() {
+	reqs := a.requests(time.Now())
+	if len(reqs) == 0 {
+		return
+	}
+	token, err := a.accessToken()
+	if err != nil {
+		a.log.Errorf("Failed to send metrics: %v\n", err)
+		return
+	}
+	for _, r := range reqs {
+		if err := a.send(token, r); err != nil {
+			a.log.Errorf("Failed to send metric '%v': %v\n", r.Data.BaseData.Metric, err)
+		}
+	}
+}
+
+func (a *AzureMonitor) send(token string, r azureMonitorRequest) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", a.metricURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status code %v: %s", res.StatusCode, bytes.TrimSpace(resBody))
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// flushNow sends metrics immediately.
+func (a *AzureMonitor) flushNow() bool {
+	requestFlush(a.flushChan, a.doneChan)
+	return true
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (a *AzureMonitor) SetLogger(log log.Modular) {
+	a.log = log
+}
+
+// Close stops the AzureMonitor object from aggregating metrics and sends the
+// remaining metrics.
+func (a *AzureMonitor) Close() error {
+	a.closeOnce.Do(func() {
+		close(a.closedChan)
+		<-a.doneChan
+	})
+	return nil
+}
+
+//------------------------------------------------------------------------------
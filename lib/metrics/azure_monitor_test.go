@@ -0,0 +1,271 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAzureMonitorInterface(t *testing.T) {
+	o := &AzureMonitor{}
+	if Type(o) == nil {
+		t.Errorf("AzureMonitor does not satisfy Type interface")
+	}
+}
+
+func TestAzureMonitorBadConfig(t *testing.T) {
+	for name, fn := range map[string]func(c *AzureMonitorConfig){
+		"no region":        func(c *AzureMonitorConfig) { c.Region = "" },
+		"no resource id":   func(c *AzureMonitorConfig) { c.ResourceID = "" },
+		"secret no tenant": func(c *AzureMonitorConfig) { c.ClientSecret = "baz" },
+		"bad flush period": func(c *AzureMonitorConfig) { c.FlushPeriod = "nope" },
+		"bad timeout":      func(c *AzureMonitorConfig) { c.Timeout = "nope" },
+	} {
+		conf := NewConfig()
+		conf.Type = TypeAzureMonitor
+		conf.AzureMonitor.Region = "westeurope"
+		conf.AzureMonitor.ResourceID = "/subscriptions/foo"
+		fn(&conf.AzureMonitor)
+		if _, err := New(conf); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestAzureMonitorRequests(t *testing.T) {
+	conf := NewAzureMonitorConfig()
+	conf.Region = "westeurope"
+	conf.ResourceID = "/subscriptions/foo/"
+	conf.Dimensions = map[string]string{"host": "bar"}
+	conf.FlushPeriod = "1h"
+
+	a, err := newAzureMonitor(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if exp, act := "https://westeurope.monitoring.azure.com/subscriptions/foo/metrics", a.metricURL; exp != act {
+		t.Errorf("Wrong metric URL: %v != %v", act, exp)
+	}
+
+	a.GetCounter("a.counter").Incr(3)
+	a.GetCounter("a.unchanged")
+	gauge := a.GetGaugeVec("a.gauge", []string{"label"})
+	gauge.With("baz").Set(10)
+	gauge.With("qux").Set(20)
+	timer := a.GetTimer("a.timer")
+	for _, v := range []int64{1, 2, 3} {
+		timer.Timing(v * int64(time.Millisecond))
+	}
+	a.GetTimer("a.empty")
+
+	reqs := a.requests(time.Unix(60, 0))
+	exp := []azureMonitorBaseData{
+		{
+			Metric:    "a.counter",
+			Namespace: "Benthos",
+			DimNames:  []string{"host"},
+			Series: []azureMonitorSeries{
+				{DimValues: []string{"bar"}, Min: 3, Max: 3, Sum: 3, Count: 1},
+			},
+		},
+		{
+			Metric:    "a.gauge",
+			Namespace: "Benthos",
+			DimNames:  []string{"host", "label"},
+			Series: []azureMonitorSeries{
+				{DimValues: []string{"bar", "baz"}, Min: 10, Max: 10, Sum: 10, Count: 1},
+				{DimValues: []string{"bar", "qux"}, Min: 20, Max: 20, Sum: 20, Count: 1},
+			},
+		},
+		{
+			Metric:    "a.timer",
+			Namespace: "Benthos",
+			DimNames:  []string{"host"},
+			Series: []azureMonitorSeries{
+				{DimValues: []string{"bar"}, Min: 1, Max: 3, Sum: 6, Count: 3},
+			},
+		},
+	}
+	var act []azureMonitorBaseData
+	for _, r := range reqs {
+		if exp, act := "1970-01-01T00:01:00Z", r.Time; exp != act {
+			t.Errorf("Wrong time: %v != %v", act, exp)
+		}
+		act = append(act, r.Data.BaseData)
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong requests: %+v != %+v", act, exp)
+	}
+
+	// Counters are sent as deltas and timings are reset each flush.
+	reqs = a.requests(time.Unix(120, 0))
+	if exp, act := 1, len(reqs); exp != act {
+		t.Fatalf("Wrong count of requests: %v != %v", act, exp)
+	}
+	if exp, act := "a.gauge", reqs[0].Data.BaseData.Metric; exp != act {
+		t.Errorf("Wrong metric: %v != %v", act, exp)
+	}
+}
+
+func TestAzureMonitorMaxDimensions(t *testing.T) {
+	conf := NewAzureMonitorConfig()
+	conf.Region = "westeurope"
+	conf.ResourceID = "/subscriptions/foo"
+	conf.FlushPeriod = "1h"
+
+	a, err := newAzureMonitor(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	labels := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}
+	a.GetGaugeVec("a.gauge", labels).With(labels...).Set(1)
+
+	reqs := a.requests(time.Now())
+	if exp, act := 1, len(reqs); exp != act {
+		t.Fatalf("Wrong count of requests: %v != %v", act, exp)
+	}
+	if exp, act := labels[:10], reqs[0].Data.BaseData.DimNames; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong dimensions: %v != %v", act, exp)
+	}
+}
+
+func TestAzureMonitorHTTP(t *testing.T) {
+	var reqMut sync.Mutex
+	var tokenReqs int
+	var metrics []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqMut.Lock()
+		defer reqMut.Unlock()
+
+		switch r.URL.Path {
+		case "/tenant/oauth2/token":
+			tokenReqs++
+			if err := r.ParseForm(); err != nil {
+				t.Error(err)
+			}
+			if exp, act := "client_credentials", r.Form.Get("grant_type"); exp != act {
+				t.Errorf("Wrong grant type: %v != %v", act, exp)
+			}
+			if exp, act := "secret", r.Form.Get("client_secret"); exp != act {
+				t.Errorf("Wrong client secret: %v != %v", act, exp)
+			}
+			w.Write([]byte(`{"access_token":"footoken","expires_in":"3600"}`))
+		case "/metrics":
+			if exp, act := "Bearer footoken", r.Header.Get("Authorization"); exp != act {
+				t.Errorf("Wrong authorization header: %v != %v", act, exp)
+			}
+			var req azureMonitorRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Error(err)
+			}
+			metrics = append(metrics, req.Data.BaseData.Metric)
+		default:
+			t.Errorf("Unexpected path: %v", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	conf := NewAzureMonitorConfig()
+	conf.Region = "westeurope"
+	conf.ResourceID = "/subscriptions/foo"
+	conf.TenantID = "tenant"
+	conf.ClientID = "client"
+	conf.ClientSecret = "secret"
+	conf.FlushPeriod = "1h"
+
+	a, err := newAzureMonitor(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.metricURL = server.URL + "/metrics"
+	a.loginURL = server.URL
+
+	a.GetCounter("a.counter").Incr(1)
+	a.GetGauge("a.gauge").Set(1)
+	a.flush()
+
+	a.GetCounter("a.counter").Incr(1)
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+
+	if exp, act := 1, tokenReqs; exp != act {
+		t.Errorf("Wrong count of token requests: %v != %v", act, exp)
+	}
+	if exp, act := []string{"a.counter", "a.gauge", "a.counter", "a.gauge"}, metrics; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong metrics: %v != %v", act, exp)
+	}
+}
+
+func TestAzureMonitorManagedIdentity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "true", r.Header.Get("Metadata"); exp != act {
+			t.Errorf("Wrong metadata header: %v != %v", act, exp)
+		}
+		if exp, act := "client", r.URL.Query().Get("client_id"); exp != act {
+			t.Errorf("Wrong client id: %v != %v", act, exp)
+		}
+		if exp, act := azureMonitorResource, r.URL.Query().Get("resource"); exp != act {
+			t.Errorf("Wrong resource: %v != %v", act, exp)
+		}
+		w.Write([]byte(`{"access_token":"footoken","expires_on":"4000000000"}`))
+	}))
+	defer server.Close()
+
+	conf := NewAzureMonitorConfig()
+	conf.Region = "westeurope"
+	conf.ResourceID = "/subscriptions/foo"
+	conf.ClientID = "client"
+	conf.FlushPeriod = "1h"
+
+	a, err := newAzureMonitor(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	a.imdsURL = server.URL
+
+	token, err := a.accessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "footoken", token; exp != act {
+		t.Errorf("Wrong token: %v != %v", act, exp)
+	}
+	if exp, act := time.Unix(4000000000, 0), a.tokenExpiry; !exp.Equal(act) {
+		t.Errorf("Wrong expiry: %v != %v", act, exp)
+	}
+}
@@ -292,4 +292,21 @@ func (h *Blacklist) HandlerFunc() http.HandlerFunc {
 	}
 }
 
+// jsonHandlerFunc returns the grouped JSON metrics handler of the child type,
+// if it has one.
+func (h *Blacklist) jsonHandlerFunc() (http.HandlerFunc, bool) {
+	return JSONHandlerFunc(h.s)
+}
+
+// flushNow flushes the metrics of the child type, if it pushes metrics.
+func (h *Blacklist) flushNow() bool {
+	return Flush(h.s)
+}
+
+// resetNow resets the metrics of the child type, if it aggregates metrics
+// locally.
+func (h *Blacklist) resetNow() bool {
+	return Reset(h.s)
+}
+
 //------------------------------------------------------------------------------
@@ -0,0 +1,281 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	sess "github.com/Jeffail/benthos/v3/lib/util/aws/session"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeCloudWatch] = TypeSpec{
+		constructor: NewCloudWatch,
+		description: `
+Send metrics to AWS CloudWatch using the PutMetricData endpoint.
+
+Metrics are aggregated and sent to CloudWatch in batches of at most 20 each
+` + "`flush_period`" + `. Counters are sent as the change in value since the
+previous flush, gauges are sent with their current value and timers are sent as
+a statistic set of the timings (in microseconds) recorded during the flush
+period.
+
+Metric labels, along with the static ` + "`dimensions`" + `, are sent as the
+dimensions of each metric. In order to derive dimensions from the segments of a
+metric path use the ` + "`mapping`" + ` field with ` + "`to_label`" + ` rules.
+CloudWatch supports at most 10 dimensions per metric and any beyond this limit
+are dropped.
+
+The ` + "`storage_resolution`" + ` field sets the resolution of the metrics
+in seconds, where 1 enables high resolution metrics and 60 is standard
+resolution.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// CloudWatchConfig contains config fields for the CloudWatch metrics type.
+type CloudWatchConfig struct {
+	sess.Config       `json:",inline" yaml:",inline"`
+	Namespace         string            `json:"namespace" yaml:"namespace"`
+	Dimensions        map[string]string `json:"dimensions" yaml:"dimensions"`
+	StorageResolution int64             `json:"storage_resolution" yaml:"storage_resolution"`
+	FlushPeriod       string            `json:"flush_period" yaml:"flush_period"`
+}
+
+// NewCloudWatchConfig creates an CloudWatchConfig struct with default values.
+func NewCloudWatchConfig() CloudWatchConfig {
+	return CloudWatchConfig{
+		Config:            sess.NewConfig(),
+		Namespace:         "Benthos",
+		Dimensions:        map[string]string{},
+		StorageResolution: 60,
+		FlushPeriod:       "100ms",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	cloudWatchMaxDatumsPerCall      = 20
+	cloudWatchMaxDimensionsPerDatum = 10
+)
+
+// CloudWatch is a stats object with capability to send metrics to AWS
+// CloudWatch.
+type CloudWatch struct {
+	*snapshotStore
+
+	config    CloudWatchConfig
+	client    cloudwatchiface.CloudWatchAPI
+	log       log.Modular
+	closeOnce sync.Once
+
+	closedChan chan struct{}
+	doneChan   chan struct{}
+	flushChan  chan chan struct{}
+}
+
+// NewCloudWatch creates and returns a new CloudWatch object.
+func NewCloudWatch(config Config, opts ...func(Type)) (Type, error) {
+	session, err := config.CloudWatch.GetSession()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCloudWatch(config.CloudWatch, cloudwatch.New(session), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newCloudWatch(conf CloudWatchConfig, client cloudwatchiface.CloudWatchAPI, opts ...func(Type)) (*CloudWatch, error) {
+	flushPeriod, err := time.ParseDuration(conf.FlushPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush period: %v", err)
+	}
+	if flushPeriod <= 0 {
+		return nil, fmt.Errorf("flush period must be greater than zero: %v", conf.FlushPeriod)
+	}
+	if conf.StorageResolution != 1 && conf.StorageResolution != 60 {
+		return nil, fmt.Errorf("storage resolution must be either 1 or 60: %v", conf.StorageResolution)
+	}
+	if len(conf.Namespace) == 0 {
+		return nil, fmt.Errorf("a namespace must be specified")
+	}
+
+	c := &CloudWatch{
+		snapshotStore: newSnapshotStore(),
+		config:        conf,
+		client:        client,
+		log:           log.Noop(),
+		closedChan:    make(chan struct{}),
+		doneChan:      make(chan struct{}),
+		flushChan:     make(chan chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	go c.loop(flushPeriod)
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (c *CloudWatch) loop(flushPeriod time.Duration) {
+	defer close(c.doneChan)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case done := <-c.flushChan:
+			c.flush()
+			close(done)
+		case <-c.closedChan:
+			c.flush()
+			return
+		}
+	}
+}
+
+func (c *CloudWatch) dimensions(m metricSnapshot) []*cloudwatch.Dimension {
+	labels := m.Labels()
+	for k, v := range c.config.Dimensions {
+		if _, exists := labels[k]; !exists {
+			labels[k] = v
+		}
+	}
+
+	dims := make([]*cloudwatch.Dimension, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		if len(labels[k]) == 0 {
+			continue
+		}
+		if len(dims) == cloudWatchMaxDimensionsPerDatum {
+			c.log.Warnf("Dropping dimensions of metric '%v' beyond the limit of %v\n", m.Path, cloudWatchMaxDimensionsPerDatum)
+			break
+		}
+		dims = append(dims, &cloudwatch.Dimension{
+			Name:  aws.String(k),
+			Value: aws.String(labels[k]),
+		})
+	}
+	return dims
+}
+
+// datums returns a snapshot of all metrics as CloudWatch metric datums.
+func (c *CloudWatch) datums(t time.Time) []*cloudwatch.MetricDatum {
+	counters, gauges, timers := c.snapshot()
+
+	var datums []*cloudwatch.MetricDatum
+	newDatum := func(m metricSnapshot, unit string) *cloudwatch.MetricDatum {
+		return &cloudwatch.MetricDatum{
+			MetricName:        aws.String(m.Path),
+			Dimensions:        c.dimensions(m),
+			StorageResolution: aws.Int64(c.config.StorageResolution),
+			Timestamp:         aws.Time(t),
+			Unit:              aws.String(unit),
+		}
+	}
+	for _, m := range counters {
+		if m.Delta == 0 {
+			continue
+		}
+		d := newDatum(m, cloudwatch.StandardUnitCount)
+		d.Value = aws.Float64(float64(m.Delta))
+		datums = append(datums, d)
+	}
+	for _, m := range gauges {
+		d := newDatum(m, cloudwatch.StandardUnitNone)
+		d.Value = aws.Float64(float64(m.Value))
+		datums = append(datums, d)
+	}
+	for _, m := range timers {
+		if m.Timing.Count == 0 {
+			continue
+		}
+		d := newDatum(m, cloudwatch.StandardUnitMicroseconds)
+		d.StatisticValues = &cloudwatch.StatisticSet{
+			SampleCount: aws.Float64(float64(m.Timing.Count)),
+			Sum:         aws.Float64(float64(m.Timing.Sum) / 1000),
+			Minimum:     aws.Float64(float64(m.Timing.Min) / 1000),
+			Maximum:     aws.Float64(float64(m.Timing.Max) / 1000),
+		}
+		datums = append(datums, d)
+	}
+	return datums
+}
+
+func (c *CloudWatch) flush() {
+	datums := c.datums(time.Now())
+	for len(datums) > 0 {
+		batch := datums
+		if len(batch) > cloudWatchMaxDatumsPerCall {
+			batch = batch[:cloudWatchMaxDatumsPerCall]
+		}
+		datums = datums[len(batch):]
+
+		if _, err := c.client.PutMetricData(&cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(c.config.Namespace),
+			MetricData: batch,
+		}); err != nil {
+			c.log.Errorf("Failed to send metrics: %v\n", err)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// flushNow sends metrics immediately.
+func (c *CloudWatch) flushNow() bool {
+	requestFlush(c.flushChan, c.doneChan)
+	return true
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (c *CloudWatch) SetLogger(log log.Modular) {
+	c.log = log
+}
+
+// Close stops the CloudWatch object from aggregating metrics and sends the
+// remaining metrics.
+func (c *CloudWatch) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closedChan)
+		<-c.doneChan
+	})
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,155 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+type mockCloudWatchClient struct {
+	cloudwatchiface.CloudWatchAPI
+
+	inputs []*cloudwatch.PutMetricDataInput
+	sync.Mutex
+}
+
+func (m *mockCloudWatchClient) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	m.Lock()
+	m.inputs = append(m.inputs, input)
+	m.Unlock()
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestCloudWatchInterface(t *testing.T) {
+	o := &CloudWatch{}
+	if Type(o) == nil {
+		t.Errorf("CloudWatch does not satisfy Type interface")
+	}
+}
+
+func TestCloudWatchBadConfig(t *testing.T) {
+	for name, fn := range map[string]func(c *CloudWatchConfig){
+		"bad flush period":       func(c *CloudWatchConfig) { c.FlushPeriod = "nope" },
+		"bad storage resolution": func(c *CloudWatchConfig) { c.StorageResolution = 10 },
+		"no namespace":           func(c *CloudWatchConfig) { c.Namespace = "" },
+	} {
+		conf := NewCloudWatchConfig()
+		fn(&conf)
+		if _, err := newCloudWatch(conf, &mockCloudWatchClient{}); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestCloudWatchDatums(t *testing.T) {
+	conf := NewCloudWatchConfig()
+	conf.FlushPeriod = "1h"
+	conf.Dimensions = map[string]string{"stream": "foo"}
+
+	c, err := newCloudWatch(conf, &mockCloudWatchClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.GetCounterVec("a.counter", []string{"label"}).With("bar").Incr(3)
+	c.GetGauge("a.gauge").Set(10)
+	c.GetTimer("a.timer").Timing(2000)
+	c.GetTimer("a.timer").Timing(4000)
+
+	datums := c.datums(time.Now())
+	if exp, act := 3, len(datums); exp != act {
+		t.Fatalf("Wrong count of datums: %v != %v", act, exp)
+	}
+
+	if exp, act := "a.counter", *datums[0].MetricName; exp != act {
+		t.Errorf("Wrong name: %v != %v", act, exp)
+	}
+	if exp, act := float64(3), *datums[0].Value; exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+	if exp, act := 2, len(datums[0].Dimensions); exp != act {
+		t.Fatalf("Wrong count of dimensions: %v != %v", act, exp)
+	}
+	if exp, act := "label=bar", fmt.Sprintf("%v=%v", *datums[0].Dimensions[0].Name, *datums[0].Dimensions[0].Value); exp != act {
+		t.Errorf("Wrong dimension: %v != %v", act, exp)
+	}
+	if exp, act := "stream=foo", fmt.Sprintf("%v=%v", *datums[0].Dimensions[1].Name, *datums[0].Dimensions[1].Value); exp != act {
+		t.Errorf("Wrong dimension: %v != %v", act, exp)
+	}
+
+	if exp, act := float64(10), *datums[1].Value; exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+
+	stats := datums[2].StatisticValues
+	if exp, act := float64(2), *stats.SampleCount; exp != act {
+		t.Errorf("Wrong sample count: %v != %v", act, exp)
+	}
+	if exp, act := float64(6), *stats.Sum; exp != act {
+		t.Errorf("Wrong sum: %v != %v", act, exp)
+	}
+	if exp, act := float64(4), *stats.Maximum; exp != act {
+		t.Errorf("Wrong maximum: %v != %v", act, exp)
+	}
+
+	// Counters that haven't changed and timers without samples are skipped.
+	if datums = c.datums(time.Now()); len(datums) != 1 {
+		t.Errorf("Wrong count of datums: %v", len(datums))
+	}
+}
+
+func TestCloudWatchBatches(t *testing.T) {
+	conf := NewCloudWatchConfig()
+	conf.FlushPeriod = "1h"
+
+	client := &mockCloudWatchClient{}
+	c, err := newCloudWatch(conf, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 45; i++ {
+		c.GetCounter(fmt.Sprintf("counter.%v", i)).Incr(1)
+	}
+	if err = c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.Lock()
+	defer client.Unlock()
+	if exp, act := 3, len(client.inputs); exp != act {
+		t.Fatalf("Wrong count of calls: %v != %v", act, exp)
+	}
+	for i, exp := range []int{20, 20, 5} {
+		if act := len(client.inputs[i].MetricData); exp != act {
+			t.Errorf("Wrong batch size %v: %v != %v", i, act, exp)
+		}
+		if exp, act := "Benthos", *client.inputs[i].Namespace; exp != act {
+			t.Errorf("Wrong namespace: %v != %v", act, exp)
+		}
+	}
+}
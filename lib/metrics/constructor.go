@@ -56,13 +56,23 @@ var Constructors = map[string]TypeSpec{}
 
 // String constants representing each metric type.
 const (
-	TypeBlackList  = "blacklist"
-	TypeHTTPServer = "http_server"
-	TypePrometheus = "prometheus"
-	TypeRename     = "rename"
-	TypeStatsd     = "statsd"
-	TypeStdout     = "stdout"
-	TypeWhiteList  = "whitelist"
+	TypeAzureMonitor   = "azure_monitor"
+	TypeBlackList      = "blacklist"
+	TypeCloudWatch     = "cloudwatch"
+	TypeDogStatsd      = "dogstatsd"
+	TypeFile           = "file"
+	TypeGCPStackdriver = "gcp_stackdriver"
+	TypeHTTPServer     = "http_server"
+	TypeInfluxDB       = "influxdb"
+	TypeMulti          = "multi"
+	TypeNewRelic       = "newrelic"
+	TypeOTLP           = "otlp"
+	TypePipeline       = "pipeline"
+	TypePrometheus     = "prometheus"
+	TypeRename         = "rename"
+	TypeStatsd         = "statsd"
+	TypeStdout         = "stdout"
+	TypeWhiteList      = "whitelist"
 )
 
 //------------------------------------------------------------------------------
@@ -70,27 +80,51 @@ const (
 // Config is the all encompassing configuration struct for all metric output
 // types.
 type Config struct {
-	Type       string           `json:"type" yaml:"type"`
-	Blacklist  BlacklistConfig  `json:"blacklist" yaml:"blacklist"`
-	HTTP       HTTPConfig       `json:"http_server" yaml:"http_server"`
-	Prometheus PrometheusConfig `json:"prometheus" yaml:"prometheus"`
-	Rename     RenameConfig     `json:"rename" yaml:"rename"`
-	Statsd     StatsdConfig     `json:"statsd" yaml:"statsd"`
-	Stdout     StdoutConfig     `json:"stdout" yaml:"stdout"`
-	Whitelist  WhitelistConfig  `json:"whitelist" yaml:"whitelist"`
+	Type           string               `json:"type" yaml:"type"`
+	Mapping        []MappingRuleConfig  `json:"mapping" yaml:"mapping"`
+	StaticLabels   map[string]string    `json:"static_labels" yaml:"static_labels"`
+	AzureMonitor   AzureMonitorConfig   `json:"azure_monitor" yaml:"azure_monitor"`
+	Blacklist      BlacklistConfig      `json:"blacklist" yaml:"blacklist"`
+	CloudWatch     CloudWatchConfig     `json:"cloudwatch" yaml:"cloudwatch"`
+	DogStatsd      DogStatsdConfig      `json:"dogstatsd" yaml:"dogstatsd"`
+	File           FileConfig           `json:"file" yaml:"file"`
+	GCPStackdriver GCPStackdriverConfig `json:"gcp_stackdriver" yaml:"gcp_stackdriver"`
+	HTTP           HTTPConfig           `json:"http_server" yaml:"http_server"`
+	InfluxDB       InfluxDBConfig       `json:"influxdb" yaml:"influxdb"`
+	Multi          MultiConfig          `json:"multi" yaml:"multi"`
+	NewRelic       NewRelicConfig       `json:"newrelic" yaml:"newrelic"`
+	OTLP           OTLPConfig           `json:"otlp" yaml:"otlp"`
+	Pipeline       PipelineConfig       `json:"pipeline" yaml:"pipeline"`
+	Prometheus     PrometheusConfig     `json:"prometheus" yaml:"prometheus"`
+	Rename         RenameConfig         `json:"rename" yaml:"rename"`
+	Statsd         StatsdConfig         `json:"statsd" yaml:"statsd"`
+	Stdout         StdoutConfig         `json:"stdout" yaml:"stdout"`
+	Whitelist      WhitelistConfig      `json:"whitelist" yaml:"whitelist"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:       "http_server",
-		Blacklist:  NewBlacklistConfig(),
-		HTTP:       NewHTTPConfig(),
-		Prometheus: NewPrometheusConfig(),
-		Rename:     NewRenameConfig(),
-		Statsd:     NewStatsdConfig(),
-		Stdout:     NewStdoutConfig(),
-		Whitelist:  NewWhitelistConfig(),
+		Type:           "http_server",
+		Mapping:        []MappingRuleConfig{},
+		StaticLabels:   map[string]string{},
+		AzureMonitor:   NewAzureMonitorConfig(),
+		Blacklist:      NewBlacklistConfig(),
+		CloudWatch:     NewCloudWatchConfig(),
+		DogStatsd:      NewDogStatsdConfig(),
+		File:           NewFileConfig(),
+		GCPStackdriver: NewGCPStackdriverConfig(),
+		HTTP:           NewHTTPConfig(),
+		InfluxDB:       NewInfluxDBConfig(),
+		Multi:          NewMultiConfig(),
+		NewRelic:       NewNewRelicConfig(),
+		OTLP:           NewOTLPConfig(),
+		Pipeline:       NewPipelineConfig(),
+		Prometheus:     NewPrometheusConfig(),
+		Rename:         NewRenameConfig(),
+		Statsd:         NewStatsdConfig(),
+		Stdout:         NewStdoutConfig(),
+		Whitelist:      NewWhitelistConfig(),
 	}
 }
 
@@ -118,6 +152,12 @@ func SanitiseConfig(conf Config) (interface{}, error) {
 	} else {
 		outputMap[t] = hashMap[t]
 	}
+	if len(conf.Mapping) > 0 {
+		outputMap["mapping"] = conf.Mapping
+	}
+	if len(conf.StaticLabels) > 0 {
+		outputMap["static_labels"] = conf.StaticLabels
+	}
 	return outputMap, nil
 }
 
@@ -202,7 +242,76 @@ metrics:
 
 Benthos exposes lots of metrics and their paths will depend on your pipeline
 configuration. However, there are some critical metrics that will always be
-present that are outlined in [this document](paths.md).`
+present that are outlined in [this document](paths.md).
+
+### Mapping
+
+Any metrics type can be configured with a list of ` + "`mapping`" + ` rules that
+are applied in order to each metric path as it is registered, before it reaches
+the metrics target. This allows naming conventions to be enforced regardless of
+the chosen target:
+
+` + "``` yaml" + `
+metrics:
+  type: prometheus
+  mapping:
+  - pattern: "^pipeline\\.processor\\.([0-9]+)\\.(.*)"
+    value: "pipeline.processor.$2"
+    to_label:
+      index: $1
+  - pattern: "^input\\.connection\\."
+    drop: true
+` + "```" + `
+
+Each pattern is parsed as an RE2 regular expression and tested against the
+metric path in dot notation. A rule with ` + "`drop`" + ` set to ` + "`true`" + `
+removes matching metrics entirely. Otherwise, if ` + "`value`" + ` is non-empty
+all matches within the path are replaced with it, where $ signs are interpreted
+as submatch expansions. The field ` + "`to_label`" + ` may contain any number of
+key/value pairs to be added to matching metrics as labels, where the value may
+contain submatches from the left-most match of the pattern. Labels are added to
+metrics regardless of whether they were registered with labels.
+
+Mapping rules are applied before metrics reach the target, and therefore the
+resulting paths are those grouped into objects by the ` + "`stdout`" + ` type and
+registered by the ` + "`prometheus`" + ` type.
+
+### Static Labels
+
+The field ` + "`static_labels`" + ` may contain any number of key/value pairs
+that are added as labels to all metrics, which is useful for identifying the
+host, pod or region that metrics originate from. Values can be populated from
+environment variables:
+
+` + "``` yaml" + `
+metrics:
+  type: prometheus
+  static_labels:
+    host: ${HOSTNAME}
+    region: ${REGION:unknown}
+` + "```" + `
+
+Static labels are added in the same way as labels from mapping rules, where a
+label of a mapping rule with the same name takes precedence. The ` + "`stdout`" + `,
+` + "`file` and `pipeline`" + ` types instead add them as fields to each object
+written, and the ` + "`multi`" + ` type adds them to each of its children. The
+` + "`statsd`" + ` type does not support labels and therefore ignores them.
+
+### Flushing
+
+Types that periodically push metrics, such as ` + "`stdout`" + `, ` + "`statsd`" + ` and
+` + "`cloudwatch`" + `, can be forced to send metrics immediately by sending a
+` + "`SIGUSR1`" + ` signal to the Benthos process, or with a POST request to the
+` + "`/metrics/flush`" + ` endpoint of the HTTP server. This is useful for debugging,
+or for sending metrics before shutting down a service.
+
+### Resetting
+
+The counters and timers of types that aggregate metrics locally, such as
+` + "`stdout`" + ` and ` + "`http_server`" + `, can be reset to zero with a POST request to the
+` + "`/metrics/reset`" + ` endpoint of the HTTP server, which allows them to be
+re-baselined (after a deploy, for example) without restarting the process.
+Gauges are left unchanged.`
 
 // Descriptions returns a formatted string of collated descriptions of each
 // type.
@@ -247,15 +356,39 @@ func Descriptions() string {
 	return buf.String()
 }
 
+// staticLabelsApplier is implemented by types that may add the static labels
+// of their config to metrics themselves.
+type staticLabelsApplier interface {
+	appliesStaticLabels() bool
+}
+
 // New creates a metric output type based on a configuration.
 func New(conf Config, opts ...func(Type)) (Type, error) {
 	if conf.Type == "none" {
 		return DudType{}, nil
 	}
-	if c, ok := Constructors[conf.Type]; ok {
-		return c.constructor(conf, opts...)
+	c, ok := Constructors[conf.Type]
+	if !ok {
+		return nil, ErrInvalidMetricOutputType
+	}
+	t, err := c.constructor(conf, opts...)
+	if err != nil {
+		return t, err
+	}
+	staticLabels := conf.StaticLabels
+	if a, ok := t.(staticLabelsApplier); ok && a.appliesStaticLabels() {
+		staticLabels = nil
+	}
+	if len(conf.Mapping) == 0 && len(staticLabels) == 0 {
+		return t, nil
+	}
+	if t, err = newMapping(t, conf.Mapping, staticLabels); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(t)
 	}
-	return nil, ErrInvalidMetricOutputType
+	return t, nil
 }
 
 //------------------------------------------------------------------------------
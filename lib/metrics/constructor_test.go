@@ -54,6 +54,8 @@ func TestSanitise(t *testing.T) {
 			"prefix":       "benthos",
 			"flush_period": "100ms",
 			"network":      "udp",
+			"timing_type":  "aggregate",
+			"sample_rates": map[string]interface{}{},
 		},
 	}
 
@@ -0,0 +1,327 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeDogStatsd] = TypeSpec{
+		constructor: NewDogStatsd,
+		description: `
+Push metrics over a UDP or TCP connection using the
+[DogStatsD protocol](https://docs.datadoghq.com/developers/dogstatsd/datagram_shell/),
+which extends StatsD with tags.
+
+Metrics registered with labels (such as those of the
+` + "[`metric` processor](../processors/README.md#metric)" + `) are sent with
+their labels as tags, and the static ` + "`tags`" + ` are added to all metrics.
+
+When ` + "`path_tags`" + ` is ` + "`true`" + ` the numeric index segments of a
+metric path are also converted into tags named after the segment preceding
+them, which keeps the number of distinct metric names small. For example, the
+path ` + "`pipeline.processor.0.count`" + ` is sent as the metric
+` + "`pipeline.processor.count`" + ` with the tag ` + "`processor:0`" + `.
+
+Timings are sent in milliseconds.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// DogStatsdConfig is config for the DogStatsd metrics type.
+type DogStatsdConfig struct {
+	Prefix      string            `json:"prefix" yaml:"prefix"`
+	Address     string            `json:"address" yaml:"address"`
+	FlushPeriod string            `json:"flush_period" yaml:"flush_period"`
+	Network     string            `json:"network" yaml:"network"`
+	Tags        map[string]string `json:"tags" yaml:"tags"`
+	PathTags    bool              `json:"path_tags" yaml:"path_tags"`
+}
+
+// NewDogStatsdConfig creates an DogStatsdConfig struct with default values.
+func NewDogStatsdConfig() DogStatsdConfig {
+	return DogStatsdConfig{
+		Prefix:      "benthos",
+		Address:     "localhost:8125",
+		FlushPeriod: "100ms",
+		Network:     "udp",
+		Tags:        map[string]string{},
+		PathTags:    true,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// DogStatsdStat is a representation of a single metric stat. Interactions
+// with this stat are thread safe.
+type DogStatsdStat struct {
+	name  string
+	tags  string
+	value int64
+	d     *DogStatsd
+}
+
+// Incr increments a metric by an amount.
+func (s *DogStatsdStat) Incr(count int64) error {
+	s.d.write(s.name, strconv.FormatInt(count, 10), "c", s.tags)
+	return nil
+}
+
+// Decr decrements a metric by an amount.
+func (s *DogStatsdStat) Decr(count int64) error {
+	s.d.write(s.name, strconv.FormatInt(-count, 10), "c", s.tags)
+	return nil
+}
+
+// Timing sets a timing metric.
+func (s *DogStatsdStat) Timing(delta int64) error {
+	ms := strconv.FormatFloat(float64(delta)/float64(time.Millisecond), 'f', -1, 64)
+	s.d.write(s.name, ms, "ms", s.tags)
+	return nil
+}
+
+// Set sets a gauge metric.
+func (s *DogStatsdStat) Set(value int64) error {
+	atomic.StoreInt64(&s.value, value)
+	s.d.write(s.name, strconv.FormatInt(value, 10), "g", s.tags)
+	return nil
+}
+
+// dogStatsdGauge is a gauge stat, where increments and decrements are applied
+// to a value held locally and then sent in full.
+type dogStatsdGauge struct {
+	*DogStatsdStat
+}
+
+// Incr increments a gauge by an amount.
+func (g dogStatsdGauge) Incr(count int64) error {
+	return g.Set(atomic.AddInt64(&g.value, count))
+}
+
+// Decr decrements a gauge by an amount.
+func (g dogStatsdGauge) Decr(count int64) error {
+	return g.Set(atomic.AddInt64(&g.value, -count))
+}
+
+//------------------------------------------------------------------------------
+
+// DogStatsd is a stats object with capability to push metrics with tags to a
+// DogStatsD agent.
+type DogStatsd struct {
+	config DogStatsdConfig
+	prefix string
+	w      *statsdWriter
+	log    log.Modular
+
+	gauges    map[string]*DogStatsdStat
+	gaugesMut sync.Mutex
+}
+
+// NewDogStatsd creates and returns a new DogStatsd object.
+func NewDogStatsd(config Config, opts ...func(Type)) (Type, error) {
+	d, err := newDogStatsd(config.DogStatsd, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func newDogStatsd(conf DogStatsdConfig, opts ...func(Type)) (*DogStatsd, error) {
+	flushPeriod, err := time.ParseDuration(conf.FlushPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush period: %s", err)
+	}
+	if flushPeriod <= 0 {
+		return nil, fmt.Errorf("flush period must be greater than zero: %v", conf.FlushPeriod)
+	}
+	if conf.Network != "udp" && conf.Network != "tcp" {
+		return nil, fmt.Errorf("network not recognised: %v", conf.Network)
+	}
+
+	d := &DogStatsd{
+		config: conf,
+		prefix: conf.Prefix,
+		log:    log.Noop(),
+		gauges: map[string]*DogStatsdStat{},
+	}
+	if len(d.prefix) > 0 && d.prefix[len(d.prefix)-1] != '.' {
+		d.prefix = d.prefix + "."
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.w, err = newStatsdWriter(conf.Network, conf.Address, flushPeriod, func(err error) {
+		d.log.Debugf("Failed to send metrics: %v\n", err)
+	}); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+//------------------------------------------------------------------------------
+
+var dogStatsdTagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+// nameAndTags returns the metric name and serialised tags of a path and
+// labels.
+func (d *DogStatsd) nameAndTags(path string, names, values []string) (string, string) {
+	tags := map[string]string{}
+	for k, v := range d.config.Tags {
+		tags[k] = v
+	}
+
+	if d.config.PathTags {
+		segments := strings.Split(path, ".")
+		nameSegments := make([]string, 0, len(segments))
+		for i, seg := range segments {
+			if _, err := strconv.Atoi(seg); err == nil && i > 0 {
+				tags[segments[i-1]] = seg
+				continue
+			}
+			nameSegments = append(nameSegments, seg)
+		}
+		path = strings.Join(nameSegments, ".")
+	}
+
+	for i, n := range names {
+		if i < len(values) {
+			tags[n] = values[i]
+		}
+	}
+
+	keys := sortedKeys(tags)
+	serialised := make([]string, 0, len(keys))
+	for _, k := range keys {
+		tag := dogStatsdTagEscaper.Replace(k)
+		if v := tags[k]; len(v) > 0 {
+			tag += ":" + dogStatsdTagEscaper.Replace(v)
+		}
+		serialised = append(serialised, tag)
+	}
+	return d.prefix + path, strings.Join(serialised, ",")
+}
+
+func (d *DogStatsd) newStat(path string, names, values []string) *DogStatsdStat {
+	name, tags := d.nameAndTags(path, names, values)
+	return &DogStatsdStat{
+		name: name,
+		tags: tags,
+		d:    d,
+	}
+}
+
+// newGauge returns a gauge stat, where gauges of the same name and tags share
+// their value.
+func (d *DogStatsd) newGauge(path string, names, values []string) StatGauge {
+	stat := d.newStat(path, names, values)
+	key := stat.name + "|" + stat.tags
+
+	d.gaugesMut.Lock()
+	if existing, exists := d.gauges[key]; exists {
+		stat = existing
+	} else {
+		d.gauges[key] = stat
+	}
+	d.gaugesMut.Unlock()
+	return dogStatsdGauge{stat}
+}
+
+// write adds a metric line to the buffer.
+func (d *DogStatsd) write(name, value, kind, tags string) {
+	line := name + ":" + value + "|" + kind
+	if len(tags) > 0 {
+		line += "|#" + tags
+	}
+	d.w.write(line)
+}
+
+//------------------------------------------------------------------------------
+
+// GetCounter returns a stat counter object for a path.
+func (d *DogStatsd) GetCounter(path string) StatCounter {
+	return d.newStat(path, nil, nil)
+}
+
+// GetCounterVec returns a stat counter object for a path with the labels
+// sent as tags.
+func (d *DogStatsd) GetCounterVec(path string, n []string) StatCounterVec {
+	return fakeCounterVec(func(v []string) StatCounter {
+		return d.newStat(path, n, v)
+	})
+}
+
+// GetTimer returns a stat timer object for a path.
+func (d *DogStatsd) GetTimer(path string) StatTimer {
+	return d.newStat(path, nil, nil)
+}
+
+// GetTimerVec returns a stat timer object for a path with the labels sent as
+// tags.
+func (d *DogStatsd) GetTimerVec(path string, n []string) StatTimerVec {
+	return fakeTimerVec(func(v []string) StatTimer {
+		return d.newStat(path, n, v)
+	})
+}
+
+// GetGauge returns a stat gauge object for a path.
+func (d *DogStatsd) GetGauge(path string) StatGauge {
+	return d.newGauge(path, nil, nil)
+}
+
+// GetGaugeVec returns a stat gauge object for a path with the labels sent as
+// tags.
+func (d *DogStatsd) GetGaugeVec(path string, n []string) StatGaugeVec {
+	return fakeGaugeVec(func(v []string) StatGauge {
+		return d.newGauge(path, n, v)
+	})
+}
+
+// flushNow sends buffered metrics immediately.
+func (d *DogStatsd) flushNow() bool {
+	d.w.flush()
+	return true
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (d *DogStatsd) SetLogger(log log.Modular) {
+	d.log = log
+}
+
+// Close stops the DogStatsd object from aggregating metrics, sends any
+// buffered metrics and closes the connection.
+func (d *DogStatsd) Close() error {
+	d.w.close()
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,140 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDogStatsdInterface(t *testing.T) {
+	o := &DogStatsd{}
+	if Type(o) == nil {
+		t.Errorf("DogStatsd does not satisfy Type interface")
+	}
+}
+
+func TestDogStatsdBadConfig(t *testing.T) {
+	conf := NewDogStatsdConfig()
+	conf.Network = "nope"
+	if _, err := newDogStatsd(conf); err == nil {
+		t.Error("Expected error from bad network")
+	}
+
+	conf = NewDogStatsdConfig()
+	conf.FlushPeriod = "nope"
+	if _, err := newDogStatsd(conf); err == nil {
+		t.Error("Expected error from bad flush period")
+	}
+}
+
+func TestDogStatsdNameAndTags(t *testing.T) {
+	tests := []struct {
+		pathTags bool
+		path     string
+		names    []string
+		values   []string
+		expName  string
+		expTags  string
+	}{
+		{
+			pathTags: true,
+			path:     "pipeline.processor.0.count",
+			expName:  "benthos.pipeline.processor.count",
+			expTags:  "env:prod,processor:0",
+		},
+		{
+			pathTags: false,
+			path:     "pipeline.processor.0.count",
+			expName:  "benthos.pipeline.processor.0.count",
+			expTags:  "env:prod",
+		},
+		{
+			pathTags: true,
+			path:     "output.broker.outputs.2.count",
+			names:    []string{"topic", "env"},
+			values:   []string{"foo,bar", "dev"},
+			expName:  "benthos.output.broker.outputs.count",
+			expTags:  "env:dev,outputs:2,topic:foo_bar",
+		},
+	}
+
+	for _, test := range tests {
+		conf := NewDogStatsdConfig()
+		conf.Tags = map[string]string{"env": "prod"}
+		conf.PathTags = test.pathTags
+		d := &DogStatsd{config: conf, prefix: "benthos."}
+
+		name, tags := d.nameAndTags(test.path, test.names, test.values)
+		if name != test.expName {
+			t.Errorf("Wrong name: %v != %v", name, test.expName)
+		}
+		if tags != test.expTags {
+			t.Errorf("Wrong tags: %v != %v", tags, test.expTags)
+		}
+	}
+}
+
+func TestDogStatsdUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeDogStatsd
+	conf.DogStatsd.Address = conn.LocalAddr().String()
+	conf.DogStatsd.FlushPeriod = "1h"
+
+	d, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.GetCounterVec("input.count", []string{"topic"}).With("foo").Incr(2)
+	gauge := d.GetGauge("input.gauge")
+	gauge.Set(5)
+	gauge.Incr(2)
+	d.GetTimer("input.latency").Timing(int64(1500 * time.Microsecond))
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	buf := make([]byte, statsdMaxPacketSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"benthos.input.count:2|c|#topic:foo",
+		"benthos.input.gauge:5|g",
+		"benthos.input.gauge:7|g",
+		"benthos.input.latency:1.5|ms",
+	}
+	if act := strings.Split(strings.TrimSpace(string(buf[:n])), "\n"); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong metrics: %q != %q", act, exp)
+	}
+}
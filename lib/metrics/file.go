@@ -0,0 +1,270 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeFile] = TypeSpec{
+		constructor: NewFile,
+		description: `
+EXPERIMENTAL: This component is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Writes metrics as JSON objects to a file at a configured path, one object per
+line grouped by the input/processor/output instance. The objects written are
+identical to those of the ` + "[`stdout`](#stdout)" + ` metrics type, and the
+fields ` + "`push_interval`, `push_every_n_messages`, `static_fields`, `flush_metrics`, `report_deltas`," + `
+` + "`include_patterns`, `exclude_patterns`, `format` and `emf_namespace`" + ` behave the same way. This allows metrics to be collected by log shippers without
+polluting the data stream on stdout.
+
+### Rotation
+
+If ` + "`rotate_max_bytes`" + ` is greater than zero then the file is rotated
+before a write would cause it to exceed that size. If ` + "`rotate_interval`" + `
+is set then the file is rotated once it has been open for longer than the
+interval. Rotated files are renamed by appending the timestamp of the rotation
+to the path, e.g. ` + "`metrics.jsonl.2006-01-02T15-04-05.000`" + `, and if
+` + "`rotate_max_files`" + ` is greater than zero then only that number of the
+most recent files rotated by the running instance are kept.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// FileConfig contains configuration parameters for the File metrics
+// aggregator.
+type FileConfig struct {
+	Path               string                 `json:"path" yaml:"path"`
+	RotateMaxBytes     int64                  `json:"rotate_max_bytes" yaml:"rotate_max_bytes"`
+	RotateInterval     string                 `json:"rotate_interval" yaml:"rotate_interval"`
+	RotateMaxFiles     int                    `json:"rotate_max_files" yaml:"rotate_max_files"`
+	PushInterval       string                 `json:"push_interval" yaml:"push_interval"`
+	PushEveryNMessages int64                  `json:"push_every_n_messages" yaml:"push_every_n_messages"`
+	StaticFields       map[string]interface{} `json:"static_fields" yaml:"static_fields"`
+	FlushMetrics       bool                   `json:"flush_metrics" yaml:"flush_metrics"`
+	ReportDeltas       bool                   `json:"report_deltas" yaml:"report_deltas"`
+	IncludePatterns    []string               `json:"include_patterns" yaml:"include_patterns"`
+	ExcludePatterns    []string               `json:"exclude_patterns" yaml:"exclude_patterns"`
+	Format             string                 `json:"format" yaml:"format"`
+	EMFNamespace       string                 `json:"emf_namespace" yaml:"emf_namespace"`
+}
+
+// NewFileConfig returns a new FileConfig with default values.
+func NewFileConfig() FileConfig {
+	return FileConfig{
+		Path:               "",
+		RotateMaxBytes:     0,
+		RotateInterval:     "",
+		RotateMaxFiles:     0,
+		PushInterval:       "",
+		PushEveryNMessages: 0,
+		StaticFields: map[string]interface{}{
+			"@service": "benthos",
+		},
+		FlushMetrics:    false,
+		ReportDeltas:    false,
+		IncludePatterns: []string{},
+		ExcludePatterns: []string{},
+		Format:          "json",
+		EMFNamespace:    "Benthos",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// File is an object with capability to hold internal stats and emit them as
+// individual JSON objects to a file.
+type File struct {
+	*Stdout
+	file *rotatingFile
+}
+
+// NewFile creates and returns a new File metric object.
+func NewFile(config Config, opts ...func(Type)) (Type, error) {
+	if len(config.File.Path) == 0 {
+		return nil, errors.New("a file path must be specified")
+	}
+
+	var rotateInterval time.Duration
+	if len(config.File.RotateInterval) > 0 {
+		var err error
+		if rotateInterval, err = time.ParseDuration(config.File.RotateInterval); err != nil {
+			return nil, fmt.Errorf("failed to parse rotate interval: %v", err)
+		}
+	}
+
+	file, err := newRotatingFile(
+		config.File.Path,
+		config.File.RotateMaxBytes,
+		rotateInterval,
+		config.File.RotateMaxFiles,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stdoutConf := config
+	stdoutConf.Stdout = StdoutConfig{
+		PushInterval:       config.File.PushInterval,
+		PushEveryNMessages: config.File.PushEveryNMessages,
+		StaticFields:       config.File.StaticFields,
+		FlushMetrics:       config.File.FlushMetrics,
+		ReportDeltas:       config.File.ReportDeltas,
+		IncludePatterns:    config.File.IncludePatterns,
+		ExcludePatterns:    config.File.ExcludePatterns,
+		Format:             config.File.Format,
+		EMFNamespace:       config.File.EMFNamespace,
+	}
+
+	s, err := newStdout(stdoutConf, file, opts...)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &File{
+		Stdout: s,
+		file:   file,
+	}, nil
+}
+
+// Close stops the File object from aggregating metrics, does a final write of
+// metrics and closes the underlying file.
+func (f *File) Close() error {
+	err := f.Stdout.Close()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+// rotatingFile is an io.WriteCloser that appends to a file and rotates it
+// based on size and age.
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	interval time.Duration
+	maxFiles int
+
+	file    *os.File
+	size    int64
+	opened  time.Time
+	rotated []string
+
+	mut sync.Mutex
+}
+
+func newRotatingFile(path string, maxBytes int64, interval time.Duration, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:     path,
+		maxBytes: maxBytes,
+		interval: interval,
+		maxFiles: maxFiles,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.FileMode(0666))
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	rotatedPath := r.path + "." + time.Now().Format("2006-01-02T15-04-05.000")
+	if err := os.Rename(r.path, rotatedPath); err != nil {
+		return err
+	}
+	r.rotated = append(r.rotated, rotatedPath)
+	if r.maxFiles > 0 {
+		for len(r.rotated) > r.maxFiles {
+			os.Remove(r.rotated[0])
+			r.rotated = r.rotated[1:]
+		}
+	}
+	return r.open()
+}
+
+// Write appends bytes to the file, rotating the file beforehand if necessary.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.size > 0 && ((r.maxBytes > 0 && r.size+int64(len(p)) > r.maxBytes) ||
+		(r.interval > 0 && time.Since(r.opened) >= r.interval)) {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("failed to rotate file: %v", err)
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the underlying file.
+func (r *rotatingFile) Close() error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,150 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func TestFileInterface(t *testing.T) {
+	o := &File{}
+	if Type(o) == nil {
+		t.Errorf("Type does not satisfy Type interface.")
+	}
+}
+
+func TestFileNoPath(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeFile
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from missing path")
+	}
+}
+
+func TestFileWritesOnClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_metrics_file_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewConfig()
+	conf.Type = TypeFile
+	conf.File.Path = filepath.Join(dir, "metrics.jsonl")
+
+	f, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.GetCounter("input.count").Incr(10)
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(conf.File.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := parseStdoutLines(t, strings.Split(strings.TrimSpace(string(content)), "\n"))
+	if exp, act := float64(10), objs["input"]["input"].(map[string]interface{})["count"]; exp != act {
+		t.Errorf("Wrong input count: %v != %v", act, exp)
+	}
+}
+
+func TestRotatingFileMaxBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_metrics_file_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metrics.jsonl")
+	r, err := newRotatingFile(path, 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range []string{"first\n", "second\n", "third\n"} {
+		if _, err = r.Write([]byte(l)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "third\n", string(content); exp != act {
+		t.Errorf("Wrong current file content: %q != %q", act, exp)
+	}
+	if exp, act := 2, len(r.rotated); exp != act {
+		t.Errorf("Wrong count of rotated files: %v != %v", act, exp)
+	}
+}
+
+func TestRotatingFileMaxFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_metrics_file_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metrics.jsonl")
+	r, err := newRotatingFile(path, 1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.rotated = []string{filepath.Join(dir, "metrics.jsonl.old")}
+	if err = ioutil.WriteFile(r.rotated[0], []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range []string{"first\n", "second\n"} {
+		if _, err = r.Write([]byte(l)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "metrics.jsonl.old")); !os.IsNotExist(err) {
+		t.Errorf("Expected oldest rotated file to be removed: %v", err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, len(files); exp != act {
+		t.Errorf("Wrong count of files: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
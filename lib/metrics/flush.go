@@ -0,0 +1,55 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+//------------------------------------------------------------------------------
+
+// flusher is implemented by types that periodically push metrics and are able
+// to publish them on demand.
+type flusher interface {
+	flushNow() bool
+}
+
+// Flush forces a Type that periodically pushes metrics to publish them
+// immediately, blocking until the metrics have been sent. Returns false if the
+// Type does not push metrics.
+func Flush(t Type) bool {
+	if f, ok := t.(flusher); ok {
+		return f.flushNow()
+	}
+	return false
+}
+
+// requestFlush asks the loop of a push based type to flush its metrics and
+// waits for it to complete. The loop receives a channel from flushChan which it
+// closes once the flush is done, and the request is abandoned if the loop has
+// already stopped.
+func requestFlush(flushChan chan<- chan struct{}, doneChan <-chan struct{}) {
+	done := make(chan struct{})
+	select {
+	case flushChan <- done:
+	case <-doneChan:
+		return
+	}
+	<-done
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,101 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+)
+
+func TestFlushUnsupported(t *testing.T) {
+	if Flush(NewLocal()) {
+		t.Error("Expected local type not to flush")
+	}
+	if Flush(Noop()) {
+		t.Error("Expected noop type not to flush")
+	}
+}
+
+func TestFlushStdout(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	conf := NewConfig()
+	conf.Stdout.PushInterval = ""
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.GetCounter("foo").Incr(1)
+	wrapped := &Whitelist{s: s}
+	if !Flush(wrapped) {
+		t.Fatal("Expected flush")
+	}
+	if buf.Len() == 0 {
+		t.Error("Expected metrics to be written")
+	}
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if !Flush(s) {
+		t.Fatal("Expected flush")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no metrics to be written after close: %s", buf.Bytes())
+	}
+}
+
+func TestFlushLoop(t *testing.T) {
+	conf := testGCPStackdriverConfig()
+
+	var writes int32
+	g, err := newGCPStackdriver(conf, func(context.Context, *monitoringpb.CreateTimeSeriesRequest) error {
+		atomic.AddInt32(&writes, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.GetCounter("foo").Incr(1)
+
+	if !Flush(g) {
+		t.Fatal("Expected flush")
+	}
+	if exp, act := int32(1), atomic.LoadInt32(&writes); exp != act {
+		t.Errorf("Wrong count of writes: %v != %v", act, exp)
+	}
+
+	if err = g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := int32(2), atomic.LoadInt32(&writes); exp != act {
+		t.Errorf("Wrong count of writes: %v != %v", act, exp)
+	}
+
+	// Flushing a closed type should not block.
+	Flush(g)
+}
@@ -0,0 +1,427 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	monitoring "cloud.google.com/go/monitoring/apiv3"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/genproto/googleapis/api/distribution"
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeGCPStackdriver] = TypeSpec{
+		constructor: NewGCPStackdriver,
+		description: `
+Write metrics as custom metric time series to
+[Google Cloud Monitoring](https://cloud.google.com/monitoring/custom-metrics)
+(formerly Stackdriver). Credentials are obtained from the environment with
+[application default credentials](https://cloud.google.com/docs/authentication/production).
+
+Each metric is written with the type of its path prepended with
+` + "`prefix`" + `, and with its labels as metric labels. Counters are written as
+cumulative integers, gauges as integers and timers as cumulative distributions
+of timings in milliseconds within the configured ` + "`histogram_buckets`" + `.
+
+### Resources
+
+Time series are written against the monitored resource ` + "`resource_type`" + `
+with the labels ` + "`resource_labels`" + `. When ` + "`resource_type`" + ` is
+empty the resource is detected from the GCE metadata server, where within a GKE
+cluster the resource is a ` + "`k8s_pod`" + `, within a GCE instance it is a
+` + "`gce_instance`" + `, and otherwise it is ` + "`global`" + `. The project
+is also detected from the metadata server when ` + "`project`" + ` is empty.
+
+The namespace and name of a pod are read from the environment variables
+` + "`POD_NAMESPACE`" + ` and ` + "`POD_NAME`" + `, falling back to the
+namespace of the service account and the hostname.
+
+### Quotas
+
+Time series are written each ` + "`flush_period`" + ` in batches of at most
+` + "`batch_size`" + ` time series per request, which cannot exceed 200. Google
+Cloud Monitoring rejects points written to a time series more than once every
+five seconds, and therefore the flush period cannot be less than this.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// GCPStackdriverConfig is config for the Google Cloud Monitoring metrics type.
+type GCPStackdriverConfig struct {
+	Project          string            `json:"project" yaml:"project"`
+	Prefix           string            `json:"prefix" yaml:"prefix"`
+	ResourceType     string            `json:"resource_type" yaml:"resource_type"`
+	ResourceLabels   map[string]string `json:"resource_labels" yaml:"resource_labels"`
+	HistogramBuckets []string          `json:"histogram_buckets" yaml:"histogram_buckets"`
+	BatchSize        int               `json:"batch_size" yaml:"batch_size"`
+	FlushPeriod      string            `json:"flush_period" yaml:"flush_period"`
+	Timeout          string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewGCPStackdriverConfig creates a GCPStackdriverConfig struct with default
+// values.
+func NewGCPStackdriverConfig() GCPStackdriverConfig {
+	return GCPStackdriverConfig{
+		Project:        "",
+		Prefix:         "custom.googleapis.com/benthos/",
+		ResourceType:   "",
+		ResourceLabels: map[string]string{},
+		HistogramBuckets: []string{
+			"1ms", "5ms", "10ms", "25ms", "50ms", "100ms", "250ms", "500ms",
+			"1s", "2.5s", "5s", "10s",
+		},
+		BatchSize:   200,
+		FlushPeriod: "60s",
+		Timeout:     "10s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	gcpStackdriverMaxBatchSize      = 200
+	gcpStackdriverMinFlushPeriod    = time.Second * 5
+	gcpStackdriverServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+var gcpStackdriverInvalidChars = regexp.MustCompile("[^a-zA-Z0-9_/.]")
+
+// GCPStackdriver is a stats object that periodically writes custom metric time
+// series to Google Cloud Monitoring.
+type GCPStackdriver struct {
+	*snapshotStore
+
+	config    GCPStackdriverConfig
+	timeout   time.Duration
+	resource  *monitoredres.MonitoredResource
+	start     *timestamp.Timestamp
+	log       log.Modular
+	closeOnce sync.Once
+
+	write       func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error
+	closeClient func() error
+
+	closedChan chan struct{}
+	doneChan   chan struct{}
+	flushChan  chan chan struct{}
+}
+
+// NewGCPStackdriver creates and returns a new GCPStackdriver object.
+func NewGCPStackdriver(config Config, opts ...func(Type)) (Type, error) {
+	client, err := monitoring.NewMetricClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+	g, err := newGCPStackdriver(config.GCPStackdriver, func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+		return client.CreateTimeSeries(ctx, req)
+	}, opts...)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	g.closeClient = client.Close
+	return g, nil
+}
+
+func newGCPStackdriver(
+	conf GCPStackdriverConfig,
+	write func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error,
+	opts ...func(Type),
+) (*GCPStackdriver, error) {
+	if conf.BatchSize < 1 || conf.BatchSize > gcpStackdriverMaxBatchSize {
+		return nil, fmt.Errorf("batch size must be between 1 and %v: %v", gcpStackdriverMaxBatchSize, conf.BatchSize)
+	}
+	flushPeriod, err := time.ParseDuration(conf.FlushPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush period: %v", err)
+	}
+	if flushPeriod < gcpStackdriverMinFlushPeriod {
+		return nil, fmt.Errorf("flush period must be at least %v: %v", gcpStackdriverMinFlushPeriod, conf.FlushPeriod)
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	g := &GCPStackdriver{
+		snapshotStore: newSnapshotStore(),
+		config:        conf,
+		timeout:       timeout,
+		start:         gcpTimestamp(time.Now()),
+		log:           log.Noop(),
+		write:         write,
+		closeClient:   func() error { return nil },
+		closedChan:    make(chan struct{}),
+		doneChan:      make(chan struct{}),
+		flushChan:     make(chan chan struct{}),
+	}
+
+	for _, b := range conf.HistogramBuckets {
+		bound, err := time.ParseDuration(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse histogram bucket: %v", err)
+		}
+		if l := len(g.bucketBounds); l > 0 && int64(bound) <= g.bucketBounds[l-1] {
+			return nil, errors.New("histogram buckets must be in increasing order")
+		}
+		g.bucketBounds = append(g.bucketBounds, int64(bound))
+	}
+
+	if len(g.config.Project) == 0 {
+		if !metadata.OnGCE() {
+			return nil, errors.New("a project must be specified when not running within GCP")
+		}
+		if g.config.Project, err = metadata.ProjectID(); err != nil {
+			return nil, fmt.Errorf("failed to detect project: %v", err)
+		}
+	}
+
+	resourceType, resourceLabels := conf.ResourceType, conf.ResourceLabels
+	if len(resourceType) == 0 {
+		resourceType, resourceLabels = detectGCPResource(g.config.Project)
+		for k, v := range conf.ResourceLabels {
+			resourceLabels[k] = v
+		}
+	}
+	g.resource = &monitoredres.MonitoredResource{
+		Type:   resourceType,
+		Labels: resourceLabels,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	go g.loop(flushPeriod)
+	return g, nil
+}
+
+//------------------------------------------------------------------------------
+
+// detectGCPResource returns the type and labels of the monitored resource
+// Benthos is running within.
+func detectGCPResource(project string) (string, map[string]string) {
+	if !metadata.OnGCE() {
+		return "global", map[string]string{"project_id": project}
+	}
+
+	zone, _ := metadata.Zone()
+	if cluster, err := metadata.InstanceAttributeValue("cluster-name"); err == nil && len(cluster) > 0 {
+		location, err := metadata.InstanceAttributeValue("cluster-location")
+		if err != nil || len(location) == 0 {
+			location = zone
+		}
+		namespace := os.Getenv("POD_NAMESPACE")
+		if len(namespace) == 0 {
+			if b, err := ioutil.ReadFile(gcpStackdriverServiceAccountDir + "/namespace"); err == nil {
+				namespace = strings.TrimSpace(string(b))
+			}
+		}
+		pod := os.Getenv("POD_NAME")
+		if len(pod) == 0 {
+			pod, _ = os.Hostname()
+		}
+		return "k8s_pod", map[string]string{
+			"project_id":     project,
+			"location":       location,
+			"cluster_name":   cluster,
+			"namespace_name": namespace,
+			"pod_name":       pod,
+		}
+	}
+
+	instanceID, _ := metadata.InstanceID()
+	return "gce_instance", map[string]string{
+		"project_id":  project,
+		"instance_id": instanceID,
+		"zone":        zone,
+	}
+}
+
+func gcpTimestamp(t time.Time) *timestamp.Timestamp {
+	return &timestamp.Timestamp{
+		Seconds: t.Unix(),
+		Nanos:   int32(t.Nanosecond()),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (g *GCPStackdriver) loop(flushPeriod time.Duration) {
+	defer close(g.doneChan)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.flush()
+		case done := <-g.flushChan:
+			g.flush()
+			close(done)
+		case <-g.closedChan:
+			g.flush()
+			return
+		}
+	}
+}
+
+func (g *GCPStackdriver) metric(m metricSnapshot) *metricpb.Metric {
+	return &metricpb.Metric{
+		Type:   g.config.Prefix + gcpStackdriverInvalidChars.ReplaceAllString(m.Path, "_"),
+		Labels: m.Labels(),
+	}
+}
+
+// timeSeries returns a snapshot of all metrics as time series.
+func (g *GCPStackdriver) timeSeries(t time.Time) []*monitoringpb.TimeSeries {
+	counters, gauges, timers := g.snapshot()
+	now := gcpTimestamp(t)
+
+	var series []*monitoringpb.TimeSeries
+	for _, m := range counters {
+		series = append(series, &monitoringpb.TimeSeries{
+			Metric:     g.metric(m),
+			Resource:   g.resource,
+			MetricKind: metricpb.MetricDescriptor_CUMULATIVE,
+			ValueType:  metricpb.MetricDescriptor_INT64,
+			Points: []*monitoringpb.Point{{
+				Interval: &monitoringpb.TimeInterval{StartTime: g.start, EndTime: now},
+				Value: &monitoringpb.TypedValue{
+					Value: &monitoringpb.TypedValue_Int64Value{Int64Value: m.Value},
+				},
+			}},
+		})
+	}
+	for _, m := range gauges {
+		series = append(series, &monitoringpb.TimeSeries{
+			Metric:     g.metric(m),
+			Resource:   g.resource,
+			MetricKind: metricpb.MetricDescriptor_GAUGE,
+			ValueType:  metricpb.MetricDescriptor_INT64,
+			Points: []*monitoringpb.Point{{
+				Interval: &monitoringpb.TimeInterval{EndTime: now},
+				Value: &monitoringpb.TypedValue{
+					Value: &monitoringpb.TypedValue_Int64Value{Int64Value: m.Value},
+				},
+			}},
+		})
+	}
+
+	bounds := make([]float64, len(g.bucketBounds))
+	for i, b := range g.bucketBounds {
+		bounds[i] = float64(b) / float64(time.Millisecond)
+	}
+	for _, m := range timers {
+		dist := &distribution.Distribution{
+			Count: m.Timing.TotalCount,
+			BucketOptions: &distribution.Distribution_BucketOptions{
+				Options: &distribution.Distribution_BucketOptions_ExplicitBuckets{
+					ExplicitBuckets: &distribution.Distribution_BucketOptions_Explicit{
+						Bounds: bounds,
+					},
+				},
+			},
+			BucketCounts: m.Timing.TotalBuckets,
+		}
+		if m.Timing.TotalCount > 0 {
+			dist.Mean = float64(m.Timing.TotalSum) / float64(m.Timing.TotalCount) / float64(time.Millisecond)
+		}
+		series = append(series, &monitoringpb.TimeSeries{
+			Metric:     g.metric(m),
+			Resource:   g.resource,
+			MetricKind: metricpb.MetricDescriptor_CUMULATIVE,
+			ValueType:  metricpb.MetricDescriptor_DISTRIBUTION,
+			Points: []*monitoringpb.Point{{
+				Interval: &monitoringpb.TimeInterval{StartTime: g.start, EndTime: now},
+				Value: &monitoringpb.TypedValue{
+					Value: &monitoringpb.TypedValue_DistributionValue{DistributionValue: dist},
+				},
+			}},
+		})
+	}
+	return series
+}
+
+func (g *GCPStackdriver) flush() {
+	series := g.timeSeries(time.Now())
+	for len(series) > 0 {
+		n := g.config.BatchSize
+		if n > len(series) {
+			n = len(series)
+		}
+		ctx, done := context.WithTimeout(context.Background(), g.timeout)
+		err := g.write(ctx, &monitoringpb.CreateTimeSeriesRequest{
+			Name:       "projects/" + g.config.Project,
+			TimeSeries: series[:n],
+		})
+		done()
+		if err != nil {
+			g.log.Errorf("Failed to write time series: %v\n", err)
+		}
+		series = series[n:]
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// flushNow sends metrics immediately.
+func (g *GCPStackdriver) flushNow() bool {
+	requestFlush(g.flushChan, g.doneChan)
+	return true
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (g *GCPStackdriver) SetLogger(log log.Modular) {
+	g.log = log
+}
+
+// Close stops the GCPStackdriver object from aggregating metrics and writes
+// the remaining time series.
+func (g *GCPStackdriver) Close() error {
+	var err error
+	g.closeOnce.Do(func() {
+		close(g.closedChan)
+		<-g.doneChan
+		err = g.closeClient()
+	})
+	return err
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,187 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	metricpb "google.golang.org/genproto/googleapis/api/metric"
+	monitoringpb "google.golang.org/genproto/googleapis/monitoring/v3"
+)
+
+func TestGCPStackdriverInterface(t *testing.T) {
+	o := &GCPStackdriver{}
+	if Type(o) == nil {
+		t.Errorf("GCPStackdriver does not satisfy Type interface")
+	}
+}
+
+func testGCPStackdriverConfig() GCPStackdriverConfig {
+	conf := NewGCPStackdriverConfig()
+	conf.Project = "foo"
+	conf.ResourceType = "global"
+	conf.ResourceLabels = map[string]string{"project_id": "foo"}
+	conf.FlushPeriod = "1h"
+	return conf
+}
+
+func TestGCPStackdriverBadConfig(t *testing.T) {
+	noopWrite := func(context.Context, *monitoringpb.CreateTimeSeriesRequest) error { return nil }
+	for name, fn := range map[string]func(c *GCPStackdriverConfig){
+		"zero batch size":    func(c *GCPStackdriverConfig) { c.BatchSize = 0 },
+		"large batch size":   func(c *GCPStackdriverConfig) { c.BatchSize = 201 },
+		"bad flush period":   func(c *GCPStackdriverConfig) { c.FlushPeriod = "nope" },
+		"short flush period": func(c *GCPStackdriverConfig) { c.FlushPeriod = "1s" },
+		"bad timeout":        func(c *GCPStackdriverConfig) { c.Timeout = "nope" },
+		"bad bucket":         func(c *GCPStackdriverConfig) { c.HistogramBuckets = []string{"nope"} },
+		"unordered buckets":  func(c *GCPStackdriverConfig) { c.HistogramBuckets = []string{"2ms", "1ms"} },
+	} {
+		conf := testGCPStackdriverConfig()
+		fn(&conf)
+		if _, err := newGCPStackdriver(conf, noopWrite); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestGCPStackdriverTimeSeries(t *testing.T) {
+	conf := testGCPStackdriverConfig()
+	conf.HistogramBuckets = []string{"2ms", "10ms"}
+
+	g, err := newGCPStackdriver(conf, func(context.Context, *monitoringpb.CreateTimeSeriesRequest) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	g.GetCounter("a.counter").Incr(3)
+	g.GetGaugeVec("a-gauge", []string{"label"}).With("baz").Set(10)
+	timer := g.GetTimer("a.timer")
+	for _, v := range []int64{1, 2, 3} {
+		timer.Timing(v * int64(time.Millisecond))
+	}
+
+	series := g.timeSeries(time.Unix(60, 0))
+	if exp, act := 3, len(series); exp != act {
+		t.Fatalf("Wrong count of time series: %v != %v", act, exp)
+	}
+
+	counter := series[0]
+	if exp, act := "custom.googleapis.com/benthos/a.counter", counter.Metric.Type; exp != act {
+		t.Errorf("Wrong metric type: %v != %v", act, exp)
+	}
+	if exp, act := metricpb.MetricDescriptor_CUMULATIVE, counter.MetricKind; exp != act {
+		t.Errorf("Wrong metric kind: %v != %v", act, exp)
+	}
+	if exp, act := int64(3), counter.Points[0].Value.GetInt64Value(); exp != act {
+		t.Errorf("Wrong counter value: %v != %v", act, exp)
+	}
+	if exp, act := g.start, counter.Points[0].Interval.StartTime; exp != act {
+		t.Errorf("Wrong start time: %v != %v", act, exp)
+	}
+	if exp, act := "global", counter.Resource.Type; exp != act {
+		t.Errorf("Wrong resource type: %v != %v", act, exp)
+	}
+
+	gauge := series[1]
+	if exp, act := "custom.googleapis.com/benthos/a_gauge", gauge.Metric.Type; exp != act {
+		t.Errorf("Wrong metric type: %v != %v", act, exp)
+	}
+	if exp, act := map[string]string{"label": "baz"}, gauge.Metric.Labels; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong labels: %v != %v", act, exp)
+	}
+	if exp, act := metricpb.MetricDescriptor_GAUGE, gauge.MetricKind; exp != act {
+		t.Errorf("Wrong metric kind: %v != %v", act, exp)
+	}
+	if exp, act := int64(10), gauge.Points[0].Value.GetInt64Value(); exp != act {
+		t.Errorf("Wrong gauge value: %v != %v", act, exp)
+	}
+
+	dist := series[2].Points[0].Value.GetDistributionValue()
+	if exp, act := int64(3), dist.Count; exp != act {
+		t.Errorf("Wrong distribution count: %v != %v", act, exp)
+	}
+	if exp, act := float64(2), dist.Mean; exp != act {
+		t.Errorf("Wrong distribution mean: %v != %v", act, exp)
+	}
+	if exp, act := []int64{2, 1, 0}, dist.BucketCounts; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong distribution buckets: %v != %v", act, exp)
+	}
+	if exp, act := []float64{2, 10}, dist.BucketOptions.GetExplicitBuckets().Bounds; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong distribution bounds: %v != %v", act, exp)
+	}
+
+	// Counters and distributions are cumulative across flushes.
+	g.GetCounter("a.counter").Incr(1)
+	timer.Timing(int64(time.Second))
+	series = g.timeSeries(time.Unix(120, 0))
+	if exp, act := int64(4), series[0].Points[0].Value.GetInt64Value(); exp != act {
+		t.Errorf("Wrong counter value: %v != %v", act, exp)
+	}
+	if exp, act := []int64{2, 1, 1}, series[2].Points[0].Value.GetDistributionValue().BucketCounts; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong distribution buckets: %v != %v", act, exp)
+	}
+}
+
+func TestGCPStackdriverBatching(t *testing.T) {
+	conf := testGCPStackdriverConfig()
+	conf.BatchSize = 2
+
+	var reqMut sync.Mutex
+	var batches []int
+	g, err := newGCPStackdriver(conf, func(ctx context.Context, req *monitoringpb.CreateTimeSeriesRequest) error {
+		reqMut.Lock()
+		defer reqMut.Unlock()
+		if exp, act := "projects/foo", req.Name; exp != act {
+			t.Errorf("Wrong name: %v != %v", act, exp)
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected a deadline")
+		}
+		batches = append(batches, len(req.TimeSeries))
+		return errors.New("failed to write")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.GetCounter("a").Incr(1)
+	g.GetCounter("b").Incr(1)
+	g.GetGauge("c").Set(1)
+	g.GetGauge("d").Set(1)
+	g.GetTimer("e").Timing(1)
+	if err = g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+	if exp, act := []int{2, 2, 1}, batches; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong batches: %v != %v", act, exp)
+	}
+}
@@ -55,7 +55,14 @@ this:
 		"baz": 3
 	}
 }
-` + "```" + ``,
+` + "```" + `
+
+Timers are also given the estimated 50th, 90th and 99th percentiles of their
+most recent 512 timings, with the suffixes ` + "`_p50`, `_p90` and `_p99`" + `.
+
+Metrics are also served at the endpoint ` + "`/metrics/json`" + ` as an array
+of JSON documents for each component instance, matching those written by the
+` + "[`stdout`](#stdout)" + ` type.`,
 	}
 }
 
@@ -111,7 +118,7 @@ func (h *HTTP) HandlerFunc() http.HandlerFunc {
 		goroutines := runtime.NumGoroutine()
 
 		counters := h.local.GetCounters()
-		timings := h.local.GetTimings()
+		timings := withTimingPercentiles(h.local.GetTimings(), h.local.GetTimingPercentiles())
 
 		obj := gabs.New()
 		for k, v := range counters {
@@ -135,6 +142,38 @@ func (h *HTTP) HandlerFunc() http.HandlerFunc {
 	}
 }
 
+// jsonHandlerFunc returns an http.HandlerFunc that serves metrics as an array
+// of JSON documents for each component instance, matching the documents
+// written by the stdout type.
+func (h *HTTP) jsonHandlerFunc() (http.HandlerFunc, bool) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		objs := map[string]*gabs.Container{}
+		for _, metrics := range []map[string]int64{
+			h.local.GetCounters(),
+			withTimingPercentiles(h.local.GetTimings(), h.local.GetTimingPercentiles()),
+			systemMetrics(h.timestamp),
+		} {
+			for k, v := range metrics {
+				obj, valKey := metricObject(objs, k)
+				obj.SetP(v, valKey)
+			}
+		}
+		timestamp := time.Now().Format(time.RFC3339)
+		docs := make([]*gabs.Container, 0, len(objs))
+		for _, k := range sortedContainerKeys(objs) {
+			objs[k].SetP(timestamp, "@timestamp")
+			docs = append(docs, objs[k])
+		}
+		writeJSONDocuments(w, docs)
+	}, true
+}
+
+// resetNow zeroes all counters and timers.
+func (h *HTTP) resetNow() bool {
+	h.local.Reset()
+	return true
+}
+
 // GetCounter returns a stat counter object for a path.
 func (h *HTTP) GetCounter(path string) StatCounter {
 	return h.local.GetCounter(path)
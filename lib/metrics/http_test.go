@@ -20,7 +20,11 @@
 
 package metrics
 
-import "testing"
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
 
 func TestHTTPInterface(t *testing.T) {
 	o := &HTTP{}
@@ -28,3 +32,43 @@ func TestHTTPInterface(t *testing.T) {
 		t.Errorf("Type does not satisfy Type interface.")
 	}
 }
+
+func TestHTTPJSONHandler(t *testing.T) {
+	h, err := NewHTTP(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.GetCounter("pipeline.processor.0.count").Incr(3)
+	h.GetTimer("output.latency").Timing(10)
+
+	handler, ok := JSONHandlerFunc(h)
+	if !ok {
+		t.Fatal("Expected http_server to have a JSON handler")
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/metrics/json", nil))
+
+	var docs []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	objs := map[string]map[string]interface{}{}
+	for _, d := range docs {
+		if _, exists := d["@timestamp"]; !exists {
+			t.Errorf("Missing timestamp: %v", d)
+		}
+		objs[d["metric"].(string)] = d
+	}
+	if exp, act := float64(3), objs["pipeline.processor.0"]["count"]; exp != act {
+		t.Errorf("Wrong counter value: %v != %v", act, exp)
+	}
+	if exp, act := float64(10), objs["output"]["output"].(map[string]interface{})["latency"]; exp != act {
+		t.Errorf("Wrong timing value: %v != %v", act, exp)
+	}
+	if _, exists := objs["system"]; !exists {
+		t.Error("Missing system metrics")
+	}
+}
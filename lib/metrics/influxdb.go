@@ -0,0 +1,376 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeInfluxDB] = TypeSpec{
+		constructor: NewInfluxDB,
+		description: `
+Push metrics to [InfluxDB](https://www.influxdata.com/) using the
+[line protocol](https://docs.influxdata.com/influxdb/v1.8/write_protocols/line_protocol_tutorial/)
+over either HTTP or UDP.
+
+The ` + "`url`" + ` field determines the protocol used, where an ` + "`http`" + `
+or ` + "`https`" + ` scheme writes batches of metrics to the HTTP API and a
+` + "`udp`" + ` scheme (e.g. ` + "`udp://localhost:8089`" + `) writes them as
+datagrams.
+
+When writing over HTTP to InfluxDB 1.x the ` + "`db`" + ` field selects the
+database to write to, and optionally ` + "`username`" + ` and ` + "`password`" + `
+can be set for authentication. When the ` + "`bucket`" + ` field is set metrics are
+instead written to the InfluxDB 2.x API using the ` + "`org`" + ` and
+` + "`token`" + ` fields.
+
+Each metric is written as a measurement named by its path (with the
+` + "`prefix`" + `), with metric labels as well as the static ` + "`tags`" + `
+added as tags. Counters and gauges are written with a single field
+` + "`value`" + `, and timers are written with the fields ` + "`count`" + `,
+` + "`sum`" + `, ` + "`min`" + `, ` + "`max`" + `, ` + "`mean`" + `, ` + "`p50`" + `,
+` + "`p90`" + ` and ` + "`p99`" + ` summarising the timings (in nanoseconds)
+recorded during each flush period.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// InfluxDBConfig is config for the InfluxDB metrics type.
+type InfluxDBConfig struct {
+	URL         string            `json:"url" yaml:"url"`
+	DB          string            `json:"db" yaml:"db"`
+	Username    string            `json:"username" yaml:"username"`
+	Password    string            `json:"password" yaml:"password"`
+	Org         string            `json:"org" yaml:"org"`
+	Bucket      string            `json:"bucket" yaml:"bucket"`
+	Token       string            `json:"token" yaml:"token"`
+	Prefix      string            `json:"prefix" yaml:"prefix"`
+	Tags        map[string]string `json:"tags" yaml:"tags"`
+	FlushPeriod string            `json:"flush_period" yaml:"flush_period"`
+	Timeout     string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewInfluxDBConfig creates an InfluxDBConfig struct with default values.
+func NewInfluxDBConfig() InfluxDBConfig {
+	return InfluxDBConfig{
+		URL:         "http://localhost:8086",
+		DB:          "benthos",
+		Username:    "",
+		Password:    "",
+		Org:         "",
+		Bucket:      "",
+		Token:       "",
+		Prefix:      "benthos",
+		Tags:        map[string]string{},
+		FlushPeriod: "10s",
+		Timeout:     "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// influxDBMaxDatagramSize is the maximum size of each UDP datagram written.
+const influxDBMaxDatagramSize = 1400
+
+// InfluxDB is a stats object that periodically pushes metrics to InfluxDB.
+type InfluxDB struct {
+	*snapshotStore
+
+	config    InfluxDBConfig
+	prefix    string
+	tagNames  []string
+	writeURL  string
+	udpConn   net.Conn
+	client    *http.Client
+	log       log.Modular
+	closeOnce sync.Once
+
+	closedChan chan struct{}
+	doneChan   chan struct{}
+	flushChan  chan chan struct{}
+}
+
+// NewInfluxDB creates and returns a new InfluxDB object.
+func NewInfluxDB(config Config, opts ...func(Type)) (Type, error) {
+	i, err := newInfluxDB(config.InfluxDB, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func newInfluxDB(conf InfluxDBConfig, opts ...func(Type)) (*InfluxDB, error) {
+	flushPeriod, err := time.ParseDuration(conf.FlushPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush period: %v", err)
+	}
+	if flushPeriod <= 0 {
+		return nil, fmt.Errorf("flush period must be greater than zero: %v", conf.FlushPeriod)
+	}
+
+	i := &InfluxDB{
+		snapshotStore: newSnapshotStore(),
+		config:        conf,
+		prefix:        conf.Prefix,
+		log:           log.Noop(),
+		closedChan:    make(chan struct{}),
+		doneChan:      make(chan struct{}),
+		flushChan:     make(chan chan struct{}),
+	}
+	if len(i.prefix) > 0 && i.prefix[len(i.prefix)-1] != '.' {
+		i.prefix = i.prefix + "."
+	}
+	i.tagNames = sortedKeys(conf.Tags)
+
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if i.writeURL, err = influxDBWriteURL(*u, conf); err != nil {
+			return nil, err
+		}
+		timeout, err := time.ParseDuration(conf.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timeout: %v", err)
+		}
+		i.client = &http.Client{Timeout: timeout}
+	case "udp":
+		if i.udpConn, err = net.Dial("udp", u.Host); err != nil {
+			return nil, fmt.Errorf("failed to dial udp address: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported url scheme: %v", u.Scheme)
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	go i.loop(flushPeriod)
+	return i, nil
+}
+
+func influxDBWriteURL(u url.URL, conf InfluxDBConfig) (string, error) {
+	query := url.Values{}
+	if len(conf.Bucket) > 0 {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+		query.Set("org", conf.Org)
+		query.Set("bucket", conf.Bucket)
+	} else if len(conf.DB) > 0 {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/write"
+		query.Set("db", conf.DB)
+	} else {
+		return "", fmt.Errorf("either a db or a bucket must be specified")
+	}
+	query.Set("precision", "ns")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+//------------------------------------------------------------------------------
+
+func (i *InfluxDB) loop(flushPeriod time.Duration) {
+	defer close(i.doneChan)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			i.flush()
+		case done := <-i.flushChan:
+			i.flush()
+			close(done)
+		case <-i.closedChan:
+			i.flush()
+			return
+		}
+	}
+}
+
+var (
+	influxDBMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", `\n`)
+	influxDBTagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+)
+
+func (i *InfluxDB) writeLine(buf *bytes.Buffer, m metricSnapshot, fields string, ts string) {
+	buf.WriteString(influxDBMeasurementEscaper.Replace(i.prefix + m.Path))
+
+	tags := m.Labels()
+	for _, k := range i.tagNames {
+		if _, exists := tags[k]; !exists {
+			tags[k] = i.config.Tags[k]
+		}
+	}
+	for _, k := range sortedKeys(tags) {
+		if len(tags[k]) == 0 {
+			continue
+		}
+		buf.WriteByte(',')
+		buf.WriteString(influxDBTagEscaper.Replace(k))
+		buf.WriteByte('=')
+		buf.WriteString(influxDBTagEscaper.Replace(tags[k]))
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(fields)
+	buf.WriteByte(' ')
+	buf.WriteString(ts)
+	buf.WriteByte('\n')
+}
+
+// lines returns a snapshot of all metrics as line protocol lines.
+func (i *InfluxDB) lines(t time.Time) []string {
+	counters, gauges, timers := i.snapshot()
+	ts := strconv.FormatInt(t.UnixNano(), 10)
+
+	var lines []string
+	var buf bytes.Buffer
+	for _, list := range [][]metricSnapshot{counters, gauges} {
+		for _, m := range list {
+			buf.Reset()
+			i.writeLine(&buf, m, "value="+strconv.FormatInt(m.Value, 10)+"i", ts)
+			lines = append(lines, buf.String())
+		}
+	}
+	for _, m := range timers {
+		if m.Timing.Count == 0 {
+			continue
+		}
+		buf.Reset()
+		i.writeLine(&buf, m, fmt.Sprintf(
+			"count=%di,sum=%di,min=%di,max=%di,mean=%v,p50=%di,p90=%di,p99=%di",
+			m.Timing.Count, m.Timing.Sum, m.Timing.Min, m.Timing.Max,
+			strconv.FormatFloat(m.Timing.Mean(), 'f', -1, 64),
+			m.Timing.Percentile(0.5), m.Timing.Percentile(0.9), m.Timing.Percentile(0.99),
+		), ts)
+		lines = append(lines, buf.String())
+	}
+	return lines
+}
+
+func (i *InfluxDB) flush() {
+	lines := i.lines(time.Now())
+	if len(lines) == 0 {
+		return
+	}
+
+	var err error
+	if i.udpConn != nil {
+		err = i.writeUDP(lines)
+	} else {
+		err = i.writeHTTP(lines)
+	}
+	if err != nil {
+		i.log.Errorf("Failed to push metrics: %v\n", err)
+	}
+}
+
+func (i *InfluxDB) writeUDP(lines []string) error {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		if buf.Len() > 0 && buf.Len()+len(l) > influxDBMaxDatagramSize {
+			if _, err := i.udpConn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		buf.WriteString(l)
+	}
+	if buf.Len() > 0 {
+		if _, err := i.udpConn.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *InfluxDB) writeHTTP(lines []string) error {
+	req, err := http.NewRequest("POST", i.writeURL, strings.NewReader(strings.Join(lines, "")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if len(i.config.Token) > 0 {
+		req.Header.Set("Authorization", "Token "+i.config.Token)
+	} else if len(i.config.Username) > 0 {
+		req.SetBasicAuth(i.config.Username, i.config.Password)
+	}
+
+	res, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status code %v: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// flushNow sends metrics immediately.
+func (i *InfluxDB) flushNow() bool {
+	requestFlush(i.flushChan, i.doneChan)
+	return true
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (i *InfluxDB) SetLogger(log log.Modular) {
+	i.log = log
+}
+
+// Close stops the InfluxDB object from aggregating metrics and pushes the
+// remaining metrics.
+func (i *InfluxDB) Close() error {
+	i.closeOnce.Do(func() {
+		close(i.closedChan)
+		<-i.doneChan
+		if i.udpConn != nil {
+			i.udpConn.Close()
+		}
+	})
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,170 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInfluxDBInterface(t *testing.T) {
+	o := &InfluxDB{}
+	if Type(o) == nil {
+		t.Errorf("InfluxDB does not satisfy Type interface")
+	}
+}
+
+func TestInfluxDBBadConfig(t *testing.T) {
+	for name, fn := range map[string]func(c *InfluxDBConfig){
+		"bad flush period": func(c *InfluxDBConfig) { c.FlushPeriod = "nope" },
+		"bad scheme":       func(c *InfluxDBConfig) { c.URL = "tcp://localhost:8086" },
+		"no db or bucket":  func(c *InfluxDBConfig) { c.DB = "" },
+	} {
+		conf := NewConfig()
+		conf.Type = TypeInfluxDB
+		fn(&conf.InfluxDB)
+		if _, err := New(conf); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestInfluxDBLines(t *testing.T) {
+	conf := NewInfluxDBConfig()
+	conf.Tags = map[string]string{"host": "foo bar"}
+	conf.FlushPeriod = "1h"
+
+	i, err := newInfluxDB(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+
+	i.GetCounter("a.counter").Incr(3)
+	i.GetGaugeVec("a.gauge", []string{"label"}).With("x,y").Set(10)
+	timer := i.GetTimer("a.timer")
+	for _, v := range []int64{10, 20, 30, 40} {
+		timer.Timing(v)
+	}
+
+	exp := []string{
+		"benthos.a.counter,host=foo\\ bar value=3i 5\n",
+		"benthos.a.gauge,host=foo\\ bar,label=x\\,y value=10i 5\n",
+		"benthos.a.timer,host=foo\\ bar count=4i,sum=100i,min=10i,max=40i,mean=25,p50=20i,p90=40i,p99=40i 5\n",
+	}
+	act := i.lines(time.Unix(0, 5))
+	if len(act) != len(exp) {
+		t.Fatalf("Wrong lines: %q != %q", act, exp)
+	}
+	for j := range exp {
+		if exp[j] != act[j] {
+			t.Errorf("Wrong line %v: %q != %q", j, act[j], exp[j])
+		}
+	}
+
+	// Timers without new samples are not written again.
+	if act = i.lines(time.Unix(0, 5)); len(act) != 2 {
+		t.Errorf("Wrong count of lines: %q", act)
+	}
+}
+
+func TestInfluxDBHTTP(t *testing.T) {
+	var reqMut sync.Mutex
+	var reqURL, reqAuth, reqBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reqMut.Lock()
+		reqURL = r.URL.String()
+		reqAuth = r.Header.Get("Authorization")
+		reqBody = string(body)
+		reqMut.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeInfluxDB
+	conf.InfluxDB.URL = server.URL
+	conf.InfluxDB.Bucket = "foo"
+	conf.InfluxDB.Org = "bar"
+	conf.InfluxDB.Token = "baz"
+	conf.InfluxDB.FlushPeriod = "1h"
+
+	i, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.GetCounter("a.counter").Incr(1)
+	if err = i.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+	if exp, act := "/api/v2/write?bucket=foo&org=bar&precision=ns", reqURL; exp != act {
+		t.Errorf("Wrong url: %v != %v", act, exp)
+	}
+	if exp, act := "Token baz", reqAuth; exp != act {
+		t.Errorf("Wrong auth: %v != %v", act, exp)
+	}
+	if !strings.HasPrefix(reqBody, "benthos.a.counter value=1i ") {
+		t.Errorf("Wrong body: %v", reqBody)
+	}
+}
+
+func TestInfluxDBUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeInfluxDB
+	conf.InfluxDB.URL = "udp://" + conn.LocalAddr().String()
+	conf.InfluxDB.Prefix = ""
+	conf.InfluxDB.FlushPeriod = "1h"
+
+	i, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.GetGauge("a.gauge").Set(5)
+	if err = i.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	buf := make([]byte, influxDBMaxDatagramSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act := string(buf[:n]); !strings.HasPrefix(act, "a.gauge value=5i ") {
+		t.Errorf("Wrong datagram: %v", act)
+	}
+}
@@ -21,6 +21,8 @@
 package metrics
 
 import (
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -29,11 +31,83 @@ import (
 
 //------------------------------------------------------------------------------
 
+// localReservoirSize is the number of most recent timings retained by each
+// timer of a Local aggregator in order to estimate percentiles.
+const localReservoirSize = 512
+
+// localReservoir is a sliding window of the most recent timings of a timer.
+type localReservoir struct {
+	samples []int64
+	next    int
+	mut     sync.Mutex
+}
+
+func (r *localReservoir) add(delta int64) {
+	r.mut.Lock()
+	if len(r.samples) < localReservoirSize {
+		r.samples = append(r.samples, delta)
+	} else {
+		r.samples[r.next] = delta
+		r.next = (r.next + 1) % localReservoirSize
+	}
+	r.mut.Unlock()
+}
+
+// percentiles returns estimated percentiles of the timings currently within
+// the window before optionally emptying it.
+func (r *localReservoir) percentiles(reset bool) LocalTimingPercentiles {
+	r.mut.Lock()
+	sorted := make([]int64, len(r.samples))
+	copy(sorted, r.samples)
+	if reset {
+		r.samples = r.samples[:0]
+		r.next = 0
+	}
+	r.mut.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	summary := timingSummary{sorted: sorted}
+	return LocalTimingPercentiles{
+		P50: summary.Percentile(0.5),
+		P90: summary.Percentile(0.9),
+		P99: summary.Percentile(0.99),
+	}
+}
+
+// LocalTimingPercentiles contains percentiles of the most recent timings of a
+// timer, estimated from a sliding window of samples.
+type LocalTimingPercentiles struct {
+	P50 int64
+	P90 int64
+	P99 int64
+}
+
+// withTimingPercentiles returns a copy of a map of timings where the estimated
+// percentiles of each timer are added with the keys <path>_p50, <path>_p90 and
+// <path>_p99.
+func withTimingPercentiles(timings map[string]int64, percentiles map[string]LocalTimingPercentiles) map[string]int64 {
+	res := make(map[string]int64, len(timings)*4)
+	for k, v := range timings {
+		res[k] = v
+		if p, exists := percentiles[k]; exists {
+			res[k+"_p50"] = p.P50
+			res[k+"_p90"] = p.P90
+			res[k+"_p99"] = p.P99
+		}
+	}
+	return res
+}
+
+//------------------------------------------------------------------------------
+
 // LocalStat is a representation of a single metric stat. Interactions with this
 // stat are thread safe.
 type LocalStat struct {
 	Value           *int64
 	labelsAndValues map[string]string
+	reservoir       *localReservoir
 }
 
 // Incr increments a metric by an amount.
@@ -51,6 +125,9 @@ func (l *LocalStat) Decr(count int64) error {
 // Timing sets a timing metric.
 func (l *LocalStat) Timing(delta int64) error {
 	atomic.StoreInt64(l.Value, delta)
+	if l.reservoir != nil {
+		l.reservoir.add(delta)
+	}
 	return nil
 }
 
@@ -67,6 +144,16 @@ func (l *LocalStat) setLabelsAndValues(ls, vs []string) *LocalStat {
 	return l
 }
 
+// LabelsAndValues returns a copy of the label names and values recorded with
+// the stat.
+func (l *LocalStat) LabelsAndValues() map[string]string {
+	labels := make(map[string]string, len(l.labelsAndValues))
+	for k, v := range l.labelsAndValues {
+		labels[k] = v
+	}
+	return labels
+}
+
 // HasLabelWithValue takes a label/value pair and returns true if that
 // combination has been recorded, or false otherwise.
 //
@@ -83,6 +170,64 @@ func newLocalStat(c int64) *LocalStat {
 	}
 }
 
+func newLocalCounter() *LocalStat {
+	return newLocalStat(0)
+}
+
+func newLocalTimer() *LocalStat {
+	st := newLocalStat(0)
+	st.reservoir = &localReservoir{}
+	return st
+}
+
+//------------------------------------------------------------------------------
+
+// localLabelledStat is a stat registered with labels, where updates are
+// applied to both the stat of the specific label values and the aggregate stat
+// of the path.
+type localLabelledStat struct {
+	agg      *LocalStat
+	labelled *LocalStat
+}
+
+// Incr increments a metric by an amount.
+func (l *localLabelledStat) Incr(count int64) error {
+	l.agg.Incr(count)
+	return l.labelled.Incr(count)
+}
+
+// Decr decrements a metric by an amount.
+func (l *localLabelledStat) Decr(count int64) error {
+	l.agg.Decr(count)
+	return l.labelled.Decr(count)
+}
+
+// Timing sets a timing metric.
+func (l *localLabelledStat) Timing(delta int64) error {
+	l.agg.Timing(delta)
+	return l.labelled.Timing(delta)
+}
+
+// Set sets a gauge metric.
+func (l *localLabelledStat) Set(value int64) error {
+	l.agg.Set(value)
+	return l.labelled.Set(value)
+}
+
+// labelKey returns a unique key for a combination of label names and values.
+func labelKey(names, values []string) string {
+	pairs := make([]string, 0, len(names))
+	for i, n := range names {
+		var v string
+		if i < len(values) {
+			v = values[i]
+		}
+		pairs = append(pairs, n+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
 //------------------------------------------------------------------------------
 
 // Local is a metrics aggregator that stores metrics locally.
@@ -90,6 +235,11 @@ type Local struct {
 	flatCounters map[string]*LocalStat
 	flatTimings  map[string]*LocalStat
 
+	counterVecs map[string]map[string]*LocalStat
+	timingVecs  map[string]map[string]*LocalStat
+
+	gaugePaths map[string]struct{}
+
 	sync.Mutex
 }
 
@@ -98,11 +248,55 @@ func NewLocal() *Local {
 	return &Local{
 		flatCounters: make(map[string]*LocalStat),
 		flatTimings:  make(map[string]*LocalStat),
+		counterVecs:  make(map[string]map[string]*LocalStat),
+		timingVecs:   make(map[string]map[string]*LocalStat),
+		gaugePaths:   make(map[string]struct{}),
 	}
 }
 
 //------------------------------------------------------------------------------
 
+// Reset zeroes all counters and timers, including those with labels, and
+// empties the sliding windows used to estimate timing percentiles. Gauges are
+// left untouched as they represent current state rather than a running total.
+func (l *Local) Reset() {
+	l.Lock()
+	for k, st := range l.flatCounters {
+		if _, isGauge := l.gaugePaths[k]; !isGauge {
+			atomic.StoreInt64(st.Value, 0)
+		}
+	}
+	for k, stats := range l.counterVecs {
+		if _, isGauge := l.gaugePaths[k]; isGauge {
+			continue
+		}
+		for _, st := range stats {
+			atomic.StoreInt64(st.Value, 0)
+		}
+	}
+	for _, st := range l.flatTimings {
+		atomic.StoreInt64(st.Value, 0)
+		st.reservoir.percentiles(true)
+	}
+	for _, stats := range l.timingVecs {
+		for _, st := range stats {
+			atomic.StoreInt64(st.Value, 0)
+			if st.reservoir != nil {
+				st.reservoir.percentiles(true)
+			}
+		}
+	}
+	l.Unlock()
+}
+
+// resetNow zeroes all counters and timers.
+func (l *Local) resetNow() bool {
+	l.Reset()
+	return true
+}
+
+//------------------------------------------------------------------------------
+
 // GetCounters returns a map of metric paths to counters.
 func (l *Local) GetCounters() map[string]int64 {
 	return l.getCounters(false)
@@ -167,12 +361,26 @@ func (l *Local) getTimings(reset bool) map[string]int64 {
 		localFlatTimings[k] = atomic.LoadInt64(l.flatTimings[k].Value)
 		if reset {
 			atomic.StoreInt64(l.flatTimings[k].Value, 0)
+			l.flatTimings[k].reservoir.percentiles(true)
 		}
 	}
 	l.Unlock()
 	return localFlatTimings
 }
 
+// GetTimingPercentiles returns a map of metric paths to the estimated
+// percentiles of their most recent timings. The window of timings of each path
+// is emptied when timings are flushed.
+func (l *Local) GetTimingPercentiles() map[string]LocalTimingPercentiles {
+	l.Lock()
+	percentiles := make(map[string]LocalTimingPercentiles, len(l.flatTimings))
+	for k, st := range l.flatTimings {
+		percentiles[k] = st.reservoir.percentiles(false)
+	}
+	l.Unlock()
+	return percentiles
+}
+
 // GetTimingsWithLabels returns a map of metric paths to timers, including
 // labels and values.
 func (l *Local) GetTimingsWithLabels() map[string]LocalStat {
@@ -191,6 +399,103 @@ func (l *Local) GetTimingsWithLabels() map[string]LocalStat {
 	return localFlatTimings
 }
 
+// GetCounterVecs returns a map of metric paths to the counters of each
+// combination of label values registered for the path. Counters registered
+// without labels are not included.
+func (l *Local) GetCounterVecs() map[string][]LocalStat {
+	return l.getVecs(l.counterVecs, false)
+}
+
+// FlushCounterVecs returns a map of metric paths to the counters of each
+// combination of label values registered for the path and then resets the
+// counters to 0.
+func (l *Local) FlushCounterVecs() map[string][]LocalStat {
+	return l.getVecs(l.counterVecs, true)
+}
+
+// GetTimingVecs returns a map of metric paths to the timers of each
+// combination of label values registered for the path. Timers registered
+// without labels are not included.
+func (l *Local) GetTimingVecs() map[string][]LocalStat {
+	return l.getVecs(l.timingVecs, false)
+}
+
+// FlushTimingVecs returns a map of metric paths to the timers of each
+// combination of label values registered for the path and then resets the
+// timers to 0.
+func (l *Local) FlushTimingVecs() map[string][]LocalStat {
+	return l.getVecs(l.timingVecs, true)
+}
+
+// getVecs returns a copy of a map of labelled stats, sorted by their label
+// values, before optionally resetting them.
+func (l *Local) getVecs(vecs map[string]map[string]*LocalStat, reset bool) map[string][]LocalStat {
+	l.Lock()
+	localVecs := make(map[string][]LocalStat, len(vecs))
+	for path, stats := range vecs {
+		keys := make([]string, 0, len(stats))
+		for k := range stats {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pathStats := make([]LocalStat, 0, len(stats))
+		for _, k := range keys {
+			o := stats[k]
+			var cv int64
+			if reset {
+				cv = atomic.SwapInt64(o.Value, 0)
+			} else {
+				cv = atomic.LoadInt64(o.Value)
+			}
+			ls := *newLocalStat(cv)
+			for lk, lv := range o.labelsAndValues {
+				ls.labelsAndValues[lk] = lv
+			}
+			pathStats = append(pathStats, ls)
+		}
+		localVecs[path] = pathStats
+	}
+	l.Unlock()
+	return localVecs
+}
+
+// getLabelled returns a stat for a path and combination of label values,
+// creating both the aggregate stat for the path and the stat for the label
+// values if they do not yet exist.
+func (l *Local) getLabelled(
+	flat map[string]*LocalStat,
+	vecs map[string]map[string]*LocalStat,
+	path string, names, values []string,
+	newAgg func() *LocalStat,
+) *localLabelledStat {
+	l.Lock()
+	defer l.Unlock()
+
+	agg, exists := flat[path]
+	if !exists {
+		agg = newAgg()
+		flat[path] = agg
+	}
+	agg.setLabelsAndValues(names, values)
+
+	pathVecs, exists := vecs[path]
+	if !exists {
+		pathVecs = map[string]*LocalStat{}
+		vecs[path] = pathVecs
+	}
+	key := labelKey(names, values)
+	st, exists := pathVecs[key]
+	if !exists {
+		st = newLocalStat(0).setLabelsAndValues(names, values)
+		pathVecs[key] = st
+	}
+	return &localLabelledStat{
+		agg:      agg,
+		labelled: st,
+	}
+}
+
 //------------------------------------------------------------------------------
 
 // GetCounter returns a stat counter object for a path.
@@ -211,7 +516,7 @@ func (l *Local) GetTimer(path string) StatTimer {
 	l.Lock()
 	st, exists := l.flatTimings[path]
 	if !exists {
-		st = newLocalStat(0)
+		st = newLocalTimer()
 		l.flatTimings[path] = st
 	}
 	l.Unlock()
@@ -227,32 +532,39 @@ func (l *Local) GetGauge(path string) StatGauge {
 		st = newLocalStat(0)
 		l.flatCounters[path] = st
 	}
+	l.gaugePaths[path] = struct{}{}
 	l.Unlock()
 
 	return st
 }
 
 // GetCounterVec returns a stat counter object for a path and records the
-// labels and values.
+// labels and values. Each combination of label values is tracked separately,
+// and updates to any combination are also applied to the counter of the path.
 func (l *Local) GetCounterVec(path string, k []string) StatCounterVec {
 	return fakeCounterVec(func(v []string) StatCounter {
-		return l.GetCounter(path).(*LocalStat).setLabelsAndValues(k, v)
+		return l.getLabelled(l.flatCounters, l.counterVecs, path, k, v, newLocalCounter)
 	})
 }
 
-// GetTimerVec returns a stat timer object for a path with the labels
-// and values.
+// GetTimerVec returns a stat timer object for a path with the labels and
+// values. Each combination of label values is tracked separately, and timings
+// of any combination are also applied to the timer of the path.
 func (l *Local) GetTimerVec(path string, k []string) StatTimerVec {
 	return fakeTimerVec(func(v []string) StatTimer {
-		return l.GetTimer(path).(*LocalStat).setLabelsAndValues(k, v)
+		return l.getLabelled(l.flatTimings, l.timingVecs, path, k, v, newLocalTimer)
 	})
 }
 
-// GetGaugeVec returns a stat timer object for a path with the labels
-// discarded.
+// GetGaugeVec returns a stat gauge object for a path with the labels and
+// values. Each combination of label values is tracked separately, and updates
+// to any combination are also applied to the gauge of the path.
 func (l *Local) GetGaugeVec(path string, k []string) StatGaugeVec {
+	l.Lock()
+	l.gaugePaths[path] = struct{}{}
+	l.Unlock()
 	return fakeGaugeVec(func(v []string) StatGauge {
-		return l.GetGauge(path).(*LocalStat).setLabelsAndValues(k, v)
+		return l.getLabelled(l.flatCounters, l.counterVecs, path, k, v, newLocalCounter)
 	})
 }
 
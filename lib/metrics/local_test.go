@@ -1,6 +1,9 @@
 package metrics
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestCounter(t *testing.T) {
 	path := "testing.label"
@@ -218,3 +221,125 @@ func TestGaugeWithLabelsAndValues(t *testing.T) {
 		t.Fatal("counter has label with value unknown")
 	}
 }
+
+func TestCounterVecsSeparateLabelValues(t *testing.T) {
+	path := "testing.label"
+	local := NewLocal()
+	counter := local.GetCounterVec(path, []string{"tested"})
+
+	counter.With("foo").Incr(1)
+	counter.With("bar").Incr(2)
+	counter.With("foo").Incr(3)
+
+	if exp, act := int64(6), local.GetCounters()[path]; exp != act {
+		t.Errorf("Wrong aggregate counter value: %v != %v", act, exp)
+	}
+
+	vecs := local.GetCounterVecs()[path]
+	if exp, act := 2, len(vecs); exp != act {
+		t.Fatalf("Wrong count of labelled counters: %v != %v", act, exp)
+	}
+	if !vecs[0].HasLabelWithValue("tested", "bar") {
+		t.Errorf("Wrong labels for first counter: %v", vecs[0].LabelsAndValues())
+	}
+	if exp, act := int64(2), *vecs[0].Value; exp != act {
+		t.Errorf("Wrong first counter value: %v != %v", act, exp)
+	}
+	if !vecs[1].HasLabelWithValue("tested", "foo") {
+		t.Errorf("Wrong labels for second counter: %v", vecs[1].LabelsAndValues())
+	}
+	if exp, act := int64(4), *vecs[1].Value; exp != act {
+		t.Errorf("Wrong second counter value: %v != %v", act, exp)
+	}
+
+	local.FlushCounterVecs()
+	for _, v := range local.GetCounterVecs()[path] {
+		if exp, act := int64(0), *v.Value; exp != act {
+			t.Errorf("Wrong flushed counter value: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestTimingVecsSeparateLabelValues(t *testing.T) {
+	path := "testing.label"
+	local := NewLocal()
+	timer := local.GetTimerVec(path, []string{"tested"})
+
+	timer.With("foo").Timing(10)
+	timer.With("bar").Timing(20)
+
+	vecs := local.GetTimingVecs()[path]
+	if exp, act := 2, len(vecs); exp != act {
+		t.Fatalf("Wrong count of labelled timers: %v != %v", act, exp)
+	}
+	if exp, act := int64(20), *vecs[0].Value; exp != act {
+		t.Errorf("Wrong first timer value: %v != %v", act, exp)
+	}
+	if exp, act := int64(10), *vecs[1].Value; exp != act {
+		t.Errorf("Wrong second timer value: %v != %v", act, exp)
+	}
+	if _, exists := local.GetCounterVecs()[path]; exists {
+		t.Error("Timers should not appear in counter vecs")
+	}
+}
+
+func TestLocalTimingPercentiles(t *testing.T) {
+	local := NewLocal()
+
+	timer := local.GetTimer("foo")
+	for i := int64(0); i < localReservoirSize+100; i++ {
+		timer.Timing(i)
+	}
+	local.GetTimer("bar")
+
+	exp := map[string]LocalTimingPercentiles{
+		"foo": {P50: 355, P90: 560, P99: 606},
+		"bar": {},
+	}
+	if act := local.GetTimingPercentiles(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong percentiles: %v != %v", act, exp)
+	}
+
+	local.FlushTimings()
+	exp["foo"] = LocalTimingPercentiles{}
+	if act := local.GetTimingPercentiles(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong percentiles after flush: %v != %v", act, exp)
+	}
+}
+
+func TestLocalReset(t *testing.T) {
+	local := NewLocal()
+
+	local.GetCounter("foo").Incr(5)
+	local.GetCounterVec("bar", []string{"baz"}).With("qux").Incr(3)
+	local.GetGauge("gauge").Set(10)
+	local.GetGaugeVec("gauges", []string{"baz"}).With("qux").Set(7)
+	local.GetTimer("timer").Timing(100)
+
+	local.Reset()
+
+	counters := local.GetCounters()
+	for k, exp := range map[string]int64{
+		"foo":    0,
+		"bar":    0,
+		"gauge":  10,
+		"gauges": 7,
+	} {
+		if act := counters[k]; exp != act {
+			t.Errorf("Wrong value of %v: %v != %v", k, act, exp)
+		}
+	}
+	vecs := local.GetCounterVecs()
+	if act := *vecs["bar"][0].Value; act != 0 {
+		t.Errorf("Wrong labelled counter value: %v != 0", act)
+	}
+	if act := *vecs["gauges"][0].Value; act != 7 {
+		t.Errorf("Wrong labelled gauge value: %v != 7", act)
+	}
+	if act := local.GetTimings()["timer"]; act != 0 {
+		t.Errorf("Wrong timer value: %v != 0", act)
+	}
+	if act := local.GetTimingPercentiles()["timer"].P99; act != 0 {
+		t.Errorf("Wrong timer percentile: %v != 0", act)
+	}
+}
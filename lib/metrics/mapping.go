@@ -0,0 +1,333 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+)
+
+//------------------------------------------------------------------------------
+
+// MappingRuleConfig contains config fields for a rule that renames, drops or
+// adds labels to metrics with paths that match a regular expression pattern.
+type MappingRuleConfig struct {
+	Pattern string            `json:"pattern" yaml:"pattern"`
+	Value   string            `json:"value" yaml:"value"`
+	Labels  map[string]string `json:"to_label" yaml:"to_label"`
+	Drop    bool              `json:"drop" yaml:"drop"`
+}
+
+// NewMappingRuleConfig returns a MappingRuleConfig with default values.
+func NewMappingRuleConfig() MappingRuleConfig {
+	return MappingRuleConfig{
+		Pattern: "",
+		Value:   "",
+		Labels:  map[string]string{},
+		Drop:    false,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type mappingRule struct {
+	expression *regexp.Regexp
+	value      string
+	labels     map[string]string
+	drop       bool
+}
+
+// counterTrigger is implemented by types that trigger behaviour from the
+// counters of specific paths, and which therefore need to observe counters by
+// their path before any mapping rules were applied.
+type counterTrigger interface {
+	triggerCounter(path string, c StatCounter) StatCounter
+}
+
+// mapping is a metrics type that wraps another and rewrites the paths and
+// labels of metrics as they are registered, according to a list of rules.
+type mapping struct {
+	rules        []mappingRule
+	staticLabels map[string]string
+	s            Type
+	log          log.Modular
+}
+
+// mappingWithHandlerFunc is a mapping with a child that exposes an HTTP
+// endpoint.
+type mappingWithHandlerFunc struct {
+	*mapping
+	h WithHandlerFunc
+}
+
+// HandlerFunc returns the http.HandlerFunc of the child type.
+func (m *mappingWithHandlerFunc) HandlerFunc() http.HandlerFunc {
+	return m.h.HandlerFunc()
+}
+
+// WithMapping wraps a metrics type with a list of mapping rules that are
+// applied to each metric as it is registered. If the list of rules is empty
+// the type is returned unchanged.
+func WithMapping(t Type, rules []MappingRuleConfig) (Type, error) {
+	return newMapping(t, rules, nil)
+}
+
+// newMapping wraps a metrics type with a list of mapping rules and a map of
+// static labels that are added to all metrics. If both are empty the type is
+// returned unchanged.
+func newMapping(t Type, rules []MappingRuleConfig, staticLabels map[string]string) (Type, error) {
+	if len(rules) == 0 && len(staticLabels) == 0 {
+		return t, nil
+	}
+	m := &mapping{
+		staticLabels: staticLabels,
+		s:            t,
+		log:          log.Noop(),
+	}
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: '%s': %v", r.Pattern, err)
+		}
+		m.rules = append(m.rules, mappingRule{
+			expression: re,
+			value:      r.Value,
+			labels:     r.Labels,
+			drop:       r.Drop,
+		})
+	}
+	if h, ok := t.(WithHandlerFunc); ok {
+		return &mappingWithHandlerFunc{
+			mapping: m,
+			h:       h,
+		}, nil
+	}
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
+
+// mapPath applies each rule to a path in order and returns the resulting path
+// and any labels to add to the metric, or false if the metric is dropped.
+func (m *mapping) mapPath(path string) (string, []string, []string, bool) {
+	labels := make(map[string]string, len(m.staticLabels))
+	for k, v := range m.staticLabels {
+		labels[k] = v
+	}
+	for _, r := range m.rules {
+		if !r.expression.MatchString(path) {
+			continue
+		}
+		if r.drop {
+			m.log.Tracef("Dropped metric path '%v' as per regexp '%v'\n", path, r.expression.String())
+			return "", nil, nil, false
+		}
+		if len(r.labels) > 0 {
+			// Extract only the matching segment of the path (left-most)
+			leftPath := r.expression.FindString(path)
+			for k, v := range r.labels {
+				labels[k] = r.expression.ReplaceAllString(leftPath, v)
+			}
+		}
+		if len(r.value) > 0 {
+			newPath := r.expression.ReplaceAllString(path, r.value)
+			m.log.Tracef("Mapped metric path '%v' to '%v' as per regexp '%v'\n", path, newPath, r.expression.String())
+			path = newPath
+		}
+	}
+
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names))
+	for _, k := range names {
+		values = append(values, labels[k])
+	}
+	return path, names, values, true
+}
+
+// withoutLabels removes any label names and their values that are already
+// registered with a metric, as those take precedence.
+func withoutLabels(names, values, registered []string) ([]string, []string) {
+	var resNames, resValues []string
+	for i, n := range names {
+		exists := false
+		for _, r := range registered {
+			if r == n {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			resNames = append(resNames, n)
+			resValues = append(resValues, values[i])
+		}
+	}
+	return resNames, resValues
+}
+
+//------------------------------------------------------------------------------
+
+// triggerCounter passes a mapped counter to the child type along with its
+// original path, if the child triggers behaviour from counters.
+func (m *mapping) triggerCounter(path string, c StatCounter) StatCounter {
+	if t, ok := m.s.(counterTrigger); ok {
+		return t.triggerCounter(path, c)
+	}
+	return c
+}
+
+// GetCounter returns a stat counter object for a path.
+func (m *mapping) GetCounter(path string) StatCounter {
+	mpath, names, values, ok := m.mapPath(path)
+	if !ok {
+		return m.triggerCounter(path, DudStat{})
+	}
+	if len(names) == 0 {
+		return m.triggerCounter(path, m.s.GetCounter(mpath))
+	}
+	return m.triggerCounter(path, m.s.GetCounterVec(mpath, names).With(values...))
+}
+
+// GetCounterVec returns a stat counter object for a path with the labels
+// and values.
+func (m *mapping) GetCounterVec(path string, n []string) StatCounterVec {
+	mpath, names, values, ok := m.mapPath(path)
+	if !ok {
+		return fakeCounterVec(func([]string) StatCounter {
+			return m.triggerCounter(path, DudStat{})
+		})
+	}
+	if names, values = withoutLabels(names, values, n); len(names) == 0 {
+		vec := m.s.GetCounterVec(mpath, n)
+		return fakeCounterVec(func(v []string) StatCounter {
+			return m.triggerCounter(path, vec.With(v...))
+		})
+	}
+	vec := m.s.GetCounterVec(mpath, append(append([]string{}, n...), names...))
+	return fakeCounterVec(func(v []string) StatCounter {
+		return m.triggerCounter(path, vec.With(append(append([]string{}, v...), values...)...))
+	})
+}
+
+// GetTimer returns a stat timer object for a path.
+func (m *mapping) GetTimer(path string) StatTimer {
+	mpath, names, values, ok := m.mapPath(path)
+	if !ok {
+		return DudStat{}
+	}
+	if len(names) == 0 {
+		return m.s.GetTimer(mpath)
+	}
+	return m.s.GetTimerVec(mpath, names).With(values...)
+}
+
+// GetTimerVec returns a stat timer object for a path with the labels
+// and values.
+func (m *mapping) GetTimerVec(path string, n []string) StatTimerVec {
+	mpath, names, values, ok := m.mapPath(path)
+	if !ok {
+		return fakeTimerVec(func([]string) StatTimer {
+			return DudStat{}
+		})
+	}
+	if names, values = withoutLabels(names, values, n); len(names) == 0 {
+		return m.s.GetTimerVec(mpath, n)
+	}
+	vec := m.s.GetTimerVec(mpath, append(append([]string{}, n...), names...))
+	return fakeTimerVec(func(v []string) StatTimer {
+		return vec.With(append(append([]string{}, v...), values...)...)
+	})
+}
+
+// GetGauge returns a stat gauge object for a path.
+func (m *mapping) GetGauge(path string) StatGauge {
+	mpath, names, values, ok := m.mapPath(path)
+	if !ok {
+		return DudStat{}
+	}
+	if len(names) == 0 {
+		return m.s.GetGauge(mpath)
+	}
+	return m.s.GetGaugeVec(mpath, names).With(values...)
+}
+
+// GetGaugeVec returns a stat gauge object for a path with the labels
+// and values.
+func (m *mapping) GetGaugeVec(path string, n []string) StatGaugeVec {
+	mpath, names, values, ok := m.mapPath(path)
+	if !ok {
+		return fakeGaugeVec(func([]string) StatGauge {
+			return DudStat{}
+		})
+	}
+	if names, values = withoutLabels(names, values, n); len(names) == 0 {
+		return m.s.GetGaugeVec(mpath, n)
+	}
+	vec := m.s.GetGaugeVec(mpath, append(append([]string{}, n...), names...))
+	return fakeGaugeVec(func(v []string) StatGauge {
+		return vec.With(append(append([]string{}, v...), values...)...)
+	})
+}
+
+// jsonHandlerFunc returns the grouped JSON metrics handler of the child type,
+// if it has one.
+func (m *mapping) jsonHandlerFunc() (http.HandlerFunc, bool) {
+	return JSONHandlerFunc(m.s)
+}
+
+// flushNow flushes the metrics of the child type, if it pushes metrics.
+func (m *mapping) flushNow() bool {
+	return Flush(m.s)
+}
+
+// resetNow resets the metrics of the child type, if it aggregates metrics
+// locally.
+func (m *mapping) resetNow() bool {
+	return Reset(m.s)
+}
+
+// reportsSystemMetrics returns true if the child type collects system metrics
+// itself.
+func (m *mapping) reportsSystemMetrics() bool {
+	r, ok := m.s.(systemMetricsReporter)
+	return ok && r.reportsSystemMetrics()
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (m *mapping) SetLogger(log log.Modular) {
+	m.log = log.NewModule(".mapping")
+	m.s.SetLogger(log)
+}
+
+// Close stops the underlying metrics type from aggregating metrics and cleans
+// up resources.
+func (m *mapping) Close() error {
+	return m.s.Close()
+}
+
+//------------------------------------------------------------------------------
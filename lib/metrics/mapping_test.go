@@ -0,0 +1,233 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func TestMappingEmptyRules(t *testing.T) {
+	child := NewLocal()
+	m, err := WithMapping(child, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != Type(child) {
+		t.Error("Expected child to be returned unchanged")
+	}
+}
+
+func TestMappingBadPattern(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeHTTPServer
+	rule := NewMappingRuleConfig()
+	rule.Pattern = "("
+	conf.Mapping = append(conf.Mapping, rule)
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from bad pattern")
+	}
+}
+
+func TestMappingRenameAndDrop(t *testing.T) {
+	child := NewLocal()
+
+	renameRule := NewMappingRuleConfig()
+	renameRule.Pattern = `^foo\.([a-z]+)$`
+	renameRule.Value = "bar.$1"
+
+	dropRule := NewMappingRuleConfig()
+	dropRule.Pattern = `^baz\.`
+	dropRule.Drop = true
+
+	m, err := WithMapping(child, []MappingRuleConfig{renameRule, dropRule})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.GetCounter("foo.a").Incr(1)
+	m.GetGauge("foo.b").Set(2)
+	m.GetTimer("foo.c").Timing(3)
+	m.GetCounter("baz.a").Incr(4)
+	m.GetCounterVec("baz.b", []string{"label"}).With("value").Incr(5)
+	m.GetCounter("qux").Incr(6)
+
+	expCounters := map[string]int64{
+		"bar.a": 1,
+		"bar.b": 2,
+		"qux":   6,
+	}
+	counters := child.GetCounters()
+	if len(counters) != len(expCounters) {
+		t.Errorf("Wrong counters: %v != %v", counters, expCounters)
+	}
+	for k, v := range expCounters {
+		if act := counters[k]; act != v {
+			t.Errorf("Wrong value for counter '%v': %v != %v", k, act, v)
+		}
+	}
+	if exp, act := int64(3), child.GetTimings()["bar.c"]; exp != act {
+		t.Errorf("Wrong timing: %v != %v", act, exp)
+	}
+}
+
+func TestMappingStdoutGrouping(t *testing.T) {
+	buf := &syncBuffer{}
+	child, err := newStdout(NewConfig(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renameRule := NewMappingRuleConfig()
+	renameRule.Pattern = `^pipeline\.processor\.0\.`
+	renameRule.Value = "pipeline.processor.1."
+
+	dropRule := NewMappingRuleConfig()
+	dropRule.Pattern = `^input\.`
+	dropRule.Drop = true
+
+	m, err := WithMapping(child, []MappingRuleConfig{renameRule, dropRule})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.GetCounter("pipeline.processor.0.count").Incr(2)
+	m.GetCounter("input.count").Incr(3)
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	objs := parseStdoutLines(t, buf.Lines())
+	if exp, act := float64(2), objs["pipeline.processor.1"]["count"]; exp != act {
+		t.Errorf("Wrong processor count: %v != %v", act, exp)
+	}
+	if _, exists := objs["pipeline.processor.0"]; exists {
+		t.Error("Expected original path not to be grouped")
+	}
+	if _, exists := objs["input"]; exists {
+		t.Error("Expected dropped metric not to be written")
+	}
+}
+
+func TestMappingToLabel(t *testing.T) {
+	child := NewLocal()
+
+	rule := NewMappingRuleConfig()
+	rule.Pattern = `^processor\.([0-9]+)\.(.*)`
+	rule.Value = "processor.$2"
+	rule.Labels = map[string]string{
+		"index": "$1",
+	}
+
+	m, err := WithMapping(child, []MappingRuleConfig{rule})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.GetCounter("processor.0.count").Incr(1)
+	m.GetCounterVec("processor.1.count", []string{"foo"}).With("bar").Incr(2)
+
+	vecs := child.GetCounterVecs()["processor.count"]
+	if exp, act := 2, len(vecs); exp != act {
+		t.Fatalf("Wrong count of labelled counters: %v != %v", act, exp)
+	}
+	if !vecs[0].HasLabelWithValue("index", "1") || !vecs[0].HasLabelWithValue("foo", "bar") {
+		t.Errorf("Wrong labels: %v", vecs[0].LabelsAndValues())
+	}
+	if exp, act := int64(2), *vecs[0].Value; exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+	if !vecs[1].HasLabelWithValue("index", "0") {
+		t.Errorf("Wrong labels: %v", vecs[1].LabelsAndValues())
+	}
+	if exp, act := int64(1), *vecs[1].Value; exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+}
+
+func TestMappingHandlerFunc(t *testing.T) {
+	rule := NewMappingRuleConfig()
+	rule.Pattern = "foo"
+
+	conf := NewConfig()
+	conf.Type = TypeHTTPServer
+	conf.Mapping = []MappingRuleConfig{rule}
+
+	m, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(WithHandlerFunc); !ok {
+		t.Error("Expected mapping of http_server type to expose handler func")
+	}
+
+	l, err := WithMapping(NewLocal(), conf.Mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.(WithHandlerFunc); ok {
+		t.Error("Expected mapping of local type to not expose handler func")
+	}
+}
+
+func TestMappingStaticLabels(t *testing.T) {
+	child := NewLocal()
+
+	rule := NewMappingRuleConfig()
+	rule.Pattern = `^output\.`
+	rule.Labels = map[string]string{
+		"region": "overridden",
+	}
+
+	m, err := newMapping(child, []MappingRuleConfig{rule}, map[string]string{
+		"host":   "foo",
+		"region": "bar",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.GetCounter("input.count").Incr(1)
+	m.GetTimer("output.latency").Timing(2)
+	m.GetCounterVec("input.labelled", []string{"host"}).With("baz").Incr(3)
+
+	vecs := child.GetCounterVecs()
+	if stats := vecs["input.count"]; len(stats) != 1 ||
+		!stats[0].HasLabelWithValue("host", "foo") ||
+		!stats[0].HasLabelWithValue("region", "bar") {
+		t.Errorf("Wrong labels of counter: %v", stats)
+	}
+	if stats := child.GetTimingVecs()["output.latency"]; len(stats) != 1 ||
+		!stats[0].HasLabelWithValue("host", "foo") ||
+		!stats[0].HasLabelWithValue("region", "overridden") {
+		t.Errorf("Wrong labels of timer: %v", stats)
+	}
+
+	// Labels registered with the metric take precedence.
+	if stats := vecs["input.labelled"]; len(stats) != 1 ||
+		!stats[0].HasLabelWithValue("host", "baz") ||
+		!stats[0].HasLabelWithValue("region", "bar") {
+		t.Errorf("Wrong labels of labelled counter: %v", stats)
+	}
+}
+
+//------------------------------------------------------------------------------
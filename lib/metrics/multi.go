@@ -0,0 +1,383 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeMulti] = TypeSpec{
+		constructor: NewMulti,
+		description: `
+Sends metrics to multiple child metrics types, where each counter, timer and
+gauge update is sent to all of them. This is useful when metrics are needed in
+more than one place, for example Prometheus for scraping and stdout for logs:
+
+` + "``` yaml" + `
+metrics:
+  type: multi
+  multi:
+    children:
+    - type: prometheus
+      prometheus:
+        prefix: benthos
+    - type: stdout
+      stdout:
+        push_interval: 1m
+` + "```" + `
+
+If any of the children expose metrics over HTTP (such as ` + "`prometheus`" + `)
+then the first of those is served from the ` + "`/metrics`" + ` endpoint of the
+Benthos HTTP server. Similarly, the first child that supports the
+` + "`/metrics/json`" + ` endpoint (such as ` + "`stdout`" + `) is served from
+it.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			children := []interface{}{}
+			for _, c := range conf.Multi.Children {
+				childSanit, err := SanitiseConfig(c)
+				if err != nil {
+					return nil, err
+				}
+				children = append(children, childSanit)
+			}
+			return map[string]interface{}{
+				"children": children,
+			}, nil
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// MultiConfig contains a list of metrics configurations that are all sent
+// the same metrics.
+type MultiConfig struct {
+	Children []Config `json:"children" yaml:"children"`
+}
+
+// NewMultiConfig returns the default configuration for a multi metrics type.
+func NewMultiConfig() MultiConfig {
+	return MultiConfig{
+		Children: []Config{},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type multiCounter []StatCounter
+
+func (m multiCounter) Incr(count int64) error {
+	for _, c := range m {
+		c.Incr(count)
+	}
+	return nil
+}
+
+type multiTimer []StatTimer
+
+func (m multiTimer) Timing(delta int64) error {
+	for _, t := range m {
+		t.Timing(delta)
+	}
+	return nil
+}
+
+type multiGauge []StatGauge
+
+func (m multiGauge) Set(value int64) error {
+	for _, g := range m {
+		g.Set(value)
+	}
+	return nil
+}
+
+func (m multiGauge) Incr(count int64) error {
+	for _, g := range m {
+		g.Incr(count)
+	}
+	return nil
+}
+
+func (m multiGauge) Decr(count int64) error {
+	for _, g := range m {
+		g.Decr(count)
+	}
+	return nil
+}
+
+type multiCounterVec []StatCounterVec
+
+func (m multiCounterVec) With(labelValues ...string) StatCounter {
+	counters := make(multiCounter, len(m))
+	for i, v := range m {
+		counters[i] = v.With(labelValues...)
+	}
+	return counters
+}
+
+type multiTimerVec []StatTimerVec
+
+func (m multiTimerVec) With(labelValues ...string) StatTimer {
+	timers := make(multiTimer, len(m))
+	for i, v := range m {
+		timers[i] = v.With(labelValues...)
+	}
+	return timers
+}
+
+type multiGaugeVec []StatGaugeVec
+
+func (m multiGaugeVec) With(labelValues ...string) StatGauge {
+	gauges := make(multiGauge, len(m))
+	for i, v := range m {
+		gauges[i] = v.With(labelValues...)
+	}
+	return gauges
+}
+
+//------------------------------------------------------------------------------
+
+// Multi is a statistics object that sends all metrics to a list of child
+// statistics objects.
+type Multi struct {
+	children []Type
+	log      log.Modular
+}
+
+// multiWithHandlerFunc is a Multi with a child that exposes an HTTP endpoint.
+type multiWithHandlerFunc struct {
+	*Multi
+	h WithHandlerFunc
+}
+
+// HandlerFunc returns the http.HandlerFunc of the first child type that has
+// one.
+func (m *multiWithHandlerFunc) HandlerFunc() http.HandlerFunc {
+	return m.h.HandlerFunc()
+}
+
+// NewMulti creates and returns a new Multi object.
+func NewMulti(config Config, opts ...func(Type)) (Type, error) {
+	if len(config.Multi.Children) == 0 {
+		return nil, errors.New("cannot create a multi metric without children")
+	}
+
+	m := &Multi{
+		log: log.Noop(),
+	}
+	var handler WithHandlerFunc
+	for i, c := range config.Multi.Children {
+		if len(config.StaticLabels) > 0 {
+			labels := make(map[string]string, len(config.StaticLabels)+len(c.StaticLabels))
+			for k, v := range config.StaticLabels {
+				labels[k] = v
+			}
+			for k, v := range c.StaticLabels {
+				labels[k] = v
+			}
+			c.StaticLabels = labels
+		}
+		child, err := New(c)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("failed to create child '%v': %v", i, err)
+		}
+		if h, ok := child.(WithHandlerFunc); ok && handler == nil {
+			handler = h
+		}
+		m.children = append(m.children, child)
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if handler != nil {
+		return &multiWithHandlerFunc{
+			Multi: m,
+			h:     handler,
+		}, nil
+	}
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
+
+// GetCounter returns a stat counter object for a path.
+func (m *Multi) GetCounter(path string) StatCounter {
+	counters := make(multiCounter, len(m.children))
+	for i, c := range m.children {
+		counters[i] = c.GetCounter(path)
+	}
+	return counters
+}
+
+// GetCounterVec returns a stat counter object for a path with the labels
+// sent to all children.
+func (m *Multi) GetCounterVec(path string, n []string) StatCounterVec {
+	vecs := make(multiCounterVec, len(m.children))
+	for i, c := range m.children {
+		vecs[i] = c.GetCounterVec(path, n)
+	}
+	return vecs
+}
+
+// GetTimer returns a stat timer object for a path.
+func (m *Multi) GetTimer(path string) StatTimer {
+	timers := make(multiTimer, len(m.children))
+	for i, c := range m.children {
+		timers[i] = c.GetTimer(path)
+	}
+	return timers
+}
+
+// GetTimerVec returns a stat timer object for a path with the labels sent to
+// all children.
+func (m *Multi) GetTimerVec(path string, n []string) StatTimerVec {
+	vecs := make(multiTimerVec, len(m.children))
+	for i, c := range m.children {
+		vecs[i] = c.GetTimerVec(path, n)
+	}
+	return vecs
+}
+
+// GetGauge returns a stat gauge object for a path.
+func (m *Multi) GetGauge(path string) StatGauge {
+	gauges := make(multiGauge, len(m.children))
+	for i, c := range m.children {
+		gauges[i] = c.GetGauge(path)
+	}
+	return gauges
+}
+
+// GetGaugeVec returns a stat gauge object for a path with the labels sent to
+// all children.
+func (m *Multi) GetGaugeVec(path string, n []string) StatGaugeVec {
+	vecs := make(multiGaugeVec, len(m.children))
+	for i, c := range m.children {
+		vecs[i] = c.GetGaugeVec(path, n)
+	}
+	return vecs
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (m *Multi) SetLogger(log log.Modular) {
+	m.log = log.NewModule(".multi")
+	for i, c := range m.children {
+		c.SetLogger(log.NewModule(fmt.Sprintf(".multi.%v", i)))
+	}
+}
+
+// Close stops all children from aggregating metrics and cleans up resources.
+func (m *Multi) Close() error {
+	var err error
+	for _, c := range m.children {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// jsonHandlerFunc returns the grouped JSON metrics handler of the first child
+// type that has one.
+func (m *Multi) jsonHandlerFunc() (http.HandlerFunc, bool) {
+	for _, c := range m.children {
+		if h, ok := JSONHandlerFunc(c); ok {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+// flushNow flushes the metrics of all children that push metrics, returns
+// false if none of them do.
+func (m *Multi) flushNow() bool {
+	flushed := false
+	for _, c := range m.children {
+		if Flush(c) {
+			flushed = true
+		}
+	}
+	return flushed
+}
+
+// resetNow resets the metrics of all children that aggregate metrics locally,
+// returns false if none of them do.
+func (m *Multi) resetNow() bool {
+	reset := false
+	for _, c := range m.children {
+		if Reset(c) {
+			reset = true
+		}
+	}
+	return reset
+}
+
+// triggerCounter passes each child counter of a counter created by the Multi,
+// along with its path before any mapping rules were applied, to the children
+// that trigger behaviour from counters. Counters that were not created by the
+// Multi, such as those of dropped metrics, are combined with a counter for
+// each of those children instead.
+func (m *Multi) triggerCounter(path string, c StatCounter) StatCounter {
+	if counters, ok := c.(multiCounter); ok && len(counters) == len(m.children) {
+		triggered := make(multiCounter, len(counters))
+		for i, child := range m.children {
+			triggered[i] = counters[i]
+			if t, ok := child.(counterTrigger); ok {
+				triggered[i] = t.triggerCounter(path, counters[i])
+			}
+		}
+		return triggered
+	}
+	combined := multiCounter{c}
+	for _, child := range m.children {
+		if t, ok := child.(counterTrigger); ok {
+			combined = append(combined, t.triggerCounter(path, DudStat{}))
+		}
+	}
+	return combined
+}
+
+// appliesStaticLabels indicates that static labels are added to each child.
+func (m *Multi) appliesStaticLabels() bool {
+	return true
+}
+
+// reportsSystemMetrics returns true if all children collect system metrics
+// themselves.
+func (m *Multi) reportsSystemMetrics() bool {
+	for _, c := range m.children {
+		if r, ok := c.(systemMetricsReporter); !ok || !r.reportsSystemMetrics() {
+			return false
+		}
+	}
+	return true
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,270 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/util/config"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestMultiInterface(t *testing.T) {
+	o := &Multi{}
+	if Type(o) == nil {
+		t.Errorf("Multi does not satisfy Type interface")
+	}
+}
+
+func TestMultiNoChildren(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeMulti
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from no children")
+	}
+}
+
+func TestMultiBadChild(t *testing.T) {
+	child := NewConfig()
+	child.Type = "nope"
+
+	conf := NewConfig()
+	conf.Type = TypeMulti
+	conf.Multi.Children = []Config{NewConfig(), child}
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from bad child")
+	}
+}
+
+func TestMultiSanitise(t *testing.T) {
+	conf := NewConfig()
+	if err := yaml.Unmarshal([]byte(`
+type: multi
+multi:
+  children:
+  - type: prometheus
+  - type: stdout
+`), &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	sanit, err := SanitiseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	children := sanit.(config.Sanitised)["multi"].(map[string]interface{})["children"].([]interface{})
+	if exp, act := 2, len(children); exp != act {
+		t.Fatalf("Wrong count of children: %v != %v", act, exp)
+	}
+	for i, exp := range []string{TypePrometheus, TypeStdout} {
+		child := children[i].(config.Sanitised)
+		if act := child["type"]; act != exp {
+			t.Errorf("Wrong child type: %v != %v", act, exp)
+		}
+		if _, exists := child[exp]; !exists {
+			t.Errorf("Missing child config for %v", exp)
+		}
+	}
+}
+
+func TestMultiHandlerFunc(t *testing.T) {
+	stdoutConf := NewConfig()
+	stdoutConf.Type = TypeStdout
+
+	conf := NewConfig()
+	conf.Type = TypeMulti
+	conf.Multi.Children = []Config{stdoutConf}
+
+	m, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(WithHandlerFunc); ok {
+		t.Error("Expected multi without HTTP children to not have a handler")
+	}
+	m.Close()
+
+	conf.Multi.Children = append(conf.Multi.Children, NewConfig())
+	if m, err = New(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(WithHandlerFunc); !ok {
+		t.Error("Expected multi with HTTP children to have a handler")
+	}
+	m.Close()
+}
+
+func TestMultiFanOut(t *testing.T) {
+	one, two := NewLocal(), NewLocal()
+	m := &Multi{children: []Type{one, two}}
+
+	m.GetCounter("counter").Incr(2)
+	m.GetCounterVec("countervec", []string{"topic"}).With("foo").Incr(3)
+	m.GetTimer("timer").Timing(10)
+	m.GetTimerVec("timervec", []string{"topic"}).With("bar").Timing(20)
+
+	gauge := m.GetGauge("gauge")
+	gauge.Set(5)
+	gauge.Incr(2)
+	gauge.Decr(1)
+	m.GetGaugeVec("gaugevec", []string{"topic"}).With("baz").Set(7)
+
+	expCounters := map[string]int64{
+		"counter":    2,
+		"countervec": 3,
+		"gauge":      6,
+		"gaugevec":   7,
+	}
+	expTimings := map[string]int64{
+		"timer":    10,
+		"timervec": 20,
+	}
+	for i, l := range []*Local{one, two} {
+		if act := l.GetCounters(); !reflect.DeepEqual(act, expCounters) {
+			t.Errorf("Wrong counters for child %v: %v != %v", i, act, expCounters)
+		}
+		if act := l.GetTimings(); !reflect.DeepEqual(act, expTimings) {
+			t.Errorf("Wrong timings for child %v: %v != %v", i, act, expTimings)
+		}
+		if stat := l.GetCountersWithLabels()["countervec"]; !stat.HasLabelWithValue("topic", "foo") {
+			t.Errorf("Missing label for child %v", i)
+		}
+	}
+}
+
+func TestMultiJSONHandlerFunc(t *testing.T) {
+	promConf := NewConfig()
+	promConf.Type = TypePrometheus
+
+	conf := NewConfig()
+	conf.Type = TypeMulti
+	conf.Multi.Children = []Config{promConf}
+
+	m, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := JSONHandlerFunc(m); ok {
+		t.Error("Expected multi without JSON children to not have a JSON handler")
+	}
+	m.Close()
+
+	stdoutConf := NewConfig()
+	stdoutConf.Type = TypeStdout
+	conf.Multi.Children = append(conf.Multi.Children, stdoutConf)
+	conf.Mapping = []MappingRuleConfig{{Pattern: "^foo$", Value: "bar"}}
+	if m, err = New(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := JSONHandlerFunc(m); !ok {
+		t.Error("Expected mapped multi with JSON children to have a JSON handler")
+	}
+	m.Close()
+}
+
+func TestMultiStaticLabels(t *testing.T) {
+	stdoutConf := NewConfig()
+	stdoutConf.Type = TypeStdout
+	stdoutConf.StaticLabels = map[string]string{"region": "bar"}
+
+	conf := NewConfig()
+	conf.Type = TypeMulti
+	conf.StaticLabels = map[string]string{"host": "foo", "region": "baz"}
+	conf.Multi.Children = []Config{stdoutConf, NewConfig()}
+
+	m, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	children := m.(*multiWithHandlerFunc).children
+	if exp, act := map[string]string{"host": "foo", "region": "bar"}, children[0].(*Stdout).staticLabels; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong stdout static labels: %v != %v", act, exp)
+	}
+	mapped, ok := children[1].(*mappingWithHandlerFunc)
+	if !ok {
+		t.Fatalf("Expected http_server child to be wrapped with labels, got %T", children[1])
+	}
+	if exp, act := map[string]string{"host": "foo", "region": "baz"}, mapped.staticLabels; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong http_server static labels: %v != %v", act, exp)
+	}
+}
+
+func TestMultiMappedPushEveryNMessages(t *testing.T) {
+	tests := map[string]MappingRuleConfig{
+		"renamed": {
+			Pattern: `^output\.sent$`,
+			Value:   "output.messages_sent",
+		},
+		"labelled": {
+			Pattern: `^output\.sent$`,
+			Labels:  map[string]string{"stream": "foo"},
+		},
+		"dropped": {
+			Pattern: `^output\.sent$`,
+			Drop:    true,
+		},
+	}
+
+	for name, rule := range tests {
+		stdoutConf := NewConfig()
+		stdoutConf.Type = TypeStdout
+		stdoutConf.Stdout.PushEveryNMessages = 5
+
+		conf := NewConfig()
+		conf.Type = TypeMulti
+		conf.Multi.Children = []Config{stdoutConf, NewConfig()}
+		conf.Mapping = []MappingRuleConfig{rule}
+
+		m, err := New(conf)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		std := m.(*mappingWithHandlerFunc).s.(*multiWithHandlerFunc).children[0].(*Stdout)
+		buf := &syncBuffer{}
+		std.writer = buf
+
+		sent := m.GetCounter("output.sent")
+		sent.Incr(4)
+
+		<-time.After(time.Millisecond * 50)
+		if exp, act := 0, len(buf.Lines()); exp != act {
+			t.Errorf("%v: Wrong count of lines before threshold: %v != %v", name, act, exp)
+		}
+
+		sent.Incr(1)
+
+		timeout := time.After(time.Second * 5)
+		for len(buf.Lines()) == 0 {
+			select {
+			case <-time.After(time.Millisecond):
+			case <-timeout:
+				t.Fatalf("%v: Timed out waiting for message count push", name)
+			}
+		}
+		if err = m.Close(); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+	}
+}
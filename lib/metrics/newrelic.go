@@ -0,0 +1,339 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeNewRelic] = TypeSpec{
+		constructor: NewNewRelic,
+		description: `
+Push metrics to the [New Relic Metric API](https://docs.newrelic.com/docs/telemetry-data-platform/ingest-apis/introduction-metric-api/)
+authenticated with an insert or license key set in ` + "`api_key`" + `. Accounts
+within the EU region must set the ` + "`url`" + ` to
+` + "`https://metric-api.eu.newrelic.com/metric/v1`" + `.
+
+Metrics are pushed every ` + "`flush_period`" + ` in gzip compressed batches of
+at most ` + "`batch_size`" + ` metrics. Each metric is named by its path (with
+the ` + "`prefix`" + `), and metric labels as well as the static
+` + "`attributes`" + ` are added as attributes.
+
+Counters are sent as ` + "`count`" + ` metrics of their change in value since
+the previous push, gauges are sent as ` + "`gauge`" + ` metrics and timers are
+converted to ` + "`summary`" + ` metrics of the count, sum, minimum and maximum
+of the timings (in milliseconds) recorded since the previous push. Counters
+that have not changed and timers without timings are not sent.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewRelicConfig is config for the New Relic metrics type.
+type NewRelicConfig struct {
+	URL         string            `json:"url" yaml:"url"`
+	APIKey      string            `json:"api_key" yaml:"api_key"`
+	Prefix      string            `json:"prefix" yaml:"prefix"`
+	Attributes  map[string]string `json:"attributes" yaml:"attributes"`
+	BatchSize   int               `json:"batch_size" yaml:"batch_size"`
+	FlushPeriod string            `json:"flush_period" yaml:"flush_period"`
+	Timeout     string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewNewRelicConfig creates an NewRelicConfig struct with default values.
+func NewNewRelicConfig() NewRelicConfig {
+	return NewRelicConfig{
+		URL:         "https://metric-api.newrelic.com/metric/v1",
+		APIKey:      "",
+		Prefix:      "benthos",
+		Attributes:  map[string]string{},
+		BatchSize:   1000,
+		FlushPeriod: "10s",
+		Timeout:     "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// newRelicMetric is a single metric within a New Relic Metric API payload.
+type newRelicMetric struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Value      interface{}       `json:"value"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// newRelicSummary is the value of a summary metric.
+type newRelicSummary struct {
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+// newRelicCommon contains the fields shared by all metrics within a payload.
+type newRelicCommon struct {
+	Timestamp  int64             `json:"timestamp"`
+	IntervalMS int64             `json:"interval.ms"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// newRelicPayload is a batch of metrics sent in a single request.
+type newRelicPayload struct {
+	Common  newRelicCommon   `json:"common"`
+	Metrics []newRelicMetric `json:"metrics"`
+}
+
+// NewRelic is a stats object that periodically pushes metrics to the New Relic
+// Metric API.
+type NewRelic struct {
+	*snapshotStore
+
+	config    NewRelicConfig
+	prefix    string
+	client    *http.Client
+	lastFlush time.Time
+	log       log.Modular
+	closeOnce sync.Once
+
+	closedChan chan struct{}
+	doneChan   chan struct{}
+	flushChan  chan chan struct{}
+}
+
+// NewNewRelic creates and returns a new NewRelic object.
+func NewNewRelic(config Config, opts ...func(Type)) (Type, error) {
+	n, err := newNewRelic(config.NewRelic, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func newNewRelic(conf NewRelicConfig, opts ...func(Type)) (*NewRelic, error) {
+	if len(conf.APIKey) == 0 {
+		return nil, errors.New("an api_key must be specified")
+	}
+	if conf.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be greater than zero: %v", conf.BatchSize)
+	}
+	flushPeriod, err := time.ParseDuration(conf.FlushPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush period: %v", err)
+	}
+	if flushPeriod <= 0 {
+		return nil, fmt.Errorf("flush period must be greater than zero: %v", conf.FlushPeriod)
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	n := &NewRelic{
+		snapshotStore: newSnapshotStore(),
+		config:        conf,
+		prefix:        conf.Prefix,
+		client:        &http.Client{Timeout: timeout},
+		lastFlush:     time.Now(),
+		log:           log.Noop(),
+		closedChan:    make(chan struct{}),
+		doneChan:      make(chan struct{}),
+		flushChan:     make(chan chan struct{}),
+	}
+	if len(n.prefix) > 0 && n.prefix[len(n.prefix)-1] != '.' {
+		n.prefix = n.prefix + "."
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	go n.loop(flushPeriod)
+	return n, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (n *NewRelic) loop(flushPeriod time.Duration) {
+	defer close(n.doneChan)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.flush(time.Now())
+		case done := <-n.flushChan:
+			n.flush(time.Now())
+			close(done)
+		case <-n.closedChan:
+			n.flush(time.Now())
+			return
+		}
+	}
+}
+
+func (n *NewRelic) newMetric(m metricSnapshot, kind string, value interface{}) newRelicMetric {
+	metric := newRelicMetric{
+		Name:  n.prefix + m.Path,
+		Type:  kind,
+		Value: value,
+	}
+	if labels := m.Labels(); len(labels) > 0 {
+		metric.Attributes = labels
+	}
+	return metric
+}
+
+// metrics returns a snapshot of all metrics that should be sent.
+func (n *NewRelic) metrics() []newRelicMetric {
+	counters, gauges, timers := n.snapshot()
+
+	var metrics []newRelicMetric
+	for _, m := range counters {
+		if m.Delta == 0 {
+			continue
+		}
+		metrics = append(metrics, n.newMetric(m, "count", m.Delta))
+	}
+	for _, m := range gauges {
+		metrics = append(metrics, n.newMetric(m, "gauge", m.Value))
+	}
+	for _, m := range timers {
+		if m.Timing.Count == 0 {
+			continue
+		}
+		metrics = append(metrics, n.newMetric(m, "summary", newRelicSummary{
+			Count: m.Timing.Count,
+			Sum:   float64(m.Timing.Sum) / float64(time.Millisecond),
+			Min:   float64(m.Timing.Min) / float64(time.Millisecond),
+			Max:   float64(m.Timing.Max) / float64(time.Millisecond),
+		}))
+	}
+	return metrics
+}
+
+// payloads returns a snapshot of all metrics split into batches.
+func (n *NewRelic) payloads(t time.Time) []newRelicPayload {
+	metrics := n.metrics()
+
+	common := newRelicCommon{
+		Timestamp:  n.lastFlush.UnixNano() / int64(time.Millisecond),
+		IntervalMS: int64(t.Sub(n.lastFlush) / time.Millisecond),
+	}
+	if len(n.config.Attributes) > 0 {
+		common.Attributes = n.config.Attributes
+	}
+	n.lastFlush = t
+
+	var payloads []newRelicPayload
+	for len(metrics) > 0 {
+		size := n.config.BatchSize
+		if size > len(metrics) {
+			size = len(metrics)
+		}
+		payloads = append(payloads, newRelicPayload{
+			Common:  common,
+			Metrics: metrics[:size],
+		})
+		metrics = metrics[size:]
+	}
+	return payloads
+}
+
+func (n *NewRelic) flush(t time.Time) {
+	for _, p := range n.payloads(t) {
+		if err := n.send(p); err != nil {
+			n.log.Errorf("Failed to push metrics: %v\n", err)
+		}
+	}
+}
+
+func (n *NewRelic) send(p newRelicPayload) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode([]newRelicPayload{p}); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", n.config.URL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Api-Key", n.config.APIKey)
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status code %v: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// flushNow sends metrics immediately.
+func (n *NewRelic) flushNow() bool {
+	requestFlush(n.flushChan, n.doneChan)
+	return true
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (n *NewRelic) SetLogger(log log.Modular) {
+	n.log = log
+}
+
+// Close stops the NewRelic object from aggregating metrics and pushes the
+// remaining metrics.
+func (n *NewRelic) Close() error {
+	n.closeOnce.Do(func() {
+		close(n.closedChan)
+		<-n.doneChan
+	})
+	return nil
+}
+
+//------------------------------------------------------------------------------
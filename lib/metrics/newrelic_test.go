@@ -0,0 +1,164 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewRelicInterface(t *testing.T) {
+	o := &NewRelic{}
+	if Type(o) == nil {
+		t.Errorf("NewRelic does not satisfy Type interface")
+	}
+}
+
+func TestNewRelicBadConfig(t *testing.T) {
+	for name, fn := range map[string]func(c *NewRelicConfig){
+		"no api key":       func(c *NewRelicConfig) { c.APIKey = "" },
+		"bad batch size":   func(c *NewRelicConfig) { c.BatchSize = 0 },
+		"bad flush period": func(c *NewRelicConfig) { c.FlushPeriod = "nope" },
+		"bad timeout":      func(c *NewRelicConfig) { c.Timeout = "nope" },
+	} {
+		conf := NewConfig()
+		conf.Type = TypeNewRelic
+		conf.NewRelic.APIKey = "foo"
+		fn(&conf.NewRelic)
+		if _, err := New(conf); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestNewRelicPayloads(t *testing.T) {
+	conf := NewNewRelicConfig()
+	conf.APIKey = "foo"
+	conf.Attributes = map[string]string{"host": "bar"}
+	conf.BatchSize = 2
+	conf.FlushPeriod = "1h"
+
+	n, err := newNewRelic(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	start := time.Unix(10, 0)
+	n.lastFlush = start
+
+	n.GetCounter("a.counter").Incr(3)
+	n.GetCounter("a.unchanged")
+	n.GetGaugeVec("a.gauge", []string{"label"}).With("baz").Set(10)
+	timer := n.GetTimer("a.timer")
+	for _, v := range []int64{1, 2, 3} {
+		timer.Timing(v * int64(time.Millisecond))
+	}
+	n.GetTimer("a.empty")
+
+	payloads := n.payloads(start.Add(time.Second * 5))
+	if exp, act := 2, len(payloads); exp != act {
+		t.Fatalf("Wrong count of payloads: %v != %v", act, exp)
+	}
+
+	exp := `[{"common":{"timestamp":10000,"interval.ms":5000,"attributes":{"host":"bar"}},"metrics":[` +
+		`{"name":"benthos.a.counter","type":"count","value":3},` +
+		`{"name":"benthos.a.gauge","type":"gauge","value":10,"attributes":{"label":"baz"}}]},` +
+		`{"common":{"timestamp":10000,"interval.ms":5000,"attributes":{"host":"bar"}},"metrics":[` +
+		`{"name":"benthos.a.timer","type":"summary","value":{"count":3,"sum":6,"min":1,"max":3}}]}]`
+	if act := jsonString(t, payloads); exp != act {
+		t.Errorf("Wrong payloads: %v != %v", act, exp)
+	}
+
+	n.GetCounter("a.counter").Incr(1)
+	payloads = n.payloads(start.Add(time.Second * 10))
+	exp = `[{"common":{"timestamp":15000,"interval.ms":5000,"attributes":{"host":"bar"}},"metrics":[` +
+		`{"name":"benthos.a.counter","type":"count","value":1},` +
+		`{"name":"benthos.a.gauge","type":"gauge","value":10,"attributes":{"label":"baz"}}]}]`
+	if act := jsonString(t, payloads); exp != act {
+		t.Errorf("Wrong payloads: %v != %v", act, exp)
+	}
+}
+
+func TestNewRelicHTTP(t *testing.T) {
+	var reqMut sync.Mutex
+	var apiKey string
+	var payloads []newRelicPayload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqMut.Lock()
+		defer reqMut.Unlock()
+
+		apiKey = r.Header.Get("Api-Key")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var p []newRelicPayload
+		if err = json.NewDecoder(zr).Decode(&p); err != nil {
+			t.Error(err)
+			return
+		}
+		payloads = append(payloads, p...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeNewRelic
+	conf.NewRelic.URL = server.URL
+	conf.NewRelic.APIKey = "foo"
+	conf.NewRelic.FlushPeriod = "1h"
+
+	n, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.GetCounter("a.counter").Incr(5)
+	if err = n.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+
+	if exp, act := "foo", apiKey; exp != act {
+		t.Errorf("Wrong api key: %v != %v", act, exp)
+	}
+	if exp, act := 1, len(payloads); exp != act {
+		t.Fatalf("Wrong count of payloads: %v != %v", act, exp)
+	}
+	if exp, act := 1, len(payloads[0].Metrics); exp != act {
+		t.Fatalf("Wrong count of metrics: %v != %v", act, exp)
+	}
+	if exp, act := "benthos.a.counter", payloads[0].Metrics[0].Name; exp != act {
+		t.Errorf("Wrong metric name: %v != %v", act, exp)
+	}
+	if exp, act := float64(5), payloads[0].Metrics[0].Value; exp != act {
+		t.Errorf("Wrong metric value: %v != %v", act, exp)
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/util/otlp"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeOTLP] = TypeSpec{
+		constructor: NewOTLP,
+		description: `
+EXPERIMENTAL: This component is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Export metrics to an [OpenTelemetry](https://opentelemetry.io/) collector using
+the OTLP protocol over either gRPC or HTTP.
+
+When ` + "`protocol`" + ` is ` + "`grpc`" + ` the ` + "`endpoint`" + ` is the
+host and port of the collector (e.g. ` + "`localhost:4317`" + `), and when it is
+` + "`http`" + ` the ` + "`endpoint`" + ` is the full URL that metrics are posted
+to (e.g. ` + "`http://localhost:4318/v1/metrics`" + `).
+
+Counters are exported as monotonic sums, gauges as gauges and timers as
+histograms of nanoseconds with the bucket boundaries ` + "`histogram_buckets`" + `.
+Metric labels are exported as attributes of each data point.
+
+The ` + "`temporality`" + ` of sums and histograms can be either
+` + "`cumulative`" + `, where values are totals since Benthos started, or
+` + "`delta`" + `, where values are the change since the previous export.
+
+Metrics are exported with the resource attributes ` + "`service.name`" + ` and
+` + "`service.instance.id`" + `, where the instance id defaults to the hostname
+of the machine when left empty, along with any ` + "`resource_attributes`" + `.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OTLPConfig contains config fields for the OTLP metrics type.
+type OTLPConfig struct {
+	Protocol           string            `json:"protocol" yaml:"protocol"`
+	Endpoint           string            `json:"endpoint" yaml:"endpoint"`
+	Headers            map[string]string `json:"headers" yaml:"headers"`
+	TLS                btls.Config       `json:"tls" yaml:"tls"`
+	ServiceName        string            `json:"service_name" yaml:"service_name"`
+	ServiceInstanceID  string            `json:"service_instance_id" yaml:"service_instance_id"`
+	ResourceAttributes map[string]string `json:"resource_attributes" yaml:"resource_attributes"`
+	Temporality        string            `json:"temporality" yaml:"temporality"`
+	HistogramBuckets   []string          `json:"histogram_buckets" yaml:"histogram_buckets"`
+	FlushPeriod        string            `json:"flush_period" yaml:"flush_period"`
+	Timeout            string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewOTLPConfig creates an OTLPConfig struct with default values.
+func NewOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		Protocol:           "grpc",
+		Endpoint:           "localhost:4317",
+		Headers:            map[string]string{},
+		TLS:                btls.NewConfig(),
+		ServiceName:        "benthos",
+		ServiceInstanceID:  "",
+		ResourceAttributes: map[string]string{},
+		Temporality:        "cumulative",
+		HistogramBuckets: []string{
+			"1ms", "5ms", "10ms", "25ms", "50ms", "100ms", "250ms", "500ms",
+			"1s", "2.5s", "5s", "10s",
+		},
+		FlushPeriod: "10s",
+		Timeout:     "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+const otlpExportMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// Values of the OTLP AggregationTemporality enum.
+const (
+	otlpTemporalityDelta      = 1
+	otlpTemporalityCumulative = 2
+)
+
+// OTLP is a stats object with capability to export metrics to an
+// OpenTelemetry collector.
+type OTLP struct {
+	*snapshotStore
+
+	config      OTLPConfig
+	temporality uint64
+	resource    []byte
+	timeout     time.Duration
+
+	client *otlp.Client
+
+	startTime time.Time
+	lastFlush time.Time
+
+	log       log.Modular
+	closeOnce sync.Once
+
+	closedChan chan struct{}
+	doneChan   chan struct{}
+	flushChan  chan chan struct{}
+}
+
+// NewOTLP creates and returns a new OTLP object.
+func NewOTLP(config Config, opts ...func(Type)) (Type, error) {
+	o, err := newOTLP(config.OTLP, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func newOTLP(conf OTLPConfig, opts ...func(Type)) (*OTLP, error) {
+	flushPeriod, err := time.ParseDuration(conf.FlushPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush period: %v", err)
+	}
+	if flushPeriod <= 0 {
+		return nil, fmt.Errorf("flush period must be greater than zero: %v", conf.FlushPeriod)
+	}
+
+	o := &OTLP{
+		snapshotStore: newSnapshotStore(),
+		config:        conf,
+		startTime:     time.Now(),
+		log:           log.Noop(),
+		closedChan:    make(chan struct{}),
+		doneChan:      make(chan struct{}),
+		flushChan:     make(chan chan struct{}),
+	}
+	o.lastFlush = o.startTime
+
+	if o.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	switch conf.Temporality {
+	case "cumulative":
+		o.temporality = otlpTemporalityCumulative
+	case "delta":
+		o.temporality = otlpTemporalityDelta
+	default:
+		return nil, fmt.Errorf("temporality not recognised: %v", conf.Temporality)
+	}
+
+	for _, b := range conf.HistogramBuckets {
+		bound, err := time.ParseDuration(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse histogram bucket: %v", err)
+		}
+		if l := len(o.bucketBounds); l > 0 && int64(bound) <= o.bucketBounds[l-1] {
+			return nil, errors.New("histogram buckets must be in increasing order")
+		}
+		o.bucketBounds = append(o.bucketBounds, int64(bound))
+	}
+
+	instanceID := conf.ServiceInstanceID
+	if len(instanceID) == 0 {
+		instanceID, _ = os.Hostname()
+	}
+	attributes := map[string]string{}
+	for k, v := range conf.ResourceAttributes {
+		attributes[k] = v
+	}
+	attributes["service.name"] = conf.ServiceName
+	attributes["service.instance.id"] = instanceID
+	var resource otlp.ProtoBuf
+	otlp.EncodeAttributes(&resource, 1, attributes)
+	o.resource = resource.B
+
+	if o.client, err = otlp.NewClient(otlp.ClientConfig{
+		Protocol:   conf.Protocol,
+		Endpoint:   conf.Endpoint,
+		GRPCMethod: otlpExportMethod,
+		Headers:    conf.Headers,
+		TLS:        conf.TLS,
+		Timeout:    o.timeout,
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	go o.loop(flushPeriod)
+	return o, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (o *OTLP) loop(flushPeriod time.Duration) {
+	defer close(o.doneChan)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			o.flush()
+		case done := <-o.flushChan:
+			o.flush()
+			close(done)
+		case <-o.closedChan:
+			o.flush()
+			return
+		}
+	}
+}
+
+func (o *OTLP) flush() {
+	now := time.Now()
+	req := o.exportRequest(now)
+	o.lastFlush = now
+	if req == nil {
+		return
+	}
+
+	if err := o.client.Export(req); err != nil {
+		o.log.Errorf("Failed to export metrics: %v\n", err)
+	}
+}
+
+// exportRequest returns a snapshot of all metrics serialised as an OTLP
+// ExportMetricsServiceRequest, or nil if there are no metrics to export.
+func (o *OTLP) exportRequest(now time.Time) []byte {
+	counters, gauges, timers := o.snapshot()
+	if len(counters)+len(gauges)+len(timers) == 0 {
+		return nil
+	}
+
+	startTime := o.startTime
+	if o.temporality == otlpTemporalityDelta {
+		startTime = o.lastFlush
+	}
+	start, ts := uint64(startTime.UnixNano()), uint64(now.UnixNano())
+
+	// Group data points into a single metric per path.
+	metrics := map[string]*otlpMetric{}
+	getMetric := func(path, unit string, kind uint64) *otlpMetric {
+		m, exists := metrics[path]
+		if !exists {
+			m = &otlpMetric{name: path, unit: unit, kind: kind}
+			metrics[path] = m
+		}
+		return m
+	}
+
+	for _, c := range counters {
+		value := c.Value
+		if o.temporality == otlpTemporalityDelta {
+			value = c.Delta
+		}
+		var p otlp.ProtoBuf
+		otlp.EncodeAttributes(&p, 7, c.Labels())
+		p.Fixed64(2, start)
+		p.Fixed64(3, ts)
+		p.Fixed64(6, uint64(value))
+		m := getMetric(c.Path, "1", 7)
+		m.points = append(m.points, p.B)
+	}
+	for _, g := range gauges {
+		var p otlp.ProtoBuf
+		otlp.EncodeAttributes(&p, 7, g.Labels())
+		p.Fixed64(3, ts)
+		p.Fixed64(6, uint64(g.Value))
+		m := getMetric(g.Path, "1", 5)
+		m.points = append(m.points, p.B)
+	}
+	for _, t := range timers {
+		count, sum, buckets := t.Timing.TotalCount, t.Timing.TotalSum, t.Timing.TotalBuckets
+		if o.temporality == otlpTemporalityDelta {
+			count, sum, buckets = t.Timing.Count, t.Timing.Sum, t.Timing.Buckets
+		}
+		if len(buckets) == 0 {
+			buckets = []int64{count}
+		}
+
+		var p otlp.ProtoBuf
+		otlp.EncodeAttributes(&p, 9, t.Labels())
+		p.Fixed64(2, start)
+		p.Fixed64(3, ts)
+		p.Fixed64(4, uint64(count))
+		p.Double(5, float64(sum))
+
+		var packed otlp.ProtoBuf
+		for _, b := range buckets {
+			packed.B = otlp.AppendFixed64(packed.B, uint64(b))
+		}
+		p.Message(6, packed.B)
+		packed.B = nil
+		for _, b := range o.bucketBounds {
+			packed.B = otlp.AppendFixed64(packed.B, math.Float64bits(float64(b)))
+		}
+		p.Message(7, packed.B)
+
+		if o.temporality == otlpTemporalityDelta && t.Timing.Count > 0 {
+			p.Double(11, float64(t.Timing.Min))
+			p.Double(12, float64(t.Timing.Max))
+		}
+		m := getMetric(t.Path, "ns", 9)
+		m.points = append(m.points, p.B)
+	}
+
+	paths := make([]string, 0, len(metrics))
+	for k := range metrics {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+
+	var scope otlp.ProtoBuf
+	var scopeInfo otlp.ProtoBuf
+	scopeInfo.String(1, "benthos")
+	scope.Message(1, scopeInfo.B)
+	for _, path := range paths {
+		scope.Message(2, metrics[path].encode(o.temporality))
+	}
+
+	var resourceMetrics otlp.ProtoBuf
+	resourceMetrics.Message(1, o.resource)
+	resourceMetrics.Message(2, scope.B)
+
+	var req otlp.ProtoBuf
+	req.Message(1, resourceMetrics.B)
+	return req.B
+}
+
+//------------------------------------------------------------------------------
+
+// flushNow sends metrics immediately.
+func (o *OTLP) flushNow() bool {
+	requestFlush(o.flushChan, o.doneChan)
+	return true
+}
+
+// SetLogger sets the logger used to print connection errors.
+func (o *OTLP) SetLogger(log log.Modular) {
+	o.log = log
+}
+
+// Close stops the OTLP object from aggregating metrics and exports the
+// remaining metrics.
+func (o *OTLP) Close() error {
+	o.closeOnce.Do(func() {
+		close(o.closedChan)
+		<-o.doneChan
+		o.client.Close()
+	})
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// otlpMetric is an OTLP Metric message under construction, where kind is the
+// field number of the data type (5 for gauge, 7 for sum and 9 for histogram).
+type otlpMetric struct {
+	name   string
+	unit   string
+	kind   uint64
+	points [][]byte
+}
+
+func (m *otlpMetric) encode(temporality uint64) []byte {
+	var data otlp.ProtoBuf
+	for _, p := range m.points {
+		data.Message(1, p)
+	}
+	switch m.kind {
+	case 7:
+		data.Varint(2, temporality)
+		data.Varint(3, 1)
+	case 9:
+		data.Varint(2, temporality)
+	}
+
+	var p otlp.ProtoBuf
+	p.String(1, m.name)
+	p.String(3, m.unit)
+	p.Message(m.kind, data.B)
+	return p.B
+}
+
+//------------------------------------------------------------------------------
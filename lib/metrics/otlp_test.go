@@ -0,0 +1,317 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/util/otlp"
+	"google.golang.org/grpc"
+)
+
+type protoField struct {
+	num    uint64
+	varint uint64
+	bytes  []byte
+}
+
+// decodeProto parses the fields of a protobuf message, where fixed64 values
+// are returned as varint.
+func decodeProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("Failed to parse field key")
+		}
+		b = b[n:]
+
+		f := protoField{num: key >> 3}
+		switch key & 7 {
+		case 0:
+			if f.varint, n = binary.Uvarint(b); n <= 0 {
+				t.Fatal("Failed to parse varint")
+			}
+			b = b[n:]
+		case 1:
+			f.varint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatal("Failed to parse length")
+			}
+			f.bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("Unexpected wire type: %v", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func protoFieldsByNum(t *testing.T, b []byte, num uint64) []protoField {
+	t.Helper()
+
+	var res []protoField
+	for _, f := range decodeProto(t, b) {
+		if f.num == num {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+func protoAttributes(t *testing.T, b []byte, num uint64) map[string]string {
+	t.Helper()
+
+	attrs := map[string]string{}
+	for _, kv := range protoFieldsByNum(t, b, num) {
+		key := string(protoFieldsByNum(t, kv.bytes, 1)[0].bytes)
+		value := protoFieldsByNum(t, protoFieldsByNum(t, kv.bytes, 2)[0].bytes, 1)[0].bytes
+		attrs[key] = string(value)
+	}
+	return attrs
+}
+
+// otlpMetrics returns the serialised metrics of an export request by name.
+func otlpMetrics(t *testing.T, req []byte) (map[string]string, map[string][]byte) {
+	t.Helper()
+
+	resourceMetrics := protoFieldsByNum(t, req, 1)[0].bytes
+	resource := protoAttributes(t, protoFieldsByNum(t, resourceMetrics, 1)[0].bytes, 1)
+
+	metrics := map[string][]byte{}
+	scope := protoFieldsByNum(t, resourceMetrics, 2)[0].bytes
+	for _, m := range protoFieldsByNum(t, scope, 2) {
+		name := string(protoFieldsByNum(t, m.bytes, 1)[0].bytes)
+		metrics[name] = m.bytes
+	}
+	return resource, metrics
+}
+
+//------------------------------------------------------------------------------
+
+func TestOTLPInterface(t *testing.T) {
+	o := &OTLP{}
+	if Type(o) == nil {
+		t.Errorf("OTLP does not satisfy Type interface")
+	}
+}
+
+func TestOTLPBadConfig(t *testing.T) {
+	for name, fn := range map[string]func(c *OTLPConfig){
+		"bad protocol":    func(c *OTLPConfig) { c.Protocol = "nope" },
+		"bad temporality": func(c *OTLPConfig) { c.Temporality = "nope" },
+		"bad buckets":     func(c *OTLPConfig) { c.HistogramBuckets = []string{"1s", "1ms"} },
+		"bad period":      func(c *OTLPConfig) { c.FlushPeriod = "nope" },
+	} {
+		conf := NewOTLPConfig()
+		fn(&conf)
+		if _, err := newOTLP(conf); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestOTLPExportRequest(t *testing.T) {
+	conf := NewOTLPConfig()
+	conf.Protocol = "http"
+	conf.Temporality = "delta"
+	conf.ServiceInstanceID = "foo"
+	conf.ResourceAttributes = map[string]string{"env": "prod"}
+	conf.HistogramBuckets = []string{"1ms", "10ms"}
+	conf.FlushPeriod = "1h"
+
+	o, err := newOTLP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	if o.exportRequest(time.Now()) != nil {
+		t.Error("Expected nil request without metrics")
+	}
+
+	o.GetCounterVec("input.count", []string{"topic"}).With("bar").Incr(3)
+	o.GetGauge("input.gauge").Set(7)
+	o.GetTimer("input.latency").Timing(int64(5 * time.Millisecond))
+
+	resource, metrics := otlpMetrics(t, o.exportRequest(time.Now()))
+	if exp, act := map[string]string{
+		"env":                 "prod",
+		"service.name":        "benthos",
+		"service.instance.id": "foo",
+	}, resource; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong resource: %v != %v", act, exp)
+	}
+	if exp, act := 3, len(metrics); exp != act {
+		t.Fatalf("Wrong count of metrics: %v != %v", act, exp)
+	}
+
+	sum := protoFieldsByNum(t, metrics["input.count"], 7)[0].bytes
+	if exp, act := uint64(otlpTemporalityDelta), protoFieldsByNum(t, sum, 2)[0].varint; exp != act {
+		t.Errorf("Wrong temporality: %v != %v", act, exp)
+	}
+	point := protoFieldsByNum(t, sum, 1)[0].bytes
+	if exp, act := uint64(3), protoFieldsByNum(t, point, 6)[0].varint; exp != act {
+		t.Errorf("Wrong counter value: %v != %v", act, exp)
+	}
+	if exp, act := map[string]string{"topic": "bar"}, protoAttributes(t, point, 7); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong attributes: %v != %v", act, exp)
+	}
+
+	gauge := protoFieldsByNum(t, metrics["input.gauge"], 5)[0].bytes
+	point = protoFieldsByNum(t, gauge, 1)[0].bytes
+	if exp, act := uint64(7), protoFieldsByNum(t, point, 6)[0].varint; exp != act {
+		t.Errorf("Wrong gauge value: %v != %v", act, exp)
+	}
+
+	hist := protoFieldsByNum(t, metrics["input.latency"], 9)[0].bytes
+	point = protoFieldsByNum(t, hist, 1)[0].bytes
+	if exp, act := uint64(1), protoFieldsByNum(t, point, 4)[0].varint; exp != act {
+		t.Errorf("Wrong histogram count: %v != %v", act, exp)
+	}
+	if exp, act := float64(5*time.Millisecond), math.Float64frombits(protoFieldsByNum(t, point, 5)[0].varint); exp != act {
+		t.Errorf("Wrong histogram sum: %v != %v", act, exp)
+	}
+	buckets := protoFieldsByNum(t, point, 6)[0].bytes
+	var counts []uint64
+	for i := 0; i < len(buckets); i += 8 {
+		counts = append(counts, binary.LittleEndian.Uint64(buckets[i:]))
+	}
+	if exp, act := []uint64{0, 1, 0}, counts; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong bucket counts: %v != %v", act, exp)
+	}
+
+	// With delta temporality the counter is reset by each export.
+	_, metrics = otlpMetrics(t, o.exportRequest(time.Now()))
+	sum = protoFieldsByNum(t, metrics["input.count"], 7)[0].bytes
+	point = protoFieldsByNum(t, sum, 1)[0].bytes
+	if exp, act := uint64(0), protoFieldsByNum(t, point, 6)[0].varint; exp != act {
+		t.Errorf("Wrong counter value: %v != %v", act, exp)
+	}
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	var reqMut sync.Mutex
+	var reqBody []byte
+	var reqHeaders http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reqMut.Lock()
+		reqBody = body
+		reqHeaders = r.Header
+		reqMut.Unlock()
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeOTLP
+	conf.OTLP.Protocol = "http"
+	conf.OTLP.Endpoint = server.URL + "/v1/metrics"
+	conf.OTLP.Headers = map[string]string{"X-Foo": "bar"}
+	conf.OTLP.FlushPeriod = "1h"
+
+	o, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.GetCounter("input.count").Incr(1)
+	if err = o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+	if exp, act := "application/x-protobuf", reqHeaders.Get("Content-Type"); exp != act {
+		t.Errorf("Wrong content type: %v != %v", act, exp)
+	}
+	if exp, act := "bar", reqHeaders.Get("X-Foo"); exp != act {
+		t.Errorf("Wrong header: %v != %v", act, exp)
+	}
+	if _, metrics := otlpMetrics(t, reqBody); metrics["input.count"] == nil {
+		t.Errorf("Missing metric: %v", metrics)
+	}
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reqMut sync.Mutex
+	var reqMethod string
+	var reqBody []byte
+	server := grpc.NewServer(
+		grpc.CustomCodec(otlp.RawCodec{}),
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			var msg otlp.RawMessage
+			if err := stream.RecvMsg(&msg); err != nil {
+				return err
+			}
+			method, _ := grpc.MethodFromServerStream(stream)
+			reqMut.Lock()
+			reqMethod = method
+			reqBody = msg
+			reqMut.Unlock()
+			return stream.SendMsg(&otlp.RawMessage{})
+		}),
+	)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conf := NewConfig()
+	conf.Type = TypeOTLP
+	conf.OTLP.Endpoint = lis.Addr().String()
+	conf.OTLP.FlushPeriod = "1h"
+
+	o, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.GetGauge("input.gauge").Set(1)
+	if err = o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+	if exp, act := otlpExportMethod, reqMethod; exp != act {
+		t.Errorf("Wrong method: %v != %v", act, exp)
+	}
+	if _, metrics := otlpMetrics(t, reqBody); metrics["input.gauge"] == nil {
+		t.Errorf("Missing metric: %v", metrics)
+	}
+}
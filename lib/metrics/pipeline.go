@@ -0,0 +1,248 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypePipeline] = TypeSpec{
+		constructor: NewPipeline,
+		description: `
+EXPERIMENTAL: This component is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Converts metrics into messages and writes them to a Benthos
+` + "[output](../outputs/README.md)" + `, allowing metrics to be sent anywhere a
+Benthos output can reach, such as Kafka, Elasticsearch or an HTTP endpoint.
+
+Each push of metrics is written as a single batch of messages, where each message
+is a JSON object identical to those written by the ` + "[`stdout`](#stdout)" + `
+metrics type, grouped by the input/processor/output instance. The fields
+` + "`push_interval`, `push_every_n_messages`, `static_fields`, `flush_metrics`, `report_deltas`," + `
+` + "`include_patterns` and `exclude_patterns`" + ` behave the same way.
+
+The ` + "`timeout`" + ` field is the maximum period of time to wait for each batch
+to be acknowledged by the output, after which an error is logged and the batch
+is abandoned. The output does not emit metrics of its own.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// PipelineConfig contains configuration parameters for the Pipeline metrics
+// type.
+type PipelineConfig struct {
+	PushInterval       string                 `json:"push_interval" yaml:"push_interval"`
+	PushEveryNMessages int64                  `json:"push_every_n_messages" yaml:"push_every_n_messages"`
+	StaticFields       map[string]interface{} `json:"static_fields" yaml:"static_fields"`
+	FlushMetrics       bool                   `json:"flush_metrics" yaml:"flush_metrics"`
+	ReportDeltas       bool                   `json:"report_deltas" yaml:"report_deltas"`
+	IncludePatterns    []string               `json:"include_patterns" yaml:"include_patterns"`
+	ExcludePatterns    []string               `json:"exclude_patterns" yaml:"exclude_patterns"`
+	Timeout            string                 `json:"timeout" yaml:"timeout"`
+	Output             interface{}            `json:"output" yaml:"output"`
+}
+
+// NewPipelineConfig returns a new PipelineConfig with default values.
+func NewPipelineConfig() PipelineConfig {
+	return PipelineConfig{
+		PushInterval:       "10s",
+		PushEveryNMessages: 0,
+		StaticFields: map[string]interface{}{
+			"@service": "benthos",
+		},
+		FlushMetrics:    false,
+		ReportDeltas:    false,
+		IncludePatterns: []string{},
+		ExcludePatterns: []string{},
+		Timeout:         "5s",
+		Output: map[string]interface{}{
+			"type": "stdout",
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OutputConstructor creates an output from a generic output config
+// structure, as parsed from a config file.
+type OutputConstructor func(conf interface{}, log log.Modular) (types.Output, error)
+
+var (
+	outputCtor    OutputConstructor
+	outputCtorMut sync.RWMutex
+)
+
+// SetOutputConstructor sets the constructor used by the pipeline metrics type
+// in order to create outputs. This is called by the output package in order to
+// avoid a cyclic dependency.
+func SetOutputConstructor(ctor OutputConstructor) {
+	outputCtorMut.Lock()
+	outputCtor = ctor
+	outputCtorMut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// Pipeline is an object with capability to hold internal stats and write them
+// to an output as messages.
+type Pipeline struct {
+	*Stdout
+
+	output types.Output
+	writer *pipelineWriter
+}
+
+// NewPipeline creates and returns a new Pipeline metric object.
+func NewPipeline(config Config, opts ...func(Type)) (Type, error) {
+	outputCtorMut.RLock()
+	ctor := outputCtor
+	outputCtorMut.RUnlock()
+	if ctor == nil {
+		return nil, errors.New("outputs are not available to the pipeline metrics type")
+	}
+
+	timeout, err := time.ParseDuration(config.Pipeline.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	w := &pipelineWriter{
+		timeout:  timeout,
+		tranChan: make(chan types.Transaction),
+	}
+
+	stdoutConf := config
+	stdoutConf.Stdout = StdoutConfig{
+		PushInterval:       config.Pipeline.PushInterval,
+		PushEveryNMessages: config.Pipeline.PushEveryNMessages,
+		StaticFields:       config.Pipeline.StaticFields,
+		FlushMetrics:       config.Pipeline.FlushMetrics,
+		ReportDeltas:       config.Pipeline.ReportDeltas,
+		IncludePatterns:    config.Pipeline.IncludePatterns,
+		ExcludePatterns:    config.Pipeline.ExcludePatterns,
+		Format:             "json",
+	}
+
+	s, err := newStdout(stdoutConf, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	output, err := ctor(config.Pipeline.Output, s.log.NewModule(".output"))
+	if err == nil {
+		err = output.Consume(w.tranChan)
+	}
+	if err != nil {
+		w.discard()
+		s.Close()
+		return nil, fmt.Errorf("failed to create output: %v", err)
+	}
+	return &Pipeline{
+		Stdout: s,
+		output: output,
+		writer: w,
+	}, nil
+}
+
+// Close stops the Pipeline object from aggregating metrics, does a final write
+// of metrics and closes the output.
+func (p *Pipeline) Close() error {
+	err := p.Stdout.Close()
+	p.output.CloseAsync()
+	if cerr := p.output.WaitForClose(p.writer.timeout); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+// pipelineWriter buffers the lines written by a Stdout object and sends them as
+// a batch of messages to an output each time it is flushed.
+type pipelineWriter struct {
+	timeout  time.Duration
+	tranChan chan types.Transaction
+
+	parts     [][]byte
+	discarded bool
+	mut       sync.Mutex
+}
+
+// discard causes all subsequent writes to be dropped, which is used when the
+// output could not be created.
+func (w *pipelineWriter) discard() {
+	w.mut.Lock()
+	w.discarded = true
+	w.parts = nil
+	w.mut.Unlock()
+}
+
+// Write adds a line as a message part of the next batch.
+func (w *pipelineWriter) Write(p []byte) (int, error) {
+	w.mut.Lock()
+	if !w.discarded {
+		w.parts = append(w.parts, bytes.TrimSuffix(append([]byte(nil), p...), []byte("\n")))
+	}
+	w.mut.Unlock()
+	return len(p), nil
+}
+
+// Flush sends the buffered lines to the output as a batch and waits for it to
+// be acknowledged.
+func (w *pipelineWriter) Flush() error {
+	w.mut.Lock()
+	parts := w.parts
+	w.parts = nil
+	w.mut.Unlock()
+
+	if len(parts) == 0 {
+		return nil
+	}
+
+	timeout := time.After(w.timeout)
+	resChan := make(chan types.Response, 1)
+	select {
+	case w.tranChan <- types.NewTransaction(message.New(parts), resChan):
+	case <-timeout:
+		return types.ErrTimeout
+	}
+
+	select {
+	case res := <-resChan:
+		return res.Error()
+	case <-timeout:
+		return types.ErrTimeout
+	}
+}
+
+//------------------------------------------------------------------------------
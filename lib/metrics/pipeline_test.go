@@ -0,0 +1,154 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+type mockPipelineOutput struct {
+	conf     interface{}
+	batches  [][]string
+	closed   bool
+	doneChan chan struct{}
+	sync.Mutex
+}
+
+func (m *mockPipelineOutput) Consume(tranChan <-chan types.Transaction) error {
+	go func() {
+		defer close(m.doneChan)
+		for tran := range tranChan {
+			var batch []string
+			tran.Payload.Iter(func(i int, p types.Part) error {
+				batch = append(batch, string(p.Get()))
+				return nil
+			})
+			m.Lock()
+			m.batches = append(m.batches, batch)
+			m.Unlock()
+			tran.ResponseChan <- response.NewAck()
+		}
+	}()
+	return nil
+}
+
+func (m *mockPipelineOutput) Connected() bool {
+	return true
+}
+
+func (m *mockPipelineOutput) CloseAsync() {
+	m.Lock()
+	m.closed = true
+	m.Unlock()
+}
+
+func (m *mockPipelineOutput) WaitForClose(time.Duration) error {
+	return nil
+}
+
+func TestPipelineInterface(t *testing.T) {
+	o := &Pipeline{}
+	if Type(o) == nil {
+		t.Errorf("Pipeline does not satisfy Type interface")
+	}
+}
+
+func TestPipelineWritesBatches(t *testing.T) {
+	output := &mockPipelineOutput{doneChan: make(chan struct{})}
+	SetOutputConstructor(func(conf interface{}, log log.Modular) (types.Output, error) {
+		output.conf = conf
+		return output, nil
+	})
+	defer SetOutputConstructor(nil)
+
+	conf := NewConfig()
+	conf.Type = TypePipeline
+	conf.Pipeline.PushInterval = ""
+	conf.Pipeline.IncludePatterns = []string{`^input\.`, `^output\.`}
+	conf.Pipeline.Output = map[string]interface{}{"type": "foo"}
+
+	p, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.GetCounter("input.count").Incr(2)
+	p.GetCounter("output.count").Incr(1)
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output.Lock()
+	defer output.Unlock()
+	if !output.closed {
+		t.Error("Expected output to be closed")
+	}
+	if exp, act := "foo", output.conf.(map[string]interface{})["type"]; exp != act {
+		t.Errorf("Wrong output config: %v != %v", act, exp)
+	}
+	if exp, act := 1, len(output.batches); exp != act {
+		t.Fatalf("Wrong count of batches: %v != %v", act, exp)
+	}
+	if exp, act := 2, len(output.batches[0]); exp != act {
+		t.Fatalf("Wrong count of messages: %v != %v", act, exp)
+	}
+	objs := map[string]map[string]interface{}{}
+	for _, msg := range output.batches[0] {
+		var obj map[string]interface{}
+		if err = json.Unmarshal([]byte(msg), &obj); err != nil {
+			t.Fatal(err)
+		}
+		objs[obj["metric"].(string)] = obj
+	}
+	if exp, act := float64(2), objs["input"]["input"].(map[string]interface{})["count"]; exp != act {
+		t.Errorf("Wrong metric value: %v != %v", act, exp)
+	}
+}
+
+func TestPipelineNoOutputConstructor(t *testing.T) {
+	SetOutputConstructor(nil)
+
+	conf := NewConfig()
+	conf.Type = TypePipeline
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error without output constructor")
+	}
+}
+
+func TestPipelineOutputError(t *testing.T) {
+	SetOutputConstructor(func(conf interface{}, log log.Modular) (types.Output, error) {
+		return nil, errors.New("nope")
+	})
+	defer SetOutputConstructor(nil)
+
+	conf := NewConfig()
+	conf.Type = TypePipeline
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from output constructor")
+	}
+}
@@ -171,7 +171,7 @@ func NewPrometheus(config Config, opts ...func(Type)) (Type, error) {
 				case <-p.closedChan:
 					return
 				case <-time.After(interval):
-					if err = push.New(p.config.PushURL, p.config.PushJobName).Gatherer(prometheus.DefaultGatherer).Push(); err != nil {
+					if err = p.pusher().Push(); err != nil {
 						p.log.Errorf("Failed to push metrics: %v\n", err)
 					}
 				}
@@ -184,6 +184,15 @@ func NewPrometheus(config Config, opts ...func(Type)) (Type, error) {
 
 //------------------------------------------------------------------------------
 
+// pusher returns a Pusher for pushing metrics to the configured Push Gateway.
+func (p *Prometheus) pusher() *push.Pusher {
+	pusher := push.New(p.config.PushURL, p.config.PushJobName).Gatherer(prometheus.DefaultGatherer)
+	for k, v := range p.config.PushGroupingLabels {
+		pusher = pusher.Grouping(k, v)
+	}
+	return pusher
+}
+
 // HandlerFunc returns an http.HandlerFunc for scraping metrics.
 func (p *Prometheus) HandlerFunc() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -351,6 +360,18 @@ func (p *Prometheus) GetGaugeVec(path string, labelNames []string) StatGaugeVec
 	}
 }
 
+// flushNow pushes metrics to the Push Gateway immediately, returns false if a
+// Push Gateway is not configured.
+func (p *Prometheus) flushNow() bool {
+	if len(p.config.PushURL) == 0 {
+		return false
+	}
+	if err := p.pusher().Push(); err != nil {
+		p.log.Errorf("Failed to push metrics: %v\n", err)
+	}
+	return true
+}
+
 // SetLogger does nothing.
 func (p *Prometheus) SetLogger(log log.Modular) {
 	p.log = log
@@ -363,7 +384,7 @@ func (p *Prometheus) Close() error {
 		close(p.closedChan)
 	}
 	if len(p.config.PushURL) > 0 {
-		return push.New(p.config.PushURL, p.config.PushJobName).Gatherer(prometheus.DefaultGatherer).Push()
+		return p.pusher().Push()
 	}
 	return nil
 }
@@ -37,6 +37,12 @@ metrics to a [Prometheus Push Gateway](https://prometheus.io/docs/instrumenting/
 once Benthos shuts down. It is also possible to specify a
 ` + "`push_interval`" + ` which results in periodic pushes.
 
+Metrics are pushed under the job ` + "`push_job_name`" + `, and the optional
+` + "`push_grouping_labels`" + ` are added to the grouping key of the push,
+e.g. an ` + "`instance`" + ` label allows the metrics of multiple Benthos
+instances of the same job to be stored separately rather than overwriting each
+other.
+
 The Push Gateway This is useful for when Benthos instances are short lived. Do
 not include the "/metrics/jobs/..." path in the push URL.`,
 	}
@@ -46,19 +52,21 @@ not include the "/metrics/jobs/..." path in the push URL.`,
 
 // PrometheusConfig is config for the Prometheus metrics type.
 type PrometheusConfig struct {
-	Prefix       string `json:"prefix" yaml:"prefix"`
-	PushURL      string `json:"push_url" yaml:"push_url"`
-	PushInterval string `json:"push_interval" yaml:"push_interval"`
-	PushJobName  string `json:"push_job_name" yaml:"push_job_name"`
+	Prefix             string            `json:"prefix" yaml:"prefix"`
+	PushURL            string            `json:"push_url" yaml:"push_url"`
+	PushInterval       string            `json:"push_interval" yaml:"push_interval"`
+	PushJobName        string            `json:"push_job_name" yaml:"push_job_name"`
+	PushGroupingLabels map[string]string `json:"push_grouping_labels" yaml:"push_grouping_labels"`
 }
 
 // NewPrometheusConfig creates an PrometheusConfig struct with default values.
 func NewPrometheusConfig() PrometheusConfig {
 	return PrometheusConfig{
-		Prefix:       "benthos",
-		PushURL:      "",
-		PushInterval: "",
-		PushJobName:  "benthos_push",
+		Prefix:             "benthos",
+		PushURL:            "",
+		PushInterval:       "",
+		PushJobName:        "benthos_push",
+		PushGroupingLabels: map[string]string{},
 	}
 }
 
@@ -335,4 +335,21 @@ func (r *Rename) HandlerFunc() http.HandlerFunc {
 	}
 }
 
+// jsonHandlerFunc returns the grouped JSON metrics handler of the child type,
+// if it has one.
+func (h *Rename) jsonHandlerFunc() (http.HandlerFunc, bool) {
+	return JSONHandlerFunc(h.s)
+}
+
+// flushNow flushes the metrics of the child type, if it pushes metrics.
+func (h *Rename) flushNow() bool {
+	return Flush(h.s)
+}
+
+// resetNow resets the metrics of the child type, if it aggregates metrics
+// locally.
+func (h *Rename) resetNow() bool {
+	return Reset(h.s)
+}
+
 //------------------------------------------------------------------------------
@@ -0,0 +1,42 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+//------------------------------------------------------------------------------
+
+// resetter is implemented by types that aggregate metrics locally and are able
+// to zero them on demand.
+type resetter interface {
+	resetNow() bool
+}
+
+// Reset zeroes the counters and timers of a Type that aggregates metrics
+// locally, such as stdout, allowing them to be re-baselined without restarting
+// the process. Gauges are left unchanged. Returns false if the Type does not
+// aggregate metrics locally.
+func Reset(t Type) bool {
+	if r, ok := t.(resetter); ok {
+		return r.resetNow()
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
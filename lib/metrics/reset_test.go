@@ -0,0 +1,66 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"testing"
+)
+
+func TestResetUnsupported(t *testing.T) {
+	if Reset(Noop()) {
+		t.Error("Expected noop type not to reset")
+	}
+	if Reset(&Whitelist{s: Noop()}) {
+		t.Error("Expected wrapped noop type not to reset")
+	}
+}
+
+func TestResetStdoutDeltas(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.ReportDeltas = true
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctr := s.GetCounter("input.count")
+	ctr.Incr(5)
+	s.publishMetrics()
+
+	if !Reset(&Whitelist{s: s}) {
+		t.Fatal("Expected reset")
+	}
+	if act := s.local.GetCounters()["input.count"]; act != 0 {
+		t.Errorf("Wrong counter value after reset: %v != 0", act)
+	}
+
+	buf.Reset()
+	ctr.Incr(2)
+	s.publishMetrics()
+
+	obj := parseStdoutLines(t, buf.Lines())["input"]["input"].(map[string]interface{})
+	if exp, act := float64(2), obj["count"]; exp != act {
+		t.Errorf("Wrong counter delta after reset: %v != %v", act, exp)
+	}
+}
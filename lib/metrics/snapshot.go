@@ -0,0 +1,357 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+//------------------------------------------------------------------------------
+
+// snapshotReservoirSize is the maximum number of timing samples retained for
+// each timer between snapshots.
+const snapshotReservoirSize = 1024
+
+// snapshotStat is a single metric stat registered within a snapshotStore.
+// Interactions with this stat are thread safe.
+type snapshotStat struct {
+	path   string
+	names  []string
+	values []string
+
+	value    int64
+	reported int64
+
+	samples []int64
+	count   int64
+	sum     int64
+	min     int64
+	max     int64
+
+	bounds       []int64
+	buckets      []int64
+	totalCount   int64
+	totalSum     int64
+	totalBuckets []int64
+
+	mut sync.Mutex
+}
+
+// Incr increments a metric by an amount.
+func (s *snapshotStat) Incr(count int64) error {
+	atomic.AddInt64(&s.value, count)
+	return nil
+}
+
+// Decr decrements a metric by an amount.
+func (s *snapshotStat) Decr(count int64) error {
+	atomic.AddInt64(&s.value, -count)
+	return nil
+}
+
+// Set sets a gauge metric.
+func (s *snapshotStat) Set(value int64) error {
+	atomic.StoreInt64(&s.value, value)
+	return nil
+}
+
+// Timing records a timing sample.
+func (s *snapshotStat) Timing(delta int64) error {
+	s.mut.Lock()
+	if s.count == 0 || delta < s.min {
+		s.min = delta
+	}
+	if s.count == 0 || delta > s.max {
+		s.max = delta
+	}
+	s.count++
+	s.sum += delta
+	s.totalCount++
+	s.totalSum += delta
+	if len(s.bounds) > 0 {
+		i := sort.Search(len(s.bounds), func(i int) bool {
+			return delta <= s.bounds[i]
+		})
+		s.buckets[i]++
+		s.totalBuckets[i]++
+	}
+	if len(s.samples) < snapshotReservoirSize {
+		s.samples = append(s.samples, delta)
+	} else if i := rand.Int63n(s.count); i < snapshotReservoirSize {
+		s.samples[i] = delta
+	}
+	s.mut.Unlock()
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// timingSummary is a summary of the timing samples recorded by a timer since
+// the previous snapshot.
+type timingSummary struct {
+	Count  int64
+	Sum    int64
+	Min    int64
+	Max    int64
+	sorted []int64
+
+	// Buckets are the counts of timings recorded since the previous snapshot
+	// within each histogram bucket, where the final count is of timings
+	// exceeding the upper bound of all buckets.
+	Buckets []int64
+
+	// TotalCount, TotalSum and TotalBuckets are the equivalents of Count, Sum
+	// and Buckets for all timings recorded since the timer was registered.
+	TotalCount   int64
+	TotalSum     int64
+	TotalBuckets []int64
+}
+
+// Mean returns the mean of all timings.
+func (t timingSummary) Mean() float64 {
+	if t.Count == 0 {
+		return 0
+	}
+	return float64(t.Sum) / float64(t.Count)
+}
+
+// Percentile returns an estimate of a percentile (between 0 and 1) of the
+// timings.
+func (t timingSummary) Percentile(q float64) int64 {
+	if len(t.sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(t.sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(t.sorted) {
+		i = len(t.sorted) - 1
+	}
+	return t.sorted[i]
+}
+
+// metricSnapshot is the state of a single metric at the time of a snapshot.
+type metricSnapshot struct {
+	Path        string
+	LabelNames  []string
+	LabelValues []string
+
+	// Value is the current value of a counter or gauge.
+	Value int64
+
+	// Delta is the change in value of a counter since the previous snapshot.
+	Delta int64
+
+	// Timing is a summary of timings recorded since the previous snapshot.
+	Timing timingSummary
+}
+
+// Labels returns the labels of the metric as a map.
+func (m metricSnapshot) Labels() map[string]string {
+	labels := make(map[string]string, len(m.LabelNames))
+	for i, k := range m.LabelNames {
+		labels[k] = m.LabelValues[i]
+	}
+	return labels
+}
+
+// sortedKeys returns the keys of a map of labels in sorted order.
+func sortedKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//------------------------------------------------------------------------------
+
+// snapshotStore holds metrics registered by push based metrics types, where
+// each combination of path and label values is tracked separately, until
+// they're periodically collected with a snapshot.
+type snapshotStore struct {
+	counters map[string]*snapshotStat
+	gauges   map[string]*snapshotStat
+	timers   map[string]*snapshotStat
+
+	// bucketBounds are the inclusive upper bounds of histogram buckets tracked
+	// for each timer, if empty then no buckets are tracked.
+	bucketBounds []int64
+
+	sync.Mutex
+}
+
+func newSnapshotStore() *snapshotStore {
+	return &snapshotStore{
+		counters: map[string]*snapshotStat{},
+		gauges:   map[string]*snapshotStat{},
+		timers:   map[string]*snapshotStat{},
+	}
+}
+
+func (s *snapshotStore) get(stats map[string]*snapshotStat, path string, names, values []string) *snapshotStat {
+	key := path
+	if len(names) > 0 {
+		key = path + "\x00" + labelKey(names, values)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	st, exists := stats[key]
+	if !exists {
+		st = &snapshotStat{
+			path:   path,
+			names:  make([]string, len(names)),
+			values: make([]string, len(names)),
+		}
+		copy(st.names, names)
+		copy(st.values, values)
+		if len(s.bucketBounds) > 0 {
+			st.bounds = s.bucketBounds
+			st.buckets = make([]int64, len(s.bucketBounds)+1)
+			st.totalBuckets = make([]int64, len(s.bucketBounds)+1)
+		}
+		stats[key] = st
+	}
+	return st
+}
+
+// GetCounter returns a stat counter object for a path.
+func (s *snapshotStore) GetCounter(path string) StatCounter {
+	return s.get(s.counters, path, nil, nil)
+}
+
+// GetCounterVec returns a stat counter object for a path with the labels and
+// values.
+func (s *snapshotStore) GetCounterVec(path string, n []string) StatCounterVec {
+	return fakeCounterVec(func(v []string) StatCounter {
+		return s.get(s.counters, path, n, v)
+	})
+}
+
+// GetTimer returns a stat timer object for a path.
+func (s *snapshotStore) GetTimer(path string) StatTimer {
+	return s.get(s.timers, path, nil, nil)
+}
+
+// GetTimerVec returns a stat timer object for a path with the labels and
+// values.
+func (s *snapshotStore) GetTimerVec(path string, n []string) StatTimerVec {
+	return fakeTimerVec(func(v []string) StatTimer {
+		return s.get(s.timers, path, n, v)
+	})
+}
+
+// GetGauge returns a stat gauge object for a path.
+func (s *snapshotStore) GetGauge(path string) StatGauge {
+	return s.get(s.gauges, path, nil, nil)
+}
+
+// GetGaugeVec returns a stat gauge object for a path with the labels and
+// values.
+func (s *snapshotStore) GetGaugeVec(path string, n []string) StatGaugeVec {
+	return fakeGaugeVec(func(v []string) StatGauge {
+		return s.get(s.gauges, path, n, v)
+	})
+}
+
+func sortedSnapshot(stats map[string]*snapshotStat, fn func(st *snapshotStat) metricSnapshot) []metricSnapshot {
+	keys := make([]string, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	snaps := make([]metricSnapshot, 0, len(keys))
+	for _, k := range keys {
+		st := stats[k]
+		snap := fn(st)
+		snap.Path = st.path
+		snap.LabelNames = st.names
+		snap.LabelValues = st.values
+		snaps = append(snaps, snap)
+	}
+	return snaps
+}
+
+// snapshot returns the current state of all counters, gauges and timers. The
+// delta of each counter and the timing samples of each timer are reset by
+// each snapshot.
+func (s *snapshotStore) snapshot() (counters, gauges, timers []metricSnapshot) {
+	s.Lock()
+	defer s.Unlock()
+
+	counters = sortedSnapshot(s.counters, func(st *snapshotStat) metricSnapshot {
+		v := atomic.LoadInt64(&st.value)
+		prev := atomic.SwapInt64(&st.reported, v)
+		return metricSnapshot{
+			Value: v,
+			Delta: v - prev,
+		}
+	})
+	gauges = sortedSnapshot(s.gauges, func(st *snapshotStat) metricSnapshot {
+		return metricSnapshot{
+			Value: atomic.LoadInt64(&st.value),
+		}
+	})
+	timers = sortedSnapshot(s.timers, func(st *snapshotStat) metricSnapshot {
+		st.mut.Lock()
+		summary := timingSummary{
+			Count:  st.count,
+			Sum:    st.sum,
+			Min:    st.min,
+			Max:    st.max,
+			sorted: st.samples,
+
+			TotalCount: st.totalCount,
+			TotalSum:   st.totalSum,
+		}
+		if len(st.buckets) > 0 {
+			summary.Buckets = make([]int64, len(st.buckets))
+			summary.TotalBuckets = make([]int64, len(st.totalBuckets))
+			copy(summary.Buckets, st.buckets)
+			copy(summary.TotalBuckets, st.totalBuckets)
+			for i := range st.buckets {
+				st.buckets[i] = 0
+			}
+		}
+		st.samples = nil
+		st.count, st.sum, st.min, st.max = 0, 0, 0, 0
+		st.mut.Unlock()
+
+		sort.Slice(summary.sorted, func(i, j int) bool {
+			return summary.sorted[i] < summary.sorted[j]
+		})
+		return metricSnapshot{
+			Timing: summary,
+		}
+	})
+	return
+}
+
+//------------------------------------------------------------------------------
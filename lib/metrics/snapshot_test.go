@@ -0,0 +1,119 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotStoreCounters(t *testing.T) {
+	s := newSnapshotStore()
+
+	s.GetCounter("foo").Incr(2)
+	s.GetCounterVec("bar", []string{"a"}).With("x").Incr(3)
+	s.GetCounterVec("bar", []string{"a"}).With("y").Incr(1)
+
+	counters, _, _ := s.snapshot()
+	if exp, act := 3, len(counters); exp != act {
+		t.Fatalf("Wrong count of counters: %v != %v", act, exp)
+	}
+	if exp, act := map[string]string{"a": "x"}, counters[0].Labels(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong labels: %v != %v", act, exp)
+	}
+	if exp, act := int64(3), counters[0].Delta; exp != act {
+		t.Errorf("Wrong delta: %v != %v", act, exp)
+	}
+
+	s.GetCounterVec("bar", []string{"a"}).With("x").Incr(4)
+	counters, _, _ = s.snapshot()
+	if exp, act := int64(7), counters[0].Value; exp != act {
+		t.Errorf("Wrong value: %v != %v", act, exp)
+	}
+	if exp, act := int64(4), counters[0].Delta; exp != act {
+		t.Errorf("Wrong delta: %v != %v", act, exp)
+	}
+	if exp, act := int64(0), counters[2].Delta; exp != act {
+		t.Errorf("Wrong delta: %v != %v", act, exp)
+	}
+}
+
+func TestSnapshotStoreTimers(t *testing.T) {
+	s := newSnapshotStore()
+
+	timer := s.GetTimer("foo")
+	for i := int64(1); i <= 100; i++ {
+		timer.Timing(i)
+	}
+
+	_, _, timers := s.snapshot()
+	if exp, act := 1, len(timers); exp != act {
+		t.Fatalf("Wrong count of timers: %v != %v", act, exp)
+	}
+	summary := timers[0].Timing
+	if exp, act := int64(100), summary.Count; exp != act {
+		t.Errorf("Wrong count: %v != %v", act, exp)
+	}
+	if exp, act := 50.5, summary.Mean(); exp != act {
+		t.Errorf("Wrong mean: %v != %v", act, exp)
+	}
+	if exp, act := int64(1), summary.Min; exp != act {
+		t.Errorf("Wrong min: %v != %v", act, exp)
+	}
+	if exp, act := int64(50), summary.Percentile(0.5); exp != act {
+		t.Errorf("Wrong p50: %v != %v", act, exp)
+	}
+	if exp, act := int64(99), summary.Percentile(0.99); exp != act {
+		t.Errorf("Wrong p99: %v != %v", act, exp)
+	}
+
+	_, _, timers = s.snapshot()
+	if exp, act := int64(0), timers[0].Timing.Count; exp != act {
+		t.Errorf("Wrong count after reset: %v != %v", act, exp)
+	}
+}
+
+func TestSnapshotStoreBuckets(t *testing.T) {
+	s := newSnapshotStore()
+	s.bucketBounds = []int64{10, 20}
+
+	timer := s.GetTimer("foo")
+	for _, v := range []int64{5, 10, 15, 25, 30} {
+		timer.Timing(v)
+	}
+
+	_, _, timers := s.snapshot()
+	if exp, act := []int64{2, 1, 2}, timers[0].Timing.Buckets; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong buckets: %v != %v", act, exp)
+	}
+
+	timer.Timing(1)
+	_, _, timers = s.snapshot()
+	if exp, act := []int64{1, 0, 0}, timers[0].Timing.Buckets; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong buckets: %v != %v", act, exp)
+	}
+	if exp, act := []int64{3, 1, 2}, timers[0].Timing.TotalBuckets; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong total buckets: %v != %v", act, exp)
+	}
+	if exp, act := int64(86), timers[0].Timing.TotalSum; exp != act {
+		t.Errorf("Wrong total sum: %v != %v", act, exp)
+	}
+}
@@ -23,6 +23,9 @@ package metrics
 import (
 	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -36,7 +39,44 @@ func init() {
 		constructor: NewStatsd,
 		description: `
 Push metrics over a TCP or UDP connection using the
-[StatsD protocol](https://github.com/statsd/statsd).`,
+[StatsD protocol](https://github.com/statsd/statsd).
+
+### Timing Types
+
+The default timing type ` + "`aggregate`" + ` collects timings over each flush
+period and sends the ` + "`count`, `avg`, `min` and `max`" + ` of a timer. Setting
+` + "`timing_type`" + ` to ` + "`histogram` or `distribution`" + ` instead
+sends each timing individually using the native histogram (` + "`h`" + `) or
+distribution (` + "`d`" + `) metric types, allowing the daemon to calculate
+percentiles. Setting it to ` + "`timing`" + ` sends each timing individually
+using the ` + "`ms`" + ` type.
+
+### Sample Rates
+
+Sending timings individually from a high throughput pipeline can overwhelm a
+StatsD daemon, and therefore ` + "`sample_rates`" + ` can be used to only send a
+fraction of them. Each key is a metric path prefix and its value is a sample
+rate between 0 and 1, where the longest prefix that matches a timing path is
+used. Timings are sent with their sample rate so that the daemon is able to
+scale its calculations.
+
+For example, the following config sends 10% of the processor latencies and 50%
+of all other timings from the pipeline:
+
+` + "``` yaml" + `
+metrics:
+  type: statsd
+  statsd:
+    address: localhost:8125
+    timing_type: distribution
+    sample_rates:
+      pipeline: 0.5
+      pipeline.processor: 0.1
+` + "```" + `
+
+Counters and gauges are aggregated before being sent and are therefore never
+sampled. When the timing type is ` + "`aggregate`" + ` any timings with a
+sample rate below 1 are sent individually using the ` + "`ms`" + ` type.`,
 	}
 }
 
@@ -54,10 +94,12 @@ func (w *wrappedLogger) Println(v ...interface{}) {
 
 // StatsdConfig is config for the Statsd metrics type.
 type StatsdConfig struct {
-	Prefix      string `json:"prefix" yaml:"prefix"`
-	Address     string `json:"address" yaml:"address"`
-	FlushPeriod string `json:"flush_period" yaml:"flush_period"`
-	Network     string `json:"network" yaml:"network"`
+	Prefix      string             `json:"prefix" yaml:"prefix"`
+	Address     string             `json:"address" yaml:"address"`
+	FlushPeriod string             `json:"flush_period" yaml:"flush_period"`
+	Network     string             `json:"network" yaml:"network"`
+	TimingType  string             `json:"timing_type" yaml:"timing_type"`
+	SampleRates map[string]float64 `json:"sample_rates" yaml:"sample_rates"`
 }
 
 // NewStatsdConfig creates an StatsdConfig struct with default values.
@@ -67,6 +109,8 @@ func NewStatsdConfig() StatsdConfig {
 		Address:     "localhost:4040",
 		FlushPeriod: "100ms",
 		Network:     "udp",
+		TimingType:  "aggregate",
+		SampleRates: map[string]float64{},
 	}
 }
 
@@ -103,14 +147,40 @@ func (s *StatsdStat) Set(value int64) error {
 	return nil
 }
 
+// statsdRawTimer is a timer stat where each timing is written individually,
+// optionally sampled.
+type statsdRawTimer struct {
+	name string
+	kind string
+	rate float64
+	w    *statsdWriter
+}
+
+// Timing sets a timing metric.
+func (s *statsdRawTimer) Timing(delta int64) error {
+	line := s.name + ":" + strconv.FormatInt(delta, 10) + "|" + s.kind
+	if s.rate < 1 {
+		if rand.Float64() >= s.rate {
+			return nil
+		}
+		line += "|@" + strconv.FormatFloat(s.rate, 'f', -1, 64)
+	}
+	s.w.write(line)
+	return nil
+}
+
 //------------------------------------------------------------------------------
 
 // Statsd is a stats object with capability to hold internal stats as a JSON
 // endpoint.
 type Statsd struct {
-	config Config
-	s      statsd.Statsd
-	log    log.Modular
+	config      Config
+	prefix      string
+	kind        string
+	flushPeriod time.Duration
+	s           statsd.Statsd
+	w           *statsdWriter
+	log         log.Modular
 }
 
 // NewStatsd creates and returns a new Statsd object.
@@ -119,9 +189,28 @@ func NewStatsd(config Config, opts ...func(Type)) (Type, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse flush period: %s", err)
 	}
+	var kind string
+	switch config.Statsd.TimingType {
+	case "aggregate":
+	case "timing":
+		kind = "ms"
+	case "histogram":
+		kind = "h"
+	case "distribution":
+		kind = "d"
+	default:
+		return nil, fmt.Errorf("timing type not recognised: %v", config.Statsd.TimingType)
+	}
+	for k, v := range config.Statsd.SampleRates {
+		if v <= 0 || v > 1 {
+			return nil, fmt.Errorf("sample rate of path '%v' must be greater than 0 and no more than 1: %v", k, v)
+		}
+	}
 	s := &Statsd{
-		config: config,
-		log:    log.New(ioutil.Discard, log.Config{LogLevel: "OFF"}),
+		config:      config,
+		kind:        kind,
+		flushPeriod: flushPeriod,
+		log:         log.New(ioutil.Discard, log.Config{LogLevel: "OFF"}),
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -131,6 +220,7 @@ func NewStatsd(config Config, opts ...func(Type)) (Type, error) {
 	if len(prefix) > 0 && prefix[len(prefix)-1] != '.' {
 		prefix = prefix + "."
 	}
+	s.prefix = prefix
 
 	statsdclient := statsd.NewStatsdBuffer(
 		flushPeriod,
@@ -147,9 +237,54 @@ func NewStatsd(config Config, opts ...func(Type)) (Type, error) {
 		}
 	}
 	s.s = statsdclient
+
+	if len(kind) > 0 || len(config.Statsd.SampleRates) > 0 {
+		network := "udp"
+		if config.Statsd.Network != "udp" {
+			network = "tcp"
+		}
+		if s.w, err = newStatsdWriter(network, config.Statsd.Address, flushPeriod, func(err error) {
+			s.log.Debugf("Failed to send metrics: %v\n", err)
+		}); err != nil {
+			statsdclient.Close()
+			return nil, err
+		}
+	}
 	return s, nil
 }
 
+// sampleRate returns the sample rate of the longest path prefix that matches
+// a path, or 1 if there are none.
+func (h *Statsd) sampleRate(path string) float64 {
+	rate, matched := 1.0, -1
+	for p, r := range h.config.Statsd.SampleRates {
+		if len(p) > matched && strings.HasPrefix(path, p) {
+			rate, matched = r, len(p)
+		}
+	}
+	return rate
+}
+
+func (h *Statsd) newTimer(path string) StatTimer {
+	rate := h.sampleRate(path)
+	if len(h.kind) == 0 && rate >= 1 {
+		return &StatsdStat{
+			path: path,
+			s:    h.s,
+		}
+	}
+	kind := h.kind
+	if len(kind) == 0 {
+		kind = "ms"
+	}
+	return &statsdRawTimer{
+		name: h.prefix + path,
+		kind: kind,
+		rate: rate,
+		w:    h.w,
+	}
+}
+
 //------------------------------------------------------------------------------
 
 // GetCounter returns a stat counter object for a path.
@@ -173,20 +308,14 @@ func (h *Statsd) GetCounterVec(path string, n []string) StatCounterVec {
 
 // GetTimer returns a stat timer object for a path.
 func (h *Statsd) GetTimer(path string) StatTimer {
-	return &StatsdStat{
-		path: path,
-		s:    h.s,
-	}
+	return h.newTimer(path)
 }
 
 // GetTimerVec returns a stat timer object for a path with the labels
 // discarded.
 func (h *Statsd) GetTimerVec(path string, n []string) StatTimerVec {
 	return fakeTimerVec(func([]string) StatTimer {
-		return &StatsdStat{
-			path: path,
-			s:    h.s,
-		}
+		return h.newTimer(path)
 	})
 }
 
@@ -209,6 +338,17 @@ func (h *Statsd) GetGaugeVec(path string, n []string) StatGaugeVec {
 	})
 }
 
+// flushNow sends timings written individually immediately. The client used
+// for aggregated metrics can't be flushed on demand, and therefore this blocks
+// for a flush period, by which point they will have been sent.
+func (h *Statsd) flushNow() bool {
+	if h.w != nil {
+		h.w.flush()
+	}
+	<-time.After(h.flushPeriod)
+	return true
+}
+
 // SetLogger sets the logger used to print connection errors.
 func (h *Statsd) SetLogger(log log.Modular) {
 	h.log = log
@@ -218,6 +358,9 @@ func (h *Statsd) SetLogger(log log.Modular) {
 // resources.
 func (h *Statsd) Close() error {
 	h.s.Close()
+	if h.w != nil {
+		h.w.close()
+	}
 	return nil
 }
 
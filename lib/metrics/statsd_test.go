@@ -0,0 +1,177 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeStatsd
+	conf.Statsd.TimingType = "nope"
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from bad timing type")
+	}
+
+	conf = NewConfig()
+	conf.Type = TypeStatsd
+	conf.Statsd.SampleRates = map[string]float64{"foo": 1.5}
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from bad sample rate")
+	}
+
+	conf.Statsd.SampleRates = map[string]float64{"foo": 0}
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from zero sample rate")
+	}
+}
+
+func TestStatsdSampleRate(t *testing.T) {
+	conf := NewConfig()
+	conf.Statsd.SampleRates = map[string]float64{
+		"pipeline":           0.5,
+		"pipeline.processor": 0.1,
+	}
+	s := &Statsd{config: conf}
+
+	tests := map[string]float64{
+		"pipeline.processor.0.latency": 0.1,
+		"pipeline.latency":             0.5,
+		"input.latency":                1,
+	}
+	for path, exp := range tests {
+		if act := s.sampleRate(path); act != exp {
+			t.Errorf("Wrong sample rate for %v: %v != %v", path, act, exp)
+		}
+	}
+}
+
+func readStatsdLines(t *testing.T, conn net.PacketConn) []string {
+	t.Helper()
+
+	var lines []string
+	buf := make([]byte, statsdMaxPacketSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return lines
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(string(buf[:n])), "\n")...)
+	}
+}
+
+func TestStatsdDistribution(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeStatsd
+	conf.Statsd.Address = conn.LocalAddr().String()
+	conf.Statsd.FlushPeriod = "1h"
+	conf.Statsd.TimingType = "distribution"
+	conf.Statsd.SampleRates = map[string]float64{
+		"input.sampled": 0.5,
+	}
+
+	s, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GetTimer("input.latency").Timing(10)
+	s.GetTimerVec("output.latency", []string{"topic"}).With("foo").Timing(20)
+	sampled := s.GetTimer("input.sampled.latency")
+	for i := 0; i < 100; i++ {
+		sampled.Timing(30)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readStatsdLines(t, conn)
+
+	var latencies, sampledLatencies []string
+	for _, l := range lines {
+		if strings.HasPrefix(l, "benthos.input.sampled.latency:") {
+			sampledLatencies = append(sampledLatencies, l)
+		} else {
+			latencies = append(latencies, l)
+		}
+	}
+
+	exp := []string{
+		"benthos.input.latency:10|d",
+		"benthos.output.latency:20|d",
+	}
+	if strings.Join(latencies, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("Wrong metrics: %q != %q", latencies, exp)
+	}
+	if len(sampledLatencies) == 0 || len(sampledLatencies) == 100 {
+		t.Errorf("Expected a sample of timings, received %v", len(sampledLatencies))
+	}
+	for _, l := range sampledLatencies {
+		if exp := "benthos.input.sampled.latency:30|d|@0.5"; l != exp {
+			t.Errorf("Wrong sampled metric: %v != %v", l, exp)
+		}
+	}
+}
+
+func TestStatsdAggregateSampled(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeStatsd
+	conf.Statsd.Address = conn.LocalAddr().String()
+	conf.Statsd.FlushPeriod = "1h"
+	conf.Statsd.SampleRates = map[string]float64{
+		"input.latency": 0.999999,
+	}
+
+	s, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GetTimer("input.latency").Timing(10)
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readStatsdLines(t, conn)
+	if len(lines) > 1 {
+		t.Fatalf("Expected at most one metric, received: %q", lines)
+	}
+	for _, l := range lines {
+		if exp := "benthos.input.latency:10|ms|@0.999999"; l != exp {
+			t.Errorf("Wrong metric: %v != %v", l, exp)
+		}
+	}
+}
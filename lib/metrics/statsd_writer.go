@@ -0,0 +1,134 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// statsdMaxPacketSize is the maximum size of a buffer of metrics written in
+// one go.
+const statsdMaxPacketSize = 1432
+
+// statsdWriter buffers newline delimited metric lines and writes them to a
+// connection either periodically or once the buffer would exceed the maximum
+// packet size.
+type statsdWriter struct {
+	conn  net.Conn
+	onErr func(err error)
+
+	buf    bytes.Buffer
+	bufMut sync.Mutex
+
+	closeOnce  sync.Once
+	closedChan chan struct{}
+	doneChan   chan struct{}
+}
+
+// newStatsdWriter dials a connection and starts a goroutine that flushes
+// buffered lines at the provided period.
+func newStatsdWriter(
+	network, address string,
+	flushPeriod time.Duration,
+	onErr func(err error),
+) (*statsdWriter, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	w := &statsdWriter{
+		conn:       conn,
+		onErr:      onErr,
+		closedChan: make(chan struct{}),
+		doneChan:   make(chan struct{}),
+	}
+	go w.loop(flushPeriod)
+	return w, nil
+}
+
+// write adds a metric line to the buffer, flushing the buffer first if the
+// line would exceed the maximum packet size.
+func (w *statsdWriter) write(line string) {
+	w.bufMut.Lock()
+	defer w.bufMut.Unlock()
+
+	if w.buf.Len() > 0 && w.buf.Len()+len(line)+1 > statsdMaxPacketSize {
+		w.flushBuffer()
+	}
+	w.buf.WriteString(line)
+	w.buf.WriteByte('\n')
+}
+
+// flushBuffer writes any buffered metrics to the connection. The buffer mutex
+// must be held by the caller.
+func (w *statsdWriter) flushBuffer() {
+	if w.buf.Len() == 0 {
+		return
+	}
+	if _, err := w.conn.Write(w.buf.Bytes()); err != nil {
+		w.onErr(err)
+	}
+	w.buf.Reset()
+}
+
+// flush writes any buffered metrics to the connection immediately.
+func (w *statsdWriter) flush() {
+	w.bufMut.Lock()
+	w.flushBuffer()
+	w.bufMut.Unlock()
+}
+
+func (w *statsdWriter) loop(flushPeriod time.Duration) {
+	defer close(w.doneChan)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.closedChan:
+			w.bufMut.Lock()
+			w.flushBuffer()
+			w.bufMut.Unlock()
+			return
+		}
+		w.bufMut.Lock()
+		w.flushBuffer()
+		w.bufMut.Unlock()
+	}
+}
+
+// close sends any buffered metrics and closes the connection.
+func (w *statsdWriter) close() {
+	w.closeOnce.Do(func() {
+		close(w.closedChan)
+		<-w.doneChan
+		w.conn.Close()
+	})
+}
+
+//------------------------------------------------------------------------------
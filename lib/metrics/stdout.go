@@ -23,13 +23,20 @@ package metrics
 import (
 	"encoding/json"
 	"fmt"
-	"runtime"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/Jeffail/gabs/v2"
 )
 
@@ -53,11 +60,96 @@ input/processor/output instance.  Separation into individual JSON objects instea
 of a single monolithic object allows for easy ingestion into document stores such
 as Elasticsearch.
 
+Metrics registered with labels (such as those of the
+` + "[`metric` processor](../processors/README.md#metric)" + `) are emitted
+with their total value as normal, and the value of each combination of labels is
+also emitted within an array under the field ` + "`labelled`" + `:
+
+` + "``` json" + `
+{"labelled":{"foo":[{"labels":{"bar":"baz"},"value":5}]},"foo":5}
+` + "```" + `
+
+Timers are emitted with their most recent value, along with the estimated 50th,
+90th and 99th percentiles of their most recent 512 timings under the suffixes
+` + "`_p50`, `_p90` and `_p99`" + `, e.g. ` + "`latency_p99`" + `. When
+flush_metrics is true the percentiles only cover the timings since the previous
+push.
+
 If defined, metrics are pushed at the configured push_interval, otherwise they
-are emitted when Benthos closes.
+are emitted when Benthos closes. A final push is always made when Benthos closes,
+regardless of whether a push_interval is set.
+
+It is also possible to push metrics each time a number of messages have been
+sent by the output layer (measured by the ` + "`output.sent`" + ` metric, even
+when it is renamed or labelled by ` + "`mapping`" + ` rules) by setting
+push_every_n_messages to a value greater than zero. This is useful for
+serverless invocations that process many messages within a single call. This can
+be combined with push_interval, in which case a push is made whenever either
+condition is met.
 
 flush_metrics dictates whether counter and timing metrics are reset to 0 after
 they are pushed out.
+
+When report_deltas is true each counter is written as the change in its value
+since the previous push rather than its total, which is useful when objects are
+aggregated downstream (e.g. summed within Kibana visualisations). Unlike
+flush_metrics this does not reset the counters themselves, and gauges and
+timings are written with their current values regardless.
+
+The string values of static_fields support
+[interpolation functions](../config_interpolation.md#functions), which are
+resolved each time a metric object is written. For example, the field
+` + "`host: ${!hostname}`" + ` would add the hostname of the machine to each
+object.
+
+The [` + "`static_labels`" + `](#static-labels) of a metrics config are also
+added as fields to each object, and should be preferred over static_fields for
+identifying the origin of metrics consistently across metrics types.
+
+The current state of all metrics is also served as a JSON array of these
+objects at the endpoint ` + "`/metrics/json`" + ` of the Benthos HTTP server,
+which allows dashboards to poll structured metrics. Metrics served this way are
+never flushed or written as deltas, and are always in the JSON format.
+
+### Filtering
+
+The fields include_patterns and exclude_patterns are lists of RE2 regular
+expressions tested against the dot separated path of each metric as it is
+written. When include_patterns is non-empty only metrics matching at least one
+pattern are written, and metrics matching any of the exclude_patterns are never
+written. For example, the pattern ` + "`^pipeline\\.processor\\.`" + ` could be
+excluded in order to suppress per-processor metrics. System metrics such as
+` + "`system.uptime`" + ` are also subject to these filters.
+
+In order to filter metrics as they are registered, regardless of the metrics
+target, use the ` + "[`whitelist`](#whitelist) or [`blacklist`](#blacklist)" + `
+types instead.
+
+### Single Object
+
+Setting the field format to ` + "`single_object`" + ` writes a single JSON
+object each push containing all metrics, with the object of each component
+instance under the field ` + "`metrics`" + ` keyed by its path, for consumers
+that prefer to ingest snapshots:
+
+` + "``` json" + `
+{"@timestamp":"2020-01-01T00:00:00Z","metrics":{"input":{"component":"input","count":5,"metric":"input"}}}
+` + "```" + `
+
+### Embedded Metric Format
+
+Setting the field format to ` + "`emf`" + ` writes metrics in the
+[CloudWatch Embedded Metric Format](https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html),
+which allows CloudWatch to extract metrics from the logs of Lambda functions
+(and other log sources) automatically. Each object is written with an
+` + "`_aws`" + ` metadata field declaring its metrics under the namespace
+emf_namespace, with the fields ` + "`component`" + ` and ` + "`metric`" + ` as
+dimensions. Since the metric values of an object must be top level fields they
+are written with dot separated keys (e.g. ` + "`batch.sent`" + `) rather than
+as nested objects, and timings are written in microseconds.
+
+Metrics registered with labels are written as a separate object for each
+combination of labels, with the labels added as fields and dimensions.
 `,
 	}
 }
@@ -67,19 +159,31 @@ they are pushed out.
 // StdoutConfig contains configuration parameters for the Stdout metrics
 // aggregator.
 type StdoutConfig struct {
-	PushInterval string                 `json:"push_interval" yaml:"push_interval"`
-	StaticFields map[string]interface{} `json:"static_fields" yaml:"static_fields"`
-	FlushMetrics bool                   `json:"flush_metrics" yaml:"flush_metrics"`
+	PushInterval       string                 `json:"push_interval" yaml:"push_interval"`
+	PushEveryNMessages int64                  `json:"push_every_n_messages" yaml:"push_every_n_messages"`
+	StaticFields       map[string]interface{} `json:"static_fields" yaml:"static_fields"`
+	FlushMetrics       bool                   `json:"flush_metrics" yaml:"flush_metrics"`
+	ReportDeltas       bool                   `json:"report_deltas" yaml:"report_deltas"`
+	IncludePatterns    []string               `json:"include_patterns" yaml:"include_patterns"`
+	ExcludePatterns    []string               `json:"exclude_patterns" yaml:"exclude_patterns"`
+	Format             string                 `json:"format" yaml:"format"`
+	EMFNamespace       string                 `json:"emf_namespace" yaml:"emf_namespace"`
 }
 
 // NewStdoutConfig returns a new StdoutConfig with default values.
 func NewStdoutConfig() StdoutConfig {
 	return StdoutConfig{
-		PushInterval: "",
+		PushInterval:       "",
+		PushEveryNMessages: 0,
 		StaticFields: map[string]interface{}{
 			"@service": "benthos",
 		},
-		FlushMetrics: false,
+		FlushMetrics:    false,
+		ReportDeltas:    false,
+		IncludePatterns: []string{},
+		ExcludePatterns: []string{},
+		Format:          "json",
+		EMFNamespace:    "Benthos",
 	}
 }
 
@@ -94,91 +198,484 @@ type Stdout struct {
 
 	config     StdoutConfig
 	closedChan chan struct{}
+	doneChan   chan struct{}
+	pushChan   chan struct{}
 	running    int32
 
-	staticFields []byte
+	pendingMessages int64
+
+	staticFields            []byte
+	interpolateStaticFields bool
+	staticLabels            map[string]string
+
+	includePatterns []*regexp.Regexp
+	excludePatterns []*regexp.Regexp
+
+	gaugePaths   map[string]struct{}
+	gaugesMut    sync.Mutex
+	lastReported map[string]int64
+
+	writer     io.Writer
+	publishMut sync.Mutex
 }
 
 // NewStdout creates and returns a new Stdout metric object.
 func NewStdout(config Config, opts ...func(Type)) (Type, error) {
+	s, err := newStdout(config, os.Stdout, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newStdout(config Config, w io.Writer, opts ...func(Type)) (*Stdout, error) {
 	t := &Stdout{
 		local:      NewLocal(),
 		timestamp:  time.Now(),
+		log:        log.Noop(),
 		config:     config.Stdout,
 		closedChan: make(chan struct{}),
+		doneChan:   make(chan struct{}),
+		pushChan:   make(chan struct{}, 1),
 		running:    1,
+		writer:     w,
+
+		staticLabels: config.StaticLabels,
+		gaugePaths:   map[string]struct{}{},
+		lastReported: map[string]int64{},
 	}
 
-	//TODO: add field interpolation here
 	sf, err := json.Marshal(config.Stdout.StaticFields)
 	if err != nil {
-		return t, fmt.Errorf("failed to parse static fields: %v", err)
+		return nil, fmt.Errorf("failed to parse static fields: %v", err)
 	}
 	t.staticFields = sf
+	t.interpolateStaticFields = text.ContainsFunctionVariables(sf)
+
+	switch config.Stdout.Format {
+	case "", "json", "single_object", "emf":
+	default:
+		return nil, fmt.Errorf("unrecognised format: %v", config.Stdout.Format)
+	}
+
+	if t.includePatterns, err = compilePatterns(config.Stdout.IncludePatterns); err != nil {
+		return nil, err
+	}
+	if t.excludePatterns, err = compilePatterns(config.Stdout.ExcludePatterns); err != nil {
+		return nil, err
+	}
 
 	for _, opt := range opts {
 		opt(t)
 	}
 
+	var interval time.Duration
 	if len(t.config.PushInterval) > 0 {
-		interval, err := time.ParseDuration(t.config.PushInterval)
-		if err != nil {
+		if interval, err = time.ParseDuration(t.config.PushInterval); err != nil {
 			return nil, fmt.Errorf("failed to parse push interval: %v", err)
 		}
-		go func() {
-			for {
-				select {
-				case <-t.closedChan:
-					return
-				case <-time.After(interval):
-					t.publishMetrics()
-				}
-			}
-		}()
+	}
+	if t.isPushing() {
+		go t.loop(interval)
+	} else {
+		close(t.doneChan)
 	}
 
 	return t, nil
 }
 
+// compilePatterns parses a list of RE2 regular expressions.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: '%s': %v", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// allowPath returns true if a metric path should be written according to the
+// include and exclude patterns.
+func (s *Stdout) allowPath(path string) bool {
+	if len(s.includePatterns) > 0 {
+		included := false
+		for _, re := range s.includePatterns {
+			if re.MatchString(path) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	for _, re := range s.excludePatterns {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPushing returns true if metrics are published during the lifetime of the
+// Stdout object rather than only when it is closed.
+func (s *Stdout) isPushing() bool {
+	return len(s.config.PushInterval) > 0 || s.config.PushEveryNMessages > 0
+}
+
+// loop publishes metrics at a regular interval and/or whenever a push is
+// triggered by the message count until the Stdout object is closed, at which
+// point a final publish is performed.
+func (s *Stdout) loop(interval time.Duration) {
+	defer close(s.doneChan)
+
+	var tickChan <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tickChan = ticker.C
+	}
+	for {
+		select {
+		case <-s.closedChan:
+			s.publishMetrics()
+			return
+		case <-tickChan:
+			s.publishMetrics()
+		case <-s.pushChan:
+			s.publishMetrics()
+		}
+	}
+}
+
+// addMessages records a count of sent messages and triggers a push once the
+// configured threshold has been reached.
+func (s *Stdout) addMessages(count int64) {
+	if n := atomic.AddInt64(&s.pendingMessages, count); n >= s.config.PushEveryNMessages {
+		atomic.AddInt64(&s.pendingMessages, -n)
+		select {
+		case s.pushChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
 //------------------------------------------------------------------------------
 
-// writeMetric prints a metric object with any configured extras merged in to
-// t.
-func (s *Stdout) writeMetric(metricSet *gabs.Container) {
+// stdoutSentPath is the metric path of the count of messages sent by the output
+// layer.
+const stdoutSentPath = "output.sent"
+
+// stdoutSentCounter wraps the counter of messages sent by the output layer in
+// order to trigger pushes based on message count.
+type stdoutSentCounter struct {
+	StatCounter
+	s *Stdout
+}
+
+// Incr increments the counter by an amount.
+func (c *stdoutSentCounter) Incr(count int64) error {
+	err := c.StatCounter.Incr(count)
+	c.s.addMessages(count)
+	return err
+}
+
+// triggerCounter wraps the counter of messages sent by the output layer, where
+// the path is that of the counter before any mapping rules were applied. Any
+// wrapping from the mapped path is removed so that messages are counted once.
+func (s *Stdout) triggerCounter(path string, c StatCounter) StatCounter {
+	if sc, ok := c.(*stdoutSentCounter); ok {
+		c = sc.StatCounter
+	}
+	if s.config.PushEveryNMessages > 0 && path == stdoutSentPath {
+		return &stdoutSentCounter{StatCounter: c, s: s}
+	}
+	return c
+}
+
+//------------------------------------------------------------------------------
+
+// baseObject returns a new container of the static fields.
+func (s *Stdout) baseObject() *gabs.Container {
+	if s.interpolateStaticFields {
+		return gabs.Wrap(interpolateFields(message.New(nil), s.config.StaticFields))
+	}
 	base, _ := gabs.ParseJSON(s.staticFields)
+	return base
+}
+
+// document returns a metric object with any configured extras merged in to
+// it.
+func (s *Stdout) document(metricSet *gabs.Container) *gabs.Container {
+	base := s.baseObject()
+	for k, v := range s.staticLabels {
+		base.Set(v, k)
+	}
 	base.SetP(time.Now().Format(time.RFC3339), "@timestamp")
 	base.Merge(metricSet)
+	return base
+}
+
+// writeMetric prints a metric object with any configured extras merged in to
+// it.
+func (s *Stdout) writeMetric(metricSet *gabs.Container) {
+	fmt.Fprintf(s.writer, "%s\n", s.document(metricSet).String())
+}
 
-	fmt.Printf("%s\n", base.String())
+// interpolateFields returns a copy of a structure of static fields where all
+// string values have had their interpolation functions resolved.
+func interpolateFields(msg text.Message, v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		newMap := make(map[string]interface{}, len(t))
+		for k, ele := range t {
+			newMap[k] = interpolateFields(msg, ele)
+		}
+		return newMap
+	case []interface{}:
+		newSlice := make([]interface{}, len(t))
+		for i, ele := range t {
+			newSlice[i] = interpolateFields(msg, ele)
+		}
+		return newSlice
+	case string:
+		return string(text.ReplaceFunctionVariables(msg, []byte(t)))
+	}
+	return v
 }
 
-// publishMetrics
+// publishMetrics writes the current state of all metrics to the writer, grouped
+// by component instance.
 func (s *Stdout) publishMetrics() {
-	counterObjs := make(map[string]*gabs.Container)
+	s.publishMut.Lock()
+	defer s.publishMut.Unlock()
+	defer s.flushWriter()
 
 	var counters map[string]int64
 	var timings map[string]int64
+	var counterVecs map[string][]LocalStat
+	var timingVecs map[string][]LocalStat
+	percentiles := s.local.GetTimingPercentiles()
 	if s.config.FlushMetrics {
 		counters = s.local.FlushCounters()
 		timings = s.local.FlushTimings()
+		counterVecs = s.local.FlushCounterVecs()
+		timingVecs = s.local.FlushTimingVecs()
 	} else {
 		counters = s.local.GetCounters()
 		timings = s.local.GetTimings()
+		counterVecs = s.local.GetCounterVecs()
+		timingVecs = s.local.GetTimingVecs()
+	}
+
+	timings = withTimingPercentiles(timings, percentiles)
+
+	if s.config.ReportDeltas {
+		counters = s.counterDeltas(counters)
+		counterVecs = s.counterVecDeltas(counterVecs)
+	}
+
+	system := systemMetrics(s.timestamp)
+
+	if s.config.Format == "emf" {
+		s.publishEMF(counters, timings, counterVecs, timingVecs, system)
+		return
 	}
 
+	objs := s.groupMetrics(counters, timings, counterVecs, timingVecs, system)
+	if s.config.Format == "single_object" {
+		s.writeMetric(singleObject(objs))
+		return
+	}
+	for _, o := range objs {
+		s.writeMetric(o)
+	}
+}
+
+// singleObject returns a container with the object of each component instance
+// under the field metrics, keyed by its path.
+func singleObject(objs map[string]*gabs.Container) *gabs.Container {
+	metricsObj := map[string]interface{}{}
+	for k, o := range objs {
+		metricsObj[k] = o.Data()
+	}
+	return gabs.Wrap(map[string]interface{}{
+		"metrics": metricsObj,
+	})
+}
+
+// groupMetrics returns a container for each component instance with all of
+// the metrics belonging to it.
+func (s *Stdout) groupMetrics(
+	counters, timings map[string]int64,
+	counterVecs, timingVecs map[string][]LocalStat,
+	system map[string]int64,
+) map[string]*gabs.Container {
+	counterObjs := make(map[string]*gabs.Container)
+
 	s.constructMetrics(counterObjs, counters)
 	s.constructMetrics(counterObjs, timings)
-
-	system := make(map[string]int64)
-	uptime := time.Since(s.timestamp).Milliseconds()
-	goroutines := runtime.NumGoroutine()
-	system["system.uptime"] = uptime
-	system["system.goroutines"] = int64(goroutines)
+	s.constructLabelledMetrics(counterObjs, counterVecs)
+	s.constructLabelledMetrics(counterObjs, timingVecs)
 	s.constructMetrics(counterObjs, system)
+	s.constructLabelledMetrics(counterObjs, map[string][]LocalStat{
+		buildInfoPath: {buildInfoStat()},
+	})
 
-	for _, o := range counterObjs {
-		s.writeMetric(o)
+	return counterObjs
+}
+
+// jsonHandlerFunc returns an http.HandlerFunc that serves the current state of
+// all metrics as an array of the documents that would be written for each
+// component instance. Metrics are never flushed or reported as deltas by the
+// handler.
+func (s *Stdout) jsonHandlerFunc() (http.HandlerFunc, bool) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		objs := s.groupMetrics(
+			s.local.GetCounters(),
+			withTimingPercentiles(s.local.GetTimings(), s.local.GetTimingPercentiles()),
+			s.local.GetCounterVecs(),
+			s.local.GetTimingVecs(),
+			systemMetrics(s.timestamp),
+		)
+		docs := make([]*gabs.Container, 0, len(objs))
+		for _, k := range sortedContainerKeys(objs) {
+			docs = append(docs, s.document(objs[k]))
+		}
+		writeJSONDocuments(w, docs)
+	}, true
+}
+
+// isGauge returns true if a path was registered as a gauge.
+func (s *Stdout) isGauge(path string) bool {
+	s.gaugesMut.Lock()
+	_, exists := s.gaugePaths[path]
+	s.gaugesMut.Unlock()
+	return exists
+}
+
+// delta returns the change in value of a counter since the previous push and
+// records the new value. The publish mutex must be held by the caller.
+func (s *Stdout) delta(key string, value int64) int64 {
+	d := value - s.lastReported[key]
+	s.lastReported[key] = value
+	return d
+}
+
+// counterDeltas returns a map of counter paths to the change in their value
+// since the previous push, where the values of gauges are left unchanged.
+func (s *Stdout) counterDeltas(counters map[string]int64) map[string]int64 {
+	deltas := make(map[string]int64, len(counters))
+	for k, v := range counters {
+		if s.isGauge(k) {
+			deltas[k] = v
+			continue
+		}
+		deltas[k] = s.delta(k, v)
+	}
+	return deltas
+}
+
+// counterVecDeltas returns the labelled equivalent of counterDeltas.
+func (s *Stdout) counterVecDeltas(vecs map[string][]LocalStat) map[string][]LocalStat {
+	deltas := make(map[string][]LocalStat, len(vecs))
+	for k, stats := range vecs {
+		if s.isGauge(k) {
+			deltas[k] = stats
+			continue
+		}
+		newStats := make([]LocalStat, len(stats))
+		for i, st := range stats {
+			names := make([]string, 0, len(st.labelsAndValues))
+			values := make([]string, 0, len(st.labelsAndValues))
+			for lk, lv := range st.labelsAndValues {
+				names = append(names, lk)
+				values = append(values, lv)
+			}
+			d := s.delta(k+"\x00"+labelKey(names, values), *st.Value)
+			newStats[i] = LocalStat{
+				Value:           &d,
+				labelsAndValues: st.labelsAndValues,
+			}
+		}
+		deltas[k] = newStats
 	}
+	return deltas
+}
+
+// flushWriter flushes the writer after a publish if it buffers writes.
+func (s *Stdout) flushWriter() {
+	if f, ok := s.writer.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			s.log.Errorf("Failed to write metrics: %v\n", err)
+		}
+	}
+}
+
+// splitMetricPath splits a metric path into the path of the component instance
+// it belongs to, the name of the component and the key of the metric value.
+func splitMetricPath(k string) (objKey, component, valKey string) {
+	kParts := strings.Split(k, ".")
+	// walk key parts backwards building up objects of instances of processor/broker/etc
+	for i := len(kParts) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(kParts[i]); err == nil {
+			// part is a reference to an index of a processor/broker/etc
+			objKey = strings.Join(kParts[:i+1], ".")
+			valKey = strings.Join(kParts[i+1:], ".")
+			break
+		}
+	}
+
+	if objKey == "" {
+		// key is not referencing an 'instance' of a processor/broker/etc
+		objKey = kParts[0]
+		valKey = strings.Join(kParts[0:], ".")
+	}
+	return objKey, kParts[0], valKey
+}
+
+// metricObject returns the container of the component instance that a metric
+// path belongs to, creating it if it does not yet exist, along with the key of
+// the metric value within the container.
+func metricObject(co map[string]*gabs.Container, k string) (*gabs.Container, string) {
+	objKey, component, valKey := splitMetricPath(k)
+
+	obj, exists := co[objKey]
+	if !exists {
+		obj = gabs.New()
+		obj.SetP(objKey, "metric")
+		obj.SetP(component, "component")
+		co[objKey] = obj
+	}
+	return obj, valKey
+}
+
+// sortedContainerKeys returns the keys of a map of containers in sorted order.
+func sortedContainerKeys(co map[string]*gabs.Container) []string {
+	keys := make([]string, 0, len(co))
+	for k := range co {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeJSONDocuments writes a slice of metric documents as a JSON array.
+func writeJSONDocuments(w http.ResponseWriter, docs []*gabs.Container) {
+	arr := make([]interface{}, len(docs))
+	for i, d := range docs {
+		arr[i] = d.Data()
+	}
+	resBytes, err := json.Marshal(arr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resBytes)
 }
 
 // constructMetrics groups individual Benthos metrics contained in a map into
@@ -187,47 +684,180 @@ func (s *Stdout) publishMetrics() {
 // into a single pipeline.processor.1 object.
 func (s *Stdout) constructMetrics(co map[string]*gabs.Container, metrics map[string]int64) {
 	for k, v := range metrics {
-		kParts := strings.Split(k, ".")
-		var objKey string
-		var valKey string
-		// walk key parts backwards building up objects of instances of processor/broker/etc
-		for i := len(kParts) - 1; i >= 0; i-- {
-			if _, err := strconv.Atoi(kParts[i]); err == nil {
-				// part is a reference to an index of a processor/broker/etc
-				objKey = strings.Join(kParts[:i+1], ".")
-				valKey = strings.Join(kParts[i+1:], ".")
-				break
+		if !s.allowPath(k) {
+			continue
+		}
+		obj, valKey := metricObject(co, k)
+		obj.SetP(v, valKey)
+	}
+}
+
+// constructLabelledMetrics adds metrics registered with labels to the
+// container of each component instance. The value of each combination of
+// labels is added as an object within an array under the field labelled, for
+// example:
+//
+// {"labelled":{"count":[{"labels":{"foo":"bar"},"value":5}]}}
+func (s *Stdout) constructLabelledMetrics(co map[string]*gabs.Container, metrics map[string][]LocalStat) {
+	for k, stats := range metrics {
+		if !s.allowPath(k) {
+			continue
+		}
+		obj, valKey := metricObject(co, k)
+		values := make([]interface{}, 0, len(stats))
+		for _, st := range stats {
+			labels := map[string]interface{}{}
+			for lk, lv := range st.LabelsAndValues() {
+				labels[lk] = lv
 			}
+			values = append(values, map[string]interface{}{
+				"labels": labels,
+				"value":  *st.Value,
+			})
+		}
+		obj.SetP(values, "labelled."+valKey)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// emfObject is a single object written in the CloudWatch Embedded Metric
+// Format.
+type emfObject struct {
+	fields     map[string]interface{}
+	dimensions []string
+	metrics    []interface{}
+}
+
+func (o *emfObject) addMetric(name string, value interface{}, unit string) {
+	o.fields[name] = value
+	def := map[string]interface{}{"Name": name}
+	if len(unit) > 0 {
+		def["Unit"] = unit
+	}
+	o.metrics = append(o.metrics, def)
+}
+
+// publishEMF writes metrics in the CloudWatch Embedded Metric Format, grouped
+// by component instance and by combination of labels.
+func (s *Stdout) publishEMF(
+	counters, timings map[string]int64,
+	counterVecs, timingVecs map[string][]LocalStat,
+	system map[string]int64,
+) {
+	objs := map[string]*emfObject{}
+	getObj := func(objKey, component string, metricLabels map[string]string) *emfObject {
+		labels := make(map[string]string, len(s.staticLabels)+len(metricLabels))
+		for k, v := range s.staticLabels {
+			labels[k] = v
+		}
+		for k, v := range metricLabels {
+			labels[k] = v
 		}
 
-		if objKey == "" {
-			// key is not referencing an 'instance' of a processor/broker/etc
-			objKey = kParts[0]
-			valKey = strings.Join(kParts[0:], ".")
+		key := objKey
+		labelNames := make([]string, 0, len(labels))
+		for k := range labels {
+			labelNames = append(labelNames, k)
+		}
+		sort.Strings(labelNames)
+		for _, k := range labelNames {
+			key += "\x00" + k + "=" + labels[k]
 		}
 
-		_, exists := co[objKey]
+		obj, exists := objs[key]
 		if !exists {
-			co[objKey] = gabs.New()
-			co[objKey].SetP(objKey, "metric")
-			co[objKey].SetP(kParts[0], "component")
+			obj = &emfObject{
+				fields: map[string]interface{}{
+					"metric":    objKey,
+					"component": component,
+				},
+				dimensions: append([]string{"component", "metric"}, labelNames...),
+			}
+			for k, v := range labels {
+				obj.fields[k] = v
+			}
+			objs[key] = obj
+		}
+		return obj
+	}
+
+	addMetrics := func(metrics map[string]int64, unit string, divisor int64) {
+		for k, v := range metrics {
+			if !s.allowPath(k) {
+				continue
+			}
+			objKey, component, valKey := splitMetricPath(k)
+			getObj(objKey, component, nil).addMetric(valKey, v/divisor, unit)
+		}
+	}
+	addLabelledMetrics := func(metrics map[string][]LocalStat, unit string, divisor int64) {
+		for k, stats := range metrics {
+			if !s.allowPath(k) {
+				continue
+			}
+			objKey, component, valKey := splitMetricPath(k)
+			for _, st := range stats {
+				getObj(objKey, component, st.LabelsAndValues()).addMetric(valKey, *st.Value/divisor, unit)
+			}
+		}
+	}
+
+	addMetrics(counters, "", 1)
+	addMetrics(timings, "Microseconds", 1000)
+	addLabelledMetrics(counterVecs, "", 1)
+	addLabelledMetrics(timingVecs, "Microseconds", 1000)
+	addMetrics(system, "", 1)
+	addLabelledMetrics(map[string][]LocalStat{
+		buildInfoPath: {buildInfoStat()},
+	}, "", 1)
+
+	keys := make([]string, 0, len(objs))
+	for k := range objs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, k := range keys {
+		obj := objs[k]
+
+		base := s.baseObject()
+		for fk, fv := range obj.fields {
+			base.Set(fv, fk)
 		}
-		co[objKey].SetP(v, valKey)
+		base.Set(map[string]interface{}{
+			"Timestamp": timestamp,
+			"CloudWatchMetrics": []interface{}{
+				map[string]interface{}{
+					"Namespace":  s.config.EMFNamespace,
+					"Dimensions": []interface{}{obj.dimensions},
+					"Metrics":    obj.metrics,
+				},
+			},
+		}, "_aws")
+
+		fmt.Fprintf(s.writer, "%s\n", base.String())
 	}
 }
 
+//------------------------------------------------------------------------------
+
 // GetCounter returns a stat counter object for a path.
 func (s *Stdout) GetCounter(path string) StatCounter {
-	return s.local.GetCounter(path)
+	return s.triggerCounter(path, s.local.GetCounter(path))
 }
 
 // GetCounterVec returns a stat counter object for a path with the labels
-// discarded.
+// and values.
 func (s *Stdout) GetCounterVec(path string, n []string) StatCounterVec {
-	return fakeCounterVec(func([]string) StatCounter {
-		return s.local.GetCounter(path)
-	})
+	vec := s.local.GetCounterVec(path, n)
+	if s.config.PushEveryNMessages > 0 && path == stdoutSentPath {
+		return fakeCounterVec(func(v []string) StatCounter {
+			return s.triggerCounter(path, vec.With(v...))
+		})
+	}
+	return vec
 }
 
 // GetTimer returns a stat timer object for a path.
@@ -235,28 +865,63 @@ func (s *Stdout) GetTimer(path string) StatTimer {
 	return s.local.GetTimer(path)
 }
 
-// GetTimerVec returns a stat timer object for a path with the labels
-// discarded.
+// GetTimerVec returns a stat timer object for a path with the labels and
+// values.
 func (s *Stdout) GetTimerVec(path string, n []string) StatTimerVec {
-	return fakeTimerVec(func([]string) StatTimer {
-		return s.local.GetTimer(path)
-	})
+	return s.local.GetTimerVec(path, n)
 }
 
 // GetGauge returns a stat gauge object for a path.
 func (s *Stdout) GetGauge(path string) StatGauge {
+	s.addGaugePath(path)
 	return s.local.GetGauge(path)
 }
 
-// GetGaugeVec returns a stat timer object for a path with the labels
-// discarded.
+// GetGaugeVec returns a stat gauge object for a path with the labels and
+// values.
 func (s *Stdout) GetGaugeVec(path string, n []string) StatGaugeVec {
-	return fakeGaugeVec(func([]string) StatGauge {
-		return s.local.GetGauge(path)
-	})
+	s.addGaugePath(path)
+	return s.local.GetGaugeVec(path, n)
+}
+
+func (s *Stdout) addGaugePath(path string) {
+	s.gaugesMut.Lock()
+	s.gaugePaths[path] = struct{}{}
+	s.gaugesMut.Unlock()
 }
 
-// SetLogger does nothing.
+// appliesStaticLabels indicates that static labels are added as fields to each
+// object written.
+func (s *Stdout) appliesStaticLabels() bool {
+	return true
+}
+
+// reportsSystemMetrics indicates that system metrics are collected each time
+// metrics are published.
+func (s *Stdout) reportsSystemMetrics() bool {
+	return true
+}
+
+// flushNow publishes metrics immediately, unless the Stdout object has been
+// closed.
+func (s *Stdout) flushNow() bool {
+	if atomic.LoadInt32(&s.running) == 1 {
+		s.publishMetrics()
+	}
+	return true
+}
+
+// resetNow zeroes all counters and timers, and forgets the values last
+// reported for calculating deltas.
+func (s *Stdout) resetNow() bool {
+	s.publishMut.Lock()
+	s.local.Reset()
+	s.lastReported = map[string]int64{}
+	s.publishMut.Unlock()
+	return true
+}
+
+// SetLogger sets the logger used to print errors.
 func (s *Stdout) SetLogger(log log.Modular) {
 	s.log = log
 }
@@ -264,11 +929,16 @@ func (s *Stdout) SetLogger(log log.Modular) {
 // Close stops the Stdout object from aggregating metrics and does a publish
 // (write to stdout) of metrics.
 func (s *Stdout) Close() error {
-	if atomic.CompareAndSwapInt32(&s.running, 1, 0) {
-		close(s.closedChan)
+	if !atomic.CompareAndSwapInt32(&s.running, 1, 0) {
+		return nil
+	}
+	close(s.closedChan)
+	if s.isPushing() {
+		// The push loop performs the final publish before exiting.
+		<-s.doneChan
+		return nil
 	}
 	s.publishMetrics()
-
 	return nil
 }
 
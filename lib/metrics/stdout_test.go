@@ -0,0 +1,772 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+type syncBuffer struct {
+	buf bytes.Buffer
+	sync.Mutex
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) Lines() []string {
+	s.Lock()
+	defer s.Unlock()
+	var lines []string
+	for _, l := range strings.Split(s.buf.String(), "\n") {
+		if len(l) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+func (s *syncBuffer) Reset() {
+	s.Lock()
+	s.buf.Reset()
+	s.Unlock()
+}
+
+func parseStdoutLines(t *testing.T, lines []string) map[string]map[string]interface{} {
+	t.Helper()
+	objs := map[string]map[string]interface{}{}
+	for _, l := range lines {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &obj); err != nil {
+			t.Fatalf("Failed to parse line '%v': %v", l, err)
+		}
+		metric, _ := obj["metric"].(string)
+		objs[metric] = obj
+	}
+	return objs
+}
+
+//------------------------------------------------------------------------------
+
+func TestStdoutInterface(t *testing.T) {
+	o := &Stdout{}
+	if Type(o) == nil {
+		t.Errorf("Type does not satisfy Type interface.")
+	}
+}
+
+func TestStdoutBadPushInterval(t *testing.T) {
+	conf := NewConfig()
+	conf.Stdout.PushInterval = "not a duration"
+	if _, err := NewStdout(conf); err == nil {
+		t.Error("Expected error from bad push interval")
+	}
+}
+
+func TestStdoutPublishOnClose(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.GetCounter("input.count").Incr(3)
+	s.GetCounter("pipeline.processor.0.count").Incr(2)
+
+	if exp, act := 0, len(buf.Lines()); exp != act {
+		t.Errorf("Wrong count of lines before close: %v != %v", act, exp)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	objs := parseStdoutLines(t, buf.Lines())
+	if exp, act := float64(3), objs["input"]["input"].(map[string]interface{})["count"]; exp != act {
+		t.Errorf("Wrong input count: %v != %v", act, exp)
+	}
+	if exp, act := float64(2), objs["pipeline.processor.0"]["count"]; exp != act {
+		t.Errorf("Wrong processor count: %v != %v", act, exp)
+	}
+	if exp, act := "benthos", objs["input"]["@service"]; exp != act {
+		t.Errorf("Wrong static field: %v != %v", act, exp)
+	}
+	if _, exists := objs["system"]; !exists {
+		t.Error("Expected system metrics object")
+	}
+
+	// Should be idempotent
+	buf.Reset()
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 0, len(buf.Lines()); exp != act {
+		t.Errorf("Wrong count of lines after second close: %v != %v", act, exp)
+	}
+}
+
+func TestStdoutPushInterval(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.PushInterval = "10ms"
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.GetCounter("input.count").Incr(1)
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(time.Second * 5)
+
+countLoop:
+	for {
+		select {
+		case <-ticker.C:
+			if len(buf.Lines()) > 0 {
+				break countLoop
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for periodic push")
+		}
+	}
+
+	buf.Reset()
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	objs := parseStdoutLines(t, buf.Lines())
+	if _, exists := objs["input"]; !exists {
+		t.Errorf("Expected final publish of input metrics on close: %v", buf.Lines())
+	}
+}
+
+func TestStdoutStaticFieldsInterpolation(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.StaticFields = map[string]interface{}{
+		"@service": "benthos",
+		"static":   "${!echo:foo}",
+		"nested": map[string]interface{}{
+			"quoted": `${!echo:"bar"}`,
+		},
+	}
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.GetCounter("input.count").Incr(1)
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	obj := parseStdoutLines(t, buf.Lines())["input"]
+	if exp, act := "foo", obj["static"]; exp != act {
+		t.Errorf("Wrong interpolated field: %v != %v", act, exp)
+	}
+	if exp, act := `"bar"`, obj["nested"].(map[string]interface{})["quoted"]; exp != act {
+		t.Errorf("Wrong interpolated nested field: %v != %v", act, exp)
+	}
+	if exp, act := "benthos", obj["@service"]; exp != act {
+		t.Errorf("Wrong static field: %v != %v", act, exp)
+	}
+}
+
+func TestStdoutPushEveryNMessages(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.PushEveryNMessages = 5
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := s.GetCounter("output.sent")
+	sent.Incr(4)
+
+	<-time.After(time.Millisecond * 50)
+	if exp, act := 0, len(buf.Lines()); exp != act {
+		t.Errorf("Wrong count of lines before threshold: %v != %v", act, exp)
+	}
+
+	sent.Incr(1)
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(time.Second * 5)
+
+countLoop:
+	for {
+		select {
+		case <-ticker.C:
+			if len(buf.Lines()) > 0 {
+				break countLoop
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for message count push")
+		}
+	}
+
+	objs := parseStdoutLines(t, buf.Lines())
+	if exp, act := float64(5), objs["output"]["output"].(map[string]interface{})["sent"]; exp != act {
+		t.Errorf("Wrong output sent count: %v != %v", act, exp)
+	}
+
+	buf.Reset()
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := parseStdoutLines(t, buf.Lines())["output"]; !exists {
+		t.Errorf("Expected final publish of output metrics on close: %v", buf.Lines())
+	}
+}
+
+func TestStdoutPushEveryNMessagesMapped(t *testing.T) {
+	tests := map[string]MappingRuleConfig{
+		"renamed": {
+			Pattern: `^output\.sent$`,
+			Value:   "output.messages_sent",
+		},
+		"labelled": {
+			Pattern: `^output\.sent$`,
+			Labels:  map[string]string{"stream": "foo"},
+		},
+		"renamed to": {
+			Pattern: `^output\.batch\.sent$`,
+			Value:   "output.sent",
+		},
+	}
+
+	for name, rule := range tests {
+		conf := NewConfig()
+		conf.Type = TypeStdout
+		conf.Stdout.PushEveryNMessages = 5
+		conf.Mapping = []MappingRuleConfig{rule}
+
+		s, err := New(conf)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		m, ok := s.(*mapping)
+		if !ok {
+			t.Fatalf("%v: Expected mapping type, got %T", name, s)
+		}
+		buf := &syncBuffer{}
+		m.s.(*Stdout).writer = buf
+
+		sent := s.GetCounter("output.sent")
+		batchSent := s.GetCounter("output.batch.sent")
+		sent.Incr(4)
+		batchSent.Incr(4)
+
+		<-time.After(time.Millisecond * 50)
+		if exp, act := 0, len(buf.Lines()); exp != act {
+			t.Errorf("%v: Wrong count of lines before threshold: %v != %v", name, act, exp)
+		}
+
+		sent.Incr(1)
+
+		timeout := time.After(time.Second * 5)
+		for len(buf.Lines()) == 0 {
+			select {
+			case <-time.After(time.Millisecond):
+			case <-timeout:
+				t.Fatalf("%v: Timed out waiting for message count push", name)
+			}
+		}
+		if err = s.Close(); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+	}
+}
+
+func TestStdoutLabelledMetrics(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctr := s.GetCounterVec("pipeline.processor.0.foo", []string{"bar"})
+	ctr.With("baz").Incr(2)
+	ctr.With("qux").Incr(3)
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	obj := parseStdoutLines(t, buf.Lines())["pipeline.processor.0"]
+	if exp, act := float64(5), obj["foo"]; exp != act {
+		t.Errorf("Wrong aggregate value: %v != %v", act, exp)
+	}
+
+	labelled, ok := obj["labelled"].(map[string]interface{})["foo"].([]interface{})
+	if !ok {
+		t.Fatalf("Missing labelled values: %v", obj)
+	}
+	if exp, act := 2, len(labelled); exp != act {
+		t.Fatalf("Wrong count of labelled values: %v != %v", act, exp)
+	}
+	first := labelled[0].(map[string]interface{})
+	if exp, act := "baz", first["labels"].(map[string]interface{})["bar"]; exp != act {
+		t.Errorf("Wrong label value: %v != %v", act, exp)
+	}
+	if exp, act := float64(2), first["value"]; exp != act {
+		t.Errorf("Wrong labelled value: %v != %v", act, exp)
+	}
+}
+
+func TestStdoutBuildInfo(t *testing.T) {
+	SetBuildInfo("v1.2.3", "")
+	defer SetBuildInfo("", "")
+
+	buf := &syncBuffer{}
+
+	s, err := newStdout(NewConfig(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	obj := parseStdoutLines(t, buf.Lines())["system"]
+	labelled, ok := obj["labelled"].(map[string]interface{})["system"].(map[string]interface{})["build_info"].([]interface{})
+	if !ok {
+		t.Fatalf("Missing build info: %v", obj)
+	}
+	if exp, act := 1, len(labelled); exp != act {
+		t.Fatalf("Wrong count of build info values: %v != %v", act, exp)
+	}
+	info := labelled[0].(map[string]interface{})
+	labels := info["labels"].(map[string]interface{})
+	if exp, act := "v1.2.3", labels["version"]; exp != act {
+		t.Errorf("Wrong version label: %v != %v", act, exp)
+	}
+	if exp, act := "unknown", labels["git_sha"]; exp != act {
+		t.Errorf("Wrong git_sha label: %v != %v", act, exp)
+	}
+	if exp, act := float64(1), info["value"]; exp != act {
+		t.Errorf("Wrong build info value: %v != %v", act, exp)
+	}
+}
+
+func TestStdoutBadPatterns(t *testing.T) {
+	conf := NewConfig()
+	conf.Stdout.IncludePatterns = []string{"("}
+	if _, err := NewStdout(conf); err == nil {
+		t.Error("Expected error from bad include pattern")
+	}
+
+	conf = NewConfig()
+	conf.Stdout.ExcludePatterns = []string{"("}
+	if _, err := NewStdout(conf); err == nil {
+		t.Error("Expected error from bad exclude pattern")
+	}
+}
+
+func TestStdoutPatternFiltering(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.IncludePatterns = []string{`^input\.`, `^pipeline\.`}
+	conf.Stdout.ExcludePatterns = []string{`^pipeline\.processor\.1\.`, `\.error$`}
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.GetCounter("input.count").Incr(1)
+	s.GetCounter("input.error").Incr(1)
+	s.GetCounter("pipeline.processor.0.count").Incr(1)
+	s.GetCounter("pipeline.processor.1.count").Incr(1)
+	s.GetCounter("output.count").Incr(1)
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	objs := parseStdoutLines(t, buf.Lines())
+	if exp, act := 2, len(objs); exp != act {
+		t.Errorf("Wrong count of objects: %v != %v: %v", act, exp, objs)
+	}
+	input, exists := objs["input"]
+	if !exists {
+		t.Fatal("Expected input object")
+	}
+	if _, exists = input["input"].(map[string]interface{})["error"]; exists {
+		t.Error("Expected input.error to be excluded")
+	}
+	if _, exists = objs["pipeline.processor.0"]; !exists {
+		t.Error("Expected pipeline.processor.0 object")
+	}
+}
+
+func TestStdoutBadFormat(t *testing.T) {
+	conf := NewConfig()
+	conf.Stdout.Format = "nope"
+	if _, err := NewStdout(conf); err == nil {
+		t.Error("Expected error from bad format")
+	}
+}
+
+func TestStdoutSingleObject(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.Format = "single_object"
+	conf.StaticLabels = map[string]string{"region": "foo"}
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.GetCounter("input.count").Incr(3)
+	s.GetCounter("pipeline.processor.0.count").Incr(2)
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := buf.Lines()
+	if exp, act := 1, len(lines); exp != act {
+		t.Fatalf("Wrong count of lines: %v != %v", act, exp)
+	}
+
+	var obj map[string]interface{}
+	if err = json.Unmarshal([]byte(lines[0]), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "benthos", obj["@service"]; exp != act {
+		t.Errorf("Wrong static field: %v != %v", act, exp)
+	}
+	if exp, act := "foo", obj["region"]; exp != act {
+		t.Errorf("Wrong static label: %v != %v", act, exp)
+	}
+	if _, exists := obj["@timestamp"]; !exists {
+		t.Error("Expected timestamp")
+	}
+
+	metricsObj, ok := obj["metrics"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Wrong metrics field: %v", obj["metrics"])
+	}
+	input := metricsObj["input"].(map[string]interface{})
+	if exp, act := float64(3), input["input"].(map[string]interface{})["count"]; exp != act {
+		t.Errorf("Wrong input count: %v != %v", act, exp)
+	}
+	proc := metricsObj["pipeline.processor.0"].(map[string]interface{})
+	if exp, act := float64(2), proc["count"]; exp != act {
+		t.Errorf("Wrong processor count: %v != %v", act, exp)
+	}
+	if _, exists := metricsObj["system"]; !exists {
+		t.Error("Expected system metrics object")
+	}
+}
+
+func TestStdoutEMF(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.Format = "emf"
+	conf.Stdout.EMFNamespace = "Foo"
+	conf.Stdout.IncludePatterns = []string{`^pipeline\.`}
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.GetCounter("pipeline.processor.0.batch.sent").Incr(3)
+	s.GetTimer("pipeline.processor.0.latency").Timing(5000)
+	s.GetCounterVec("pipeline.processor.0.foo", []string{"bar"}).With("baz").Incr(2)
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := buf.Lines()
+	if exp, act := 2, len(lines); exp != act {
+		t.Fatalf("Wrong count of lines: %v != %v: %v", act, exp, lines)
+	}
+
+	var obj map[string]interface{}
+	if err = json.Unmarshal([]byte(lines[0]), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := float64(3), obj["batch.sent"]; exp != act {
+		t.Errorf("Wrong counter value: %v != %v", act, exp)
+	}
+	if exp, act := float64(5), obj["latency"]; exp != act {
+		t.Errorf("Wrong timer value: %v != %v", act, exp)
+	}
+	if exp, act := float64(5), obj["latency_p99"]; exp != act {
+		t.Errorf("Wrong timer percentile: %v != %v", act, exp)
+	}
+	if exp, act := "benthos", obj["@service"]; exp != act {
+		t.Errorf("Wrong static field: %v != %v", act, exp)
+	}
+
+	directive := obj["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	if exp, act := "Foo", directive["Namespace"]; exp != act {
+		t.Errorf("Wrong namespace: %v != %v", act, exp)
+	}
+	if exp, act := `[["component","metric"]]`, jsonString(t, directive["Dimensions"]); exp != act {
+		t.Errorf("Wrong dimensions: %v != %v", act, exp)
+	}
+	if exp, act := 6, len(directive["Metrics"].([]interface{})); exp != act {
+		t.Errorf("Wrong count of metrics: %v != %v", act, exp)
+	}
+
+	obj = nil
+	if err = json.Unmarshal([]byte(lines[1]), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := float64(2), obj["foo"]; exp != act {
+		t.Errorf("Wrong labelled value: %v != %v", act, exp)
+	}
+	if exp, act := "baz", obj["bar"]; exp != act {
+		t.Errorf("Wrong label: %v != %v", act, exp)
+	}
+	directive = obj["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	if exp, act := `[["component","metric","bar"]]`, jsonString(t, directive["Dimensions"]); exp != act {
+		t.Errorf("Wrong dimensions: %v != %v", act, exp)
+	}
+}
+
+func TestStdoutReportDeltas(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.ReportDeltas = true
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctr := s.GetCounter("input.count")
+	labelled := s.GetCounterVec("input.labelled", []string{"foo"}).With("bar")
+	gauge := s.GetGauge("input.gauge")
+
+	ctr.Incr(5)
+	labelled.Incr(2)
+	gauge.Set(10)
+	s.publishMetrics()
+
+	obj := parseStdoutLines(t, buf.Lines())["input"]["input"].(map[string]interface{})
+	if exp, act := float64(5), obj["count"]; exp != act {
+		t.Errorf("Wrong counter delta: %v != %v", act, exp)
+	}
+
+	buf.Reset()
+	ctr.Incr(3)
+	s.publishMetrics()
+
+	full := parseStdoutLines(t, buf.Lines())["input"]
+	obj = full["input"].(map[string]interface{})
+	if exp, act := float64(3), obj["count"]; exp != act {
+		t.Errorf("Wrong counter delta: %v != %v", act, exp)
+	}
+	if exp, act := float64(0), obj["labelled"]; exp != act {
+		t.Errorf("Wrong labelled counter delta: %v != %v", act, exp)
+	}
+	if exp, act := float64(10), obj["gauge"]; exp != act {
+		t.Errorf("Wrong gauge value: %v != %v", act, exp)
+	}
+	values := full["labelled"].(map[string]interface{})["input"].(map[string]interface{})["labelled"].([]interface{})
+	if exp, act := float64(0), values[0].(map[string]interface{})["value"]; exp != act {
+		t.Errorf("Wrong labelled value delta: %v != %v", act, exp)
+	}
+
+	// The totals held by the aggregator are unaffected.
+	if exp, act := int64(8), s.local.GetCounters()["input.count"]; exp != act {
+		t.Errorf("Wrong counter total: %v != %v", act, exp)
+	}
+}
+
+func jsonString(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+//------------------------------------------------------------------------------
+
+func TestStdoutJSONHandler(t *testing.T) {
+	conf := NewConfig()
+	conf.Stdout.FlushMetrics = true
+	conf.Stdout.ExcludePatterns = []string{`^system\.`}
+	s, err := newStdout(conf, &syncBuffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.GetCounter("pipeline.processor.0.count").Incr(3)
+	s.GetCounterVec("input.labelled", []string{"foo"}).With("bar").Incr(2)
+	s.GetTimer("output.latency").Timing(10)
+
+	handler, ok := JSONHandlerFunc(s)
+	if !ok {
+		t.Fatal("Expected stdout to have a JSON handler")
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/metrics/json", nil))
+		if exp, act := "application/json", rec.Header().Get("Content-Type"); exp != act {
+			t.Errorf("Wrong content type: %v != %v", act, exp)
+		}
+
+		var docs []map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &docs); err != nil {
+			t.Fatal(err)
+		}
+		var metrics []string
+		for _, d := range docs {
+			metrics = append(metrics, d["metric"].(string))
+			if exp, act := "benthos", d["@service"]; exp != act {
+				t.Errorf("Wrong static field: %v != %v", act, exp)
+			}
+		}
+		if exp, act := "input,output,pipeline.processor.0", strings.Join(metrics, ","); exp != act {
+			t.Fatalf("Wrong documents: %v != %v", act, exp)
+		}
+
+		// Metrics are not flushed by the handler.
+		if exp, act := float64(3), docs[2]["count"]; exp != act {
+			t.Errorf("Wrong counter value: %v != %v", act, exp)
+		}
+		if exp, act := float64(10), docs[1]["output"].(map[string]interface{})["latency"]; exp != act {
+			t.Errorf("Wrong timing value: %v != %v", act, exp)
+		}
+		labelled := docs[0]["labelled"].(map[string]interface{})["input"].(map[string]interface{})["labelled"].([]interface{})
+		if exp, act := float64(2), labelled[0].(map[string]interface{})["value"]; exp != act {
+			t.Errorf("Wrong labelled value: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestStdoutTimingPercentiles(t *testing.T) {
+	buf := &syncBuffer{}
+
+	conf := NewConfig()
+	conf.Stdout.FlushMetrics = true
+	s, err := newStdout(conf, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	timer := s.GetTimerVec("output.latency", []string{"topic"}).With("foo")
+	for i := int64(1); i <= 100; i++ {
+		timer.Timing(i)
+	}
+	s.publishMetrics()
+
+	obj := parseStdoutLines(t, buf.Lines())["output"]["output"].(map[string]interface{})
+	for k, exp := range map[string]float64{
+		"latency":     100,
+		"latency_p50": 50,
+		"latency_p90": 90,
+		"latency_p99": 99,
+	} {
+		if act := obj[k]; act != exp {
+			t.Errorf("Wrong value of %v: %v != %v", k, act, exp)
+		}
+	}
+
+	// Flushing metrics also empties the window of timings.
+	buf.Reset()
+	timer.Timing(7)
+	s.publishMetrics()
+
+	obj = parseStdoutLines(t, buf.Lines())["output"]["output"].(map[string]interface{})
+	if exp, act := float64(7), obj["latency_p50"]; exp != act {
+		t.Errorf("Wrong percentile after flush: %v != %v", act, exp)
+	}
+}
+
+func TestStdoutStaticLabels(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeStdout
+	conf.StaticLabels = map[string]string{"host": "foo"}
+
+	s, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	std, ok := s.(*Stdout)
+	if !ok {
+		t.Fatalf("Expected stdout type to apply static labels itself, got %T", s)
+	}
+	buf := &syncBuffer{}
+	std.writer = buf
+
+	s.GetCounter("input.count").Incr(1)
+	std.publishMetrics()
+
+	obj := parseStdoutLines(t, buf.Lines())["input"]
+	if exp, act := "foo", obj["host"]; exp != act {
+		t.Errorf("Wrong static label: %v != %v", act, exp)
+	}
+	if _, exists := obj["labelled"]; exists {
+		t.Errorf("Unexpected labelled metrics: %v", obj)
+	}
+
+	buf.Reset()
+	std.config.Format = "emf"
+	std.publishMetrics()
+
+	obj = parseStdoutLines(t, buf.Lines())["input"]
+	if exp, act := "foo", obj["host"]; exp != act {
+		t.Errorf("Wrong static label: %v != %v", act, exp)
+	}
+	directive := obj["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	if exp, act := `[["component","metric","host"]]`, jsonString(t, directive["Dimensions"]); exp != act {
+		t.Errorf("Wrong dimensions: %v != %v", act, exp)
+	}
+}
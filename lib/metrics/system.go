@@ -0,0 +1,183 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// systemMetricsInterval is the period between updates of system metrics for
+// types that do not collect them on demand.
+var systemMetricsInterval = time.Second * 5
+
+// systemMetrics returns the current values of system metrics by their path,
+// where start is the time at which the process started.
+func systemMetrics(start time.Time) map[string]int64 {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	system := map[string]int64{
+		"system.uptime":            time.Since(start).Milliseconds(),
+		"system.goroutines":        int64(runtime.NumGoroutine()),
+		"system.mem.heap_bytes":    int64(mem.HeapAlloc),
+		"system.mem.sys_bytes":     int64(mem.Sys),
+		"system.mem.alloc_bytes":   int64(mem.TotalAlloc),
+		"system.gc.count":          int64(mem.NumGC),
+		"system.gc.pause_total_ns": int64(mem.PauseTotalNs),
+	}
+	addOSSystemMetrics(system)
+	return system
+}
+
+// buildInfoPath is the path of a constant gauge labelled with the version, git
+// SHA and Go runtime version of the running build.
+const buildInfoPath = "system.build_info"
+
+var buildInfo = struct {
+	sync.RWMutex
+	version string
+	gitSHA  string
+}{}
+
+// SetBuildInfo sets the version and git SHA of the running build, which are
+// reported along with the Go runtime version as the labels of the constant
+// gauge system.build_info.
+func SetBuildInfo(version, gitSHA string) {
+	buildInfo.Lock()
+	buildInfo.version = version
+	buildInfo.gitSHA = gitSHA
+	buildInfo.Unlock()
+}
+
+// buildInfoLabels returns the label names and values of the build info gauge,
+// where unset values are reported as unknown.
+func buildInfoLabels() (names, values []string) {
+	buildInfo.RLock()
+	version, gitSHA := buildInfo.version, buildInfo.gitSHA
+	buildInfo.RUnlock()
+
+	if len(version) == 0 {
+		version = "unknown"
+	}
+	if len(gitSHA) == 0 {
+		gitSHA = "unknown"
+	}
+	return []string{"version", "git_sha", "go_version"},
+		[]string{version, gitSHA, runtime.Version()}
+}
+
+// buildInfoStat returns the build info gauge as a labelled stat.
+func buildInfoStat() LocalStat {
+	names, values := buildInfoLabels()
+	labels := make(map[string]string, len(names))
+	for i, n := range names {
+		labels[n] = values[i]
+	}
+	v := int64(1)
+	return LocalStat{
+		Value:           &v,
+		labelsAndValues: labels,
+	}
+}
+
+// systemMetricsReporter is implemented by types that may collect system
+// metrics themselves each time metrics are reported.
+type systemMetricsReporter interface {
+	reportsSystemMetrics() bool
+}
+
+//------------------------------------------------------------------------------
+
+// System periodically updates system metrics, such as memory usage, CPU time
+// and open file descriptors, as gauges of a metrics type.
+type System struct {
+	stats Type
+	start time.Time
+
+	closeOnce  sync.Once
+	closedChan chan struct{}
+	doneChan   chan struct{}
+}
+
+// NewSystem starts updating system metrics as gauges of a metrics type until
+// Close is called, and sets the constant gauge system.build_info to 1. Types
+// that collect system metrics themselves each time metrics are reported (such
+// as stdout) are left untouched.
+func NewSystem(stats Type) *System {
+	s := &System{
+		stats:      stats,
+		start:      time.Now(),
+		closedChan: make(chan struct{}),
+		doneChan:   make(chan struct{}),
+	}
+	if r, ok := stats.(systemMetricsReporter); ok && r.reportsSystemMetrics() {
+		close(s.doneChan)
+		return s
+	}
+	names, values := buildInfoLabels()
+	stats.GetGaugeVec(buildInfoPath, names).With(values...).Set(1)
+	go s.loop()
+	return s
+}
+
+func (s *System) update(gauges map[string]StatGauge) {
+	for k, v := range systemMetrics(s.start) {
+		g, exists := gauges[k]
+		if !exists {
+			g = s.stats.GetGauge(k)
+			gauges[k] = g
+		}
+		g.Set(v)
+	}
+}
+
+func (s *System) loop() {
+	defer close(s.doneChan)
+
+	gauges := map[string]StatGauge{}
+	s.update(gauges)
+
+	ticker := time.NewTicker(systemMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.update(gauges)
+		case <-s.closedChan:
+			return
+		}
+	}
+}
+
+// Close stops updating system metrics.
+func (s *System) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closedChan)
+	})
+	<-s.doneChan
+}
+
+//------------------------------------------------------------------------------
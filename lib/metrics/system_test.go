@@ -0,0 +1,114 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSystemMetricsValues(t *testing.T) {
+	system := systemMetrics(time.Now().Add(-time.Second))
+	for _, k := range []string{
+		"system.uptime",
+		"system.goroutines",
+		"system.mem.heap_bytes",
+		"system.mem.sys_bytes",
+		"system.mem.alloc_bytes",
+		"system.gc.count",
+		"system.gc.pause_total_ns",
+	} {
+		if _, exists := system[k]; !exists {
+			t.Errorf("Missing system metric: %v", k)
+		}
+	}
+	if system["system.uptime"] < 1000 {
+		t.Errorf("Wrong uptime: %v", system["system.uptime"])
+	}
+	if system["system.mem.heap_bytes"] <= 0 {
+		t.Errorf("Wrong heap bytes: %v", system["system.mem.heap_bytes"])
+	}
+}
+
+func TestSystemUpdatesGauges(t *testing.T) {
+	local := NewLocal()
+
+	s := NewSystem(local)
+	s.Close()
+
+	counters := local.GetCounters()
+	if counters["system.goroutines"] <= 0 {
+		t.Errorf("Expected goroutines gauge to be set: %v", counters)
+	}
+	if counters["system.mem.sys_bytes"] <= 0 {
+		t.Errorf("Expected sys bytes gauge to be set: %v", counters)
+	}
+}
+
+func TestSystemBuildInfo(t *testing.T) {
+	SetBuildInfo("v1.2.3", "abc123")
+	defer SetBuildInfo("", "")
+
+	local := NewLocal()
+
+	s := NewSystem(local)
+	s.Close()
+
+	stats := local.GetCounterVecs()[buildInfoPath]
+	if exp, act := 1, len(stats); exp != act {
+		t.Fatalf("Wrong count of build info gauges: %v != %v", act, exp)
+	}
+	if exp, act := int64(1), *stats[0].Value; exp != act {
+		t.Errorf("Wrong build info value: %v != %v", act, exp)
+	}
+	exp := map[string]string{
+		"version":    "v1.2.3",
+		"git_sha":    "abc123",
+		"go_version": runtime.Version(),
+	}
+	for k, v := range exp {
+		if act := stats[0].LabelsAndValues()[k]; act != v {
+			t.Errorf("Wrong %v label: %v != %v", k, act, v)
+		}
+	}
+}
+
+func TestSystemIgnoresReporters(t *testing.T) {
+	buf := &syncBuffer{}
+
+	s, err := newStdout(NewConfig(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := WithMapping(s, []MappingRuleConfig{{Pattern: "foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sys := NewSystem(mapped)
+	sys.Close()
+
+	if _, exists := s.local.GetCounters()["system.goroutines"]; exists {
+		t.Error("Expected system metrics not to be registered")
+	}
+}
@@ -119,4 +119,21 @@ type WithHandlerFunc interface {
 	HandlerFunc() http.HandlerFunc
 }
 
+// jsonHandlerProvider is implemented by types that are able to serve their
+// metrics as JSON documents grouped by component instance.
+type jsonHandlerProvider interface {
+	jsonHandlerFunc() (http.HandlerFunc, bool)
+}
+
+// JSONHandlerFunc returns an http.HandlerFunc that serves the metrics of a
+// Type as a JSON array of documents grouped by component instance, in the same
+// form as those written by the stdout type. Returns false if the Type does not
+// support grouped JSON metrics.
+func JSONHandlerFunc(t Type) (http.HandlerFunc, bool) {
+	if p, ok := t.(jsonHandlerProvider); ok {
+		return p.jsonHandlerFunc()
+	}
+	return nil, false
+}
+
 //------------------------------------------------------------------------------
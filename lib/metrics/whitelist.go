@@ -290,4 +290,21 @@ func (h *Whitelist) HandlerFunc() http.HandlerFunc {
 	}
 }
 
+// jsonHandlerFunc returns the grouped JSON metrics handler of the child type,
+// if it has one.
+func (h *Whitelist) jsonHandlerFunc() (http.HandlerFunc, bool) {
+	return JSONHandlerFunc(h.s)
+}
+
+// flushNow flushes the metrics of the child type, if it pushes metrics.
+func (h *Whitelist) flushNow() bool {
+	return Flush(h.s)
+}
+
+// resetNow resets the metrics of the child type, if it aggregates metrics
+// locally.
+func (h *Whitelist) resetNow() bool {
+	return Reset(h.s)
+}
+
 //------------------------------------------------------------------------------
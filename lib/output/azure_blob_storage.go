@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeAzureBlobStorage] = TypeSpec{
+		constructor: NewAzureBlobStorage,
+		description: `
+Writes messages as blobs to an Azure Blob Storage container. Each message of a
+batch is written with its own request, and messages that fail are retried
+individually. Message metadata is stored as blob metadata, where characters
+that are not valid within a metadata name are replaced with underscores.
+
+The fields ` + "`container`" + `, ` + "`path`" + `, ` + "`content_type`" + `
+and ` + "`content_encoding`" + ` can be set dynamically using
+[function interpolations](../config_interpolation.md#functions), which are
+resolved individually for each message of a batch. This makes it possible to
+land data within a partitioned layout, for example with a path such as
+` + "`${!timestamp:2006/01/02}/${!uuid_v4}.json`" + `.
+
+### Blob Types
+
+When ` + "`blob_type`" + ` is ` + "`BLOCK`" + ` each message is written as a
+block blob, replacing any existing blob at the same path.
+
+When ` + "`blob_type`" + ` is ` + "`APPEND`" + ` the contents of each message
+are appended to an append blob, which is created on the first write to a path
+that does not yet exist. Messages are appended as they are, and therefore a
+delimiter such as a newline should be added to the contents of messages when
+the blob is expected to contain one record per line.
+
+### Credentials
+
+Authentication uses either the ` + "`storage_access_key`" + ` or the
+` + "`storage_sas_token`" + ` of the ` + "`storage_account`" + `, or a
+` + "`storage_connection_string`" + ` which may also specify custom endpoints
+or ` + "`UseDevelopmentStorage=true`" + ` for the storage emulator. When
+running within Azure it's also possible to authenticate with a managed identity
+by setting ` + "`managed_identity.enabled`" + ` to ` + "`true`" + `, and a
+user-assigned identity can be selected with ` + "`managed_identity.client_id`" + `.
+The identity requires the Storage Blob Data Contributor role, or equivalent
+permissions, on the target containers.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewAzureBlobStorage creates a new AzureBlobStorage output type.
+func NewAzureBlobStorage(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	a, err := writer.NewAzureBlobStorage(conf.AzureBlobStorage, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(
+		"azure_blob_storage", a, log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
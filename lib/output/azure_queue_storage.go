@@ -0,0 +1,81 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeAzureQueueStorage] = TypeSpec{
+		constructor: NewAzureQueueStorage,
+		description: `
+Adds messages to an Azure Storage queue. Message contents are base64 encoded,
+which is the format expected by most Azure Storage clients including the
+` + "`azure_blob_storage`" + ` input. Each message of a batch is added with its
+own request, and messages that fail are retried individually.
+
+The field ` + "`queue_name`" + ` can be set dynamically using
+[function interpolations](../config_interpolation.md#functions), which are
+resolved individually for each message of a batch.
+
+### Credentials
+
+Authentication uses either the ` + "`storage_access_key`" + ` or the
+` + "`storage_sas_token`" + ` of the ` + "`storage_account`" + `, or a
+` + "`storage_connection_string`" + ` which may also specify custom endpoints
+or ` + "`UseDevelopmentStorage=true`" + ` for the storage emulator. When
+running within Azure it's also possible to authenticate with a managed identity
+by setting ` + "`managed_identity.enabled`" + ` to ` + "`true`" + `, and a
+user-assigned identity can be selected with ` + "`managed_identity.client_id`" + `.
+
+### Scheduled Messages
+
+The field ` + "`scheduled_enqueue_time`" + ` supports interpolation and can be
+either an RFC3339 timestamp or a duration relative to the time the message is
+sent, such as ` + "`30s`" + `. Messages are added with a visibility timeout
+that hides them from consumers until that time, up to a maximum of seven days.
+
+The field ` + "`ttl`" + ` sets the time-to-live of messages as a duration,
+where a negative value means messages never expire. When empty the queue
+default of seven days is used.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewAzureQueueStorage creates a new AzureQueueStorage output type.
+func NewAzureQueueStorage(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	a, err := writer.NewAzureQueueStorage(conf.AzureQueueStorage, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(
+		"azure_queue_storage", a, log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
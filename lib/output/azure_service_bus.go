@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeAzureServiceBus] = TypeSpec{
+		constructor: NewAzureServiceBus,
+		description: `
+Sends messages to an Azure Service Bus queue or topic. Message batches are sent
+within a single request, and metadata values are sent along with each message
+as custom properties.
+
+The ` + "`entity`" + ` is the name of the queue or topic to send to, and when
+empty defaults to the ` + "`EntityPath`" + ` of the connection string. The
+fields ` + "`entity`, `session_id`, `message_id`, `content_type` and `scheduled_enqueue_time`" + `
+can be set dynamically using
+[function interpolations](../config_interpolation.md#functions), which are
+resolved individually for each message of a batch.
+
+### Credentials
+
+Authentication uses either a shared access policy from the
+` + "`connection_string`" + ` of the namespace, or a managed identity when
+running within Azure by setting ` + "`namespace`" + ` and
+` + "`managed_identity.enabled`" + ` to ` + "`true`" + `. A user-assigned
+identity can be selected with ` + "`managed_identity.client_id`" + `.
+
+### Sessions
+
+Queues and subscriptions that require sessions only accept messages with a
+session ID, and messages with the same ID are delivered in order to a single
+consumer. For example, in order to group messages by a customer field:
+
+` + "``` yaml" + `
+output:
+  azure_service_bus:
+    connection_string: ${SERVICE_BUS_CONNECTION_STRING}
+    entity: orders
+    session_id: ${!json_field:customer.id}
+` + "```" + `
+
+### Scheduled Messages
+
+The field ` + "`scheduled_enqueue_time`" + ` can be either an RFC3339 timestamp
+or a duration relative to the time the message is sent, such as ` + "`1h`" + `.
+Messages are hidden from consumers until that time. Messages with a value that
+cannot be parsed are sent immediately and an error is logged.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewAzureServiceBus creates a new AzureServiceBus output type.
+func NewAzureServiceBus(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	a, err := writer.NewAzureServiceBus(conf.AzureServiceBus, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(
+		"azure_service_bus", a, log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
@@ -105,7 +105,9 @@ outputs.
 
 The try pattern attempts to send each message to only one output, starting from
 the first output on the list. If an output attempt fails then the broker
-attempts to send to the next output in the list and so on.
+attempts to send to the next output in the list and so on. When an output
+reports which messages of a batch failed only those messages are sent to the
+next output.
 
 This pattern is useful for triggering events in the case where certain output
 targets have broken. For example, if you had an output type ` + "`http_client`" + `
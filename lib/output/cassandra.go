@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeCassandra] = TypeSpec{
+		constructor: NewCassandra,
+		description: `
+Runs a CQL query against a Cassandra or ScyllaDB cluster for each message. A
+batch of messages is executed as a single batch statement, where the type of
+the batch is set with ` + "`batch_type`" + ` and is one of ` + "`LOGGED`" + `,
+` + "`UNLOGGED`" + ` or ` + "`COUNTER`" + `. Messages should be batched with a
+` + "[`batch`](../processors/README.md#batch)" + ` processor in order to take
+advantage of batch statements.
+
+Queries with arguments are prepared once and cached by the driver. The values
+of the arguments are set with ` + "`args`" + `, which is a list of
+[function interpolations](../config_interpolation.md#functions) that are
+resolved individually for each message of a batch and bound to the placeholders
+of the query in order:
+
+` + "``` yaml" + `
+cassandra:
+  addresses:
+    - localhost:9042
+  query: INSERT INTO foo.bar (id, content, created_at) VALUES (?, ?, ?)
+  args:
+    - ${!json_field:id}
+    - ${!content}
+    - ${!timestamp_unix}000
+  batch_type: UNLOGGED
+` + "```" + `
+
+Arguments are converted into the type of the column they are bound to, which
+supports text, numeric, ` + "`boolean`" + `, ` + "`uuid`" + `,
+` + "`inet`" + ` and ` + "`date`" + ` columns, and ` + "`timestamp`" + `
+columns from either RFC3339 timestamps or unix timestamps in milliseconds.
+
+### Routing
+
+When ` + "`token_aware`" + ` is enabled queries are sent directly to a replica
+of the partition they modify, falling back to round robin selection of hosts.
+For batch statements the partition of the first statement is used, and
+therefore ` + "`UNLOGGED`" + ` batches are most efficient when their messages
+share a partition key.
+
+### Credentials
+
+TLS is configured with the ` + "`tls`" + ` fields, and username and password
+authentication with the ` + "`password_authenticator`" + ` fields.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewCassandra creates a new Cassandra output type.
+func NewCassandra(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	c, err := writer.NewCassandra(conf.Cassandra, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter("cassandra", c, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeClickHouse] = TypeSpec{
+		constructor: NewClickHouse,
+		description: `
+Inserts JSON documents into a ClickHouse table using the native protocol. Each
+message batch is inserted as a single block, and so for high throughput loading
+messages should be batched with a
+` + "[`batch`](../processors/README.md#batch)" + ` processor.
+
+The ` + "`dsn`" + ` has the format
+` + "`tcp://host1:9000?username=user&password=qwerty&database=clicks`" + `,
+where further parameters such as ` + "`compress`, `secure` and `alt_hosts`" + `
+are described in the
+[driver documentation](https://github.com/ClickHouse/clickhouse-go#dsn).
+
+### Columns
+
+Each of the ` + "`columns`" + ` has a ` + "`name`" + `, a ` + "`type`" + ` and
+an optional ` + "`path`" + `, which is the [dot path](../field_paths.md) of the
+field of the document that holds the column value and defaults to the name of
+the column:
+
+` + "``` yaml" + `
+clickhouse:
+  dsn: tcp://localhost:9000?database=analytics
+  table: events
+  columns:
+    - name: id
+      type: UInt64
+    - name: user
+      path: user.name
+      type: String
+    - name: created_at
+      type: DateTime
+    - name: tags
+      type: Array(String)
+    - name: score
+      type: Nullable(Float64)
+` + "```" + `
+
+The supported types are ` + "`String`, `UUID`, `Bool`, `Int8`, `Int16`" + `,
+` + "`Int32`, `Int64`, `UInt8`, `UInt16`, `UInt32`, `UInt64`, `Float32`" + `,
+` + "`Float64`, `Date` and `DateTime`" + `, each of which can be wrapped in
+either ` + "`Nullable`" + ` or ` + "`Array`" + `. Numbers may be given as either JSON numbers or strings,
+` + "`Date` and `DateTime`" + ` values as either RFC3339 timestamps or unix
+timestamps in seconds, and values that are not strings are serialised as JSON
+for ` + "`String`" + ` columns.
+
+Messages that are not JSON documents, that are missing the field of a column
+that isn't ` + "`Nullable`" + `, or that have values that cannot be converted
+to the column type are logged and dropped.
+
+### Asynchronous Inserts
+
+When ` + "`async_insert`" + ` is true ClickHouse buffers inserted rows and
+writes them to the table in larger parts, which reduces the number of parts
+created by frequent small batches. When ` + "`wait_for_async_insert`" + ` is
+true messages are only acknowledged once the buffered rows have been written,
+otherwise they are acknowledged as soon as they are buffered, which is faster
+but means rows can be lost if the server fails. Asynchronous inserts require a
+version of ClickHouse that supports them.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewClickHouse creates a new ClickHouse output type.
+func NewClickHouse(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	c, err := writer.NewClickHouse(conf.ClickHouse, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(
+		TypeClickHouse, c, log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
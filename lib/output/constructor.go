@@ -60,137 +60,188 @@ var Constructors = map[string]TypeSpec{}
 
 // String constants representing each output type.
 const (
-	TypeAMQP            = "amqp"
-	TypeBroker          = "broker"
-	TypeCache           = "cache"
-	TypeDrop            = "drop"
-	TypeDropOnError     = "drop_on_error"
-	TypeDynamic         = "dynamic"
-	TypeDynamoDB        = "dynamodb"
-	TypeElasticsearch   = "elasticsearch"
-	TypeFile            = "file"
-	TypeFiles           = "files"
-	TypeGCPPubSub       = "gcp_pubsub"
-	TypeHDFS            = "hdfs"
-	TypeHTTPClient      = "http_client"
-	TypeHTTPServer      = "http_server"
-	TypeInproc          = "inproc"
-	TypeKafka           = "kafka"
-	TypeKinesis         = "kinesis"
-	TypeKinesisFirehose = "kinesis_firehose"
-	TypeMQTT            = "mqtt"
-	TypeNanomsg         = "nanomsg"
-	TypeNATS            = "nats"
-	TypeNATSStream      = "nats_stream"
-	TypeNSQ             = "nsq"
-	TypeRedisHash       = "redis_hash"
-	TypeRedisList       = "redis_list"
-	TypeRedisPubSub     = "redis_pubsub"
-	TypeRedisStreams    = "redis_streams"
-	TypeRetry           = "retry"
-	TypeS3              = "s3"
-	TypeSNS             = "sns"
-	TypeSQS             = "sqs"
-	TypeSTDOUT          = "stdout"
-	TypeSwitch          = "switch"
-	TypeSyncResponse    = "sync_response"
-	TypeTCP             = "tcp"
-	TypeUDP             = "udp"
-	TypeWebsocket       = "websocket"
-	TypeZMQ4            = "zmq4"
+	TypeAMQP              = "amqp"
+	TypeAzureBlobStorage  = "azure_blob_storage"
+	TypeAzureQueueStorage = "azure_queue_storage"
+	TypeAzureServiceBus   = "azure_service_bus"
+	TypeBroker            = "broker"
+	TypeCache             = "cache"
+	TypeCassandra         = "cassandra"
+	TypeClickHouse        = "clickhouse"
+	TypeDrop              = "drop"
+	TypeDropOnError       = "drop_on_error"
+	TypeDynamic           = "dynamic"
+	TypeDynamoDB          = "dynamodb"
+	TypeElasticsearch     = "elasticsearch"
+	TypeEmail             = "email"
+	TypeFile              = "file"
+	TypeFiles             = "files"
+	TypeGCPCloudStorage   = "gcp_cloud_storage"
+	TypeGCPPubSub         = "gcp_pubsub"
+	TypeGRPCClient        = "grpc_client"
+	TypeHDFS              = "hdfs"
+	TypeHTTPClient        = "http_client"
+	TypeHTTPServer        = "http_server"
+	TypeInproc            = "inproc"
+	TypeKafka             = "kafka"
+	TypeKinesis           = "kinesis"
+	TypeKinesisFirehose   = "kinesis_firehose"
+	TypeMongoDB           = "mongodb"
+	TypeMQTT              = "mqtt"
+	TypeNanomsg           = "nanomsg"
+	TypeNATS              = "nats"
+	TypeNATSJetStream     = "nats_jetstream"
+	TypeNATSStream        = "nats_stream"
+	TypeNSQ               = "nsq"
+	TypeOpenSearch        = "opensearch"
+	TypePulsar            = "pulsar"
+	TypeRedisHash         = "redis_hash"
+	TypeRedisList         = "redis_list"
+	TypeRedisPubSub       = "redis_pubsub"
+	TypeRedisStreams      = "redis_streams"
+	TypeRetry             = "retry"
+	TypeS3                = "s3"
+	TypeSFTP              = "sftp"
+	TypeSNS               = "sns"
+	TypeSplunkHEC         = "splunk_hec"
+	TypeSQLInsert         = "sql_insert"
+	TypeSQS               = "sqs"
+	TypeSTDOUT            = "stdout"
+	TypeSwitch            = "switch"
+	TypeSyncResponse      = "sync_response"
+	TypeTCP               = "tcp"
+	TypeTry               = "try"
+	TypeUDP               = "udp"
+	TypeWebsocket         = "websocket"
+	TypeZMQ4              = "zmq4"
+	TypeZMQ4N             = "zmq4n"
 )
 
 //------------------------------------------------------------------------------
 
 // Config is the all encompassing configuration struct for all output types.
 type Config struct {
-	Type            string                       `json:"type" yaml:"type"`
-	AMQP            writer.AMQPConfig            `json:"amqp" yaml:"amqp"`
-	Broker          BrokerConfig                 `json:"broker" yaml:"broker"`
-	Cache           writer.CacheConfig           `json:"cache" yaml:"cache"`
-	Drop            writer.DropConfig            `json:"drop" yaml:"drop"`
-	DropOnError     DropOnErrorConfig            `json:"drop_on_error" yaml:"drop_on_error"`
-	Dynamic         DynamicConfig                `json:"dynamic" yaml:"dynamic"`
-	DynamoDB        writer.DynamoDBConfig        `json:"dynamodb" yaml:"dynamodb"`
-	Elasticsearch   writer.ElasticsearchConfig   `json:"elasticsearch" yaml:"elasticsearch"`
-	File            FileConfig                   `json:"file" yaml:"file"`
-	Files           writer.FilesConfig           `json:"files" yaml:"files"`
-	GCPPubSub       writer.GCPPubSubConfig       `json:"gcp_pubsub" yaml:"gcp_pubsub"`
-	HDFS            writer.HDFSConfig            `json:"hdfs" yaml:"hdfs"`
-	HTTPClient      writer.HTTPClientConfig      `json:"http_client" yaml:"http_client"`
-	HTTPServer      HTTPServerConfig             `json:"http_server" yaml:"http_server"`
-	Inproc          InprocConfig                 `json:"inproc" yaml:"inproc"`
-	Kafka           writer.KafkaConfig           `json:"kafka" yaml:"kafka"`
-	Kinesis         writer.KinesisConfig         `json:"kinesis" yaml:"kinesis"`
-	KinesisFirehose writer.KinesisFirehoseConfig `json:"kinesis_firehose" yaml:"kinesis_firehose"`
-	MQTT            writer.MQTTConfig            `json:"mqtt" yaml:"mqtt"`
-	Nanomsg         writer.NanomsgConfig         `json:"nanomsg" yaml:"nanomsg"`
-	NATS            writer.NATSConfig            `json:"nats" yaml:"nats"`
-	NATSStream      writer.NATSStreamConfig      `json:"nats_stream" yaml:"nats_stream"`
-	NSQ             writer.NSQConfig             `json:"nsq" yaml:"nsq"`
-	Plugin          interface{}                  `json:"plugin,omitempty" yaml:"plugin,omitempty"`
-	RedisHash       writer.RedisHashConfig       `json:"redis_hash" yaml:"redis_hash"`
-	RedisList       writer.RedisListConfig       `json:"redis_list" yaml:"redis_list"`
-	RedisPubSub     writer.RedisPubSubConfig     `json:"redis_pubsub" yaml:"redis_pubsub"`
-	RedisStreams    writer.RedisStreamsConfig    `json:"redis_streams" yaml:"redis_streams"`
-	Retry           RetryConfig                  `json:"retry" yaml:"retry"`
-	S3              writer.AmazonS3Config        `json:"s3" yaml:"s3"`
-	SNS             writer.SNSConfig             `json:"sns" yaml:"sns"`
-	SQS             writer.AmazonSQSConfig       `json:"sqs" yaml:"sqs"`
-	STDOUT          STDOUTConfig                 `json:"stdout" yaml:"stdout"`
-	Switch          SwitchConfig                 `json:"switch" yaml:"switch"`
-	SyncResponse    struct{}                     `json:"sync_response" yaml:"sync_response"`
-	TCP             writer.TCPConfig             `json:"tcp" yaml:"tcp"`
-	UDP             writer.UDPConfig             `json:"udp" yaml:"udp"`
-	Websocket       writer.WebsocketConfig       `json:"websocket" yaml:"websocket"`
-	ZMQ4            *writer.ZMQ4Config           `json:"zmq4,omitempty" yaml:"zmq4,omitempty"`
-	Processors      []processor.Config           `json:"processors" yaml:"processors"`
+	Type              string                         `json:"type" yaml:"type"`
+	AMQP              writer.AMQPConfig              `json:"amqp" yaml:"amqp"`
+	AzureBlobStorage  writer.AzureBlobStorageConfig  `json:"azure_blob_storage" yaml:"azure_blob_storage"`
+	AzureQueueStorage writer.AzureQueueStorageConfig `json:"azure_queue_storage" yaml:"azure_queue_storage"`
+	AzureServiceBus   writer.AzureServiceBusConfig   `json:"azure_service_bus" yaml:"azure_service_bus"`
+	Broker            BrokerConfig                   `json:"broker" yaml:"broker"`
+	Cache             writer.CacheConfig             `json:"cache" yaml:"cache"`
+	Cassandra         writer.CassandraConfig         `json:"cassandra" yaml:"cassandra"`
+	ClickHouse        writer.ClickHouseConfig        `json:"clickhouse" yaml:"clickhouse"`
+	Drop              writer.DropConfig              `json:"drop" yaml:"drop"`
+	DropOnError       DropOnErrorConfig              `json:"drop_on_error" yaml:"drop_on_error"`
+	Dynamic           DynamicConfig                  `json:"dynamic" yaml:"dynamic"`
+	DynamoDB          writer.DynamoDBConfig          `json:"dynamodb" yaml:"dynamodb"`
+	Elasticsearch     writer.ElasticsearchConfig     `json:"elasticsearch" yaml:"elasticsearch"`
+	Email             writer.EmailConfig             `json:"email" yaml:"email"`
+	File              FileConfig                     `json:"file" yaml:"file"`
+	Files             writer.FilesConfig             `json:"files" yaml:"files"`
+	GCPCloudStorage   writer.GCPCloudStorageConfig   `json:"gcp_cloud_storage" yaml:"gcp_cloud_storage"`
+	GCPPubSub         writer.GCPPubSubConfig         `json:"gcp_pubsub" yaml:"gcp_pubsub"`
+	GRPCClient        writer.GRPCClientConfig        `json:"grpc_client" yaml:"grpc_client"`
+	HDFS              writer.HDFSConfig              `json:"hdfs" yaml:"hdfs"`
+	HTTPClient        writer.HTTPClientConfig        `json:"http_client" yaml:"http_client"`
+	HTTPServer        HTTPServerConfig               `json:"http_server" yaml:"http_server"`
+	Inproc            InprocConfig                   `json:"inproc" yaml:"inproc"`
+	Kafka             writer.KafkaConfig             `json:"kafka" yaml:"kafka"`
+	Kinesis           writer.KinesisConfig           `json:"kinesis" yaml:"kinesis"`
+	KinesisFirehose   writer.KinesisFirehoseConfig   `json:"kinesis_firehose" yaml:"kinesis_firehose"`
+	MongoDB           writer.MongoDBConfig           `json:"mongodb" yaml:"mongodb"`
+	MQTT              writer.MQTTConfig              `json:"mqtt" yaml:"mqtt"`
+	Nanomsg           writer.NanomsgConfig           `json:"nanomsg" yaml:"nanomsg"`
+	NATS              writer.NATSConfig              `json:"nats" yaml:"nats"`
+	NATSJetStream     writer.NATSJetStreamConfig     `json:"nats_jetstream" yaml:"nats_jetstream"`
+	NATSStream        writer.NATSStreamConfig        `json:"nats_stream" yaml:"nats_stream"`
+	NSQ               writer.NSQConfig               `json:"nsq" yaml:"nsq"`
+	OpenSearch        writer.ElasticsearchConfig     `json:"opensearch" yaml:"opensearch"`
+	Plugin            interface{}                    `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	Pulsar            writer.PulsarConfig            `json:"pulsar" yaml:"pulsar"`
+	RedisHash         writer.RedisHashConfig         `json:"redis_hash" yaml:"redis_hash"`
+	RedisList         writer.RedisListConfig         `json:"redis_list" yaml:"redis_list"`
+	RedisPubSub       writer.RedisPubSubConfig       `json:"redis_pubsub" yaml:"redis_pubsub"`
+	RedisStreams      writer.RedisStreamsConfig      `json:"redis_streams" yaml:"redis_streams"`
+	Retry             RetryConfig                    `json:"retry" yaml:"retry"`
+	S3                writer.AmazonS3Config          `json:"s3" yaml:"s3"`
+	SFTP              writer.SFTPConfig              `json:"sftp" yaml:"sftp"`
+	SNS               writer.SNSConfig               `json:"sns" yaml:"sns"`
+	SplunkHEC         writer.SplunkHECConfig         `json:"splunk_hec" yaml:"splunk_hec"`
+	SQLInsert         writer.SQLInsertConfig         `json:"sql_insert" yaml:"sql_insert"`
+	SQS               writer.AmazonSQSConfig         `json:"sqs" yaml:"sqs"`
+	STDOUT            STDOUTConfig                   `json:"stdout" yaml:"stdout"`
+	Switch            SwitchConfig                   `json:"switch" yaml:"switch"`
+	SyncResponse      struct{}                       `json:"sync_response" yaml:"sync_response"`
+	TCP               writer.TCPConfig               `json:"tcp" yaml:"tcp"`
+	Try               TryConfig                      `json:"try" yaml:"try"`
+	UDP               writer.UDPConfig               `json:"udp" yaml:"udp"`
+	Websocket         writer.WebsocketConfig         `json:"websocket" yaml:"websocket"`
+	ZMQ4              *writer.ZMQ4Config             `json:"zmq4,omitempty" yaml:"zmq4,omitempty"`
+	ZMQ4N             writer.ZMQ4NConfig             `json:"zmq4n" yaml:"zmq4n"`
+	Processors        []processor.Config             `json:"processors" yaml:"processors"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:            "stdout",
-		AMQP:            writer.NewAMQPConfig(),
-		Broker:          NewBrokerConfig(),
-		Cache:           writer.NewCacheConfig(),
-		Drop:            writer.NewDropConfig(),
-		DropOnError:     NewDropOnErrorConfig(),
-		Dynamic:         NewDynamicConfig(),
-		DynamoDB:        writer.NewDynamoDBConfig(),
-		Elasticsearch:   writer.NewElasticsearchConfig(),
-		File:            NewFileConfig(),
-		Files:           writer.NewFilesConfig(),
-		GCPPubSub:       writer.NewGCPPubSubConfig(),
-		HDFS:            writer.NewHDFSConfig(),
-		HTTPClient:      writer.NewHTTPClientConfig(),
-		HTTPServer:      NewHTTPServerConfig(),
-		Inproc:          NewInprocConfig(),
-		Kafka:           writer.NewKafkaConfig(),
-		Kinesis:         writer.NewKinesisConfig(),
-		KinesisFirehose: writer.NewKinesisFirehoseConfig(),
-		MQTT:            writer.NewMQTTConfig(),
-		Nanomsg:         writer.NewNanomsgConfig(),
-		NATS:            writer.NewNATSConfig(),
-		NATSStream:      writer.NewNATSStreamConfig(),
-		NSQ:             writer.NewNSQConfig(),
-		Plugin:          nil,
-		RedisHash:       writer.NewRedisHashConfig(),
-		RedisList:       writer.NewRedisListConfig(),
-		RedisPubSub:     writer.NewRedisPubSubConfig(),
-		RedisStreams:    writer.NewRedisStreamsConfig(),
-		Retry:           NewRetryConfig(),
-		S3:              writer.NewAmazonS3Config(),
-		SNS:             writer.NewSNSConfig(),
-		SQS:             writer.NewAmazonSQSConfig(),
-		STDOUT:          NewSTDOUTConfig(),
-		Switch:          NewSwitchConfig(),
-		SyncResponse:    struct{}{},
-		TCP:             writer.NewTCPConfig(),
-		UDP:             writer.NewUDPConfig(),
-		Websocket:       writer.NewWebsocketConfig(),
-		ZMQ4:            writer.NewZMQ4Config(),
-		Processors:      []processor.Config{},
+		Type:              "stdout",
+		AMQP:              writer.NewAMQPConfig(),
+		AzureBlobStorage:  writer.NewAzureBlobStorageConfig(),
+		AzureQueueStorage: writer.NewAzureQueueStorageConfig(),
+		AzureServiceBus:   writer.NewAzureServiceBusConfig(),
+		Broker:            NewBrokerConfig(),
+		Cache:             writer.NewCacheConfig(),
+		Cassandra:         writer.NewCassandraConfig(),
+		ClickHouse:        writer.NewClickHouseConfig(),
+		Drop:              writer.NewDropConfig(),
+		DropOnError:       NewDropOnErrorConfig(),
+		Dynamic:           NewDynamicConfig(),
+		DynamoDB:          writer.NewDynamoDBConfig(),
+		Elasticsearch:     writer.NewElasticsearchConfig(),
+		Email:             writer.NewEmailConfig(),
+		File:              NewFileConfig(),
+		Files:             writer.NewFilesConfig(),
+		GCPCloudStorage:   writer.NewGCPCloudStorageConfig(),
+		GCPPubSub:         writer.NewGCPPubSubConfig(),
+		GRPCClient:        writer.NewGRPCClientConfig(),
+		HDFS:              writer.NewHDFSConfig(),
+		HTTPClient:        writer.NewHTTPClientConfig(),
+		HTTPServer:        NewHTTPServerConfig(),
+		Inproc:            NewInprocConfig(),
+		Kafka:             writer.NewKafkaConfig(),
+		Kinesis:           writer.NewKinesisConfig(),
+		KinesisFirehose:   writer.NewKinesisFirehoseConfig(),
+		MongoDB:           writer.NewMongoDBConfig(),
+		MQTT:              writer.NewMQTTConfig(),
+		Nanomsg:           writer.NewNanomsgConfig(),
+		NATS:              writer.NewNATSConfig(),
+		NATSJetStream:     writer.NewNATSJetStreamConfig(),
+		NATSStream:        writer.NewNATSStreamConfig(),
+		NSQ:               writer.NewNSQConfig(),
+		OpenSearch:        writer.NewOpenSearchConfig(),
+		Plugin:            nil,
+		Pulsar:            writer.NewPulsarConfig(),
+		RedisHash:         writer.NewRedisHashConfig(),
+		RedisList:         writer.NewRedisListConfig(),
+		RedisPubSub:       writer.NewRedisPubSubConfig(),
+		RedisStreams:      writer.NewRedisStreamsConfig(),
+		Retry:             NewRetryConfig(),
+		S3:                writer.NewAmazonS3Config(),
+		SFTP:              writer.NewSFTPConfig(),
+		SNS:               writer.NewSNSConfig(),
+		SplunkHEC:         writer.NewSplunkHECConfig(),
+		SQLInsert:         writer.NewSQLInsertConfig(),
+		SQS:               writer.NewAmazonSQSConfig(),
+		STDOUT:            NewSTDOUTConfig(),
+		Switch:            NewSwitchConfig(),
+		SyncResponse:      struct{}{},
+		TCP:               writer.NewTCPConfig(),
+		Try:               NewTryConfig(),
+		UDP:               writer.NewUDPConfig(),
+		Websocket:         writer.NewWebsocketConfig(),
+		ZMQ4:              writer.NewZMQ4Config(),
+		ZMQ4N:             writer.NewZMQ4NConfig(),
+		Processors:        []processor.Config{},
 	}
 }
 
@@ -72,6 +72,15 @@ In which case the top level document fields will be written at the root of the
 item, potentially overwriting previously defined column values. If a path is not
 found within a document the column will not be populated.
 
+### Batching
+
+The messages of a batch are written with ` + "`BatchWriteItem`" + ` requests
+of up to 25 items each. Items that DynamoDB reports as unprocessed are retried
+according to the ` + "`backoff`" + ` and ` + "`max_retries`" + ` fields, and any
+items that remain unprocessed once the retries are exhausted are reported as
+errors of their respective messages. When this output is wrapped within a
+` + "[`retry`](#retry)" + ` output only those failed messages are sent again.
+
 ### Credentials
 
 By default Benthos will use a shared credentials file when connecting to AWS
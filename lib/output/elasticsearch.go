@@ -36,9 +36,54 @@ func init() {
 Publishes messages into an Elasticsearch index. If the index does not exist then
 it is created with a dynamic mapping.
 
-Both the ` + "`id` and `index`" + ` fields can be dynamically set using function
-interpolations described [here](../config_interpolation.md#functions). When
-sending batched messages these interpolations are performed per message part.
+The fields ` + "`id`, `action`, `index`, `pipeline` and `routing`" + ` can be
+dynamically set using function interpolations described
+[here](../config_interpolation.md#functions). When sending batched messages
+these interpolations are performed per message part.
+
+### Actions
+
+The ` + "`action`" + ` of each message is one of ` + "`index`" + `,
+` + "`create`" + `, ` + "`update`" + ` or ` + "`delete`" + `, which makes it
+possible to apply change data capture streams to an index by setting the action
+from a metadata field, e.g. ` + "`${!metadata:operation}`" + `. The contents of
+messages with the action ` + "`delete`" + ` are ignored.
+
+An ` + "`update`" + ` merges the message into the existing document with the
+` + "`id`" + `, and fails when the document does not exist unless
+` + "`doc_as_upsert`" + ` is true, in which case the message is indexed as a new
+document. When conflicting updates are expected ` + "`retry_on_conflict`" + `
+sets the number of times Elasticsearch retries an update.
+
+### Scripted Updates
+
+When a ` + "`script.source`" + ` is set updates run the script instead of
+merging documents, where the message must be a JSON object that is provided to
+the script as ` + "`params`" + `. For example, the following config increments
+a counter of each document:
+
+` + "``` yaml" + `
+elasticsearch:
+  urls: [ http://localhost:9200 ]
+  index: counters
+  id: ${!json_field:id}
+  action: update
+  script:
+    source: ctx._source.count += params.count
+    scripted_upsert: true
+` + "```" + `
+
+When ` + "`script.scripted_upsert`" + ` is true the script also runs for
+documents that do not yet exist, starting from an empty document, and otherwise
+when ` + "`doc_as_upsert`" + ` is true the message is indexed for documents that
+do not yet exist.
+
+### AWS
+
+When ` + "`aws.enabled`" + ` is true requests are signed with AWS Signature
+Version 4 for the ` + "`aws.service`" + `, which is ` + "`es`" + ` for Amazon
+OpenSearch Service domains and ` + "`aoss`" + ` for OpenSearch Serverless
+collections.
 
 ### AWS Credentials
 
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeEmail] = TypeSpec{
+		constructor: NewEmail,
+		description: `
+Sends messages as emails via an SMTP server, which is useful for alerting
+pipelines. The fields ` + "`to`" + `, ` + "`cc`" + `, ` + "`bcc`" + `,
+` + "`subject`" + ` and ` + "`body`" + ` support
+[function interpolation](../config_interpolation.md#functions), and each
+recipient field may resolve to multiple comma separated addresses. The body is
+templated from the contents of a message with ` + "`${!content}`" + ` by
+default, and ` + "`${!json_field:path}`" + ` can be used in order to build a
+body from fields of a JSON document.
+
+When a ` + "`username`" + ` is set the server is authenticated with PLAIN
+authentication. When TLS is enabled the connection is established over implicit
+TLS (usually port 465), otherwise STARTTLS is used when the server supports it.
+
+### Attachments
+
+By default each message of a batch is sent as its own email, and messages that
+fail are retried individually. When ` + "`attachments.enabled`" + ` is set to
+` + "`true`" + ` each batch is sent as a single email, where the first message
+of the batch is used to resolve the recipients, subject and body, and the
+remaining messages are attached as files. The name of each attachment is
+resolved from ` + "`attachments.filename`" + `, falling back to
+` + "`attachment-N`" + ` when empty, and when ` + "`attachments.content_type`" + `
+is empty the type is detected from the extension of the filename or the
+contents of the attachment.
+
+In order to send batches of attachments use the ` + "`batch`" + ` processor:
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - batch:
+      count: 10
+      period: 1h
+output:
+  email:
+    address: smtp.example.com:587
+    username: benthos
+    password: ${SMTP_PASSWORD}
+    from: alerts@example.com
+    to: [ oncall@example.com ]
+    subject: 'Alert: ${!json_field:service}'
+    body: '${!json_field:summary}'
+    attachments:
+      enabled: true
+      filename: ${!metadata:filename}
+` + "```" + `
+
+` + tls.Documentation + ``,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewEmail creates a new Email output type.
+func NewEmail(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	e, err := writer.NewEmail(conf.Email, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter("email", e, log, stats)
+}
+
+//------------------------------------------------------------------------------
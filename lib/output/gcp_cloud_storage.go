@@ -0,0 +1,100 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeGCPCloudStorage] = TypeSpec{
+		constructor: NewGCPCloudStorage,
+		description: `
+Sends message parts as objects to a Google Cloud Storage bucket. Each object is
+uploaded with the path specified with the ` + "`path`" + ` field, and the
+metadata of each message is added to its object as custom metadata.
+
+The fields ` + "`path`, `content_type`, `content_encoding` and `storage_class`" + `
+support [function interpolation](../config_interpolation.md#functions), which
+is calculated per message of a batch.
+
+Credentials are found using
+[Application Default Credentials](https://cloud.google.com/docs/authentication/production).
+
+### Retention and Encryption
+
+Objects can be placed under a temporary hold or an event-based hold with
+` + "`temporary_hold`" + ` and ` + "`event_based_hold`" + ` respectively,
+which prevents them from being deleted or replaced until the hold is released,
+and when the bucket has a retention policy an event-based hold also delays the
+start of the retention period until it is released. When a
+` + "`kms_key_name`" + ` is set objects are encrypted with that Cloud KMS key
+rather than the default key of the bucket.
+
+### Codecs
+
+With the ` + "`all-bytes`" + ` codec each message of a batch is uploaded as its
+own object. With the ` + "`lines`" + ` codec all messages of a batch are
+written to a single object with each message followed by a newline, where the
+object fields are resolved from the first message of the batch. The codec
+` + "`delim:x`" + ` is the same as ` + "`lines`" + ` but with a custom
+delimiter ` + "`x`" + `.
+
+Objects are uploaded in chunks of ` + "`chunk_size`" + ` bytes as they are
+written, so that batches are not buffered as a whole. Setting
+` + "`chunk_size`" + ` to zero uploads each object in a single request, which
+requires fewer requests for small objects but cannot be retried when an upload
+fails part way. In order to write batches as single objects use the
+` + "`batch`" + ` processor:
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - batch:
+      count: 100000
+      period: 5m
+output:
+  gcp_cloud_storage:
+    bucket: data-lake
+    path: events/${!timestamp_unix}.jsonl
+    codec: lines
+    timeout: 5m
+` + "```" + ``,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewGCPCloudStorage creates a new GCP Cloud Storage output type.
+func NewGCPCloudStorage(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	g, err := writer.NewGCPCloudStorage(conf.GCPCloudStorage, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(TypeGCPCloudStorage, g, log, stats)
+}
+
+//------------------------------------------------------------------------------
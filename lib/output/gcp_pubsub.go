@@ -34,7 +34,14 @@ func init() {
 		constructor: NewGCPPubSub,
 		description: `
 Sends messages to a GCP Cloud Pub/Sub topic. Metadata from messages are sent as
-attributes.`,
+attributes.
+
+The field ` + "`ordering_key`" + ` supports
+[interpolation functions](../config_interpolation.md#functions), and when set
+enables message ordering on the topic, where messages that share an ordering key
+are delivered to ordered subscriptions in the order they were published. If
+publishing a message fails then publishing for its ordering key is resumed
+before the message is retried.`,
 	}
 }
 
@@ -0,0 +1,74 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeGRPCClient] = TypeSpec{
+		constructor: NewGRPCClient,
+		description: `
+Invokes a gRPC method for messages. The request and response types of the
+` + "`method`" + `, which is of the form ` + "`package.Service/Method`" + `,
+are read from a compiled protobuf descriptor set at the path
+` + "`descriptor_set`" + `, which can be generated with ` + "`protoc`" + `:
+
+` + "``` sh" + `
+protoc --include_imports --descriptor_set_out=./service.pb service.proto
+` + "```" + `
+
+Message contents are parsed as JSON and mapped onto the request type using the
+[canonical protobuf JSON mapping](https://developers.google.com/protocol-buffers/docs/proto3#json).
+Responses are discarded.
+
+For unary methods each message of a batch is sent as an individual request, and
+messages that fail are retried individually. For client streaming methods each
+batch is sent as a single stream of requests, and the batch is only
+acknowledged once the server has responded. Server streaming methods are not
+supported.
+
+The values of the field ` + "`metadata`" + ` are sent as metadata with each
+call and support
+[function interpolation](../config_interpolation.md#functions). For client
+streaming methods they are resolved from the first message of a batch.
+
+` + tls.Documentation + ``,
+	}
+}
+
+// NewGRPCClient creates a new GRPCClient output type.
+func NewGRPCClient(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	w, err := writer.NewGRPCClient(conf.GRPCClient, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter("grpc_client", w, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output/writer"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kerberos"
 )
 
 //------------------------------------------------------------------------------
@@ -37,7 +38,12 @@ Sends message parts as files to a HDFS directory. Each file is written
 with the path specified with the 'path' field, in order to have a different path
 for each object you should use function interpolations described
 [here](../config_interpolation.md#functions). When sending batched messages the
-interpolations are performed per message part.`,
+interpolations are performed per message part.
+
+` + kerberos.Documentation + `
+
+When Kerberos is enabled the field ` + "`user`" + ` can be left empty in order to
+act as the authenticated principal.`,
 	}
 }
 
@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output/writer"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/v3/lib/util/tls"
 )
 
@@ -39,8 +40,20 @@ acknowledged, which is propagated back to the input. The config field
 ` + "`ack_replicas`" + ` determines whether we wait for acknowledgement from all
 replicas or just a single broker.
 
+When ` + "`idempotent_write`" + ` is set to ` + "`true`" + ` the producer is
+assigned an ID by the brokers and each message is given a sequence number,
+which allows brokers to discard duplicates caused by the producer retrying
+sends. Idempotent writes require a ` + "`target_version`" + ` of at least
+0.11.0 and always wait for acknowledgement from all replicas. Note that this
+prevents duplicates introduced by retries within the producer only, messages
+that are resent by Benthos after a failed batch may still be duplicated.
+
 It is possible to specify a compression codec to use out of the following
-options: none, snappy, lz4 and gzip.
+options: none, snappy, lz4, gzip and zstd. The zstd codec requires a
+` + "`target_version`" + ` of at least 2.1.0. The field
+` + "`compression_level`" + ` sets the level of the gzip codec from 1 (best
+speed) to 9 (best compression), where -1 is the default level, and is not
+currently supported by the other codecs.
 
 If the field ` + "`key`" + ` is not empty then each message will be given its
 contents as a key.
@@ -55,7 +68,14 @@ value. If the key is empty then a partition is chosen at random. You can
 alternatively force the partitioner to round-robin partitions with the field
 ` + "`round_robin_partitions`" + `.
 
-` + tls.Documentation + ``,
+Metadata fields of messages are written as record headers (version 0.11+).
+When a [tracer](../tracers/README.md) is configured the span context of each
+message is also written to its headers, allowing consumers such as the
+` + "`kafka`" + ` input to continue the trace.
+
+` + tls.Documentation + `
+
+` + sasl.Documentation + ``,
 	}
 }
 
@@ -90,6 +90,7 @@ func (w *LineWriter) loop() {
 		mSent      = w.stats.GetCounter("batch.sent")
 		mBytesSent = w.stats.GetCounter("batch.bytes")
 		mLatency   = w.stats.GetTimer("batch.latency")
+		mInFlight  = w.stats.GetGauge("batch.in_flight")
 	)
 
 	defer func() {
@@ -119,6 +120,7 @@ func (w *LineWriter) loop() {
 
 		spans := tracing.CreateChildSpans("output_"+w.typeStr, ts.Payload)
 
+		mInFlight.Set(1)
 		var err error
 		t0 := time.Now()
 		if ts.Payload.Len() == 1 {
@@ -127,6 +129,7 @@ func (w *LineWriter) loop() {
 			_, err = fmt.Fprintf(w.handle, "%s%s%s", bytes.Join(message.GetAllBytes(ts.Payload), delim), delim, delim)
 		}
 		latency := time.Since(t0).Nanoseconds()
+		mInFlight.Set(0)
 		if err == nil {
 			mSent.Incr(1)
 			mPartsSent.Incr(int64(ts.Payload.Len()))
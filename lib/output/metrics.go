@@ -0,0 +1,54 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	yaml "gopkg.in/yaml.v3"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	metrics.SetOutputConstructor(newMetricsOutput)
+}
+
+// newMetricsOutput creates an output for the pipeline metrics type from a
+// generic config structure.
+func newMetricsOutput(conf interface{}, log log.Modular) (types.Output, error) {
+	oConf := NewConfig()
+	if conf != nil {
+		rawBytes, err := yaml.Marshal(conf)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(rawBytes, &oConf); err != nil {
+			return nil, fmt.Errorf("failed to parse output config: %v", err)
+		}
+	}
+	return New(oConf, types.NoopMgr(), log, metrics.Noop())
+}
+
+//------------------------------------------------------------------------------
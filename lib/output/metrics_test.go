@@ -0,0 +1,79 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+func TestMetricsPipelineOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_metrics_pipeline_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metrics.jsonl")
+
+	conf := metrics.NewConfig()
+	conf.Type = metrics.TypePipeline
+	conf.Pipeline.PushInterval = ""
+	conf.Pipeline.IncludePatterns = []string{`^input\.`}
+	conf.Pipeline.Output = map[string]interface{}{
+		"type": "file",
+		"file": map[string]interface{}{
+			"path": path,
+		},
+	}
+
+	stats, err := metrics.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats.GetCounter("input.count").Incr(3)
+	if err = stats.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if exp, act := 1, len(lines); exp != act {
+		t.Fatalf("Wrong count of lines: %v != %v: %s", act, exp, data)
+	}
+
+	var obj map[string]interface{}
+	if err = json.Unmarshal([]byte(lines[0]), &obj); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := float64(3), obj["input"].(map[string]interface{})["count"]; exp != act {
+		t.Errorf("Wrong metric value: %v != %v", act, exp)
+	}
+}
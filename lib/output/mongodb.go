@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeMongoDB] = TypeSpec{
+		constructor: NewMongoDB,
+		description: `
+Performs an operation against a MongoDB collection for each message. A batch of
+messages is sent as a single bulk write, and messages should be batched with a
+` + "[`batch`](../processors/README.md#batch)" + ` processor in order to take
+advantage of this.
+
+The ` + "`operation`" + ` is one of ` + "`insert-one`" + `,
+` + "`update-one`" + `, ` + "`update-many`" + `, ` + "`replace-one`" + `,
+` + "`delete-one`" + ` or ` + "`delete-many`" + `.
+
+The fields ` + "`document`" + ` and ` + "`filter`" + ` are
+[function interpolations](../config_interpolation.md#functions) that are
+resolved individually for each message of a batch, and must result in
+[extended JSON](https://docs.mongodb.com/manual/reference/mongodb-extended-json/)
+documents. Insert operations require a ` + "`document`" + `, delete operations
+require a ` + "`filter`" + `, and update and replace operations require both,
+where the ` + "`document`" + ` of an update contains update operators:
+
+` + "``` yaml" + `
+mongodb:
+  url: mongodb://localhost:27017
+  database: shop
+  collection: stock
+  operation: update-one
+  filter: '{"_id":"${!json_field:sku}"}'
+  document: '{"$inc":{"count":${!json_field:delta}}}'
+  upsert: true
+` + "```" + `
+
+When ` + "`upsert`" + ` is enabled update and replace operations insert a new
+document when no document matches the filter.
+
+### Errors
+
+Messages that fail, either because their documents could not be parsed or
+because the write was rejected, are retried individually. When
+` + "`ordered`" + ` is enabled the writes of a batch are executed in order and
+the first failure causes the remaining writes to also fail, otherwise each
+write is executed independently.
+
+### Write Concern
+
+The field ` + "`write_concern.w`" + ` is either a number of nodes,
+` + "`majority`" + ` or the name of a tag set, ` + "`write_concern.j`" + `
+requests acknowledgement that writes are written to the journal and
+` + "`write_concern.w_timeout`" + ` sets a time limit for acknowledgement. When
+no write concern is configured the default of the server is used.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewMongoDB creates a new MongoDB output type.
+func NewMongoDB(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	m, err := writer.NewMongoDB(conf.MongoDB, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter("mongodb", m, log, stats)
+}
+
+//------------------------------------------------------------------------------
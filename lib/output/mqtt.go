@@ -33,7 +33,11 @@ func init() {
 	Constructors[TypeMQTT] = TypeSpec{
 		constructor: NewMQTT,
 		description: `
-Pushes messages to an MQTT broker.`,
+Pushes messages to an MQTT broker.
+
+The ` + "`protocol_version`" + ` can be either ` + "`3.1.1`" + ` (the default) or
+` + "`5`" + `. With version 5 the metadata of messages are written as user
+properties.`,
 	}
 }
 
@@ -34,7 +34,7 @@ func init() {
 		constructor: NewNATS,
 		description: `
 Publish to an NATS subject. NATS is at-most-once, so delivery is not guaranteed.
-For at-least-once behaviour with NATS look at NATS Stream.
+For at-least-once behaviour with NATS look at NATS Stream or NATS JetStream.
 
 This output will interpolate functions within the subject field, you
 can find a list of functions [here](../config_interpolation.md#functions).`,
@@ -0,0 +1,64 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeNATSJetStream] = TypeSpec{
+		constructor: NewNATSJetStream,
+		description: `
+Publish to a NATS JetStream subject. Messages are only acknowledged once the
+stream that captures the subject has confirmed that they were stored, giving
+at-least-once delivery. Messages of a batch are published in parallel, and those
+not confirmed within the ` + "`ack_timeout`" + ` are retried individually.
+
+The fields ` + "`subject`, `msg_id` and `headers`" + ` support
+[function interpolation](../config_interpolation.md#functions), which are
+resolved individually for each message of a batch.
+
+### Deduplication
+
+When ` + "`msg_id`" + ` is set it is sent as the ` + "`Nats-Msg-Id`" + ` header,
+and the server discards messages with an ID that has already been seen within
+the duplicate window of the stream. Setting it to a value that uniquely
+identifies each message, such as ` + "`${!json_field:id}`" + `, prevents
+retries from resulting in duplicates within the stream.`,
+	}
+}
+
+// NewNATSJetStream creates a new NATSJetStream output type.
+func NewNATSJetStream(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	w, err := writer.NewNATSJetStream(conf.NATSJetStream, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter("nats_jetstream", w, log, stats)
+}
+
+//------------------------------------------------------------------------------
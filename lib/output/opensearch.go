@@ -0,0 +1,95 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeOpenSearch] = TypeSpec{
+		constructor: NewOpenSearch,
+		description: `
+Publishes messages into an OpenSearch index, supporting the same fields as the
+[` + "`elasticsearch`" + `](#elasticsearch) output. The defaults differ in
+that sniffing is disabled and documents are written without a type, as
+OpenSearch no longer supports types.
+
+The fields ` + "`id`, `action`, `index`, `pipeline` and `routing`" + ` can be
+dynamically set using function interpolations described
+[here](../config_interpolation.md#functions). When sending batched messages
+these interpolations are performed per message part.
+
+### Amazon OpenSearch Service
+
+Requests to an Amazon OpenSearch Service domain can be signed with AWS
+Signature Version 4 by setting ` + "`aws.enabled`" + ` to true, which means a
+signing proxy is not needed. The ` + "`aws.service`" + ` should be
+` + "`es`" + ` for managed domains and ` + "`aoss`" + ` for OpenSearch
+Serverless collections.
+
+### Data Streams
+
+Documents are appended to a data stream by setting the ` + "`index`" + ` to the
+name of the data stream and the ` + "`action`" + ` to ` + "`create`" + `, as
+data streams only accept new documents. Each document must contain a
+` + "`@timestamp`" + ` field. When the ` + "`id`" + ` is empty an ID is
+generated for each document:
+
+` + "``` yaml" + `
+opensearch:
+  urls: [ https://search-foo.eu-west-1.es.amazonaws.com ]
+  index: logs-benthos
+  action: create
+  id: ""
+  aws:
+    enabled: true
+    region: eu-west-1
+` + "```" + `
+
+### AWS Credentials
+
+By default Benthos will use a shared credentials file when connecting to AWS
+services. It's also possible to set them explicitly at the component level,
+allowing you to transfer data across accounts. You can find out more
+[in this document](../aws.md).`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewOpenSearch creates a new OpenSearch output type.
+func NewOpenSearch(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	openWriter, err := writer.NewElasticsearch(conf.OpenSearch, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(
+		TypeOpenSearch, openWriter, log, stats,
+	)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypePulsar] = TypeSpec{
+		constructor: NewPulsar,
+		description: `
+Writes messages to an Apache Pulsar topic, waiting for each message to be
+acknowledged by the broker. The metadata of messages are written as message
+properties.
+
+The fields ` + "`key` and `ordering_key`" + ` can be dynamically set using
+function interpolations described [here](../config_interpolation.md#functions).
+When sending batched messages these interpolations are performed per message
+part.
+
+By default the producer groups messages into batches of up to
+` + "`producer_batching.max_messages`" + `, waiting at most
+` + "`producer_batching.max_publish_delay`" + ` before sending a batch. When
+consumers use a ` + "`key_shared`" + ` subscription you should set
+` + "`producer_batching.key_based`" + ` to true, which groups messages of
+the same key into the same batch so that key ordering is preserved.
+
+### Authentication
+
+Connections are encrypted when the ` + "`url`" + ` uses the
+` + "`pulsar+ssl://`" + ` scheme, in which case a custom certificate
+authority can be set with ` + "`tls.root_cas_file`" + `. Clients can be
+authenticated either with a JSON Web Token, set within ` + "`auth.token`" + `,
+or with a client certificate, set within ` + "`auth.tls`" + `.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewPulsar creates a new Pulsar output type.
+func NewPulsar(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	w, err := writer.NewPulsar(conf.Pulsar, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(TypePulsar, w, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -34,15 +34,25 @@ func init() {
 		constructor: NewRedisStreams,
 		description: `
 Pushes messages to a Redis (v5.0+) Stream (which is created if it doesn't
-already exist) using the XADD command. It's possible to specify a maximum length
-of the target stream by setting it to a value greater than 0, in which case this
-cap is applied only when Redis is able to remove a whole macro node, for
-efficiency.
+already exist) using the XADD command. The field ` + "`stream`" + ` supports
+[interpolation functions](../config_interpolation.md#functions), which are
+resolved individually for each message of a batch, allowing messages to be
+routed to different streams.
 
 Redis stream entries are key/value pairs, as such it is necessary to specify the
 key to be set to the body of the message. All metadata fields of the message
 will also be set as key/value pairs, if there is a key collision between
-a metadata item and the body then the body takes precedence.`,
+a metadata item and the body then the body takes precedence.
+
+### Trimming
+
+It's possible to specify a maximum length of the target stream by setting
+` + "`max_length`" + ` to a value greater than 0. Alternatively, entries older
+than a duration can be trimmed by setting ` + "`max_age`" + `, such as
+` + "`24h`" + `, which requires Redis v6.2+ and relies on entries having the
+default time based IDs. Only one of these fields can be set, and in both cases
+the cap is applied only when Redis is able to remove a whole macro node, for
+efficiency, and therefore a stream can temporarily exceed it.`,
 	}
 }
 
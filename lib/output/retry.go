@@ -28,6 +28,8 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -53,6 +55,10 @@ This output type is useful whenever we wish to avoid reprocessing a message on
 the event of a failed send. We might, for example, have a dedupe processor that
 we want to avoid reapplying to the same message more than once in the pipeline.
 
+Some outputs (such as ` + "[`dynamodb`](#dynamodb)" + `) are able to report
+which messages of a batch failed to send, in which case only the failed messages
+are retried.
+
 Rather than retrying the same output you may wish to retry the send using a
 different output target (a dead letter queue). In which case you should instead
 use the ` + "[`broker`](#broker)" + ` output type with the pattern 'try'.`,
@@ -228,11 +234,12 @@ func (r *Retry) loop() {
 		}
 
 		var resOut types.Response
+		payload := ts.Payload
 
 	retryLoop:
 		for atomic.LoadInt32(&r.running) == 1 {
 			select {
-			case r.transactionsOut <- types.NewTransaction(ts.Payload, resChan):
+			case r.transactionsOut <- types.NewTransaction(payload, resChan):
 			case <-r.closeChan:
 				return
 			}
@@ -247,6 +254,7 @@ func (r *Retry) loop() {
 			if res.Error() != nil {
 				mError.Incr(1)
 				r.log.Errorf("Failed to send message: %v\n", res.Error())
+				payload = failedParts(payload, res.Error())
 
 				nextBackoff := r.backoff.NextBackOff()
 				if nextBackoff == backoff.Stop {
@@ -278,6 +286,24 @@ func (r *Retry) loop() {
 	}
 }
 
+// failedParts returns the messages of a batch that failed to send according to
+// a batch error, or the entire batch if the error does not identify individual
+// messages.
+func failedParts(msg types.Message, err error) types.Message {
+	bErr, ok := err.(*batch.Error)
+	if !ok || bErr.IndexedErrors() == 0 || bErr.Len() != msg.Len() {
+		return msg
+	}
+	failed := message.New(nil)
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			failed.Append(msg.Get(i))
+		}
+		return true
+	})
+	return failed
+}
+
 // Consume assigns a messages channel for the output to read.
 func (r *Retry) Consume(ts <-chan types.Transaction) error {
 	if r.transactionsIn != nil {
@@ -21,11 +21,14 @@
 package output
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -210,3 +213,91 @@ func TestRetrySadPath(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestRetryFailedParts(t *testing.T) {
+	conf := NewConfig()
+
+	childConf := NewConfig()
+	conf.Retry.Output = &childConf
+	conf.Retry.Backoff.InitialInterval = "10us"
+	conf.Retry.Backoff.MaxInterval = "10us"
+
+	output, err := NewRetry(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, ok := output.(*Retry)
+	if !ok {
+		t.Fatal("Failed to cast")
+	}
+
+	mOut := &mockOutput{
+		ts: make(chan types.Transaction),
+	}
+	ret.wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	if err = ret.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	testMsg := message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	})
+	go func() {
+		select {
+		case tChan <- types.NewTransaction(testMsg, resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}()
+
+	var tran types.Transaction
+	select {
+	case tran = <-mOut.ts:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	bErr := batch.NewError(tran.Payload, errors.New("nope")).Failed(1, errors.New("nah"))
+	select {
+	case tran.ResponseChan <- response.NewError(bErr):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case tran = <-mOut.ts:
+	case <-resChan:
+		t.Fatal("Received response not retry")
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	if exp, act := [][]byte{[]byte("bar")}, message.GetAllBytes(tran.Payload); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong retried payload: %s != %s", act, exp)
+	}
+
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case res := <-resChan:
+		if err = res.Error(); err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
@@ -40,8 +40,54 @@ In order to have a different path for each object you should use function
 interpolations described [here](../config_interpolation.md#functions), which are
 calculated per message of a batch.
 
-The fields ` + "`content_type` and `content_encoding`" + ` can also be set
-dynamically using function interpolation.
+The fields ` + "`content_type`, `content_encoding` and `storage_class`" + `
+can also be set dynamically using function interpolation, as can the values of
+` + "`tags`" + `, which are added to each object as tags. The storage class can
+be any supported by S3, such as ` + "`STANDARD_IA`" + ` or
+` + "`GLACIER_IR`" + `, and ` + "`acl`" + ` optionally sets a canned ACL such
+as ` + "`bucket-owner-full-control`" + ` on each object.
+
+### Codecs
+
+With the ` + "`all-bytes`" + ` codec each message of a batch is uploaded as its
+own object. With the ` + "`lines`" + ` codec all messages of a batch are
+written to a single object with each message followed by a newline, where the
+object fields such as ` + "`path`" + ` are resolved from the first message of
+the batch. The codec ` + "`delim:x`" + ` is the same as ` + "`lines`" + ` but
+with a custom delimiter ` + "`x`" + `.
+
+When writing batches as single objects the contents are streamed to S3 as a
+multipart upload, where each part is sent once ` + "`multipart.part_size`" + `
+bytes (minimum 5MB) have been written, with up to
+` + "`multipart.concurrency`" + ` parts in flight at once. This allows large
+batches to be uploaded without first being concatenated into a second copy in
+memory, although the messages of the batch are themselves still held in memory.
+An archive created by the ` + "[`archive`](../processors/README.md#archive)" + `
+processor is a single message, and is therefore buffered in full before it is
+uploaded in parts.
+
+The ` + "`timeout`" + ` applies to each request made to S3, such as the upload
+of each part, rather than to an upload as a whole:
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - batch:
+      count: 100000
+      period: 5m
+output:
+  s3:
+    bucket: data-lake
+    path: events/${!timestamp_unix}.jsonl
+    codec: lines
+` + "```" + `
+
+### Encryption
+
+Objects can be encrypted at rest by setting ` + "`server_side_encryption`" + `
+to either ` + "`AES256`" + ` or ` + "`aws:kms`" + `. When a
+` + "`kms_key_id`" + ` is set objects are encrypted with that KMS key, and the
+encryption defaults to ` + "`aws:kms`" + `.
 
 ### Credentials
 
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSFTP] = TypeSpec{
+		constructor: NewSFTP,
+		description: `
+Writes messages as files to an SFTP server. The ` + "`path`" + ` of each file
+supports
+[function interpolation](../config_interpolation.md#functions), and directories
+of the path that do not exist are created.
+
+Files are first uploaded to a hidden temporary file within the target
+directory, which is renamed to the target path once complete. Consumers of the
+directory therefore never see partially written files. Existing files at the
+target path are replaced, which is atomic when the server supports the
+` + "`posix-rename@openssh.com`" + ` extension (such as OpenSSH).
+
+The server can be authenticated with either a ` + "`password`" + `, a
+` + "`private_key_file`" + ` or both. When ` + "`known_hosts_file`" + ` is set
+the host key of the server is verified against it, otherwise it is not verified.
+
+### Codecs
+
+With the ` + "`all-bytes`" + ` codec each message of a batch is written to its
+own file, and messages that fail are retried individually. With the
+` + "`lines`" + ` codec all messages of a batch are written to a single file
+with each message followed by a newline, and the path is resolved from the
+first message of the batch. The codec ` + "`delim:x`" + ` is the same as
+` + "`lines`" + ` but with a custom delimiter ` + "`x`" + `.
+
+In order to write batches as single files use the ` + "`batch`" + ` processor:
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - batch:
+      count: 1000
+      period: 1m
+output:
+  sftp:
+    address: partner.example.com:22
+    path: /incoming/orders-${!timestamp_unix}.jsonl
+    codec: lines
+    credentials:
+      username: benthos
+      private_key_file: ./id_rsa
+` + "```" + ``,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSFTP creates a new SFTP output type.
+func NewSFTP(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	s, err := writer.NewSFTP(conf.SFTP, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter("sftp", s, log, stats)
+}
+
+//------------------------------------------------------------------------------
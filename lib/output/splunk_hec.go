@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSplunkHEC] = TypeSpec{
+		constructor: NewSplunkHEC,
+		description: `
+Sends messages to a Splunk HTTP Event Collector, authenticated with the
+` + "`token`" + ` of the collector. The ` + "`url`" + ` is the base URL of the
+collector, such as ` + "`https://splunk.example.com:8088`" + `.
+
+When ` + "`endpoint`" + ` is ` + "`event`" + ` each message is sent as the
+` + "`event`" + ` of a JSON event, where messages that are valid JSON are
+embedded as they are and other messages are embedded as strings. A batch of
+messages is sent as a single request.
+
+When ` + "`endpoint`" + ` is ` + "`raw`" + ` the contents of messages are sent
+as they are, and a batch is sent as a single newline delimited request for each
+distinct combination of ` + "`index`" + `, ` + "`source`" + `,
+` + "`sourcetype`" + ` and ` + "`host`" + `.
+
+The fields ` + "`index`" + `, ` + "`source`" + `, ` + "`sourcetype`" + ` and
+` + "`host`" + ` support
+[function interpolations](../config_interpolation.md#functions), which are
+resolved individually for each message of a batch. When empty the defaults of
+the token are used.
+
+Requests are compressed when ` + "`gzip`" + ` is enabled.
+
+### Acknowledgements
+
+When the token has indexer acknowledgement enabled a successful request only
+means that the collector has received the events. Enabling
+` + "`acknowledgement.enabled`" + ` causes the output to poll the
+acknowledgement status of each request every
+` + "`acknowledgement.poll_interval`" + ` until the events have been indexed.
+Messages that are not acknowledged within ` + "`acknowledgement.timeout`" + `
+are sent again, which provides at-least-once delivery guarantees at the cost of
+potential duplicates.
+
+Acknowledgements are tracked per channel, which is a GUID that identifies the
+client. When ` + "`acknowledgement.channel`" + ` is empty a random channel is
+generated each time the output is created.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSplunkHEC creates a new SplunkHEC output type.
+func NewSplunkHEC(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	s, err := writer.NewSplunkHEC(conf.SplunkHEC, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter("splunk_hec", s, log, stats)
+}
+
+//------------------------------------------------------------------------------
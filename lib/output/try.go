@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/lib/broker"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// ErrTryNoOutputs is returned when creating a Try type with zero outputs.
+var ErrTryNoOutputs = errors.New("attempting to create try output with no outputs")
+
+func init() {
+	Constructors[TypeTry] = TypeSpec{
+		brokerConstructor: NewTry,
+		description: `
+Attempts to send each message to a child output, starting from the first output
+on the list. If an output attempt fails then the messages are sent to the next
+output in the list and so on, and an error is only returned once all outputs
+have failed.
+
+When an output reports which messages of a batch failed (such as the
+` + "`dynamodb`" + ` output) only the failed messages are sent to the next
+output, otherwise the whole batch is. This makes the try output useful for
+routing messages to a dead letter queue when a target is unavailable, e.g.
+falling back to S3 storage when writes to Kafka fail:
+
+` + "``` yaml" + `
+output:
+  try:
+  - kafka:
+      addresses: [ localhost:9092 ]
+      topic: foo
+      max_retries: 3
+  - s3:
+      bucket: dead-letter-queue
+      path: ${!metadata:kafka_key}-${!timestamp_unix_nano}.json
+` + "```" + `
+
+Outputs that retry failed messages indefinitely (such as the
+` + "`retry`" + ` output) never fall back to the next output, and should
+therefore only be placed last in the list.
+
+This output is equivalent to the ` + "[`broker`](#broker)" + ` output with the
+pattern ` + "`try`" + `.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			outSlice := []interface{}{}
+			for _, output := range conf.Try {
+				sanOutput, err := SanitiseConfig(output)
+				if err != nil {
+					return nil, err
+				}
+				outSlice = append(outSlice, sanOutput)
+			}
+			return outSlice, nil
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// TryConfig contains configuration fields for the Try output type.
+type TryConfig []Config
+
+// NewTryConfig creates a new TryConfig with default values.
+func NewTryConfig() TryConfig {
+	return TryConfig{}
+}
+
+// UnmarshalJSON ensures that when parsing child config objects the default
+// values are still applied.
+func (t *TryConfig) UnmarshalJSON(bytes []byte) error {
+	var outputs brokerOutputList
+	if err := outputs.UnmarshalJSON(bytes); err != nil {
+		return err
+	}
+	*t = TryConfig(outputs)
+	return nil
+}
+
+// UnmarshalYAML ensures that when parsing child config objects the default
+// values are still applied.
+func (t *TryConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var outputs brokerOutputList
+	if err := outputs.UnmarshalYAML(unmarshal); err != nil {
+		return err
+	}
+	*t = TryConfig(outputs)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// NewTry creates a new Try output type. Messages are sent to the first child
+// output and are only sent to the next output when the prior fails.
+func NewTry(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+	pipelines ...types.PipelineConstructorFunc,
+) (Type, error) {
+	if len(conf.Try) == 0 {
+		return nil, ErrTryNoOutputs
+	}
+
+	outputs := make([]types.Output, len(conf.Try))
+
+	var err error
+	for i, oConf := range conf.Try {
+		ns := fmt.Sprintf("try.outputs.%v", i)
+		if outputs[i], err = New(
+			oConf, mgr,
+			log.NewModule("."+ns),
+			metrics.Combine(stats, metrics.Namespaced(stats, ns)),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create output '%v' type '%v': %v", i, oConf.Type, err)
+		}
+	}
+
+	var t Type
+	if t, err = broker.NewTry(outputs, stats); err != nil {
+		return nil, err
+	}
+	return WrapWithPipelines(t, pipelines...)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,141 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestTryConfigDefaults(t *testing.T) {
+	confStr := `
+try:
+- http_client:
+    url: http://localhost:1234
+- files:
+    path: ./foo
+`
+
+	conf := NewConfig()
+	if err := yaml.Unmarshal([]byte(confStr), &conf); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := TypeTry, conf.Type; exp != act {
+		t.Errorf("Wrong type: %v != %v", act, exp)
+	}
+	if exp, act := 2, len(conf.Try); exp != act {
+		t.Fatalf("Wrong count of outputs: %v != %v", act, exp)
+	}
+	if exp, act := "POST", conf.Try[0].HTTPClient.Verb; exp != act {
+		t.Errorf("Wrong default verb: %v != %v", act, exp)
+	}
+	if exp, act := "./foo", conf.Try[1].Files.Path; exp != act {
+		t.Errorf("Wrong path: %v != %v", act, exp)
+	}
+}
+
+func TestTryNoOutputs(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeTry
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err != ErrTryNoOutputs {
+		t.Errorf("Wrong error: %v != %v", err, ErrTryNoOutputs)
+	}
+}
+
+func TestTryFallback(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "benthos_try_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	httpConf := NewConfig()
+	httpConf.Type = TypeHTTPClient
+	httpConf.HTTPClient.URL = ts.URL
+	httpConf.HTTPClient.NumRetries = 0
+
+	filesConf := NewConfig()
+	filesConf.Type = TypeFiles
+	filesConf.Files.Path = filepath.Join(dir, "${!content}.txt")
+
+	conf := NewConfig()
+	conf.Type = TypeTry
+	conf.Try = append(conf.Try, httpConf, filesConf)
+
+	o, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sendChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = o.Consume(sendChan); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case sendChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+	case <-time.After(time.Second * 5):
+		t.Fatal("Action timed out")
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Action timed out")
+	}
+
+	if requests == 0 {
+		t.Error("Expected request to http server")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "foo.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "foo", string(data); exp != act {
+		t.Errorf("Wrong file contents: %v != %v", act, exp)
+	}
+
+	o.CloseAsync()
+	if err = o.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
@@ -95,6 +95,7 @@ func (w *Writer) loop() {
 		mSent       = w.stats.GetCounter("batch.sent")
 		mBytesSent  = w.stats.GetCounter("batch.bytes")
 		mLatency    = w.stats.GetTimer("batch.latency")
+		mInFlight   = w.stats.GetGauge("batch.in_flight")
 		mConn       = w.stats.GetCounter("connection.up")
 		mFailedConn = w.stats.GetCounter("connection.failed")
 		mLostConn   = w.stats.GetCounter("connection.lost")
@@ -142,6 +143,7 @@ func (w *Writer) loop() {
 			return
 		}
 
+		mInFlight.Set(1)
 		spans := tracing.CreateChildSpans("output_"+w.typeStr, ts.Payload)
 		latency, err := w.latencyMeasuringWrite(ts.Payload)
 
@@ -172,6 +174,7 @@ func (w *Writer) loop() {
 				}
 			}
 		}
+		mInFlight.Set(0)
 
 		// Close immediately if our writer is closed.
 		if err == types.ErrTypeClosed {
@@ -0,0 +1,225 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/azure/storage"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+// AzureBlobStorageConfig contains configuration fields for the
+// AzureBlobStorage output type.
+type AzureBlobStorageConfig struct {
+	storage.Config  `json:",inline" yaml:",inline"`
+	Container       string `json:"container" yaml:"container"`
+	Path            string `json:"path" yaml:"path"`
+	BlobType        string `json:"blob_type" yaml:"blob_type"`
+	ContentType     string `json:"content_type" yaml:"content_type"`
+	ContentEncoding string `json:"content_encoding" yaml:"content_encoding"`
+	Timeout         string `json:"timeout" yaml:"timeout"`
+}
+
+// NewAzureBlobStorageConfig creates a new AzureBlobStorageConfig with default
+// values.
+func NewAzureBlobStorageConfig() AzureBlobStorageConfig {
+	return AzureBlobStorageConfig{
+		Config:          storage.NewConfig(),
+		Container:       "",
+		Path:            "${!count:files}-${!timestamp_unix_nano}.txt",
+		BlobType:        "BLOCK",
+		ContentType:     "application/octet-stream",
+		ContentEncoding: "",
+		Timeout:         "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// azureMetadataKey converts a metadata key into a valid blob metadata name,
+// which must be a valid C# identifier, by replacing invalid characters with
+// underscores.
+func azureMetadataKey(k string) string {
+	b := []byte(k)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+//------------------------------------------------------------------------------
+
+// AzureBlobStorage is a benthos writer.Type implementation that writes
+// messages to blobs within an Azure Blob Storage container.
+type AzureBlobStorage struct {
+	conf    AzureBlobStorageConfig
+	client  *storage.Client
+	timeout time.Duration
+	append  bool
+
+	container       *text.InterpolatedString
+	path            *text.InterpolatedString
+	contentType     *text.InterpolatedString
+	contentEncoding *text.InterpolatedString
+
+	connMut   sync.RWMutex
+	connected bool
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewAzureBlobStorage creates a new Azure Blob Storage writer.Type.
+func NewAzureBlobStorage(
+	conf AzureBlobStorageConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*AzureBlobStorage, error) {
+	if len(conf.Container) == 0 {
+		return nil, errors.New("a container must be specified")
+	}
+	a := &AzureBlobStorage{
+		conf:            conf,
+		container:       text.NewInterpolatedString(conf.Container),
+		path:            text.NewInterpolatedString(conf.Path),
+		contentType:     text.NewInterpolatedString(conf.ContentType),
+		contentEncoding: text.NewInterpolatedString(conf.ContentEncoding),
+		log:             log,
+		stats:           stats,
+	}
+
+	switch strings.ToUpper(conf.BlobType) {
+	case "BLOCK":
+	case "APPEND":
+		a.append = true
+	default:
+		return nil, fmt.Errorf("blob type not recognised: %v", conf.BlobType)
+	}
+
+	var err error
+	if a.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if a.client, err = conf.Config.NewClient(a.timeout); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Connect attempts to establish a connection to the target container.
+func (a *AzureBlobStorage) Connect() error {
+	a.connMut.Lock()
+	defer a.connMut.Unlock()
+
+	if !a.connected {
+		a.connected = true
+		a.log.Infof("Uploading message parts as blobs to Azure Blob Storage container: %v\n", a.conf.Container)
+	}
+	return nil
+}
+
+// writeBlob writes the contents of a message to a blob, where append blobs are
+// created when they do not yet exist.
+func (a *AzureBlobStorage) writeBlob(ctx context.Context, container, path string, props storage.BlobProperties, data []byte) error {
+	if !a.append {
+		return a.client.PutBlockBlob(ctx, container, path, props, data)
+	}
+	err := a.client.AppendBlob(ctx, container, path, data)
+	if storage.IsNotFound(err) {
+		if err = a.client.CreateAppendBlob(ctx, container, path, props); err == nil {
+			err = a.client.AppendBlob(ctx, container, path, data)
+		}
+	}
+	return err
+}
+
+// Write attempts to write message contents to the target container, where each
+// message of a batch is written individually and failed messages are reported
+// with a batch.Error.
+func (a *AzureBlobStorage) Write(msg types.Message) error {
+	a.connMut.RLock()
+	connected := a.connected
+	a.connMut.RUnlock()
+	if !connected {
+		return types.ErrNotConnected
+	}
+
+	var batchErr *batch.Error
+	msg.Iter(func(i int, p types.Part) error {
+		lMsg := message.Lock(msg, i)
+
+		props := storage.BlobProperties{
+			ContentType:     a.contentType.Get(lMsg),
+			ContentEncoding: a.contentEncoding.Get(lMsg),
+			Metadata:        map[string]string{},
+		}
+		p.Metadata().Iter(func(k, v string) error {
+			props.Metadata[azureMetadataKey(k)] = v
+			return nil
+		})
+
+		ctx, done := context.WithTimeout(context.Background(), a.timeout)
+		err := a.writeBlob(ctx, a.container.Get(lMsg), a.path.Get(lMsg), props, p.Get())
+		done()
+		if err != nil {
+			if batchErr == nil {
+				batchErr = batch.NewError(msg, err)
+			}
+			batchErr.Failed(i, err)
+		}
+		return nil
+	})
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (a *AzureBlobStorage) CloseAsync() {
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (a *AzureBlobStorage) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
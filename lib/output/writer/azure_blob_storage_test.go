@@ -0,0 +1,198 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type testBlobServer struct {
+	t        *testing.T
+	mut      sync.Mutex
+	blobs    map[string]string
+	types    map[string]string
+	metadata map[string]string
+}
+
+func newTestBlobServer(t *testing.T) *testBlobServer {
+	return &testBlobServer{
+		t:        t,
+		blobs:    map[string]string{},
+		types:    map[string]string{},
+		metadata: map[string]string{},
+	}
+}
+
+func (s *testBlobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if r.Method != http.MethodPut {
+		s.t.Errorf("Unexpected method: %v", r.Method)
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if string(body) == "fail" {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("comp") == "appendblock" {
+		if _, exists := s.blobs[r.URL.Path]; !exists {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.blobs[r.URL.Path] += string(body)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	s.blobs[r.URL.Path] = string(body)
+	s.types[r.URL.Path] = r.Header.Get("x-ms-blob-type") + ";" + r.Header.Get("x-ms-blob-content-type")
+	for k, v := range r.Header {
+		if k == "X-Ms-Meta-Foo_bar" {
+			s.metadata[r.URL.Path] = v[0]
+		}
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func TestAzureBlobStorageBlock(t *testing.T) {
+	s := newTestBlobServer(t)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	conf := NewAzureBlobStorageConfig()
+	conf.StorageConnectionString = "AccountName=foo;AccountKey=" + base64.StdEncoding.EncodeToString([]byte("bar")) + ";BlobEndpoint=" + server.URL
+	conf.Container = "${!metadata:container}"
+	conf.Path = "${!metadata:name}.txt"
+	conf.ContentType = "text/plain"
+
+	w, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New(nil)); err == nil {
+		t.Error("Expected error when not connected")
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("fail"), []byte("bar")})
+	for i, name := range []string{"a", "b", "c"} {
+		msg.Get(i).Metadata().Set("container", "landing").Set("name", name)
+	}
+	msg.Get(0).Metadata().Set("foo-bar", "baz")
+
+	err = w.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, received: %v", err)
+	}
+	if exp, act := 1, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of failed messages: %v != %v", act, exp)
+	}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if (i == 1) != (err != nil) {
+			t.Errorf("Unexpected error for message %v: %v", i, err)
+		}
+		return true
+	})
+
+	if exp, act := map[string]string{
+		"/landing/a.txt": "foo",
+		"/landing/c.txt": "bar",
+	}, s.blobs; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong blobs: %v != %v", act, exp)
+	}
+	if exp, act := "BlockBlob;text/plain", s.types["/landing/a.txt"]; exp != act {
+		t.Errorf("Wrong blob type: %v != %v", act, exp)
+	}
+	if exp, act := map[string]string{"/landing/a.txt": "baz"}, s.metadata; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+}
+
+func TestAzureBlobStorageAppend(t *testing.T) {
+	s := newTestBlobServer(t)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	conf := NewAzureBlobStorageConfig()
+	conf.StorageConnectionString = "AccountName=foo;AccountKey=" + base64.StdEncoding.EncodeToString([]byte("bar")) + ";BlobEndpoint=" + server.URL
+	conf.Container = "landing"
+	conf.Path = "log.txt"
+	conf.BlobType = "APPEND"
+
+	w, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Write(message.New([][]byte{[]byte("foo\n"), []byte("bar\n")})); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New([][]byte{[]byte("baz\n")})); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := map[string]string{"/landing/log.txt": "foo\nbar\nbaz\n"}, s.blobs; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong blobs: %v != %v", act, exp)
+	}
+	if exp, act := "AppendBlob;application/octet-stream", s.types["/landing/log.txt"]; exp != act {
+		t.Errorf("Wrong blob type: %v != %v", act, exp)
+	}
+}
+
+func TestAzureBlobStorageConfigErrors(t *testing.T) {
+	conf := NewAzureBlobStorageConfig()
+	conf.StorageAccount = "foo"
+	conf.StorageAccessKey = "YmFy"
+	if _, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing container")
+	}
+
+	conf.Container = "foo"
+	conf.BlobType = "PAGE"
+	if _, err := NewAzureBlobStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad blob type")
+	}
+}
+
+//------------------------------------------------------------------------------
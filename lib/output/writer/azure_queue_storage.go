@@ -0,0 +1,219 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/azure/storage"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+// AzureQueueStorageConfig contains configuration fields for the
+// AzureQueueStorage output type.
+type AzureQueueStorageConfig struct {
+	storage.Config       `json:",inline" yaml:",inline"`
+	QueueName            string `json:"queue_name" yaml:"queue_name"`
+	TTL                  string `json:"ttl" yaml:"ttl"`
+	ScheduledEnqueueTime string `json:"scheduled_enqueue_time" yaml:"scheduled_enqueue_time"`
+	Timeout              string `json:"timeout" yaml:"timeout"`
+}
+
+// NewAzureQueueStorageConfig creates a new AzureQueueStorageConfig with default
+// values.
+func NewAzureQueueStorageConfig() AzureQueueStorageConfig {
+	return AzureQueueStorageConfig{
+		Config:               storage.NewConfig(),
+		QueueName:            "",
+		TTL:                  "",
+		ScheduledEnqueueTime: "",
+		Timeout:              "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// azureQueueMaxVisibilityTimeout is the longest that a message added to a
+// storage queue can remain invisible.
+const azureQueueMaxVisibilityTimeout = time.Hour * 24 * 7
+
+// parseEnqueueTime parses a scheduled enqueue time, which is either an RFC3339
+// timestamp or a duration relative to now. An empty string results in a zero
+// time.
+func parseEnqueueTime(s string, now time.Time) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC3339 timestamp or duration: %v", err)
+	}
+	return t, nil
+}
+
+//------------------------------------------------------------------------------
+
+// AzureQueueStorage is a benthos writer.Type implementation that writes
+// messages to an Azure Storage queue.
+type AzureQueueStorage struct {
+	conf    AzureQueueStorageConfig
+	client  *storage.Client
+	timeout time.Duration
+	ttl     int
+
+	queueName   *text.InterpolatedString
+	enqueueTime *text.InterpolatedString
+
+	connMut   sync.RWMutex
+	connected bool
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewAzureQueueStorage creates a new Azure Storage queue writer.Type.
+func NewAzureQueueStorage(
+	conf AzureQueueStorageConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*AzureQueueStorage, error) {
+	if len(conf.QueueName) == 0 {
+		return nil, errors.New("a queue name must be specified")
+	}
+	a := &AzureQueueStorage{
+		conf:        conf,
+		queueName:   text.NewInterpolatedString(conf.QueueName),
+		enqueueTime: text.NewInterpolatedString(conf.ScheduledEnqueueTime),
+		log:         log,
+		stats:       stats,
+	}
+
+	var err error
+	if a.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if len(conf.TTL) > 0 {
+		ttl, err := time.ParseDuration(conf.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ttl: %v", err)
+		}
+		if ttl < 0 {
+			a.ttl = -1
+		} else {
+			a.ttl = int(math.Ceil(ttl.Seconds()))
+		}
+	}
+	if a.client, err = conf.Config.NewClient(a.timeout); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Connect attempts to establish a connection to the target queue.
+func (a *AzureQueueStorage) Connect() error {
+	a.connMut.Lock()
+	defer a.connMut.Unlock()
+
+	if !a.connected {
+		a.connected = true
+		a.log.Infof("Sending messages to Azure Storage queue: %v\n", a.conf.QueueName)
+	}
+	return nil
+}
+
+// Write attempts to write message contents to the target queue, where each
+// message of a batch is added individually and failed messages are reported
+// with a batch.Error.
+func (a *AzureQueueStorage) Write(msg types.Message) error {
+	a.connMut.RLock()
+	connected := a.connected
+	a.connMut.RUnlock()
+	if !connected {
+		return types.ErrNotConnected
+	}
+
+	var batchErr *batch.Error
+	msg.Iter(func(i int, p types.Part) error {
+		lMsg := message.Lock(msg, i)
+
+		var visibility int
+		enqueueAt, err := parseEnqueueTime(a.enqueueTime.Get(lMsg), time.Now())
+		if err != nil {
+			a.log.Errorf("Failed to parse scheduled enqueue time: %v\n", err)
+		} else if !enqueueAt.IsZero() {
+			delay := time.Until(enqueueAt)
+			if delay > azureQueueMaxVisibilityTimeout {
+				delay = azureQueueMaxVisibilityTimeout
+			}
+			if delay > 0 {
+				visibility = int(math.Ceil(delay.Seconds()))
+			}
+		}
+
+		ctx, done := context.WithTimeout(context.Background(), a.timeout)
+		err = a.client.PutMessage(
+			ctx, a.queueName.Get(lMsg),
+			base64.StdEncoding.EncodeToString(p.Get()),
+			visibility, a.ttl,
+		)
+		done()
+		if err != nil {
+			if batchErr == nil {
+				batchErr = batch.NewError(msg, err)
+			}
+			batchErr.Failed(i, err)
+		}
+		return nil
+	})
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (a *AzureQueueStorage) CloseAsync() {
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (a *AzureQueueStorage) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,174 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestParseEnqueueTime(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"":                     {},
+		"30s":                  now.Add(time.Second * 30),
+		"-1m":                  now.Add(-time.Minute),
+		"2020-01-02T00:00:00Z": now.Add(time.Hour * 24),
+	}
+	for input, exp := range tests {
+		act, err := parseEnqueueTime(input, now)
+		if err != nil {
+			t.Errorf("Unexpected error from '%v': %v", input, err)
+		}
+		if !exp.Equal(act) {
+			t.Errorf("Wrong result from '%v': %v != %v", input, act, exp)
+		}
+	}
+
+	if _, err := parseEnqueueTime("nope", now); err == nil {
+		t.Error("Expected error from bad value")
+	}
+}
+
+func TestAzureQueueStorageWrite(t *testing.T) {
+	type putReq struct {
+		path       string
+		visibility string
+		ttl        string
+		text       string
+	}
+
+	var reqMut sync.Mutex
+	var reqs []putReq
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var qMsg struct {
+			MessageText string `xml:"MessageText"`
+		}
+		if err := xml.Unmarshal(body, &qMsg); err != nil {
+			t.Error(err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(qMsg.MessageText)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(decoded) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		reqMut.Lock()
+		reqs = append(reqs, putReq{
+			path:       r.URL.Path,
+			visibility: r.URL.Query().Get("visibilitytimeout"),
+			ttl:        r.URL.Query().Get("messagettl"),
+			text:       string(decoded),
+		})
+		reqMut.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	conf := NewAzureQueueStorageConfig()
+	conf.StorageConnectionString = "AccountName=foo;AccountKey=" + base64.StdEncoding.EncodeToString([]byte("bar")) + ";QueueEndpoint=" + server.URL
+	conf.QueueName = "${!metadata:queue}"
+	conf.ScheduledEnqueueTime = "${!metadata:delay}"
+	conf.TTL = "-1s"
+
+	w, err := NewAzureQueueStorage(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New(nil)); err == nil {
+		t.Error("Expected error when not connected")
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("fail"), []byte("bar")})
+	msg.Get(0).Metadata().Set("queue", "first")
+	msg.Get(1).Metadata().Set("queue", "first")
+	msg.Get(2).Metadata().Set("queue", "second")
+	msg.Get(2).Metadata().Set("delay", "90s")
+
+	err = w.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, received: %v", err)
+	}
+	if exp, act := 1, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of failed messages: %v != %v", act, exp)
+	}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if (i == 1) != (err != nil) {
+			t.Errorf("Unexpected error for message %v: %v", i, err)
+		}
+		return true
+	})
+
+	if exp, act := 2, len(reqs); exp != act {
+		t.Fatalf("Wrong count of requests: %v != %v", act, exp)
+	}
+	if exp, act := (putReq{path: "/first/messages", ttl: "-1", text: "foo"}), reqs[0]; exp != act {
+		t.Errorf("Wrong request: %+v != %+v", act, exp)
+	}
+	if exp, act := "/second/messages", reqs[1].path; exp != act {
+		t.Errorf("Wrong path: %v != %v", act, exp)
+	}
+	if act := reqs[1].visibility; act != "90" && act != "89" {
+		t.Errorf("Wrong visibility timeout: %v", act)
+	}
+	if exp, act := "bar", reqs[1].text; exp != act {
+		t.Errorf("Wrong text: %v != %v", act, exp)
+	}
+}
+
+func TestAzureQueueStorageConfigErrors(t *testing.T) {
+	conf := NewAzureQueueStorageConfig()
+	conf.StorageAccount = "foo"
+	conf.StorageAccessKey = "YmFy"
+	if _, err := NewAzureQueueStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing queue name")
+	}
+
+	conf.QueueName = "foo"
+	conf.TTL = "nope"
+	if _, err := NewAzureQueueStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad ttl")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,208 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/azure/servicebus"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+)
+
+//------------------------------------------------------------------------------
+
+// AzureServiceBusConfig contains configuration fields for the AzureServiceBus
+// output type.
+type AzureServiceBusConfig struct {
+	servicebus.Config    `json:",inline" yaml:",inline"`
+	Entity               string `json:"entity" yaml:"entity"`
+	SessionID            string `json:"session_id" yaml:"session_id"`
+	MessageID            string `json:"message_id" yaml:"message_id"`
+	ContentType          string `json:"content_type" yaml:"content_type"`
+	ScheduledEnqueueTime string `json:"scheduled_enqueue_time" yaml:"scheduled_enqueue_time"`
+	TTL                  string `json:"ttl" yaml:"ttl"`
+	Timeout              string `json:"timeout" yaml:"timeout"`
+}
+
+// NewAzureServiceBusConfig creates a new AzureServiceBusConfig with default
+// values.
+func NewAzureServiceBusConfig() AzureServiceBusConfig {
+	return AzureServiceBusConfig{
+		Config:               servicebus.NewConfig(),
+		Entity:               "",
+		SessionID:            "",
+		MessageID:            "",
+		ContentType:          "",
+		ScheduledEnqueueTime: "",
+		TTL:                  "",
+		Timeout:              "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// AzureServiceBus is a benthos writer.Type implementation that writes messages
+// to an Azure Service Bus queue or topic.
+type AzureServiceBus struct {
+	conf    AzureServiceBusConfig
+	client  *servicebus.Client
+	entity  *text.InterpolatedString
+	timeout time.Duration
+	ttl     time.Duration
+
+	sessionID   *text.InterpolatedString
+	messageID   *text.InterpolatedString
+	contentType *text.InterpolatedString
+	enqueueTime *text.InterpolatedString
+
+	connMut   sync.RWMutex
+	connected bool
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewAzureServiceBus creates a new Azure Service Bus writer.Type.
+func NewAzureServiceBus(
+	conf AzureServiceBusConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*AzureServiceBus, error) {
+	a := &AzureServiceBus{
+		conf:        conf,
+		sessionID:   text.NewInterpolatedString(conf.SessionID),
+		messageID:   text.NewInterpolatedString(conf.MessageID),
+		contentType: text.NewInterpolatedString(conf.ContentType),
+		enqueueTime: text.NewInterpolatedString(conf.ScheduledEnqueueTime),
+		log:         log,
+		stats:       stats,
+	}
+
+	var err error
+	if a.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if len(conf.TTL) > 0 {
+		if a.ttl, err = time.ParseDuration(conf.TTL); err != nil {
+			return nil, fmt.Errorf("failed to parse ttl: %v", err)
+		}
+	}
+	if a.client, err = conf.Config.NewClient(a.timeout); err != nil {
+		return nil, err
+	}
+
+	entity := conf.Entity
+	if len(entity) == 0 {
+		entity = a.client.EntityPath()
+	}
+	if len(entity) == 0 {
+		return nil, errors.New("an entity must be specified either explicitly or within the connection string")
+	}
+	a.entity = text.NewInterpolatedString(entity)
+	return a, nil
+}
+
+// Connect attempts to establish a connection to the target entity.
+func (a *AzureServiceBus) Connect() error {
+	a.connMut.Lock()
+	defer a.connMut.Unlock()
+
+	if !a.connected {
+		a.connected = true
+		a.log.Infof("Sending messages to Azure Service Bus entity: %v\n", a.entity.Get(message.New(nil)))
+	}
+	return nil
+}
+
+// Write attempts to write message contents to the target entity. Messages of a
+// batch that resolve to the same entity are sent within a single request.
+func (a *AzureServiceBus) Write(msg types.Message) error {
+	a.connMut.RLock()
+	connected := a.connected
+	a.connMut.RUnlock()
+	if !connected {
+		return types.ErrNotConnected
+	}
+
+	var entities []string
+	batches := map[string][]servicebus.Message{}
+
+	now := time.Now()
+	msg.Iter(func(i int, p types.Part) error {
+		lMsg := message.Lock(msg, i)
+
+		sMsg := servicebus.Message{
+			Body:        p.Get(),
+			SessionID:   a.sessionID.Get(lMsg),
+			MessageID:   a.messageID.Get(lMsg),
+			ContentType: a.contentType.Get(lMsg),
+			TimeToLive:  a.ttl,
+			Properties:  map[string]string{},
+		}
+		enqueueAt, err := parseEnqueueTime(a.enqueueTime.Get(lMsg), now)
+		if err != nil {
+			a.log.Errorf("Failed to parse scheduled enqueue time: %v\n", err)
+		} else {
+			sMsg.ScheduledEnqueueTime = enqueueAt
+		}
+		p.Metadata().Iter(func(k, v string) error {
+			sMsg.Properties[k] = v
+			return nil
+		})
+
+		entity := a.entity.Get(lMsg)
+		if _, exists := batches[entity]; !exists {
+			entities = append(entities, entity)
+		}
+		batches[entity] = append(batches[entity], sMsg)
+		return nil
+	})
+
+	for _, entity := range entities {
+		ctx, done := context.WithTimeout(context.Background(), a.timeout)
+		err := a.client.Send(ctx, entity, batches[entity])
+		done()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (a *AzureServiceBus) CloseAsync() {
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (a *AzureServiceBus) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func TestAzureServiceBusWrite(t *testing.T) {
+	type sbMsg struct {
+		Body             string
+		BrokerProperties map[string]interface{}
+		UserProperties   map[string]string
+	}
+
+	var reqMut sync.Mutex
+	reqs := map[string][]sbMsg{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var msgs []sbMsg
+		if err := json.Unmarshal(body, &msgs); err != nil {
+			t.Error(err)
+		}
+		reqMut.Lock()
+		reqs[r.URL.Path] = append(reqs[r.URL.Path], msgs...)
+		reqMut.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	conf := NewAzureServiceBusConfig()
+	conf.ConnectionString = "Endpoint=sb://foo.servicebus.windows.net/;SharedAccessKeyName=RootManageSharedAccessKey;SharedAccessKey=bar;EntityPath=baz"
+	conf.SessionID = "${!json_field:customer}"
+	conf.ScheduledEnqueueTime = "${!metadata:enqueue}"
+
+	w, err := NewAzureServiceBus(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	w.client.SetEndpoint(u)
+
+	if err = w.Write(message.New(nil)); err == nil {
+		t.Error("Expected error when not connected")
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"customer":"a"}`),
+		[]byte(`{"customer":"b"}`),
+	})
+	msg.Get(0).Metadata().Set("foo", "bar")
+	msg.Get(1).Metadata().Set("enqueue", "2020-01-01T00:00:00Z")
+	if err = w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string][]sbMsg{
+		"/baz/messages": {
+			{
+				Body:             `{"customer":"a"}`,
+				BrokerProperties: map[string]interface{}{"SessionId": "a"},
+				UserProperties:   map[string]string{"foo": "bar"},
+			},
+			{
+				Body: `{"customer":"b"}`,
+				BrokerProperties: map[string]interface{}{
+					"SessionId":               "b",
+					"ScheduledEnqueueTimeUtc": "Wed, 01 Jan 2020 00:00:00 GMT",
+				},
+				UserProperties: map[string]string{"enqueue": "2020-01-01T00:00:00Z"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(exp, reqs) {
+		t.Errorf("Wrong requests: %+v != %+v", reqs, exp)
+	}
+}
+
+func TestAzureServiceBusConfigErrors(t *testing.T) {
+	conf := NewAzureServiceBusConfig()
+	conf.ConnectionString = "Endpoint=sb://foo.servicebus.windows.net/;SharedAccessKeyName=RootManageSharedAccessKey;SharedAccessKey=bar"
+	if _, err := NewAzureServiceBus(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing entity")
+	}
+
+	conf.Entity = "baz"
+	if _, err := NewAzureServiceBus(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Error(err)
+	}
+
+	conf.TTL = "nope"
+	if _, err := NewAzureServiceBus(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad ttl")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,312 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/gocql/gocql"
+)
+
+//------------------------------------------------------------------------------
+
+// CassandraPasswordAuthenticatorConfig contains configuration fields for
+// authenticating with a Cassandra cluster using a username and password.
+type CassandraPasswordAuthenticatorConfig struct {
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+}
+
+// CassandraBackoffConfig contains configuration fields for the backoff applied
+// between retries of a failed query.
+type CassandraBackoffConfig struct {
+	InitialInterval string `json:"initial_interval" yaml:"initial_interval"`
+	MaxInterval     string `json:"max_interval" yaml:"max_interval"`
+}
+
+// CassandraConfig contains configuration fields for the Cassandra output type.
+type CassandraConfig struct {
+	Addresses                []string                             `json:"addresses" yaml:"addresses"`
+	TLS                      btls.Config                          `json:"tls" yaml:"tls"`
+	PasswordAuthenticator    CassandraPasswordAuthenticatorConfig `json:"password_authenticator" yaml:"password_authenticator"`
+	DisableInitialHostLookup bool                                 `json:"disable_initial_host_lookup" yaml:"disable_initial_host_lookup"`
+	TokenAware               bool                                 `json:"token_aware" yaml:"token_aware"`
+	Query                    string                               `json:"query" yaml:"query"`
+	Args                     []string                             `json:"args" yaml:"args"`
+	Consistency              string                               `json:"consistency" yaml:"consistency"`
+	BatchType                string                               `json:"batch_type" yaml:"batch_type"`
+	MaxRetries               int                                  `json:"max_retries" yaml:"max_retries"`
+	Backoff                  CassandraBackoffConfig               `json:"backoff" yaml:"backoff"`
+	Timeout                  string                               `json:"timeout" yaml:"timeout"`
+}
+
+// NewCassandraConfig creates a new CassandraConfig with default values.
+func NewCassandraConfig() CassandraConfig {
+	return CassandraConfig{
+		Addresses: []string{},
+		TLS:       btls.NewConfig(),
+		PasswordAuthenticator: CassandraPasswordAuthenticatorConfig{
+			Enabled:  false,
+			Username: "",
+			Password: "",
+		},
+		DisableInitialHostLookup: false,
+		TokenAware:               true,
+		Query:                    "",
+		Args:                     []string{},
+		Consistency:              gocql.Quorum.String(),
+		BatchType:                "LOGGED",
+		MaxRetries:               3,
+		Backoff: CassandraBackoffConfig{
+			InitialInterval: "1s",
+			MaxInterval:     "5s",
+		},
+		Timeout: "600ms",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// cassandraArg is a query argument resolved from an interpolated string, which
+// is converted into the type of the column it is bound to.
+type cassandraArg string
+
+// MarshalCQL converts the argument into the type of the column it is bound to,
+// where types that gocql cannot marshal from a string are parsed first.
+func (a cassandraArg) MarshalCQL(info gocql.TypeInfo) ([]byte, error) {
+	s := string(a)
+	switch info.Type() {
+	case gocql.TypeBoolean:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Marshal(info, b)
+	case gocql.TypeFloat:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Marshal(info, float32(f))
+	case gocql.TypeDouble:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return gocql.Marshal(info, f)
+	case gocql.TypeTimestamp:
+		if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return gocql.Marshal(info, ms)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("expected an RFC3339 timestamp or unix milliseconds: %v", err)
+		}
+		return gocql.Marshal(info, t)
+	}
+	return gocql.Marshal(info, s)
+}
+
+//------------------------------------------------------------------------------
+
+// Cassandra is a benthos writer.Type implementation that executes a CQL
+// statement for each message of a batch.
+type Cassandra struct {
+	conf  CassandraConfig
+	log   log.Modular
+	stats metrics.Type
+
+	cluster   *gocql.ClusterConfig
+	batchType gocql.BatchType
+	args      []*text.InterpolatedString
+
+	session *gocql.Session
+	connMut sync.RWMutex
+}
+
+// NewCassandra creates a new Cassandra writer.Type.
+func NewCassandra(
+	conf CassandraConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*Cassandra, error) {
+	if len(conf.Addresses) == 0 {
+		return nil, errors.New("at least one address must be specified")
+	}
+	if len(conf.Query) == 0 {
+		return nil, errors.New("a query must be specified")
+	}
+
+	c := &Cassandra{
+		conf:  conf,
+		log:   log,
+		stats: stats,
+	}
+	for _, v := range conf.Args {
+		c.args = append(c.args, text.NewInterpolatedString(v))
+	}
+
+	switch strings.ToUpper(conf.BatchType) {
+	case "LOGGED":
+		c.batchType = gocql.LoggedBatch
+	case "UNLOGGED":
+		c.batchType = gocql.UnloggedBatch
+	case "COUNTER":
+		c.batchType = gocql.CounterBatch
+	default:
+		return nil, fmt.Errorf("batch type not recognised: %v", conf.BatchType)
+	}
+
+	var addresses []string
+	for _, addr := range conf.Addresses {
+		for _, splitAddr := range strings.Split(addr, ",") {
+			if trimmed := strings.TrimSpace(splitAddr); len(trimmed) > 0 {
+				addresses = append(addresses, trimmed)
+			}
+		}
+	}
+
+	c.cluster = gocql.NewCluster(addresses...)
+	c.cluster.DisableInitialHostLookup = conf.DisableInitialHostLookup
+	if conf.TokenAware {
+		c.cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	}
+
+	var err error
+	if c.cluster.Consistency, err = gocql.ParseConsistencyWrapper(conf.Consistency); err != nil {
+		return nil, fmt.Errorf("failed to parse consistency: %v", err)
+	}
+	if c.cluster.Timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	retryPolicy := &gocql.ExponentialBackoffRetryPolicy{
+		NumRetries: conf.MaxRetries,
+	}
+	if retryPolicy.Min, err = time.ParseDuration(conf.Backoff.InitialInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse backoff initial interval: %v", err)
+	}
+	if retryPolicy.Max, err = time.ParseDuration(conf.Backoff.MaxInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse backoff max interval: %v", err)
+	}
+	c.cluster.RetryPolicy = retryPolicy
+
+	if conf.TLS.Enabled {
+		tlsConf, err := conf.TLS.Get()
+		if err != nil {
+			return nil, err
+		}
+		c.cluster.SslOpts = &gocql.SslOptions{
+			Config:                 tlsConf,
+			EnableHostVerification: !conf.TLS.InsecureSkipVerify,
+		}
+	}
+	if conf.PasswordAuthenticator.Enabled {
+		c.cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: conf.PasswordAuthenticator.Username,
+			Password: conf.PasswordAuthenticator.Password,
+		}
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes a session with the Cassandra cluster.
+func (c *Cassandra) Connect() error {
+	c.connMut.Lock()
+	defer c.connMut.Unlock()
+	if c.session != nil {
+		return nil
+	}
+
+	session, err := c.cluster.CreateSession()
+	if err != nil {
+		return err
+	}
+
+	c.session = session
+	c.log.Infof("Sending messages to Cassandra: %v\n", c.conf.Addresses)
+	return nil
+}
+
+// queryArgs resolves the arguments of the query for a message of a batch.
+func (c *Cassandra) queryArgs(msg types.Message, index int) []interface{} {
+	lMsg := message.Lock(msg, index)
+	args := make([]interface{}, len(c.args))
+	for i, v := range c.args {
+		args[i] = cassandraArg(v.Get(lMsg))
+	}
+	return args
+}
+
+// Write attempts to execute the query for each message of a batch. A single
+// message is executed as a query, whereas larger batches are executed as a
+// single batch statement of the configured type.
+func (c *Cassandra) Write(msg types.Message) error {
+	c.connMut.RLock()
+	session := c.session
+	c.connMut.RUnlock()
+
+	if session == nil {
+		return types.ErrNotConnected
+	}
+
+	if msg.Len() == 1 {
+		return session.Query(c.conf.Query, c.queryArgs(msg, 0)...).Exec()
+	}
+
+	batch := session.NewBatch(c.batchType)
+	msg.Iter(func(i int, p types.Part) error {
+		batch.Query(c.conf.Query, c.queryArgs(msg, i)...)
+		return nil
+	})
+	return session.ExecuteBatch(batch)
+}
+
+// CloseAsync shuts down the Cassandra output and stops processing messages.
+func (c *Cassandra) CloseAsync() {
+	c.connMut.Lock()
+	if c.session != nil {
+		c.session.Close()
+		c.session = nil
+	}
+	c.connMut.Unlock()
+}
+
+// WaitForClose blocks until the Cassandra output has closed down.
+func (c *Cassandra) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,134 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/gocql/gocql"
+)
+
+//------------------------------------------------------------------------------
+
+func TestCassandraArgMarshal(t *testing.T) {
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		typ   gocql.Type
+		input string
+		exp   interface{}
+	}{
+		{typ: gocql.TypeVarchar, input: "foo", exp: "foo"},
+		{typ: gocql.TypeInt, input: "10", exp: 10},
+		{typ: gocql.TypeBigInt, input: "-20", exp: int64(-20)},
+		{typ: gocql.TypeBoolean, input: "true", exp: true},
+		{typ: gocql.TypeFloat, input: "1.5", exp: float32(1.5)},
+		{typ: gocql.TypeDouble, input: "2.5", exp: 2.5},
+		{typ: gocql.TypeTimestamp, input: "2020-01-01T00:00:00Z", exp: ts},
+		{typ: gocql.TypeTimestamp, input: "1577836800000", exp: ts},
+	}
+
+	for _, test := range tests {
+		info := gocql.NewNativeType(4, test.typ, "")
+		exp, err := gocql.Marshal(info, test.exp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act, err := gocql.Marshal(info, cassandraArg(test.input))
+		if err != nil {
+			t.Errorf("Unexpected error from '%v' as %v: %v", test.input, test.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong result from '%v' as %v: %v != %v", test.input, test.typ, act, exp)
+		}
+	}
+
+	for _, typ := range []gocql.Type{gocql.TypeBoolean, gocql.TypeDouble, gocql.TypeTimestamp} {
+		if _, err := gocql.Marshal(gocql.NewNativeType(4, typ, ""), cassandraArg("nope")); err == nil {
+			t.Errorf("Expected error from bad %v", typ)
+		}
+	}
+}
+
+func TestCassandraQueryArgs(t *testing.T) {
+	conf := NewCassandraConfig()
+	conf.Addresses = []string{"localhost:9042"}
+	conf.Query = "INSERT INTO foo.bar (id, content) VALUES (?, ?)"
+	conf.Args = []string{"${!metadata:id}", "${!content}"}
+
+	c, err := NewCassandra(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(1).Metadata().Set("id", "2")
+
+	exp := []interface{}{cassandraArg("2"), cassandraArg("bar")}
+	if act := c.queryArgs(msg, 1); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong args: %v != %v", act, exp)
+	}
+	if err = c.Write(msg); err != types.ErrNotConnected {
+		t.Errorf("Expected not connected error, received: %v", err)
+	}
+}
+
+func TestCassandraConfigErrors(t *testing.T) {
+	tests := map[string]func(c *CassandraConfig){
+		"no addresses": func(c *CassandraConfig) {
+			c.Addresses = nil
+		},
+		"no query": func(c *CassandraConfig) {
+			c.Query = ""
+		},
+		"bad batch type": func(c *CassandraConfig) {
+			c.BatchType = "nope"
+		},
+		"bad consistency": func(c *CassandraConfig) {
+			c.Consistency = "nope"
+		},
+		"bad timeout": func(c *CassandraConfig) {
+			c.Timeout = "nope"
+		},
+		"bad backoff": func(c *CassandraConfig) {
+			c.Backoff.MaxInterval = "nope"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewCassandraConfig()
+		conf.Addresses = []string{"localhost:9042"}
+		conf.Query = "INSERT INTO foo.bar (id) VALUES (?)"
+		fn(&conf)
+		if _, err := NewCassandra(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,469 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/gabs/v2"
+
+	// ClickHouse native protocol driver
+	_ "github.com/ClickHouse/clickhouse-go"
+)
+
+//------------------------------------------------------------------------------
+
+// ClickHouseColumnConfig contains config fields for mapping a field of a JSON
+// document to a column of a ClickHouse table.
+type ClickHouseColumnConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Path string `json:"path" yaml:"path"`
+	Type string `json:"type" yaml:"type"`
+}
+
+// ClickHouseConfig contains config fields for the ClickHouse output type.
+type ClickHouseConfig struct {
+	DSN                string                   `json:"dsn" yaml:"dsn"`
+	Table              string                   `json:"table" yaml:"table"`
+	Columns            []ClickHouseColumnConfig `json:"columns" yaml:"columns"`
+	AsyncInsert        bool                     `json:"async_insert" yaml:"async_insert"`
+	WaitForAsyncInsert bool                     `json:"wait_for_async_insert" yaml:"wait_for_async_insert"`
+}
+
+// NewClickHouseConfig creates a ClickHouseConfig populated with default
+// values.
+func NewClickHouseConfig() ClickHouseConfig {
+	return ClickHouseConfig{
+		DSN:                "tcp://localhost:9000?database=default",
+		Table:              "",
+		Columns:            []ClickHouseColumnConfig{},
+		AsyncInsert:        false,
+		WaitForAsyncInsert: true,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// clickHouseColumn converts a field of a JSON document into the value of a
+// column.
+type clickHouseColumn struct {
+	name     string
+	path     string
+	typ      reflect.Type
+	nullable bool
+	array    bool
+	parse    func(v interface{}) (interface{}, error)
+}
+
+// clickHouseScalars are the parsers and Go types of the scalar column types
+// that are supported.
+var clickHouseScalars = map[string]struct {
+	typ   reflect.Type
+	parse func(v interface{}) (interface{}, error)
+}{
+	"String":   {reflect.TypeOf(""), clickHouseParseString},
+	"UUID":     {reflect.TypeOf(""), clickHouseParseString},
+	"Bool":     {reflect.TypeOf(uint8(0)), clickHouseParseBool},
+	"Int8":     {reflect.TypeOf(int8(0)), clickHouseParseInt(8)},
+	"Int16":    {reflect.TypeOf(int16(0)), clickHouseParseInt(16)},
+	"Int32":    {reflect.TypeOf(int32(0)), clickHouseParseInt(32)},
+	"Int64":    {reflect.TypeOf(int64(0)), clickHouseParseInt(64)},
+	"UInt8":    {reflect.TypeOf(uint8(0)), clickHouseParseUint(8)},
+	"UInt16":   {reflect.TypeOf(uint16(0)), clickHouseParseUint(16)},
+	"UInt32":   {reflect.TypeOf(uint32(0)), clickHouseParseUint(32)},
+	"UInt64":   {reflect.TypeOf(uint64(0)), clickHouseParseUint(64)},
+	"Float32":  {reflect.TypeOf(float32(0)), clickHouseParseFloat(32)},
+	"Float64":  {reflect.TypeOf(float64(0)), clickHouseParseFloat(64)},
+	"Date":     {reflect.TypeOf(time.Time{}), clickHouseParseTime},
+	"DateTime": {reflect.TypeOf(time.Time{}), clickHouseParseTime},
+}
+
+func clickHouseParseString(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func clickHouseParseBool(v interface{}) (interface{}, error) {
+	var b bool
+	switch t := v.(type) {
+	case bool:
+		b = t
+	case string:
+		var err error
+		if b, err = strconv.ParseBool(t); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("expected bool value, got %T", v)
+	}
+	if b {
+		return uint8(1), nil
+	}
+	return uint8(0), nil
+}
+
+// clickHouseNumber returns the string representation of a JSON number, which
+// may also be given as a string.
+func clickHouseNumber(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case json.Number:
+		return t.String(), nil
+	case string:
+		return t, nil
+	}
+	return "", fmt.Errorf("expected number value, got %T", v)
+}
+
+func clickHouseParseInt(bits int) func(v interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		s, err := clickHouseNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		i, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch bits {
+		case 8:
+			return int8(i), nil
+		case 16:
+			return int16(i), nil
+		case 32:
+			return int32(i), nil
+		}
+		return i, nil
+	}
+}
+
+func clickHouseParseUint(bits int) func(v interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		s, err := clickHouseNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		u, err := strconv.ParseUint(s, 10, bits)
+		if err != nil {
+			return nil, err
+		}
+		switch bits {
+		case 8:
+			return uint8(u), nil
+		case 16:
+			return uint16(u), nil
+		case 32:
+			return uint32(u), nil
+		}
+		return u, nil
+	}
+}
+
+func clickHouseParseFloat(bits int) func(v interface{}) (interface{}, error) {
+	return func(v interface{}) (interface{}, error) {
+		s, err := clickHouseNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		f, err := strconv.ParseFloat(s, bits)
+		if err != nil {
+			return nil, err
+		}
+		if bits == 32 {
+			return float32(f), nil
+		}
+		return f, nil
+	}
+}
+
+// clickHouseParseTime parses either an RFC3339 timestamp or a number of seconds
+// since the unix epoch.
+func clickHouseParseTime(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(0, int64(f*float64(time.Second))).UTC(), nil
+	case string:
+		return time.Parse(time.RFC3339Nano, t)
+	}
+	return nil, fmt.Errorf("expected timestamp value, got %T", v)
+}
+
+// newClickHouseColumn creates a column from its config, where the type is a
+// scalar type optionally wrapped in Nullable or Array.
+func newClickHouseColumn(conf ClickHouseColumnConfig) (*clickHouseColumn, error) {
+	if len(conf.Name) == 0 {
+		return nil, errors.New("a column name must be specified")
+	}
+	c := &clickHouseColumn{
+		name: conf.Name,
+		path: conf.Path,
+	}
+	if len(c.path) == 0 {
+		c.path = conf.Name
+	}
+
+	typ := strings.TrimSpace(conf.Type)
+	if strings.HasPrefix(typ, "Nullable(") && strings.HasSuffix(typ, ")") {
+		c.nullable = true
+		typ = strings.TrimSuffix(strings.TrimPrefix(typ, "Nullable("), ")")
+	} else if strings.HasPrefix(typ, "Array(") && strings.HasSuffix(typ, ")") {
+		c.array = true
+		typ = strings.TrimSuffix(strings.TrimPrefix(typ, "Array("), ")")
+	}
+	scalar, exists := clickHouseScalars[typ]
+	if !exists {
+		return nil, fmt.Errorf("column '%v' has unsupported type '%v'", conf.Name, conf.Type)
+	}
+	c.typ, c.parse = scalar.typ, scalar.parse
+	return c, nil
+}
+
+// value returns the value of the column from a JSON document.
+func (c *clickHouseColumn) value(doc *gabs.Container) (interface{}, error) {
+	v := doc.Path(c.path).Data()
+	if v == nil {
+		if c.nullable {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("field '%v' of column '%v' not found", c.path, c.name)
+	}
+	if !c.array {
+		res, err := c.parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse column '%v': %v", c.name, err)
+		}
+		return res, nil
+	}
+	elems, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected array value of column '%v', got %T", c.name, v)
+	}
+	arr := reflect.MakeSlice(reflect.SliceOf(c.typ), len(elems), len(elems))
+	for i, e := range elems {
+		res, err := c.parse(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse element %v of column '%v': %v", i, c.name, err)
+		}
+		arr.Index(i).Set(reflect.ValueOf(res))
+	}
+	return arr.Interface(), nil
+}
+
+//------------------------------------------------------------------------------
+
+// ClickHouse is a benthos writer.Type implementation that inserts JSON
+// documents into a ClickHouse table.
+type ClickHouse struct {
+	conf    ClickHouseConfig
+	columns []*clickHouseColumn
+	query   string
+
+	db    *sql.DB
+	dbMut sync.Mutex
+
+	log   log.Modular
+	stats metrics.Type
+
+	mErrMapping metrics.StatCounter
+	mRows       metrics.StatCounter
+}
+
+// NewClickHouse creates a new ClickHouse writer.Type.
+func NewClickHouse(conf ClickHouseConfig, log log.Modular, stats metrics.Type) (*ClickHouse, error) {
+	if len(conf.Table) == 0 {
+		return nil, errors.New("a table must be specified")
+	}
+	if len(conf.Columns) == 0 {
+		return nil, errors.New("at least one column must be specified")
+	}
+	c := &ClickHouse{
+		conf:        conf,
+		log:         log,
+		stats:       stats,
+		mErrMapping: stats.GetCounter("error.mapping"),
+		mRows:       stats.GetCounter("rows.sent"),
+	}
+	names := make([]string, 0, len(conf.Columns))
+	for _, cConf := range conf.Columns {
+		col, err := newClickHouseColumn(cConf)
+		if err != nil {
+			return nil, err
+		}
+		c.columns = append(c.columns, col)
+		names = append(names, col.name)
+	}
+	c.query = clickHouseInsertQuery(conf, names)
+	return c, nil
+}
+
+// clickHouseInsertQuery builds the batch insert query of a table.
+func clickHouseInsertQuery(conf ClickHouseConfig, names []string) string {
+	var settings string
+	if conf.AsyncInsert {
+		wait := 0
+		if conf.WaitForAsyncInsert {
+			wait = 1
+		}
+		settings = fmt.Sprintf(" SETTINGS async_insert=1, wait_for_async_insert=%v", wait)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	return fmt.Sprintf(
+		"INSERT INTO %v (%v)%v VALUES (%v)",
+		conf.Table, strings.Join(names, ", "), settings, placeholders,
+	)
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a connection to ClickHouse.
+func (c *ClickHouse) Connect() error {
+	c.dbMut.Lock()
+	defer c.dbMut.Unlock()
+
+	if c.db != nil {
+		return nil
+	}
+
+	db, err := sql.Open("clickhouse", c.conf.DSN)
+	if err != nil {
+		return err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	c.db = db
+	c.log.Infof("Inserting messages into ClickHouse table: %v\n", c.conf.Table)
+	return nil
+}
+
+// rows converts the parts of a message into rows, skipping parts that cannot
+// be mapped.
+func (c *ClickHouse) rows(msg types.Message) [][]interface{} {
+	var rows [][]interface{}
+	msg.Iter(func(i int, part types.Part) error {
+		dec := json.NewDecoder(bytes.NewReader(part.Get()))
+		dec.UseNumber()
+
+		var jObj interface{}
+		if err := dec.Decode(&jObj); err != nil {
+			c.mErrMapping.Incr(1)
+			c.log.Errorf("Failed to parse message as JSON: %v\n", err)
+			return nil
+		}
+		doc := gabs.Wrap(jObj)
+
+		row := make([]interface{}, 0, len(c.columns))
+		for _, col := range c.columns {
+			v, err := col.value(doc)
+			if err != nil {
+				c.mErrMapping.Incr(1)
+				c.log.Errorf("Failed to map message to row: %v\n", err)
+				return nil
+			}
+			row = append(row, v)
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows
+}
+
+// Write attempts to insert the parts of a message as a single batch.
+func (c *ClickHouse) Write(msg types.Message) error {
+	c.dbMut.Lock()
+	db := c.db
+	c.dbMut.Unlock()
+
+	if db == nil {
+		return types.ErrNotConnected
+	}
+
+	rows := c.rows(msg)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Rows are sent as a single block when the transaction is committed.
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(c.query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, row := range rows {
+		if _, err = stmt.Exec(row...); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	stmt.Close()
+
+	c.mRows.Incr(int64(len(rows)))
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (c *ClickHouse) CloseAsync() {
+	c.dbMut.Lock()
+	if c.db != nil {
+		c.db.Close()
+		c.db = nil
+	}
+	c.dbMut.Unlock()
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (c *ClickHouse) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,133 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestClickHouseBadConfig(t *testing.T) {
+	conf := NewClickHouseConfig()
+	conf.Columns = []ClickHouseColumnConfig{{Name: "foo", Type: "String"}}
+	if _, err := NewClickHouse(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing table")
+	}
+
+	conf.Table = "foo"
+	conf.Columns = nil
+	if _, err := NewClickHouse(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing columns")
+	}
+
+	conf.Columns = []ClickHouseColumnConfig{{Name: "foo", Type: "Map(String, String)"}}
+	if _, err := NewClickHouse(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from unsupported type")
+	}
+
+	conf.Columns = []ClickHouseColumnConfig{{Type: "String"}}
+	if _, err := NewClickHouse(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing column name")
+	}
+}
+
+func TestClickHouseQuery(t *testing.T) {
+	conf := NewClickHouseConfig()
+	conf.Table = "events"
+	conf.Columns = []ClickHouseColumnConfig{
+		{Name: "id", Type: "UInt64"},
+		{Name: "name", Type: "String"},
+	}
+
+	c, err := NewClickHouse(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "INSERT INTO events (id, name) VALUES (?, ?)", c.query; exp != act {
+		t.Errorf("Wrong query: %v != %v", act, exp)
+	}
+
+	conf.AsyncInsert = true
+	conf.WaitForAsyncInsert = false
+	if c, err = NewClickHouse(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "INSERT INTO events (id, name) SETTINGS async_insert=1, wait_for_async_insert=0 VALUES (?, ?)", c.query; exp != act {
+		t.Errorf("Wrong query: %v != %v", act, exp)
+	}
+}
+
+func TestClickHouseRows(t *testing.T) {
+	conf := NewClickHouseConfig()
+	conf.Table = "events"
+	conf.Columns = []ClickHouseColumnConfig{
+		{Name: "id", Type: "UInt64"},
+		{Name: "user", Path: "user.name", Type: "String"},
+		{Name: "meta", Type: "String"},
+		{Name: "active", Type: "Bool"},
+		{Name: "count", Type: "Int32"},
+		{Name: "score", Type: "Nullable(Float64)"},
+		{Name: "tags", Type: "Array(String)"},
+		{Name: "created_at", Type: "DateTime"},
+	}
+
+	c, err := NewClickHouse(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := c.rows(message.New([][]byte{
+		[]byte(`{"id":18446744073709551615,"user":{"name":"foo"},"meta":{"a":1},"active":true,"count":"-5","score":1.5,"tags":["a","b"],"created_at":"2020-01-01T00:00:00Z"}`),
+		[]byte(`{"id":2,"user":{"name":"bar"},"meta":"baz","active":false,"count":5,"tags":[],"created_at":1577836800}`),
+		[]byte(`{"id":3,"user":{"name":"bar"},"meta":"baz","active":false,"count":5,"tags":[],"created_at":"nope"}`),
+		[]byte(`{"id":4}`),
+		[]byte(`not json`),
+	}))
+
+	ts := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	exp := [][]interface{}{
+		{uint64(18446744073709551615), "foo", `{"a":1}`, uint8(1), int32(-5), float64(1.5), []string{"a", "b"}, ts},
+		{uint64(2), "bar", "baz", uint8(0), int32(5), nil, []string{}, ts},
+	}
+	if !reflect.DeepEqual(exp, rows) {
+		t.Errorf("Wrong rows: %v != %v", rows, exp)
+	}
+}
+
+func TestClickHouseNotConnected(t *testing.T) {
+	conf := NewClickHouseConfig()
+	conf.Table = "events"
+	conf.Columns = []ClickHouseColumnConfig{{Name: "id", Type: "UInt64"}}
+
+	c, err := NewClickHouse(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Write(message.New([][]byte{[]byte(`{"id":1}`)})); err != types.ErrNotConnected {
+		t.Errorf("Expected not connected error, got: %v", err)
+	}
+}
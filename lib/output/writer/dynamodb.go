@@ -24,11 +24,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/benthos/v3/lib/util/aws/session"
@@ -89,6 +92,9 @@ type DynamoDB struct {
 	ttl            time.Duration
 	strColumns     map[string]*text.InterpolatedString
 	jsonMapColumns map[string]string
+
+	closeChan chan struct{}
+	closeOnce sync.Once
 }
 
 // NewDynamoDB creates a new Amazon SQS writer.Type.
@@ -109,6 +115,7 @@ func NewDynamoDB(
 		backoff:        boff,
 		strColumns:     map[string]*text.InterpolatedString{},
 		jsonMapColumns: map[string]string{},
+		closeChan:      make(chan struct{}),
 	}
 	if len(conf.StringColumns) == 0 && len(conf.JSONMapColumns) == 0 {
 		return nil, errors.New("you must provide at least one column")
@@ -214,13 +221,27 @@ func jsonToMap(path string, root interface{}) (*dynamodb.AttributeValue, error)
 	return walkJSON(gObj.Data()), nil
 }
 
-// Write attempts to write message contents to a target SQS.
+// dynamoDBMaxBatchSize is the maximum number of items that can be written in a
+// single BatchWriteItem request.
+const dynamoDBMaxBatchSize = 25
+
+// dynamoDBRequest is a pending write request along with the index of the
+// message it was created from.
+type dynamoDBRequest struct {
+	index int
+	req   *dynamodb.WriteRequest
+}
+
+// Write attempts to write message contents to a target DynamoDB table. Items
+// are written with BatchWriteItem requests, where unprocessed items are
+// retried with backoff, and items that could not be written are reported as
+// errors of their respective messages with a batch.Error.
 func (d *DynamoDB) Write(msg types.Message) error {
 	if d.client == nil {
 		return types.ErrNotConnected
 	}
 
-	writeReqs := []*dynamodb.WriteRequest{}
+	writeReqs := []dynamoDBRequest{}
 	msg.Iter(func(i int, p types.Part) error {
 		items := map[string]*dynamodb.AttributeValue{}
 		if d.ttl != 0 && d.conf.TTLKey != "" {
@@ -254,48 +275,107 @@ func (d *DynamoDB) Write(msg types.Message) error {
 				}
 			}
 		}
-		writeReqs = append(writeReqs, &dynamodb.WriteRequest{
-			PutRequest: &dynamodb.PutRequest{
-				Item: items,
+		writeReqs = append(writeReqs, dynamoDBRequest{
+			index: i,
+			req: &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{
+					Item: items,
+				},
 			},
 		})
 		return nil
 	})
 
-	var err error
+	var batchErr *batch.Error
 	for len(writeReqs) > 0 {
-		wait := d.backoff.NextBackOff()
-		var batchResult *dynamodb.BatchWriteItemOutput
-		batchResult, err = d.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+		chunk := writeReqs
+		if len(chunk) > dynamoDBMaxBatchSize {
+			chunk = chunk[:dynamoDBMaxBatchSize]
+		}
+		writeReqs = writeReqs[len(chunk):]
+
+		failed, err := d.writeChunk(chunk)
+		if err == types.ErrTypeClosed {
+			return err
+		}
+		for _, r := range failed {
+			if batchErr == nil {
+				batchErr = batch.NewError(msg, errors.New("failed to write items to dynamodb"))
+			}
+			batchErr.Failed(r.index, err)
+		}
+	}
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// writeChunk writes a chunk of requests with BatchWriteItem until either all
+// items are processed or the retries are exhausted, in which case the requests
+// that remain unprocessed are returned along with the last error.
+func (d *DynamoDB) writeChunk(pending []dynamoDBRequest) ([]dynamoDBRequest, error) {
+	d.backoff.Reset()
+
+	for {
+		reqs := make([]*dynamodb.WriteRequest, len(pending))
+		for i, r := range pending {
+			reqs[i] = r.req
+		}
+
+		batchResult, err := d.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
 			RequestItems: map[string][]*dynamodb.WriteRequest{
-				*d.table: writeReqs,
+				*d.table: reqs,
 			},
 		})
 		if err != nil {
 			d.log.Errorf("Write multi error: %v\n", err)
 		} else if unproc := batchResult.UnprocessedItems[*d.table]; len(unproc) > 0 {
-			writeReqs = unproc
-			err = fmt.Errorf("failed to set %v items", len(unproc))
+			pending = matchUnprocessed(pending, unproc)
+			err = fmt.Errorf("failed to process %v items", len(unproc))
 		} else {
-			writeReqs = nil
+			return nil, nil
 		}
 
-		if err != nil {
-			if wait == backoff.Stop {
+		wait := d.backoff.NextBackOff()
+		if wait == backoff.Stop {
+			return pending, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-d.closeChan:
+			return pending, types.ErrTypeClosed
+		}
+	}
+}
+
+// matchUnprocessed returns the pending requests that were reported as
+// unprocessed. If not all unprocessed items can be matched with a pending
+// request then all pending requests are returned.
+func matchUnprocessed(pending []dynamoDBRequest, unproc []*dynamodb.WriteRequest) []dynamoDBRequest {
+	matched := make([]dynamoDBRequest, 0, len(unproc))
+	taken := make([]bool, len(pending))
+	for _, u := range unproc {
+		for i, r := range pending {
+			if !taken[i] && reflect.DeepEqual(r.req, u) {
+				taken[i] = true
+				matched = append(matched, r)
 				break
 			}
-			time.After(wait)
 		}
 	}
-
-	if err == nil {
-		d.backoff.Reset()
+	if len(matched) != len(unproc) {
+		return pending
 	}
-	return err
+	return matched
 }
 
 // CloseAsync begins cleaning up resources used by this writer asynchronously.
 func (d *DynamoDB) CloseAsync() {
+	d.closeOnce.Do(func() {
+		close(d.closeChan)
+	})
 }
 
 // WaitForClose will block until either the writer is closed or a specified
@@ -0,0 +1,204 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+//------------------------------------------------------------------------------
+
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	fn func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+func (m *mockDynamoDB) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	return m.fn(input)
+}
+
+func testDynamoDB(t *testing.T, fn func(*dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)) *DynamoDB {
+	t.Helper()
+
+	conf := NewDynamoDBConfig()
+	conf.Table = "FooTable"
+	conf.StringColumns = map[string]string{
+		"id": "${!content}",
+	}
+	conf.MaxRetries = 2
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxInterval = "1ms"
+
+	db, err := NewDynamoDB(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.client = &mockDynamoDB{fn: fn}
+	return db
+}
+
+func dynamoDBPutIDs(t *testing.T, input *dynamodb.BatchWriteItemInput) []string {
+	t.Helper()
+	var ids []string
+	for _, req := range input.RequestItems["FooTable"] {
+		ids = append(ids, *req.PutRequest.Item["id"].S)
+	}
+	return ids
+}
+
+func dynamoDBUnprocessed(ids ...string) *dynamodb.BatchWriteItemOutput {
+	var reqs []*dynamodb.WriteRequest
+	for _, id := range ids {
+		reqs = append(reqs, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{
+				Item: map[string]*dynamodb.AttributeValue{
+					"id": {S: aws.String(id)},
+				},
+			},
+		})
+	}
+	return &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]*dynamodb.WriteRequest{
+			"FooTable": reqs,
+		},
+	}
+}
+
+func TestDynamoDBHappy(t *testing.T) {
+	var calls []string
+	db := testDynamoDB(t, func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		calls = append(calls, dynamoDBPutIDs(t, input)...)
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	})
+
+	if err := db.Write(message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []string{"foo", "bar", "baz"}, calls; len(act) != len(exp) || act[0] != exp[0] || act[2] != exp[2] {
+		t.Errorf("Wrong items written: %v != %v", act, exp)
+	}
+}
+
+func TestDynamoDBChunks(t *testing.T) {
+	var sizes []int
+	db := testDynamoDB(t, func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		sizes = append(sizes, len(input.RequestItems["FooTable"]))
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	})
+
+	parts := make([][]byte, 60)
+	for i := range parts {
+		parts[i] = []byte{byte(i)}
+	}
+	if err := db.Write(message.New(parts)); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []int{25, 25, 10}, sizes; len(act) != len(exp) || act[0] != exp[0] || act[1] != exp[1] || act[2] != exp[2] {
+		t.Errorf("Wrong request sizes: %v != %v", act, exp)
+	}
+}
+
+func TestDynamoDBUnprocessedRetry(t *testing.T) {
+	var calls [][]string
+	db := testDynamoDB(t, func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		calls = append(calls, dynamoDBPutIDs(t, input))
+		if len(calls) == 1 {
+			return dynamoDBUnprocessed("bar"), nil
+		}
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	})
+
+	if err := db.Write(message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("Wrong count of calls: %v", calls)
+	}
+	if exp, act := []string{"bar"}, calls[1]; len(act) != 1 || act[0] != exp[0] {
+		t.Errorf("Wrong retried items: %v != %v", act, exp)
+	}
+}
+
+func TestDynamoDBUnprocessedFailed(t *testing.T) {
+	var calls int
+	db := testDynamoDB(t, func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		calls++
+		return dynamoDBUnprocessed("baz", "foo"), nil
+	})
+
+	err := db.Write(message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	}))
+	if exp, act := 3, calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if exp, act := 2, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of failed messages: %v != %v", act, exp)
+	}
+	var failed []string
+	bErr.WalkParts(func(i int, p types.Part, err error) bool {
+		if err != nil {
+			failed = append(failed, string(p.Get()))
+		}
+		return true
+	})
+	if exp, act := []string{"foo", "baz"}, failed; len(act) != 2 || act[0] != exp[0] || act[1] != exp[1] {
+		t.Errorf("Wrong failed messages: %v != %v", act, exp)
+	}
+}
+
+func TestDynamoDBRequestError(t *testing.T) {
+	db := testDynamoDB(t, func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+		return nil, errors.New("nope")
+	})
+
+	err := db.Write(message.New([][]byte{
+		[]byte("foo"), []byte("bar"),
+	}))
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if exp, act := 2, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of failed messages: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
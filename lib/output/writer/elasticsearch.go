@@ -21,8 +21,12 @@
 package writer
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
@@ -35,9 +39,9 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/util/http/auth"
 	"github.com/Jeffail/benthos/v3/lib/util/retries"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/cenkalti/backoff"
 	"github.com/olivere/elastic"
-	aws "github.com/olivere/elastic/aws/v4"
 )
 
 //------------------------------------------------------------------------------
@@ -45,25 +49,69 @@ import (
 // OptionalAWSConfig contains config fields for AWS authentication with an
 // enable flag.
 type OptionalAWSConfig struct {
-	Enabled     bool `json:"enabled" yaml:"enabled"`
+	Enabled     bool   `json:"enabled" yaml:"enabled"`
+	Service     string `json:"service" yaml:"service"`
 	sess.Config `json:",inline" yaml:",inline"`
 }
 
+// awsV4Transport signs requests with AWS Signature Version 4 before sending
+// them.
+type awsV4Transport struct {
+	signer  *v4.Signer
+	service string
+	region  string
+	rt      http.RoundTripper
+}
+
+func (t *awsV4Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	// Some services, such as OpenSearch Serverless, require the payload hash
+	// as a header.
+	hash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(hash[:]))
+
+	if _, err := t.signer.Sign(req, bytes.NewReader(body), t.service, t.region, time.Now()); err != nil {
+		return nil, err
+	}
+	return t.rt.RoundTrip(req)
+}
+
 //------------------------------------------------------------------------------
 
+// ElasticsearchScriptConfig contains config fields for a script that is used
+// by update actions of the Elasticsearch output type.
+type ElasticsearchScriptConfig struct {
+	Source         string `json:"source" yaml:"source"`
+	Lang           string `json:"lang" yaml:"lang"`
+	ScriptedUpsert bool   `json:"scripted_upsert" yaml:"scripted_upsert"`
+}
+
 // ElasticsearchConfig contains configuration fields for the Elasticsearch
 // output type.
 type ElasticsearchConfig struct {
-	URLs           []string             `json:"urls" yaml:"urls"`
-	Sniff          bool                 `json:"sniff" yaml:"sniff"`
-	ID             string               `json:"id" yaml:"id"`
-	Index          string               `json:"index" yaml:"index"`
-	Pipeline       string               `json:"pipeline" yaml:"pipeline"`
-	Type           string               `json:"type" yaml:"type"`
-	Timeout        string               `json:"timeout" yaml:"timeout"`
-	Auth           auth.BasicAuthConfig `json:"basic_auth" yaml:"basic_auth"`
-	AWS            OptionalAWSConfig    `json:"aws" yaml:"aws"`
-	retries.Config `json:",inline" yaml:",inline"`
+	URLs            []string                  `json:"urls" yaml:"urls"`
+	Sniff           bool                      `json:"sniff" yaml:"sniff"`
+	ID              string                    `json:"id" yaml:"id"`
+	Action          string                    `json:"action" yaml:"action"`
+	Index           string                    `json:"index" yaml:"index"`
+	Pipeline        string                    `json:"pipeline" yaml:"pipeline"`
+	Routing         string                    `json:"routing" yaml:"routing"`
+	Type            string                    `json:"type" yaml:"type"`
+	DocAsUpsert     bool                      `json:"doc_as_upsert" yaml:"doc_as_upsert"`
+	Script          ElasticsearchScriptConfig `json:"script" yaml:"script"`
+	RetryOnConflict int                       `json:"retry_on_conflict" yaml:"retry_on_conflict"`
+	Timeout         string                    `json:"timeout" yaml:"timeout"`
+	Auth            auth.BasicAuthConfig      `json:"basic_auth" yaml:"basic_auth"`
+	AWS             OptionalAWSConfig         `json:"aws" yaml:"aws"`
+	retries.Config  `json:",inline" yaml:",inline"`
 }
 
 // NewElasticsearchConfig creates a new ElasticsearchConfig with default values.
@@ -74,22 +122,43 @@ func NewElasticsearchConfig() ElasticsearchConfig {
 	rConf.Backoff.MaxElapsedTime = "30s"
 
 	return ElasticsearchConfig{
-		URLs:     []string{"http://localhost:9200"},
-		Sniff:    true,
-		ID:       "${!count:elastic_ids}-${!timestamp_unix}",
-		Index:    "benthos_index",
-		Pipeline: "",
-		Type:     "doc",
-		Timeout:  "5s",
-		Auth:     auth.NewBasicAuthConfig(),
+		URLs:        []string{"http://localhost:9200"},
+		Sniff:       true,
+		ID:          "${!count:elastic_ids}-${!timestamp_unix}",
+		Action:      "index",
+		Index:       "benthos_index",
+		Pipeline:    "",
+		Routing:     "",
+		Type:        "doc",
+		DocAsUpsert: false,
+		Script: ElasticsearchScriptConfig{
+			Source:         "",
+			Lang:           "painless",
+			ScriptedUpsert: false,
+		},
+		RetryOnConflict: 0,
+		Timeout:         "5s",
+		Auth:            auth.NewBasicAuthConfig(),
 		AWS: OptionalAWSConfig{
 			Enabled: false,
+			Service: "es",
 			Config:  sess.NewConfig(),
 		},
 		Config: rConf,
 	}
 }
 
+// NewOpenSearchConfig creates a new ElasticsearchConfig with default values
+// suited to OpenSearch, which does not support document types or sniffing
+// when hosted by Amazon OpenSearch Service.
+func NewOpenSearchConfig() ElasticsearchConfig {
+	conf := NewElasticsearchConfig()
+	conf.Sniff = false
+	conf.ID = "${!count:opensearch_ids}-${!timestamp_unix}"
+	conf.Type = ""
+	return conf
+}
+
 //------------------------------------------------------------------------------
 
 // Elasticsearch is a writer type that writes messages into elasticsearch.
@@ -105,8 +174,10 @@ type Elasticsearch struct {
 	timeout time.Duration
 
 	idStr             *text.InterpolatedString
+	actionStr         *text.InterpolatedString
 	indexStr          *text.InterpolatedString
 	pipelineStr       *text.InterpolatedString
+	routingStr        *text.InterpolatedString
 	interpolatedIndex bool
 
 	eJSONErr metrics.StatCounter
@@ -122,8 +193,10 @@ func NewElasticsearch(conf ElasticsearchConfig, log log.Modular, stats metrics.T
 		conf:              conf,
 		sniff:             conf.Sniff,
 		idStr:             text.NewInterpolatedString(conf.ID),
+		actionStr:         text.NewInterpolatedString(conf.Action),
 		indexStr:          text.NewInterpolatedString(conf.Index),
 		pipelineStr:       text.NewInterpolatedString(conf.Pipeline),
+		routingStr:        text.NewInterpolatedString(conf.Routing),
 		interpolatedIndex: text.ContainsFunctionVariables([]byte(conf.Index)),
 		eJSONErr:          stats.GetCounter("error.json"),
 	}
@@ -143,6 +216,12 @@ func NewElasticsearch(conf ElasticsearchConfig, log log.Modular, stats metrics.T
 		}
 	}
 
+	if !text.ContainsFunctionVariables([]byte(conf.Action)) {
+		if err := checkElasticAction(conf.Action); err != nil {
+			return nil, err
+		}
+	}
+
 	var err error
 	if e.backoff, err = conf.Config.Get(); err != nil {
 		return nil, err
@@ -177,8 +256,19 @@ func (e *Elasticsearch) Connect() error {
 		if err != nil {
 			return err
 		}
-		signingClient := aws.NewV4SigningClient(tsess.Config.Credentials, e.conf.AWS.Region)
-		opts = append(opts, elastic.SetHttpClient(signingClient))
+		service := e.conf.AWS.Service
+		if len(service) == 0 {
+			service = "es"
+		}
+		opts = append(opts, elastic.SetHttpClient(&http.Client{
+			Timeout: e.timeout,
+			Transport: &awsV4Transport{
+				signer:  v4.NewSigner(tsess.Config.Credentials),
+				service: service,
+				region:  e.conf.AWS.Region,
+				rt:      http.DefaultTransport,
+			},
+		}))
 	}
 
 	client, err := elastic.NewClient(opts...)
@@ -198,13 +288,74 @@ func shouldRetry(s int) bool {
 	return false
 }
 
+// checkElasticAction returns an error if an action is not supported.
+func checkElasticAction(action string) error {
+	switch action {
+	case "index", "create", "update", "delete":
+		return nil
+	}
+	return fmt.Errorf("elasticsearch action '%s' is not supported", action)
+}
+
 type pendingBulkIndex struct {
+	ID       string
+	Action   string
 	Index    string
 	Pipeline string
+	Routing  string
 	Type     string
 	Doc      interface{}
 }
 
+// bulkRequest creates a bulk request for a pending document.
+func (e *Elasticsearch) bulkRequest(p *pendingBulkIndex) (elastic.BulkableRequest, error) {
+	switch p.Action {
+	case "index", "create":
+		return elastic.NewBulkIndexRequest().
+			OpType(p.Action).
+			Index(p.Index).
+			Pipeline(p.Pipeline).
+			Routing(p.Routing).
+			Type(p.Type).
+			Id(p.ID).
+			Doc(p.Doc), nil
+	case "update":
+		r := elastic.NewBulkUpdateRequest().
+			Index(p.Index).
+			Routing(p.Routing).
+			Type(p.Type).
+			Id(p.ID)
+		if e.conf.RetryOnConflict > 0 {
+			r = r.RetryOnConflict(e.conf.RetryOnConflict)
+		}
+		if len(e.conf.Script.Source) == 0 {
+			return r.Doc(p.Doc).DocAsUpsert(e.conf.DocAsUpsert), nil
+		}
+		params, ok := p.Doc.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected JSON object as script params, got %T", p.Doc)
+		}
+		script := elastic.NewScript(e.conf.Script.Source).Params(params)
+		if len(e.conf.Script.Lang) > 0 {
+			script = script.Lang(e.conf.Script.Lang)
+		}
+		r = r.Script(script)
+		if e.conf.Script.ScriptedUpsert {
+			r = r.ScriptedUpsert(true).Upsert(map[string]interface{}{})
+		} else if e.conf.DocAsUpsert {
+			r = r.Upsert(p.Doc)
+		}
+		return r, nil
+	case "delete":
+		return elastic.NewBulkDeleteRequest().
+			Index(p.Index).
+			Routing(p.Routing).
+			Type(p.Type).
+			Id(p.ID), nil
+	}
+	return nil, checkElasticAction(p.Action)
+}
+
 // Write will attempt to write a message to Elasticsearch, wait for
 // acknowledgement, and returns an error if applicable.
 func (e *Elasticsearch) Write(msg types.Message) error {
@@ -212,11 +363,13 @@ func (e *Elasticsearch) Write(msg types.Message) error {
 		return types.ErrNotConnected
 	}
 
-	if msg.Len() == 1 {
+	// Documents without a type can only be written with the bulk API.
+	if msg.Len() == 1 && len(e.conf.Type) > 0 && e.actionStr.Get(msg) == "index" {
 		index := e.indexStr.Get(msg)
 		_, err := e.client.Index().
 			Index(index).
 			Pipeline(e.pipelineStr.Get(msg)).
+			Routing(e.routingStr.Get(msg)).
 			Type(e.conf.Type).
 			Id(e.idStr.Get(msg)).
 			BodyString(string(msg.Get(0).Get())).
@@ -230,68 +383,80 @@ func (e *Elasticsearch) Write(msg types.Message) error {
 
 	e.backoff.Reset()
 
-	requests := map[string]*pendingBulkIndex{}
+	var requests []*pendingBulkIndex
 	msg.Iter(func(i int, part types.Part) error {
-		jObj, ierr := part.JSON()
-		if ierr != nil {
-			e.eJSONErr.Incr(1)
-			e.log.Errorf("Failed to marshal message into JSON document: %v\n", ierr)
-			return nil
-		}
 		lMsg := message.Lock(msg, i)
-		requests[e.idStr.Get(lMsg)] = &pendingBulkIndex{
+		p := &pendingBulkIndex{
+			ID:       e.idStr.Get(lMsg),
+			Action:   e.actionStr.Get(lMsg),
 			Index:    e.indexStr.Get(lMsg),
 			Pipeline: e.pipelineStr.Get(lMsg),
+			Routing:  e.routingStr.Get(lMsg),
 			Type:     e.conf.Type,
-			Doc:      jObj,
 		}
+		if p.Action != "delete" {
+			jObj, ierr := part.JSON()
+			if ierr != nil {
+				e.eJSONErr.Incr(1)
+				e.log.Errorf("Failed to marshal message into JSON document: %v\n", ierr)
+				return nil
+			}
+			p.Doc = jObj
+		}
+		requests = append(requests, p)
 		return nil
 	})
 
-	b := e.client.Bulk()
-	for k, v := range requests {
-		b.Add(
-			elastic.NewBulkIndexRequest().
-				Index(v.Index).
-				Pipeline(v.Pipeline).
-				Type(v.Type).
-				Id(k).
-				Doc(v.Doc),
-		)
-	}
+	for len(requests) > 0 {
+		b := e.client.Bulk()
+		for _, v := range requests {
+			req, err := e.bulkRequest(v)
+			if err != nil {
+				return err
+			}
+			b.Add(req)
+		}
 
-	for b.NumberOfActions() != 0 {
 		result, err := b.Do(context.Background())
 		if err != nil {
 			return err
 		}
 
-		failed := result.Failed()
-		if len(failed) == 0 {
-			e.backoff.Reset()
+		// Items of the response are in the same order as the requests, which
+		// identifies documents that were given generated IDs.
+		var failed []*elastic.BulkResponseItem
+		var retries []*pendingBulkIndex
+		for i, item := range result.Items {
+			for _, res := range item {
+				if res.Status >= 200 && res.Status <= 299 {
+					continue
+				}
+				reason := ""
+				if res.Error != nil {
+					reason = res.Error.Reason
+				}
+				if !shouldRetry(res.Status) {
+					e.log.Errorf("Elasticsearch message '%v' rejected with code [%v]: %v\n", res.Id, res.Status, reason)
+					return fmt.Errorf("failed to send %v parts from message: %v", len(result.Failed()), reason)
+				}
+				e.log.Errorf("Elasticsearch message '%v' failed with code [%v]: %v\n", res.Id, res.Status, reason)
+				failed = append(failed, res)
+				if i < len(requests) {
+					retries = append(retries, requests[i])
+				}
+			}
+		}
+		if requests = retries; len(requests) == 0 {
 			continue
 		}
 
 		wait := e.backoff.NextBackOff()
-		for i := 0; i < len(failed); i++ {
-			if !shouldRetry(failed[i].Status) {
-				e.log.Errorf("Elasticsearch message '%v' rejected with code [%s]: %v\n", failed[i].Id, failed[i].Status, failed[i].Error.Reason)
-				return fmt.Errorf("failed to send %v parts from message: %v", len(failed), failed[0].Error.Reason)
-			}
-			e.log.Errorf("Elasticsearch message '%v' failed with code [%s]: %v\n", failed[i].Id, failed[i].Status, failed[i].Error.Reason)
-			id := failed[i].Id
-			req := requests[id]
-			b.Add(
-				elastic.NewBulkIndexRequest().
-					Index(req.Index).
-					Pipeline(req.Pipeline).
-					Type(req.Type).
-					Id(id).
-					Doc(req.Doc),
-			)
-		}
 		if wait == backoff.Stop {
-			return fmt.Errorf("failed to send %v parts from message: %v", len(failed), failed[0].Error.Reason)
+			reason := ""
+			if failed[0].Error != nil {
+				reason = failed[0].Error.Reason
+			}
+			return fmt.Errorf("failed to send %v parts from message: %v", len(failed), reason)
 		}
 		time.Sleep(wait)
 	}
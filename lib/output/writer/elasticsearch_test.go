@@ -21,9 +21,14 @@
 package writer
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -393,3 +398,248 @@ func testElasticBatch(urls []string, client *elastic.Client, t *testing.T) {
 		}
 	}
 }
+
+//------------------------------------------------------------------------------
+
+// elasticTestBulkServer starts an HTTP server that records the lines of each
+// bulk request it receives.
+func elasticTestBulkServer(t *testing.T) (*httptest.Server, <-chan []map[string]interface{}) {
+	t.Helper()
+	bulks := make(chan []map[string]interface{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/_bulk") {
+			w.Write([]byte(`{}`))
+			return
+		}
+		var lines []map[string]interface{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Error(err)
+			}
+			lines = append(lines, line)
+		}
+		bulks <- lines
+		w.Write([]byte(`{"took":1,"errors":false,"items":[]}`))
+	}))
+	return srv, bulks
+}
+
+func TestElasticActions(t *testing.T) {
+	srv, bulks := elasticTestBulkServer(t)
+	defer srv.Close()
+
+	conf := NewElasticsearchConfig()
+	conf.URLs = []string{srv.URL}
+	conf.Sniff = false
+	conf.ID = "${!json_field:id}"
+	conf.Action = "${!metadata:action}"
+	conf.Routing = "${!json_field:tenant}"
+	conf.DocAsUpsert = true
+
+	w, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"foo","tenant":"a"}`),
+		[]byte(`{"id":"bar","tenant":"b"}`),
+	})
+	msg.Get(0).Metadata().Set("action", "update")
+	msg.Get(1).Metadata().Set("action", "delete")
+	if err = w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]interface{}
+	select {
+	case lines = <-bulks:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for bulk request")
+	}
+
+	exp := map[string][]map[string]interface{}{
+		"foo": {
+			{"update": map[string]interface{}{
+				"_index": "benthos_index", "_type": "doc", "_id": "foo", "routing": "a",
+			}},
+			{"doc": map[string]interface{}{"id": "foo", "tenant": "a"}, "doc_as_upsert": true},
+		},
+		"bar": {
+			{"delete": map[string]interface{}{
+				"_index": "benthos_index", "_type": "doc", "_id": "bar", "routing": "b",
+			}},
+		},
+	}
+	act := map[string][]map[string]interface{}{}
+	for i := 0; i < len(lines); i++ {
+		var id string
+		for _, v := range lines[i] {
+			id, _ = v.(map[string]interface{})["_id"].(string)
+		}
+		act[id] = append(act[id], lines[i])
+		if _, isDelete := lines[i]["delete"]; !isDelete && i+1 < len(lines) {
+			i++
+			act[id] = append(act[id], lines[i])
+		}
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong bulk requests: %v != %v", act, exp)
+	}
+}
+
+func TestElasticScriptedUpsert(t *testing.T) {
+	srv, bulks := elasticTestBulkServer(t)
+	defer srv.Close()
+
+	conf := NewElasticsearchConfig()
+	conf.URLs = []string{srv.URL}
+	conf.Sniff = false
+	conf.ID = "foo"
+	conf.Action = "update"
+	conf.Script.Source = "ctx._source.count += params.count"
+	conf.Script.ScriptedUpsert = true
+	conf.RetryOnConflict = 3
+
+	w, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Write(message.New([][]byte{[]byte(`{"count":2}`)})); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]interface{}
+	select {
+	case lines = <-bulks:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for bulk request")
+	}
+
+	exp := []map[string]interface{}{
+		{"update": map[string]interface{}{
+			"_index": "benthos_index", "_type": "doc", "_id": "foo", "retry_on_conflict": float64(3),
+		}},
+		{
+			"script": map[string]interface{}{
+				"source": "ctx._source.count += params.count",
+				"lang":   "painless",
+				"params": map[string]interface{}{"count": float64(2)},
+			},
+			"scripted_upsert": true,
+			"upsert":          map[string]interface{}{},
+		},
+	}
+	if !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong bulk request: %v != %v", lines, exp)
+	}
+
+	if err = w.Write(message.New([][]byte{[]byte(`[1,2]`)})); err == nil {
+		t.Error("Expected error from non-object script params")
+	}
+}
+
+func TestElasticBadAction(t *testing.T) {
+	conf := NewElasticsearchConfig()
+	conf.Action = "nope"
+	if _, err := NewElasticsearch(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad action")
+	}
+}
+
+func TestElasticDataStream(t *testing.T) {
+	srv, bulks := elasticTestBulkServer(t)
+	defer srv.Close()
+
+	conf := NewOpenSearchConfig()
+	conf.URLs = []string{srv.URL}
+	conf.ID = ""
+	conf.Action = "create"
+	conf.Index = "logs-foo"
+
+	w, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Write(message.New([][]byte{
+		[]byte(`{"@timestamp":"2020-01-01T00:00:00Z","msg":"foo"}`),
+		[]byte(`{"@timestamp":"2020-01-01T00:00:01Z","msg":"bar"}`),
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]interface{}
+	select {
+	case lines = <-bulks:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for bulk request")
+	}
+
+	// Documents without an ID are all written, in order.
+	exp := []map[string]interface{}{
+		{"create": map[string]interface{}{"_index": "logs-foo"}},
+		{"@timestamp": "2020-01-01T00:00:00Z", "msg": "foo"},
+		{"create": map[string]interface{}{"_index": "logs-foo"}},
+		{"@timestamp": "2020-01-01T00:00:01Z", "msg": "bar"},
+	}
+	if !reflect.DeepEqual(exp, lines) {
+		t.Errorf("Wrong bulk request: %v != %v", lines, exp)
+	}
+}
+
+func TestElasticAWSSigning(t *testing.T) {
+	authHeaders := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Content-Sha256") == "" {
+			t.Error("Expected payload hash header")
+		}
+		authHeaders <- r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"_id":"foo","status":201}}]}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	conf := NewOpenSearchConfig()
+	conf.URLs = []string{srv.URL}
+	conf.ID = "foo"
+	conf.AWS.Enabled = true
+	conf.AWS.Service = "aoss"
+	conf.AWS.Region = "eu-west-2"
+	conf.AWS.Credentials.ID = "foo"
+	conf.AWS.Credentials.Secret = "bar"
+
+	w, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New([][]byte{[]byte(`{"msg":"foo"}`)})); err != nil {
+		t.Fatal(err)
+	}
+
+	for len(authHeaders) > 0 {
+		if auth := <-authHeaders; !strings.Contains(auth, "/eu-west-2/aoss/aws4_request") {
+			t.Errorf("Wrong authorization header: %v", auth)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+// EmailAttachmentsConfig contains configuration fields for sending the
+// messages of a batch as attachments.
+type EmailAttachmentsConfig struct {
+	Enabled     bool   `json:"enabled" yaml:"enabled"`
+	Filename    string `json:"filename" yaml:"filename"`
+	ContentType string `json:"content_type" yaml:"content_type"`
+}
+
+// EmailConfig contains configuration fields for the Email output type.
+type EmailConfig struct {
+	Address     string                 `json:"address" yaml:"address"`
+	Username    string                 `json:"username" yaml:"username"`
+	Password    string                 `json:"password" yaml:"password"`
+	From        string                 `json:"from" yaml:"from"`
+	To          []string               `json:"to" yaml:"to"`
+	Cc          []string               `json:"cc" yaml:"cc"`
+	Bcc         []string               `json:"bcc" yaml:"bcc"`
+	Subject     string                 `json:"subject" yaml:"subject"`
+	Body        string                 `json:"body" yaml:"body"`
+	ContentType string                 `json:"content_type" yaml:"content_type"`
+	Attachments EmailAttachmentsConfig `json:"attachments" yaml:"attachments"`
+	Timeout     string                 `json:"timeout" yaml:"timeout"`
+	TLS         btls.Config            `json:"tls" yaml:"tls"`
+}
+
+// NewEmailConfig creates a new EmailConfig with default values.
+func NewEmailConfig() EmailConfig {
+	return EmailConfig{
+		Address:     "localhost:587",
+		Username:    "",
+		Password:    "",
+		From:        "",
+		To:          []string{},
+		Cc:          []string{},
+		Bcc:         []string{},
+		Subject:     "",
+		Body:        "${!content}",
+		ContentType: "text/plain; charset=utf-8",
+		Attachments: EmailAttachmentsConfig{
+			Enabled:     false,
+			Filename:    "${!metadata:filename}",
+			ContentType: "",
+		},
+		Timeout: "10s",
+		TLS:     btls.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Email is a benthos writer.Type implementation that sends messages as emails
+// via SMTP.
+type Email struct {
+	conf    EmailConfig
+	host    string
+	timeout time.Duration
+	tlsConf *tls.Config
+	auth    smtp.Auth
+
+	to          []*text.InterpolatedString
+	cc          []*text.InterpolatedString
+	bcc         []*text.InterpolatedString
+	subject     *text.InterpolatedString
+	body        *text.InterpolatedString
+	contentType *text.InterpolatedString
+	attName     *text.InterpolatedString
+	attType     *text.InterpolatedString
+
+	connMut   sync.RWMutex
+	connected bool
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+func interpolatedStrings(strs []string) []*text.InterpolatedString {
+	interps := make([]*text.InterpolatedString, len(strs))
+	for i, s := range strs {
+		interps[i] = text.NewInterpolatedString(s)
+	}
+	return interps
+}
+
+// NewEmail creates a new Email writer.Type.
+func NewEmail(conf EmailConfig, log log.Modular, stats metrics.Type) (*Email, error) {
+	e := &Email{
+		conf:        conf,
+		to:          interpolatedStrings(conf.To),
+		cc:          interpolatedStrings(conf.Cc),
+		bcc:         interpolatedStrings(conf.Bcc),
+		subject:     text.NewInterpolatedString(conf.Subject),
+		body:        text.NewInterpolatedString(conf.Body),
+		contentType: text.NewInterpolatedString(conf.ContentType),
+		attName:     text.NewInterpolatedString(conf.Attachments.Filename),
+		attType:     text.NewInterpolatedString(conf.Attachments.ContentType),
+		log:         log,
+		stats:       stats,
+	}
+	if len(conf.From) == 0 {
+		return nil, errors.New("a from address must be specified")
+	}
+	if len(conf.To)+len(conf.Cc)+len(conf.Bcc) == 0 {
+		return nil, errors.New("at least one recipient must be specified")
+	}
+
+	var err error
+	if e.host, _, err = net.SplitHostPort(conf.Address); err != nil {
+		return nil, fmt.Errorf("failed to parse address: %v", err)
+	}
+	if e.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if e.tlsConf, err = conf.TLS.Get(); err != nil {
+		return nil, err
+	}
+	if len(e.tlsConf.ServerName) == 0 {
+		e.tlsConf.ServerName = e.host
+	}
+	if len(conf.Username) > 0 {
+		e.auth = smtp.PlainAuth("", conf.Username, conf.Password, e.host)
+	}
+	return e, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect is a noop as a new SMTP session is established for each write.
+func (e *Email) Connect() error {
+	e.connMut.Lock()
+	defer e.connMut.Unlock()
+
+	if !e.connected {
+		e.connected = true
+		e.log.Infof("Sending emails via SMTP server: %v\n", e.conf.Address)
+	}
+	return nil
+}
+
+// recipients resolves a list of address fields, where each resolved value may
+// contain multiple comma separated addresses.
+func recipients(fields []*text.InterpolatedString, msg text.Message) []string {
+	var addrs []string
+	for _, f := range fields {
+		for _, addr := range strings.Split(f.Get(msg), ",") {
+			if addr = strings.TrimSpace(addr); len(addr) > 0 {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// email is a composed message along with its envelope recipients.
+type email struct {
+	rcpts []string
+	data  []byte
+}
+
+func writeQuotedPrintable(w io.Writer, data []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write(data); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeBase64Lines(w io.Writer, data []byte) error {
+	enc := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(enc, data)
+	for len(enc) > 76 {
+		if _, err := w.Write(append(enc[:76:76], '\r', '\n')); err != nil {
+			return err
+		}
+		enc = enc[76:]
+	}
+	_, err := w.Write(append(enc, '\r', '\n'))
+	return err
+}
+
+// compose builds an email from a message of a batch, along with the remaining
+// messages of the batch as attachments when enabled.
+func (e *Email) compose(msg types.Message, index int, attachments []int) (email, error) {
+	lMsg := message.Lock(msg, index)
+
+	to, cc := recipients(e.to, lMsg), recipients(e.cc, lMsg)
+	rcpts := append(append(append([]string{}, to...), cc...), recipients(e.bcc, lMsg)...)
+	if len(rcpts) == 0 {
+		return email{}, errors.New("message resolved to zero recipients")
+	}
+
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%v: %v\r\n", k, v)
+	}
+	header("From", e.conf.From)
+	if len(to) > 0 {
+		header("To", strings.Join(to, ", "))
+	}
+	if len(cc) > 0 {
+		header("Cc", strings.Join(cc, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", e.subject.Get(lMsg)))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	body := e.body.Get(lMsg)
+	contentType := e.contentType.Get(lMsg)
+
+	if len(attachments) == 0 {
+		header("Content-Type", contentType)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, []byte(body)); err != nil {
+			return email{}, err
+		}
+		return email{rcpts: rcpts, data: buf.Bytes()}, nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{
+		"boundary": mw.Boundary(),
+	}))
+	buf.WriteString("\r\n")
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return email{}, err
+	}
+	if err = writeQuotedPrintable(pw, []byte(body)); err != nil {
+		return email{}, err
+	}
+
+	for n, i := range attachments {
+		aMsg := message.Lock(msg, i)
+		data := msg.Get(i).Get()
+
+		filename := e.attName.Get(aMsg)
+		if len(filename) == 0 {
+			filename = "attachment-" + strconv.Itoa(n+1)
+		}
+		attType := e.attType.Get(aMsg)
+		if len(attType) == 0 {
+			if attType = mime.TypeByExtension(path.Ext(filename)); len(attType) == 0 {
+				attType = http.DetectContentType(data)
+			}
+		}
+
+		if pw, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {attType},
+			"Content-Disposition": {mime.FormatMediaType("attachment", map[string]string{
+				"filename": filename,
+			})},
+			"Content-Transfer-Encoding": {"base64"},
+		}); err != nil {
+			return email{}, err
+		}
+		if err = writeBase64Lines(pw, data); err != nil {
+			return email{}, err
+		}
+	}
+	if err = mw.Close(); err != nil {
+		return email{}, err
+	}
+	return email{rcpts: rcpts, data: buf.Bytes()}, nil
+}
+
+// send delivers emails within a single SMTP session.
+func (e *Email) send(emails []email) error {
+	dialer := &net.Dialer{Timeout: e.timeout}
+
+	var conn net.Conn
+	var err error
+	if e.conf.TLS.Enabled {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.conf.Address, e.tlsConf)
+	} else {
+		conn, err = dialer.Dial("tcp", e.conf.Address)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(e.timeout))
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !e.conf.TLS.Enabled {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(e.tlsConf); err != nil {
+				return err
+			}
+		}
+	}
+	if e.auth != nil {
+		if err = client.Auth(e.auth); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range emails {
+		if err = client.Mail(e.conf.From); err != nil {
+			return err
+		}
+		for _, rcpt := range m.rcpts {
+			if err = client.Rcpt(rcpt); err != nil {
+				return err
+			}
+		}
+		w, err := client.Data()
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(m.data); err != nil {
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+	}
+	return client.Quit()
+}
+
+// Write attempts to send a message as emails. When attachments are enabled a
+// batch is sent as a single email with the first message as the body,
+// otherwise each message of the batch is sent as its own email.
+func (e *Email) Write(msg types.Message) error {
+	e.connMut.RLock()
+	connected := e.connected
+	e.connMut.RUnlock()
+	if !connected {
+		return types.ErrNotConnected
+	}
+
+	var emails []email
+	var batchErr *batch.Error
+	if e.conf.Attachments.Enabled {
+		indexes := make([]int, 0, msg.Len())
+		for i := 1; i < msg.Len(); i++ {
+			indexes = append(indexes, i)
+		}
+		m, err := e.compose(msg, 0, indexes)
+		if err != nil {
+			return err
+		}
+		emails = append(emails, m)
+	} else {
+		msg.Iter(func(i int, _ types.Part) error {
+			m, err := e.compose(msg, i, nil)
+			if err != nil {
+				if batchErr == nil {
+					batchErr = batch.NewError(msg, err)
+				}
+				batchErr.Failed(i, err)
+				return nil
+			}
+			emails = append(emails, m)
+			return nil
+		})
+	}
+
+	if len(emails) > 0 {
+		if err := e.send(emails); err != nil {
+			return err
+		}
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (e *Email) CloseAsync() {
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (e *Email) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
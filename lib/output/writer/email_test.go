@@ -0,0 +1,402 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"bufio"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type testSMTPMail struct {
+	from  string
+	rcpts []string
+	data  string
+}
+
+type testSMTPServer struct {
+	mut   sync.Mutex
+	auths []string
+	mails []testSMTPMail
+}
+
+func (s *testSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	var current testSMTPMail
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH"):
+			s.mut.Lock()
+			s.auths = append(s.auths, line)
+			s.mut.Unlock()
+			reply("235 OK")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			current = testSMTPMail{from: line[len("MAIL FROM:"):]}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpt := line[len("RCPT TO:"):]
+			if strings.Contains(rcpt, "reject") {
+				reply("550 no such user")
+				continue
+			}
+			current.rcpts = append(current.rcpts, rcpt)
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				dLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dLine, "."))
+			}
+			current.data = data.String()
+			s.mut.Lock()
+			s.mails = append(s.mails, current)
+			s.mut.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *testSMTPServer) getAuths() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string{}, s.auths...)
+}
+
+func (s *testSMTPServer) getMails() []testSMTPMail {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]testSMTPMail{}, s.mails...)
+}
+
+func startTestSMTPServer(t *testing.T) (*testSMTPServer, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &testSMTPServer{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, ln.Addr().String()
+}
+
+//------------------------------------------------------------------------------
+
+func TestEmailBasic(t *testing.T) {
+	server, addr := startTestSMTPServer(t)
+
+	conf := NewEmailConfig()
+	conf.Address = addr
+	conf.Username = "foo"
+	conf.Password = "bar"
+	conf.From = "benthos@example.com"
+	conf.To = []string{"${!metadata:to}"}
+	conf.Bcc = []string{"audit@example.com"}
+	conf.Subject = "Alert: ${!json_field:level}"
+	conf.Body = "Received: ${!json_field:msg}"
+
+	w, err := NewEmail(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New([][]byte{[]byte("{}")})); err != types.ErrNotConnected {
+		t.Errorf("Expected not connected error, got: %v", err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"level":"error","msg":"first"}`),
+		[]byte(`{"level":"warn","msg":"second"}`),
+	})
+	msg.Get(0).Metadata().Set("to", "a@example.com, b@example.com")
+	msg.Get(1).Metadata().Set("to", "c@example.com")
+	if err = w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := 1, len(server.getAuths()); exp != act {
+		t.Errorf("Wrong count of auths: %v != %v", act, exp)
+	}
+
+	mails := server.getMails()
+	if len(mails) != 2 {
+		t.Fatalf("Wrong count of mails: %v", len(mails))
+	}
+	if exp, act := "<benthos@example.com>", mails[0].from; exp != act {
+		t.Errorf("Wrong sender: %v != %v", act, exp)
+	}
+	if exp, act := []string{"<a@example.com>", "<b@example.com>", "<audit@example.com>"}, mails[0].rcpts; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong recipients: %v != %v", act, exp)
+	}
+	if exp, act := []string{"<c@example.com>", "<audit@example.com>"}, mails[1].rcpts; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong recipients: %v != %v", act, exp)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(mails[0].data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, exp := range map[string]string{
+		"To":           "a@example.com, b@example.com",
+		"Bcc":          "",
+		"Subject":      "Alert: error",
+		"Content-Type": "text/plain; charset=utf-8",
+	} {
+		if act := m.Header.Get(k); exp != act {
+			t.Errorf("Wrong %v header: %v != %v", k, act, exp)
+		}
+	}
+
+	body, err := ioutil.ReadAll(m.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "Received: first\r\n", string(body); exp != act {
+		t.Errorf("Wrong body: %q != %q", act, exp)
+	}
+
+	w.CloseAsync()
+	if err = w.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmailAttachments(t *testing.T) {
+	server, addr := startTestSMTPServer(t)
+
+	conf := NewEmailConfig()
+	conf.Address = addr
+	conf.From = "benthos@example.com"
+	conf.To = []string{"ops@example.com"}
+	conf.Subject = "Report ${!json_field:name}"
+	conf.Body = "See attached"
+	conf.Attachments.Enabled = true
+
+	w, err := NewEmail(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"name":"daily"}`),
+		[]byte(`{"foo":"bar"}`),
+		[]byte(`hello world`),
+	})
+	msg.Get(1).Metadata().Set("filename", "data.json")
+	if err = w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if auths := server.getAuths(); len(auths) > 0 {
+		t.Errorf("Unexpected auths: %v", auths)
+	}
+
+	mails := server.getMails()
+	if len(mails) != 1 {
+		t.Fatalf("Wrong count of mails: %v", len(mails))
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(mails[0].data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "Report daily", m.Header.Get("Subject"); exp != act {
+		t.Errorf("Wrong subject: %v != %v", act, exp)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "multipart/mixed", mediaType; exp != act {
+		t.Errorf("Wrong content type: %v != %v", act, exp)
+	}
+
+	type part struct {
+		filename    string
+		contentType string
+		data        string
+	}
+	var parts []part
+
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part{
+			filename:    p.FileName(),
+			contentType: p.Header.Get("Content-Type"),
+			data:        string(data),
+		})
+	}
+
+	// Part readers decode quoted-printable bodies but not base64.
+	exp := []part{
+		{"", "text/plain; charset=utf-8", "See attached"},
+		{"data.json", "application/json", "eyJmb28iOiJiYXIifQ==\r\n"},
+		{"attachment-2", "text/plain; charset=utf-8", "aGVsbG8gd29ybGQ=\r\n"},
+	}
+	if !reflect.DeepEqual(exp, parts) {
+		t.Errorf("Wrong parts: %v != %v", parts, exp)
+	}
+}
+
+func TestEmailNoRecipients(t *testing.T) {
+	server, addr := startTestSMTPServer(t)
+
+	conf := NewEmailConfig()
+	conf.Address = addr
+	conf.From = "benthos@example.com"
+	conf.To = []string{"${!metadata:to}"}
+
+	w, err := NewEmail(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(1).Metadata().Set("to", "a@example.com")
+
+	err = w.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if exp, act := 1, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of indexed errors: %v != %v", act, exp)
+	}
+
+	mails := server.getMails()
+	if len(mails) != 1 {
+		t.Fatalf("Wrong count of mails: %v", len(mails))
+	}
+	if exp, act := []string{"<a@example.com>"}, mails[0].rcpts; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong recipients: %v != %v", act, exp)
+	}
+}
+
+func TestEmailRejectedRecipient(t *testing.T) {
+	_, addr := startTestSMTPServer(t)
+
+	conf := NewEmailConfig()
+	conf.Address = addr
+	conf.From = "benthos@example.com"
+	conf.To = []string{"reject@example.com"}
+
+	w, err := NewEmail(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New([][]byte{[]byte("foo")})); err == nil {
+		t.Error("Expected error from rejected recipient")
+	}
+}
+
+func TestEmailBadConfig(t *testing.T) {
+	conf := NewEmailConfig()
+	conf.To = []string{"foo@example.com"}
+	if _, err := NewEmail(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing from address")
+	}
+
+	conf = NewEmailConfig()
+	conf.From = "foo@example.com"
+	if _, err := NewEmail(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing recipients")
+	}
+
+	conf.To = []string{"bar@example.com"}
+	conf.Address = "nope"
+	if _, err := NewEmail(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad address")
+	}
+}
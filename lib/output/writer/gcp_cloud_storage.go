@@ -0,0 +1,250 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+//------------------------------------------------------------------------------
+
+// GCPCloudStorageConfig contains configuration fields for the GCPCloudStorage
+// output type.
+type GCPCloudStorageConfig struct {
+	Bucket          string `json:"bucket" yaml:"bucket"`
+	Path            string `json:"path" yaml:"path"`
+	ContentType     string `json:"content_type" yaml:"content_type"`
+	ContentEncoding string `json:"content_encoding" yaml:"content_encoding"`
+	CacheControl    string `json:"cache_control" yaml:"cache_control"`
+	StorageClass    string `json:"storage_class" yaml:"storage_class"`
+	KMSKeyName      string `json:"kms_key_name" yaml:"kms_key_name"`
+	EventBasedHold  bool   `json:"event_based_hold" yaml:"event_based_hold"`
+	TemporaryHold   bool   `json:"temporary_hold" yaml:"temporary_hold"`
+	Codec           string `json:"codec" yaml:"codec"`
+	ChunkSize       int    `json:"chunk_size" yaml:"chunk_size"`
+	Timeout         string `json:"timeout" yaml:"timeout"`
+}
+
+// NewGCPCloudStorageConfig creates a new GCPCloudStorageConfig with default
+// values.
+func NewGCPCloudStorageConfig() GCPCloudStorageConfig {
+	return GCPCloudStorageConfig{
+		Bucket:          "",
+		Path:            "${!count:files}-${!timestamp_unix_nano}.txt",
+		ContentType:     "application/octet-stream",
+		ContentEncoding: "",
+		CacheControl:    "",
+		StorageClass:    "",
+		KMSKeyName:      "",
+		EventBasedHold:  false,
+		TemporaryHold:   false,
+		Codec:           "all-bytes",
+		ChunkSize:       googleapi.DefaultUploadChunkSize,
+		Timeout:         "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// GCPCloudStorage is a benthos writer.Type implementation that writes messages
+// as objects to a Google Cloud Storage bucket.
+type GCPCloudStorage struct {
+	conf    GCPCloudStorageConfig
+	timeout time.Duration
+
+	path            *text.InterpolatedString
+	contentType     *text.InterpolatedString
+	contentEncoding *text.InterpolatedString
+	storageClass    *text.InterpolatedString
+
+	// When delim is nil each message is written to its own object, otherwise
+	// the messages of a batch are streamed to a single object, each followed
+	// by delim.
+	delim []byte
+
+	// Options of the client, set when testing.
+	storageOpts []option.ClientOption
+
+	connMut sync.RWMutex
+	client  *storage.Client
+	bucket  *storage.BucketHandle
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewGCPCloudStorage creates a new GCPCloudStorage writer.Type.
+func NewGCPCloudStorage(conf GCPCloudStorageConfig, log log.Modular, stats metrics.Type) (*GCPCloudStorage, error) {
+	g := &GCPCloudStorage{
+		conf:            conf,
+		path:            text.NewInterpolatedString(conf.Path),
+		contentType:     text.NewInterpolatedString(conf.ContentType),
+		contentEncoding: text.NewInterpolatedString(conf.ContentEncoding),
+		storageClass:    text.NewInterpolatedString(conf.StorageClass),
+		log:             log,
+		stats:           stats,
+	}
+	if len(conf.Bucket) == 0 {
+		return nil, errors.New("a bucket must be specified")
+	}
+	if conf.ChunkSize < 0 {
+		return nil, fmt.Errorf("chunk size must not be negative, got: %v", conf.ChunkSize)
+	}
+
+	switch {
+	case conf.Codec == "all-bytes":
+	case conf.Codec == "lines":
+		g.delim = []byte("\n")
+	case strings.HasPrefix(conf.Codec, "delim:"):
+		if g.delim = []byte(strings.TrimPrefix(conf.Codec, "delim:")); len(g.delim) == 0 {
+			return nil, errors.New("delim codec requires a non-empty delimiter")
+		}
+	default:
+		return nil, fmt.Errorf("codec not recognised: %v", conf.Codec)
+	}
+
+	var err error
+	if g.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	return g, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to create a client for the target bucket.
+func (g *GCPCloudStorage) Connect() error {
+	g.connMut.Lock()
+	defer g.connMut.Unlock()
+
+	if g.client != nil {
+		return nil
+	}
+
+	client, err := storage.NewClient(context.Background(), g.storageOpts...)
+	if err != nil {
+		return err
+	}
+	g.client = client
+	g.bucket = client.Bucket(g.conf.Bucket)
+
+	g.log.Infof("Uploading message parts as objects to GCP Cloud Storage bucket: %v\n", g.conf.Bucket)
+	return nil
+}
+
+// objectWriter creates a writer of the object for a message of a batch.
+func (g *GCPCloudStorage) objectWriter(ctx context.Context, bucket *storage.BucketHandle, msg types.Message, index int) *storage.Writer {
+	lMsg := message.Lock(msg, index)
+
+	w := bucket.Object(g.path.Get(lMsg)).NewWriter(ctx)
+	w.ChunkSize = g.conf.ChunkSize
+	w.ContentType = g.contentType.Get(lMsg)
+	w.ContentEncoding = g.contentEncoding.Get(lMsg)
+	w.CacheControl = g.conf.CacheControl
+	w.StorageClass = g.storageClass.Get(lMsg)
+	w.KMSKeyName = g.conf.KMSKeyName
+	w.EventBasedHold = g.conf.EventBasedHold
+	w.TemporaryHold = g.conf.TemporaryHold
+
+	w.Metadata = map[string]string{}
+	msg.Get(index).Metadata().Iter(func(k, v string) error {
+		w.Metadata[k] = v
+		return nil
+	})
+	return w
+}
+
+// Write attempts to write message contents to the target bucket as objects.
+func (g *GCPCloudStorage) Write(msg types.Message) error {
+	g.connMut.RLock()
+	bucket := g.bucket
+	g.connMut.RUnlock()
+
+	if bucket == nil {
+		return types.ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	defer cancel()
+
+	if g.delim != nil {
+		// The writer uploads the object in chunks as they are filled, and
+		// therefore the batch is never buffered as a whole.
+		w := g.objectWriter(ctx, bucket, msg, 0)
+		if err := msg.Iter(func(i int, p types.Part) error {
+			if _, err := w.Write(p.Get()); err != nil {
+				return err
+			}
+			_, err := w.Write(g.delim)
+			return err
+		}); err != nil {
+			cancel()
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+
+	return msg.Iter(func(i int, p types.Part) error {
+		w := g.objectWriter(ctx, bucket, msg, i)
+		if _, err := w.Write(p.Get()); err != nil {
+			cancel()
+			w.Close()
+			return err
+		}
+		return w.Close()
+	})
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (g *GCPCloudStorage) CloseAsync() {
+	go func() {
+		g.connMut.Lock()
+		if g.client != nil {
+			g.client.Close()
+			g.client = nil
+			g.bucket = nil
+		}
+		g.connMut.Unlock()
+	}()
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (g *GCPCloudStorage) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,222 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"google.golang.org/api/option"
+)
+
+//------------------------------------------------------------------------------
+
+type gcsTestObject struct {
+	kmsKeyName string
+	attrs      map[string]interface{}
+	data       string
+}
+
+// gcsTestServer accepts multipart uploads of objects to a single bucket.
+type gcsTestServer struct {
+	mut     sync.Mutex
+	objects []gcsTestObject
+}
+
+func (s *gcsTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/upload/storage/v1/b/bkt/o" || r.URL.Query().Get("uploadType") != "multipart" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	obj := gcsTestObject{kmsKeyName: r.URL.Query().Get("kmsKeyName")}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for i := 0; i < 2; i++ {
+		p, err := mr.NextPart()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(p)
+		if i == 0 {
+			if err = json.Unmarshal(data, &obj.attrs); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			obj.data = string(data)
+		}
+	}
+
+	s.mut.Lock()
+	s.objects = append(s.objects, obj)
+	s.mut.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bucket": "bkt",
+		"name":   obj.attrs["name"],
+	})
+}
+
+func (s *gcsTestServer) getObjects() []gcsTestObject {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]gcsTestObject{}, s.objects...)
+}
+
+func gcsTestWriter(t *testing.T, conf GCPCloudStorageConfig) (*GCPCloudStorage, *gcsTestServer) {
+	t.Helper()
+
+	fs := &gcsTestServer{}
+	server := httptest.NewServer(fs)
+	t.Cleanup(server.Close)
+
+	g, err := NewGCPCloudStorage(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.storageOpts = []option.ClientOption{
+		option.WithEndpoint(server.URL + "/storage/v1/"),
+		option.WithoutAuthentication(),
+	}
+	t.Cleanup(g.CloseAsync)
+	return g, fs
+}
+
+//------------------------------------------------------------------------------
+
+func TestGCPCloudStorageAllBytes(t *testing.T) {
+	conf := NewGCPCloudStorageConfig()
+	conf.Bucket = "bkt"
+	conf.Path = "${!json_field:id}.json"
+	conf.ContentType = "application/json"
+	conf.StorageClass = "${!metadata:class}"
+	conf.KMSKeyName = "projects/foo/locations/global/keyRings/bar/cryptoKeys/baz"
+	conf.TemporaryHold = true
+	conf.ChunkSize = 0
+
+	g, fs := gcsTestWriter(t, conf)
+
+	if err := g.Write(message.New(nil)); err != types.ErrNotConnected {
+		t.Errorf("Expected not connected error, got: %v", err)
+	}
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"foo"}`),
+		[]byte(`{"id":"bar"}`),
+	})
+	msg.Get(0).Metadata().Set("class", "NEARLINE")
+	if err := g.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := fs.getObjects()
+	if len(objects) != 2 {
+		t.Fatalf("Wrong count of objects: %v", len(objects))
+	}
+
+	if exp, act := `{"id":"foo"}`, objects[0].data; exp != act {
+		t.Errorf("Wrong object contents: %v != %v", act, exp)
+	}
+	if exp, act := conf.KMSKeyName, objects[0].kmsKeyName; exp != act {
+		t.Errorf("Wrong kms key name: %v != %v", act, exp)
+	}
+	for k, exp := range map[string]interface{}{
+		"name":          "foo.json",
+		"contentType":   "application/json",
+		"storageClass":  "NEARLINE",
+		"temporaryHold": true,
+		"metadata":      map[string]interface{}{"class": "NEARLINE"},
+	} {
+		if act := objects[0].attrs[k]; !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong %v attribute: %v != %v", k, act, exp)
+		}
+	}
+
+	if exp, act := "bar.json", objects[1].attrs["name"]; exp != act {
+		t.Errorf("Wrong name: %v != %v", act, exp)
+	}
+	if act, exists := objects[1].attrs["storageClass"]; exists {
+		t.Errorf("Unexpected storage class: %v", act)
+	}
+}
+
+func TestGCPCloudStorageLines(t *testing.T) {
+	conf := NewGCPCloudStorageConfig()
+	conf.Bucket = "bkt"
+	conf.Path = "batch-${!metadata:n}.txt"
+	conf.Codec = "delim:|"
+
+	g, fs := gcsTestWriter(t, conf)
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(0).Metadata().Set("n", "1")
+	msg.Get(1).Metadata().Set("n", "2")
+	if err := g.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := fs.getObjects()
+	if len(objects) != 1 {
+		t.Fatalf("Wrong count of objects: %v", len(objects))
+	}
+	if exp, act := "batch-1.txt", objects[0].attrs["name"]; exp != act {
+		t.Errorf("Wrong name: %v != %v", act, exp)
+	}
+	if exp, act := "foo|bar|", objects[0].data; exp != act {
+		t.Errorf("Wrong object contents: %v != %v", act, exp)
+	}
+}
+
+func TestGCPCloudStorageBadConfig(t *testing.T) {
+	conf := NewGCPCloudStorageConfig()
+	if _, err := NewGCPCloudStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing bucket")
+	}
+
+	conf.Bucket = "bkt"
+	conf.Codec = "delim:"
+	if _, err := NewGCPCloudStorage(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from empty delimiter")
+	}
+}
@@ -28,23 +28,27 @@ import (
 
 	"cloud.google.com/go/pubsub"
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
 )
 
 //------------------------------------------------------------------------------
 
 // GCPPubSubConfig contains configuration fields for the output GCPPubSub type.
 type GCPPubSubConfig struct {
-	ProjectID string `json:"project" yaml:"project"`
-	TopicID   string `json:"topic" yaml:"topic"`
+	ProjectID   string `json:"project" yaml:"project"`
+	TopicID     string `json:"topic" yaml:"topic"`
+	OrderingKey string `json:"ordering_key" yaml:"ordering_key"`
 }
 
 // NewGCPPubSubConfig creates a new Config with default values.
 func NewGCPPubSubConfig() GCPPubSubConfig {
 	return GCPPubSubConfig{
-		ProjectID: "",
-		TopicID:   "",
+		ProjectID:   "",
+		TopicID:     "",
+		OrderingKey: "",
 	}
 }
 
@@ -55,9 +59,10 @@ func NewGCPPubSubConfig() GCPPubSubConfig {
 type GCPPubSub struct {
 	conf GCPPubSubConfig
 
-	client   *pubsub.Client
-	topic    *pubsub.Topic
-	topicMut sync.Mutex
+	client      *pubsub.Client
+	topic       *pubsub.Topic
+	topicMut    sync.Mutex
+	orderingKey *text.InterpolatedString
 
 	log   log.Modular
 	stats metrics.Type
@@ -75,12 +80,16 @@ func NewGCPPubSub(
 	if err != nil {
 		return nil, err
 	}
-	return &GCPPubSub{
+	c := &GCPPubSub{
 		conf:   conf,
 		log:    log,
 		client: client,
 		stats:  stats,
-	}, nil
+	}
+	if len(conf.OrderingKey) > 0 {
+		c.orderingKey = text.NewInterpolatedString(conf.OrderingKey)
+	}
+	return c, nil
 }
 
 // Connect attempts to establish a connection to the target GCP Pub/Sub topic.
@@ -102,6 +111,9 @@ func (c *GCPPubSub) Connect() error {
 	if !exists {
 		return fmt.Errorf("topic '%v' does not exist", c.conf.TopicID)
 	}
+	if c.orderingKey != nil {
+		topic.EnableMessageOrdering = true
+	}
 
 	c.topic = topic
 	c.log.Infof("Sending GCP Cloud Pub/Sub messages to project '%v' and topic '%v'\n", c.conf.ProjectID, c.conf.TopicID)
@@ -120,6 +132,7 @@ func (c *GCPPubSub) Write(msg types.Message) error {
 
 	ctx := context.Background()
 	results := make([]*pubsub.PublishResult, msg.Len())
+	keys := make([]string, msg.Len())
 
 	msg.Iter(func(i int, part types.Part) error {
 		attr := map[string]string{}
@@ -133,14 +146,23 @@ func (c *GCPPubSub) Write(msg types.Message) error {
 		if len(attr) > 0 {
 			gmsg.Attributes = attr
 		}
+		if c.orderingKey != nil {
+			keys[i] = c.orderingKey.Get(message.Lock(msg, i))
+			gmsg.OrderingKey = keys[i]
+		}
 		results[i] = topic.Publish(ctx, gmsg)
 		return nil
 	})
 
 	var errs []error
-	for _, r := range results {
+	for i, r := range results {
 		if _, err := r.Get(ctx); err != nil {
 			errs = append(errs, err)
+			if len(keys[i]) > 0 {
+				// Publishing is paused for an ordering key after a failure
+				// and must be explicitly resumed for the retry to succeed.
+				topic.ResumePublish(keys[i])
+			}
 		}
 	}
 	if len(errs) > 0 {
@@ -0,0 +1,319 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+//------------------------------------------------------------------------------
+
+// GRPCClientConfig contains configuration fields for the GRPCClient output
+// type.
+type GRPCClientConfig struct {
+	Address       string            `json:"address" yaml:"address"`
+	Method        string            `json:"method" yaml:"method"`
+	DescriptorSet string            `json:"descriptor_set" yaml:"descriptor_set"`
+	Metadata      map[string]string `json:"metadata" yaml:"metadata"`
+	Timeout       string            `json:"timeout" yaml:"timeout"`
+	TLS           btls.Config       `json:"tls" yaml:"tls"`
+}
+
+// NewGRPCClientConfig creates a new GRPCClientConfig with default values.
+func NewGRPCClientConfig() GRPCClientConfig {
+	return GRPCClientConfig{
+		Address:       "localhost:50051",
+		Method:        "",
+		DescriptorSet: "",
+		Metadata:      map[string]string{},
+		Timeout:       "5s",
+		TLS:           btls.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// grpcDynamicCodec marshals dynamic protobuf messages, which the default gRPC
+// codec does not support.
+type grpcDynamicCodec struct{}
+
+func (grpcDynamicCodec) Marshal(v interface{}) ([]byte, error) {
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (grpcDynamicCodec) Unmarshal(data []byte, v interface{}) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (grpcDynamicCodec) Name() string {
+	return "proto"
+}
+
+// grpcMethodFromDescriptorSet finds a method within a descriptor set file,
+// where the method name is of the form `package.Service/Method`.
+func grpcMethodFromDescriptorSet(path, name string) (protoreflect.MethodDescriptor, error) {
+	setBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %v", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err = proto.Unmarshal(setBytes, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set: %v", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set: %v", err)
+	}
+
+	split := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	if len(split) != 2 {
+		return nil, fmt.Errorf("expected method of the form package.Service/Method, got: %v", name)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(split[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find service %v: %v", split[0], err)
+	}
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("descriptor %v is not a service", split[0])
+	}
+	method := service.Methods().ByName(protoreflect.Name(split[1]))
+	if method == nil {
+		return nil, fmt.Errorf("method %v not found in service %v", split[1], split[0])
+	}
+	return method, nil
+}
+
+//------------------------------------------------------------------------------
+
+// GRPCClient is an output type that invokes a gRPC method for messages.
+type GRPCClient struct {
+	log log.Modular
+
+	conn    *grpc.ClientConn
+	connMut sync.RWMutex
+
+	conf       GRPCClientConfig
+	method     protoreflect.MethodDescriptor
+	fullMethod string
+	timeout    time.Duration
+	dialOpts   []grpc.DialOption
+	metadata   map[string]*text.InterpolatedString
+}
+
+// NewGRPCClient creates a new GRPCClient output type.
+func NewGRPCClient(conf GRPCClientConfig, log log.Modular, stats metrics.Type) (*GRPCClient, error) {
+	g := GRPCClient{
+		log:      log,
+		conf:     conf,
+		metadata: map[string]*text.InterpolatedString{},
+	}
+	if len(conf.Method) == 0 {
+		return nil, errors.New("a method must be specified")
+	}
+	if len(conf.DescriptorSet) == 0 {
+		return nil, errors.New("a descriptor set must be specified")
+	}
+	for k, v := range conf.Metadata {
+		g.metadata[k] = text.NewInterpolatedString(v)
+	}
+
+	var err error
+	if g.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if g.method, err = grpcMethodFromDescriptorSet(conf.DescriptorSet, conf.Method); err != nil {
+		return nil, err
+	}
+	if g.method.IsStreamingServer() {
+		return nil, fmt.Errorf("method %v is server streaming, which is not supported", conf.Method)
+	}
+	g.fullMethod = "/" + string(g.method.Parent().FullName()) + "/" + string(g.method.Name())
+
+	g.dialOpts = []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcDynamicCodec{})),
+	}
+	if conf.TLS.Enabled {
+		tlsConf, err := conf.TLS.Get()
+		if err != nil {
+			return nil, err
+		}
+		g.dialOpts = append(g.dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConf)))
+	} else {
+		g.dialOpts = append(g.dialOpts, grpc.WithInsecure())
+	}
+	return &g, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a connection to the gRPC server.
+func (g *GRPCClient) Connect() error {
+	g.connMut.Lock()
+	defer g.connMut.Unlock()
+
+	if g.conn != nil {
+		return nil
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), g.timeout)
+	defer done()
+
+	var err error
+	if g.conn, err = grpc.DialContext(ctx, g.conf.Address, g.dialOpts...); err != nil {
+		return err
+	}
+	g.log.Infof("Sending gRPC requests to method %v at: %v\n", g.fullMethod, g.conf.Address)
+	return nil
+}
+
+// outgoingContext returns a context with a timeout that carries the metadata
+// resolved for a message.
+func (g *GRPCClient) outgoingContext(msg types.Message) (context.Context, context.CancelFunc) {
+	ctx, done := context.WithTimeout(context.Background(), g.timeout)
+	if len(g.metadata) > 0 {
+		md := metadata.MD{}
+		for k, v := range g.metadata {
+			md.Set(k, v.Get(msg))
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+	return ctx, done
+}
+
+// Write attempts to write a message. For unary methods each message of a batch
+// is sent as a separate request, and for client streaming methods a batch is
+// sent as a single stream.
+func (g *GRPCClient) Write(msg types.Message) error {
+	g.connMut.RLock()
+	conn := g.conn
+	g.connMut.RUnlock()
+
+	if conn == nil {
+		return types.ErrNotConnected
+	}
+
+	reqs := make([]*dynamicpb.Message, msg.Len())
+	var batchErr *batch.Error
+	msg.Iter(func(i int, p types.Part) error {
+		req := dynamicpb.NewMessage(g.method.Input())
+		if err := protojson.Unmarshal(p.Get(), req); err != nil {
+			err = fmt.Errorf("failed to map message onto %v: %v", g.method.Input().FullName(), err)
+			if batchErr == nil {
+				batchErr = batch.NewError(msg, err)
+			}
+			batchErr.Failed(i, err)
+			return nil
+		}
+		reqs[i] = req
+		return nil
+	})
+
+	if g.method.IsStreamingClient() {
+		if batchErr != nil {
+			return batchErr
+		}
+		return g.writeStream(conn, msg, reqs)
+	}
+
+	for i, req := range reqs {
+		if req == nil {
+			continue
+		}
+		ctx, done := g.outgoingContext(message.Lock(msg, i))
+		err := conn.Invoke(ctx, g.fullMethod, req, dynamicpb.NewMessage(g.method.Output()))
+		done()
+		if err != nil {
+			if batchErr == nil {
+				batchErr = batch.NewError(msg, err)
+			}
+			batchErr.Failed(i, err)
+		}
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+func (g *GRPCClient) writeStream(conn *grpc.ClientConn, msg types.Message, reqs []*dynamicpb.Message) error {
+	ctx, done := g.outgoingContext(message.Lock(msg, 0))
+	defer done()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, g.fullMethod)
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		if err = stream.SendMsg(req); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	// When sending fails the cause is returned by receiving the response.
+	if rErr := stream.RecvMsg(dynamicpb.NewMessage(g.method.Output())); rErr != nil {
+		err = rErr
+	}
+	return err
+}
+
+// CloseAsync shuts down the gRPC output and stops processing messages.
+func (g *GRPCClient) CloseAsync() {
+	g.connMut.Lock()
+	if g.conn != nil {
+		g.conn.Close()
+		g.conn = nil
+	}
+	g.connMut.Unlock()
+}
+
+// WaitForClose blocks until the gRPC output has closed down.
+func (g *GRPCClient) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,288 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+//------------------------------------------------------------------------------
+
+func testGRPCDescriptorSet(t *testing.T) (*descriptorpb.FileDescriptorSet, protoreflect.FileDescriptor) {
+	t.Helper()
+
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Type:     typ.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	method := func(name string, clientStream, serverStream bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".benthos.test.Request"),
+			OutputType:      proto.String(".benthos.test.Response"),
+			ClientStreaming: proto.Bool(clientStream),
+			ServerStreaming: proto.Bool(serverStream),
+		}
+	}
+
+	fileProto := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test.proto"),
+		Package: proto.String("benthos.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Request"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				},
+			},
+			{
+				Name: proto.String("Response"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("count", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("Tester"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					method("Unary", false, false),
+					method("Stream", true, false),
+					method("Watch", false, true),
+				},
+			},
+		},
+	}
+	fileDesc, err := protodesc.NewFile(fileProto, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{fileProto},
+	}, fileDesc
+}
+
+// grpcTestServerCodec adapts grpcDynamicCodec to the server codec interface.
+type grpcTestServerCodec struct {
+	grpcDynamicCodec
+}
+
+func (grpcTestServerCodec) String() string {
+	return "proto"
+}
+
+type grpcTestCall struct {
+	method   string
+	metadata []string
+	requests []string
+}
+
+func startGRPCTestServer(t *testing.T) (string, string, func() []grpcTestCall) {
+	t.Helper()
+
+	set, fileDesc := testGRPCDescriptorSet(t)
+	setBytes, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "benthos_grpc_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	setPath := filepath.Join(dir, "test.pb")
+	if err = ioutil.WriteFile(setPath, setBytes, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reqDesc := fileDesc.Messages().ByName("Request")
+	resDesc := fileDesc.Messages().ByName("Response")
+
+	var callsMut sync.Mutex
+	var calls []grpcTestCall
+
+	server := grpc.NewServer(
+		grpc.CustomCodec(grpcTestServerCodec{}),
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			call := grpcTestCall{method: method, metadata: md.Get("foo")}
+			for {
+				req := dynamicpb.NewMessage(reqDesc)
+				if err := stream.RecvMsg(req); err != nil {
+					if err != io.EOF {
+						return err
+					}
+					break
+				}
+				call.requests = append(call.requests, fmt.Sprintf(
+					"%v:%v",
+					req.Get(reqDesc.Fields().ByName("name")).String(),
+					req.Get(reqDesc.Fields().ByName("count")).Int(),
+				))
+			}
+			callsMut.Lock()
+			calls = append(calls, call)
+			callsMut.Unlock()
+
+			res := dynamicpb.NewMessage(resDesc)
+			res.Set(resDesc.Fields().ByName("count"), protoreflect.ValueOfInt64(int64(len(call.requests))))
+			return stream.SendMsg(res)
+		}),
+	)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String(), setPath, func() []grpcTestCall {
+		callsMut.Lock()
+		defer callsMut.Unlock()
+		return calls
+	}
+}
+
+func TestGRPCClientUnary(t *testing.T) {
+	addr, setPath, getCalls := startGRPCTestServer(t)
+
+	conf := NewGRPCClientConfig()
+	conf.Address = addr
+	conf.Method = "benthos.test.Tester/Unary"
+	conf.DescriptorSet = setPath
+	conf.Metadata = map[string]string{"foo": "${!json_field:name}"}
+
+	w, err := NewGRPCClient(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New(nil)); err == nil {
+		t.Error("Expected error when not connected")
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.CloseAsync()
+
+	err = w.Write(message.New([][]byte{
+		[]byte(`{"name":"a","count":"1"}`),
+		[]byte(`{"nope":"b"}`),
+		[]byte(`{"name":"c"}`),
+	}))
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, received: %v", err)
+	}
+	if exp, act := 1, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of failed messages: %v != %v", act, exp)
+	}
+
+	exp := []grpcTestCall{
+		{method: "/benthos.test.Tester/Unary", metadata: []string{"a"}, requests: []string{"a:1"}},
+		{method: "/benthos.test.Tester/Unary", metadata: []string{"c"}, requests: []string{"c:0"}},
+	}
+	if act := getCalls(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong calls: %+v != %+v", act, exp)
+	}
+}
+
+func TestGRPCClientStream(t *testing.T) {
+	addr, setPath, getCalls := startGRPCTestServer(t)
+
+	conf := NewGRPCClientConfig()
+	conf.Address = addr
+	conf.Method = "/benthos.test.Tester/Stream"
+	conf.DescriptorSet = setPath
+	conf.Metadata = map[string]string{"foo": "bar"}
+
+	w, err := NewGRPCClient(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.CloseAsync()
+
+	if err = w.Write(message.New([][]byte{
+		[]byte(`{"name":"a"}`),
+		[]byte(`{"name":"b"}`),
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []grpcTestCall{
+		{method: "/benthos.test.Tester/Stream", metadata: []string{"bar"}, requests: []string{"a:0", "b:0"}},
+	}
+	if act := getCalls(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong calls: %+v != %+v", act, exp)
+	}
+}
+
+func TestGRPCClientConfigErrors(t *testing.T) {
+	_, setPath, _ := startGRPCTestServer(t)
+
+	for _, method := range []string{
+		"",
+		"benthos.test.Tester",
+		"benthos.test.Nope/Unary",
+		"benthos.test.Request/Unary",
+		"benthos.test.Tester/Nope",
+		"benthos.test.Tester/Watch",
+	} {
+		conf := NewGRPCClientConfig()
+		conf.Method = method
+		conf.DescriptorSet = setPath
+		if _, err := NewGRPCClient(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("Expected error from method: %v", method)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
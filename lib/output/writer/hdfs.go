@@ -21,6 +21,7 @@
 package writer
 
 import (
+	"fmt"
 	"path/filepath"
 	"time"
 
@@ -28,27 +29,32 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kerberos"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
-	"github.com/colinmarc/hdfs"
+	"github.com/colinmarc/hdfs/v2"
 )
 
 //------------------------------------------------------------------------------
 
 // HDFSConfig contains configuration fields for the HDFS output type.
 type HDFSConfig struct {
-	Hosts     []string `json:"hosts" yaml:"hosts"`
-	User      string   `json:"user" yaml:"user"`
-	Directory string   `json:"directory" yaml:"directory"`
-	Path      string   `json:"path" yaml:"path"`
+	Hosts     []string        `json:"hosts" yaml:"hosts"`
+	User      string          `json:"user" yaml:"user"`
+	Directory string          `json:"directory" yaml:"directory"`
+	Path      string          `json:"path" yaml:"path"`
+	Kerberos  kerberos.Config `json:"kerberos" yaml:"kerberos"`
 }
 
 // NewHDFSConfig creates a new Config with default values.
 func NewHDFSConfig() HDFSConfig {
+	kerb := kerberos.NewConfig()
+	kerb.ServicePrincipalName = "nn/_HOST"
 	return HDFSConfig{
 		Hosts:     []string{"localhost:9000"},
 		User:      "benthos_hdfs",
 		Directory: "",
 		Path:      "${!count:files}-${!timestamp_unix_nano}.txt",
+		Kerberos:  kerb,
 	}
 }
 
@@ -91,11 +97,24 @@ func (h *HDFS) Connect() error {
 		return nil
 	}
 
-	client, err := hdfs.NewClient(hdfs.ClientOptions{
+	opts := hdfs.ClientOptions{
 		Addresses: h.conf.Hosts,
 		User:      h.conf.User,
-	})
+	}
+	if h.conf.Kerberos.Enabled {
+		krbClient, err := h.conf.Kerberos.Client()
+		if err != nil {
+			return fmt.Errorf("failed to create kerberos client: %v", err)
+		}
+		opts.KerberosClient = krbClient
+		opts.KerberosServicePrincipleName = h.conf.Kerberos.ServicePrincipalName
+	}
+
+	client, err := hdfs.NewClient(opts)
 	if err != nil {
+		if opts.KerberosClient != nil {
+			opts.KerberosClient.Destroy()
+		}
 		return err
 	}
 
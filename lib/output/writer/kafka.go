@@ -21,16 +21,20 @@
 package writer
 
 import (
+	"compress/gzip"
 	"crypto/tls"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
 	"github.com/Jeffail/benthos/v3/lib/util/retries"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
@@ -48,9 +52,11 @@ type KafkaConfig struct {
 	RoundRobinPartitions bool        `json:"round_robin_partitions" yaml:"round_robin_partitions"`
 	Topic                string      `json:"topic" yaml:"topic"`
 	Compression          string      `json:"compression" yaml:"compression"`
+	CompressionLevel     int         `json:"compression_level" yaml:"compression_level"`
 	MaxMsgBytes          int         `json:"max_msg_bytes" yaml:"max_msg_bytes"`
 	Timeout              string      `json:"timeout" yaml:"timeout"`
 	AckReplicas          bool        `json:"ack_replicas" yaml:"ack_replicas"`
+	IdempotentWrite      bool        `json:"idempotent_write" yaml:"idempotent_write"`
 	TargetVersion        string      `json:"target_version" yaml:"target_version"`
 	TLS                  btls.Config `json:"tls" yaml:"tls"`
 	SASL                 SASLConfig  `json:"sasl" yaml:"sasl"`
@@ -58,11 +64,7 @@ type KafkaConfig struct {
 }
 
 // SASLConfig contains configuration for SASL based authentication.
-type SASLConfig struct {
-	Enabled  bool   `json:"enabled" yaml:"enabled"`
-	User     string `json:"user" yaml:"user"`
-	Password string `json:"password" yaml:"password"`
-}
+type SASLConfig = sasl.Config
 
 // NewKafkaConfig creates a new KafkaConfig with default values.
 func NewKafkaConfig() KafkaConfig {
@@ -77,11 +79,14 @@ func NewKafkaConfig() KafkaConfig {
 		RoundRobinPartitions: false,
 		Topic:                "benthos_stream",
 		Compression:          "none",
+		CompressionLevel:     -1,
 		MaxMsgBytes:          1000000,
 		Timeout:              "5s",
 		AckReplicas:          false,
+		IdempotentWrite:      false,
 		TargetVersion:        sarama.V1_0_0_0.String(),
 		TLS:                  btls.NewConfig(),
+		SASL:                 sasl.NewConfig(),
 		Config:               rConf,
 	}
 }
@@ -95,8 +100,9 @@ type Kafka struct {
 
 	backoff backoff.BackOff
 
-	tlsConf *tls.Config
-	timeout time.Duration
+	tlsConf  *tls.Config
+	saslAuth *sasl.Auth
+	timeout  time.Duration
 
 	addresses []string
 	version   sarama.KafkaVersion
@@ -144,9 +150,27 @@ func NewKafka(conf KafkaConfig, log log.Modular, stats metrics.Type) (*Kafka, er
 		}
 	}
 
+	if k.saslAuth, err = conf.SASL.Get(); err != nil {
+		return nil, err
+	}
+
 	if k.version, err = sarama.ParseKafkaVersion(conf.TargetVersion); err != nil {
 		return nil, err
 	}
+	if compression == sarama.CompressionZSTD && !k.version.IsAtLeast(sarama.V2_1_0_0) {
+		return nil, fmt.Errorf("zstd compression requires a target version of at least 2.1.0, got: %v", conf.TargetVersion)
+	}
+	if conf.CompressionLevel != -1 {
+		if compression != sarama.CompressionGZIP {
+			return nil, fmt.Errorf("compression level is not supported for compression codec: %v", conf.Compression)
+		}
+		if conf.CompressionLevel < gzip.HuffmanOnly || conf.CompressionLevel > gzip.BestCompression {
+			return nil, fmt.Errorf("invalid gzip compression level: %v", conf.CompressionLevel)
+		}
+	}
+	if conf.IdempotentWrite && !k.version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil, fmt.Errorf("idempotent writes require a target version of at least 0.11.0, got: %v", conf.TargetVersion)
+	}
 
 	for _, addr := range conf.Addresses {
 		for _, splitAddr := range strings.Split(addr, ",") {
@@ -174,22 +198,43 @@ func strToCompressionCodec(str string) (sarama.CompressionCodec, error) {
 		return sarama.CompressionLZ4, nil
 	case "gzip":
 		return sarama.CompressionGZIP, nil
+	case "zstd":
+		return sarama.CompressionZSTD, nil
 	}
 	return sarama.CompressionNone, fmt.Errorf("compression codec not recognised: %v", str)
 }
 
 //------------------------------------------------------------------------------
 
+// buildHeaders returns the metadata of a part as record headers, along with the
+// context of its span so that consumers can join the trace. Span headers
+// replace any metadata of the same key, which might have been extracted from
+// an upstream record.
 func buildHeaders(part types.Part) []sarama.RecordHeader {
 	out := []sarama.RecordHeader{}
+	spanHeaders := tracing.InjectSpanTextMap(part)
 	meta := part.Metadata()
 	meta.Iter(func(k, v string) error {
+		if _, exists := spanHeaders[k]; exists {
+			return nil
+		}
 		out = append(out, sarama.RecordHeader{
 			Key:   []byte(k),
 			Value: []byte(v),
 		})
 		return nil
 	})
+	spanKeys := make([]string, 0, len(spanHeaders))
+	for k := range spanHeaders {
+		spanKeys = append(spanKeys, k)
+	}
+	sort.Strings(spanKeys)
+	for _, k := range spanKeys {
+		out = append(out, sarama.RecordHeader{
+			Key:   []byte(k),
+			Value: []byte(spanHeaders[k]),
+		})
+	}
 
 	return out
 }
@@ -211,6 +256,9 @@ func (k *Kafka) Connect() error {
 	config.Version = k.version
 
 	config.Producer.Compression = k.compression
+	if k.conf.CompressionLevel != -1 {
+		config.Producer.CompressionLevel = k.conf.CompressionLevel
+	}
 	config.Producer.MaxMessageBytes = k.conf.MaxMsgBytes
 	config.Producer.Timeout = k.timeout
 	config.Producer.Return.Errors = true
@@ -219,11 +267,7 @@ func (k *Kafka) Connect() error {
 	if k.conf.TLS.Enabled {
 		config.Net.TLS.Config = k.tlsConf
 	}
-	if k.conf.SASL.Enabled {
-		config.Net.SASL.Enable = true
-		config.Net.SASL.User = k.conf.SASL.User
-		config.Net.SASL.Password = k.conf.SASL.Password
-	}
+	k.saslAuth.Apply(config)
 
 	if k.conf.RoundRobinPartitions {
 		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
@@ -235,6 +279,14 @@ func (k *Kafka) Connect() error {
 		config.Producer.RequiredAcks = sarama.WaitForLocal
 	}
 
+	if k.conf.IdempotentWrite {
+		// Idempotent producers require acknowledgements from all replicas and
+		// a single in-flight request per broker in order to preserve ordering.
+		config.Producer.Idempotent = true
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		config.Net.MaxOpenRequests = 1
+	}
+
 	var err error
 	k.producer, err = sarama.NewSyncProducer(k.addresses, config)
 
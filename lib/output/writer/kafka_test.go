@@ -0,0 +1,86 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+func TestKafkaConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    func(c *KafkaConfig)
+		wantErr bool
+	}{
+		{name: "defaults", conf: func(c *KafkaConfig) {}},
+		{name: "zstd", conf: func(c *KafkaConfig) {
+			c.Compression = "zstd"
+			c.TargetVersion = "2.1.0"
+		}},
+		{name: "zstd old version", conf: func(c *KafkaConfig) {
+			c.Compression = "zstd"
+		}, wantErr: true},
+		{name: "unknown codec", conf: func(c *KafkaConfig) {
+			c.Compression = "brotli"
+		}, wantErr: true},
+		{name: "gzip level", conf: func(c *KafkaConfig) {
+			c.Compression = "gzip"
+			c.CompressionLevel = 9
+		}},
+		{name: "gzip bad level", conf: func(c *KafkaConfig) {
+			c.Compression = "gzip"
+			c.CompressionLevel = 10
+		}, wantErr: true},
+		{name: "lz4 level", conf: func(c *KafkaConfig) {
+			c.Compression = "lz4"
+			c.CompressionLevel = 4
+		}, wantErr: true},
+		{name: "idempotent", conf: func(c *KafkaConfig) {
+			c.IdempotentWrite = true
+		}},
+		{name: "idempotent old version", conf: func(c *KafkaConfig) {
+			c.IdempotentWrite = true
+			c.TargetVersion = "0.10.2.0"
+		}, wantErr: true},
+		{name: "sasl bad mechanism", conf: func(c *KafkaConfig) {
+			c.SASL.Enabled = true
+			c.SASL.Mechanism = "nope"
+		}, wantErr: true},
+		{name: "sasl missing access token", conf: func(c *KafkaConfig) {
+			c.SASL.Enabled = true
+			c.SASL.Mechanism = "OAUTHBEARER"
+		}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		conf := NewKafkaConfig()
+		test.conf(&conf)
+		_, err := NewKafka(conf, log.Noop(), metrics.Noop())
+		if test.wantErr && err == nil {
+			t.Errorf("%v: expected error", test.name)
+		} else if !test.wantErr && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		}
+	}
+}
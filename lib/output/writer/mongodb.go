@@ -0,0 +1,379 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+//------------------------------------------------------------------------------
+
+// MongoDBWriteConcernConfig contains configuration fields for the write
+// concern of a MongoDB output.
+type MongoDBWriteConcernConfig struct {
+	W        string `json:"w" yaml:"w"`
+	J        bool   `json:"j" yaml:"j"`
+	WTimeout string `json:"w_timeout" yaml:"w_timeout"`
+}
+
+// MongoDBConfig contains configuration fields for the MongoDB output type.
+type MongoDBConfig struct {
+	URL          string                    `json:"url" yaml:"url"`
+	Database     string                    `json:"database" yaml:"database"`
+	Collection   string                    `json:"collection" yaml:"collection"`
+	Username     string                    `json:"username" yaml:"username"`
+	Password     string                    `json:"password" yaml:"password"`
+	Operation    string                    `json:"operation" yaml:"operation"`
+	Document     string                    `json:"document" yaml:"document"`
+	Filter       string                    `json:"filter" yaml:"filter"`
+	Upsert       bool                      `json:"upsert" yaml:"upsert"`
+	Ordered      bool                      `json:"ordered" yaml:"ordered"`
+	WriteConcern MongoDBWriteConcernConfig `json:"write_concern" yaml:"write_concern"`
+	Timeout      string                    `json:"timeout" yaml:"timeout"`
+}
+
+// NewMongoDBConfig creates a new MongoDBConfig with default values.
+func NewMongoDBConfig() MongoDBConfig {
+	return MongoDBConfig{
+		URL:        "mongodb://localhost:27017",
+		Database:   "",
+		Collection: "",
+		Username:   "",
+		Password:   "",
+		Operation:  "insert-one",
+		Document:   "${!content}",
+		Filter:     "",
+		Upsert:     false,
+		Ordered:    true,
+		WriteConcern: MongoDBWriteConcernConfig{
+			W:        "",
+			J:        false,
+			WTimeout: "",
+		},
+		Timeout: "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// MongoDB operations that can be performed for each message.
+const (
+	mongoInsertOne  = "insert-one"
+	mongoUpdateOne  = "update-one"
+	mongoUpdateMany = "update-many"
+	mongoReplaceOne = "replace-one"
+	mongoDeleteOne  = "delete-one"
+	mongoDeleteMany = "delete-many"
+)
+
+// writeConcern creates a write concern from its config, returning nil when no
+// write concern is configured in order to use the defaults of the server.
+func (c MongoDBWriteConcernConfig) writeConcern() (*writeconcern.WriteConcern, error) {
+	var opts []writeconcern.Option
+	switch c.W {
+	case "":
+	case "majority":
+		opts = append(opts, writeconcern.WMajority())
+	default:
+		if w, err := strconv.Atoi(c.W); err == nil {
+			opts = append(opts, writeconcern.W(w))
+		} else {
+			opts = append(opts, writeconcern.WTagSet(c.W))
+		}
+	}
+	if c.J {
+		opts = append(opts, writeconcern.J(true))
+	}
+	if len(c.WTimeout) > 0 {
+		d, err := time.ParseDuration(c.WTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse w_timeout: %v", err)
+		}
+		opts = append(opts, writeconcern.WTimeout(d))
+	}
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	return writeconcern.New(opts...), nil
+}
+
+//------------------------------------------------------------------------------
+
+// MongoDB is a benthos writer.Type implementation that writes messages to a
+// MongoDB collection as a bulk write.
+type MongoDB struct {
+	conf    MongoDBConfig
+	timeout time.Duration
+	wc      *writeconcern.WriteConcern
+
+	document *text.InterpolatedString
+	filter   *text.InterpolatedString
+
+	client     *mongo.Client
+	collection *mongo.Collection
+	connMut    sync.RWMutex
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewMongoDB creates a new MongoDB writer.Type.
+func NewMongoDB(
+	conf MongoDBConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*MongoDB, error) {
+	if len(conf.Database) == 0 {
+		return nil, errors.New("a database must be specified")
+	}
+	if len(conf.Collection) == 0 {
+		return nil, errors.New("a collection must be specified")
+	}
+
+	needsDocument, needsFilter := false, false
+	switch conf.Operation {
+	case mongoInsertOne:
+		needsDocument = true
+	case mongoUpdateOne, mongoUpdateMany, mongoReplaceOne:
+		needsDocument, needsFilter = true, true
+	case mongoDeleteOne, mongoDeleteMany:
+		needsFilter = true
+	default:
+		return nil, fmt.Errorf("operation not recognised: %v", conf.Operation)
+	}
+	if needsDocument != (len(conf.Document) > 0) {
+		if needsDocument {
+			return nil, fmt.Errorf("a document must be specified for %v operations", conf.Operation)
+		}
+		return nil, fmt.Errorf("a document cannot be specified for %v operations", conf.Operation)
+	}
+	if needsFilter != (len(conf.Filter) > 0) {
+		if needsFilter {
+			return nil, fmt.Errorf("a filter must be specified for %v operations", conf.Operation)
+		}
+		return nil, fmt.Errorf("a filter cannot be specified for %v operations", conf.Operation)
+	}
+	if conf.Upsert && (!needsDocument || !needsFilter) {
+		return nil, fmt.Errorf("upsert cannot be enabled for %v operations", conf.Operation)
+	}
+
+	m := &MongoDB{
+		conf:     conf,
+		document: text.NewInterpolatedString(conf.Document),
+		filter:   text.NewInterpolatedString(conf.Filter),
+		log:      log,
+		stats:    stats,
+	}
+
+	var err error
+	if m.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if m.wc, err = conf.WriteConcern.writeConcern(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a connection to the MongoDB server.
+func (m *MongoDB) Connect() error {
+	m.connMut.Lock()
+	defer m.connMut.Unlock()
+	if m.client != nil {
+		return nil
+	}
+
+	opts := options.Client().ApplyURI(m.conf.URL)
+	if len(m.conf.Username) > 0 {
+		opts = opts.SetAuth(options.Credential{
+			Username: m.conf.Username,
+			Password: m.conf.Password,
+		})
+	}
+	client, err := mongo.NewClient(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), m.timeout)
+	defer done()
+	if err = client.Connect(ctx); err != nil {
+		return err
+	}
+	if err = client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return err
+	}
+
+	dbOpts := options.Database()
+	if m.wc != nil {
+		dbOpts = dbOpts.SetWriteConcern(m.wc)
+	}
+	m.client = client
+	m.collection = client.Database(m.conf.Database, dbOpts).Collection(m.conf.Collection)
+
+	m.log.Infof("Writing messages to MongoDB collection: %v.%v\n", m.conf.Database, m.conf.Collection)
+	return nil
+}
+
+// parseDocument parses an extended JSON document resolved from an interpolated
+// string.
+func parseDocument(s *text.InterpolatedString, msg types.Message) (bson.D, error) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON([]byte(s.Get(msg)), false, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// writeModel creates the write model of the operation for a message of a
+// batch.
+func (m *MongoDB) writeModel(msg types.Message, index int) (mongo.WriteModel, error) {
+	lMsg := message.Lock(msg, index)
+
+	var doc, filter bson.D
+	var err error
+	if len(m.conf.Document) > 0 {
+		if doc, err = parseDocument(m.document, lMsg); err != nil {
+			return nil, fmt.Errorf("failed to parse document: %v", err)
+		}
+	}
+	if len(m.conf.Filter) > 0 {
+		if filter, err = parseDocument(m.filter, lMsg); err != nil {
+			return nil, fmt.Errorf("failed to parse filter: %v", err)
+		}
+	}
+
+	switch m.conf.Operation {
+	case mongoInsertOne:
+		return mongo.NewInsertOneModel().SetDocument(doc), nil
+	case mongoUpdateOne:
+		return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(doc).SetUpsert(m.conf.Upsert), nil
+	case mongoUpdateMany:
+		return mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(doc).SetUpsert(m.conf.Upsert), nil
+	case mongoReplaceOne:
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(m.conf.Upsert), nil
+	case mongoDeleteOne:
+		return mongo.NewDeleteOneModel().SetFilter(filter), nil
+	}
+	return mongo.NewDeleteManyModel().SetFilter(filter), nil
+}
+
+// Write attempts to write a message batch to the target collection as a single
+// bulk write, where messages that fail are reported with a batch.Error.
+func (m *MongoDB) Write(msg types.Message) error {
+	m.connMut.RLock()
+	collection := m.collection
+	m.connMut.RUnlock()
+
+	if collection == nil {
+		return types.ErrNotConnected
+	}
+
+	var batchErr *batch.Error
+	failed := func(i int, err error) {
+		if batchErr == nil {
+			batchErr = batch.NewError(msg, err)
+		}
+		batchErr.Failed(i, err)
+	}
+
+	var models []mongo.WriteModel
+	var indexes []int
+	msg.Iter(func(i int, p types.Part) error {
+		model, err := m.writeModel(msg, i)
+		if err != nil {
+			m.log.Errorf("Failed to create write model: %v\n", err)
+			failed(i, err)
+			return nil
+		}
+		models = append(models, model)
+		indexes = append(indexes, i)
+		return nil
+	})
+	if len(models) == 0 {
+		return batchErr
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), m.timeout)
+	defer done()
+
+	_, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(m.conf.Ordered))
+	if err != nil {
+		bwErr, ok := err.(mongo.BulkWriteException)
+		if !ok || bwErr.WriteConcernError != nil || len(bwErr.WriteErrors) == 0 {
+			return err
+		}
+		for _, wErr := range bwErr.WriteErrors {
+			failed(indexes[wErr.Index], wErr)
+			if m.conf.Ordered {
+				// An ordered bulk write stops at the first error, and therefore
+				// all subsequent writes have also failed.
+				for _, i := range indexes[wErr.Index+1:] {
+					failed(i, err)
+				}
+				break
+			}
+		}
+	}
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (m *MongoDB) CloseAsync() {
+	m.connMut.Lock()
+	if m.client != nil {
+		m.client.Disconnect(context.Background())
+		m.client = nil
+		m.collection = nil
+	}
+	m.connMut.Unlock()
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (m *MongoDB) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
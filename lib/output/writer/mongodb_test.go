@@ -0,0 +1,207 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+//------------------------------------------------------------------------------
+
+func TestMongoDBWriteModels(t *testing.T) {
+	msg := message.New([][]byte{[]byte(`{"id":"foo","count":{"$numberInt":"5"}}`)})
+	msg.Get(0).Metadata().Set("id", "foo")
+
+	doc := bson.D{{Key: "id", Value: "foo"}, {Key: "count", Value: int32(5)}}
+	filter := bson.D{{Key: "_id", Value: "foo"}}
+
+	tests := map[string]struct {
+		operation string
+		document  string
+		filter    string
+		upsert    bool
+		exp       mongo.WriteModel
+	}{
+		"insert one": {
+			operation: "insert-one",
+			document:  "${!content}",
+			exp:       mongo.NewInsertOneModel().SetDocument(doc),
+		},
+		"update one": {
+			operation: "update-one",
+			document:  `{"$set":{"count":10}}`,
+			filter:    `{"_id":"${!metadata:id}"}`,
+			upsert:    true,
+			exp: mongo.NewUpdateOneModel().
+				SetFilter(filter).
+				SetUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: "count", Value: int32(10)}}}}).
+				SetUpsert(true),
+		},
+		"update many": {
+			operation: "update-many",
+			document:  `{"$inc":{"count":1}}`,
+			filter:    `{"_id":"${!metadata:id}"}`,
+			exp: mongo.NewUpdateManyModel().
+				SetFilter(filter).
+				SetUpdate(bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: int32(1)}}}}).
+				SetUpsert(false),
+		},
+		"replace one": {
+			operation: "replace-one",
+			document:  "${!content}",
+			filter:    `{"_id":"${!metadata:id}"}`,
+			exp:       mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(false),
+		},
+		"delete one": {
+			operation: "delete-one",
+			filter:    `{"_id":"${!metadata:id}"}`,
+			exp:       mongo.NewDeleteOneModel().SetFilter(filter),
+		},
+		"delete many": {
+			operation: "delete-many",
+			filter:    `{"_id":"${!metadata:id}"}`,
+			exp:       mongo.NewDeleteManyModel().SetFilter(filter),
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewMongoDBConfig()
+		conf.Database = "foo"
+		conf.Collection = "bar"
+		conf.Operation = test.operation
+		conf.Document = test.document
+		conf.Filter = test.filter
+		conf.Upsert = test.upsert
+
+		m, err := NewMongoDB(conf, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		act, err := m.writeModel(msg, 0)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !reflect.DeepEqual(test.exp, act) {
+			t.Errorf("%v: Wrong write model: %#v != %#v", name, act, test.exp)
+		}
+	}
+}
+
+func TestMongoDBWriteModelBadDocument(t *testing.T) {
+	conf := NewMongoDBConfig()
+	conf.Database = "foo"
+	conf.Collection = "bar"
+
+	m, err := NewMongoDB(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.writeModel(message.New([][]byte{[]byte("not json")}), 0); err == nil {
+		t.Error("Expected error from bad document")
+	}
+	if err = m.Write(message.New([][]byte{[]byte("{}")})); err != types.ErrNotConnected {
+		t.Errorf("Expected not connected error, received: %v", err)
+	}
+}
+
+func TestMongoDBWriteConcern(t *testing.T) {
+	tests := map[string]struct {
+		conf MongoDBWriteConcernConfig
+		exp  *writeconcern.WriteConcern
+	}{
+		"empty": {},
+		"majority": {
+			conf: MongoDBWriteConcernConfig{W: "majority", J: true, WTimeout: "5s"},
+			exp:  writeconcern.New(writeconcern.WMajority(), writeconcern.J(true), writeconcern.WTimeout(time.Second*5)),
+		},
+		"number": {
+			conf: MongoDBWriteConcernConfig{W: "2"},
+			exp:  writeconcern.New(writeconcern.W(2)),
+		},
+		"tag": {
+			conf: MongoDBWriteConcernConfig{W: "east"},
+			exp:  writeconcern.New(writeconcern.WTagSet("east")),
+		},
+	}
+
+	for name, test := range tests {
+		act, err := test.conf.writeConcern()
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !reflect.DeepEqual(test.exp, act) {
+			t.Errorf("%v: Wrong write concern: %v != %v", name, act, test.exp)
+		}
+	}
+
+	if _, err := (MongoDBWriteConcernConfig{WTimeout: "nope"}).writeConcern(); err == nil {
+		t.Error("Expected error from bad w_timeout")
+	}
+}
+
+func TestMongoDBConfigErrors(t *testing.T) {
+	tests := map[string]func(c *MongoDBConfig){
+		"no database": func(c *MongoDBConfig) {
+			c.Database = ""
+		},
+		"no collection": func(c *MongoDBConfig) {
+			c.Collection = ""
+		},
+		"bad operation": func(c *MongoDBConfig) {
+			c.Operation = "nope"
+		},
+		"update without filter": func(c *MongoDBConfig) {
+			c.Operation = "update-one"
+		},
+		"delete with document": func(c *MongoDBConfig) {
+			c.Operation = "delete-one"
+			c.Filter = `{}`
+		},
+		"insert with upsert": func(c *MongoDBConfig) {
+			c.Upsert = true
+		},
+		"bad timeout": func(c *MongoDBConfig) {
+			c.Timeout = "nope"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewMongoDBConfig()
+		conf.Database = "foo"
+		conf.Collection = "bar"
+		fn(&conf)
+		if _, err := NewMongoDB(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
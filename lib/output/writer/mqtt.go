@@ -21,6 +21,7 @@
 package writer
 
 import (
+	"context"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +29,8 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bmqtt "github.com/Jeffail/benthos/v3/lib/util/mqtt"
+	"github.com/eclipse/paho.golang/paho"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -35,23 +38,25 @@ import (
 
 // MQTTConfig contains configuration fields for the MQTT output type.
 type MQTTConfig struct {
-	URLs     []string `json:"urls" yaml:"urls"`
-	QoS      uint8    `json:"qos" yaml:"qos"`
-	Topic    string   `json:"topic" yaml:"topic"`
-	ClientID string   `json:"client_id" yaml:"client_id"`
-	User     string   `json:"user" yaml:"user"`
-	Password string   `json:"password" yaml:"password"`
+	URLs            []string `json:"urls" yaml:"urls"`
+	ProtocolVersion string   `json:"protocol_version" yaml:"protocol_version"`
+	QoS             uint8    `json:"qos" yaml:"qos"`
+	Topic           string   `json:"topic" yaml:"topic"`
+	ClientID        string   `json:"client_id" yaml:"client_id"`
+	User            string   `json:"user" yaml:"user"`
+	Password        string   `json:"password" yaml:"password"`
 }
 
 // NewMQTTConfig creates a new MQTTConfig with default values.
 func NewMQTTConfig() MQTTConfig {
 	return MQTTConfig{
-		URLs:     []string{"tcp://localhost:1883"},
-		QoS:      1,
-		Topic:    "benthos_topic",
-		ClientID: "benthos_output",
-		User:     "",
-		Password: "",
+		URLs:            []string{"tcp://localhost:1883"},
+		ProtocolVersion: "3.1.1",
+		QoS:             1,
+		Topic:           "benthos_topic",
+		ClientID:        "benthos_output",
+		User:            "",
+		Password:        "",
 	}
 }
 
@@ -64,9 +69,11 @@ type MQTT struct {
 
 	urls []string
 	conf MQTTConfig
+	v5   bool
 
-	client  mqtt.Client
-	connMut sync.RWMutex
+	client   mqtt.Client
+	clientV5 *bmqtt.V5Client
+	connMut  sync.RWMutex
 }
 
 // NewMQTT creates a new MQTT output type.
@@ -81,6 +88,11 @@ func NewMQTT(
 		conf:  conf,
 	}
 
+	var err error
+	if m.v5, err = bmqtt.IsV5(conf.ProtocolVersion); err != nil {
+		return nil, err
+	}
+
 	for _, u := range conf.URLs {
 		for _, splitURL := range strings.Split(u, ",") {
 			if len(splitURL) > 0 {
@@ -99,7 +111,24 @@ func (m *MQTT) Connect() error {
 	m.connMut.Lock()
 	defer m.connMut.Unlock()
 
-	if m.client != nil {
+	if m.client != nil || m.clientV5 != nil {
+		return nil
+	}
+
+	if m.v5 {
+		client, err := bmqtt.ConnectV5(context.Background(), bmqtt.V5Options{
+			URLs:           m.urls,
+			ClientID:       m.conf.ClientID,
+			User:           m.conf.User,
+			Password:       m.conf.Password,
+			CleanStart:     true,
+			KeepAlive:      time.Second * 30,
+			ConnectTimeout: time.Second,
+		})
+		if err != nil {
+			return err
+		}
+		m.clientV5 = client
 		return nil
 	}
 
@@ -138,9 +167,12 @@ func (m *MQTT) Connect() error {
 // Write attempts to write a message by pushing it to an MQTT broker.
 func (m *MQTT) Write(msg types.Message) error {
 	m.connMut.RLock()
-	client := m.client
+	client, clientV5 := m.client, m.clientV5
 	m.connMut.RUnlock()
 
+	if clientV5 != nil {
+		return m.writeV5(clientV5, msg)
+	}
 	if client == nil {
 		return types.ErrNotConnected
 	}
@@ -152,6 +184,36 @@ func (m *MQTT) Write(msg types.Message) error {
 	})
 }
 
+func (m *MQTT) writeV5(client *bmqtt.V5Client, msg types.Message) error {
+	return msg.Iter(func(i int, p types.Part) error {
+		select {
+		case <-client.Done():
+			m.connMut.Lock()
+			if m.clientV5 == client {
+				m.clientV5 = nil
+			}
+			m.connMut.Unlock()
+			return types.ErrNotConnected
+		default:
+		}
+
+		props := &paho.PublishProperties{
+			User: map[string]string{},
+		}
+		p.Metadata().Iter(func(k, v string) error {
+			props.User[k] = v
+			return nil
+		})
+		_, err := client.Publish(context.Background(), &paho.Publish{
+			QoS:        byte(m.conf.QoS),
+			Topic:      m.conf.Topic,
+			Properties: props,
+			Payload:    p.Get(),
+		})
+		return err
+	})
+}
+
 // CloseAsync shuts down the MQTT output and stops processing messages.
 func (m *MQTT) CloseAsync() {
 	m.connMut.Lock()
@@ -159,6 +221,10 @@ func (m *MQTT) CloseAsync() {
 		m.client.Disconnect(0)
 		m.client = nil
 	}
+	if m.clientV5 != nil {
+		m.clientV5.Disconnect(&paho.Disconnect{})
+		m.clientV5 = nil
+	}
 	m.connMut.Unlock()
 }
 
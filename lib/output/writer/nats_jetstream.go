@@ -0,0 +1,217 @@
+// Copyright (c) 2014 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"github.com/nats-io/nats.go"
+)
+
+//------------------------------------------------------------------------------
+
+// NATSJetStreamConfig contains configuration fields for the NATS JetStream
+// output type.
+type NATSJetStreamConfig struct {
+	URLs       []string          `json:"urls" yaml:"urls"`
+	Subject    string            `json:"subject" yaml:"subject"`
+	MsgID      string            `json:"msg_id" yaml:"msg_id"`
+	Headers    map[string]string `json:"headers" yaml:"headers"`
+	AckTimeout string            `json:"ack_timeout" yaml:"ack_timeout"`
+}
+
+// NewNATSJetStreamConfig creates a new NATSJetStreamConfig with default values.
+func NewNATSJetStreamConfig() NATSJetStreamConfig {
+	return NATSJetStreamConfig{
+		URLs:       []string{nats.DefaultURL},
+		Subject:    "benthos_messages",
+		MsgID:      "",
+		Headers:    map[string]string{},
+		AckTimeout: "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NATSJetStream is an output type that publishes messages to a NATS JetStream
+// stream and waits for them to be acknowledged.
+type NATSJetStream struct {
+	log log.Modular
+
+	natsConn *nats.Conn
+	js       nats.JetStreamContext
+	connMut  sync.RWMutex
+
+	urls       string
+	conf       NATSJetStreamConfig
+	ackTimeout time.Duration
+	subjectStr *text.InterpolatedString
+	msgIDStr   *text.InterpolatedString
+	headers    map[string]*text.InterpolatedString
+}
+
+// NewNATSJetStream creates a new NATS JetStream output type.
+func NewNATSJetStream(conf NATSJetStreamConfig, log log.Modular, stats metrics.Type) (*NATSJetStream, error) {
+	n := NATSJetStream{
+		log:        log,
+		conf:       conf,
+		subjectStr: text.NewInterpolatedString(conf.Subject),
+		msgIDStr:   text.NewInterpolatedString(conf.MsgID),
+		headers:    map[string]*text.InterpolatedString{},
+	}
+	n.urls = strings.Join(conf.URLs, ",")
+	for k, v := range conf.Headers {
+		n.headers[k] = text.NewInterpolatedString(v)
+	}
+
+	var err error
+	if n.ackTimeout, err = time.ParseDuration(conf.AckTimeout); err != nil {
+		return nil, fmt.Errorf("failed to parse ack_timeout: %v", err)
+	}
+	return &n, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a connection to NATS servers.
+func (n *NATSJetStream) Connect() error {
+	n.connMut.Lock()
+	defer n.connMut.Unlock()
+
+	if n.natsConn != nil {
+		return nil
+	}
+
+	natsConn, err := nats.Connect(n.urls)
+	if err != nil {
+		return err
+	}
+	js, err := natsConn.JetStream()
+	if err != nil {
+		natsConn.Close()
+		return err
+	}
+
+	n.natsConn, n.js = natsConn, js
+	n.log.Infof("Sending NATS JetStream messages to subject: %v\n", n.conf.Subject)
+	return nil
+}
+
+// Write attempts to write a message. Each message of a batch is published
+// asynchronously and the write only succeeds once all of them have been
+// acknowledged by the server. Messages that are not acknowledged within the
+// ack timeout are reported as failed.
+func (n *NATSJetStream) Write(msg types.Message) error {
+	n.connMut.RLock()
+	conn, js := n.natsConn, n.js
+	n.connMut.RUnlock()
+
+	if conn == nil {
+		return types.ErrNotConnected
+	}
+
+	var batchErr *batch.Error
+	failed := func(i int, err error) {
+		if batchErr == nil {
+			batchErr = batch.NewError(msg, err)
+		}
+		batchErr.Failed(i, err)
+	}
+
+	futures := make([]nats.PubAckFuture, msg.Len())
+	msg.Iter(func(i int, p types.Part) error {
+		lMsg := message.Lock(msg, i)
+
+		nMsg := nats.NewMsg(n.subjectStr.Get(lMsg))
+		nMsg.Data = p.Get()
+		for k, v := range n.headers {
+			nMsg.Header.Set(k, v.Get(lMsg))
+		}
+
+		var opts []nats.PubOpt
+		if id := n.msgIDStr.Get(lMsg); len(id) > 0 {
+			opts = append(opts, nats.MsgId(id))
+		}
+
+		n.log.Debugf("Writing NATS JetStream message to subject %s", nMsg.Subject)
+		future, err := js.PublishMsgAsync(nMsg, opts...)
+		if err != nil {
+			failed(i, err)
+			return nil
+		}
+		futures[i] = future
+		return nil
+	})
+
+	timeout := time.After(n.ackTimeout)
+	for i, future := range futures {
+		if future == nil {
+			continue
+		}
+		select {
+		case <-future.Ok():
+		case err := <-future.Err():
+			failed(i, err)
+		case <-timeout:
+			failed(i, nats.ErrTimeout)
+		}
+	}
+
+	if conn.IsClosed() {
+		n.connMut.Lock()
+		if n.natsConn == conn {
+			n.natsConn, n.js = nil, nil
+		}
+		n.connMut.Unlock()
+		return types.ErrNotConnected
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// CloseAsync shuts down the NATS JetStream output and stops processing
+// messages.
+func (n *NATSJetStream) CloseAsync() {
+	n.connMut.Lock()
+	if n.natsConn != nil {
+		n.natsConn.Close()
+		n.natsConn, n.js = nil, nil
+	}
+	n.connMut.Unlock()
+}
+
+// WaitForClose blocks until the NATS JetStream output has closed down.
+func (n *NATSJetStream) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
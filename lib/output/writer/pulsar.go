@@ -0,0 +1,235 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	bpulsar "github.com/Jeffail/benthos/v3/lib/util/pulsar"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+//------------------------------------------------------------------------------
+
+// PulsarBatchingConfig contains configuration fields for the batching of
+// messages performed by a Pulsar producer.
+type PulsarBatchingConfig struct {
+	Enabled         bool   `json:"enabled" yaml:"enabled"`
+	KeyBased        bool   `json:"key_based" yaml:"key_based"`
+	MaxMessages     uint   `json:"max_messages" yaml:"max_messages"`
+	MaxPublishDelay string `json:"max_publish_delay" yaml:"max_publish_delay"`
+}
+
+// PulsarConfig contains configuration fields for the Pulsar output type.
+type PulsarConfig struct {
+	bpulsar.ClientConfig `json:",inline" yaml:",inline"`
+	Topic                string               `json:"topic" yaml:"topic"`
+	Key                  string               `json:"key" yaml:"key"`
+	OrderingKey          string               `json:"ordering_key" yaml:"ordering_key"`
+	Timeout              string               `json:"timeout" yaml:"timeout"`
+	Batching             PulsarBatchingConfig `json:"producer_batching" yaml:"producer_batching"`
+}
+
+// NewPulsarConfig creates a new PulsarConfig with default values.
+func NewPulsarConfig() PulsarConfig {
+	return PulsarConfig{
+		ClientConfig: bpulsar.NewClientConfig(),
+		Topic:        "benthos_stream",
+		Key:          "",
+		OrderingKey:  "",
+		Timeout:      "30s",
+		Batching: PulsarBatchingConfig{
+			Enabled:         true,
+			KeyBased:        false,
+			MaxMessages:     1000,
+			MaxPublishDelay: "10ms",
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Pulsar is a writer type that writes messages to an Apache Pulsar topic.
+type Pulsar struct {
+	conf PulsarConfig
+	log  log.Modular
+
+	key         *text.InterpolatedString
+	orderingKey *text.InterpolatedString
+
+	timeout      time.Duration
+	publishDelay time.Duration
+
+	client   pulsar.Client
+	producer pulsar.Producer
+	connMut  sync.RWMutex
+}
+
+// NewPulsar creates a new Pulsar writer type.
+func NewPulsar(conf PulsarConfig, log log.Modular, stats metrics.Type) (*Pulsar, error) {
+	if len(conf.Topic) == 0 {
+		return nil, errors.New("a topic must be provided")
+	}
+	if _, err := conf.Options(log); err != nil {
+		return nil, err
+	}
+
+	p := Pulsar{
+		conf:        conf,
+		log:         log,
+		key:         text.NewInterpolatedString(conf.Key),
+		orderingKey: text.NewInterpolatedString(conf.OrderingKey),
+	}
+
+	var err error
+	if tout := conf.Timeout; len(tout) > 0 {
+		if p.timeout, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse timeout string: %v", err)
+		}
+	}
+	if delay := conf.Batching.MaxPublishDelay; len(delay) > 0 {
+		if p.publishDelay, err = time.ParseDuration(delay); err != nil {
+			return nil, fmt.Errorf("failed to parse max publish delay string: %v", err)
+		}
+	}
+	return &p, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to create a producer for the Pulsar topic.
+func (p *Pulsar) Connect() error {
+	p.connMut.Lock()
+	defer p.connMut.Unlock()
+
+	if p.producer != nil {
+		return nil
+	}
+
+	opts, err := p.conf.Options(p.log)
+	if err != nil {
+		return err
+	}
+	client, err := pulsar.NewClient(opts)
+	if err != nil {
+		return err
+	}
+
+	pOpts := pulsar.ProducerOptions{
+		Topic:                   p.conf.Topic,
+		SendTimeout:             p.timeout,
+		DisableBatching:         !p.conf.Batching.Enabled,
+		BatchingMaxMessages:     p.conf.Batching.MaxMessages,
+		BatchingMaxPublishDelay: p.publishDelay,
+	}
+	if p.conf.Batching.KeyBased {
+		pOpts.BatcherBuilderType = pulsar.KeyBasedBatchBuilder
+	}
+
+	producer, err := client.CreateProducer(pOpts)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	p.client = client
+	p.producer = producer
+	p.log.Infof("Sending Pulsar messages to topic: %v\n", p.conf.Topic)
+	return nil
+}
+
+// Write attempts to write a message to the Pulsar topic, and blocks until all
+// parts of the message have been acknowledged by the broker.
+func (p *Pulsar) Write(msg types.Message) error {
+	p.connMut.RLock()
+	producer := p.producer
+	p.connMut.RUnlock()
+
+	if producer == nil {
+		return types.ErrNotConnected
+	}
+
+	var errMut sync.Mutex
+	var sendErr error
+
+	wg := sync.WaitGroup{}
+	wg.Add(msg.Len())
+
+	msg.Iter(func(i int, part types.Part) error {
+		lMsg := message.Lock(msg, i)
+
+		props := map[string]string{}
+		part.Metadata().Iter(func(k, v string) error {
+			props[k] = v
+			return nil
+		})
+
+		producer.SendAsync(context.Background(), &pulsar.ProducerMessage{
+			Payload:     part.Get(),
+			Key:         p.key.Get(lMsg),
+			OrderingKey: p.orderingKey.Get(lMsg),
+			Properties:  props,
+		}, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+			if err != nil {
+				errMut.Lock()
+				sendErr = err
+				errMut.Unlock()
+			}
+			wg.Done()
+		})
+		return nil
+	})
+
+	wg.Wait()
+	return sendErr
+}
+
+// CloseAsync shuts down the Pulsar output and stops processing messages.
+func (p *Pulsar) CloseAsync() {
+	go func() {
+		p.connMut.Lock()
+		if p.producer != nil {
+			p.producer.Close()
+			p.producer = nil
+		}
+		if p.client != nil {
+			p.client.Close()
+			p.client = nil
+		}
+		p.connMut.Unlock()
+	}()
+}
+
+// WaitForClose blocks until the Pulsar output has closed down.
+func (p *Pulsar) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -21,13 +21,19 @@
 package writer
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/go-redis/redis"
 )
 
@@ -39,6 +45,7 @@ type RedisStreamsConfig struct {
 	Stream       string `json:"stream" yaml:"stream"`
 	BodyKey      string `json:"body_key" yaml:"body_key"`
 	MaxLenApprox int64  `json:"max_length" yaml:"max_length"`
+	MaxAge       string `json:"max_age" yaml:"max_age"`
 }
 
 // NewRedisStreamsConfig creates a new RedisStreamsConfig with default values.
@@ -48,6 +55,7 @@ func NewRedisStreamsConfig() RedisStreamsConfig {
 		Stream:       "benthos_stream",
 		BodyKey:      "body",
 		MaxLenApprox: 0,
+		MaxAge:       "",
 	}
 }
 
@@ -58,8 +66,10 @@ type RedisStreams struct {
 	log   log.Modular
 	stats metrics.Type
 
-	url  *url.URL
-	conf RedisStreamsConfig
+	url    *url.URL
+	conf   RedisStreamsConfig
+	stream *text.InterpolatedString
+	maxAge time.Duration
 
 	client  *redis.Client
 	connMut sync.RWMutex
@@ -73,9 +83,10 @@ func NewRedisStreams(
 ) (*RedisStreams, error) {
 
 	r := &RedisStreams{
-		log:   log,
-		stats: stats,
-		conf:  conf,
+		log:    log,
+		stats:  stats,
+		conf:   conf,
+		stream: text.NewInterpolatedString(conf.Stream),
 	}
 
 	var err error
@@ -84,6 +95,18 @@ func NewRedisStreams(
 		return nil, err
 	}
 
+	if len(conf.MaxAge) > 0 {
+		if conf.MaxLenApprox > 0 {
+			return nil, errors.New("only one of max_length and max_age can be set")
+		}
+		if r.maxAge, err = time.ParseDuration(conf.MaxAge); err != nil {
+			return nil, fmt.Errorf("failed to parse max_age: %v", err)
+		}
+		if r.maxAge <= 0 {
+			return nil, errors.New("max_age must be greater than zero")
+		}
+	}
+
 	return r, nil
 }
 
@@ -116,7 +139,37 @@ func (r *RedisStreams) Connect() error {
 
 //------------------------------------------------------------------------------
 
-// Write attempts to write a message by pushing it to the end of a Redis list.
+// xaddArgs returns the arguments of an XADD command that adds a message part
+// to a stream, trimming the stream according to the configured limits. The
+// metadata of the part is added in key order so that entries are consistent.
+func (r *RedisStreams) xaddArgs(stream string, p types.Part, now time.Time) []interface{} {
+	args := []interface{}{"xadd", stream}
+	if r.conf.MaxLenApprox > 0 {
+		args = append(args, "maxlen", "~", r.conf.MaxLenApprox)
+	} else if r.maxAge > 0 {
+		minID := now.Add(-r.maxAge).UnixNano() / int64(time.Millisecond)
+		args = append(args, "minid", "~", strconv.FormatInt(minID, 10))
+	}
+	args = append(args, "*")
+
+	var keys []string
+	values := map[string]string{}
+	p.Metadata().Iter(func(k, v string) error {
+		if k != r.conf.BodyKey {
+			keys = append(keys, k)
+			values[k] = v
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, k, values[k])
+	}
+	return append(args, r.conf.BodyKey, p.Get())
+}
+
+// Write attempts to write a message by adding each part as an entry of a Redis
+// stream.
 func (r *RedisStreams) Write(msg types.Message) error {
 	r.connMut.RLock()
 	client := r.client
@@ -127,18 +180,8 @@ func (r *RedisStreams) Write(msg types.Message) error {
 	}
 
 	return msg.Iter(func(i int, p types.Part) error {
-		values := map[string]interface{}{}
-		p.Metadata().Iter(func(k, v string) error {
-			values[k] = v
-			return nil
-		})
-		values[r.conf.BodyKey] = p.Get()
-		if err := client.XAdd(&redis.XAddArgs{
-			ID:           "*",
-			Stream:       r.conf.Stream,
-			MaxLenApprox: r.conf.MaxLenApprox,
-			Values:       values,
-		}).Err(); err != nil {
+		stream := r.stream.Get(message.Lock(msg, i))
+		if err := client.Do(r.xaddArgs(stream, p, time.Now())...).Err(); err != nil {
 			r.disconnect()
 			r.log.Errorf("Error from redis: %v\n", err)
 			return types.ErrNotConnected
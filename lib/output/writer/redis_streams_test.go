@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func TestRedisStreamsXAddArgs(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	part := message.NewPart([]byte("hello"))
+	part.Metadata().Set("foo", "a").Set("bar", "b").Set("body", "ignored")
+
+	tests := map[string]struct {
+		maxLen int64
+		maxAge string
+		exp    []interface{}
+	}{
+		"no trimming": {
+			exp: []interface{}{"xadd", "foo", "*", "bar", "b", "foo", "a", "body", []byte("hello")},
+		},
+		"max length": {
+			maxLen: 10,
+			exp:    []interface{}{"xadd", "foo", "maxlen", "~", int64(10), "*", "bar", "b", "foo", "a", "body", []byte("hello")},
+		},
+		"max age": {
+			maxAge: "10s",
+			exp:    []interface{}{"xadd", "foo", "minid", "~", "990000", "*", "bar", "b", "foo", "a", "body", []byte("hello")},
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewRedisStreamsConfig()
+		conf.MaxLenApprox = test.maxLen
+		conf.MaxAge = test.maxAge
+
+		r, err := NewRedisStreams(conf, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if act := r.xaddArgs("foo", part, now); !reflect.DeepEqual(test.exp, act) {
+			t.Errorf("%v: Wrong args: %v != %v", name, act, test.exp)
+		}
+	}
+}
+
+func TestRedisStreamsConfigErrors(t *testing.T) {
+	tests := map[string]func(c *RedisStreamsConfig){
+		"both limits": func(c *RedisStreamsConfig) {
+			c.MaxLenApprox = 10
+			c.MaxAge = "1h"
+		},
+		"bad max age": func(c *RedisStreamsConfig) {
+			c.MaxAge = "nope"
+		},
+		"negative max age": func(c *RedisStreamsConfig) {
+			c.MaxAge = "-1h"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewRedisStreamsConfig()
+		fn(&conf)
+		if _, err := NewRedisStreams(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -24,6 +24,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -33,7 +37,9 @@ import (
 	sess "github.com/Jeffail/benthos/v3/lib/util/aws/session"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
@@ -41,25 +47,49 @@ import (
 
 // AmazonS3Config contains configuration fields for the AmazonS3 output type.
 type AmazonS3Config struct {
-	sess.Config        `json:",inline" yaml:",inline"`
-	Bucket             string `json:"bucket" yaml:"bucket"`
-	ForcePathStyleURLs bool   `json:"force_path_style_urls" yaml:"force_path_style_urls"`
-	Path               string `json:"path" yaml:"path"`
-	ContentType        string `json:"content_type" yaml:"content_type"`
-	ContentEncoding    string `json:"content_encoding" yaml:"content_encoding"`
-	Timeout            string `json:"timeout" yaml:"timeout"`
+	sess.Config          `json:",inline" yaml:",inline"`
+	Bucket               string            `json:"bucket" yaml:"bucket"`
+	ForcePathStyleURLs   bool              `json:"force_path_style_urls" yaml:"force_path_style_urls"`
+	Path                 string            `json:"path" yaml:"path"`
+	Tags                 map[string]string `json:"tags" yaml:"tags"`
+	ContentType          string            `json:"content_type" yaml:"content_type"`
+	ContentEncoding      string            `json:"content_encoding" yaml:"content_encoding"`
+	StorageClass         string            `json:"storage_class" yaml:"storage_class"`
+	ACL                  string            `json:"acl" yaml:"acl"`
+	ServerSideEncryption string            `json:"server_side_encryption" yaml:"server_side_encryption"`
+	KMSKeyID             string            `json:"kms_key_id" yaml:"kms_key_id"`
+	Codec                string            `json:"codec" yaml:"codec"`
+	Multipart            S3MultipartConfig `json:"multipart" yaml:"multipart"`
+	Timeout              string            `json:"timeout" yaml:"timeout"`
+}
+
+// S3MultipartConfig contains configuration fields for the multipart uploads
+// performed by the AmazonS3 output type.
+type S3MultipartConfig struct {
+	PartSize    int64 `json:"part_size" yaml:"part_size"`
+	Concurrency int   `json:"concurrency" yaml:"concurrency"`
 }
 
 // NewAmazonS3Config creates a new Config with default values.
 func NewAmazonS3Config() AmazonS3Config {
 	return AmazonS3Config{
-		Config:             sess.NewConfig(),
-		Bucket:             "",
-		ForcePathStyleURLs: false,
-		Path:               "${!count:files}-${!timestamp_unix_nano}.txt",
-		ContentType:        "application/octet-stream",
-		ContentEncoding:    "",
-		Timeout:            "5s",
+		Config:               sess.NewConfig(),
+		Bucket:               "",
+		ForcePathStyleURLs:   false,
+		Path:                 "${!count:files}-${!timestamp_unix_nano}.txt",
+		Tags:                 map[string]string{},
+		ContentType:          "application/octet-stream",
+		ContentEncoding:      "",
+		StorageClass:         "STANDARD",
+		ACL:                  "",
+		ServerSideEncryption: "",
+		KMSKeyID:             "",
+		Codec:                "all-bytes",
+		Multipart: S3MultipartConfig{
+			PartSize:    s3manager.DefaultUploadPartSize,
+			Concurrency: s3manager.DefaultUploadConcurrency,
+		},
+		Timeout: "5s",
 	}
 }
 
@@ -71,8 +101,15 @@ type AmazonS3 struct {
 	conf AmazonS3Config
 
 	path            *text.InterpolatedString
+	tags            []s3Tag
 	contentType     *text.InterpolatedString
 	contentEncoding *text.InterpolatedString
+	storageClass    *text.InterpolatedString
+
+	// When delim is nil each message is written to its own object, otherwise
+	// the messages of a batch are streamed to a single object, each followed
+	// by delim.
+	delim []byte
 
 	session  *session.Session
 	uploader *s3manager.Uploader
@@ -82,6 +119,11 @@ type AmazonS3 struct {
 	stats metrics.Type
 }
 
+type s3Tag struct {
+	key   string
+	value *text.InterpolatedString
+}
+
 // NewAmazonS3 creates a new Amazon S3 bucket writer.Type.
 func NewAmazonS3(
 	conf AmazonS3Config,
@@ -95,13 +137,53 @@ func NewAmazonS3(
 			return nil, fmt.Errorf("failed to parse timeout period string: %v", err)
 		}
 	}
+	var delim []byte
+	switch {
+	case conf.Codec == "all-bytes":
+	case conf.Codec == "lines":
+		delim = []byte("\n")
+	case strings.HasPrefix(conf.Codec, "delim:"):
+		if delim = []byte(strings.TrimPrefix(conf.Codec, "delim:")); len(delim) == 0 {
+			return nil, fmt.Errorf("delim codec requires a non-empty delimiter")
+		}
+	default:
+		return nil, fmt.Errorf("codec not recognised: %v", conf.Codec)
+	}
+	if conf.Multipart.PartSize < s3manager.MinUploadPartSize {
+		return nil, fmt.Errorf("multipart part size must be at least %v bytes, got: %v", s3manager.MinUploadPartSize, conf.Multipart.PartSize)
+	}
+	if conf.Multipart.Concurrency < 1 {
+		return nil, fmt.Errorf("multipart concurrency must be at least 1, got: %v", conf.Multipart.Concurrency)
+	}
+
+	if len(conf.KMSKeyID) > 0 && len(conf.ServerSideEncryption) > 0 && conf.ServerSideEncryption != s3.ServerSideEncryptionAwsKms {
+		return nil, fmt.Errorf("a kms_key_id requires server_side_encryption %v, got: %v", s3.ServerSideEncryptionAwsKms, conf.ServerSideEncryption)
+	}
+
+	tagKeys := make([]string, 0, len(conf.Tags))
+	for k := range conf.Tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+
+	tags := make([]s3Tag, 0, len(tagKeys))
+	for _, k := range tagKeys {
+		tags = append(tags, s3Tag{
+			key:   k,
+			value: text.NewInterpolatedString(conf.Tags[k]),
+		})
+	}
+
 	return &AmazonS3{
 		conf:            conf,
 		log:             log,
 		stats:           stats,
 		path:            text.NewInterpolatedString(conf.Path),
+		tags:            tags,
 		contentType:     text.NewInterpolatedString(conf.ContentType),
 		contentEncoding: text.NewInterpolatedString(conf.ContentEncoding),
+		storageClass:    text.NewInterpolatedString(conf.StorageClass),
+		delim:           delim,
 		timeout:         timeout,
 	}, nil
 }
@@ -120,49 +202,110 @@ func (a *AmazonS3) Connect() error {
 	}
 
 	a.session = sess
-	a.uploader = s3manager.NewUploader(sess)
+	a.uploader = s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		u.PartSize = a.conf.Multipart.PartSize
+		u.Concurrency = a.conf.Multipart.Concurrency
+		if a.timeout > 0 {
+			u.RequestOptions = append(u.RequestOptions, requestTimeout(a.timeout))
+		}
+	})
 
 	a.log.Infof("Uploading message parts as objects to Amazon S3 bucket: %v\n", a.conf.Bucket)
 	return nil
 }
 
+// requestTimeout returns a request option that limits each request of an
+// upload to a timeout, such that a multipart upload is limited per part rather
+// than as a whole.
+func requestTimeout(timeout time.Duration) request.Option {
+	return func(r *request.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		r.SetContext(ctx)
+		r.Handlers.Complete.PushBack(func(*request.Request) {
+			cancel()
+		})
+	}
+}
+
+// uploadInput creates the input of an upload for a message of a batch.
+func (a *AmazonS3) uploadInput(msg types.Message, index int, body io.Reader) *s3manager.UploadInput {
+	metadata := map[string]*string{}
+	msg.Get(index).Metadata().Iter(func(k, v string) error {
+		metadata[k] = aws.String(v)
+		return nil
+	})
+
+	lMsg := message.Lock(msg, index)
+
+	uploadInput := &s3manager.UploadInput{
+		Bucket:      &a.conf.Bucket,
+		Key:         aws.String(a.path.Get(lMsg)),
+		Body:        body,
+		ContentType: aws.String(a.contentType.Get(lMsg)),
+		Metadata:    metadata,
+	}
+	if ce := a.contentEncoding.Get(lMsg); len(ce) > 0 {
+		uploadInput.ContentEncoding = aws.String(ce)
+	}
+	if sc := a.storageClass.Get(lMsg); len(sc) > 0 {
+		uploadInput.StorageClass = aws.String(sc)
+	}
+	if len(a.tags) > 0 {
+		tags := url.Values{}
+		for _, t := range a.tags {
+			tags.Add(t.key, t.value.Get(lMsg))
+		}
+		uploadInput.Tagging = aws.String(tags.Encode())
+	}
+	if len(a.conf.ACL) > 0 {
+		uploadInput.ACL = aws.String(a.conf.ACL)
+	}
+	if len(a.conf.KMSKeyID) > 0 {
+		uploadInput.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		uploadInput.SSEKMSKeyId = aws.String(a.conf.KMSKeyID)
+	} else if len(a.conf.ServerSideEncryption) > 0 {
+		uploadInput.ServerSideEncryption = aws.String(a.conf.ServerSideEncryption)
+	}
+	return uploadInput
+}
+
 // Write attempts to write message contents to a target S3 bucket as files.
 func (a *AmazonS3) Write(msg types.Message) error {
 	if a.session == nil {
 		return types.ErrNotConnected
 	}
 
-	ctx, cancel := context.WithTimeout(
-		aws.BackgroundContext(), a.timeout,
-	)
-	defer cancel()
+	ctx := aws.BackgroundContext()
+	if a.delim != nil {
+		return a.writeBatch(ctx, msg)
+	}
 
 	return msg.Iter(func(i int, p types.Part) error {
-		metadata := map[string]*string{}
-		p.Metadata().Iter(func(k, v string) error {
-			metadata[k] = aws.String(v)
-			return nil
-		})
+		_, err := a.uploader.UploadWithContext(ctx, a.uploadInput(msg, i, bytes.NewReader(p.Get())))
+		return err
+	})
+}
 
-		lMsg := message.Lock(msg, i)
+// writeBatch streams the messages of a batch to a single object, which is
+// uploaded in parts as they are filled rather than concatenated in full. The
+// messages themselves are already held in memory.
+func (a *AmazonS3) writeBatch(ctx context.Context, msg types.Message) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(msg.Iter(func(i int, p types.Part) error {
+			if _, err := pw.Write(p.Get()); err != nil {
+				return err
+			}
+			_, err := pw.Write(a.delim)
+			return err
+		}))
+	}()
 
-		var contentEncoding *string
-		if ce := a.contentEncoding.Get(lMsg); len(ce) > 0 {
-			contentEncoding = aws.String(ce)
-		}
+	_, err := a.uploader.UploadWithContext(ctx, a.uploadInput(msg, 0, pr))
 
-		if _, err := a.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
-			Bucket:          &a.conf.Bucket,
-			Key:             aws.String(a.path.Get(lMsg)),
-			Body:            bytes.NewReader(p.Get()),
-			ContentType:     aws.String(a.contentType.Get(lMsg)),
-			ContentEncoding: contentEncoding,
-			Metadata:        metadata,
-		}); err != nil {
-			return err
-		}
-		return nil
-	})
+	// Unblock the writing goroutine when the upload ended early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	return err
 }
 
 // CloseAsync begins cleaning up resources used by this reader asynchronously.
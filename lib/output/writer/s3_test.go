@@ -0,0 +1,263 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+func TestAmazonS3UploadOptions(t *testing.T) {
+	type upload struct {
+		path    string
+		headers http.Header
+		body    string
+	}
+	var uploadsMut sync.Mutex
+	var uploads []upload
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			http.Error(w, "unexpected method", http.StatusBadRequest)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		uploadsMut.Lock()
+		uploads = append(uploads, upload{
+			path:    r.URL.Path,
+			headers: r.Header,
+			body:    string(body),
+		})
+		uploadsMut.Unlock()
+	}))
+	defer ts.Close()
+
+	conf := NewAmazonS3Config()
+	conf.Endpoint = ts.URL
+	conf.Region = "eu-west-1"
+	conf.Credentials.ID = "foo"
+	conf.Credentials.Secret = "bar"
+	conf.Bucket = "buckety"
+	conf.ForcePathStyleURLs = true
+	conf.Path = "${!json_field:id}.json"
+	conf.Tags = map[string]string{
+		"team":   "data",
+		"source": "${!metadata:source}",
+	}
+	conf.StorageClass = "${!metadata:class}"
+	conf.ACL = "bucket-owner-full-control"
+	conf.KMSKeyID = "arn:aws:kms:eu-west-1:123:key/abc"
+
+	s, err := NewAmazonS3(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"foo"}`),
+		[]byte(`{"id":"bar"}`),
+	})
+	msg.Get(0).Metadata().Set("source", "a b")
+	msg.Get(0).Metadata().Set("class", "GLACIER_IR")
+	msg.Get(1).Metadata().Set("source", "c")
+	if err = s.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	uploadsMut.Lock()
+	defer uploadsMut.Unlock()
+
+	if len(uploads) != 2 {
+		t.Fatalf("Wrong count of uploads: %v", len(uploads))
+	}
+
+	if exp, act := "/buckety/foo.json", uploads[0].path; exp != act {
+		t.Errorf("Wrong path: %v != %v", act, exp)
+	}
+	if exp, act := `{"id":"foo"}`, uploads[0].body; exp != act {
+		t.Errorf("Wrong body: %v != %v", act, exp)
+	}
+	for k, exp := range map[string]string{
+		"X-Amz-Tagging":                "source=a+b&team=data",
+		"X-Amz-Storage-Class":          "GLACIER_IR",
+		"X-Amz-Acl":                    "bucket-owner-full-control",
+		"X-Amz-Server-Side-Encryption": "aws:kms",
+		"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": "arn:aws:kms:eu-west-1:123:key/abc",
+	} {
+		if act := uploads[0].headers.Get(k); exp != act {
+			t.Errorf("Wrong %v header: %v != %v", k, act, exp)
+		}
+	}
+
+	if exp, act := "source=c&team=data", uploads[1].headers.Get("X-Amz-Tagging"); exp != act {
+		t.Errorf("Wrong tagging header: %v != %v", act, exp)
+	}
+	if act := uploads[1].headers.Get("X-Amz-Storage-Class"); act != "" {
+		t.Errorf("Unexpected storage class header: %v", act)
+	}
+}
+
+func TestAmazonS3MultipartLines(t *testing.T) {
+	var objectsMut sync.Mutex
+	objects := map[string][]byte{}
+	parts := map[int][]byte{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Each request is within the timeout, but the upload as a whole
+		// exceeds it.
+		<-time.After(time.Millisecond * 300)
+
+		objectsMut.Lock()
+		defer objectsMut.Unlock()
+
+		query := r.URL.Query()
+		_, initiate := query["uploads"]
+		switch {
+		case r.Method == "POST" && initiate:
+			fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>buckety</Bucket><Key>%v</Key><UploadId>foo</UploadId></InitiateMultipartUploadResult>`, r.URL.Path)
+		case r.Method == "PUT" && query.Get("uploadId") == "foo":
+			n, err := strconv.Atoi(query.Get("partNumber"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			parts[n] = body
+			w.Header().Set("ETag", fmt.Sprintf(`"part%v"`, n))
+		case r.Method == "POST" && query.Get("uploadId") == "foo":
+			var partNums []int
+			for n := range parts {
+				partNums = append(partNums, n)
+			}
+			sort.Ints(partNums)
+			var object []byte
+			for _, n := range partNums {
+				object = append(object, parts[n]...)
+			}
+			objects[r.URL.Path] = object
+			fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>buckety</Bucket><Key>%v</Key><ETag>"foo"</ETag></CompleteMultipartUploadResult>`, r.URL.Path)
+		case r.Method == "PUT":
+			objects[r.URL.Path] = body
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	conf := NewAmazonS3Config()
+	conf.Endpoint = ts.URL
+	conf.Region = "eu-west-1"
+	conf.Credentials.ID = "foo"
+	conf.Credentials.Secret = "bar"
+	conf.Bucket = "buckety"
+	conf.ForcePathStyleURLs = true
+	conf.Path = "${!metadata:name}.txt"
+	conf.Codec = "lines"
+	conf.Multipart.Concurrency = 1
+	conf.Timeout = "1s"
+
+	s, err := NewAmazonS3(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	small := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	small.Get(0).Metadata().Set("name", "small")
+	if err = s.Write(small); err != nil {
+		t.Fatal(err)
+	}
+
+	var largeParts [][]byte
+	var expLarge []byte
+	for i := 0; i < 3; i++ {
+		part := bytes.Repeat([]byte{byte('a' + i)}, 3*1024*1024)
+		largeParts = append(largeParts, part)
+		expLarge = append(append(expLarge, part...), '\n')
+	}
+	large := message.New(largeParts)
+	large.Get(0).Metadata().Set("name", "large")
+	if err = s.Write(large); err != nil {
+		t.Fatal(err)
+	}
+
+	objectsMut.Lock()
+	defer objectsMut.Unlock()
+
+	if exp, act := "foo\nbar\n", string(objects["/buckety/small.txt"]); exp != act {
+		t.Errorf("Wrong small object: %q != %q", act, exp)
+	}
+	if exp, act := 2, len(parts); exp != act {
+		t.Errorf("Wrong count of parts: %v != %v", act, exp)
+	}
+	if !bytes.Equal(expLarge, objects["/buckety/large.txt"]) {
+		t.Errorf("Wrong large object of size %v", len(objects["/buckety/large.txt"]))
+	}
+}
+
+func TestAmazonS3BadConfig(t *testing.T) {
+	conf := NewAmazonS3Config()
+	conf.Codec = "nope"
+	if _, err := NewAmazonS3(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad codec")
+	}
+
+	conf = NewAmazonS3Config()
+	conf.Multipart.PartSize = 1024
+	if _, err := NewAmazonS3(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from small part size")
+	}
+}
+
+func TestAmazonS3BadEncryption(t *testing.T) {
+	conf := NewAmazonS3Config()
+	conf.ServerSideEncryption = "AES256"
+	conf.KMSKeyID = "foo"
+	if _, err := NewAmazonS3(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from kms key without kms encryption")
+	}
+}
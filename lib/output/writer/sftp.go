@@ -0,0 +1,256 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/sftp"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"golang.org/x/crypto/ssh"
+)
+
+//------------------------------------------------------------------------------
+
+// SFTPConfig contains configuration fields for the SFTP output type.
+type SFTPConfig struct {
+	Address        string           `json:"address" yaml:"address"`
+	Path           string           `json:"path" yaml:"path"`
+	Codec          string           `json:"codec" yaml:"codec"`
+	Credentials    sftp.Credentials `json:"credentials" yaml:"credentials"`
+	KnownHostsFile string           `json:"known_hosts_file" yaml:"known_hosts_file"`
+	Timeout        string           `json:"timeout" yaml:"timeout"`
+}
+
+// NewSFTPConfig creates a new SFTPConfig with default values.
+func NewSFTPConfig() SFTPConfig {
+	return SFTPConfig{
+		Address:        "localhost:22",
+		Path:           "${!count:files}-${!timestamp_unix_nano}.txt",
+		Codec:          "all-bytes",
+		Credentials:    sftp.NewCredentials(),
+		KnownHostsFile: "",
+		Timeout:        "10s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SFTP is a benthos writer.Type implementation that writes messages to files
+// of an SFTP server.
+type SFTP struct {
+	conf    SFTPConfig
+	sshConf *ssh.ClientConfig
+	timeout time.Duration
+	path    *text.InterpolatedString
+
+	// When delim is nil each message is written to its own file, otherwise the
+	// messages of a batch are written to a single file, each followed by delim.
+	delim []byte
+
+	tmpCount uint64
+
+	mut    sync.Mutex
+	client *sftp.Client
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSFTP creates a new SFTP writer.Type.
+func NewSFTP(conf SFTPConfig, log log.Modular, stats metrics.Type) (*SFTP, error) {
+	s := &SFTP{
+		conf:  conf,
+		path:  text.NewInterpolatedString(conf.Path),
+		log:   log,
+		stats: stats,
+	}
+
+	switch {
+	case conf.Codec == "all-bytes":
+	case conf.Codec == "lines":
+		s.delim = []byte("\n")
+	case strings.HasPrefix(conf.Codec, "delim:"):
+		if s.delim = []byte(strings.TrimPrefix(conf.Codec, "delim:")); len(s.delim) == 0 {
+			return nil, fmt.Errorf("delim codec requires a non-empty delimiter")
+		}
+	default:
+		return nil, fmt.Errorf("codec not recognised: %v", conf.Codec)
+	}
+
+	var err error
+	if s.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if s.sshConf, err = conf.Credentials.SSHConfig(conf.KnownHostsFile, s.timeout, log); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establishes an SFTP session with the server.
+func (s *SFTP) Connect() error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.client != nil {
+		return nil
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), s.timeout)
+	defer done()
+
+	var err error
+	if s.client, err = sftp.Dial(ctx, s.conf.Address, s.sshConf); err != nil {
+		return err
+	}
+	s.log.Infof("Writing files to SFTP server %v at path: %v\n", s.conf.Address, s.conf.Path)
+	return nil
+}
+
+// disconnect closes the current session after a transport error.
+func (s *SFTP) disconnect(client *sftp.Client) {
+	s.mut.Lock()
+	if s.client == client {
+		s.client.Close()
+		s.client = nil
+	}
+	s.mut.Unlock()
+}
+
+// writeFile uploads the contents of a file to a temporary path alongside the
+// target and then renames it, so that the target is never seen partially
+// written. Missing directories of the target are created.
+func (s *SFTP) writeFile(client *sftp.Client, target string, data []byte) error {
+	dir, base := path.Split(target)
+	tmp := path.Join(dir, "."+base+"."+strconv.FormatUint(atomic.AddUint64(&s.tmpCount, 1), 10)+".tmp")
+
+	err := client.WriteFile(tmp, data)
+	if err != nil && sftp.IsNotExist(err) && len(dir) > 0 {
+		if err = client.MkdirAll(path.Clean(dir)); err == nil {
+			err = client.WriteFile(tmp, data)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err = client.PosixRename(tmp, target); err != nil {
+		client.Remove(tmp)
+	}
+	return err
+}
+
+// isTransportErr returns true if an error was not returned by the server, in
+// which case the session is no longer usable.
+func isTransportErr(err error) bool {
+	_, ok := err.(*sftp.StatusError)
+	return !ok
+}
+
+// Write attempts to write a message to the SFTP server.
+func (s *SFTP) Write(msg types.Message) error {
+	s.mut.Lock()
+	client := s.client
+	s.mut.Unlock()
+
+	if client == nil {
+		return types.ErrNotConnected
+	}
+
+	if s.delim != nil {
+		var buf bytes.Buffer
+		msg.Iter(func(i int, p types.Part) error {
+			buf.Write(p.Get())
+			buf.Write(s.delim)
+			return nil
+		})
+		target := s.path.Get(message.Lock(msg, 0))
+		if err := s.writeFile(client, target, buf.Bytes()); err != nil {
+			if isTransportErr(err) {
+				s.log.Errorf("Failed to write file '%v': %v\n", target, err)
+				s.disconnect(client)
+				return types.ErrNotConnected
+			}
+			return fmt.Errorf("failed to write file '%v': %v", target, err)
+		}
+		return nil
+	}
+
+	var batchErr *batch.Error
+	err := msg.Iter(func(i int, p types.Part) error {
+		target := s.path.Get(message.Lock(msg, i))
+		err := s.writeFile(client, target, p.Get())
+		if err == nil {
+			return nil
+		}
+		if isTransportErr(err) {
+			return fmt.Errorf("failed to write file '%v': %v", target, err)
+		}
+		err = fmt.Errorf("failed to write file '%v': %v", target, err)
+		if batchErr == nil {
+			batchErr = batch.NewError(msg, err)
+		}
+		batchErr.Failed(i, err)
+		return nil
+	})
+	if err != nil {
+		s.log.Errorf("%v\n", err)
+		s.disconnect(client)
+		return types.ErrNotConnected
+	}
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (s *SFTP) CloseAsync() {
+	s.mut.Lock()
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+	s.mut.Unlock()
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (s *SFTP) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,216 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/sftp/sftptest"
+	"golang.org/x/crypto/ssh"
+)
+
+//------------------------------------------------------------------------------
+
+func sftpTestPasswordServer(t *testing.T, fs *sftptest.Server) string {
+	t.Helper()
+	ln, err := fs.ListenSSH(&ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "foo" && string(pass) == "bar" {
+				return nil, nil
+			}
+			return nil, errors.New("bad password")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String()
+}
+
+func TestSFTPBadConfig(t *testing.T) {
+	conf := NewSFTPConfig()
+	conf.Credentials.Password = "bar"
+	conf.Codec = "nope"
+	if _, err := NewSFTP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad codec")
+	}
+
+	conf.Codec = "delim:"
+	if _, err := NewSFTP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from empty delimiter")
+	}
+
+	conf.Codec = "all-bytes"
+	conf.Credentials.Password = ""
+	if _, err := NewSFTP(conf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing credentials")
+	}
+}
+
+func TestSFTPWriteWithKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "benthos_sftp_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "id_rsa")
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := sftptest.NewServer()
+	fs.Put("/out/b.txt", "old", time.Now())
+
+	ln, err := fs.ListenSSH(&ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, k ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(k.Marshal(), pubKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("bad key")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conf := NewSFTPConfig()
+	conf.Address = ln.Addr().String()
+	conf.Path = "/out/${!metadata:dir}${!json_field:id}.txt"
+	conf.Credentials.Username = "foo"
+	conf.Credentials.PrivateKeyFile = keyFile
+
+	s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Write(message.New(nil)); err != types.ErrNotConnected {
+		t.Errorf("Expected not connected error, got: %v", err)
+	}
+	if err = s.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.CloseAsync()
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"b"}`),
+	})
+	msg.Get(0).Metadata().Set("dir", "deep/er/")
+	if err = s.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := []string{"/out/b.txt", "/out/deep/er/a.txt"}, fs.Names(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong files: %v != %v", act, exp)
+	}
+	if data, _ := fs.Get("/out/deep/er/a.txt"); data != `{"id":"a"}` {
+		t.Errorf("Wrong file contents: %v", data)
+	}
+	if data, _ := fs.Get("/out/b.txt"); data != `{"id":"b"}` {
+		t.Errorf("Wrong file contents: %v", data)
+	}
+}
+
+func TestSFTPWriteLines(t *testing.T) {
+	for _, posixRename := range []bool{true, false} {
+		fs := sftptest.NewServer()
+		fs.PosixRename = posixRename
+		fs.Put("/out/batch.txt", "old", time.Now())
+
+		conf := NewSFTPConfig()
+		conf.Address = sftpTestPasswordServer(t, fs)
+		conf.Path = "/out/batch.txt"
+		conf.Codec = "lines"
+		conf.Credentials.Username = "foo"
+		conf.Credentials.Password = "bar"
+
+		s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.Connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = s.Write(message.New([][]byte{
+			[]byte("foo"),
+			[]byte("bar"),
+		})); err != nil {
+			t.Fatal(err)
+		}
+		s.CloseAsync()
+
+		if exp, act := []string{"/out/batch.txt"}, fs.Names(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong files: %v != %v", act, exp)
+		}
+		if data, _ := fs.Get("/out/batch.txt"); data != "foo\nbar\n" {
+			t.Errorf("Wrong file contents: %v", data)
+		}
+	}
+}
+
+func TestSFTPBadPassword(t *testing.T) {
+	conf := NewSFTPConfig()
+	conf.Address = sftpTestPasswordServer(t, sftptest.NewServer())
+	conf.Credentials.Username = "foo"
+	conf.Credentials.Password = "nope"
+
+	s, err := NewSFTP(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Connect(); err == nil {
+		t.Error("Expected error from bad password")
+	}
+}
+
+//------------------------------------------------------------------------------
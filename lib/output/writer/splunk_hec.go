@@ -0,0 +1,470 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/gofrs/uuid"
+)
+
+//------------------------------------------------------------------------------
+
+// SplunkHECAckConfig contains configuration fields for polling the indexer
+// acknowledgement of events sent to a Splunk HTTP Event Collector.
+type SplunkHECAckConfig struct {
+	Enabled      bool   `json:"enabled" yaml:"enabled"`
+	Channel      string `json:"channel" yaml:"channel"`
+	PollInterval string `json:"poll_interval" yaml:"poll_interval"`
+	Timeout      string `json:"timeout" yaml:"timeout"`
+}
+
+// SplunkHECConfig contains configuration fields for the SplunkHEC output type.
+type SplunkHECConfig struct {
+	URL             string             `json:"url" yaml:"url"`
+	Token           string             `json:"token" yaml:"token"`
+	Endpoint        string             `json:"endpoint" yaml:"endpoint"`
+	Index           string             `json:"index" yaml:"index"`
+	Source          string             `json:"source" yaml:"source"`
+	SourceType      string             `json:"sourcetype" yaml:"sourcetype"`
+	Host            string             `json:"host" yaml:"host"`
+	Gzip            bool               `json:"gzip" yaml:"gzip"`
+	Acknowledgement SplunkHECAckConfig `json:"acknowledgement" yaml:"acknowledgement"`
+	TLS             btls.Config        `json:"tls" yaml:"tls"`
+	Timeout         string             `json:"timeout" yaml:"timeout"`
+}
+
+// NewSplunkHECConfig creates a new SplunkHECConfig with default values.
+func NewSplunkHECConfig() SplunkHECConfig {
+	return SplunkHECConfig{
+		URL:        "https://localhost:8088",
+		Token:      "",
+		Endpoint:   "event",
+		Index:      "",
+		Source:     "",
+		SourceType: "",
+		Host:       "",
+		Gzip:       false,
+		Acknowledgement: SplunkHECAckConfig{
+			Enabled:      false,
+			Channel:      "",
+			PollInterval: "1s",
+			Timeout:      "30s",
+		},
+		TLS:     btls.NewConfig(),
+		Timeout: "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ErrSplunkHECAckTimeout is returned for messages that were sent to a Splunk
+// HTTP Event Collector but were not acknowledged as indexed within the
+// configured timeout.
+var ErrSplunkHECAckTimeout = errors.New("timed out waiting for indexer acknowledgement")
+
+// splunkHECEvent is an event sent to the event endpoint of a Splunk HTTP
+// Event Collector.
+type splunkHECEvent struct {
+	Event      json.RawMessage `json:"event"`
+	Index      string          `json:"index,omitempty"`
+	Source     string          `json:"source,omitempty"`
+	SourceType string          `json:"sourcetype,omitempty"`
+	Host       string          `json:"host,omitempty"`
+}
+
+// splunkHECResponse is the response of a Splunk HTTP Event Collector to a
+// request.
+type splunkHECResponse struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+// splunkHECRequest is a request to a Splunk HTTP Event Collector along with the
+// indexes of the messages it contains.
+type splunkHECRequest struct {
+	query   url.Values
+	body    bytes.Buffer
+	indexes []int
+}
+
+//------------------------------------------------------------------------------
+
+// SplunkHEC is a benthos writer.Type implementation that sends messages to a
+// Splunk HTTP Event Collector.
+type SplunkHEC struct {
+	conf    SplunkHECConfig
+	client  *http.Client
+	timeout time.Duration
+	raw     bool
+	channel string
+
+	ackPollInterval time.Duration
+	ackTimeout      time.Duration
+
+	index      *text.InterpolatedString
+	source     *text.InterpolatedString
+	sourceType *text.InterpolatedString
+	host       *text.InterpolatedString
+
+	connMut   sync.RWMutex
+	connected bool
+	closeChan chan struct{}
+	closeOnce sync.Once
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSplunkHEC creates a new Splunk HTTP Event Collector writer.Type.
+func NewSplunkHEC(
+	conf SplunkHECConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*SplunkHEC, error) {
+	if len(conf.Token) == 0 {
+		return nil, errors.New("a token must be specified")
+	}
+	s := &SplunkHEC{
+		conf:       conf,
+		channel:    conf.Acknowledgement.Channel,
+		index:      text.NewInterpolatedString(conf.Index),
+		source:     text.NewInterpolatedString(conf.Source),
+		sourceType: text.NewInterpolatedString(conf.SourceType),
+		host:       text.NewInterpolatedString(conf.Host),
+		closeChan:  make(chan struct{}),
+		log:        log,
+		stats:      stats,
+	}
+
+	switch conf.Endpoint {
+	case "event":
+	case "raw":
+		s.raw = true
+	default:
+		return nil, fmt.Errorf("endpoint not recognised: %v", conf.Endpoint)
+	}
+
+	var err error
+	if s.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if conf.Acknowledgement.Enabled {
+		if s.ackPollInterval, err = time.ParseDuration(conf.Acknowledgement.PollInterval); err != nil {
+			return nil, fmt.Errorf("failed to parse acknowledgement poll interval: %v", err)
+		}
+		if s.ackTimeout, err = time.ParseDuration(conf.Acknowledgement.Timeout); err != nil {
+			return nil, fmt.Errorf("failed to parse acknowledgement timeout: %v", err)
+		}
+	}
+	if len(s.channel) == 0 {
+		u4, err := uuid.NewV4()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate channel: %v", err)
+		}
+		s.channel = u4.String()
+	}
+
+	s.client = &http.Client{}
+	if conf.TLS.Enabled {
+		tlsConf, err := conf.TLS.Get()
+		if err != nil {
+			return nil, err
+		}
+		s.client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConf,
+		}
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect does nothing as requests are sent without a persistent connection.
+func (s *SplunkHEC) Connect() error {
+	s.connMut.Lock()
+	defer s.connMut.Unlock()
+
+	if !s.connected {
+		s.connected = true
+		s.log.Infof("Sending messages to Splunk HTTP Event Collector: %v\n", s.conf.URL)
+	}
+	return nil
+}
+
+// requests creates the requests that send a message batch. Events each carry
+// their own metadata and are therefore sent as a single request, whereas raw
+// messages are grouped into a request for each distinct combination of
+// metadata.
+func (s *SplunkHEC) requests(msg types.Message) ([]*splunkHECRequest, error) {
+	var reqs []*splunkHECRequest
+	if !s.raw {
+		reqs = append(reqs, &splunkHECRequest{})
+	}
+	rawReqs := map[string]*splunkHECRequest{}
+
+	err := msg.Iter(func(i int, p types.Part) error {
+		lMsg := message.Lock(msg, i)
+		index, source := s.index.Get(lMsg), s.source.Get(lMsg)
+		sourceType, host := s.sourceType.Get(lMsg), s.host.Get(lMsg)
+
+		if !s.raw {
+			event := splunkHECEvent{
+				Event:      json.RawMessage(p.Get()),
+				Index:      index,
+				Source:     source,
+				SourceType: sourceType,
+				Host:       host,
+			}
+			if !json.Valid(event.Event) {
+				str, _ := json.Marshal(string(p.Get()))
+				event.Event = str
+			}
+			eventBytes, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			reqs[0].body.Write(eventBytes)
+			reqs[0].indexes = append(reqs[0].indexes, i)
+			return nil
+		}
+
+		query := url.Values{}
+		for k, v := range map[string]string{
+			"index":      index,
+			"source":     source,
+			"sourcetype": sourceType,
+			"host":       host,
+		} {
+			if len(v) > 0 {
+				query.Set(k, v)
+			}
+		}
+		key := query.Encode()
+		req, exists := rawReqs[key]
+		if !exists {
+			req = &splunkHECRequest{query: query}
+			rawReqs[key] = req
+			reqs = append(reqs, req)
+		} else {
+			req.body.WriteByte('\n')
+		}
+		req.body.Write(p.Get())
+		req.indexes = append(req.indexes, i)
+		return nil
+	})
+	return reqs, err
+}
+
+// post sends a request body to an endpoint of the collector and returns the
+// body of the response once its status has been checked.
+func (s *SplunkHEC) post(path string, query url.Values, body []byte) ([]byte, error) {
+	var contentEncoding string
+	if s.conf.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body, contentEncoding = buf.Bytes(), "gzip"
+	}
+
+	u := strings.TrimSuffix(s.conf.URL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), s.timeout)
+	defer done()
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Splunk "+s.conf.Token)
+	req.Header.Set("X-Splunk-Request-Channel", s.channel)
+	if len(contentEncoding) > 0 {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var hecRes splunkHECResponse
+	if err = json.Unmarshal(resBytes, &hecRes); err != nil {
+		return nil, fmt.Errorf("unexpected response (%v): %s", res.StatusCode, resBytes)
+	}
+	if res.StatusCode != http.StatusOK || hecRes.Code != 0 {
+		return nil, fmt.Errorf("%v (%v)", hecRes.Text, hecRes.Code)
+	}
+	return resBytes, nil
+}
+
+// waitForAcks polls the acknowledgement status of requests until all of them
+// have been indexed, and returns the IDs of acknowledgements that remain
+// pending once the timeout is reached.
+func (s *SplunkHEC) waitForAcks(ackIDs []int64) []int64 {
+	deadline := time.Now().Add(s.ackTimeout)
+	for {
+		body, err := json.Marshal(map[string][]int64{"acks": ackIDs})
+		if err != nil {
+			return ackIDs
+		}
+		resBytes, err := s.post("/services/collector/ack", nil, body)
+		if err == nil {
+			var res struct {
+				Acks map[string]bool `json:"acks"`
+			}
+			if err = json.Unmarshal(resBytes, &res); err == nil {
+				var pending []int64
+				for _, id := range ackIDs {
+					if !res.Acks[strconv.FormatInt(id, 10)] {
+						pending = append(pending, id)
+					}
+				}
+				ackIDs = pending
+			}
+		}
+		if err != nil {
+			s.log.Errorf("Failed to poll indexer acknowledgements: %v\n", err)
+		}
+		if len(ackIDs) == 0 || time.Now().After(deadline) {
+			return ackIDs
+		}
+		select {
+		case <-time.After(s.ackPollInterval):
+		case <-s.closeChan:
+			return ackIDs
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Write attempts to send a message batch to the collector, where messages that
+// fail to be sent or acknowledged are reported with a batch.Error.
+func (s *SplunkHEC) Write(msg types.Message) error {
+	s.connMut.RLock()
+	connected := s.connected
+	s.connMut.RUnlock()
+	if !connected {
+		return types.ErrNotConnected
+	}
+
+	reqs, err := s.requests(msg)
+	if err != nil {
+		return err
+	}
+
+	path := "/services/collector/event"
+	if s.raw {
+		path = "/services/collector/raw"
+	}
+
+	var batchErr *batch.Error
+	failed := func(indexes []int, err error) {
+		if batchErr == nil {
+			batchErr = batch.NewError(msg, err)
+		}
+		for _, i := range indexes {
+			batchErr.Failed(i, err)
+		}
+	}
+
+	pendingAcks := map[int64][]int{}
+	var ackIDs []int64
+	for _, req := range reqs {
+		resBytes, err := s.post(path, req.query, req.body.Bytes())
+		if err != nil {
+			s.log.Errorf("Failed to send messages: %v\n", err)
+			failed(req.indexes, err)
+			continue
+		}
+		if s.conf.Acknowledgement.Enabled {
+			var res splunkHECResponse
+			json.Unmarshal(resBytes, &res)
+			if res.AckID == nil {
+				failed(req.indexes, errors.New("indexer acknowledgement is not enabled for the token"))
+				continue
+			}
+			pendingAcks[*res.AckID] = req.indexes
+			ackIDs = append(ackIDs, *res.AckID)
+		}
+	}
+
+	if len(ackIDs) > 0 {
+		for _, id := range s.waitForAcks(ackIDs) {
+			failed(pendingAcks[id], ErrSplunkHECAckTimeout)
+		}
+	}
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (s *SplunkHEC) CloseAsync() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+	})
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (s *SplunkHEC) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,266 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// testHECServer emulates a Splunk HTTP Event Collector, where requests
+// containing the body "fail" are rejected and acknowledgements are only
+// reported as indexed on the second poll.
+type testHECServer struct {
+	t    *testing.T
+	mut  sync.Mutex
+	reqs []string
+	acks map[int64]int
+	next int64
+}
+
+func (s *testHECServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if exp, act := "Splunk foo", r.Header.Get("Authorization"); exp != act {
+		s.t.Errorf("Wrong authorization: %v != %v", act, exp)
+	}
+	if exp, act := "bar", r.Header.Get("X-Splunk-Request-Channel"); exp != act {
+		s.t.Errorf("Wrong channel: %v != %v", act, exp)
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.t.Error(err)
+			return
+		}
+		body = zr
+	}
+	bodyBytes, _ := ioutil.ReadAll(body)
+
+	if r.URL.Path == "/services/collector/ack" {
+		var req struct {
+			Acks []int64 `json:"acks"`
+		}
+		if err := json.Unmarshal(bodyBytes, &req); err != nil {
+			s.t.Error(err)
+		}
+		acks := map[string]bool{}
+		for _, id := range req.Acks {
+			s.acks[id]++
+			acks[strconv.FormatInt(id, 10)] = s.acks[id] > 1
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"acks": acks})
+		return
+	}
+
+	if string(bodyBytes) == "fail" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"text":"Invalid data format","code":6}`))
+		return
+	}
+
+	req := r.URL.Path + "?" + r.URL.RawQuery + " " + string(bodyBytes)
+	s.reqs = append(s.reqs, req)
+	fmt.Fprintf(w, `{"text":"Success","code":0,"ackId":%v}`, s.next)
+	s.next++
+}
+
+func newTestHECServer(t *testing.T) (*testHECServer, *httptest.Server) {
+	s := &testHECServer{t: t, acks: map[int64]int{}}
+	return s, httptest.NewServer(s)
+}
+
+func testHECConfig(url string) SplunkHECConfig {
+	conf := NewSplunkHECConfig()
+	conf.URL = url
+	conf.Token = "foo"
+	conf.Acknowledgement.Channel = "bar"
+	return conf
+}
+
+func TestSplunkHECEvents(t *testing.T) {
+	s, server := newTestHECServer(t)
+	defer server.Close()
+
+	conf := testHECConfig(server.URL)
+	conf.Index = "${!metadata:index}"
+	conf.SourceType = "benthos"
+	conf.Gzip = true
+
+	w, err := NewSplunkHEC(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New(nil)); err == nil {
+		t.Error("Expected error when not connected")
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte(`{"foo":"bar"}`), []byte("hello world")})
+	msg.Get(0).Metadata().Set("index", "main")
+	if err = w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		`/services/collector/event? {"event":{"foo":"bar"},"index":"main","sourcetype":"benthos"}{"event":"hello world","sourcetype":"benthos"}`,
+	}
+	if !reflect.DeepEqual(exp, s.reqs) {
+		t.Errorf("Wrong requests: %v != %v", s.reqs, exp)
+	}
+}
+
+func TestSplunkHECRaw(t *testing.T) {
+	s, server := newTestHECServer(t)
+	defer server.Close()
+
+	conf := testHECConfig(server.URL)
+	conf.Endpoint = "raw"
+	conf.Index = "${!metadata:index}"
+
+	w, err := NewSplunkHEC(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("fail"), []byte("bar"), []byte("baz")})
+	msg.Get(0).Metadata().Set("index", "a")
+	msg.Get(1).Metadata().Set("index", "b")
+	msg.Get(2).Metadata().Set("index", "c")
+	msg.Get(3).Metadata().Set("index", "a")
+
+	err = w.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, received: %v", err)
+	}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if (i == 1) != (err != nil) {
+			t.Errorf("Unexpected error for message %v: %v", i, err)
+		}
+		return true
+	})
+
+	exp := []string{
+		"/services/collector/raw?index=a foo\nbaz",
+		"/services/collector/raw?index=c bar",
+	}
+	if !reflect.DeepEqual(exp, s.reqs) {
+		t.Errorf("Wrong requests: %v != %v", s.reqs, exp)
+	}
+}
+
+func TestSplunkHECAcks(t *testing.T) {
+	s, server := newTestHECServer(t)
+	defer server.Close()
+
+	conf := testHECConfig(server.URL)
+	conf.Acknowledgement.Enabled = true
+	conf.Acknowledgement.PollInterval = "1ms"
+
+	w, err := NewSplunkHEC(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Write(message.New([][]byte{[]byte("foo")})); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := map[int64]int{0: 2}, s.acks; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong ack polls: %v != %v", act, exp)
+	}
+
+	conf.Acknowledgement.Timeout = "0s"
+	if w, err = NewSplunkHEC(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Write(message.New([][]byte{[]byte("bar")}))
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, received: %v", err)
+	}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != ErrSplunkHECAckTimeout {
+			t.Errorf("Unexpected error for message %v: %v", i, err)
+		}
+		return true
+	})
+}
+
+func TestSplunkHECConfigErrors(t *testing.T) {
+	tests := map[string]func(c *SplunkHECConfig){
+		"no token": func(c *SplunkHECConfig) {
+			c.Token = ""
+		},
+		"bad endpoint": func(c *SplunkHECConfig) {
+			c.Endpoint = "nope"
+		},
+		"bad timeout": func(c *SplunkHECConfig) {
+			c.Timeout = "nope"
+		},
+		"bad poll interval": func(c *SplunkHECConfig) {
+			c.Acknowledgement.Enabled = true
+			c.Acknowledgement.PollInterval = "nope"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := testHECConfig("http://localhost:8088")
+		fn(&conf)
+		if _, err := NewSplunkHEC(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
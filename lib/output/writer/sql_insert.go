@@ -0,0 +1,327 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+
+	// SQL Drivers
+	_ "github.com/go-sql-driver/mysql"
+)
+
+//------------------------------------------------------------------------------
+
+// SQLInsertConflictConfig contains config fields for handling rows that
+// conflict with existing rows of a table.
+type SQLInsertConflictConfig struct {
+	Action string   `json:"action" yaml:"action"`
+	Keys   []string `json:"keys" yaml:"keys"`
+}
+
+// SQLInsertConfig contains config fields for the SQL insert output type.
+type SQLInsertConfig struct {
+	Driver    string                  `json:"driver" yaml:"driver"`
+	DSN       string                  `json:"dsn" yaml:"dsn"`
+	Table     string                  `json:"table" yaml:"table"`
+	Columns   []string                `json:"columns" yaml:"columns"`
+	Args      []string                `json:"args" yaml:"args"`
+	BatchSize int                     `json:"batch_size" yaml:"batch_size"`
+	Conflict  SQLInsertConflictConfig `json:"on_conflict" yaml:"on_conflict"`
+}
+
+// NewSQLInsertConfig creates a SQLInsertConfig populated with default values.
+func NewSQLInsertConfig() SQLInsertConfig {
+	return SQLInsertConfig{
+		Driver:    "mysql",
+		DSN:       "",
+		Table:     "",
+		Columns:   []string{},
+		Args:      []string{},
+		BatchSize: 100,
+		Conflict: SQLInsertConflictConfig{
+			Action: "error",
+			Keys:   []string{},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SQLInsert is a benthos writer.Type implementation that inserts the parts of
+// messages as rows of an SQL table.
+type SQLInsert struct {
+	conf SQLInsertConfig
+	args []*text.InterpolatedString
+
+	db    *sql.DB
+	dbMut sync.Mutex
+
+	log   log.Modular
+	stats metrics.Type
+
+	mRows metrics.StatCounter
+}
+
+// NewSQLInsert creates a new SQLInsert writer.Type.
+func NewSQLInsert(conf SQLInsertConfig, log log.Modular, stats metrics.Type) (*SQLInsert, error) {
+	switch conf.Driver {
+	case "mysql", "postgres", "sqlite3", "mssql":
+	default:
+		return nil, fmt.Errorf("driver '%v' is not supported", conf.Driver)
+	}
+	if !sqlDriverRegistered(conf.Driver) {
+		return nil, fmt.Errorf("driver '%v' is not available in this build", conf.Driver)
+	}
+	if len(conf.Table) == 0 {
+		return nil, errors.New("a table must be specified")
+	}
+	if len(conf.Columns) == 0 {
+		return nil, errors.New("at least one column must be specified")
+	}
+	if len(conf.Columns) != len(conf.Args) {
+		return nil, fmt.Errorf("the number of args (%v) must match the number of columns (%v)", len(conf.Args), len(conf.Columns))
+	}
+	if conf.BatchSize < 1 {
+		return nil, fmt.Errorf("batch_size '%v' must be > 0", conf.BatchSize)
+	}
+	switch conf.Conflict.Action {
+	case "error", "ignore":
+	case "update":
+		if conf.Driver != "mysql" && len(conf.Conflict.Keys) == 0 {
+			return nil, errors.New("on_conflict.keys must be specified in order to update conflicting rows")
+		}
+	default:
+		return nil, fmt.Errorf("on_conflict action '%v' is not supported", conf.Conflict.Action)
+	}
+	if conf.Driver == "mssql" && conf.Conflict.Action != "error" && len(conf.Conflict.Keys) == 0 {
+		return nil, errors.New("on_conflict.keys must be specified in order to handle conflicts with the mssql driver")
+	}
+
+	s := &SQLInsert{
+		conf:  conf,
+		log:   log,
+		stats: stats,
+		mRows: stats.GetCounter("rows.sent"),
+	}
+	for _, v := range conf.Args {
+		s.args = append(s.args, text.NewInterpolatedString(v))
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// sqlPlaceholder returns the placeholder of the nth (from 1) argument of a
+// query for a driver.
+func sqlPlaceholder(driver string, n int) string {
+	switch driver {
+	case "postgres":
+		return fmt.Sprintf("$%v", n)
+	case "mssql":
+		return fmt.Sprintf("@p%v", n)
+	}
+	return "?"
+}
+
+// sqlDriverRegistered returns true if a driver has been registered with the
+// database/sql package, which depends on the build of Benthos.
+func sqlDriverRegistered(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlInsertQuery builds a query that inserts a number of rows into the table
+// of a config, handling conflicts as configured.
+func sqlInsertQuery(conf SQLInsertConfig, rows int) string {
+	n := 0
+	values := make([]string, rows)
+	for i := range values {
+		placeholders := make([]string, len(conf.Columns))
+		for j := range placeholders {
+			n++
+			placeholders[j] = sqlPlaceholder(conf.Driver, n)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	columns := strings.Join(conf.Columns, ", ")
+	isKey := map[string]bool{}
+	for _, k := range conf.Conflict.Keys {
+		isKey[k] = true
+	}
+
+	if conf.Driver == "mssql" && conf.Conflict.Action != "error" {
+		var on, updates, sources []string
+		for _, k := range conf.Conflict.Keys {
+			on = append(on, fmt.Sprintf("target.%v = source.%v", k, k))
+		}
+		for _, c := range conf.Columns {
+			sources = append(sources, "source."+c)
+			if !isKey[c] {
+				updates = append(updates, fmt.Sprintf("%v = source.%v", c, c))
+			}
+		}
+		query := fmt.Sprintf(
+			"MERGE INTO %v AS target USING (VALUES %v) AS source (%v) ON %v",
+			conf.Table, strings.Join(values, ", "), columns, strings.Join(on, " AND "),
+		)
+		if conf.Conflict.Action == "update" && len(updates) > 0 {
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ", ")
+		}
+		return query + fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%v) VALUES (%v);", columns, strings.Join(sources, ", "))
+	}
+
+	insert := "INSERT INTO"
+	if conf.Driver == "mysql" && conf.Conflict.Action == "ignore" {
+		insert = "INSERT IGNORE INTO"
+	}
+	query := fmt.Sprintf("%v %v (%v) VALUES %v", insert, conf.Table, columns, strings.Join(values, ", "))
+
+	switch conf.Conflict.Action {
+	case "ignore":
+		if conf.Driver == "mysql" {
+			break
+		}
+		query += " ON CONFLICT"
+		if len(conf.Conflict.Keys) > 0 {
+			query += " (" + strings.Join(conf.Conflict.Keys, ", ") + ")"
+		}
+		query += " DO NOTHING"
+	case "update":
+		var updates []string
+		for _, c := range conf.Columns {
+			if isKey[c] {
+				continue
+			}
+			if conf.Driver == "mysql" {
+				updates = append(updates, fmt.Sprintf("%v = VALUES(%v)", c, c))
+			} else {
+				updates = append(updates, fmt.Sprintf("%v = excluded.%v", c, c))
+			}
+		}
+		if conf.Driver == "mysql" {
+			query += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+		} else if len(updates) == 0 {
+			query += " ON CONFLICT (" + strings.Join(conf.Conflict.Keys, ", ") + ") DO NOTHING"
+		} else {
+			query += " ON CONFLICT (" + strings.Join(conf.Conflict.Keys, ", ") + ") DO UPDATE SET " + strings.Join(updates, ", ")
+		}
+	}
+	return query
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a connection to the database.
+func (s *SQLInsert) Connect() error {
+	s.dbMut.Lock()
+	defer s.dbMut.Unlock()
+
+	if s.db != nil {
+		return nil
+	}
+
+	db, err := sql.Open(s.conf.Driver, s.conf.DSN)
+	if err != nil {
+		return err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	s.log.Infof("Inserting messages into SQL table: %v\n", s.conf.Table)
+	return nil
+}
+
+// Write attempts to insert the parts of a message as rows within a single
+// transaction, using an insert statement for each batch of rows.
+func (s *SQLInsert) Write(msg types.Message) error {
+	s.dbMut.Lock()
+	db := s.db
+	s.dbMut.Unlock()
+
+	if db == nil {
+		return types.ErrNotConnected
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < msg.Len(); start += s.conf.BatchSize {
+		end := start + s.conf.BatchSize
+		if end > msg.Len() {
+			end = msg.Len()
+		}
+		args := make([]interface{}, 0, (end-start)*len(s.args))
+		for i := start; i < end; i++ {
+			lMsg := message.Lock(msg, i)
+			for _, arg := range s.args {
+				args = append(args, arg.Get(lMsg))
+			}
+		}
+		if _, err = tx.Exec(sqlInsertQuery(s.conf, end-start), args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	s.mRows.Incr(int64(msg.Len()))
+	return nil
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (s *SQLInsert) CloseAsync() {
+	s.dbMut.Lock()
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+	s.dbMut.Unlock()
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (s *SQLInsert) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
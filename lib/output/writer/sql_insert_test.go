@@ -0,0 +1,174 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+func TestSQLInsertBadConfig(t *testing.T) {
+	for name, fn := range map[string]func(c *SQLInsertConfig){
+		"bad driver":     func(c *SQLInsertConfig) { c.Driver = "nope" },
+		"no table":       func(c *SQLInsertConfig) { c.Table = "" },
+		"no columns":     func(c *SQLInsertConfig) { c.Columns, c.Args = nil, nil },
+		"args mismatch":  func(c *SQLInsertConfig) { c.Args = c.Args[:1] },
+		"bad batch size": func(c *SQLInsertConfig) { c.BatchSize = 0 },
+		"bad action":     func(c *SQLInsertConfig) { c.Conflict.Action = "nope" },
+		"update no keys": func(c *SQLInsertConfig) { c.Driver, c.Conflict.Action = "postgres", "update" },
+		"mssql no keys":  func(c *SQLInsertConfig) { c.Driver, c.Conflict.Action = "mssql", "ignore" },
+	} {
+		conf := NewSQLInsertConfig()
+		conf.Table = "foo"
+		conf.Columns = []string{"a", "b"}
+		conf.Args = []string{"${!json_field:a}", "${!json_field:b}"}
+		fn(&conf)
+		if _, err := NewSQLInsert(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestSQLInsertQuery(t *testing.T) {
+	type test struct {
+		driver string
+		action string
+		keys   []string
+		exp    string
+	}
+	tests := []test{
+		{"mysql", "error", nil, "INSERT INTO foo (id, a, b) VALUES (?, ?, ?), (?, ?, ?)"},
+		{"mysql", "ignore", nil, "INSERT IGNORE INTO foo (id, a, b) VALUES (?, ?, ?), (?, ?, ?)"},
+		{"mysql", "update", nil, "INSERT INTO foo (id, a, b) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE id = VALUES(id), a = VALUES(a), b = VALUES(b)"},
+		{"postgres", "error", nil, "INSERT INTO foo (id, a, b) VALUES ($1, $2, $3), ($4, $5, $6)"},
+		{"postgres", "ignore", nil, "INSERT INTO foo (id, a, b) VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT DO NOTHING"},
+		{"postgres", "update", []string{"id"}, "INSERT INTO foo (id, a, b) VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT (id) DO UPDATE SET a = excluded.a, b = excluded.b"},
+		{"sqlite3", "ignore", []string{"id"}, "INSERT INTO foo (id, a, b) VALUES (?, ?, ?), (?, ?, ?) ON CONFLICT (id) DO NOTHING"},
+		{"mssql", "error", nil, "INSERT INTO foo (id, a, b) VALUES (@p1, @p2, @p3), (@p4, @p5, @p6)"},
+		{"mssql", "ignore", []string{"id"}, "MERGE INTO foo AS target USING (VALUES (@p1, @p2, @p3), (@p4, @p5, @p6)) AS source (id, a, b) ON target.id = source.id WHEN NOT MATCHED THEN INSERT (id, a, b) VALUES (source.id, source.a, source.b);"},
+		{"mssql", "update", []string{"id"}, "MERGE INTO foo AS target USING (VALUES (@p1, @p2, @p3), (@p4, @p5, @p6)) AS source (id, a, b) ON target.id = source.id WHEN MATCHED THEN UPDATE SET a = source.a, b = source.b WHEN NOT MATCHED THEN INSERT (id, a, b) VALUES (source.id, source.a, source.b);"},
+	}
+	for _, test := range tests {
+		conf := NewSQLInsertConfig()
+		conf.Driver = test.driver
+		conf.Table = "foo"
+		conf.Columns = []string{"id", "a", "b"}
+		conf.Conflict.Action = test.action
+		conf.Conflict.Keys = test.keys
+		if act := sqlInsertQuery(conf, 2); act != test.exp {
+			t.Errorf("Wrong query for %v %v: %v != %v", test.driver, test.action, act, test.exp)
+		}
+	}
+}
+
+func TestSQLInsertSQLite(t *testing.T) {
+	if !sqlDriverRegistered("sqlite3") {
+		t.Skip("sqlite3 driver requires cgo")
+	}
+
+	dir, err := ioutil.TempDir("", "benthos_sql_insert_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dsn := filepath.Join(dir, "test.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("CREATE TABLE foo (id INTEGER PRIMARY KEY, name TEXT, count INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewSQLInsertConfig()
+	conf.Driver = "sqlite3"
+	conf.DSN = dsn
+	conf.Table = "foo"
+	conf.Columns = []string{"id", "name", "count"}
+	conf.Args = []string{"${!json_field:id}", "${!json_field:name}", "${!json_field:count}"}
+	conf.BatchSize = 2
+	conf.Conflict.Action = "update"
+	conf.Conflict.Keys = []string{"id"}
+
+	w, err := NewSQLInsert(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.CloseAsync()
+
+	if err = w.Write(message.New([][]byte{
+		[]byte(`{"id":1,"name":"foo","count":1}`),
+		[]byte(`{"id":2,"name":"bar","count":2}`),
+		[]byte(`{"id":3,"name":"baz","count":3}`),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Write(message.New([][]byte{
+		[]byte(`{"id":2,"name":"bar","count":20}`),
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed batch is rolled back as a whole.
+	if err = w.Write(message.New([][]byte{
+		[]byte(`{"id":4,"name":"qux","count":4}`),
+		[]byte(`{"id":"nope","name":"quz","count":5}`),
+	})); err == nil {
+		t.Error("Expected error from bad id")
+	}
+
+	rows, err := db.Query("SELECT id, name, count FROM foo ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		id    int
+		name  string
+		count int
+	}
+	var act []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.id, &r.name, &r.count); err != nil {
+			t.Fatal(err)
+		}
+		act = append(act, r)
+	}
+	exp := []row{{1, "foo", 1}, {2, "bar", 20}, {3, "baz", 3}}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong rows: %v != %v", act, exp)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/zmtp"
+)
+
+//------------------------------------------------------------------------------
+
+// ZMQ4NConfig contains configuration fields for the ZMQ4N output type.
+type ZMQ4NConfig struct {
+	URLs          []string `json:"urls" yaml:"urls"`
+	Bind          bool     `json:"bind" yaml:"bind"`
+	SocketType    string   `json:"socket_type" yaml:"socket_type"`
+	HighWaterMark int      `json:"high_water_mark" yaml:"high_water_mark"`
+	PollTimeout   string   `json:"poll_timeout" yaml:"poll_timeout"`
+}
+
+// NewZMQ4NConfig creates a new ZMQ4NConfig with default values.
+func NewZMQ4NConfig() ZMQ4NConfig {
+	return ZMQ4NConfig{
+		URLs:          []string{"tcp://*:5556"},
+		Bind:          true,
+		SocketType:    "PUSH",
+		HighWaterMark: 0,
+		PollTimeout:   "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ZMQ4N is an output type that writes ZMQ messages using a pure Go
+// implementation of the ZMTP protocol.
+type ZMQ4N struct {
+	log   log.Modular
+	stats metrics.Type
+
+	urls []string
+	conf ZMQ4NConfig
+
+	typ         zmtp.SocketType
+	pollTimeout time.Duration
+
+	mut    sync.Mutex
+	socket *zmtp.Socket
+}
+
+// NewZMQ4N creates a new ZMQ4N output type.
+func NewZMQ4N(conf ZMQ4NConfig, log log.Modular, stats metrics.Type) (*ZMQ4N, error) {
+	z := ZMQ4N{
+		log:   log,
+		stats: stats,
+		conf:  conf,
+	}
+
+	switch conf.SocketType {
+	case "PUB":
+		z.typ = zmtp.PUB
+	case "PUSH":
+		z.typ = zmtp.PUSH
+	default:
+		return nil, types.ErrInvalidZMQType
+	}
+
+	if tout := conf.PollTimeout; len(tout) > 0 {
+		var err error
+		if z.pollTimeout, err = time.ParseDuration(tout); err != nil {
+			return nil, fmt.Errorf("failed to parse poll timeout string: %v", err)
+		}
+	}
+
+	for _, u := range conf.URLs {
+		for _, splitU := range strings.Split(u, ",") {
+			if len(splitU) > 0 {
+				z.urls = append(z.urls, splitU)
+			}
+		}
+	}
+
+	return &z, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect attempts to establish a ZMQ4N socket.
+func (z *ZMQ4N) Connect() (err error) {
+	z.mut.Lock()
+	defer z.mut.Unlock()
+
+	if z.socket != nil {
+		return nil
+	}
+
+	var socket *zmtp.Socket
+	if socket, err = zmtp.NewSocket(z.typ, z.conf.HighWaterMark); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			socket.Close()
+		}
+	}()
+
+	for _, address := range z.urls {
+		if z.conf.Bind {
+			err = socket.Bind(address)
+		} else {
+			err = socket.Connect(address)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	z.socket = socket
+
+	z.log.Infof("Sending ZMQ4N messages to URLs: %s\n", z.urls)
+	return nil
+}
+
+// Write will attempt to write a message to the ZMQ4N socket.
+func (z *ZMQ4N) Write(msg types.Message) error {
+	z.mut.Lock()
+	socket := z.socket
+	z.mut.Unlock()
+
+	if socket == nil {
+		return types.ErrNotConnected
+	}
+
+	ctx := context.Background()
+	if z.pollTimeout > 0 {
+		var done func()
+		ctx, done = context.WithTimeout(ctx, z.pollTimeout)
+		defer done()
+	}
+
+	err := socket.Send(ctx, message.GetAllBytes(msg))
+	if err == zmtp.ErrClosed {
+		return types.ErrNotConnected
+	}
+	if err == context.DeadlineExceeded {
+		return types.ErrTimeout
+	}
+	return err
+}
+
+// CloseAsync shuts down the ZMQ4N output and stops processing messages.
+func (z *ZMQ4N) CloseAsync() {
+	z.mut.Lock()
+	if z.socket != nil {
+		go z.socket.Close()
+		z.socket = nil
+	}
+	z.mut.Unlock()
+}
+
+// WaitForClose blocks until the ZMQ4N output has closed down.
+func (z *ZMQ4N) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,90 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/zmtp"
+)
+
+func TestZMQ4NPush(t *testing.T) {
+	conf := NewZMQ4NConfig()
+	conf.URLs = []string{"tcp://127.0.0.1:0"}
+	conf.PollTimeout = "100ms"
+
+	z, err := NewZMQ4N(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = z.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		z.CloseAsync()
+		if err := z.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	pull, err := zmtp.NewSocket(zmtp.PULL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pull.Close()
+	if err = pull.Connect("tcp://" + z.socket.Addrs()[0].String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = z.Write(message.New([][]byte{[]byte("foo"), []byte("bar")})); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+	parts, err := pull.Recv(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, len(parts); exp != act {
+		t.Fatalf("Wrong count of parts: %v != %v", act, exp)
+	}
+	if exp, act := "foo", string(parts[0]); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	if exp, act := "bar", string(parts[1]); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+}
+
+func TestZMQ4NBadType(t *testing.T) {
+	conf := NewZMQ4NConfig()
+	conf.SocketType = "PULL"
+	if _, err := NewZMQ4N(conf, log.Noop(), metrics.Noop()); err != types.ErrInvalidZMQType {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrInvalidZMQType)
+	}
+}
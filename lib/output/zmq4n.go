@@ -0,0 +1,68 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeZMQ4N] = TypeSpec{
+		constructor: NewZMQ4N,
+		description: `
+Sends messages to a ZeroMQ socket using a pure Go implementation of the ZeroMQ
+Message Transport Protocol, which unlike the ` + "`zmq4`" + ` output does not
+depend on C bindings and is therefore included in the default build.
+
+Version 3 of the protocol is supported with the NULL security mechanism, which
+is compatible with peers using libzmq 4.x. Endpoints may use the
+` + "`tcp`" + ` or ` + "`ipc`" + ` transports, e.g. ` + "`tcp://*:5556`" + `
+or ` + "`ipc:///tmp/benthos.sock`" + `.
+
+The ` + "`socket_type`" + ` can be either ` + "`PUSH`" + ` or
+` + "`PUB`" + `. A ` + "`PUSH`" + ` socket distributes messages across its
+peers and blocks until a peer is available or the ` + "`poll_timeout`" + `
+elapses, whereas a ` + "`PUB`" + ` socket sends each message to all
+subscribed peers and drops messages for peers that are not keeping up.
+
+The ` + "`high_water_mark`" + ` sets the maximum number of messages queued
+by the socket, or by each peer of a ` + "`PUB`" + ` socket, where zero
+results in a default of 1000.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewZMQ4N creates a new ZMQ4N output type.
+func NewZMQ4N(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	z, err := writer.NewZMQ4N(conf.ZMQ4N, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(TypeZMQ4N, z, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -34,6 +34,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/parquet"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	olog "github.com/opentracing/opentracing-go/log"
 )
@@ -46,7 +47,7 @@ func init() {
 		description: `
 Archives all the messages of a batch into a single message according to the
 selected archive format. Supported archive formats are:
-` + "`tar`, `zip`, `binary`, `lines` and `json_array`." + `
+` + "`tar`, `zip`, `binary`, `lines`, `json_array` and `parquet`." + `
 
 Some archive formats (such as tar, zip) treat each archive item (message part)
 as a file with a path. Since message parts only contain raw data a unique path
@@ -59,7 +60,62 @@ The ` + "`json_array`" + ` format attempts to JSON parse each message and append
 the result to an array, which becomes the contents of the resulting message.
 
 The resulting archived message adopts the metadata of the _first_ message part
-of the batch.`,
+of the batch.
+
+### Parquet
+
+The ` + "`parquet`" + ` format attempts to JSON parse each message as an object
+and writes the batch as a Parquet file, where each message becomes a row. The
+columns of the file are listed in the field ` + "`parquet.schema`" + `, where
+each column has the name of a top level field of the messages and a type, which
+can be one of ` + "`boolean`, `int32`, `int64`, `float`, `double`, `bytes`," + `
+` + "`string`, `json` or `timestamp`" + `. Missing fields and null values are
+written as nulls.
+
+Values of ` + "`json`" + ` columns are serialised as JSON documents, which is
+useful for nested structures. Values of ` + "`timestamp`" + ` columns are parsed
+from either RFC3339 strings or numbers of milliseconds since the unix epoch.
+
+When the schema is left empty it is inferred from the fields of the first batch
+and then reused for all subsequent batches. Booleans, strings and numbers result
+in ` + "`boolean`, `string` and either `int64` or `double`" + ` columns, and all
+other values result in ` + "`json`" + ` columns.
+
+Pages are compressed with the codec ` + "`parquet.compression`" + `, which can be
+one of ` + "`uncompressed`, `snappy` (default), `gzip` or `zstd`" + `. Combined
+with the ` + "[`batch`](#batch)" + ` processor this allows outputs such as
+` + "`s3`" + ` to write Parquet files:
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - batch:
+      count: 1000
+      period: 1m
+  - archive:
+      format: parquet
+      parquet:
+        schema:
+        - name: id
+          type: int64
+        - name: content
+          type: string
+output:
+  s3:
+    bucket: TODO
+    path: ${!count:files}-${!timestamp_unix_nano}.parquet
+    content_type: application/vnd.apache.parquet
+` + "```" + ``,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			m := map[string]interface{}{
+				"format": conf.Archive.Format,
+				"path":   conf.Archive.Path,
+			}
+			if conf.Archive.Format == "parquet" {
+				m["parquet"] = conf.Archive.Parquet
+			}
+			return m, nil
+		},
 	}
 }
 
@@ -67,15 +123,38 @@ of the batch.`,
 
 // ArchiveConfig contains configuration fields for the Archive processor.
 type ArchiveConfig struct {
-	Format string `json:"format" yaml:"format"`
-	Path   string `json:"path" yaml:"path"`
+	Format  string               `json:"format" yaml:"format"`
+	Path    string               `json:"path" yaml:"path"`
+	Parquet ArchiveParquetConfig `json:"parquet" yaml:"parquet"`
 }
 
 // NewArchiveConfig returns a ArchiveConfig with default values.
 func NewArchiveConfig() ArchiveConfig {
 	return ArchiveConfig{
-		Format: "binary",
-		Path:   "${!count:files}-${!timestamp_unix_nano}.txt",
+		Format:  "binary",
+		Path:    "${!count:files}-${!timestamp_unix_nano}.txt",
+		Parquet: NewArchiveParquetConfig(),
+	}
+}
+
+// ArchiveParquetColumnConfig describes a column of the parquet archive format.
+type ArchiveParquetColumnConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+}
+
+// ArchiveParquetConfig contains configuration fields for the parquet archive
+// format.
+type ArchiveParquetConfig struct {
+	Schema      []ArchiveParquetColumnConfig `json:"schema" yaml:"schema"`
+	Compression string                       `json:"compression" yaml:"compression"`
+}
+
+// NewArchiveParquetConfig returns a ArchiveParquetConfig with default values.
+func NewArchiveParquetConfig() ArchiveParquetConfig {
+	return ArchiveParquetConfig{
+		Schema:      []ArchiveParquetColumnConfig{},
+		Compression: "snappy",
 	}
 }
 
@@ -184,8 +263,45 @@ func jsonArrayArchive(hFunc headerFunc, msg types.Message) (types.Part, error) {
 	return newPart, nil
 }
 
-func strToArchiver(str string) (archiveFunc, error) {
-	switch str {
+func parquetArchiver(conf ArchiveParquetConfig) (archiveFunc, error) {
+	columns := make([]parquet.Column, len(conf.Schema))
+	for i, c := range conf.Schema {
+		columns[i] = parquet.Column{Name: c.Name, Type: c.Type}
+	}
+	w, err := parquet.NewWriter(columns, conf.Compression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet writer: %v", err)
+	}
+	return func(hFunc headerFunc, msg types.Message) (types.Part, error) {
+		rows := make([]map[string]interface{}, msg.Len())
+		err := msg.Iter(func(i int, part types.Part) error {
+			doc, jerr := part.JSON()
+			if jerr != nil {
+				return fmt.Errorf("failed to parse message as JSON: %v", jerr)
+			}
+			obj, ok := doc.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("expected message to be a JSON object, got %T", doc)
+			}
+			rows[i] = obj
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := w.Encode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode parquet file: %v", err)
+		}
+		newPart := msg.Get(0).Copy()
+		newPart.Set(b)
+		return newPart, nil
+	}, nil
+}
+
+func strToArchiver(conf ArchiveConfig) (archiveFunc, error) {
+	switch conf.Format {
 	case "tar":
 		return tarArchive, nil
 	case "zip":
@@ -196,8 +312,10 @@ func strToArchiver(str string) (archiveFunc, error) {
 		return linesArchive, nil
 	case "json_array":
 		return jsonArrayArchive, nil
+	case "parquet":
+		return parquetArchiver(conf.Parquet)
 	}
-	return nil, fmt.Errorf("archive format not recognised: %v", str)
+	return nil, fmt.Errorf("archive format not recognised: %v", conf.Format)
 }
 
 //------------------------------------------------------------------------------
@@ -228,7 +346,7 @@ func NewArchive(
 	pathBytes := []byte(conf.Archive.Path)
 	interpolatePath := text.ContainsFunctionVariables(pathBytes)
 
-	archiver, err := strToArchiver(conf.Archive.Format)
+	archiver, err := strToArchiver(conf.Archive)
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +24,7 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -34,6 +35,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/parquet"
 )
 
 func TestArchiveBadAlgo(t *testing.T) {
@@ -243,6 +245,148 @@ func TestArchiveJSONArray(t *testing.T) {
 	}
 }
 
+func readParquetRows(t *testing.T, b []byte) string {
+	t.Helper()
+	r, err := parquet.NewReaderFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []interface{}
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	rowsBytes, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(rowsBytes)
+}
+
+func TestArchiveParquet(t *testing.T) {
+	conf := NewConfig()
+	conf.Archive.Format = "parquet"
+	conf.Archive.Parquet.Schema = []ArchiveParquetColumnConfig{
+		{Name: "id", Type: "int64"},
+		{Name: "content", Type: "string"},
+		{Name: "tags", Type: "json"},
+	}
+
+	proc, err := NewArchive(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":1,"content":"foo","tags":["a","b"]}`),
+		[]byte(`{"id":2,"content":"bar","other":"ignored"}`),
+		[]byte(`{"id":3}`),
+	})
+	msg.Get(0).Metadata().Set("foo", "bar")
+	msgs, res := proc.ProcessMessage(msg)
+	if len(msgs) != 1 {
+		t.Fatal("Archive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+	if msgs[0].Len() != 1 {
+		t.Fatal("More parts than expected")
+	}
+	if HasFailed(msgs[0].Get(0)) {
+		t.Fatalf("Archive failed: %v", msgs[0].Get(0).Metadata().Get(FailFlagKey))
+	}
+	if exp, act := "bar", msgs[0].Get(0).Metadata().Get("foo"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+
+	exp := `[` +
+		`{"content":"foo","id":1,"tags":["a","b"]},` +
+		`{"content":"bar","id":2,"tags":null},` +
+		`{"content":null,"id":3,"tags":null}` +
+		`]`
+	if act := readParquetRows(t, msgs[0].Get(0).Get()); act != exp {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+}
+
+func TestArchiveParquetInferred(t *testing.T) {
+	conf := NewConfig()
+	conf.Archive.Format = "parquet"
+	conf.Archive.Parquet.Compression = "gzip"
+
+	proc, err := NewArchive(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"id":1,"content":"foo"}`),
+		[]byte(`{"id":2,"score":0.5}`),
+	}))
+	if len(msgs) != 1 || msgs[0].Len() != 1 {
+		t.Fatal("Archive failed")
+	}
+	exp := `[{"content":"foo","id":1,"score":null},{"content":null,"id":2,"score":0.5}]`
+	if act := readParquetRows(t, msgs[0].Get(0).Get()); act != exp {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+
+	// Subsequent batches reuse the schema of the first.
+	msgs, _ = proc.ProcessMessage(message.New([][]byte{
+		[]byte(`{"id":3,"new":"field"}`),
+	}))
+	if len(msgs) != 1 || msgs[0].Len() != 1 {
+		t.Fatal("Archive failed")
+	}
+	exp = `[{"content":null,"id":3,"score":null}]`
+	if act := readParquetRows(t, msgs[0].Get(0).Get()); act != exp {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+}
+
+func TestArchiveParquetErrors(t *testing.T) {
+	conf := NewConfig()
+	conf.Archive.Format = "parquet"
+	conf.Archive.Parquet.Compression = "nope"
+	if _, err := NewArchive(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad compression")
+	}
+
+	conf = NewConfig()
+	conf.Archive.Format = "parquet"
+	conf.Archive.Parquet.Schema = []ArchiveParquetColumnConfig{
+		{Name: "id", Type: "nope"},
+	}
+	if _, err := NewArchive(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad column type")
+	}
+
+	conf.Archive.Parquet.Schema[0].Type = "int64"
+	proc, err := NewArchive(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{`not json`, `["not","an","object"]`, `{"id":"not a number"}`} {
+		msg := message.New([][]byte{[]byte(input)})
+		msgs, _ := proc.ProcessMessage(msg)
+		if len(msgs) != 1 {
+			t.Fatal("Expected a message")
+		}
+		if !HasFailed(msgs[0].Get(0)) {
+			t.Errorf("Expected failure from %v", input)
+		}
+		if exp, act := input, string(msgs[0].Get(0).Get()); exp != act {
+			t.Errorf("Expected original message: %v != %v", act, exp)
+		}
+	}
+}
+
 func TestArchiveBinary(t *testing.T) {
 	conf := NewConfig()
 	conf.Archive.Format = "binary"
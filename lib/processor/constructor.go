@@ -128,6 +128,7 @@ const (
 	TypeText         = "text"
 	TypeTry          = "try"
 	TypeThrottle     = "throttle"
+	TypeTracing      = "tracing"
 	TypeUnarchive    = "unarchive"
 	TypeWhile        = "while"
 	TypeXML          = "xml"
@@ -187,6 +188,7 @@ type Config struct {
 	Text         TextConfig         `json:"text" yaml:"text"`
 	Try          TryConfig          `json:"try" yaml:"try"`
 	Throttle     ThrottleConfig     `json:"throttle" yaml:"throttle"`
+	Tracing      TracingConfig      `json:"tracing" yaml:"tracing"`
 	Unarchive    UnarchiveConfig    `json:"unarchive" yaml:"unarchive"`
 	While        WhileConfig        `json:"while" yaml:"while"`
 	XML          XMLConfig          `json:"xml" yaml:"xml"`
@@ -245,6 +247,7 @@ func NewConfig() Config {
 		Text:         NewTextConfig(),
 		Try:          NewTryConfig(),
 		Throttle:     NewThrottleConfig(),
+		Tracing:      NewTracingConfig(),
 		Unarchive:    NewUnarchiveConfig(),
 		While:        NewWhileConfig(),
 		XML:          NewXMLConfig(),
@@ -0,0 +1,174 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/opentracing/opentracing-go/ext"
+	olog "github.com/opentracing/opentracing-go/log"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeTracing] = TypeSpec{
+		constructor: NewTracing,
+		description: `
+Adds metadata values of messages to their tracing spans as tags, and records
+messages that have failed a processing step as error events of their spans.
+Messages are otherwise left unchanged.
+
+Each message is allocated a span when it is ingested by an input which lasts
+until the message is delivered, and it is this span that is modified rather
+than a new child span. Metadata keys listed in ` + "`metadata_keys`" + ` that
+are present on a message are set as tags of the span with the same name.
+
+When ` + "`record_errors`" + ` is true and a message has been flagged as failed
+by a prior processor the span is tagged with ` + "`error`" + ` and the error is
+logged as an event of the span, which allows failed messages to be found
+within traces:
+
+` + "``` yaml" + `
+- jmespath:
+    query: 'foo.bar'
+- tracing:
+    metadata_keys: [ kafka_topic, kafka_partition ]
+    record_errors: true
+` + "```" + ``,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// TracingConfig contains configuration fields for the Tracing processor.
+type TracingConfig struct {
+	Parts        []int    `json:"parts" yaml:"parts"`
+	MetadataKeys []string `json:"metadata_keys" yaml:"metadata_keys"`
+	RecordErrors bool     `json:"record_errors" yaml:"record_errors"`
+}
+
+// NewTracingConfig returns a TracingConfig with default values.
+func NewTracingConfig() TracingConfig {
+	return TracingConfig{
+		Parts:        []int{},
+		MetadataKeys: []string{},
+		RecordErrors: true,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Tracing is a processor that adds metadata values and errors of messages to
+// their tracing spans.
+type Tracing struct {
+	conf TracingConfig
+	log  log.Modular
+
+	mCount     metrics.StatCounter
+	mTagged    metrics.StatCounter
+	mErrors    metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewTracing returns a Tracing processor.
+func NewTracing(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	return &Tracing{
+		conf: conf.Tracing,
+		log:  log,
+
+		mCount:     stats.GetCounter("count"),
+		mTagged:    stats.GetCounter("tagged"),
+		mErrors:    stats.GetCounter("errors_recorded"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (t *Tracing) annotate(part types.Part) {
+	span := tracing.GetSpan(part)
+	if span == nil {
+		return
+	}
+
+	meta := part.Metadata()
+	tagged := false
+	for _, k := range t.conf.MetadataKeys {
+		if v := meta.Get(k); len(v) > 0 {
+			span.SetTag(k, v)
+			tagged = true
+		}
+	}
+	if tagged {
+		t.mTagged.Incr(1)
+	}
+
+	if t.conf.RecordErrors && HasFailed(part) {
+		ext.Error.Set(span, true)
+		span.LogFields(
+			olog.String("event", "error"),
+			olog.String("type", meta.Get(FailFlagKey)),
+		)
+		t.mErrors.Incr(1)
+	}
+}
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (t *Tracing) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	t.mCount.Incr(1)
+
+	if len(t.conf.Parts) == 0 {
+		msg.Iter(func(i int, p types.Part) error {
+			t.annotate(p)
+			return nil
+		})
+	} else {
+		for _, i := range t.conf.Parts {
+			t.annotate(msg.Get(i))
+		}
+	}
+
+	t.mBatchSent.Incr(1)
+	t.mSent.Incr(int64(msg.Len()))
+	msgs := [1]types.Message{msg}
+	return msgs[:], nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (t *Tracing) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (t *Tracing) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,163 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestTracing(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	conf := NewConfig()
+	conf.Type = TypeTracing
+	conf.Tracing.MetadataKeys = []string{"foo", "bar"}
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgIn := message.New([][]byte{[]byte("first"), []byte("second")})
+	msgIn.Get(0).Metadata().Set("foo", "foo1").Set("baz", "baz1")
+	msgIn.Get(1).Metadata().Set("bar", "bar2")
+	FlagErr(msgIn.Get(1), errors.New("failed thing"))
+
+	tracing.InitSpans("test", msgIn)
+
+	msgsOut, res := proc.ProcessMessage(msgIn)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if exp, act := msgIn, msgsOut[0]; exp != act {
+		t.Errorf("Wrong message returned: %v != %v", act, exp)
+	}
+
+	tracing.FinishSpans(msgIn)
+
+	spans := tracer.FinishedSpans()
+	if exp, act := 2, len(spans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+
+	if exp, act := map[string]interface{}{"foo": "foo1"}, spans[0].Tags(); !tagsEqual(exp, act) {
+		t.Errorf("Wrong tags on first span: %v != %v", act, exp)
+	}
+	if exp, act := 0, len(spans[0].Logs()); exp != act {
+		t.Errorf("Wrong count of logs on first span: %v != %v", act, exp)
+	}
+
+	if exp, act := map[string]interface{}{"bar": "bar2", "error": true}, spans[1].Tags(); !tagsEqual(exp, act) {
+		t.Errorf("Wrong tags on second span: %v != %v", act, exp)
+	}
+	logs := spans[1].Logs()
+	if exp, act := 1, len(logs); exp != act {
+		t.Fatalf("Wrong count of logs on second span: %v != %v", act, exp)
+	}
+	fields := map[string]string{}
+	for _, f := range logs[0].Fields {
+		fields[f.Key] = f.ValueString
+	}
+	if exp, act := "failed thing", fields["type"]; exp != act {
+		t.Errorf("Wrong error recorded: %v != %v", act, exp)
+	}
+}
+
+func TestTracingParts(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	conf := NewConfig()
+	conf.Type = TypeTracing
+	conf.Tracing.Parts = []int{-1}
+	conf.Tracing.MetadataKeys = []string{"foo"}
+	conf.Tracing.RecordErrors = false
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgIn := message.New([][]byte{[]byte("first"), []byte("second")})
+	msgIn.Get(0).Metadata().Set("foo", "foo1")
+	msgIn.Get(1).Metadata().Set("foo", "foo2")
+	FlagErr(msgIn.Get(1), errors.New("failed thing"))
+
+	tracing.InitSpans("test", msgIn)
+	if _, res := proc.ProcessMessage(msgIn); res != nil {
+		t.Fatal(res.Error())
+	}
+	tracing.FinishSpans(msgIn)
+
+	spans := tracer.FinishedSpans()
+	if exp, act := 2, len(spans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+	if exp, act := 0, len(spans[0].Tags()); exp != act {
+		t.Errorf("Wrong count of tags on first span: %v != %v", act, exp)
+	}
+	if exp, act := map[string]interface{}{"foo": "foo2"}, spans[1].Tags(); !tagsEqual(exp, act) {
+		t.Errorf("Wrong tags on second span: %v != %v", act, exp)
+	}
+	if exp, act := 0, len(spans[1].Logs()); exp != act {
+		t.Errorf("Wrong count of logs on second span: %v != %v", act, exp)
+	}
+}
+
+func TestTracingNoSpans(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeTracing
+	conf.Tracing.MetadataKeys = []string{"foo"}
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgIn := message.New([][]byte{[]byte("first")})
+	msgIn.Get(0).Metadata().Set("foo", "foo1")
+	if _, res := proc.ProcessMessage(msgIn); res != nil {
+		t.Fatal(res.Error())
+	}
+}
+
+func tagsEqual(exp, act map[string]interface{}) bool {
+	if len(exp) != len(act) {
+		return false
+	}
+	for k, v := range exp {
+		if act[k] != v {
+			return false
+		}
+	}
+	return true
+}
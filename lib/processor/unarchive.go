@@ -34,6 +34,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/parquet"
 	olog "github.com/opentracing/opentracing-go/log"
 )
 
@@ -45,7 +46,7 @@ func init() {
 		description: `
 Unarchives messages according to the selected archive format into multiple
 messages within a batch. Supported archive formats are:
-` + "`tar`, `zip`, `binary`, `lines`, `json_documents` and `json_array`." + `
+` + "`tar`, `zip`, `binary`, `lines`, `json_documents`, `json_array` and `parquet`." + `
 
 When a message is unarchived the new messages replaces the original message in
 the batch. Messages that are selected but fail to unarchive (invalid format)
@@ -58,6 +59,9 @@ message.
 The ` + "`json_array`" + ` format attempts to parse the message as a JSON array
 and for each element of the array expands its contents into a new message.
 
+The ` + "`parquet`" + ` format attempts to parse the message as a Parquet file
+and expands each row into a new message as a JSON object.
+
 For the unarchive formats that contain file information (tar, zip), a metadata
 field is added to each message called ` + "`archive_filename`" + ` with the
 extracted filename.`,
@@ -213,6 +217,30 @@ func jsonArrayUnarchive(part types.Part) ([]types.Part, error) {
 	return parts, nil
 }
 
+func parquetUnarchive(part types.Part) ([]types.Part, error) {
+	r, err := parquet.NewReaderFromBytes(part.Get())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message as parquet: %v", err)
+	}
+
+	var parts []types.Part
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		newPart := part.Copy()
+		if err = newPart.SetJSON(row); err != nil {
+			return nil, fmt.Errorf("failed to marshal row into new message: %v", err)
+		}
+		parts = append(parts, newPart)
+	}
+	return parts, nil
+}
+
 func strToUnarchiver(str string) (unarchiveFunc, error) {
 	switch str {
 	case "tar":
@@ -227,6 +255,8 @@ func strToUnarchiver(str string) (unarchiveFunc, error) {
 		return jsonDocumentsUnarchive, nil
 	case "json_array":
 		return jsonArrayUnarchive, nil
+	case "parquet":
+		return parquetUnarchive, nil
 	}
 	return nil, fmt.Errorf("archive format not recognised: %v", str)
 }
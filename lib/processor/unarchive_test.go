@@ -24,6 +24,7 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"reflect"
@@ -276,6 +277,44 @@ func TestUnarchiveJSONArray(t *testing.T) {
 	}
 }
 
+func TestUnarchiveParquet(t *testing.T) {
+	conf := NewConfig()
+	conf.Unarchive.Format = "parquet"
+
+	// A parquet file with the columns id (int64) and name (optional string).
+	file, err := base64.StdEncoding.DecodeString(
+		"UEFSMRUAFSAVICwVBBUAFQYVBgAAAQAAAAAAAAACAAAAAAAAABUAFRoVGiwVBBUAFQYVBgAAAgAAAAMBAwAAAGZvbxUCGTxIBnNjaGVtYRUEABUEJQAYAmlkABUMJQIYBG5hbWUlAAAWBBkcGSwmCBwVBBkVABkYAmlkFQAWBBZCFkImCAAAJkocFQwZFQAZGARuYW1lFQAWBBY8FjwmSgAAFoYBFgQAAGYAAABQQVIx",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := [][]byte{
+		[]byte(`{"id":1,"name":"foo"}`),
+		[]byte(`{"id":2,"name":null}`),
+	}
+
+	proc, err := NewUnarchive(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{file}))
+	if len(msgs) != 1 {
+		t.Error("Unarchive failed")
+	} else if res != nil {
+		t.Errorf("Expected nil response: %v", res)
+	}
+	if act := message.GetAllBytes(msgs[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Unexpected output: %s != %s", act, exp)
+	}
+
+	msgs, _ = proc.ProcessMessage(message.New([][]byte{[]byte("not parquet")}))
+	if !HasFailed(msgs[0].Get(0)) {
+		t.Error("Expected failed flag on invalid parquet")
+	}
+}
+
 func TestUnarchiveBinary(t *testing.T) {
 	conf := NewConfig()
 	conf.Unarchive.Format = "binary"
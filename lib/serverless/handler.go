@@ -32,11 +32,14 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/manager"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/roundtrip"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output"
 	"github.com/Jeffail/benthos/v3/lib/pipeline"
+	"github.com/Jeffail/benthos/v3/lib/stream"
 	"github.com/Jeffail/benthos/v3/lib/tracer"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/opentracing/opentracing-go"
 )
 
 //------------------------------------------------------------------------------
@@ -74,6 +77,44 @@ func (h *Handler) Close(tout time.Duration) error {
 	return h.done(tout)
 }
 
+// lambdaTraceHeader returns the X-Ray trace header of a Lambda invocation,
+// which is provided within the context of the invocation or, for custom
+// runtimes, the environment.
+func lambdaTraceHeader(ctx context.Context) string {
+	if header, _ := ctx.Value("x-amzn-trace-id").(string); len(header) > 0 {
+		return header
+	}
+	return os.Getenv("_X_AMZN_TRACE_ID")
+}
+
+// initSpans sets up a span for the payload of an invocation, which is a child
+// of the span of the Lambda trace header when the tracer is able to extract
+// it. The spans are returned as the contexts of parts are stripped when they
+// are stored as a response.
+func initSpans(ctx context.Context, msg types.Message) []opentracing.Span {
+	initialised := false
+	if header := lambdaTraceHeader(ctx); len(header) > 0 {
+		if parent, err := opentracing.GlobalTracer().Extract(
+			opentracing.TextMap, opentracing.TextMapCarrier{"X-Amzn-Trace-Id": header},
+		); err == nil {
+			tracing.InitSpansFromParent("serverless_handler", parent, msg)
+			initialised = true
+		}
+	}
+	if !initialised {
+		tracing.InitSpans("serverless_handler", msg)
+	}
+
+	spans := make([]opentracing.Span, 0, msg.Len())
+	msg.Iter(func(i int, p types.Part) error {
+		if span := tracing.GetSpan(p); span != nil {
+			spans = append(spans, span)
+		}
+		return nil
+	})
+	return spans
+}
+
 // Handle is a request/response func that injects a payload into the underlying
 // Benthos pipeline and returns a result.
 func (h *Handler) Handle(ctx context.Context, obj interface{}) (interface{}, error) {
@@ -84,6 +125,13 @@ func (h *Handler) Handle(ctx context.Context, obj interface{}) (interface{}, err
 	}
 	msg.Append(part)
 
+	spans := initSpans(ctx, msg)
+	defer func() {
+		for _, s := range spans {
+			s.Finish()
+		}
+	}()
+
 	store := roundtrip.NewResultStore()
 	roundtrip.AddResultStore(msg, store)
 
@@ -149,10 +197,11 @@ func NewHandler(conf config.Type) (*Handler, error) {
 		logger.Errorf("Failed to connect metrics aggregator: %v\n", err)
 		stats = metrics.Noop()
 	}
+	systemStats := metrics.NewSystem(stats)
 
 	// Create our tracer type.
 	var trac tracer.Type
-	if trac, err = tracer.New(conf.Tracer); err != nil {
+	if trac, err = tracer.New(conf.Tracer, tracer.OptSetLogger(logger)); err != nil {
 		logger.Errorf("Failed to initialise tracer: %v\n", err)
 		trac = tracer.Noop()
 	}
@@ -168,6 +217,7 @@ func NewHandler(conf config.Type) (*Handler, error) {
 	var outputLayer types.Output
 
 	transactionChan := make(chan types.Transaction, 1)
+	latency := stream.NewLatencyTracker(stats)
 
 	pipelineLayer, err = pipeline.New(
 		conf.Pipeline, manager,
@@ -183,9 +233,11 @@ func NewHandler(conf config.Type) (*Handler, error) {
 		err = pipelineLayer.Consume(transactionChan)
 	}
 	if err == nil {
-		err = outputLayer.Consume(pipelineLayer.TransactionChan())
+		latency.Consume(pipelineLayer.TransactionChan())
+		err = outputLayer.Consume(latency.TransactionChan())
 	}
 	if err != nil {
+		latency.Close()
 		return nil, fmt.Errorf("failed to create resource: %v", err)
 	}
 
@@ -193,6 +245,7 @@ func NewHandler(conf config.Type) (*Handler, error) {
 		transactionChan: transactionChan,
 		done: func(exitTimeout time.Duration) error {
 			timesOut := time.Now().Add(exitTimeout)
+			defer latency.Close()
 			pipelineLayer.CloseAsync()
 			outputLayer.CloseAsync()
 
@@ -210,6 +263,7 @@ func NewHandler(conf config.Type) (*Handler, error) {
 
 			trac.Close()
 
+			systemStats.Close()
 			if sCloseErr := stats.Close(); sCloseErr != nil {
 				logger.Errorf("Failed to cleanly close metrics aggregator: %v\n", sCloseErr)
 			}
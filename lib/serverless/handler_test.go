@@ -23,9 +23,13 @@ package serverless
 import (
 	"context"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -35,6 +39,8 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/processor"
 
 	"github.com/Jeffail/benthos/v3/lib/config"
+	"github.com/Jeffail/benthos/v3/lib/tracer"
+	"github.com/opentracing/opentracing-go"
 )
 
 func TestHandlerAsync(t *testing.T) {
@@ -101,6 +107,38 @@ func TestHandlerSync(t *testing.T) {
 	}
 }
 
+func TestHandlerLatencyMetric(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_serverless_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := config.New()
+	conf.Output.Type = ServerlessResponseType
+	conf.Metrics.Type = "file"
+	conf.Metrics.File.Path = filepath.Join(dir, "metrics.jsonl")
+
+	h, err := NewHandler(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.Handle(context.Background(), map[string]interface{}{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Close(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+
+	metricsBytes, err := ioutil.ReadFile(conf.Metrics.File.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(metricsBytes), `"metric":"stream"`) || !strings.Contains(string(metricsBytes), `"latency":`) {
+		t.Errorf("Expected stream.latency metric: %s", metricsBytes)
+	}
+}
+
 func TestHandlerSyncBatch(t *testing.T) {
 	conf := config.New()
 	conf.Output.Type = ServerlessResponseType
@@ -221,3 +259,58 @@ func TestHandlerCombined(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHandlerLambdaTraceHeader(t *testing.T) {
+	os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "foo")
+	defer os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	daemon, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer daemon.Close()
+
+	conf := config.New()
+	conf.Output.Type = ServerlessResponseType
+	conf.Tracer.Type = tracer.TypeXRay
+	conf.Tracer.XRay.Address = daemon.LocalAddr().String()
+
+	h, err := NewHandler(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(
+		context.Background(), "x-amzn-trace-id",
+		"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+	)
+	if _, err = h.Handle(ctx, map[string]interface{}{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Close(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+
+	// Child spans of components are also sent, so read until the segment of
+	// the handler is found.
+	var doc string
+	buf := make([]byte, 65536)
+	for !strings.Contains(doc, `"name":"serverless_handler"`) {
+		daemon.SetReadDeadline(time.Now().Add(time.Second * 5))
+		n, _, err := daemon.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc = string(buf[:n])
+	}
+	for _, exp := range []string{
+		`"trace_id":"1-5759e988-bd862e3fe1be46a994272793"`,
+		`"parent_id":"53995c3f42cd8ad8"`,
+		`"type":"subsegment"`,
+	} {
+		if !strings.Contains(doc, exp) {
+			t.Errorf("Expected segment to contain '%v': %v", exp, doc)
+		}
+	}
+}
@@ -59,6 +59,7 @@ import (
 var (
 	Version   string
 	DateBuilt string
+	GitSHA    string
 )
 
 //------------------------------------------------------------------------------
@@ -514,9 +515,18 @@ func Run() {
 		}
 	}()
 
+	// Periodically update system metrics such as memory usage.
+	metrics.SetBuildInfo(Version, GitSHA)
+	systemStats := metrics.NewSystem(stats)
+	defer systemStats.Close()
+
+	// Flush push based metrics on demand.
+	stopFlushOnSignal := flushMetricsOnSignal(stats, logger)
+	defer stopFlushOnSignal()
+
 	// Create our tracer type.
 	var trac tracer.Type
-	if trac, err = tracer.New(config.Tracer); err != nil {
+	if trac, err = tracer.New(config.Tracer, tracer.OptSetLogger(logger)); err != nil {
 		logger.Errorf("Failed to initialise tracer: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// LatencyTracker sits in front of the output layer of a stream and measures the
+// time taken from each message batch being created by the input up to the
+// moment it has been acknowledged by the output.
+type LatencyTracker struct {
+	mLatency metrics.StatTimer
+
+	transactionsOut chan types.Transaction
+
+	closeOnce sync.Once
+	closeChan chan struct{}
+}
+
+// NewLatencyTracker creates a tracker that records the metric stream.latency.
+func NewLatencyTracker(stats metrics.Type) *LatencyTracker {
+	return &LatencyTracker{
+		mLatency:        stats.GetTimer("stream.latency"),
+		transactionsOut: make(chan types.Transaction),
+		closeChan:       make(chan struct{}),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (l *LatencyTracker) loop(transactionsIn <-chan types.Transaction) {
+	defer close(l.transactionsOut)
+
+	for {
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-transactionsIn:
+			if !open {
+				return
+			}
+		case <-l.closeChan:
+			return
+		}
+
+		resChan := make(chan types.Response)
+		select {
+		case l.transactionsOut <- types.NewTransaction(tran.Payload, resChan):
+		case <-l.closeChan:
+			return
+		}
+		go l.awaitResponse(tran, resChan)
+	}
+}
+
+func (l *LatencyTracker) awaitResponse(tran types.Transaction, resChan <-chan types.Response) {
+	var res types.Response
+	var open bool
+	select {
+	case res, open = <-resChan:
+		if !open {
+			return
+		}
+	case <-l.closeChan:
+		return
+	}
+	if res.Error() == nil && !res.SkipAck() {
+		l.mLatency.Timing(time.Since(tran.Payload.CreatedAt()).Nanoseconds())
+	}
+	select {
+	case tran.ResponseChan <- res:
+	case <-l.closeChan:
+	}
+}
+
+// Consume assigns a transactions channel for the tracker to read.
+func (l *LatencyTracker) Consume(transactionsIn <-chan types.Transaction) {
+	go l.loop(transactionsIn)
+}
+
+// TransactionChan returns the channel of transactions to be consumed by the
+// output layer.
+func (l *LatencyTracker) TransactionChan() <-chan types.Transaction {
+	return l.transactionsOut
+}
+
+// Close stops the tracker from forwarding any pending transactions or
+// responses.
+func (l *LatencyTracker) Close() {
+	l.closeOnce.Do(func() {
+		close(l.closeChan)
+	})
+}
+
+//------------------------------------------------------------------------------
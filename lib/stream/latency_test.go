@@ -0,0 +1,97 @@
+// Copyright (c) 2020 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package stream
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	stats := metrics.NewLocal()
+	l := NewLatencyTracker(stats)
+	defer l.Close()
+
+	tranChan := make(chan types.Transaction)
+	l.Consume(tranChan)
+
+	send := func(res types.Response) types.Response {
+		t.Helper()
+		resChan := make(chan types.Response)
+		select {
+		case tranChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+
+		var tran types.Transaction
+		select {
+		case tran = <-l.TransactionChan():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		if exp, act := "foo", string(tran.Payload.Get(0).Get()); exp != act {
+			t.Errorf("Wrong payload: %v != %v", act, exp)
+		}
+
+		select {
+		case tran.ResponseChan <- res:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		select {
+		case res = <-resChan:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		return res
+	}
+
+	if res := send(response.NewError(errors.New("nope"))); res.Error() == nil {
+		t.Error("Expected error response")
+	}
+	if stats.GetTimings()["stream.latency"] != 0 {
+		t.Error("Expected no latency for failed message")
+	}
+
+	if res := send(response.NewAck()); res.Error() != nil {
+		t.Error(res.Error())
+	}
+	if stats.GetTimings()["stream.latency"] == 0 {
+		t.Error("Expected latency for acknowledged message")
+	}
+
+	close(tranChan)
+	select {
+	case _, open := <-l.TransactionChan():
+		if open {
+			t.Error("Expected closed channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
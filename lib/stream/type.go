@@ -46,6 +46,8 @@ type Type struct {
 	pipelineLayer pipeline.Type
 	outputLayer   output.Type
 
+	latency *LatencyTracker
+
 	complementaryProcs []types.ProcessorConstructorFunc
 
 	manager types.Manager
@@ -195,6 +197,9 @@ func (t *Type) start() (err error) {
 		}
 		nextTranChan = t.pipelineLayer.TransactionChan()
 	}
+	t.latency = NewLatencyTracker(t.stats)
+	t.latency.Consume(nextTranChan)
+	nextTranChan = t.latency.TransactionChan()
 	if err = t.outputLayer.Consume(nextTranChan); err != nil {
 		return
 	}
@@ -363,6 +368,8 @@ func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 // Initially the attempt is graceful, but as the timeout draws close the attempt
 // becomes progressively less graceful.
 func (t *Type) Stop(timeout time.Duration) error {
+	defer t.latency.Close()
+
 	tOutUnordered := timeout / 4
 	tOutGraceful := timeout - tOutUnordered
 
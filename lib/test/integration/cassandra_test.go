@@ -0,0 +1,148 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package integration
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/gocql/gocql"
+	"github.com/ory/dockertest"
+)
+
+func TestCassandraIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = time.Minute * 3
+
+	resource, err := pool.Run("cassandra", "latest", nil)
+	if err != nil {
+		t.Fatalf("Could not start resource: %s", err)
+	}
+	defer func() {
+		if err = pool.Purge(resource); err != nil {
+			t.Logf("Failed to clean up docker resource: %v", err)
+		}
+	}()
+	resource.Expire(900)
+
+	addr := fmt.Sprintf("localhost:%v", resource.GetPort("9042/tcp"))
+
+	var session *gocql.Session
+	if err = pool.Retry(func() error {
+		cluster := gocql.NewCluster(addr)
+		cluster.DisableInitialHostLookup = true
+		cluster.Timeout = time.Second * 10
+
+		var cErr error
+		if session, cErr = cluster.CreateSession(); cErr != nil {
+			return cErr
+		}
+		if cErr = session.Query(
+			"CREATE KEYSPACE IF NOT EXISTS benthos WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}",
+		).Exec(); cErr != nil {
+			session.Close()
+			return cErr
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Could not connect to docker resource: %s", err)
+	}
+	defer session.Close()
+
+	t.Run("TestCassandraSingleAndBatch", func(te *testing.T) {
+		testCassandraSingleAndBatch(addr, session, te)
+	})
+}
+
+func testCassandraSingleAndBatch(addr string, session *gocql.Session, t *testing.T) {
+	if err := session.Query(
+		"CREATE TABLE IF NOT EXISTS benthos.single_and_batch (id int PRIMARY KEY, content text, created timestamp)",
+	).Exec(); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := writer.NewCassandraConfig()
+	conf.Addresses = []string{addr}
+	conf.DisableInitialHostLookup = true
+	conf.Timeout = "10s"
+	conf.Query = "INSERT INTO benthos.single_and_batch (id, content, created) VALUES (?, ?, ?)"
+	conf.Args = []string{"${!metadata:id}", "${!content}", "${!metadata:created}"}
+
+	w, err := writer.NewCassandra(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		w.CloseAsync()
+		if cErr := w.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+	}()
+
+	single := message.New([][]byte{[]byte("foo")})
+	single.Get(0).Metadata().Set("id", "1").Set("created", "2020-01-01T00:00:00Z")
+	if err = w.Write(single); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := message.New([][]byte{[]byte("bar"), []byte("baz")})
+	batch.Get(0).Metadata().Set("id", "2").Set("created", "1577836800000")
+	batch.Get(1).Metadata().Set("id", "3").Set("created", "1577836800000")
+	if err = w.Write(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []string
+	iter := session.Query("SELECT id, content, created FROM benthos.single_and_batch").Iter()
+	var id int
+	var content string
+	var created time.Time
+	for iter.Scan(&id, &content, &created) {
+		results = append(results, fmt.Sprintf("%v:%v:%v", id, content, created.Unix()))
+	}
+	if err = iter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(results)
+
+	exp := []string{"1:foo:1577836800", "2:bar:1577836800", "3:baz:1577836800"}
+	if fmt.Sprintf("%v", exp) != fmt.Sprintf("%v", results) {
+		t.Errorf("Wrong results: %v != %v", results, exp)
+	}
+}
@@ -153,6 +153,9 @@ func TestKafkaIntegration(t *testing.T) {
 	t.Run("TestKafkaSinglePart", func(te *testing.T) {
 		testKafkaSinglePart(address, te)
 	})
+	t.Run("TestKafkaIdempotentWrite", func(te *testing.T) {
+		testKafkaIdempotentWrite(address, te)
+	})
 	t.Run("TestKafkaResumeDurable", func(te *testing.T) {
 		testKafkaResumeDurable(address, te)
 	})
@@ -232,6 +235,48 @@ func testKafkaStreamsALO(address string, t *testing.T) {
 	checkALOSynchronousAndDieAsync(outputCtr, inputCtr, t)
 }
 
+func testKafkaIdempotentWrite(address string, t *testing.T) {
+	topic := "benthos_test_idempotent"
+
+	inConf := reader.NewKafkaBalancedConfig()
+	inConf.ClientID = "benthos_test_idempotent"
+	inConf.ConsumerGroup = "benthos_test_idempotent"
+	inConf.Addresses = []string{address}
+	inConf.Topics = []string{topic}
+
+	outConf := writer.NewKafkaConfig()
+	outConf.TargetVersion = "2.1.0"
+	outConf.Addresses = []string{address}
+	outConf.Topic = topic
+	outConf.IdempotentWrite = true
+
+	outputCtr := func() (mOutput writer.Type, err error) {
+		if mOutput, err = writer.NewKafka(outConf, log.Noop(), metrics.Noop()); err != nil {
+			return
+		}
+		err = mOutput.Connect()
+		return
+	}
+	inputCtr := func() (mInput reader.Async, err error) {
+		ctx, done := context.WithTimeout(context.Background(), time.Second)
+		defer done()
+
+		if mInput, err = reader.NewKafkaCG(inConf, nil, log.Noop(), metrics.Noop()); err != nil {
+			return
+		}
+		mInput = reader.NewAsyncPreserver(mInput)
+		err = mInput.ConnectWithContext(ctx)
+		return
+	}
+
+	checkALOSynchronousAsync(outputCtr, inputCtr, t)
+
+	outConf.TargetVersion = "0.10.2.0"
+	if _, err := writer.NewKafka(outConf, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from idempotent writes with old target version")
+	}
+}
+
 func testKafkaSinglePart(address string, t *testing.T) {
 	topic := "benthos_test_single"
 
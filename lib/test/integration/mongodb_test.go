@@ -0,0 +1,191 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/ory/dockertest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMongoDBIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = time.Second * 30
+
+	resource, err := pool.Run("mongo", "4.4", nil)
+	if err != nil {
+		t.Fatalf("Could not start resource: %s", err)
+	}
+	defer func() {
+		if err = pool.Purge(resource); err != nil {
+			t.Logf("Failed to clean up docker resource: %v", err)
+		}
+	}()
+	resource.Expire(900)
+
+	url := fmt.Sprintf("mongodb://localhost:%v", resource.GetPort("27017/tcp"))
+
+	var client *mongo.Client
+	if err = pool.Retry(func() error {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		defer done()
+
+		var cErr error
+		if client, cErr = mongo.Connect(ctx, options.Client().ApplyURI(url)); cErr != nil {
+			return cErr
+		}
+		if cErr = client.Ping(ctx, nil); cErr != nil {
+			client.Disconnect(context.Background())
+			return cErr
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Could not connect to docker resource: %s", err)
+	}
+	defer client.Disconnect(context.Background())
+
+	t.Run("TestMongoDBUpsert", func(te *testing.T) {
+		testMongoDBUpsert(url, client, te)
+	})
+	t.Run("TestMongoDBOrderedErrors", func(te *testing.T) {
+		testMongoDBBulkErrors(url, true, client, te)
+	})
+	t.Run("TestMongoDBUnorderedErrors", func(te *testing.T) {
+		testMongoDBBulkErrors(url, false, client, te)
+	})
+}
+
+func newMongoDBWriter(conf writer.MongoDBConfig, t *testing.T) *writer.MongoDB {
+	t.Helper()
+	w, err := writer.NewMongoDB(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func testMongoDBUpsert(url string, client *mongo.Client, t *testing.T) {
+	conf := writer.NewMongoDBConfig()
+	conf.URL = url
+	conf.Database = "benthos"
+	conf.Collection = "upsert"
+	conf.Operation = "update-one"
+	conf.Filter = `{"_id":"${!json_field:id}"}`
+	conf.Document = `{"$inc":{"count":${!json_field:count}}}`
+	conf.Upsert = true
+	conf.WriteConcern.W = "majority"
+
+	w := newMongoDBWriter(conf, t)
+	defer w.CloseAsync()
+
+	for i := 0; i < 2; i++ {
+		if err := w.Write(message.New([][]byte{
+			[]byte(`{"id":"foo","count":1}`),
+			[]byte(`{"id":"bar","count":2}`),
+			[]byte(`{"id":"foo","count":3}`),
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exp := map[string]int32{"foo": 8, "bar": 4}
+	for id, count := range exp {
+		var doc struct {
+			Count int32 `bson:"count"`
+		}
+		if err := client.Database("benthos").Collection("upsert").FindOne(
+			context.Background(), bson.M{"_id": id},
+		).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Count != count {
+			t.Errorf("Wrong count for %v: %v != %v", id, doc.Count, count)
+		}
+	}
+}
+
+func testMongoDBBulkErrors(url string, ordered bool, client *mongo.Client, t *testing.T) {
+	conf := writer.NewMongoDBConfig()
+	conf.URL = url
+	conf.Database = "benthos"
+	conf.Collection = fmt.Sprintf("bulk_errors_%v", ordered)
+	conf.Ordered = ordered
+
+	w := newMongoDBWriter(conf, t)
+	defer w.CloseAsync()
+
+	err := w.Write(message.New([][]byte{
+		[]byte(`{"_id":"foo"}`),
+		[]byte(`{"_id":"foo"}`),
+		[]byte(`not json`),
+		[]byte(`{"_id":"bar"}`),
+	}))
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, received: %v", err)
+	}
+
+	// The third message is never sent, the second is a duplicate, and the
+	// fourth is only skipped by ordered writes.
+	expFailed := map[int]bool{1: true, 2: true, 3: ordered}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if expFailed[i] != (err != nil) {
+			t.Errorf("Unexpected error for message %v: %v", i, err)
+		}
+		return true
+	})
+
+	n, err := client.Database("benthos").Collection(conf.Collection).CountDocuments(context.Background(), bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := int64(2)
+	if ordered {
+		exp = 1
+	}
+	if n != exp {
+		t.Errorf("Wrong count of documents: %v != %v", n, exp)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	bmqtt "github.com/Jeffail/benthos/v3/lib/util/mqtt"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/ory/dockertest"
+)
+
+func TestMQTTv5Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = time.Second * 30
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "eclipse-mosquitto",
+		Tag:        "2.0",
+		Cmd:        []string{"mosquitto", "-c", "/mosquitto-no-auth.conf"},
+	})
+	if err != nil {
+		t.Fatalf("Could not start resource: %s", err)
+	}
+	defer func() {
+		if err = pool.Purge(resource); err != nil {
+			t.Logf("Failed to clean up docker resource: %v", err)
+		}
+	}()
+	resource.Expire(900)
+
+	url := fmt.Sprintf("tcp://localhost:%v", resource.GetPort("1883/tcp"))
+
+	if err = pool.Retry(func() error {
+		client, cErr := bmqtt.ConnectV5(context.Background(), bmqtt.V5Options{
+			URLs:           []string{url},
+			ClientID:       "UNIT_TEST",
+			ConnectTimeout: time.Second,
+		})
+		if cErr != nil {
+			return cErr
+		}
+		return client.Disconnect(&paho.Disconnect{})
+	}); err != nil {
+		t.Fatalf("Could not connect to docker resource: %s", err)
+	}
+
+	t.Run("TestMQTTv5SharedUserProperties", func(te *testing.T) {
+		testMQTTv5SharedUserProperties(url, te)
+	})
+}
+
+func testMQTTv5SharedUserProperties(url string, t *testing.T) {
+	inConf := reader.NewMQTTConfig()
+	inConf.ProtocolVersion = "5"
+	inConf.ClientID = "foo"
+	inConf.Topics = []string{"test_input_v5"}
+	inConf.SharedGroup = "benthos"
+	inConf.SessionExpiry = "1m"
+	inConf.URLs = []string{url}
+
+	outConf := writer.NewMQTTConfig()
+	outConf.ProtocolVersion = "5"
+	outConf.ClientID = "bar"
+	outConf.Topic = "test_input_v5"
+	outConf.URLs = []string{url}
+
+	mInput, mOutput, err := createMQTTInputOutput(inConf, outConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		mInput.CloseAsync()
+		if cErr := mInput.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+		mOutput.CloseAsync()
+		if cErr := mOutput.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+	}()
+
+	msg := message.New([][]byte{[]byte("hello world")})
+	msg.Get(0).Metadata().Set("foo", "bar")
+	if err = mOutput.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	actM, err := mInput.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "hello world", string(actM.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+	if exp, act := "bar", actM.Get(0).Metadata().Get("foo"); exp != act {
+		t.Errorf("Wrong user property: %v != %v", act, exp)
+	}
+	if exp, act := "test_input_v5", actM.Get(0).Metadata().Get("mqtt_topic"); exp != act {
+		t.Errorf("Wrong topic: %v != %v", act, exp)
+	}
+}
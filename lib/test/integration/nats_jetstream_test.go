@@ -0,0 +1,158 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package integration
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/nats-io/nats.go"
+	"github.com/ory/dockertest"
+)
+
+func TestNATSJetStreamIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = time.Second * 30
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "nats",
+		Tag:        "latest",
+		Cmd:        []string{"-js"},
+	})
+	if err != nil {
+		t.Fatalf("Could not start resource: %s", err)
+	}
+	defer func() {
+		if err = pool.Purge(resource); err != nil {
+			t.Logf("Failed to clean up docker resource: %v", err)
+		}
+	}()
+	resource.Expire(900)
+
+	url := fmt.Sprintf("tcp://localhost:%v", resource.GetPort("4222/tcp"))
+
+	var natsConn *nats.Conn
+	if err = pool.Retry(func() error {
+		var cErr error
+		natsConn, cErr = nats.Connect(url)
+		return cErr
+	}); err != nil {
+		t.Fatalf("Could not connect to docker resource: %s", err)
+	}
+	defer natsConn.Close()
+
+	js, err := natsConn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = js.AddStream(&nats.StreamConfig{
+		Name:     "benthos_test",
+		Subjects: []string{"benthos_test.>"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("TestNATSJetStreamDedupe", func(te *testing.T) {
+		testNATSJetStreamDedupe(url, js, te)
+	})
+	t.Run("TestNATSJetStreamNoStream", func(te *testing.T) {
+		testNATSJetStreamNoStream(url, te)
+	})
+}
+
+func testNATSJetStreamDedupe(url string, js nats.JetStreamContext, t *testing.T) {
+	outConf := writer.NewNATSJetStreamConfig()
+	outConf.URLs = []string{url}
+	outConf.Subject = "benthos_test.${!json_field:type}"
+	outConf.MsgID = "${!json_field:id}"
+
+	mOutput, err := writer.NewNATSJetStream(outConf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mOutput.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mOutput.CloseAsync()
+		if cErr := mOutput.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+	}()
+
+	N := 10
+	parts := [][]byte{}
+	for i := 0; i < N; i++ {
+		parts = append(parts, []byte(fmt.Sprintf(`{"id":"%v","type":"foo"}`, i)))
+	}
+	for j := 0; j < 2; j++ {
+		if err = mOutput.Write(message.New(parts)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := js.StreamInfo("benthos_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := uint64(N), info.State.Msgs; exp != act {
+		t.Errorf("Wrong count of stored messages: %v != %v", act, exp)
+	}
+}
+
+func testNATSJetStreamNoStream(url string, t *testing.T) {
+	outConf := writer.NewNATSJetStreamConfig()
+	outConf.URLs = []string{url}
+	outConf.Subject = "benthos_test_nostream"
+	outConf.AckTimeout = "1s"
+
+	mOutput, err := writer.NewNATSJetStream(outConf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mOutput.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mOutput.CloseAsync()
+		if cErr := mOutput.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+	}()
+
+	if err = mOutput.Write(message.New([][]byte{[]byte("hello world")})); err == nil {
+		t.Error("Expected error from subject without a stream")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/ory/dockertest"
+)
+
+func TestPulsarIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = time.Minute * 2
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "apachepulsar/pulsar",
+		Tag:        "2.6.1",
+		Cmd:        []string{"bin/pulsar", "standalone"},
+	})
+	if err != nil {
+		t.Fatalf("Could not start resource: %s", err)
+	}
+	defer func() {
+		if err = pool.Purge(resource); err != nil {
+			t.Logf("Failed to clean up docker resource: %v", err)
+		}
+	}()
+	resource.Expire(900)
+
+	url := fmt.Sprintf("pulsar://localhost:%v", resource.GetPort("6650/tcp"))
+	adminURL := fmt.Sprintf("http://localhost:%v/admin/v2/clusters", resource.GetPort("8080/tcp"))
+
+	if err = pool.Retry(func() error {
+		res, err := http.Get(adminURL)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status: %v", res.StatusCode)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Could not connect to docker resource: %s", err)
+	}
+
+	t.Run("TestPulsarALO", func(te *testing.T) {
+		testPulsarALO(url, te)
+	})
+	t.Run("TestPulsarALOKeyShared", func(te *testing.T) {
+		testPulsarALOKeyShared(url, te)
+	})
+}
+
+func createPulsarCtrs(
+	inConf reader.PulsarConfig, outConf writer.PulsarConfig,
+) (func() (writer.Type, error), func() (reader.Async, error)) {
+	outputCtr := func() (mOutput writer.Type, err error) {
+		if mOutput, err = writer.NewPulsar(outConf, log.Noop(), metrics.Noop()); err != nil {
+			return
+		}
+		err = mOutput.Connect()
+		return
+	}
+	inputCtr := func() (mInput reader.Async, err error) {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+		defer done()
+
+		if mInput, err = reader.NewPulsar(inConf, log.Noop(), metrics.Noop()); err != nil {
+			return
+		}
+		err = mInput.ConnectWithContext(ctx)
+		return
+	}
+	return outputCtr, inputCtr
+}
+
+func testPulsarALO(url string, t *testing.T) {
+	topic := "benthos_test_alo"
+
+	inConf := reader.NewPulsarConfig()
+	inConf.URL = url
+	inConf.Topics = []string{topic}
+	inConf.SubscriptionName = "benthos_test_alo"
+	inConf.StartFromOldest = true
+
+	outConf := writer.NewPulsarConfig()
+	outConf.URL = url
+	outConf.Topic = topic
+
+	outputCtr, inputCtr := createPulsarCtrs(inConf, outConf)
+	checkALOSynchronousAsync(outputCtr, inputCtr, t)
+
+	inConf.Topics = []string{"benthos_test_alo_with_dc"}
+	outConf.Topic = "benthos_test_alo_with_dc"
+
+	outputCtr, inputCtr = createPulsarCtrs(inConf, outConf)
+	checkALOSynchronousAndDieAsync(outputCtr, inputCtr, t)
+}
+
+func testPulsarALOKeyShared(url string, t *testing.T) {
+	topic := "benthos_test_alo_key_shared"
+
+	inConf := reader.NewPulsarConfig()
+	inConf.URL = url
+	inConf.Topics = []string{topic}
+	inConf.SubscriptionName = "benthos_test_alo_key_shared"
+	inConf.SubscriptionType = "key_shared"
+	inConf.StartFromOldest = true
+
+	outConf := writer.NewPulsarConfig()
+	outConf.URL = url
+	outConf.Topic = topic
+	outConf.Key = "${!content}"
+	outConf.Batching.KeyBased = true
+
+	outputCtr, inputCtr := createPulsarCtrs(inConf, outConf)
+	checkALOSynchronousAsync(outputCtr, inputCtr, t)
+}
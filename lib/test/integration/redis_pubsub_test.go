@@ -239,6 +239,12 @@ func testRedisPubSubSinglePartGlobs(url string, t *testing.T) {
 				t.Errorf("Unexpected message: %v", act)
 			}
 			delete(testMsgs, act)
+			if exp, act := "benthos_test_pubsub_single_part", actM.Get(0).Metadata().Get("redis_pubsub_channel"); exp != act {
+				t.Errorf("Wrong channel metadata: %v != %v", act, exp)
+			}
+			if exp, act := "benthos_test_*_single_part", actM.Get(0).Metadata().Get("redis_pubsub_pattern"); exp != act {
+				t.Errorf("Wrong pattern metadata: %v != %v", act, exp)
+			}
 		}
 		if err = mInput.Acknowledge(nil); err != nil {
 			t.Error(err)
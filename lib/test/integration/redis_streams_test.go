@@ -23,6 +23,7 @@ package integration
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"sync"
 	"testing"
 	"time"
@@ -33,6 +34,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output/writer"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/go-redis/redis"
 	"github.com/ory/dockertest"
 )
 
@@ -93,6 +95,12 @@ func TestRedisStreamsIntegration(t *testing.T) {
 	t.Run("TestRedisStreamsDisconnect", func(te *testing.T) {
 		testRedisStreamsDisconnect(url, te)
 	})
+	t.Run("TestRedisStreamsAutoClaim", func(te *testing.T) {
+		testRedisStreamsAutoClaim(url, te)
+	})
+	t.Run("TestRedisStreamsInterpolatedTrim", func(te *testing.T) {
+		testRedisStreamsInterpolatedTrim(url, te)
+	})
 }
 
 func createRedisStreamsInputOutput(
@@ -398,3 +406,150 @@ func testRedisStreamsDisconnect(url string, t *testing.T) {
 
 	wg.Wait()
 }
+
+func testRedisStreamsAutoClaim(url string, t *testing.T) {
+	inConf := reader.NewRedisStreamsConfig()
+	inConf.URL = url
+	inConf.Streams = []string{"benthos_test_streams_auto_claim"}
+	inConf.ClientID = "benthos_crashed_consumer"
+
+	outConf := writer.NewRedisStreamsConfig()
+	outConf.URL = url
+	outConf.Stream = "benthos_test_streams_auto_claim"
+
+	mInput, mOutput, err := createRedisStreamsInputOutput(inConf, outConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mOutput.CloseAsync()
+		if cErr := mOutput.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+	}()
+
+	N := 10
+	testMsgs := map[string]struct{}{}
+	for i := 0; i < N; i++ {
+		str := fmt.Sprintf("hello world: %v", i)
+		testMsgs[str] = struct{}{}
+		if err = mOutput.Write(message.New([][]byte{[]byte(str)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Read the messages without acknowledging them, as if the consumer
+	// crashed mid-batch.
+	for len(testMsgs) > 0 {
+		var msg types.Message
+		if msg, err = mInput.Read(); err != nil {
+			if err == types.ErrTimeout {
+				continue
+			}
+			t.Fatal(err)
+		}
+		msg.Iter(func(i int, part types.Part) error {
+			delete(testMsgs, string(part.Get()))
+			return nil
+		})
+	}
+	mInput.CloseAsync()
+	if cErr := mInput.WaitForClose(time.Second); cErr != nil {
+		t.Error(cErr)
+	}
+
+	inConf.ClientID = "benthos_healthy_consumer"
+	inConf.ClaimPeriod = "1ms"
+	inConf.ClaimMinIdle = "1ms"
+	<-time.After(time.Millisecond * 10)
+
+	var claimInput *reader.RedisStreams
+	if claimInput, err = reader.NewRedisStreams(inConf, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if err = claimInput.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		claimInput.CloseAsync()
+		if cErr := claimInput.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+	}()
+
+	for i := 0; i < N; i++ {
+		testMsgs[fmt.Sprintf("hello world: %v", i)] = struct{}{}
+	}
+	for len(testMsgs) > 0 {
+		var msg types.Message
+		if msg, err = claimInput.Read(); err != nil {
+			if err == types.ErrTimeout {
+				continue
+			}
+			t.Fatal(err)
+		}
+		msg.Iter(func(i int, part types.Part) error {
+			act := string(part.Get())
+			if _, exists := testMsgs[act]; !exists {
+				t.Errorf("Unexpected message: %v", act)
+			}
+			delete(testMsgs, act)
+			return nil
+		})
+		if err = claimInput.Acknowledge(nil); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func testRedisStreamsInterpolatedTrim(urlStr string, t *testing.T) {
+	purl, err := url.Parse(urlStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:    purl.Host,
+		Network: purl.Scheme,
+	})
+	defer client.Close()
+
+	outConf := writer.NewRedisStreamsConfig()
+	outConf.URL = urlStr
+	outConf.Stream = "benthos_test_streams_trim_${!metadata:suffix}"
+	outConf.MaxAge = "1h"
+
+	mOutput, err := writer.NewRedisStreams(outConf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mOutput.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mOutput.CloseAsync()
+		if cErr := mOutput.WaitForClose(time.Second); cErr != nil {
+			t.Error(cErr)
+		}
+	}()
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar"), []byte("baz")})
+	msg.Get(0).Metadata().Set("suffix", "a")
+	msg.Get(1).Metadata().Set("suffix", "b")
+	msg.Get(2).Metadata().Set("suffix", "b")
+	if err = mOutput.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	for stream, exp := range map[string]int64{
+		"benthos_test_streams_trim_a": 1,
+		"benthos_test_streams_trim_b": 2,
+	} {
+		act, err := client.XLen(stream).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp != act {
+			t.Errorf("Wrong length of stream %v: %v != %v", stream, act, exp)
+		}
+	}
+}
@@ -28,7 +28,9 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/util/config"
+	"github.com/opentracing/opentracing-go"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -57,6 +59,9 @@ var Constructors = map[string]TypeSpec{}
 const (
 	TypeJaeger = "jaeger"
 	TypeNone   = "none"
+	TypeOTLP   = "otlp"
+	TypeXRay   = "xray"
+	TypeZipkin = "zipkin"
 )
 
 //------------------------------------------------------------------------------
@@ -71,17 +76,25 @@ type Type interface {
 
 // Config is the all encompassing configuration struct for all tracer types.
 type Config struct {
-	Type   string       `json:"type" yaml:"type"`
-	Jaeger JaegerConfig `json:"jaeger" yaml:"jaeger"`
-	None   struct{}     `json:"none" yaml:"none"`
+	Type              string                  `json:"type" yaml:"type"`
+	Jaeger            JaegerConfig            `json:"jaeger" yaml:"jaeger"`
+	None              struct{}                `json:"none" yaml:"none"`
+	OTLP              OTLPConfig              `json:"otlp" yaml:"otlp"`
+	XRay              XRayConfig              `json:"xray" yaml:"xray"`
+	Zipkin            ZipkinConfig            `json:"zipkin" yaml:"zipkin"`
+	SamplingOverrides SamplingOverridesConfig `json:"sampling_overrides" yaml:"sampling_overrides"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:   TypeNone,
-		Jaeger: NewJaegerConfig(),
-		None:   struct{}{},
+		Type:              TypeNone,
+		Jaeger:            NewJaegerConfig(),
+		None:              struct{}{},
+		OTLP:              NewOTLPConfig(),
+		XRay:              NewXRayConfig(),
+		Zipkin:            NewZipkinConfig(),
+		SamplingOverrides: NewSamplingOverridesConfig(),
 	}
 }
 
@@ -110,6 +123,11 @@ func SanitiseConfig(conf Config) (interface{}, error) {
 		outputMap[t] = hashMap[t]
 	}
 
+	so := conf.SamplingOverrides
+	if len(so.Inputs) > 0 || len(so.Processors) > 0 || len(so.Outputs) > 0 {
+		outputMap["sampling_overrides"] = hashMap["sampling_overrides"]
+	}
+
 	return outputMap, nil
 }
 
@@ -154,17 +172,27 @@ func (c *Config) UnmarshalYAML(value *yaml.Node) error {
 var header = "This document was generated with `benthos --list-tracers`" + `
 
 A tracer type represents a destination for Benthos to send opentracing events to
-such as [Jaeger](https://www.jaegertracing.io/).
+such as [Jaeger](https://www.jaegertracing.io/),
+[Zipkin](https://zipkin.io/), [AWS X-Ray](https://aws.amazon.com/xray/) or any
+[OpenTelemetry](https://opentelemetry.io/) collector.
 
 When a tracer is configured all messages will be allocated a root span during
 ingestion that represents their journey through a Benthos pipeline. Many Benthos
 processors create spans, and so opentracing is a great way to analyse the
 pathways of individual messages as they progress through a Benthos instance.
 
-Some inputs, such as ` + "`http_server` and `http_client`" + `, are capable of
-extracting a root span from the source of the message (HTTP headers). This is
-a work in progress and should eventually expand so that all inputs have a way of
-doing so.
+Some inputs, such as ` + "`http_server`" + `, are capable of extracting a root
+span from the source of the message (HTTP headers). This is a work in progress
+and should eventually expand so that all inputs have a way of doing so.
+
+Components that send HTTP requests, such as the ` + "`http_client`" + ` output and
+the ` + "`http`" + ` processor, inject the span of each request into its headers,
+allowing downstream services to join the trace. The format of these headers is
+determined by the tracer, where the ` + "`otlp`" + ` tracer uses the
+[W3C Trace Context](https://www.w3.org/TR/trace-context/) ` + "`traceparent`" + `
+header, the ` + "`zipkin`" + ` tracer uses the
+[B3](https://github.com/openzipkin/b3-propagation) headers and the
+` + "`xray`" + ` tracer uses the ` + "`X-Amzn-Trace-Id`" + ` header.
 
 A tracer config section looks like this:
 
@@ -175,6 +203,32 @@ tracer:
     bar: baz
 ` + "```" + `
 
+### Sampling Overrides
+
+The sampling decision of a tracer can be overridden for the spans of specific
+components by setting the ratio of their spans to record in the
+` + "`sampling_overrides`" + ` section, keyed by the type of the component
+within the fields ` + "`inputs`" + `, ` + "`processors`" + ` and
+` + "`outputs`" + `. This allows hot paths to be sampled sparsely whilst
+components that are prone to errors are always traced:
+
+` + "``` yaml" + `
+tracer:
+  type: jaeger
+  jaeger:
+    agent_address: localhost:6831
+  sampling_overrides:
+    inputs:
+      kafka: 0.001
+    processors:
+      http: 1
+` + "```" + `
+
+The decision for a component applies to the spans it creates and their
+children, and is communicated to the tracer with the ` + "`sampling.priority`" + `
+tag. Spans that are recorded whilst their parent is not will appear within
+traces without their parent.
+
 WARNING: Although the configuration spec of this component is stable the format
 of spans, tags and logs created by Benthos is subject to change as it is tuned
 for improvement.`
@@ -222,12 +276,35 @@ func Descriptions() string {
 	return buf.String()
 }
 
+// OptSetLogger sets the logger of a tracer type, if it supports logging.
+func OptSetLogger(l log.Modular) func(Type) {
+	return func(t Type) {
+		if lt, ok := t.(interface {
+			SetLogger(l log.Modular)
+		}); ok {
+			lt.SetLogger(l)
+		}
+	}
+}
+
 // New creates a tracer type based on a configuration.
 func New(conf Config, opts ...func(Type)) (Type, error) {
-	if c, ok := Constructors[conf.Type]; ok {
-		return c.constructor(conf, opts...)
+	c, ok := Constructors[conf.Type]
+	if !ok {
+		return nil, ErrInvalidTracerType
+	}
+	ratios, err := conf.SamplingOverrides.operationRatios()
+	if err != nil {
+		return nil, err
+	}
+	t, err := c.constructor(conf, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(ratios) > 0 {
+		opentracing.SetGlobalTracer(newSamplingTracer(opentracing.GlobalTracer(), ratios))
 	}
-	return nil, ErrInvalidTracerType
+	return t, nil
 }
 
 //------------------------------------------------------------------------------
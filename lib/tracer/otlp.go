@@ -0,0 +1,314 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/util/otlp"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeOTLP] = TypeSpec{
+		constructor: NewOTLP,
+		description: `
+EXPERIMENTAL: This component is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Export spans to an [OpenTelemetry](https://opentelemetry.io/) collector using
+the OTLP protocol over either gRPC or HTTP.
+
+When ` + "`protocol`" + ` is ` + "`grpc`" + ` the ` + "`endpoint`" + ` is the
+host and port of the collector (e.g. ` + "`localhost:4317`" + `), and when it is
+` + "`http`" + ` the ` + "`endpoint`" + ` is the full URL that spans are posted
+to (e.g. ` + "`http://localhost:4318/v1/traces`" + `).
+
+Spans are exported with the resource attributes ` + "`service.name`" + ` and
+` + "`service.instance.id`" + `, where the instance id defaults to the hostname
+of the machine when left empty, along with any ` + "`resource_attributes`" + `.
+
+### Sampling
+
+The ` + "`sampler_type`" + ` determines which traces are recorded, and can be
+one of ` + "`always_on`" + `, ` + "`always_off`" + ` or ` + "`ratio`" + `, where
+` + "`ratio`" + ` records a fraction ` + "`sampler_ratio`" + ` of traces chosen
+by their trace ID. When ` + "`sampler_parent_based`" + ` is true the sampling
+decision of spans with a parent, such as those extracted from upstream
+services, follows that of the parent and the ` + "`sampler_type`" + ` is only
+applied to new traces.
+
+### Propagation
+
+Span contexts are injected into and extracted from message carriers such as
+HTTP headers in the [W3C Trace Context](https://www.w3.org/TR/trace-context/)
+format, using the ` + "`traceparent`" + ` and ` + "`tracestate`" + ` headers,
+with baggage items carried by the
+[W3C Baggage](https://www.w3.org/TR/baggage/) header ` + "`baggage`" + `.
+
+### Batching
+
+Finished spans are queued and exported in batches of up to
+` + "`batch_size`" + ` spans at least every ` + "`flush_interval`" + `. When the
+queue reaches ` + "`max_queue_size`" + ` spans any further spans are dropped
+until it has been exported.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OTLPConfig is config for the OTLP tracer type.
+type OTLPConfig struct {
+	Protocol           string            `json:"protocol" yaml:"protocol"`
+	Endpoint           string            `json:"endpoint" yaml:"endpoint"`
+	Headers            map[string]string `json:"headers" yaml:"headers"`
+	TLS                btls.Config       `json:"tls" yaml:"tls"`
+	ServiceName        string            `json:"service_name" yaml:"service_name"`
+	ServiceInstanceID  string            `json:"service_instance_id" yaml:"service_instance_id"`
+	ResourceAttributes map[string]string `json:"resource_attributes" yaml:"resource_attributes"`
+	SamplerType        string            `json:"sampler_type" yaml:"sampler_type"`
+	SamplerRatio       float64           `json:"sampler_ratio" yaml:"sampler_ratio"`
+	SamplerParentBased bool              `json:"sampler_parent_based" yaml:"sampler_parent_based"`
+	BatchSize          int               `json:"batch_size" yaml:"batch_size"`
+	MaxQueueSize       int               `json:"max_queue_size" yaml:"max_queue_size"`
+	FlushInterval      string            `json:"flush_interval" yaml:"flush_interval"`
+	Timeout            string            `json:"timeout" yaml:"timeout"`
+}
+
+// NewOTLPConfig creates an OTLPConfig struct with default values.
+func NewOTLPConfig() OTLPConfig {
+	return OTLPConfig{
+		Protocol:           "grpc",
+		Endpoint:           "localhost:4317",
+		Headers:            map[string]string{},
+		TLS:                btls.NewConfig(),
+		ServiceName:        "benthos",
+		ServiceInstanceID:  "",
+		ResourceAttributes: map[string]string{},
+		SamplerType:        "always_on",
+		SamplerRatio:       1.0,
+		SamplerParentBased: true,
+		BatchSize:          512,
+		MaxQueueSize:       2048,
+		FlushInterval:      "5s",
+		Timeout:            "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+const otlpExportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+// Values of the OTLP Span.SpanKind enum.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+	otlpSpanKindProducer = 4
+	otlpSpanKindConsumer = 5
+)
+
+// Values of the OTLP Status.StatusCode enum.
+const (
+	otlpStatusCodeError = 2
+)
+
+// OTLP is a tracer with the capability to export spans to an OpenTelemetry
+// collector.
+type OTLP struct {
+	*recorder
+
+	client   *otlp.Client
+	resource []byte
+}
+
+// NewOTLP creates and returns a new OTLP tracer object.
+func NewOTLP(config Config, opts ...func(Type)) (Type, error) {
+	o, err := newOTLP(config.OTLP, opts...)
+	if err != nil {
+		return nil, err
+	}
+	opentracing.SetGlobalTracer(o)
+	return o, nil
+}
+
+func newOTLP(conf OTLPConfig, opts ...func(Type)) (*OTLP, error) {
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	o := &OTLP{}
+	if o.recorder, err = newRecorder(recorderConfig{
+		samplerType:        conf.SamplerType,
+		samplerRatio:       conf.SamplerRatio,
+		samplerParentBased: conf.SamplerParentBased,
+		batchSize:          conf.BatchSize,
+		maxQueueSize:       conf.MaxQueueSize,
+		flushInterval:      conf.FlushInterval,
+	}, w3cPropagator{}, o.export); err != nil {
+		return nil, err
+	}
+
+	instanceID := conf.ServiceInstanceID
+	if len(instanceID) == 0 {
+		instanceID, _ = os.Hostname()
+	}
+	attributes := map[string]string{}
+	for k, v := range conf.ResourceAttributes {
+		attributes[k] = v
+	}
+	attributes["service.name"] = conf.ServiceName
+	attributes["service.instance.id"] = instanceID
+	var resource otlp.ProtoBuf
+	otlp.EncodeAttributes(&resource, 1, attributes)
+	o.resource = resource.B
+
+	if o.client, err = otlp.NewClient(otlp.ClientConfig{
+		Protocol:   conf.Protocol,
+		Endpoint:   conf.Endpoint,
+		GRPCMethod: otlpExportMethod,
+		Headers:    conf.Headers,
+		TLS:        conf.TLS,
+		Timeout:    timeout,
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	o.start()
+	return o, nil
+}
+
+//------------------------------------------------------------------------------
+
+// export sends a batch of spans as an OTLP ExportTraceServiceRequest.
+func (o *OTLP) export(spans []spanData) {
+	var scope otlp.ProtoBuf
+	var scopeInfo otlp.ProtoBuf
+	scopeInfo.String(1, "benthos")
+	scope.Message(1, scopeInfo.B)
+	for _, s := range spans {
+		scope.Message(2, encodeOTLPSpan(s))
+	}
+
+	var resourceSpans otlp.ProtoBuf
+	resourceSpans.Message(1, o.resource)
+	resourceSpans.Message(2, scope.B)
+
+	var req otlp.ProtoBuf
+	req.Message(1, resourceSpans.B)
+
+	if err := o.client.Export(req.B); err != nil {
+		o.log.Errorf("Failed to export spans: %v\n", err)
+	}
+}
+
+// encodeOTLPSpan serialises a span as an OTLP Span message.
+func encodeOTLPSpan(s spanData) []byte {
+	kind := uint64(otlpSpanKindInternal)
+	switch fmt.Sprintf("%v", s.tags[string(ext.SpanKind)]) {
+	case string(ext.SpanKindRPCServerEnum):
+		kind = otlpSpanKindServer
+	case string(ext.SpanKindRPCClientEnum):
+		kind = otlpSpanKindClient
+	case string(ext.SpanKindProducerEnum):
+		kind = otlpSpanKindProducer
+	case string(ext.SpanKindConsumerEnum):
+		kind = otlpSpanKindConsumer
+	}
+
+	var p otlp.ProtoBuf
+	p.Message(1, s.ctx.traceID[:])
+	p.Message(2, s.ctx.spanID[:])
+	if len(s.ctx.traceState) > 0 {
+		p.String(3, s.ctx.traceState)
+	}
+	if s.hasParent {
+		p.Message(4, s.parentID[:])
+	}
+	p.String(5, s.name)
+	p.Varint(6, kind)
+	p.Fixed64(7, uint64(s.start.UnixNano()))
+	p.Fixed64(8, uint64(s.end.UnixNano()))
+
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		if k != string(ext.SpanKind) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		otlp.EncodeKeyValue(&p, 9, k, s.tags[k])
+	}
+
+	for _, e := range s.events {
+		var event otlp.ProtoBuf
+		event.Fixed64(1, uint64(e.timestamp.UnixNano()))
+		event.String(2, e.name())
+		for _, f := range e.fields {
+			otlp.EncodeKeyValue(&event, 3, f.Key(), f.Value())
+		}
+		p.Message(11, event.B)
+	}
+
+	for _, l := range s.links {
+		var link otlp.ProtoBuf
+		link.Message(1, l.traceID[:])
+		link.Message(2, l.spanID[:])
+		p.Message(13, link.B)
+	}
+
+	if isErr, _ := s.tags[string(ext.Error)].(bool); isErr {
+		var status otlp.ProtoBuf
+		status.Varint(3, otlpStatusCodeError)
+		p.Message(15, status.B)
+	}
+	return p.B
+}
+
+//------------------------------------------------------------------------------
+
+// SetLogger sets the logger used to print export errors.
+func (o *OTLP) SetLogger(log log.Modular) {
+	o.log = log
+}
+
+// Close stops the tracer and exports any remaining spans.
+func (o *OTLP) Close() error {
+	o.close()
+	o.client.Close()
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,249 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+type protoField struct {
+	num    uint64
+	varint uint64
+	bytes  []byte
+}
+
+// protoFieldsByNum parses the fields of a protobuf message with a field
+// number, where fixed64 values are returned as varint.
+func protoFieldsByNum(t *testing.T, b []byte, num uint64) []protoField {
+	t.Helper()
+
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("Failed to parse field key")
+		}
+		b = b[n:]
+
+		f := protoField{num: key >> 3}
+		switch key & 7 {
+		case 0:
+			if f.varint, n = binary.Uvarint(b); n <= 0 {
+				t.Fatal("Failed to parse varint")
+			}
+			b = b[n:]
+		case 1:
+			f.varint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatal("Failed to parse length")
+			}
+			f.bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("Unexpected wire type: %v", key&7)
+		}
+		if f.num == num {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+//------------------------------------------------------------------------------
+
+func TestOTLPBadConfig(t *testing.T) {
+	for _, fn := range []func(c *OTLPConfig){
+		func(c *OTLPConfig) { c.SamplerType = "nope" },
+		func(c *OTLPConfig) { c.SamplerType = "ratio"; c.SamplerRatio = 2 },
+		func(c *OTLPConfig) { c.FlushInterval = "nope" },
+		func(c *OTLPConfig) { c.BatchSize = 0 },
+		func(c *OTLPConfig) { c.MaxQueueSize = 1 },
+		func(c *OTLPConfig) { c.Protocol = "nope" },
+	} {
+		conf := NewOTLPConfig()
+		fn(&conf)
+		if _, err := newOTLP(conf); err == nil {
+			t.Errorf("Expected error from config: %+v", conf)
+		}
+	}
+}
+
+func TestOTLPPropagation(t *testing.T) {
+	o, err := newOTLP(NewOTLPConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	span := o.StartSpan("foo")
+	span.SetBaggageItem("bar", "baz qux")
+
+	headers := http.Header{}
+	if err = o.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers)); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 55, len(headers.Get("traceparent")); exp != act {
+		t.Errorf("Wrong traceparent length: %v != %v", act, exp)
+	}
+
+	extracted, err := o.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, act := span.Context().(spanContext), extracted.(spanContext)
+	if exp.traceID != act.traceID || exp.spanID != act.spanID || !act.sampled {
+		t.Errorf("Wrong extracted context: %+v != %+v", act, exp)
+	}
+	if exp, act := "baz qux", act.baggage["bar"]; exp != act {
+		t.Errorf("Wrong baggage item: %v != %v", act, exp)
+	}
+
+	child := o.StartSpan("child", opentracing.ChildOf(extracted)).(*recordedSpan)
+	if exp.traceID != child.data.ctx.traceID || exp.spanID != child.data.parentID {
+		t.Errorf("Wrong child context: %+v", child.data.ctx)
+	}
+
+	if _, err = o.Extract(opentracing.TextMap, opentracing.TextMapCarrier{}); err != opentracing.ErrSpanContextNotFound {
+		t.Errorf("Wrong error: %v", err)
+	}
+	for _, v := range []string{
+		"00-00000000000000000000000000000000-0000000000000001-01",
+		"00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01-extra",
+		"ff-0102030405060708090a0b0c0d0e0f10-0102030405060708-01",
+		"00-nothex-0102030405060708-01",
+	} {
+		carrier := opentracing.TextMapCarrier{"traceparent": v}
+		if _, err = o.Extract(opentracing.TextMap, carrier); err != opentracing.ErrSpanContextCorrupted {
+			t.Errorf("Wrong error for '%v': %v", v, err)
+		}
+	}
+}
+
+func TestOTLPSampling(t *testing.T) {
+	conf := NewOTLPConfig()
+	conf.SamplerType = "ratio"
+	conf.SamplerRatio = 0
+	o, err := newOTLP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	if o.StartSpan("foo").Context().(spanContext).sampled {
+		t.Error("Expected root span not to be sampled")
+	}
+
+	parent, err := o.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+		"traceparent": "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.StartSpan("foo", opentracing.ChildOf(parent)).Context().(spanContext).sampled {
+		t.Error("Expected child of sampled parent to be sampled")
+	}
+
+	o.samplerParentBased = false
+	if o.StartSpan("foo", opentracing.ChildOf(parent)).Context().(spanContext).sampled {
+		t.Error("Expected child span not to be sampled")
+	}
+}
+
+func TestOTLPExportHTTP(t *testing.T) {
+	var reqMut sync.Mutex
+	var reqBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reqMut.Lock()
+		reqBody = body
+		reqMut.Unlock()
+	}))
+	defer server.Close()
+
+	conf := NewOTLPConfig()
+	conf.Protocol = "http"
+	conf.Endpoint = server.URL + "/v1/traces"
+	conf.FlushInterval = "1h"
+
+	o, err := newOTLP(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := o.StartSpan("parent")
+	child := o.StartSpan("child", opentracing.ChildOf(parent.Context()))
+	ext.SpanKindRPCClient.Set(child)
+	ext.Error.Set(child, true)
+	child.LogKV("event", "failed", "attempt", 2)
+	child.Finish()
+	parent.Finish()
+
+	if err = o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+
+	resourceSpans := protoFieldsByNum(t, reqBody, 1)[0].bytes
+	scope := protoFieldsByNum(t, resourceSpans, 2)[0].bytes
+	spans := protoFieldsByNum(t, scope, 2)
+	if exp, act := 2, len(spans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+
+	childSpan, parentSpan := spans[0].bytes, spans[1].bytes
+	if exp, act := "child", string(protoFieldsByNum(t, childSpan, 5)[0].bytes); exp != act {
+		t.Errorf("Wrong span name: %v != %v", act, exp)
+	}
+	if !bytes.Equal(protoFieldsByNum(t, parentSpan, 2)[0].bytes, protoFieldsByNum(t, childSpan, 4)[0].bytes) {
+		t.Error("Expected child span to reference parent")
+	}
+	if exp, act := uint64(otlpSpanKindClient), protoFieldsByNum(t, childSpan, 6)[0].varint; exp != act {
+		t.Errorf("Wrong span kind: %v != %v", act, exp)
+	}
+	events := protoFieldsByNum(t, childSpan, 11)
+	if exp, act := 1, len(events); exp != act {
+		t.Fatalf("Wrong count of events: %v != %v", act, exp)
+	}
+	if exp, act := "failed", string(protoFieldsByNum(t, events[0].bytes, 2)[0].bytes); exp != act {
+		t.Errorf("Wrong event name: %v != %v", act, exp)
+	}
+	status := protoFieldsByNum(t, childSpan, 15)
+	if len(status) != 1 || protoFieldsByNum(t, status[0].bytes, 3)[0].varint != otlpStatusCodeError {
+		t.Error("Expected error status")
+	}
+	if len(protoFieldsByNum(t, parentSpan, 15)) != 0 {
+		t.Error("Expected no parent status")
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+//------------------------------------------------------------------------------
+
+// propagator writes span contexts to and reads them from text map carriers in
+// the format of a tracing system.
+type propagator interface {
+	inject(ctx spanContext, w opentracing.TextMapWriter)
+	extract(r opentracing.TextMapReader) (opentracing.SpanContext, error)
+}
+
+//------------------------------------------------------------------------------
+
+// w3cPropagator propagates span contexts with the W3C trace context headers
+// traceparent and tracestate, and baggage with the W3C baggage header.
+type w3cPropagator struct{}
+
+func (w3cPropagator) inject(ctx spanContext, w opentracing.TextMapWriter) {
+	flags := 0
+	if ctx.sampled {
+		flags = 1
+	}
+	w.Set("traceparent", fmt.Sprintf("00-%x-%x-%02x", ctx.traceID, ctx.spanID, flags))
+	if len(ctx.traceState) > 0 {
+		w.Set("tracestate", ctx.traceState)
+	}
+	if len(ctx.baggage) > 0 {
+		keys := make([]string, 0, len(ctx.baggage))
+		for k := range ctx.baggage {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		members := make([]string, 0, len(keys))
+		for _, k := range keys {
+			members = append(members, url.QueryEscape(k)+"="+url.QueryEscape(ctx.baggage[k]))
+		}
+		w.Set("baggage", strings.Join(members, ","))
+	}
+}
+
+func (w3cPropagator) extract(r opentracing.TextMapReader) (opentracing.SpanContext, error) {
+	var traceParent, traceState, baggage string
+	if err := r.ForeachKey(func(key, val string) error {
+		switch strings.ToLower(key) {
+		case "traceparent":
+			traceParent = val
+		case "tracestate":
+			traceState = val
+		case "baggage":
+			baggage = val
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(traceParent) == 0 {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	ctx, err := parseTraceParent(traceParent)
+	if err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	ctx.traceState = traceState
+	if len(baggage) > 0 {
+		ctx.baggage = map[string]string{}
+		for _, member := range strings.Split(baggage, ",") {
+			// Properties of list members are not supported and are discarded.
+			member = strings.SplitN(member, ";", 2)[0]
+			kv := strings.SplitN(member, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			k, kerr := url.QueryUnescape(strings.TrimSpace(kv[0]))
+			v, verr := url.QueryUnescape(strings.TrimSpace(kv[1]))
+			if kerr == nil && verr == nil && len(k) > 0 {
+				ctx.baggage[k] = v
+			}
+		}
+	}
+	return ctx, nil
+}
+
+var errInvalidTraceParent = errors.New("invalid traceparent")
+
+// parseTraceParent parses the value of a W3C traceparent header.
+func parseTraceParent(v string) (spanContext, error) {
+	var ctx spanContext
+
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		return ctx, errInvalidTraceParent
+	}
+	version := parts[0]
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return ctx, errInvalidTraceParent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx, errInvalidTraceParent
+	}
+	if _, err := hex.Decode(ctx.traceID[:], []byte(parts[1])); err != nil {
+		return ctx, errInvalidTraceParent
+	}
+	if _, err := hex.Decode(ctx.spanID[:], []byte(parts[2])); err != nil {
+		return ctx, errInvalidTraceParent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return ctx, errInvalidTraceParent
+	}
+	if ctx.traceID == ([16]byte{}) || ctx.spanID == ([8]byte{}) {
+		return ctx, errInvalidTraceParent
+	}
+	ctx.sampled = flags[0]&1 == 1
+	return ctx, nil
+}
+
+//------------------------------------------------------------------------------
+
+// b3Propagator propagates span contexts with the Zipkin B3 headers. Contexts
+// are injected as multiple X-B3 headers, and can be extracted from either
+// those or the single b3 header. Baggage is not propagated.
+type b3Propagator struct{}
+
+func (b3Propagator) inject(ctx spanContext, w opentracing.TextMapWriter) {
+	w.Set("X-B3-TraceId", b3TraceID(ctx.traceID))
+	w.Set("X-B3-SpanId", hex.EncodeToString(ctx.spanID[:]))
+	if ctx.sampled {
+		w.Set("X-B3-Sampled", "1")
+	} else {
+		w.Set("X-B3-Sampled", "0")
+	}
+}
+
+// b3TraceID returns the hex encoding of a trace ID, which is shortened to 64
+// bits when the upper half is empty.
+func b3TraceID(traceID [16]byte) string {
+	if binary.BigEndian.Uint64(traceID[:8]) == 0 {
+		return hex.EncodeToString(traceID[8:])
+	}
+	return hex.EncodeToString(traceID[:])
+}
+
+func (b3Propagator) extract(r opentracing.TextMapReader) (opentracing.SpanContext, error) {
+	var single, traceID, spanID, sampled, flags string
+	if err := r.ForeachKey(func(key, val string) error {
+		switch strings.ToLower(key) {
+		case "b3":
+			single = val
+		case "x-b3-traceid":
+			traceID = val
+		case "x-b3-spanid":
+			spanID = val
+		case "x-b3-sampled":
+			sampled = val
+		case "x-b3-flags":
+			flags = val
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if len(single) > 0 && len(traceID) == 0 {
+		parts := strings.Split(strings.TrimSpace(single), "-")
+		if len(parts) < 2 {
+			// A lone sampling decision carries no context to continue.
+			return nil, opentracing.ErrSpanContextNotFound
+		}
+		traceID, spanID = parts[0], parts[1]
+		if len(parts) > 2 {
+			if parts[2] == "d" {
+				flags = "1"
+			} else {
+				sampled = parts[2]
+			}
+		}
+	}
+	if len(traceID) == 0 && len(spanID) == 0 {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	var ctx spanContext
+	switch len(traceID) {
+	case 16:
+		if _, err := hex.Decode(ctx.traceID[8:], []byte(traceID)); err != nil {
+			return nil, opentracing.ErrSpanContextCorrupted
+		}
+	case 32:
+		if _, err := hex.Decode(ctx.traceID[:], []byte(traceID)); err != nil {
+			return nil, opentracing.ErrSpanContextCorrupted
+		}
+	default:
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if len(spanID) != 16 {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(ctx.spanID[:], []byte(spanID)); err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if ctx.traceID == ([16]byte{}) || ctx.spanID == ([8]byte{}) {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+
+	// An absent sampling decision is deferred to us, in which case the trace
+	// is recorded.
+	ctx.sampled = sampled != "0" && sampled != "false"
+	if flags == "1" {
+		ctx.sampled = true
+	}
+	return ctx, nil
+}
+
+//------------------------------------------------------------------------------
+
+// xrayPropagator propagates span contexts with the AWS X-Ray header
+// X-Amzn-Trace-Id. Baggage is not propagated.
+type xrayPropagator struct{}
+
+// xrayTraceID returns the X-Ray format of a trace ID, where the first four
+// bytes are the epoch time at which the trace started.
+func xrayTraceID(traceID [16]byte) string {
+	return fmt.Sprintf("1-%x-%x", traceID[:4], traceID[4:])
+}
+
+func (xrayPropagator) inject(ctx spanContext, w opentracing.TextMapWriter) {
+	sampled := 0
+	if ctx.sampled {
+		sampled = 1
+	}
+	w.Set("X-Amzn-Trace-Id", fmt.Sprintf("Root=%v;Parent=%x;Sampled=%v", xrayTraceID(ctx.traceID), ctx.spanID, sampled))
+}
+
+func (xrayPropagator) extract(r opentracing.TextMapReader) (opentracing.SpanContext, error) {
+	var header string
+	if err := r.ForeachKey(func(key, val string) error {
+		if strings.ToLower(key) == "x-amzn-trace-id" {
+			header = val
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(header) == 0 {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	return parseXRayHeader(header)
+}
+
+// parseXRayHeader parses the value of an X-Amzn-Trace-Id header, where the
+// parent is optional and an absent sampling decision is deferred to us, in
+// which case the trace is recorded.
+func parseXRayHeader(v string) (spanContext, error) {
+	ctx := spanContext{sampled: true}
+
+	var root, parent string
+	for _, kv := range strings.Split(v, ";") {
+		kvs := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(kvs) != 2 {
+			continue
+		}
+		switch kvs[0] {
+		case "Root":
+			root = kvs[1]
+		case "Parent":
+			parent = kvs[1]
+		case "Sampled":
+			ctx.sampled = kvs[1] != "0"
+		}
+	}
+
+	rootParts := strings.Split(root, "-")
+	if len(rootParts) != 3 || rootParts[0] != "1" || len(rootParts[1]) != 8 || len(rootParts[2]) != 24 {
+		return ctx, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(ctx.traceID[:4], []byte(rootParts[1])); err != nil {
+		return ctx, opentracing.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(ctx.traceID[4:], []byte(rootParts[2])); err != nil {
+		return ctx, opentracing.ErrSpanContextCorrupted
+	}
+	if len(parent) > 0 {
+		if len(parent) != 16 {
+			return ctx, opentracing.ErrSpanContextCorrupted
+		}
+		if _, err := hex.Decode(ctx.spanID[:], []byte(parent)); err != nil {
+			return ctx, opentracing.ErrSpanContextCorrupted
+		}
+	}
+	return ctx, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,509 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	olog "github.com/opentracing/opentracing-go/log"
+)
+
+//------------------------------------------------------------------------------
+
+// recorderConfig contains the sampling and batching settings of a recorder.
+type recorderConfig struct {
+	samplerType        string
+	samplerRatio       float64
+	samplerParentBased bool
+	batchSize          int
+	maxQueueSize       int
+	flushInterval      string
+}
+
+// recorder is an opentracing.Tracer that records spans and periodically hands
+// batches of finished and sampled spans to an export function. It is shared by
+// tracer types that implement their own wire format.
+type recorder struct {
+	propagator propagator
+	exportFn   func(spans []spanData)
+	traceIDFn  func(traceID []byte)
+
+	samplerType        string
+	samplerBound       uint64
+	samplerParentBased bool
+
+	batchSize     int
+	flushInterval time.Duration
+	queue         chan spanData
+	dropped       int64
+
+	randMut sync.Mutex
+	rand    *rand.Rand
+
+	log       log.Modular
+	closeOnce sync.Once
+
+	closedChan chan struct{}
+	doneChan   chan struct{}
+}
+
+func newRecorder(conf recorderConfig, prop propagator, exportFn func([]spanData)) (*recorder, error) {
+	flushInterval, err := time.ParseDuration(conf.flushInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush interval: %v", err)
+	}
+	if flushInterval <= 0 {
+		return nil, fmt.Errorf("flush interval must be greater than zero: %v", conf.flushInterval)
+	}
+	if conf.batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be greater than zero: %v", conf.batchSize)
+	}
+	if conf.maxQueueSize < conf.batchSize {
+		return nil, fmt.Errorf("max queue size must be at least the batch size: %v", conf.maxQueueSize)
+	}
+
+	var seed int64
+	if err = binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+		seed = time.Now().UnixNano()
+	}
+
+	r := &recorder{
+		propagator:         prop,
+		exportFn:           exportFn,
+		samplerType:        conf.samplerType,
+		samplerParentBased: conf.samplerParentBased,
+		batchSize:          conf.batchSize,
+		flushInterval:      flushInterval,
+		queue:              make(chan spanData, conf.maxQueueSize),
+		rand:               rand.New(rand.NewSource(seed)),
+		log:                log.Noop(),
+		closedChan:         make(chan struct{}),
+		doneChan:           make(chan struct{}),
+	}
+
+	switch conf.samplerType {
+	case "always_on", "always_off":
+	case "ratio":
+		if conf.samplerRatio < 0 || conf.samplerRatio > 1 {
+			return nil, fmt.Errorf("sampler ratio must be between 0 and 1: %v", conf.samplerRatio)
+		}
+		r.samplerBound = uint64(conf.samplerRatio * (1 << 63))
+	default:
+		return nil, fmt.Errorf("unrecognised sampler type: %v", conf.samplerType)
+	}
+	return r, nil
+}
+
+//------------------------------------------------------------------------------
+
+// start begins the loop that exports batches of spans.
+func (r *recorder) start() {
+	go r.loop()
+}
+
+func (r *recorder) loop() {
+	defer close(r.doneChan)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	var batch []spanData
+	for {
+		select {
+		case s := <-r.queue:
+			if batch = append(batch, s); len(batch) >= r.batchSize {
+				r.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			r.export(batch)
+			batch = nil
+		case <-r.closedChan:
+			for {
+				select {
+				case s := <-r.queue:
+					if batch = append(batch, s); len(batch) >= r.batchSize {
+						r.export(batch)
+						batch = nil
+					}
+				default:
+					r.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *recorder) export(spans []spanData) {
+	if dropped := atomic.SwapInt64(&r.dropped, 0); dropped > 0 {
+		r.log.Warnf("Dropped %v spans due to a full export queue\n", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	r.exportFn(spans)
+}
+
+// enqueue adds a finished span to the export queue, or drops it if the queue
+// is full.
+func (r *recorder) enqueue(s spanData) {
+	select {
+	case r.queue <- s:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// close stops the export loop after exporting any remaining spans.
+func (r *recorder) close() {
+	r.closeOnce.Do(func() {
+		close(r.closedChan)
+		<-r.doneChan
+	})
+}
+
+func (r *recorder) newID(b []byte) {
+	r.randMut.Lock()
+	for allZero := true; allZero; {
+		r.rand.Read(b)
+		for _, v := range b {
+			if v != 0 {
+				allZero = false
+				break
+			}
+		}
+	}
+	r.randMut.Unlock()
+}
+
+// sample returns whether a new trace should be recorded.
+func (r *recorder) sample(traceID [16]byte) bool {
+	switch r.samplerType {
+	case "always_on":
+		return true
+	case "ratio":
+		return binary.BigEndian.Uint64(traceID[8:])>>1 < r.samplerBound
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
+
+// StartSpan creates a span with an operation name and options. The first
+// reference of the options to a recorded span context becomes the parent of
+// the span, and any others are kept as links.
+func (r *recorder) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var sso opentracing.StartSpanOptions
+	for _, opt := range opts {
+		opt.Apply(&sso)
+	}
+
+	s := &recordedSpan{
+		tracer: r,
+		data: spanData{
+			name:  operationName,
+			start: sso.StartTime,
+			tags:  map[string]interface{}{},
+		},
+	}
+	if s.data.start.IsZero() {
+		s.data.start = time.Now()
+	}
+	for k, v := range sso.Tags {
+		s.data.tags[k] = v
+	}
+
+	var parent *spanContext
+	for _, ref := range sso.References {
+		refCtx, ok := ref.ReferencedContext.(spanContext)
+		if !ok {
+			continue
+		}
+		if parent == nil {
+			parent = &refCtx
+			continue
+		}
+		s.data.links = append(s.data.links, refCtx)
+	}
+
+	if parent != nil && parent.spanID == ([8]byte{}) {
+		// Propagation formats such as X-Ray allow a trace ID without a parent
+		// span, in which case the trace is continued by a root span.
+		s.data.ctx = spanContext{
+			traceID:    parent.traceID,
+			traceState: parent.traceState,
+			sampled:    parent.sampled,
+			baggage:    parent.baggage,
+		}
+		if !r.samplerParentBased {
+			s.data.ctx.sampled = r.sample(s.data.ctx.traceID)
+		}
+	} else if parent != nil {
+		s.data.ctx = spanContext{
+			traceID:    parent.traceID,
+			traceState: parent.traceState,
+			sampled:    parent.sampled,
+			baggage:    parent.baggage,
+		}
+		s.data.parentID = parent.spanID
+		s.data.hasParent = true
+		s.data.remoteParent = parent.remote
+		if !r.samplerParentBased {
+			s.data.ctx.sampled = r.sample(s.data.ctx.traceID)
+		}
+	} else {
+		if r.traceIDFn != nil {
+			r.traceIDFn(s.data.ctx.traceID[:])
+		} else {
+			r.newID(s.data.ctx.traceID[:])
+		}
+		s.data.ctx.sampled = r.sample(s.data.ctx.traceID)
+	}
+	if priority, ok := sso.Tags[string(ext.SamplingPriority)].(uint16); ok {
+		s.data.ctx.sampled = priority > 0
+	}
+	r.newID(s.data.ctx.spanID[:])
+	return s
+}
+
+// Inject writes a span context into a TextMap or HTTPHeaders carrier.
+func (r *recorder) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	ctx, ok := sm.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return opentracing.ErrUnsupportedFormat
+	}
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	r.propagator.inject(ctx, w)
+	return nil
+}
+
+// Extract reads a span context from a TextMap or HTTPHeaders carrier.
+func (r *recorder) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if format != opentracing.TextMap && format != opentracing.HTTPHeaders {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	rdr, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	ctx, err := r.propagator.extract(rdr)
+	if err != nil {
+		return nil, err
+	}
+	sCtx := ctx.(spanContext)
+	sCtx.remote = true
+	return sCtx, nil
+}
+
+//------------------------------------------------------------------------------
+
+// spanContext identifies a span within a trace and carries its baggage.
+type spanContext struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	traceState string
+	sampled    bool
+	baggage    map[string]string
+
+	// remote is true when the context was extracted from a carrier.
+	remote bool
+}
+
+// ForeachBaggageItem calls a handler for each baggage item until it returns
+// false.
+func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+// spanEvent is a log of a span.
+type spanEvent struct {
+	timestamp time.Time
+	fields    []olog.Field
+}
+
+// name returns the value of the event field of the log, or log if absent.
+func (e spanEvent) name() string {
+	name := "log"
+	for _, f := range e.fields {
+		if f.Key() == "event" {
+			name = fmt.Sprintf("%v", f.Value())
+		}
+	}
+	return name
+}
+
+// spanData is the recorded contents of a span.
+type spanData struct {
+	ctx          spanContext
+	parentID     [8]byte
+	hasParent    bool
+	remoteParent bool
+	links        []spanContext
+	name         string
+	start        time.Time
+	end          time.Time
+	tags         map[string]interface{}
+	events       []spanEvent
+}
+
+// recordedSpan is an opentracing.Span that is exported by a recorder when it is
+// finished, provided that it was sampled.
+type recordedSpan struct {
+	tracer *recorder
+
+	mut      sync.Mutex
+	data     spanData
+	finished bool
+}
+
+func (s *recordedSpan) Finish() {
+	s.FinishWithOptions(opentracing.FinishOptions{})
+}
+
+func (s *recordedSpan) FinishWithOptions(opts opentracing.FinishOptions) {
+	end := opts.FinishTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.finished {
+		return
+	}
+	s.finished = true
+
+	for _, lr := range opts.LogRecords {
+		s.data.events = append(s.data.events, spanEvent{timestamp: lr.Timestamp, fields: lr.Fields})
+	}
+	for _, ld := range opts.BulkLogData {
+		lr := ld.ToLogRecord()
+		s.data.events = append(s.data.events, spanEvent{timestamp: lr.Timestamp, fields: lr.Fields})
+	}
+	if !s.data.ctx.sampled {
+		return
+	}
+
+	data := s.data
+	data.end = end
+	data.tags = make(map[string]interface{}, len(s.data.tags))
+	for k, v := range s.data.tags {
+		data.tags[k] = v
+	}
+	data.events = append([]spanEvent(nil), s.data.events...)
+	s.tracer.enqueue(data)
+}
+
+func (s *recordedSpan) Context() opentracing.SpanContext {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.data.ctx
+}
+
+func (s *recordedSpan) SetOperationName(operationName string) opentracing.Span {
+	s.mut.Lock()
+	s.data.name = operationName
+	s.mut.Unlock()
+	return s
+}
+
+func (s *recordedSpan) SetTag(key string, value interface{}) opentracing.Span {
+	s.mut.Lock()
+	s.data.tags[key] = value
+	if priority, ok := value.(uint16); ok && key == string(ext.SamplingPriority) {
+		s.data.ctx.sampled = priority > 0
+	}
+	s.mut.Unlock()
+	return s
+}
+
+func (s *recordedSpan) LogFields(fields ...olog.Field) {
+	s.mut.Lock()
+	s.data.events = append(s.data.events, spanEvent{timestamp: time.Now(), fields: fields})
+	s.mut.Unlock()
+}
+
+func (s *recordedSpan) LogKV(alternatingKeyValues ...interface{}) {
+	fields, err := olog.InterleavedKVToFields(alternatingKeyValues...)
+	if err != nil {
+		fields = []olog.Field{olog.Error(err), olog.String("function", "LogKV")}
+	}
+	s.LogFields(fields...)
+}
+
+func (s *recordedSpan) SetBaggageItem(restrictedKey, value string) opentracing.Span {
+	s.mut.Lock()
+	baggage := make(map[string]string, len(s.data.ctx.baggage)+1)
+	for k, v := range s.data.ctx.baggage {
+		baggage[k] = v
+	}
+	baggage[restrictedKey] = value
+	s.data.ctx.baggage = baggage
+	s.mut.Unlock()
+	return s
+}
+
+func (s *recordedSpan) BaggageItem(restrictedKey string) string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.data.ctx.baggage[restrictedKey]
+}
+
+func (s *recordedSpan) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *recordedSpan) LogEvent(event string) {
+	s.LogFields(olog.String("event", event))
+}
+
+func (s *recordedSpan) LogEventWithPayload(event string, payload interface{}) {
+	s.LogFields(olog.String("event", event), olog.Object("payload", payload))
+}
+
+func (s *recordedSpan) Log(ld opentracing.LogData) {
+	lr := ld.ToLogRecord()
+	s.mut.Lock()
+	s.data.events = append(s.data.events, spanEvent{timestamp: lr.Timestamp, fields: lr.Fields})
+	s.mut.Unlock()
+}
+
+//------------------------------------------------------------------------------
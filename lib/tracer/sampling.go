@@ -0,0 +1,123 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+//------------------------------------------------------------------------------
+
+// SamplingOverridesConfig contains ratios of spans to record for components
+// that override the sampling decisions of a tracer, keyed by the type of the
+// component.
+type SamplingOverridesConfig struct {
+	Inputs     map[string]float64 `json:"inputs" yaml:"inputs"`
+	Processors map[string]float64 `json:"processors" yaml:"processors"`
+	Outputs    map[string]float64 `json:"outputs" yaml:"outputs"`
+}
+
+// NewSamplingOverridesConfig creates a SamplingOverridesConfig struct with
+// default values.
+func NewSamplingOverridesConfig() SamplingOverridesConfig {
+	return SamplingOverridesConfig{
+		Inputs:     map[string]float64{},
+		Processors: map[string]float64{},
+		Outputs:    map[string]float64{},
+	}
+}
+
+// operationRatios returns the sampling ratios keyed by the operation names of
+// the spans created by each component.
+func (s SamplingOverridesConfig) operationRatios() (map[string]float64, error) {
+	ratios := map[string]float64{}
+	for _, group := range []struct {
+		prefix string
+		ratios map[string]float64
+	}{
+		{"input_", s.Inputs},
+		{"", s.Processors},
+		{"output_", s.Outputs},
+	} {
+		for k, v := range group.ratios {
+			if v < 0 || v > 1 {
+				return nil, fmt.Errorf("sampling ratio of '%v' must be between 0 and 1: %v", k, v)
+			}
+			ratios[group.prefix+k] = v
+		}
+	}
+	return ratios, nil
+}
+
+//------------------------------------------------------------------------------
+
+// samplingTracer wraps a tracer and sets the sampling priority of spans with
+// an operation name that has a sampling override.
+type samplingTracer struct {
+	opentracing.Tracer
+
+	ratios map[string]float64
+
+	randMut sync.Mutex
+	rand    *rand.Rand
+}
+
+func newSamplingTracer(t opentracing.Tracer, ratios map[string]float64) *samplingTracer {
+	var seed int64
+	if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+		seed = time.Now().UnixNano()
+	}
+	return &samplingTracer{
+		Tracer: t,
+		ratios: ratios,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// StartSpan creates a span with the wrapped tracer, and with a sampling
+// priority tag when the operation name has a sampling override.
+func (s *samplingTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	ratio, exists := s.ratios[operationName]
+	if !exists {
+		return s.Tracer.StartSpan(operationName, opts...)
+	}
+
+	s.randMut.Lock()
+	sampled := s.rand.Float64() < ratio
+	s.randMut.Unlock()
+
+	priority := uint16(0)
+	if sampled {
+		priority = 1
+	}
+	opts = append(opts, opentracing.Tag{Key: string(ext.SamplingPriority), Value: priority})
+	return s.Tracer.StartSpan(operationName, opts...)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,153 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/util/config"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestSamplingOverridesRatios(t *testing.T) {
+	conf := NewSamplingOverridesConfig()
+	conf.Inputs["kafka"] = 0.001
+	conf.Processors["http"] = 1
+	conf.Outputs["elasticsearch"] = 0.5
+
+	ratios, err := conf.operationRatios()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]float64{
+		"input_kafka":          0.001,
+		"http":                 1,
+		"output_elasticsearch": 0.5,
+	}
+	if !reflect.DeepEqual(exp, ratios) {
+		t.Errorf("Wrong ratios: %v != %v", ratios, exp)
+	}
+
+	conf.Processors["http"] = 2
+	if _, err = conf.operationRatios(); err == nil {
+		t.Error("Expected error from bad ratio")
+	}
+}
+
+func TestSamplingTracer(t *testing.T) {
+	mock := mocktracer.New()
+	s := newSamplingTracer(mock, map[string]float64{
+		"input_kafka": 0,
+		"http":        1,
+	})
+
+	s.StartSpan("input_kafka").Finish()
+	s.StartSpan("http").Finish()
+	s.StartSpan("jmespath").Finish()
+
+	spans := mock.FinishedSpans()
+	if exp, act := 3, len(spans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+	if exp, act := uint16(0), spans[0].Tag(string(ext.SamplingPriority)); exp != act {
+		t.Errorf("Wrong sampling priority: %v != %v", act, exp)
+	}
+	if exp, act := uint16(1), spans[1].Tag(string(ext.SamplingPriority)); exp != act {
+		t.Errorf("Wrong sampling priority: %v != %v", act, exp)
+	}
+	if act := spans[2].Tag(string(ext.SamplingPriority)); act != nil {
+		t.Errorf("Unexpected sampling priority: %v", act)
+	}
+}
+
+func TestSamplingPriorityRecorder(t *testing.T) {
+	z, err := newZipkin(NewZipkinConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+
+	s := newSamplingTracer(z, map[string]float64{
+		"input_kafka": 0,
+		"http":        1,
+	})
+
+	root := s.StartSpan("input_kafka")
+	if root.Context().(spanContext).sampled {
+		t.Error("Expected root span not to be sampled")
+	}
+	child := s.StartSpan("jmespath", opentracing.ChildOf(root.Context()))
+	if child.Context().(spanContext).sampled {
+		t.Error("Expected child span to follow parent")
+	}
+	forced := s.StartSpan("http", opentracing.ChildOf(child.Context()))
+	if !forced.Context().(spanContext).sampled {
+		t.Error("Expected overridden span to be sampled")
+	}
+	grandChild := s.StartSpan("http_request", opentracing.ChildOf(forced.Context()))
+	if !grandChild.Context().(spanContext).sampled {
+		t.Error("Expected child of overridden span to be sampled")
+	}
+
+	ext.SamplingPriority.Set(root, 1)
+	if !root.Context().(spanContext).sampled {
+		t.Error("Expected tagged span to be sampled")
+	}
+}
+
+func TestNewSamplingOverrides(t *testing.T) {
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	conf := NewConfig()
+	conf.Type = TypeNone
+	conf.SamplingOverrides.Processors["http"] = 2
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from bad ratio")
+	}
+
+	conf.SamplingOverrides.Processors["http"] = 1
+	if _, err := New(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := opentracing.GlobalTracer().(*samplingTracer); !ok {
+		t.Errorf("Expected sampling tracer, got: %T", opentracing.GlobalTracer())
+	}
+
+	act, err := SanitiseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := config.Sanitised{
+		"type": "none",
+		"none": map[string]interface{}{},
+		"sampling_overrides": map[string]interface{}{
+			"inputs":     map[string]interface{}{},
+			"processors": map[string]interface{}{"http": float64(1)},
+			"outputs":    map[string]interface{}{},
+		},
+	}
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong sanitised output: %v != %v", act, exp)
+	}
+}
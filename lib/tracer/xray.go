@@ -0,0 +1,343 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeXRay] = TypeSpec{
+		constructor: NewXRay,
+		description: `
+EXPERIMENTAL: This component is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Send spans as segments to [AWS X-Ray](https://aws.amazon.com/xray/) via the UDP
+interface of an X-Ray daemon. When ` + "`address`" + ` is left empty the address
+is read from the environment variable ` + "`AWS_XRAY_DAEMON_ADDRESS`" + `, and
+if that is also empty defaults to ` + "`127.0.0.1:2000`" + `.
+
+Spans that begin a trace, or that continue a trace from an upstream service,
+are sent as segments named by ` + "`service_name`" + ` with the operation name
+of the span as the annotation ` + "`operation`" + `. All other spans are sent
+as subsegments of their parent. Span tags are sent as annotations, with
+characters of their keys that X-Ray does not support replaced with
+underscores, and span logs are sent as metadata.
+
+Span contexts are injected into and extracted from message carriers such as
+HTTP headers with the ` + "`X-Amzn-Trace-Id`" + ` header.
+
+### Sampling
+
+The ` + "`sampler_type`" + ` determines which traces are recorded, and can be
+one of ` + "`always_on`" + `, ` + "`always_off`" + ` or ` + "`ratio`" + `, where
+` + "`ratio`" + ` records a fraction ` + "`sampler_ratio`" + ` of traces. Traces
+extracted from upstream services follow their sampling decision.
+
+### Lambda
+
+When running within AWS Lambda the trace header of each invocation is used as
+the parent of the spans of its payload, and spans that would otherwise be sent
+as segments are sent as subsegments of the invocation segment created by
+Lambda. This requires active tracing to be enabled for the function.
+
+### Batching
+
+Finished spans are queued and sent in batches of up to ` + "`batch_size`" + `
+spans at least every ` + "`flush_interval`" + `. When the queue reaches
+` + "`max_queue_size`" + ` spans any further spans are dropped until it has been
+sent.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// XRayConfig is config for the X-Ray tracer type.
+type XRayConfig struct {
+	Address       string  `json:"address" yaml:"address"`
+	ServiceName   string  `json:"service_name" yaml:"service_name"`
+	SamplerType   string  `json:"sampler_type" yaml:"sampler_type"`
+	SamplerRatio  float64 `json:"sampler_ratio" yaml:"sampler_ratio"`
+	BatchSize     int     `json:"batch_size" yaml:"batch_size"`
+	MaxQueueSize  int     `json:"max_queue_size" yaml:"max_queue_size"`
+	FlushInterval string  `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// NewXRayConfig creates an XRayConfig struct with default values.
+func NewXRayConfig() XRayConfig {
+	return XRayConfig{
+		Address:       "",
+		ServiceName:   "benthos",
+		SamplerType:   "always_on",
+		SamplerRatio:  1.0,
+		BatchSize:     64,
+		MaxQueueSize:  2048,
+		FlushInterval: "100ms",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+const xrayDaemonHeader = `{"format": "json", "version": 1}` + "\n"
+
+// XRay is a tracer with the capability to send spans to an AWS X-Ray daemon.
+type XRay struct {
+	*recorder
+
+	conn        net.Conn
+	serviceName string
+	inLambda    bool
+}
+
+// NewXRay creates and returns a new XRay tracer object.
+func NewXRay(config Config, opts ...func(Type)) (Type, error) {
+	x, err := newXRay(config.XRay, opts...)
+	if err != nil {
+		return nil, err
+	}
+	opentracing.SetGlobalTracer(x)
+	return x, nil
+}
+
+func newXRay(conf XRayConfig, opts ...func(Type)) (*XRay, error) {
+	x := &XRay{
+		serviceName: conf.ServiceName,
+		inLambda:    len(os.Getenv("AWS_LAMBDA_FUNCTION_NAME")) > 0,
+	}
+
+	var err error
+	if x.recorder, err = newRecorder(recorderConfig{
+		samplerType:        conf.SamplerType,
+		samplerRatio:       conf.SamplerRatio,
+		samplerParentBased: true,
+		batchSize:          conf.BatchSize,
+		maxQueueSize:       conf.MaxQueueSize,
+		flushInterval:      conf.FlushInterval,
+	}, xrayPropagator{}, x.export); err != nil {
+		return nil, err
+	}
+	x.traceIDFn = x.newTraceID
+
+	if x.conn, err = net.Dial("udp", xrayDaemonAddress(conf.Address)); err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %v", err)
+	}
+
+	for _, opt := range opts {
+		opt(x)
+	}
+
+	x.start()
+	return x, nil
+}
+
+// xrayDaemonAddress returns the UDP address of the X-Ray daemon, which can
+// also be set in the environment as either host:port or a space separated
+// pair of tcp:host:port and udp:host:port.
+func xrayDaemonAddress(addr string) string {
+	if len(addr) > 0 {
+		return addr
+	}
+	env := os.Getenv("AWS_XRAY_DAEMON_ADDRESS")
+	for _, a := range strings.Fields(env) {
+		if strings.HasPrefix(a, "udp:") {
+			return strings.TrimPrefix(a, "udp:")
+		}
+	}
+	if len(env) > 0 && !strings.Contains(env, " ") {
+		return env
+	}
+	return "127.0.0.1:2000"
+}
+
+// newTraceID creates a trace ID where the first four bytes are the current
+// epoch time, as X-Ray rejects traces with a time that is too old.
+func (x *XRay) newTraceID(traceID []byte) {
+	binary.BigEndian.PutUint32(traceID[:4], uint32(time.Now().Unix()))
+	x.newID(traceID[4:])
+}
+
+//------------------------------------------------------------------------------
+
+type xrayException struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+type xrayCause struct {
+	Exceptions []xrayException `json:"exceptions"`
+}
+
+type xraySegment struct {
+	Name        string                            `json:"name"`
+	ID          string                            `json:"id"`
+	TraceID     string                            `json:"trace_id"`
+	ParentID    string                            `json:"parent_id,omitempty"`
+	Type        string                            `json:"type,omitempty"`
+	StartTime   float64                           `json:"start_time"`
+	EndTime     float64                           `json:"end_time"`
+	Namespace   string                            `json:"namespace,omitempty"`
+	Error       bool                              `json:"error,omitempty"`
+	Cause       *xrayCause                        `json:"cause,omitempty"`
+	Annotations map[string]interface{}            `json:"annotations,omitempty"`
+	Metadata    map[string]map[string]interface{} `json:"metadata,omitempty"`
+}
+
+func xrayTime(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// xrayAnnotationKey replaces characters of a key that are not supported by
+// X-Ray annotations with underscores.
+func xrayAnnotationKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, k)
+}
+
+func xrayAnnotationValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return t
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// toSegment converts a span into an X-Ray segment or subsegment document.
+func (x *XRay) toSegment(s spanData) xraySegment {
+	seg := xraySegment{
+		ID:        hex.EncodeToString(s.ctx.spanID[:]),
+		TraceID:   xrayTraceID(s.ctx.traceID),
+		StartTime: xrayTime(s.start),
+		EndTime:   xrayTime(s.end),
+	}
+	if s.hasParent {
+		seg.ParentID = hex.EncodeToString(s.parentID[:])
+	}
+
+	if s.hasParent && (!s.remoteParent || x.inLambda) {
+		seg.Type = "subsegment"
+		seg.Name = s.name
+	} else {
+		seg.Name = x.serviceName
+		seg.Annotations = map[string]interface{}{"operation": s.name}
+	}
+
+	for k, v := range s.tags {
+		switch k {
+		case string(ext.SpanKind):
+			if fmt.Sprintf("%v", v) == string(ext.SpanKindRPCClientEnum) {
+				seg.Namespace = "remote"
+			}
+			continue
+		case string(ext.Error):
+			if isErr, _ := v.(bool); isErr {
+				seg.Error = true
+			}
+			continue
+		}
+		if seg.Annotations == nil {
+			seg.Annotations = map[string]interface{}{}
+		}
+		seg.Annotations[xrayAnnotationKey(k)] = xrayAnnotationValue(v)
+	}
+
+	var events []interface{}
+	for _, e := range s.events {
+		event := map[string]interface{}{"timestamp": xrayTime(e.timestamp)}
+		for _, f := range e.fields {
+			event[f.Key()] = fmt.Sprintf("%v", f.Value())
+		}
+		events = append(events, event)
+
+		if e.name() != "error" {
+			continue
+		}
+		msg := "error"
+		for _, f := range e.fields {
+			switch f.Key() {
+			case "type", "message", "error", "error.object":
+				msg = fmt.Sprintf("%v", f.Value())
+			}
+		}
+		if seg.Cause == nil {
+			seg.Cause = &xrayCause{}
+		}
+		var id [8]byte
+		x.newID(id[:])
+		seg.Cause.Exceptions = append(seg.Cause.Exceptions, xrayException{
+			ID:      hex.EncodeToString(id[:]),
+			Message: msg,
+		})
+		seg.Error = true
+	}
+	if len(events) > 0 {
+		seg.Metadata = map[string]map[string]interface{}{
+			"benthos": {"events": events},
+		}
+	}
+	return seg
+}
+
+// export sends a batch of spans to the daemon, one segment per datagram.
+func (x *XRay) export(spans []spanData) {
+	for _, s := range spans {
+		segBytes, err := json.Marshal(x.toSegment(s))
+		if err != nil {
+			x.log.Errorf("Failed to serialise segment: %v\n", err)
+			continue
+		}
+		if _, err = x.conn.Write(append([]byte(xrayDaemonHeader), segBytes...)); err != nil {
+			x.log.Errorf("Failed to send segment: %v\n", err)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SetLogger sets the logger used to print export errors.
+func (x *XRay) SetLogger(log log.Modular) {
+	x.log = log
+}
+
+// Close stops the tracer and sends any remaining spans.
+func (x *XRay) Close() error {
+	x.close()
+	return x.conn.Close()
+}
+
+//------------------------------------------------------------------------------
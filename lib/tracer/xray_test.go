@@ -0,0 +1,257 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// readXRaySegments reads segment documents sent to a UDP listener until the
+// expected count is reached.
+func readXRaySegments(t *testing.T, conn net.PacketConn, count int) []xraySegment {
+	t.Helper()
+
+	var segments []xraySegment
+	buf := make([]byte, 65536)
+	for len(segments) < count {
+		conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := buf[:n]
+		if !bytes.HasPrefix(doc, []byte(xrayDaemonHeader)) {
+			t.Fatalf("Missing daemon header: %s", doc)
+		}
+		var seg xraySegment
+		if err = json.Unmarshal(doc[len(xrayDaemonHeader):], &seg); err != nil {
+			t.Fatal(err)
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+func TestXRayBadConfig(t *testing.T) {
+	for _, f := range []func(c *XRayConfig){
+		func(c *XRayConfig) { c.SamplerType = "nope" },
+		func(c *XRayConfig) { c.FlushInterval = "0s" },
+		func(c *XRayConfig) { c.BatchSize = 0 },
+	} {
+		conf := NewXRayConfig()
+		f(&conf)
+		if _, err := newXRay(conf); err == nil {
+			t.Errorf("Expected error from config: %+v", conf)
+		}
+	}
+}
+
+func TestXRayDaemonAddress(t *testing.T) {
+	defer os.Unsetenv("AWS_XRAY_DAEMON_ADDRESS")
+
+	os.Unsetenv("AWS_XRAY_DAEMON_ADDRESS")
+	if exp, act := "127.0.0.1:2000", xrayDaemonAddress(""); exp != act {
+		t.Errorf("Wrong address: %v != %v", act, exp)
+	}
+	if exp, act := "foo:1234", xrayDaemonAddress("foo:1234"); exp != act {
+		t.Errorf("Wrong address: %v != %v", act, exp)
+	}
+	os.Setenv("AWS_XRAY_DAEMON_ADDRESS", "bar:2000")
+	if exp, act := "bar:2000", xrayDaemonAddress(""); exp != act {
+		t.Errorf("Wrong address: %v != %v", act, exp)
+	}
+	os.Setenv("AWS_XRAY_DAEMON_ADDRESS", "tcp:baz:2000 udp:qux:2001")
+	if exp, act := "qux:2001", xrayDaemonAddress(""); exp != act {
+		t.Errorf("Wrong address: %v != %v", act, exp)
+	}
+}
+
+func TestXRayPropagation(t *testing.T) {
+	x, err := newXRay(NewXRayConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+
+	span := x.StartSpan("foo")
+	traceID := span.Context().(spanContext).traceID
+	epoch, _ := strconv.ParseInt(xrayTraceID(traceID)[2:10], 16, 64)
+	if diff := time.Now().Unix() - epoch; diff < 0 || diff > 60 {
+		t.Errorf("Wrong trace ID epoch: %v", xrayTraceID(traceID))
+	}
+
+	headers := http.Header{}
+	if err = x.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers)); err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := x.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, act := span.Context().(spanContext), extracted.(spanContext)
+	if exp.traceID != act.traceID || exp.spanID != act.spanID || !act.sampled {
+		t.Errorf("Wrong extracted context: %+v != %+v", act, exp)
+	}
+
+	rootOnly, err := x.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+		"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := x.StartSpan("bar", opentracing.ChildOf(rootOnly)).(*recordedSpan)
+	if child.data.hasParent {
+		t.Error("Expected span without parent ID to begin a segment")
+	}
+	if exp, act := "1-5759e988-bd862e3fe1be46a994272793", xrayTraceID(child.data.ctx.traceID); exp != act {
+		t.Errorf("Wrong trace ID: %v != %v", act, exp)
+	}
+	if child.data.ctx.sampled {
+		t.Error("Expected span to follow sampling decision")
+	}
+
+	for _, header := range []string{
+		"Root=nope;Parent=53995c3f42cd8ad8",
+		"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=nope",
+	} {
+		if _, err = x.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+			"X-Amzn-Trace-Id": header,
+		}); err != opentracing.ErrSpanContextCorrupted {
+			t.Errorf("Wrong error for header '%v': %v", header, err)
+		}
+	}
+}
+
+func TestXRayExport(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewXRayConfig()
+	conf.Address = conn.LocalAddr().String()
+	conf.ServiceName = "foo"
+
+	x, err := newXRay(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+
+	parent := x.StartSpan("parent")
+	child := x.StartSpan("child", opentracing.ChildOf(parent.Context()))
+	ext.SpanKindRPCClient.Set(child)
+	child.SetTag("kafka.topic", "bar")
+	child.LogKV("event", "error", "type", "failed thing")
+	child.Finish()
+	parent.Finish()
+
+	segments := readXRaySegments(t, conn, 2)
+	childSeg, parentSeg := segments[0], segments[1]
+
+	if exp, act := "foo", parentSeg.Name; exp != act {
+		t.Errorf("Wrong segment name: %v != %v", act, exp)
+	}
+	if exp, act := "parent", parentSeg.Annotations["operation"]; exp != act {
+		t.Errorf("Wrong operation annotation: %v != %v", act, exp)
+	}
+	if exp, act := "", parentSeg.Type; exp != act {
+		t.Errorf("Wrong segment type: %v != %v", act, exp)
+	}
+
+	if exp, act := "child", childSeg.Name; exp != act {
+		t.Errorf("Wrong subsegment name: %v != %v", act, exp)
+	}
+	if exp, act := "subsegment", childSeg.Type; exp != act {
+		t.Errorf("Wrong subsegment type: %v != %v", act, exp)
+	}
+	if exp, act := parentSeg.ID, childSeg.ParentID; exp != act {
+		t.Errorf("Wrong parent ID: %v != %v", act, exp)
+	}
+	if exp, act := parentSeg.TraceID, childSeg.TraceID; exp != act {
+		t.Errorf("Wrong trace ID: %v != %v", act, exp)
+	}
+	if exp, act := "remote", childSeg.Namespace; exp != act {
+		t.Errorf("Wrong namespace: %v != %v", act, exp)
+	}
+	if exp, act := "bar", childSeg.Annotations["kafka_topic"]; exp != act {
+		t.Errorf("Wrong annotation: %v != %v", act, exp)
+	}
+	if !childSeg.Error || childSeg.Cause == nil || len(childSeg.Cause.Exceptions) != 1 {
+		t.Fatalf("Expected error cause: %+v", childSeg)
+	}
+	if exp, act := "failed thing", childSeg.Cause.Exceptions[0].Message; exp != act {
+		t.Errorf("Wrong exception message: %v != %v", act, exp)
+	}
+	if childSeg.EndTime < childSeg.StartTime {
+		t.Errorf("Wrong subsegment timing: %v > %v", childSeg.StartTime, childSeg.EndTime)
+	}
+}
+
+func TestXRayExportLambda(t *testing.T) {
+	os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "foo")
+	defer os.Unsetenv("AWS_LAMBDA_FUNCTION_NAME")
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conf := NewXRayConfig()
+	conf.Address = conn.LocalAddr().String()
+
+	x, err := newXRay(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+
+	invocation, err := x.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+		"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.StartSpan("foo", opentracing.ChildOf(invocation)).Finish()
+
+	seg := readXRaySegments(t, conn, 1)[0]
+	if exp, act := "subsegment", seg.Type; exp != act {
+		t.Errorf("Wrong segment type: %v != %v", act, exp)
+	}
+	if exp, act := "53995c3f42cd8ad8", seg.ParentID; exp != act {
+		t.Errorf("Wrong parent ID: %v != %v", act, exp)
+	}
+	if exp, act := "1-5759e988-bd862e3fe1be46a994272793", seg.TraceID; exp != act {
+		t.Errorf("Wrong trace ID: %v != %v", act, exp)
+	}
+}
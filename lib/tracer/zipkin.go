@@ -0,0 +1,275 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeZipkin] = TypeSpec{
+		constructor: NewZipkin,
+		description: `
+EXPERIMENTAL: This component is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Send spans to a [Zipkin](https://zipkin.io/) server using the JSON v2 API, where
+the ` + "`url`" + ` is the full URL that spans are posted to.
+
+Spans are reported with a local endpoint named by ` + "`service_name`" + `.
+Span contexts are injected into and extracted from message carriers such as
+HTTP headers in the [B3](https://github.com/openzipkin/b3-propagation) format,
+and extraction also accepts the single ` + "`b3`" + ` header. Traces started by
+Benthos are always recorded, and those extracted from upstream services follow
+their sampling decision.
+
+### Batching
+
+Finished spans are queued and sent in batches of up to ` + "`batch_size`" + `
+spans at least every ` + "`flush_interval`" + `. When the queue reaches
+` + "`max_queue_size`" + ` spans any further spans are dropped until it has been
+sent.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ZipkinConfig is config for the Zipkin tracer type.
+type ZipkinConfig struct {
+	URL           string `json:"url" yaml:"url"`
+	ServiceName   string `json:"service_name" yaml:"service_name"`
+	BatchSize     int    `json:"batch_size" yaml:"batch_size"`
+	MaxQueueSize  int    `json:"max_queue_size" yaml:"max_queue_size"`
+	FlushInterval string `json:"flush_interval" yaml:"flush_interval"`
+	Timeout       string `json:"timeout" yaml:"timeout"`
+}
+
+// NewZipkinConfig creates a ZipkinConfig struct with default values.
+func NewZipkinConfig() ZipkinConfig {
+	return ZipkinConfig{
+		URL:           "http://localhost:9411/api/v2/spans",
+		ServiceName:   "benthos",
+		BatchSize:     512,
+		MaxQueueSize:  2048,
+		FlushInterval: "1s",
+		Timeout:       "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Zipkin is a tracer with the capability to send spans to a Zipkin server.
+type Zipkin struct {
+	*recorder
+
+	url      string
+	endpoint zipkinEndpoint
+	client   http.Client
+}
+
+// NewZipkin creates and returns a new Zipkin tracer object.
+func NewZipkin(config Config, opts ...func(Type)) (Type, error) {
+	z, err := newZipkin(config.Zipkin, opts...)
+	if err != nil {
+		return nil, err
+	}
+	opentracing.SetGlobalTracer(z)
+	return z, nil
+}
+
+func newZipkin(conf ZipkinConfig, opts ...func(Type)) (*Zipkin, error) {
+	if len(conf.URL) == 0 {
+		return nil, fmt.Errorf("a url must be specified")
+	}
+	timeout, err := time.ParseDuration(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	z := &Zipkin{
+		url:      conf.URL,
+		endpoint: zipkinEndpoint{ServiceName: conf.ServiceName},
+		client:   http.Client{Timeout: timeout},
+	}
+	if z.recorder, err = newRecorder(recorderConfig{
+		samplerType:        "always_on",
+		samplerParentBased: true,
+		batchSize:          conf.BatchSize,
+		maxQueueSize:       conf.MaxQueueSize,
+		flushInterval:      conf.FlushInterval,
+	}, b3Propagator{}, z.export); err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(z)
+	}
+
+	z.start()
+	return z, nil
+}
+
+//------------------------------------------------------------------------------
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	Duration      int64              `json:"duration"`
+	LocalEndpoint zipkinEndpoint     `json:"localEndpoint"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+}
+
+// toZipkinSpan converts a span into its Zipkin v2 model.
+func (z *Zipkin) toZipkinSpan(s spanData) zipkinSpan {
+	zs := zipkinSpan{
+		TraceID:       b3TraceID(s.ctx.traceID),
+		ID:            hex.EncodeToString(s.ctx.spanID[:]),
+		Name:          s.name,
+		Timestamp:     s.start.UnixNano() / int64(time.Microsecond),
+		Duration:      int64(s.end.Sub(s.start) / time.Microsecond),
+		LocalEndpoint: z.endpoint,
+	}
+	if zs.Duration < 1 {
+		zs.Duration = 1
+	}
+	if s.hasParent {
+		zs.ParentID = hex.EncodeToString(s.parentID[:])
+	}
+
+	switch fmt.Sprintf("%v", s.tags[string(ext.SpanKind)]) {
+	case string(ext.SpanKindRPCServerEnum):
+		zs.Kind = "SERVER"
+	case string(ext.SpanKindRPCClientEnum):
+		zs.Kind = "CLIENT"
+	case string(ext.SpanKindProducerEnum):
+		zs.Kind = "PRODUCER"
+	case string(ext.SpanKindConsumerEnum):
+		zs.Kind = "CONSUMER"
+	}
+
+	for k, v := range s.tags {
+		if k == string(ext.SpanKind) {
+			continue
+		}
+		if zs.Tags == nil {
+			zs.Tags = map[string]string{}
+		}
+		zs.Tags[k] = fmt.Sprintf("%v", v)
+	}
+
+	for _, e := range s.events {
+		var value string
+		if len(e.fields) == 1 && e.fields[0].Key() == "event" {
+			value = e.name()
+		} else {
+			kvs := make([]string, 0, len(e.fields))
+			for _, f := range e.fields {
+				kvs = append(kvs, fmt.Sprintf("%v=%v", f.Key(), f.Value()))
+			}
+			value = strings.Join(kvs, " ")
+		}
+		zs.Annotations = append(zs.Annotations, zipkinAnnotation{
+			Timestamp: e.timestamp.UnixNano() / int64(time.Microsecond),
+			Value:     value,
+		})
+	}
+	return zs
+}
+
+// export sends a batch of spans to the Zipkin server.
+func (z *Zipkin) export(spans []spanData) {
+	zSpans := make([]zipkinSpan, 0, len(spans))
+	for _, s := range spans {
+		zSpans = append(zSpans, z.toZipkinSpan(s))
+	}
+
+	body, err := json.Marshal(zSpans)
+	if err != nil {
+		z.log.Errorf("Failed to serialise spans: %v\n", err)
+		return
+	}
+	if err = z.post(body); err != nil {
+		z.log.Errorf("Failed to send spans: %v\n", err)
+	}
+}
+
+func (z *Zipkin) post(body []byte) error {
+	req, err := http.NewRequest("POST", z.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := z.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("server returned status: %v", res.StatusCode)
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// SetLogger sets the logger used to print export errors.
+func (z *Zipkin) SetLogger(log log.Modular) {
+	z.log = log
+}
+
+// Close stops the tracer and sends any remaining spans.
+func (z *Zipkin) Close() error {
+	z.close()
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,214 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tracer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+func TestZipkinBadConfig(t *testing.T) {
+	for _, f := range []func(c *ZipkinConfig){
+		func(c *ZipkinConfig) { c.URL = "" },
+		func(c *ZipkinConfig) { c.Timeout = "nope" },
+		func(c *ZipkinConfig) { c.FlushInterval = "0s" },
+		func(c *ZipkinConfig) { c.BatchSize = 0 },
+		func(c *ZipkinConfig) { c.MaxQueueSize = 1 },
+	} {
+		conf := NewZipkinConfig()
+		f(&conf)
+		if _, err := newZipkin(conf); err == nil {
+			t.Errorf("Expected error from config: %+v", conf)
+		}
+	}
+}
+
+func TestZipkinPropagation(t *testing.T) {
+	z, err := newZipkin(NewZipkinConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+
+	span := z.StartSpan("foo")
+
+	headers := http.Header{}
+	if err = z.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers)); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "1", headers.Get("X-B3-Sampled"); exp != act {
+		t.Errorf("Wrong sampled header: %v != %v", act, exp)
+	}
+
+	extracted, err := z.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, act := span.Context().(spanContext), extracted.(spanContext)
+	if exp.traceID != act.traceID || exp.spanID != act.spanID || !act.sampled {
+		t.Errorf("Wrong extracted context: %+v != %+v", act, exp)
+	}
+
+	tests := map[string]struct {
+		carrier opentracing.TextMapCarrier
+		traceID string
+		sampled bool
+	}{
+		"64 bit trace id": {
+			carrier: opentracing.TextMapCarrier{
+				"x-b3-traceid": "463ac35c9f6413ad",
+				"x-b3-spanid":  "a2fb4a1d1a96d312",
+				"x-b3-sampled": "0",
+			},
+			traceID: "463ac35c9f6413ad",
+		},
+		"single header": {
+			carrier: opentracing.TextMapCarrier{
+				"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1",
+			},
+			traceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			sampled: true,
+		},
+		"deferred sampling": {
+			carrier: opentracing.TextMapCarrier{
+				"X-B3-TraceId": "80f198ee56343ba864fe8b2a57d3eff7",
+				"X-B3-SpanId":  "e457b5a2e4d86bd1",
+			},
+			traceID: "80f198ee56343ba864fe8b2a57d3eff7",
+			sampled: true,
+		},
+	}
+	for name, test := range tests {
+		ctx, err := z.Extract(opentracing.TextMap, test.carrier)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if exp, act := test.traceID, b3TraceID(ctx.(spanContext).traceID); exp != act {
+			t.Errorf("%v: wrong trace id: %v != %v", name, act, exp)
+		}
+		if exp, act := test.sampled, ctx.(spanContext).sampled; exp != act {
+			t.Errorf("%v: wrong sampled: %v != %v", name, act, exp)
+		}
+	}
+
+	if _, err = z.Extract(opentracing.TextMap, opentracing.TextMapCarrier{"b3": "0"}); err != opentracing.ErrSpanContextNotFound {
+		t.Errorf("Wrong error for sampling only header: %v", err)
+	}
+	if _, err = z.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+		"x-b3-traceid": "nope",
+		"x-b3-spanid":  "a2fb4a1d1a96d312",
+	}); err != opentracing.ErrSpanContextCorrupted {
+		t.Errorf("Wrong error for corrupt header: %v", err)
+	}
+}
+
+func TestZipkinExport(t *testing.T) {
+	var reqMut sync.Mutex
+	var reqSpans []zipkinSpan
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "application/json", r.Header.Get("Content-Type"); exp != act {
+			t.Errorf("Wrong content type: %v != %v", act, exp)
+		}
+		var spans []zipkinSpan
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Error(err)
+		}
+		reqMut.Lock()
+		reqSpans = append(reqSpans, spans...)
+		reqMut.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	conf := NewZipkinConfig()
+	conf.URL = server.URL + "/api/v2/spans"
+	conf.ServiceName = "foo"
+	conf.FlushInterval = "1h"
+
+	z, err := newZipkin(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := z.StartSpan("parent")
+	child := z.StartSpan("child", opentracing.ChildOf(parent.Context()))
+	ext.SpanKindRPCClient.Set(child)
+	ext.Error.Set(child, true)
+	child.LogKV("event", "failed", "attempt", 2)
+	child.LogEvent("retrying")
+	child.Finish()
+	parent.Finish()
+
+	if err = z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reqMut.Lock()
+	defer reqMut.Unlock()
+
+	if exp, act := 2, len(reqSpans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+	childSpan, parentSpan := reqSpans[0], reqSpans[1]
+	if exp, act := "child", childSpan.Name; exp != act {
+		t.Errorf("Wrong span name: %v != %v", act, exp)
+	}
+	if exp, act := parentSpan.ID, childSpan.ParentID; exp != act {
+		t.Errorf("Wrong parent id: %v != %v", act, exp)
+	}
+	if exp, act := parentSpan.TraceID, childSpan.TraceID; exp != act {
+		t.Errorf("Wrong trace id: %v != %v", act, exp)
+	}
+	if exp, act := "CLIENT", childSpan.Kind; exp != act {
+		t.Errorf("Wrong span kind: %v != %v", act, exp)
+	}
+	if exp, act := "", parentSpan.Kind; exp != act {
+		t.Errorf("Wrong parent span kind: %v != %v", act, exp)
+	}
+	if exp, act := "foo", childSpan.LocalEndpoint.ServiceName; exp != act {
+		t.Errorf("Wrong service name: %v != %v", act, exp)
+	}
+	if exp, act := "true", childSpan.Tags["error"]; exp != act {
+		t.Errorf("Wrong error tag: %v != %v", act, exp)
+	}
+	if _, exists := childSpan.Tags["span.kind"]; exists {
+		t.Error("Expected span kind to be omitted from tags")
+	}
+	if exp, act := 2, len(childSpan.Annotations); exp != act {
+		t.Fatalf("Wrong count of annotations: %v != %v", act, exp)
+	}
+	if exp, act := "event=failed attempt=2", childSpan.Annotations[0].Value; exp != act {
+		t.Errorf("Wrong annotation: %v != %v", act, exp)
+	}
+	if exp, act := "retrying", childSpan.Annotations[1].Value; exp != act {
+		t.Errorf("Wrong annotation: %v != %v", act, exp)
+	}
+	if childSpan.Duration < 1 || childSpan.Timestamp <= 0 {
+		t.Errorf("Wrong span timing: %v %v", childSpan.Timestamp, childSpan.Duration)
+	}
+}
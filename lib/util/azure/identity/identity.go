@@ -0,0 +1,169 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package identity provides access tokens of Azure managed identities, which
+// allow Azure hosted services to authenticate without explicit credentials.
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// Config contains configuration fields for authenticating with an Azure
+// managed identity.
+type Config struct {
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	ClientID string `json:"client_id" yaml:"client_id"`
+}
+
+// NewConfig returns a Config with default values.
+func NewConfig() Config {
+	return Config{
+		Enabled:  false,
+		ClientID: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// imdsEndpoint is the token endpoint of the instance metadata service, which is
+// available on virtual machines and container instances.
+const imdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// refreshWindow is how long before their expiry tokens are refreshed.
+const refreshWindow = time.Minute * 5
+
+// TokenSource obtains and caches access tokens of a managed identity for a
+// resource.
+type TokenSource struct {
+	resource string
+	clientID string
+	http     *http.Client
+
+	endpoint   string
+	apiVersion string
+	header     http.Header
+
+	mut     sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewTokenSource creates a source of tokens for a resource, such as
+// https://storage.azure.com/, where requests time out after the given
+// duration. Tokens are obtained from the endpoint of App Service when the
+// IDENTITY_ENDPOINT and IDENTITY_HEADER environment variables are set, and from
+// the instance metadata service otherwise.
+func (c Config) NewTokenSource(resource string, timeout time.Duration) *TokenSource {
+	t := &TokenSource{
+		resource:   resource,
+		clientID:   c.ClientID,
+		http:       &http.Client{Timeout: timeout},
+		endpoint:   imdsEndpoint,
+		apiVersion: "2018-02-01",
+		header:     http.Header{"Metadata": []string{"true"}},
+	}
+	if endpoint, secret := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); len(endpoint) > 0 && len(secret) > 0 {
+		t.endpoint = endpoint
+		t.apiVersion = "2019-08-01"
+		t.header = http.Header{"X-Identity-Header": []string{secret}}
+	}
+	return t
+}
+
+// SetEndpoint overrides the token endpoint, which is useful for testing.
+func (t *TokenSource) SetEndpoint(endpoint string) {
+	t.endpoint = endpoint
+}
+
+// Token returns an access token, which is cached until shortly before it
+// expires.
+func (t *TokenSource) Token(ctx context.Context) (string, error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if len(t.token) > 0 && time.Now().Add(refreshWindow).Before(t.expires) {
+		return t.token, nil
+	}
+
+	query := url.Values{
+		"api-version": []string{t.apiVersion},
+		"resource":    []string{t.resource},
+	}
+	if len(t.clientID) > 0 {
+		query.Set("client_id", t.clientID)
+	}
+	req, err := http.NewRequest(http.MethodGet, t.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+
+	res, err := t.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request managed identity token: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read managed identity token: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("managed identity endpoint returned status %v: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresOn   json.Number `json:"expires_on"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse managed identity token: %v", err)
+	}
+	if len(result.AccessToken) == 0 {
+		return "", errors.New("managed identity endpoint returned an empty token")
+	}
+	expires, err := strconv.ParseInt(result.ExpiresOn.String(), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse managed identity token expiry: %v", err)
+	}
+
+	t.token, t.expires = result.AccessToken, time.Unix(expires, 0)
+	return t.token, nil
+}
+
+//------------------------------------------------------------------------------
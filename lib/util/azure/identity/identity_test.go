@@ -0,0 +1,142 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+func TestTokenSourceIMDS(t *testing.T) {
+	os.Unsetenv("IDENTITY_ENDPOINT")
+	os.Unsetenv("IDENTITY_HEADER")
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if exp, act := "true", r.Header.Get("Metadata"); exp != act {
+			t.Errorf("Wrong metadata header: %v != %v", act, exp)
+		}
+		q := r.URL.Query()
+		if exp, act := "https://storage.azure.com/", q.Get("resource"); exp != act {
+			t.Errorf("Wrong resource: %v != %v", act, exp)
+		}
+		if exp, act := "foo", q.Get("client_id"); exp != act {
+			t.Errorf("Wrong client id: %v != %v", act, exp)
+		}
+		if exp, act := "2018-02-01", q.Get("api-version"); exp != act {
+			t.Errorf("Wrong api version: %v != %v", act, exp)
+		}
+		fmt.Fprintf(w, `{"access_token":"token%v","expires_on":"%v","token_type":"Bearer"}`, requests, time.Now().Add(time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.ClientID = "foo"
+	ts := conf.NewTokenSource("https://storage.azure.com/", time.Second)
+	ts.SetEndpoint(server.URL)
+
+	for i := 0; i < 2; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := "token1", token; exp != act {
+			t.Errorf("Wrong token: %v != %v", act, exp)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected token to be cached: %v requests", requests)
+	}
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"access_token":"token%v","expires_on":%v}`, requests, time.Now().Add(time.Minute).Unix())
+	}))
+	defer server.Close()
+
+	ts := NewConfig().NewTokenSource("https://servicebus.azure.net/", time.Second)
+	ts.SetEndpoint(server.URL)
+
+	for i := 1; i <= 2; i++ {
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := fmt.Sprintf("token%v", i), token; exp != act {
+			t.Errorf("Wrong token: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestTokenSourceAppService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "secret", r.Header.Get("X-IDENTITY-HEADER"); exp != act {
+			t.Errorf("Wrong identity header: %v != %v", act, exp)
+		}
+		if exp, act := "2019-08-01", r.URL.Query().Get("api-version"); exp != act {
+			t.Errorf("Wrong api version: %v != %v", act, exp)
+		}
+		fmt.Fprintf(w, `{"access_token":"token","expires_on":"%v"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	os.Setenv("IDENTITY_ENDPOINT", server.URL)
+	os.Setenv("IDENTITY_HEADER", "secret")
+	defer func() {
+		os.Unsetenv("IDENTITY_ENDPOINT")
+		os.Unsetenv("IDENTITY_HEADER")
+	}()
+
+	token, err := NewConfig().NewTokenSource("https://storage.azure.com/", time.Second).Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "token", token; exp != act {
+		t.Errorf("Wrong token: %v != %v", act, exp)
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_request"}`))
+	}))
+	defer server.Close()
+
+	ts := NewConfig().NewTokenSource("https://storage.azure.com/", time.Second)
+	ts.SetEndpoint(server.URL)
+	if _, err := ts.Token(context.Background()); err == nil {
+		t.Error("Expected error from bad status")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,275 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package servicebus provides a minimal client of the Azure Service Bus REST
+// API for sending messages to queues and topics, with shared access signature
+// or managed identity authentication.
+package servicebus
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/util/azure/identity"
+)
+
+//------------------------------------------------------------------------------
+
+// Config contains configuration fields for authenticating with an Azure
+// Service Bus namespace.
+type Config struct {
+	ConnectionString string          `json:"connection_string" yaml:"connection_string"`
+	Namespace        string          `json:"namespace" yaml:"namespace"`
+	ManagedIdentity  identity.Config `json:"managed_identity" yaml:"managed_identity"`
+}
+
+// NewConfig returns a Config with default values.
+func NewConfig() Config {
+	return Config{
+		ConnectionString: "",
+		Namespace:        "",
+		ManagedIdentity:  identity.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// tokenResource is the resource that managed identity tokens are requested for.
+const tokenResource = "https://servicebus.azure.net/"
+
+// sasLifetime is how long generated shared access signatures are valid for.
+const sasLifetime = time.Hour
+
+// Client sends messages to the queues and topics of a Service Bus namespace.
+type Client struct {
+	endpoint   *url.URL
+	entityPath string
+	keyName    string
+	key        []byte
+	tokens     *identity.TokenSource
+	http       *http.Client
+}
+
+// NewClient creates a new Client from the config, where requests time out
+// after the given duration.
+func (c Config) NewClient(timeout time.Duration) (*Client, error) {
+	client := &Client{
+		http: &http.Client{Timeout: timeout},
+	}
+
+	namespace := c.Namespace
+	if len(c.ConnectionString) > 0 {
+		for _, kv := range strings.Split(c.ConnectionString, ";") {
+			if len(strings.TrimSpace(kv)) == 0 {
+				continue
+			}
+			i := strings.Index(kv, "=")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid connection string field: %v", kv)
+			}
+			k, v := strings.TrimSpace(kv[:i]), strings.TrimSpace(kv[i+1:])
+			switch strings.ToLower(k) {
+			case "endpoint":
+				u, err := url.Parse(v)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse endpoint: %v", err)
+				}
+				if u.Scheme == "sb" {
+					u.Scheme = "https"
+				}
+				u.Path = strings.TrimSuffix(u.Path, "/")
+				client.endpoint = u
+			case "sharedaccesskeyname":
+				client.keyName = v
+			case "sharedaccesskey":
+				client.key = []byte(v)
+			case "entitypath":
+				client.entityPath = v
+			}
+		}
+	}
+	if client.endpoint == nil {
+		if len(namespace) == 0 {
+			return nil, errors.New("either a connection string or namespace must be specified")
+		}
+		if !strings.Contains(namespace, ".") {
+			namespace += ".servicebus.windows.net"
+		}
+		client.endpoint = &url.URL{Scheme: "https", Host: namespace}
+	}
+
+	if len(client.key) > 0 {
+		if len(client.keyName) == 0 {
+			return nil, errors.New("a shared access key name must be specified with the key")
+		}
+	} else if c.ManagedIdentity.Enabled {
+		client.tokens = c.ManagedIdentity.NewTokenSource(tokenResource, timeout)
+	} else {
+		return nil, errors.New("either a shared access key or managed identity must be specified")
+	}
+	return client, nil
+}
+
+// SetEndpoint overrides the endpoint of the namespace, which is useful for
+// testing.
+func (c *Client) SetEndpoint(u *url.URL) {
+	c.endpoint = u
+}
+
+// SetTokenEndpoint overrides the managed identity token endpoint, which is
+// useful for testing.
+func (c *Client) SetTokenEndpoint(endpoint string) {
+	if c.tokens != nil {
+		c.tokens.SetEndpoint(endpoint)
+	}
+}
+
+// EntityPath returns the queue or topic specified by the connection string, if
+// any.
+func (c *Client) EntityPath() string {
+	return c.entityPath
+}
+
+// sasToken generates a shared access signature for a resource URI that expires
+// at the given time.
+func (c *Client) sasToken(uri string, expiry time.Time) string {
+	encoded := url.QueryEscape(uri)
+	se := strconv.FormatInt(expiry.Unix(), 10)
+	h := hmac.New(sha256.New, c.key)
+	h.Write([]byte(encoded + "\n" + se))
+	sig := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return fmt.Sprintf(
+		"SharedAccessSignature sr=%v&sig=%v&se=%v&skn=%v",
+		encoded, url.QueryEscape(sig), se, url.QueryEscape(c.keyName),
+	)
+}
+
+//------------------------------------------------------------------------------
+
+// Message is a message to be sent to a queue or topic.
+type Message struct {
+	Body                 []byte
+	MessageID            string
+	SessionID            string
+	ContentType          string
+	ScheduledEnqueueTime time.Time
+	TimeToLive           time.Duration
+	Properties           map[string]string
+}
+
+type brokerProperties struct {
+	MessageID               string  `json:"MessageId,omitempty"`
+	SessionID               string  `json:"SessionId,omitempty"`
+	ContentType             string  `json:"ContentType,omitempty"`
+	ScheduledEnqueueTimeUtc string  `json:"ScheduledEnqueueTimeUtc,omitempty"`
+	TimeToLive              float64 `json:"TimeToLive,omitempty"`
+}
+
+type batchMessage struct {
+	Body             string            `json:"Body"`
+	BrokerProperties brokerProperties  `json:"BrokerProperties"`
+	UserProperties   map[string]string `json:"UserProperties,omitempty"`
+}
+
+// Send sends a batch of messages to a queue or topic with a single request.
+func (c *Client) Send(ctx context.Context, entity string, msgs []Message) error {
+	batch := make([]batchMessage, len(msgs))
+	for i, m := range msgs {
+		batch[i] = batchMessage{
+			Body: string(m.Body),
+			BrokerProperties: brokerProperties{
+				MessageID:   m.MessageID,
+				SessionID:   m.SessionID,
+				ContentType: m.ContentType,
+				TimeToLive:  m.TimeToLive.Seconds(),
+			},
+			UserProperties: m.Properties,
+		}
+		if !m.ScheduledEnqueueTime.IsZero() {
+			batch[i].BrokerProperties.ScheduledEnqueueTimeUtc = m.ScheduledEnqueueTime.UTC().Format(http.TimeFormat)
+		}
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(entity, "/")
+	resource := u.String()
+	u.Path += "/messages"
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/vnd.microsoft.servicebus.json")
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Set("Authorization", c.sasToken(resource, time.Now().Add(sasLifetime)))
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return readError(res)
+	}
+	return nil
+}
+
+func readError(res *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<16))
+	var details struct {
+		Detail string `xml:"Detail"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if xml.Unmarshal(body, &details) == nil && len(details.Detail) > 0 {
+		msg = details.Detail
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("service bus returned status %v: %v", res.StatusCode, msg)
+	}
+	return fmt.Errorf("service bus returned status %v", res.StatusCode)
+}
+
+//------------------------------------------------------------------------------
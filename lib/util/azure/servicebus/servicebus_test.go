@@ -0,0 +1,213 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package servicebus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+func TestConfigConnectionString(t *testing.T) {
+	conf := NewConfig()
+	conf.ConnectionString = "Endpoint=sb://foo.servicebus.windows.net/;SharedAccessKeyName=RootManageSharedAccessKey;SharedAccessKey=bar;EntityPath=baz"
+
+	c, err := conf.NewClient(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "https://foo.servicebus.windows.net", c.endpoint.String(); exp != act {
+		t.Errorf("Wrong endpoint: %v != %v", act, exp)
+	}
+	if exp, act := "RootManageSharedAccessKey", c.keyName; exp != act {
+		t.Errorf("Wrong key name: %v != %v", act, exp)
+	}
+	if exp, act := "bar", string(c.key); exp != act {
+		t.Errorf("Wrong key: %v != %v", act, exp)
+	}
+	if exp, act := "baz", c.EntityPath(); exp != act {
+		t.Errorf("Wrong entity path: %v != %v", act, exp)
+	}
+
+	conf = NewConfig()
+	conf.Namespace = "foo"
+	conf.ManagedIdentity.Enabled = true
+	if c, err = conf.NewClient(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "https://foo.servicebus.windows.net", c.endpoint.String(); exp != act {
+		t.Errorf("Wrong endpoint: %v != %v", act, exp)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, conf := range []Config{
+		{},
+		{Namespace: "foo"},
+		{ConnectionString: "Endpoint"},
+		{ConnectionString: "Endpoint=sb://foo.servicebus.windows.net/;SharedAccessKey=bar"},
+	} {
+		if _, err := conf.NewClient(time.Second); err == nil {
+			t.Errorf("Expected error from config: %+v", conf)
+		}
+	}
+}
+
+func TestSASToken(t *testing.T) {
+	c := &Client{keyName: "foo", key: []byte("bar")}
+	token := c.sasToken("https://ns.servicebus.windows.net/queue", time.Unix(1600000000, 0))
+
+	exp := "SharedAccessSignature sr=https%3A%2F%2Fns.servicebus.windows.net%2Fqueue&sig=M4YLzMdetwXVCP38GIJQY20b3nvMXU%2Bw6XEuma2qGl4%3D&se=1600000000&skn=foo"
+	if token != exp {
+		t.Errorf("Wrong token: %v != %v", token, exp)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var c *Client
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "/foo/messages", r.URL.Path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+		if exp, act := "application/vnd.microsoft.servicebus.json", r.Header.Get("Content-Type"); exp != act {
+			t.Errorf("Wrong content type: %v != %v", act, exp)
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "SharedAccessSignature sr="+url.QueryEscape(c.endpoint.String()+"/foo")+"&") {
+			t.Errorf("Wrong authorization: %v", auth)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	var err error
+	conf := NewConfig()
+	conf.ConnectionString = "Endpoint=sb://foo.servicebus.windows.net/;SharedAccessKeyName=key;SharedAccessKey=bar"
+	if c, err = conf.NewClient(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	c.SetEndpoint(u)
+
+	err = c.Send(context.Background(), "foo", []Message{
+		{
+			Body:                 []byte("hello"),
+			MessageID:            "id1",
+			SessionID:            "session1",
+			ScheduledEnqueueTime: time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+			TimeToLive:           time.Minute,
+			Properties:           map[string]string{"a": "b"},
+		},
+		{Body: []byte("world")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []map[string]interface{}{
+		{
+			"Body": "hello",
+			"BrokerProperties": map[string]interface{}{
+				"MessageId":               "id1",
+				"SessionId":               "session1",
+				"ScheduledEnqueueTimeUtc": "Mon, 06 Jan 2020 00:00:00 GMT",
+				"TimeToLive":              float64(60),
+			},
+			"UserProperties": map[string]interface{}{"a": "b"},
+		},
+		{
+			"Body":             "world",
+			"BrokerProperties": map[string]interface{}{},
+		},
+	}
+	if !reflect.DeepEqual(exp, received) {
+		t.Errorf("Wrong messages: %v != %v", received, exp)
+	}
+}
+
+func TestSendManagedIdentity(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := tokenResource, r.URL.Query().Get("resource"); exp != act {
+			t.Errorf("Wrong resource: %v != %v", act, exp)
+		}
+		fmt.Fprintf(w, `{"access_token":"token123","expires_on":"%v"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer tokenServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "Bearer token123", r.Header.Get("Authorization"); exp != act {
+			t.Errorf("Wrong authorization: %v != %v", act, exp)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.Namespace = "foo"
+	conf.ManagedIdentity.Enabled = true
+	c, err := conf.NewClient(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	c.SetEndpoint(u)
+	c.SetTokenEndpoint(tokenServer.URL)
+
+	if err = c.Send(context.Background(), "foo", []Message{{Body: []byte("hello")}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`<Error><Code>401</Code><Detail>InvalidSignature: The token has an invalid signature.</Detail></Error>`))
+	}))
+	defer server.Close()
+
+	c := &Client{keyName: "foo", key: []byte("bar"), http: http.DefaultClient}
+	c.SetEndpoint(&url.URL{Scheme: "http", Host: strings.TrimPrefix(server.URL, "http://")})
+
+	err := c.Send(context.Background(), "foo", []Message{{Body: []byte("hello")}})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if exp, act := "service bus returned status 401: InvalidSignature: The token has an invalid signature.", err.Error(); exp != act {
+		t.Errorf("Wrong error: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,195 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// BlobProperties contains the properties of a blob.
+type BlobProperties struct {
+	LastModified    time.Time
+	ContentLength   int64
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+}
+
+// BlobItem is a blob listed within a container.
+type BlobItem struct {
+	Name       string
+	Properties BlobProperties
+}
+
+type blobListResult struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified    string `xml:"Last-Modified"`
+			ContentLength   int64  `xml:"Content-Length"`
+			ContentType     string `xml:"Content-Type"`
+			ContentEncoding string `xml:"Content-Encoding"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// ListBlobs lists a page of the blobs of a container that begin with a
+// prefix, starting at a marker returned by a previous page. The marker of the
+// next page is returned, which is empty for the last page.
+func (c *Client) ListBlobs(ctx context.Context, container, prefix, marker string) ([]BlobItem, string, error) {
+	query := url.Values{
+		"restype": []string{"container"},
+		"comp":    []string{"list"},
+	}
+	if len(prefix) > 0 {
+		query.Set("prefix", prefix)
+	}
+	if len(marker) > 0 {
+		query.Set("marker", marker)
+	}
+
+	res, err := c.Do(ctx, ServiceBlob, http.MethodGet, container, query, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	var result blobListResult
+	if err = xml.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, "", err
+	}
+	items := make([]BlobItem, 0, len(result.Blobs))
+	for _, b := range result.Blobs {
+		lastModified, _ := time.Parse(http.TimeFormat, b.Properties.LastModified)
+		items = append(items, BlobItem{
+			Name: b.Name,
+			Properties: BlobProperties{
+				LastModified:    lastModified,
+				ContentLength:   b.Properties.ContentLength,
+				ContentType:     b.Properties.ContentType,
+				ContentEncoding: b.Properties.ContentEncoding,
+			},
+		})
+	}
+	return items, result.NextMarker, nil
+}
+
+// GetBlob downloads a blob, returning its properties and a reader of its
+// contents that must be closed by the caller.
+func (c *Client) GetBlob(ctx context.Context, container, name string) (BlobProperties, io.ReadCloser, error) {
+	res, err := c.Do(ctx, ServiceBlob, http.MethodGet, container+"/"+name, nil, nil, nil)
+	if err != nil {
+		return BlobProperties{}, nil, err
+	}
+
+	props := BlobProperties{
+		ContentType:     res.Header.Get("Content-Type"),
+		ContentEncoding: res.Header.Get("Content-Encoding"),
+		Metadata:        map[string]string{},
+	}
+	props.LastModified, _ = time.Parse(http.TimeFormat, res.Header.Get("Last-Modified"))
+	props.ContentLength, _ = strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	for k, v := range res.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-ms-meta-") && len(v) > 0 {
+			props.Metadata[strings.TrimPrefix(k, "x-ms-meta-")] = v[0]
+		}
+	}
+	return props, res.Body, nil
+}
+
+// maxAppendBlockSize is the largest block that can be appended to an append
+// blob in a single request.
+const maxAppendBlockSize = 4 * 1024 * 1024
+
+// blobHeader returns the headers that set the properties of a blob when it is
+// created.
+func blobHeader(blobType string, props BlobProperties) http.Header {
+	header := http.Header{}
+	header.Set("x-ms-blob-type", blobType)
+	if len(props.ContentType) > 0 {
+		header.Set("x-ms-blob-content-type", props.ContentType)
+	}
+	if len(props.ContentEncoding) > 0 {
+		header.Set("x-ms-blob-content-encoding", props.ContentEncoding)
+	}
+	for k, v := range props.Metadata {
+		header.Set("x-ms-meta-"+k, v)
+	}
+	return header
+}
+
+// PutBlockBlob creates a block blob with the given contents and properties,
+// replacing any existing blob of the same name.
+func (c *Client) PutBlockBlob(ctx context.Context, container, name string, props BlobProperties, data []byte) error {
+	res, err := c.Do(ctx, ServiceBlob, http.MethodPut, container+"/"+name, nil, blobHeader("BlockBlob", props), data)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// CreateAppendBlob creates an empty append blob with the given properties when
+// a blob of the same name does not already exist.
+func (c *Client) CreateAppendBlob(ctx context.Context, container, name string, props BlobProperties) error {
+	header := blobHeader("AppendBlob", props)
+	header.Set("If-None-Match", "*")
+	res, err := c.Do(ctx, ServiceBlob, http.MethodPut, container+"/"+name, nil, header, nil)
+	if err != nil {
+		if serr, ok := err.(*Error); ok && serr.StatusCode == http.StatusConflict {
+			return nil
+		}
+		return err
+	}
+	return res.Body.Close()
+}
+
+// AppendBlob appends data to an existing append blob, split into blocks of the
+// maximum size accepted by the service.
+func (c *Client) AppendBlob(ctx context.Context, container, name string, data []byte) error {
+	query := url.Values{
+		"comp": []string{"appendblock"},
+	}
+	for len(data) > 0 {
+		block := data
+		if len(block) > maxAppendBlockSize {
+			block = block[:maxAppendBlockSize]
+		}
+		res, err := c.Do(ctx, ServiceBlob, http.MethodPut, container+"/"+name, query, nil, block)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		data = data[len(block):]
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,246 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+func TestListBlobs(t *testing.T) {
+	var c *Client
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !testVerify(t, c, w, r) {
+			return
+		}
+		if exp, act := "/foo", r.URL.Path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+		if exp, act := "bar/", r.URL.Query().Get("prefix"); exp != act {
+			t.Errorf("Wrong prefix: %v != %v", act, exp)
+		}
+		if r.URL.Query().Get("marker") == "" {
+			w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults ServiceEndpoint="http://foo.blob.core.windows.net/" ContainerName="foo">
+  <Prefix>bar/</Prefix>
+  <Blobs>
+    <Blob>
+      <Name>bar/a.txt</Name>
+      <Properties>
+        <Last-Modified>Mon, 06 Jan 2020 00:00:00 GMT</Last-Modified>
+        <Content-Length>3</Content-Length>
+        <Content-Type>text/plain</Content-Type>
+      </Properties>
+    </Blob>
+  </Blobs>
+  <NextMarker>page2</NextMarker>
+</EnumerationResults>`))
+			return
+		}
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<EnumerationResults ContainerName="foo">
+  <Blobs><Blob><Name>bar/b.txt</Name><Properties /></Blob></Blobs>
+  <NextMarker />
+</EnumerationResults>`))
+	}))
+	defer server.Close()
+
+	c = testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, server)
+
+	items, marker, err := c.ListBlobs(context.Background(), "foo", "bar/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []BlobItem{{
+		Name: "bar/a.txt",
+		Properties: BlobProperties{
+			LastModified:  time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+			ContentLength: 3,
+			ContentType:   "text/plain",
+		},
+	}}
+	if !reflect.DeepEqual(exp, items) {
+		t.Errorf("Wrong items: %+v != %+v", items, exp)
+	}
+	if exp, act := "page2", marker; exp != act {
+		t.Errorf("Wrong marker: %v != %v", act, exp)
+	}
+
+	if items, marker, err = c.ListBlobs(context.Background(), "foo", "bar/", marker); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(items); exp != act {
+		t.Fatalf("Wrong count of items: %v != %v", act, exp)
+	}
+	if exp, act := "bar/b.txt", items[0].Name; exp != act {
+		t.Errorf("Wrong name: %v != %v", act, exp)
+	}
+	if marker != "" {
+		t.Errorf("Unexpected marker: %v", marker)
+	}
+}
+
+func TestGetBlob(t *testing.T) {
+	var c *Client
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !testVerify(t, c, w, r) {
+			return
+		}
+		if exp, act := "/foo/bar/a b.txt", r.URL.Path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Last-Modified", "Mon, 06 Jan 2020 00:00:00 GMT")
+		w.Header().Set("x-ms-meta-Owner", "baz")
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	c = testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, server)
+
+	props, body, err := c.GetBlob(context.Background(), "foo", "bar/a b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "hello", string(data); exp != act {
+		t.Errorf("Wrong contents: %v != %v", act, exp)
+	}
+	exp := BlobProperties{
+		LastModified:  time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+		ContentLength: 5,
+		ContentType:   "text/plain",
+		Metadata:      map[string]string{"owner": "baz"},
+	}
+	if !reflect.DeepEqual(exp, props) {
+		t.Errorf("Wrong properties: %+v != %+v", props, exp)
+	}
+}
+
+func TestPutBlockBlob(t *testing.T) {
+	var c *Client
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !testVerify(t, c, w, r) {
+			return
+		}
+		if exp, act := http.MethodPut, r.Method; exp != act {
+			t.Errorf("Wrong method: %v != %v", act, exp)
+		}
+		if exp, act := "/foo/bar/a.txt", r.URL.Path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+		for k, v := range map[string]string{
+			"x-ms-blob-type":             "BlockBlob",
+			"x-ms-blob-content-type":     "text/plain",
+			"x-ms-blob-content-encoding": "gzip",
+			"x-ms-meta-owner":            "baz",
+		} {
+			if act := r.Header.Get(k); v != act {
+				t.Errorf("Wrong %v header: %v != %v", k, act, v)
+			}
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		if exp, act := "hello", string(data); exp != act {
+			t.Errorf("Wrong contents: %v != %v", act, exp)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	c = testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, server)
+
+	if err := c.PutBlockBlob(context.Background(), "foo", "bar/a.txt", BlobProperties{
+		ContentType:     "text/plain",
+		ContentEncoding: "gzip",
+		Metadata:        map[string]string{"owner": "baz"},
+	}, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendBlob(t *testing.T) {
+	var c *Client
+	var blob []byte
+	var appends int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !testVerify(t, c, w, r) {
+			return
+		}
+		if r.URL.Query().Get("comp") == "appendblock" {
+			data, _ := ioutil.ReadAll(r.Body)
+			if len(data) > maxAppendBlockSize {
+				t.Errorf("Block exceeds maximum size: %v", len(data))
+			}
+			blob = append(blob, data...)
+			appends++
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if exp, act := "AppendBlob", r.Header.Get("x-ms-blob-type"); exp != act {
+			t.Errorf("Wrong blob type: %v != %v", act, exp)
+		}
+		if exp, act := "*", r.Header.Get("If-None-Match"); exp != act {
+			t.Errorf("Wrong If-None-Match header: %v != %v", act, exp)
+		}
+		if blob != nil {
+			w.Header().Set("x-ms-error-code", "BlobAlreadyExists")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		blob = []byte{}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	c = testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, server)
+
+	for i := 0; i < 2; i++ {
+		if err := c.CreateAppendBlob(context.Background(), "foo", "log.txt", BlobProperties{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := []byte(strings.Repeat("a", maxAppendBlockSize+10))
+	if err := c.AppendBlob(context.Background(), "foo", "log.txt", data); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, appends; exp != act {
+		t.Errorf("Wrong count of appends: %v != %v", act, exp)
+	}
+	if exp, act := string(data), string(blob); exp != act {
+		t.Errorf("Wrong blob size: %v != %v", len(act), len(exp))
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+//------------------------------------------------------------------------------
+
+// QueueMessage is a message received from a queue.
+type QueueMessage struct {
+	ID           string `xml:"MessageId"`
+	PopReceipt   string `xml:"PopReceipt"`
+	DequeueCount int64  `xml:"DequeueCount"`
+	Text         string `xml:"MessageText"`
+}
+
+// GetMessages receives up to max messages from a queue, which remain invisible
+// to other consumers for the visibility timeout in seconds.
+func (c *Client) GetMessages(ctx context.Context, queue string, max, visibilityTimeout int) ([]QueueMessage, error) {
+	query := url.Values{
+		"numofmessages":     []string{strconv.Itoa(max)},
+		"visibilitytimeout": []string{strconv.Itoa(visibilityTimeout)},
+	}
+	res, err := c.Do(ctx, ServiceQueue, http.MethodGet, queue+"/messages", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Messages []QueueMessage `xml:"QueueMessage"`
+	}
+	if err = xml.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Messages, nil
+}
+
+// DeleteMessage deletes a message received from a queue.
+func (c *Client) DeleteMessage(ctx context.Context, queue string, msg QueueMessage) error {
+	query := url.Values{
+		"popreceipt": []string{msg.PopReceipt},
+	}
+	res, err := c.Do(ctx, ServiceQueue, http.MethodDelete, queue+"/messages/"+msg.ID, query, nil, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// PutMessage adds a message to a queue, which only becomes visible to consumers
+// after the visibility timeout and expires after the time to live, both in
+// seconds. A time to live of zero uses the default of the service, and a
+// negative time to live means the message never expires.
+func (c *Client) PutMessage(ctx context.Context, queue, text string, visibilityTimeout, ttl int) error {
+	query := url.Values{}
+	if visibilityTimeout > 0 {
+		query.Set("visibilitytimeout", strconv.Itoa(visibilityTimeout))
+	}
+	if ttl != 0 {
+		if ttl < 0 {
+			ttl = -1
+		}
+		query.Set("messagettl", strconv.Itoa(ttl))
+	}
+
+	var body bytes.Buffer
+	body.WriteString("<QueueMessage><MessageText>")
+	if err := xml.EscapeText(&body, []byte(text)); err != nil {
+		return err
+	}
+	body.WriteString("</MessageText></QueueMessage>")
+
+	header := http.Header{
+		"Content-Type": []string{"application/xml"},
+	}
+	res, err := c.Do(ctx, ServiceQueue, http.MethodPost, queue+"/messages", query, header, body.Bytes())
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+//------------------------------------------------------------------------------
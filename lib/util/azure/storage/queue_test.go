@@ -0,0 +1,134 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func TestQueueMessages(t *testing.T) {
+	var c *Client
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !testVerify(t, c, w, r) {
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if exp, act := "/foo/messages", r.URL.Path; exp != act {
+				t.Errorf("Wrong path: %v != %v", act, exp)
+			}
+			if exp, act := "5", r.URL.Query().Get("numofmessages"); exp != act {
+				t.Errorf("Wrong count: %v != %v", act, exp)
+			}
+			if exp, act := "30", r.URL.Query().Get("visibilitytimeout"); exp != act {
+				t.Errorf("Wrong visibility timeout: %v != %v", act, exp)
+			}
+			w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<QueueMessagesList>
+  <QueueMessage>
+    <MessageId>id1</MessageId>
+    <InsertionTime>Mon, 06 Jan 2020 00:00:00 GMT</InsertionTime>
+    <PopReceipt>receipt1</PopReceipt>
+    <DequeueCount>2</DequeueCount>
+    <MessageText>aGVsbG8=</MessageText>
+  </QueueMessage>
+</QueueMessagesList>`))
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path+"?"+r.URL.Query().Get("popreceipt"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c = testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, server)
+
+	msgs, err := c.GetMessages(context.Background(), "foo", 5, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []QueueMessage{{
+		ID:           "id1",
+		PopReceipt:   "receipt1",
+		DequeueCount: 2,
+		Text:         "aGVsbG8=",
+	}}
+	if !reflect.DeepEqual(exp, msgs) {
+		t.Errorf("Wrong messages: %+v != %+v", msgs, exp)
+	}
+
+	if err = c.DeleteMessage(context.Background(), "foo", msgs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []string{"/foo/messages/id1?receipt1"}, deleted; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong deleted messages: %v != %v", act, exp)
+	}
+}
+
+func TestQueuePutMessage(t *testing.T) {
+	var c *Client
+	var bodies, queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !testVerify(t, c, w, r) {
+			return
+		}
+		if exp, act := http.MethodPost, r.Method; exp != act {
+			t.Errorf("Wrong method: %v != %v", act, exp)
+		}
+		if exp, act := "/foo/messages", r.URL.Path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		queries = append(queries, r.URL.RawQuery)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	c = testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, server)
+
+	if err := c.PutMessage(context.Background(), "foo", "a<b>&c", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutMessage(context.Background(), "foo", "hello", 30, -5); err != nil {
+		t.Fatal(err)
+	}
+
+	expBodies := []string{
+		"<QueueMessage><MessageText>a&lt;b&gt;&amp;c</MessageText></QueueMessage>",
+		"<QueueMessage><MessageText>hello</MessageText></QueueMessage>",
+	}
+	if !reflect.DeepEqual(expBodies, bodies) {
+		t.Errorf("Wrong bodies: %v != %v", bodies, expBodies)
+	}
+	if exp := []string{"", "messagettl=-1&visibilitytimeout=30"}; !reflect.DeepEqual(exp, queries) {
+		t.Errorf("Wrong queries: %v != %v", queries, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
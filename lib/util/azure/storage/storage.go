@@ -0,0 +1,357 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package storage provides a minimal client of the Azure Storage REST API,
+// supporting the blob and queue services with shared key, shared access
+// signature or managed identity authentication.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/util/azure/identity"
+)
+
+//------------------------------------------------------------------------------
+
+// Config contains configuration fields for authenticating with an Azure
+// storage account. This config is common across any Azure storage components.
+type Config struct {
+	StorageAccount          string          `json:"storage_account" yaml:"storage_account"`
+	StorageAccessKey        string          `json:"storage_access_key" yaml:"storage_access_key"`
+	StorageSASToken         string          `json:"storage_sas_token" yaml:"storage_sas_token"`
+	StorageConnectionString string          `json:"storage_connection_string" yaml:"storage_connection_string"`
+	ManagedIdentity         identity.Config `json:"managed_identity" yaml:"managed_identity"`
+}
+
+// NewConfig returns a Config with default values.
+func NewConfig() Config {
+	return Config{
+		StorageAccount:          "",
+		StorageAccessKey:        "",
+		StorageSASToken:         "",
+		StorageConnectionString: "",
+		ManagedIdentity:         identity.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Service is a service of a storage account.
+type Service string
+
+// Storage account services.
+const (
+	ServiceBlob  Service = "blob"
+	ServiceQueue Service = "queue"
+)
+
+// apiVersion is the version of the REST API that requests are made with.
+const apiVersion = "2019-12-12"
+
+// tokenResource is the resource that managed identity tokens are requested for.
+const tokenResource = "https://storage.azure.com/"
+
+// devAccount and devKey are the well known credentials of the storage emulator.
+const (
+	devAccount = "devstoreaccount1"
+	devKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// Client makes authenticated requests to the services of a storage account.
+type Client struct {
+	account   string
+	key       []byte
+	sas       url.Values
+	tokens    *identity.TokenSource
+	endpoints map[Service]*url.URL
+	http      *http.Client
+}
+
+// NewClient creates a new Client from the config, where requests time out
+// after the given duration.
+func (c Config) NewClient(timeout time.Duration) (*Client, error) {
+	client := &Client{
+		account:   c.StorageAccount,
+		endpoints: map[Service]*url.URL{},
+		http:      &http.Client{Timeout: timeout},
+	}
+	accessKey, sasToken := c.StorageAccessKey, c.StorageSASToken
+
+	protocol, suffix := "https", "core.windows.net"
+	if len(c.StorageConnectionString) > 0 {
+		for _, kv := range strings.Split(c.StorageConnectionString, ";") {
+			if len(strings.TrimSpace(kv)) == 0 {
+				continue
+			}
+			i := strings.Index(kv, "=")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid connection string field: %v", kv)
+			}
+			k, v := strings.TrimSpace(kv[:i]), strings.TrimSpace(kv[i+1:])
+			switch strings.ToLower(k) {
+			case "accountname":
+				client.account = v
+			case "accountkey":
+				accessKey = v
+			case "sharedaccesssignature":
+				sasToken = v
+			case "defaultendpointsprotocol":
+				protocol = v
+			case "endpointsuffix":
+				suffix = v
+			case "blobendpoint", "queueendpoint":
+				u, err := url.Parse(v)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse %v: %v", k, err)
+				}
+				client.endpoints[Service(strings.TrimSuffix(strings.ToLower(k), "endpoint"))] = u
+			case "usedevelopmentstorage":
+				if strings.EqualFold(v, "true") {
+					client.account, accessKey = devAccount, devKey
+					client.endpoints[ServiceBlob], _ = url.Parse("http://127.0.0.1:10000/" + devAccount)
+					client.endpoints[ServiceQueue], _ = url.Parse("http://127.0.0.1:10001/" + devAccount)
+				}
+			}
+		}
+	}
+	if len(client.account) == 0 {
+		return nil, errors.New("a storage account must be specified")
+	}
+	for _, s := range []Service{ServiceBlob, ServiceQueue} {
+		if _, exists := client.endpoints[s]; !exists {
+			client.endpoints[s] = &url.URL{
+				Scheme: protocol,
+				Host:   client.account + "." + string(s) + "." + suffix,
+			}
+		}
+	}
+
+	if len(accessKey) > 0 {
+		var err error
+		if client.key, err = base64.StdEncoding.DecodeString(accessKey); err != nil {
+			return nil, fmt.Errorf("failed to decode storage access key: %v", err)
+		}
+	} else if len(sasToken) > 0 {
+		var err error
+		if client.sas, err = url.ParseQuery(strings.TrimPrefix(sasToken, "?")); err != nil {
+			return nil, fmt.Errorf("failed to parse storage SAS token: %v", err)
+		}
+	} else if c.ManagedIdentity.Enabled {
+		client.tokens = c.ManagedIdentity.NewTokenSource(tokenResource, timeout)
+	} else {
+		return nil, errors.New("either a storage access key, SAS token or managed identity must be specified")
+	}
+	return client, nil
+}
+
+// SetEndpoint overrides the endpoint of a service, which is useful for testing.
+func (c *Client) SetEndpoint(s Service, u *url.URL) {
+	c.endpoints[s] = u
+}
+
+// SetTokenEndpoint overrides the managed identity token endpoint, which is
+// useful for testing.
+func (c *Client) SetTokenEndpoint(endpoint string) {
+	if c.tokens != nil {
+		c.tokens.SetEndpoint(endpoint)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Error is returned when the storage service responds with an unsuccessful
+// status code.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if len(e.Message) > 0 {
+		return fmt.Sprintf("%v (%v): %v", e.Code, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%v (%v)", e.Code, e.StatusCode)
+}
+
+// IsNotFound returns true if an error is the result of a resource not being
+// found.
+func IsNotFound(err error) bool {
+	serr, ok := err.(*Error)
+	return ok && serr.StatusCode == http.StatusNotFound
+}
+
+func readError(res *http.Response) error {
+	serr := &Error{
+		StatusCode: res.StatusCode,
+		Code:       res.Header.Get("x-ms-error-code"),
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1<<16))
+	var details struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &details) == nil {
+		if len(serr.Code) == 0 {
+			serr.Code = details.Code
+		}
+		serr.Message = strings.SplitN(details.Message, "\n", 2)[0]
+	}
+	if len(serr.Code) == 0 {
+		serr.Code = http.StatusText(res.StatusCode)
+	}
+	return serr
+}
+
+//------------------------------------------------------------------------------
+
+// Do makes a request to a service, where the path is relative to the endpoint
+// of the service and is escaped by the client. The body of the response must be
+// closed by the caller, and an *Error is returned for unsuccessful responses.
+func (c *Client) Do(
+	ctx context.Context,
+	service Service,
+	method, path string,
+	query url.Values,
+	header http.Header,
+	body []byte,
+) (*http.Response, error) {
+	endpoint, exists := c.endpoints[service]
+	if !exists {
+		return nil, fmt.Errorf("unrecognised service: %v", service)
+	}
+
+	u := *endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+	u.RawPath = ""
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.sas != nil {
+		for k, v := range c.sas {
+			query[k] = v
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", apiVersion)
+	if c.key != nil {
+		req.Header.Set("Authorization", "SharedKey "+c.account+":"+c.sign(req))
+	} else if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err = readError(res)
+		res.Body.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// stringToSign returns the string that is signed for shared key authorization
+// of a request.
+func (c *Client) stringToSign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	lines := []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"",
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}
+
+	var msHeaders []string
+	for k := range req.Header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			msHeaders = append(msHeaders, k)
+		}
+	}
+	sort.Strings(msHeaders)
+	for _, k := range msHeaders {
+		lines = append(lines, k+":"+strings.TrimSpace(req.Header.Get(k)))
+	}
+
+	resource := "/" + c.account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(k) + ":" + strings.Join(values, ",")
+	}
+	return strings.Join(append(lines, resource), "\n")
+}
+
+func (c *Client) sign(req *http.Request) string {
+	h := hmac.New(sha256.New, c.key)
+	h.Write([]byte(c.stringToSign(req)))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,201 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// testKey is a base64 encoded access key used for tests.
+const testKey = "Zm9vYmFy"
+
+func testClient(t *testing.T, conf Config, server *httptest.Server) *Client {
+	t.Helper()
+	c, err := conf.NewClient(time.Second * 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server != nil {
+		u, _ := url.Parse(server.URL)
+		c.SetEndpoint(ServiceBlob, u)
+		c.SetEndpoint(ServiceQueue, u)
+	}
+	return c
+}
+
+// testVerify returns an error response if a request is not correctly signed.
+func testVerify(t *testing.T, c *Client, w http.ResponseWriter, r *http.Request) bool {
+	t.Helper()
+	if exp, act := "SharedKey "+c.account+":"+c.sign(r), r.Header.Get("Authorization"); exp != act {
+		t.Errorf("Wrong authorization: %v != %v", act, exp)
+		w.Header().Set("x-ms-error-code", "AuthenticationFailed")
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func TestConfigConnectionString(t *testing.T) {
+	conf := NewConfig()
+	conf.StorageConnectionString = "DefaultEndpointsProtocol=http;AccountName=foo;AccountKey=" + testKey + ";EndpointSuffix=example.com;QueueEndpoint=http://localhost:1234/foo"
+
+	c := testClient(t, conf, nil)
+	if exp, act := "foo", c.account; exp != act {
+		t.Errorf("Wrong account: %v != %v", act, exp)
+	}
+	if exp, act := "foobar", string(c.key); exp != act {
+		t.Errorf("Wrong key: %v != %v", act, exp)
+	}
+	if exp, act := "http://foo.blob.example.com", c.endpoints[ServiceBlob].String(); exp != act {
+		t.Errorf("Wrong blob endpoint: %v != %v", act, exp)
+	}
+	if exp, act := "http://localhost:1234/foo", c.endpoints[ServiceQueue].String(); exp != act {
+		t.Errorf("Wrong queue endpoint: %v != %v", act, exp)
+	}
+
+	conf.StorageConnectionString = "UseDevelopmentStorage=true"
+	c = testClient(t, conf, nil)
+	if exp, act := devAccount, c.account; exp != act {
+		t.Errorf("Wrong account: %v != %v", act, exp)
+	}
+	if exp, act := "http://127.0.0.1:10000/devstoreaccount1", c.endpoints[ServiceBlob].String(); exp != act {
+		t.Errorf("Wrong blob endpoint: %v != %v", act, exp)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, conf := range []Config{
+		{},
+		{StorageAccount: "foo"},
+		{StorageAccount: "foo", StorageAccessKey: "not base64!"},
+		{StorageConnectionString: "AccountName"},
+	} {
+		if _, err := conf.NewClient(time.Second); err == nil {
+			t.Errorf("Expected error from config: %+v", conf)
+		}
+	}
+}
+
+func TestStringToSign(t *testing.T) {
+	c := testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, nil)
+
+	req, err := http.NewRequest("PUT", "https://foo.blob.core.windows.net/bar/baz%20buz.txt?comp=block&blockid=b&blockid=a", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("x-ms-date", "Mon, 06 Jan 2020 00:00:00 GMT")
+	req.Header.Set("x-ms-version", apiVersion)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+
+	exp := strings.Join([]string{
+		"PUT", "", "", "5", "", "text/plain", "", "", "", "", "", "",
+		"x-ms-blob-type:BlockBlob",
+		"x-ms-date:Mon, 06 Jan 2020 00:00:00 GMT",
+		"x-ms-version:" + apiVersion,
+		"/foo/bar/baz%20buz.txt",
+		"blockid:a,b",
+		"comp:block",
+	}, "\n")
+	if act := c.stringToSign(req); exp != act {
+		t.Errorf("Wrong string to sign:\n%v\n!=\n%v", act, exp)
+	}
+}
+
+func TestClientSASToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Authorization")) > 0 {
+			t.Error("Unexpected authorization header")
+		}
+		if exp, act := "sig123", r.URL.Query().Get("sig"); exp != act {
+			t.Errorf("Wrong signature: %v != %v", act, exp)
+		}
+		if exp, act := "/foo/bar", r.URL.Path; exp != act {
+			t.Errorf("Wrong path: %v != %v", act, exp)
+		}
+	}))
+	defer server.Close()
+
+	c := testClient(t, Config{StorageAccount: "foo", StorageSASToken: "?sv=2019-12-12&sig=sig123"}, server)
+	res, err := c.Do(context.Background(), ServiceBlob, "GET", "foo/bar", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Error><Code>BlobNotFound</Code><Message>The specified blob does not exist.
+RequestId:abc</Message></Error>`))
+	}))
+	defer server.Close()
+
+	c := testClient(t, Config{StorageAccount: "foo", StorageAccessKey: testKey}, server)
+	_, err := c.Do(context.Background(), ServiceBlob, "GET", "foo/bar", nil, nil, nil)
+	if !IsNotFound(err) {
+		t.Fatalf("Expected not found error, got: %v", err)
+	}
+	if exp, act := "BlobNotFound (404): The specified blob does not exist.", err.Error(); exp != act {
+		t.Errorf("Wrong error: %v != %v", act, exp)
+	}
+}
+
+func TestClientManagedIdentity(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := tokenResource, r.URL.Query().Get("resource"); exp != act {
+			t.Errorf("Wrong resource: %v != %v", act, exp)
+		}
+		fmt.Fprintf(w, `{"access_token":"token123","expires_on":"%v"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer tokenServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "Bearer token123", r.Header.Get("Authorization"); exp != act {
+			t.Errorf("Wrong authorization: %v != %v", act, exp)
+		}
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.StorageAccount = "foo"
+	conf.ManagedIdentity.Enabled = true
+
+	c := testClient(t, conf, server)
+	c.SetTokenEndpoint(tokenServer.URL)
+	res, err := c.Do(context.Background(), ServiceBlob, "GET", "foo/bar", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,291 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	second, minute, hour, dom, month, dow uint64
+
+	// When both the day of month and day of week fields are restricted a day
+	// matches when either of them does.
+	domOrDow bool
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	secondBounds = bounds{min: 0, max: 59}
+	minuteBounds = bounds{min: 0, max: 59}
+	hourBounds   = bounds{min: 0, max: 23}
+	domBounds    = bounds{min: 1, max: 31}
+	monthBounds  = bounds{min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Day of week allows seven as an alias of Sunday.
+	dowBounds = bounds{min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse a cron expression into a Schedule.
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if d, exists := descriptors[strings.ToLower(expr)]; exists {
+		expr = d
+	} else if strings.HasPrefix(expr, "@") {
+		return nil, fmt.Errorf("unrecognised descriptor: %v", expr)
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("expected five or six fields, found %v", len(fields))
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.second, err = parseField(fields[0], secondBounds); err != nil {
+		return nil, fmt.Errorf("failed to parse seconds field: %v", err)
+	}
+	if s.minute, err = parseField(fields[1], minuteBounds); err != nil {
+		return nil, fmt.Errorf("failed to parse minutes field: %v", err)
+	}
+	if s.hour, err = parseField(fields[2], hourBounds); err != nil {
+		return nil, fmt.Errorf("failed to parse hours field: %v", err)
+	}
+	if s.dom, err = parseField(fields[3], domBounds); err != nil {
+		return nil, fmt.Errorf("failed to parse day of month field: %v", err)
+	}
+	if s.month, err = parseField(fields[4], monthBounds); err != nil {
+		return nil, fmt.Errorf("failed to parse month field: %v", err)
+	}
+	if s.dow, err = parseField(fields[5], dowBounds); err != nil {
+		return nil, fmt.Errorf("failed to parse day of week field: %v", err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = (s.dow | 1) &^ (1 << 7)
+	}
+	s.domOrDow = !isWildcard(fields[3]) && !isWildcard(fields[5])
+	return s, nil
+}
+
+func isWildcard(field string) bool {
+	return strings.HasPrefix(field, "*") || strings.HasPrefix(field, "?")
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		rangeBits, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= rangeBits
+	}
+	return bits, nil
+}
+
+func parseRange(expr string, b bounds) (uint64, error) {
+	rangeStr, stepStr := expr, ""
+	if i := strings.Index(expr, "/"); i >= 0 {
+		rangeStr, stepStr = expr[:i], expr[i+1:]
+	}
+
+	var start, end uint
+	switch {
+	case rangeStr == "*" || rangeStr == "?":
+		start, end = b.min, b.max
+	case strings.Contains(rangeStr, "-"):
+		i := strings.Index(rangeStr, "-")
+		var err error
+		if start, err = parseValue(rangeStr[:i], b); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(rangeStr[i+1:], b); err != nil {
+			return 0, err
+		}
+		if start > end {
+			return 0, fmt.Errorf("range start %v is greater than end %v", start, end)
+		}
+	default:
+		var err error
+		if start, err = parseValue(rangeStr, b); err != nil {
+			return 0, err
+		}
+		end = start
+		// A single value with a step covers the range from that value.
+		if len(stepStr) > 0 {
+			end = b.max
+		}
+	}
+
+	step := uint(1)
+	if len(stepStr) > 0 {
+		n, err := strconv.ParseUint(stepStr, 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("invalid step: %v", stepStr)
+		}
+		step = uint(n)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits, nil
+}
+
+func parseValue(str string, b bounds) (uint, error) {
+	if v, exists := b.names[strings.ToLower(str)]; exists {
+		return v, nil
+	}
+	n, err := strconv.ParseUint(str, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %v", str)
+	}
+	if v := uint(n); v >= b.min && v <= b.max {
+		return v, nil
+	}
+	return 0, fmt.Errorf("value %v out of range [%v, %v]", n, b.min, b.max)
+}
+
+//------------------------------------------------------------------------------
+
+// Next returns the earliest time after t that satisfies the schedule, in the
+// location of t. A zero time is returned if the schedule cannot be satisfied
+// within five years, which is the case for expressions such as 30 February.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	// Begin from the start of the next whole second.
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+
+	// Once a field has been advanced all lower fields are reset to their
+	// minimum value.
+	added := false
+	yearLimit := t.Year() + 5
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// Daylight saving transitions at midnight can shift the hour.
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(-time.Duration(t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domOrDow {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		exp  string
+	}{
+		{"* * * * *", "2020-01-01T10:00:00Z", "2020-01-01T10:01:00Z"},
+		{"* * * * *", "2020-01-01T10:00:30Z", "2020-01-01T10:01:00Z"},
+		{"* * * * * *", "2020-01-01T10:00:30Z", "2020-01-01T10:00:31Z"},
+		{"*/15 * * * *", "2020-01-01T10:07:00Z", "2020-01-01T10:15:00Z"},
+		{"5-10/5 * * * *", "2020-01-01T10:07:00Z", "2020-01-01T10:10:00Z"},
+		{"0 9 * * mon-fri", "2020-01-03T10:00:00Z", "2020-01-06T09:00:00Z"},
+		{"0 0 * * 7", "2020-01-01T00:00:00Z", "2020-01-05T00:00:00Z"},
+		{"0 0 1,15 * *", "2020-01-02T00:00:00Z", "2020-01-15T00:00:00Z"},
+		{"0 0 1 * 1", "2020-01-02T00:00:00Z", "2020-01-06T00:00:00Z"},
+		{"0 0 29 feb *", "2021-01-01T00:00:00Z", "2024-02-29T00:00:00Z"},
+		{"30 12 * dec *", "2020-12-31T12:30:00Z", "2021-12-01T12:30:00Z"},
+		{"@hourly", "2020-01-01T10:59:59Z", "2020-01-01T11:00:00Z"},
+		{"@daily", "2020-01-01T10:00:00Z", "2020-01-02T00:00:00Z"},
+		{"@weekly", "2020-01-01T10:00:00Z", "2020-01-05T00:00:00Z"},
+		{"@monthly", "2020-01-01T10:00:00Z", "2020-02-01T00:00:00Z"},
+		{"@yearly", "2020-01-01T10:00:00Z", "2021-01-01T00:00:00Z"},
+		{"0 0 30 feb *", "2020-01-01T00:00:00Z", ""},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("%v: %v", test.expr, err)
+			continue
+		}
+		from, err := time.Parse(time.RFC3339, test.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := s.Next(from)
+		if test.exp == "" {
+			if !next.IsZero() {
+				t.Errorf("%v: expected zero time, got %v", test.expr, next)
+			}
+			continue
+		}
+		if act := next.Format(time.RFC3339); act != test.exp {
+			t.Errorf("%v from %v: %v != %v", test.expr, test.from, act, test.exp)
+		}
+	}
+}
+
+func TestScheduleNextTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	s, err := Parse("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2020, 3, 7, 12, 0, 0, 0, loc)
+	next := s.Next(from)
+	if exp := time.Date(2020, 3, 8, 9, 0, 0, 0, loc); !next.Equal(exp) {
+		t.Errorf("Wrong next time across DST change: %v != %v", next, exp)
+	}
+	if exp, act := "2020-03-08T13:00:00Z", next.UTC().Format(time.RFC3339); exp != act {
+		t.Errorf("Wrong UTC time: %v != %v", act, exp)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"10-5 * * * *",
+		"*/0 * * * *",
+		"foo * * * *",
+		"@never",
+	}
+
+	for _, test := range tests {
+		if _, err := Parse(test); err == nil {
+			t.Errorf("Expected error from expression: %v", test)
+		}
+	}
+}
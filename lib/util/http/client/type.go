@@ -43,6 +43,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/util/throttle"
 	"github.com/Jeffail/benthos/v3/lib/util/tls"
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	olog "github.com/opentracing/opentracing-go/log"
 )
 
@@ -475,6 +476,17 @@ func (h *Type) checkStatus(code int) (succeeded bool, retStrat retryStrategy) {
 	return true, noRetry
 }
 
+// injectSpan writes the context of the first span of a message into the headers
+// of a request, allowing the receiving service to join the trace. The format of
+// the headers depends on the tracer, where the otlp tracer writes W3C trace
+// context headers such as traceparent.
+func injectSpan(spans []opentracing.Span, req *http.Request) {
+	if len(spans) == 0 {
+		return
+	}
+	spans[0].Tracer().Inject(spans[0].Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+}
+
 // Do attempts to create and perform an HTTP request from a message payload.
 // This attempt may include retries, and if all retries fail an error is
 // returned.
@@ -486,6 +498,7 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 		spans = make([]opentracing.Span, msg.Len())
 		msg.Iter(func(i int, p types.Part) error {
 			spans[i], _ = opentracing.StartSpanFromContext(message.GetContext(p), "http_request")
+			ext.SpanKindRPCClient.Set(spans[i])
 			return nil
 		})
 		defer func() {
@@ -510,6 +523,7 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 		logErr(err)
 		return nil, err
 	}
+	injectSpan(spans, req)
 
 	startedAt := time.Now()
 
@@ -548,6 +562,7 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 			logErr(err)
 			continue
 		}
+		injectSpan(spans, req)
 		if rateLimited {
 			if !h.retryThrottle.ExponentialRetry() {
 				return nil, types.ErrTypeClosed
@@ -40,6 +40,9 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
 )
 
 //------------------------------------------------------------------------------
@@ -140,6 +143,46 @@ func TestHTTPClientSendBasic(t *testing.T) {
 	}
 }
 
+func TestHTTPClientInjectsSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	headerChan := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerChan <- r.Header
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.URL = ts.URL + "/testpost"
+
+	h, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.Send(message.New([][]byte{[]byte("foo")})); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tracer.FinishedSpans()
+	if exp, act := 1, len(spans); exp != act {
+		t.Fatalf("Wrong count of spans: %v != %v", act, exp)
+	}
+	if exp, act := ext.SpanKindRPCClientEnum, spans[0].Tag(string(ext.SpanKind)); exp != act {
+		t.Errorf("Wrong span kind: %v != %v", act, exp)
+	}
+
+	select {
+	case header := <-headerChan:
+		if exp, act := strconv.Itoa(spans[0].SpanContext.TraceID), header.Get("Mockpfx-Ids-Traceid"); exp != act {
+			t.Errorf("Wrong injected trace ID: %v != %v", act, exp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Action timed out")
+	}
+}
+
 func TestHTTPClientDropOn(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sasl
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/util/aws/session"
+	"github.com/Shopify/sarama"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+//------------------------------------------------------------------------------
+
+type oauth2TokenProvider struct {
+	source oauth2.TokenSource
+}
+
+func newOAuth2TokenProvider(conf Config) (sarama.AccessTokenProvider, error) {
+	if len(conf.OAuth2.TokenURL) == 0 {
+		return nil, errors.New("an oauth2.token_url must be provided")
+	}
+	ccConf := clientcredentials.Config{
+		ClientID:     conf.OAuth2.ClientID,
+		ClientSecret: conf.OAuth2.ClientSecret,
+		TokenURL:     conf.OAuth2.TokenURL,
+		Scopes:       conf.OAuth2.Scopes,
+	}
+	// The returned token source caches tokens and only requests a new one once
+	// the cached token has expired.
+	return &oauth2TokenProvider{
+		source: ccConf.TokenSource(context.Background()),
+	}, nil
+}
+
+func (o *oauth2TokenProvider) Token() (*sarama.AccessToken, error) {
+	tok, err := o.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	return &sarama.AccessToken{Token: tok.AccessToken}, nil
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	mskIAMService   = "kafka-cluster"
+	mskIAMAction    = "kafka-cluster:Connect"
+	mskIAMUserAgent = "benthos"
+	mskIAMExpiry    = 15 * time.Minute
+)
+
+// awsMSKIAMTokenProvider generates tokens for Amazon MSK IAM access control,
+// which are the base64 encoding of a SigV4 presigned kafka-cluster:Connect
+// request. Tokens are cached and regenerated once 80% of their lifetime has
+// passed.
+type awsMSKIAMTokenProvider struct {
+	region string
+	signer *v4.Signer
+	now    func() time.Time
+
+	mut       sync.Mutex
+	token     string
+	refreshAt time.Time
+}
+
+func newAWSMSKIAMTokenProvider(conf Config) (sarama.AccessTokenProvider, error) {
+	if len(conf.AWSMSKIAM.Region) == 0 {
+		return nil, errors.New("an aws_msk_iam.region must be provided")
+	}
+	sess, err := session.Config{
+		Credentials: conf.AWSMSKIAM.Credentials,
+		Region:      conf.AWSMSKIAM.Region,
+	}.GetSession()
+	if err != nil {
+		return nil, err
+	}
+	return newAWSMSKIAMTokenProviderFromCreds(conf.AWSMSKIAM.Region, sess.Config.Credentials), nil
+}
+
+func newAWSMSKIAMTokenProviderFromCreds(region string, creds *credentials.Credentials) *awsMSKIAMTokenProvider {
+	return &awsMSKIAMTokenProvider{
+		region: region,
+		signer: v4.NewSigner(creds),
+		now:    time.Now,
+	}
+}
+
+func (a *awsMSKIAMTokenProvider) Token() (*sarama.AccessToken, error) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	now := a.now()
+	if len(a.token) > 0 && now.Before(a.refreshAt) {
+		return &sarama.AccessToken{Token: a.token}, nil
+	}
+
+	token, err := a.generate(now)
+	if err != nil {
+		return nil, err
+	}
+	a.token = token
+	a.refreshAt = now.Add(mskIAMExpiry * 4 / 5)
+	return &sarama.AccessToken{Token: token}, nil
+}
+
+func (a *awsMSKIAMTokenProvider) generate(now time.Time) (string, error) {
+	req, err := http.NewRequest("GET", "https://kafka."+a.region+".amazonaws.com/", nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	query.Set("Action", mskIAMAction)
+	req.URL.RawQuery = query.Encode()
+
+	if _, err = a.signer.Presign(req, nil, mskIAMService, a.region, mskIAMExpiry, now); err != nil {
+		return "", err
+	}
+
+	query = req.URL.Query()
+	query.Set("User-Agent", mskIAMUserAgent)
+	req.URL.RawQuery = query.Encode()
+
+	return base64.RawURLEncoding.EncodeToString([]byte(req.URL.String())), nil
+}
+
+//------------------------------------------------------------------------------
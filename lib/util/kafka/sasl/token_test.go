@@ -0,0 +1,153 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sasl
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+//------------------------------------------------------------------------------
+
+func TestOAuth2TokenProvider(t *testing.T) {
+	var reqs int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reqs, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if exp, act := "client_credentials", r.PostForm.Get("grant_type"); exp != act {
+			t.Errorf("Wrong grant type: %v != %v", act, exp)
+		}
+		if exp, act := "foo bar", r.PostForm.Get("scope"); exp != act {
+			t.Errorf("Wrong scope: %v != %v", act, exp)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "fooid" || pass != "foosecret" {
+			t.Errorf("Wrong client credentials: %v %v", user, pass)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"footoken","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.Mechanism = sarama.SASLTypeOAuth
+	conf.TokenProvider = "oauth2"
+	conf.OAuth2.TokenURL = server.URL
+	conf.OAuth2.ClientID = "fooid"
+	conf.OAuth2.ClientSecret = "foosecret"
+	conf.OAuth2.Scopes = []string{"foo", "bar"}
+
+	sConf := sarama.NewConfig()
+	if err := conf.Apply(sConf); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		tok, err := sConf.Net.SASL.TokenProvider.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := "footoken", tok.Token; exp != act {
+			t.Errorf("Wrong token: %v != %v", act, exp)
+		}
+	}
+	if exp, act := int32(1), atomic.LoadInt32(&reqs); exp != act {
+		t.Errorf("Wrong count of token requests: %v != %v", act, exp)
+	}
+}
+
+func TestAWSMSKIAMTokenProvider(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	p := newAWSMSKIAMTokenProviderFromCreds(
+		"us-east-1", credentials.NewStaticCredentials("fooid", "foosecret", ""),
+	)
+	p.now = func() time.Time { return now }
+
+	tok, err := p.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rawURL, err := base64.RawURLEncoding.DecodeString(tok.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(string(rawURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "kafka.us-east-1.amazonaws.com", u.Host; exp != act {
+		t.Errorf("Wrong host: %v != %v", act, exp)
+	}
+
+	query := u.Query()
+	for k, exp := range map[string]string{
+		"Action":              "kafka-cluster:Connect",
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    "fooid/20200601/us-east-1/kafka-cluster/aws4_request",
+		"X-Amz-Date":          "20200601T120000Z",
+		"X-Amz-Expires":       "900",
+		"X-Amz-SignedHeaders": "host",
+		"User-Agent":          "benthos",
+	} {
+		if act := query.Get(k); exp != act {
+			t.Errorf("Wrong %v: %v != %v", k, act, exp)
+		}
+	}
+	if len(query.Get("X-Amz-Signature")) == 0 {
+		t.Error("Expected signature")
+	}
+	if strings.Contains(tok.Token, "=") {
+		t.Error("Expected unpadded token")
+	}
+
+	now = now.Add(time.Minute)
+	cachedTok, err := p.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cachedTok.Token != tok.Token {
+		t.Error("Expected cached token to be reused")
+	}
+
+	now = now.Add(12 * time.Minute)
+	newTok, err := p.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newTok.Token == tok.Token {
+		t.Error("Expected token to be regenerated")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,206 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sasl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/lib/util/aws/session"
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+// Documentation is a markdown description of how and why to use SASL settings.
+const Documentation = `### SASL
+
+SASL authentication is enabled with ` + "`sasl.enabled`" + `, and the field
+` + "`sasl.mechanism`" + ` selects between ` + "`PLAIN`" + ` (the default), which
+uses the ` + "`user` and `password`" + ` fields, and ` + "`OAUTHBEARER`" + `.
+
+When using ` + "`OAUTHBEARER`" + ` the field ` + "`sasl.token_provider`" + `
+determines where access tokens come from:
+
+- ` + "`static`" + `: The token set in ` + "`access_token`" + ` is used for all
+  connections.
+- ` + "`oauth2`" + `: Tokens are obtained from ` + "`oauth2.token_url`" + ` using
+  the client credentials flow and are refreshed once they expire.
+- ` + "`aws_msk_iam`" + `: Tokens are signed with the AWS credentials configured
+  within ` + "`aws_msk_iam`" + `, allowing access to Amazon MSK clusters that use
+  IAM access control. Tokens are regenerated before they expire.
+
+` + "``` yaml" + `
+sasl:
+  enabled: true
+  mechanism: OAUTHBEARER
+  token_provider: aws_msk_iam
+  aws_msk_iam:
+    region: us-east-1
+` + "```" + ``
+
+//------------------------------------------------------------------------------
+
+// OAuth2Config contains configuration fields for obtaining access tokens using
+// the OAuth 2.0 client credentials flow.
+type OAuth2Config struct {
+	TokenURL     string   `json:"token_url" yaml:"token_url"`
+	ClientID     string   `json:"client_id" yaml:"client_id"`
+	ClientSecret string   `json:"client_secret" yaml:"client_secret"`
+	Scopes       []string `json:"scopes" yaml:"scopes"`
+}
+
+// AWSMSKIAMConfig contains configuration fields for generating access tokens
+// for Amazon MSK IAM access control.
+type AWSMSKIAMConfig struct {
+	Region      string                    `json:"region" yaml:"region"`
+	Credentials session.CredentialsConfig `json:"credentials" yaml:"credentials"`
+}
+
+// Config contains configuration for SASL based authentication.
+type Config struct {
+	Enabled       bool            `json:"enabled" yaml:"enabled"`
+	Mechanism     string          `json:"mechanism" yaml:"mechanism"`
+	User          string          `json:"user" yaml:"user"`
+	Password      string          `json:"password" yaml:"password"`
+	TokenProvider string          `json:"token_provider" yaml:"token_provider"`
+	AccessToken   string          `json:"access_token" yaml:"access_token"`
+	OAuth2        OAuth2Config    `json:"oauth2" yaml:"oauth2"`
+	AWSMSKIAM     AWSMSKIAMConfig `json:"aws_msk_iam" yaml:"aws_msk_iam"`
+}
+
+// NewConfig creates a new Config with default values.
+func NewConfig() Config {
+	awsConf := session.NewConfig()
+	return Config{
+		Enabled:       false,
+		Mechanism:     sarama.SASLTypePlaintext,
+		User:          "",
+		Password:      "",
+		TokenProvider: "static",
+		AccessToken:   "",
+		OAuth2: OAuth2Config{
+			TokenURL:     "",
+			ClientID:     "",
+			ClientSecret: "",
+			Scopes:       []string{},
+		},
+		AWSMSKIAM: AWSMSKIAMConfig{
+			Region:      awsConf.Region,
+			Credentials: awsConf.Credentials,
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Auth is a validated SASL config along with any token provider it requires,
+// which can be applied to the sarama config of each connection attempt such
+// that the provider, and any token it caches, is reused across reconnects.
+type Auth struct {
+	conf     Config
+	provider sarama.AccessTokenProvider
+}
+
+// Get validates the Config and creates its token provider, if required.
+func (c Config) Get() (*Auth, error) {
+	a := &Auth{conf: c}
+	if !c.Enabled {
+		return a, nil
+	}
+
+	switch c.Mechanism {
+	case sarama.SASLTypePlaintext, "":
+	case sarama.SASLTypeOAuth:
+		ctor, exists := TokenProviders[c.TokenProvider]
+		if !exists {
+			return nil, fmt.Errorf("token provider not recognised: %v", c.TokenProvider)
+		}
+		var err error
+		if a.provider, err = ctor(c); err != nil {
+			return nil, fmt.Errorf("failed to create %v token provider: %v", c.TokenProvider, err)
+		}
+	default:
+		return nil, fmt.Errorf("SASL mechanism not supported: %v", c.Mechanism)
+	}
+	return a, nil
+}
+
+// Apply sets the SASL fields of a sarama config. When SASL is disabled the
+// sarama config is left unchanged.
+func (a *Auth) Apply(conf *sarama.Config) {
+	if !a.conf.Enabled {
+		return
+	}
+	conf.Net.SASL.Enable = true
+	if a.provider != nil {
+		conf.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		conf.Net.SASL.TokenProvider = a.provider
+		return
+	}
+	conf.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	conf.Net.SASL.User = a.conf.User
+	conf.Net.SASL.Password = a.conf.Password
+}
+
+// Apply sets the SASL fields of a sarama config according to the Config. When
+// SASL is disabled the sarama config is left unchanged. Any token provider is
+// created anew, and therefore components that connect repeatedly should use
+// Get once instead.
+func (c Config) Apply(conf *sarama.Config) error {
+	a, err := c.Get()
+	if err != nil {
+		return err
+	}
+	a.Apply(conf)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// TokenProviderConstructor creates an OAUTHBEARER access token provider from a
+// SASL config.
+type TokenProviderConstructor func(conf Config) (sarama.AccessTokenProvider, error)
+
+// TokenProviders is a map of all registered OAUTHBEARER token providers, keyed
+// by the value of the token_provider field. Plugins are free to add their own.
+var TokenProviders = map[string]TokenProviderConstructor{
+	"static":      newStaticTokenProvider,
+	"oauth2":      newOAuth2TokenProvider,
+	"aws_msk_iam": newAWSMSKIAMTokenProvider,
+}
+
+type staticTokenProvider struct {
+	token string
+}
+
+func newStaticTokenProvider(conf Config) (sarama.AccessTokenProvider, error) {
+	if len(conf.AccessToken) == 0 {
+		return nil, errors.New("an access_token must be provided")
+	}
+	return staticTokenProvider{token: conf.AccessToken}, nil
+}
+
+func (s staticTokenProvider) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: s.token}, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,183 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sasl
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+func TestApplyDisabled(t *testing.T) {
+	conf := NewConfig()
+	conf.User = "foo"
+
+	sConf := sarama.NewConfig()
+	if err := conf.Apply(sConf); err != nil {
+		t.Fatal(err)
+	}
+	if sConf.Net.SASL.Enable {
+		t.Error("Expected SASL to be disabled")
+	}
+	if sConf.Net.SASL.User != "" {
+		t.Errorf("Unexpected user: %v", sConf.Net.SASL.User)
+	}
+}
+
+func TestApplyPlain(t *testing.T) {
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.User = "foo"
+	conf.Password = "bar"
+
+	sConf := sarama.NewConfig()
+	if err := conf.Apply(sConf); err != nil {
+		t.Fatal(err)
+	}
+	if !sConf.Net.SASL.Enable {
+		t.Error("Expected SASL to be enabled")
+	}
+	if exp, act := sarama.SASLMechanism(sarama.SASLTypePlaintext), sConf.Net.SASL.Mechanism; exp != act {
+		t.Errorf("Wrong mechanism: %v != %v", act, exp)
+	}
+	if exp, act := "foo", sConf.Net.SASL.User; exp != act {
+		t.Errorf("Wrong user: %v != %v", act, exp)
+	}
+	if exp, act := "bar", sConf.Net.SASL.Password; exp != act {
+		t.Errorf("Wrong password: %v != %v", act, exp)
+	}
+}
+
+func TestApplyOAuthStatic(t *testing.T) {
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.Mechanism = sarama.SASLTypeOAuth
+	conf.AccessToken = "footoken"
+
+	sConf := sarama.NewConfig()
+	if err := conf.Apply(sConf); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := sarama.SASLMechanism(sarama.SASLTypeOAuth), sConf.Net.SASL.Mechanism; exp != act {
+		t.Errorf("Wrong mechanism: %v != %v", act, exp)
+	}
+	if sConf.Net.SASL.TokenProvider == nil {
+		t.Fatal("Expected token provider")
+	}
+	tok, err := sConf.Net.SASL.TokenProvider.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "footoken", tok.Token; exp != act {
+		t.Errorf("Wrong token: %v != %v", act, exp)
+	}
+}
+
+func TestApplyCustomTokenProvider(t *testing.T) {
+	TokenProviders["test_custom"] = func(conf Config) (sarama.AccessTokenProvider, error) {
+		return staticTokenProvider{token: "custom-" + conf.User}, nil
+	}
+	defer delete(TokenProviders, "test_custom")
+
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.Mechanism = sarama.SASLTypeOAuth
+	conf.TokenProvider = "test_custom"
+	conf.User = "foo"
+
+	sConf := sarama.NewConfig()
+	if err := conf.Apply(sConf); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := sConf.Net.SASL.TokenProvider.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "custom-foo", tok.Token; exp != act {
+		t.Errorf("Wrong token: %v != %v", act, exp)
+	}
+}
+
+func TestGetReusesTokenProvider(t *testing.T) {
+	created := 0
+	TokenProviders["test_counted"] = func(conf Config) (sarama.AccessTokenProvider, error) {
+		created++
+		return staticTokenProvider{token: "foo"}, nil
+	}
+	defer delete(TokenProviders, "test_counted")
+
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.Mechanism = sarama.SASLTypeOAuth
+	conf.TokenProvider = "test_counted"
+
+	auth, err := conf.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		sConf := sarama.NewConfig()
+		auth.Apply(sConf)
+		if exp, act := sarama.SASLMechanism(sarama.SASLTypeOAuth), sConf.Net.SASL.Mechanism; exp != act {
+			t.Errorf("Wrong mechanism: %v != %v", act, exp)
+		}
+	}
+	if exp, act := 1, created; exp != act {
+		t.Errorf("Wrong count of created token providers: %v != %v", act, exp)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := map[string]func(c *Config){
+		"bad mechanism": func(c *Config) {
+			c.Mechanism = "SCRAM-SHA-1024"
+		},
+		"bad token provider": func(c *Config) {
+			c.Mechanism = sarama.SASLTypeOAuth
+			c.TokenProvider = "nope"
+		},
+		"missing static token": func(c *Config) {
+			c.Mechanism = sarama.SASLTypeOAuth
+		},
+		"missing token url": func(c *Config) {
+			c.Mechanism = sarama.SASLTypeOAuth
+			c.TokenProvider = "oauth2"
+		},
+		"missing region": func(c *Config) {
+			c.Mechanism = sarama.SASLTypeOAuth
+			c.TokenProvider = "aws_msk_iam"
+			c.AWSMSKIAM.Region = ""
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewConfig()
+		conf.Enabled = true
+		fn(&conf)
+		if err := conf.Apply(sarama.NewConfig()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,129 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package kerberos
+
+import (
+	"errors"
+	"fmt"
+
+	krbclient "gopkg.in/jcmturner/gokrb5.v7/client"
+	krbconfig "gopkg.in/jcmturner/gokrb5.v7/config"
+	"gopkg.in/jcmturner/gokrb5.v7/credentials"
+	"gopkg.in/jcmturner/gokrb5.v7/keytab"
+)
+
+//------------------------------------------------------------------------------
+
+// Documentation is a markdown description of how and why to use Kerberos
+// settings.
+const Documentation = `### Kerberos
+
+Kerberos authentication is enabled with ` + "`kerberos.enabled`" + `, and
+credentials are obtained from one of the following, in order of precedence:
+
+- ` + "`keytab_file`" + `: A keytab containing the key of the principal
+  ` + "`username`" + ` within ` + "`realm`" + `.
+- ` + "`ccache_file`" + `: A credential cache, such as one populated by
+  ` + "`kinit`" + `, in which case the principal is taken from the cache.
+- ` + "`password`" + `: The password of the principal ` + "`username`" + `
+  within ` + "`realm`" + `.
+
+The Kerberos configuration, which includes the KDCs of each realm, is read from
+` + "`kerberos.config_file`" + `.
+
+` + "``` yaml" + `
+kerberos:
+  enabled: true
+  config_file: /etc/krb5.conf
+  realm: EXAMPLE.COM
+  username: benthos
+  keytab_file: /etc/security/benthos.keytab
+  service_principal_name: nn/_HOST
+` + "```" + ``
+
+//------------------------------------------------------------------------------
+
+// Config contains configuration fields for Kerberos authentication.
+type Config struct {
+	Enabled              bool   `json:"enabled" yaml:"enabled"`
+	ConfigFile           string `json:"config_file" yaml:"config_file"`
+	Realm                string `json:"realm" yaml:"realm"`
+	Username             string `json:"username" yaml:"username"`
+	Password             string `json:"password" yaml:"password"`
+	KeytabFile           string `json:"keytab_file" yaml:"keytab_file"`
+	CCacheFile           string `json:"ccache_file" yaml:"ccache_file"`
+	ServicePrincipalName string `json:"service_principal_name" yaml:"service_principal_name"`
+}
+
+// NewConfig creates a new Config with default values.
+func NewConfig() Config {
+	return Config{
+		Enabled:              false,
+		ConfigFile:           "/etc/krb5.conf",
+		Realm:                "",
+		Username:             "",
+		Password:             "",
+		KeytabFile:           "",
+		CCacheFile:           "",
+		ServicePrincipalName: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Client creates a Kerberos client from the Config and logs in when the
+// credentials are a keytab or password. The caller is responsible for calling
+// Destroy on the client once it is no longer needed.
+func (c Config) Client() (*krbclient.Client, error) {
+	krb5conf, err := krbconfig.Load(c.ConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kerberos config: %v", err)
+	}
+
+	var cl *krbclient.Client
+	switch {
+	case len(c.KeytabFile) > 0:
+		kt, err := keytab.Load(c.KeytabFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keytab: %v", err)
+		}
+		cl = krbclient.NewClientWithKeytab(c.Username, c.Realm, kt, krb5conf)
+	case len(c.CCacheFile) > 0:
+		ccache, err := credentials.LoadCCache(c.CCacheFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credential cache: %v", err)
+		}
+		if cl, err = krbclient.NewClientFromCCache(ccache, krb5conf); err != nil {
+			return nil, fmt.Errorf("failed to create client from credential cache: %v", err)
+		}
+		return cl, nil
+	case len(c.Password) > 0:
+		cl = krbclient.NewClientWithPassword(c.Username, c.Realm, c.Password, krb5conf)
+	default:
+		return nil, errors.New("one of keytab_file, ccache_file or password must be provided")
+	}
+
+	if err = cl.Login(); err != nil {
+		return nil, fmt.Errorf("failed to login: %v", err)
+	}
+	return cl, nil
+}
+
+//------------------------------------------------------------------------------
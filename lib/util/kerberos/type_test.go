@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package kerberos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_kerberos_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	confPath := filepath.Join(dir, "krb5.conf")
+	if err = ioutil.WriteFile(confPath, []byte(`[libdefaults]
+  default_realm = EXAMPLE.COM
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = 127.0.0.1:88
+  }
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func(c *Config){
+		"missing config file": func(c *Config) {
+			c.ConfigFile = filepath.Join(dir, "nope.conf")
+			c.Password = "foo"
+		},
+		"no credentials": func(c *Config) {},
+		"missing keytab": func(c *Config) {
+			c.KeytabFile = filepath.Join(dir, "nope.keytab")
+		},
+		"missing ccache": func(c *Config) {
+			c.CCacheFile = filepath.Join(dir, "nope.ccache")
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewConfig()
+		conf.Enabled = true
+		conf.ConfigFile = confPath
+		conf.Realm = "EXAMPLE.COM"
+		conf.Username = "benthos"
+		fn(&conf)
+		if _, err := conf.Client(); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+//------------------------------------------------------------------------------
+
+// IsV5 parses a protocol_version config field and returns true when it selects
+// protocol version 5, or an error if the version is not recognised.
+func IsV5(protocolVersion string) (bool, error) {
+	switch protocolVersion {
+	case "3.1.1", "":
+		return false, nil
+	case "5":
+		return true, nil
+	}
+	return false, fmt.Errorf("protocol version not recognised: %v", protocolVersion)
+}
+
+//------------------------------------------------------------------------------
+
+// V5Options contains the parameters used for establishing an MQTT v5
+// connection.
+type V5Options struct {
+	URLs           []string
+	ClientID       string
+	User           string
+	Password       string
+	CleanStart     bool
+	SessionExpiry  time.Duration
+	KeepAlive      time.Duration
+	ConnectTimeout time.Duration
+
+	// Router receives all messages published to the client, and may be nil for
+	// clients that do not subscribe.
+	Router paho.Router
+}
+
+// V5Client is a connected MQTT v5 client.
+type V5Client struct {
+	*paho.Client
+	notifier *closeNotifier
+}
+
+// Done returns a channel that is closed once the underlying network connection
+// of the client has been closed, either due to an error, a server initiated
+// disconnect or a call to Disconnect.
+func (c *V5Client) Done() <-chan struct{} {
+	return c.notifier.closedChan
+}
+
+// ConnectV5 attempts to connect to each URL in turn with MQTT protocol version
+// 5, returning the first client that successfully connects.
+func ConnectV5(ctx context.Context, opts V5Options) (*V5Client, error) {
+	if len(opts.URLs) == 0 {
+		return nil, errors.New("at least one url must be provided")
+	}
+	var err error
+	for _, u := range opts.URLs {
+		var client *V5Client
+		if client, err = connectV5(ctx, u, opts); err == nil {
+			return client, nil
+		}
+		err = fmt.Errorf("failed to connect to '%v': %v", u, err)
+	}
+	return nil, err
+}
+
+func dialV5(ctx context.Context, urlStr string, timeout time.Duration) (net.Conn, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		return dialer.DialContext(ctx, "tcp", u.Host)
+	case "ssl", "tls", "tcps", "mqtts":
+		return tls.DialWithDialer(dialer, "tcp", u.Host, &tls.Config{
+			ServerName: u.Hostname(),
+		})
+	}
+	return nil, fmt.Errorf("url scheme not supported with protocol version 5: %v", u.Scheme)
+}
+
+func connectV5(ctx context.Context, urlStr string, opts V5Options) (*V5Client, error) {
+	netConn, err := dialV5(ctx, urlStr, opts.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn, notifier := wrapConn(netConn)
+
+	client := paho.NewClient()
+	client.Conn = conn
+	client.PingHandler = newPinger(client.Error)
+	if opts.Router != nil {
+		client.Router = opts.Router
+	}
+	if opts.ConnectTimeout > 0 {
+		client.PacketTimeout = opts.ConnectTimeout
+	}
+
+	// The client panics when started with a zero keep alive.
+	keepAlive := uint16(opts.KeepAlive / time.Second)
+	if keepAlive == 0 {
+		keepAlive = 30
+	}
+
+	cp := &paho.Connect{
+		ClientID:   opts.ClientID,
+		KeepAlive:  keepAlive,
+		CleanStart: opts.CleanStart,
+		Properties: &paho.ConnectProperties{},
+	}
+	if opts.SessionExpiry > 0 {
+		expiry := uint32(opts.SessionExpiry / time.Second)
+		cp.Properties.SessionExpiryInterval = &expiry
+	}
+	if len(opts.User) > 0 {
+		cp.Username = opts.User
+		cp.UsernameFlag = true
+	}
+	if len(opts.Password) > 0 {
+		cp.Password = []byte(opts.Password)
+		cp.PasswordFlag = true
+	}
+
+	if _, err = client.Connect(ctx, cp); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &V5Client{Client: client, notifier: notifier}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// pinger sends keep alive pings for a client. It replaces the default
+// paho.PingHandler, which creates its stop channel within Start and therefore
+// races with, or panics on, a call to Stop made while the connection is torn
+// down before the ping goroutine has started.
+type pinger struct {
+	stop        chan struct{}
+	stopOnce    sync.Once
+	outstanding int32
+	failFn      func(error)
+}
+
+func newPinger(failFn func(error)) *pinger {
+	return &pinger{
+		stop:   make(chan struct{}),
+		failFn: failFn,
+	}
+}
+
+// Start sends a ping every keep alive period until stopped, and fails the
+// connection when a ping is not answered before the next is due.
+func (p *pinger) Start(conn net.Conn, keepAlive time.Duration) {
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		if !atomic.CompareAndSwapInt32(&p.outstanding, 0, 1) {
+			p.failFn(errors.New("ping response timed out"))
+			return
+		}
+		if _, err := packets.NewControlPacket(packets.PINGREQ).WriteTo(conn); err != nil {
+			p.failFn(err)
+			return
+		}
+	}
+}
+
+// Stop ends the ping loop, and may be called before Start or more than once.
+func (p *pinger) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// PingResp records that the latest ping has been answered.
+func (p *pinger) PingResp() {
+	atomic.StoreInt32(&p.outstanding, 0)
+}
+
+//------------------------------------------------------------------------------
+
+// closeNotifier signals when a connection has been closed.
+type closeNotifier struct {
+	closeOnce  sync.Once
+	closedChan chan struct{}
+}
+
+func newCloseNotifier() *closeNotifier {
+	return &closeNotifier{closedChan: make(chan struct{})}
+}
+
+func (c *closeNotifier) notify() {
+	c.closeOnce.Do(func() {
+		close(c.closedChan)
+	})
+}
+
+// tcpNotifyConn embeds a *net.TCPConn rather than a net.Conn so that the
+// client continues to write each packet with a single writev call, which
+// prevents the packets of concurrent writers from interleaving.
+type tcpNotifyConn struct {
+	*net.TCPConn
+	*closeNotifier
+}
+
+func (t tcpNotifyConn) Close() error {
+	err := t.TCPConn.Close()
+	t.notify()
+	return err
+}
+
+type notifyConn struct {
+	net.Conn
+	*closeNotifier
+}
+
+func (n notifyConn) Close() error {
+	err := n.Conn.Close()
+	n.notify()
+	return err
+}
+
+func wrapConn(conn net.Conn) (net.Conn, *closeNotifier) {
+	notifier := newCloseNotifier()
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		return tcpNotifyConn{TCPConn: tcpConn, closeNotifier: notifier}, notifier
+	}
+	return notifyConn{Conn: conn, closeNotifier: notifier}, notifier
+}
+
+//------------------------------------------------------------------------------
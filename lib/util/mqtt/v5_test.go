@@ -0,0 +1,380 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mqtt
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+//------------------------------------------------------------------------------
+
+// testBroker is a minimal MQTT v5 broker that supports QoS 0 and 1 and shared
+// subscriptions, where each connection of a share group receives all messages.
+type testBroker struct {
+	ln net.Listener
+
+	mut      sync.Mutex
+	connects []*packets.Connect
+	subs     map[net.Conn][]string
+	conns    []net.Conn
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{
+		ln:   ln,
+		subs: map[net.Conn][]string{},
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mut.Lock()
+			b.conns = append(b.conns, conn)
+			b.mut.Unlock()
+			go b.handle(conn)
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *testBroker) close() {
+	b.ln.Close()
+	b.mut.Lock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	b.mut.Unlock()
+}
+
+func (b *testBroker) getConnects() []*packets.Connect {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return append([]*packets.Connect{}, b.connects...)
+}
+
+func topicMatches(sub, topic string) bool {
+	if strings.HasPrefix(sub, "$share/") {
+		if parts := strings.SplitN(sub, "/", 3); len(parts) == 3 {
+			sub = parts[2]
+		}
+	}
+	return sub == topic
+}
+
+func (b *testBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		cp, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		switch p := cp.Content.(type) {
+		case *packets.Connect:
+			b.mut.Lock()
+			b.connects = append(b.connects, p)
+			b.mut.Unlock()
+			(&packets.Connack{Properties: &packets.Properties{}}).WriteTo(conn)
+		case *packets.Subscribe:
+			reasons := []byte{}
+			b.mut.Lock()
+			for topic := range p.Subscriptions {
+				b.subs[conn] = append(b.subs[conn], topic)
+				reasons = append(reasons, 0)
+			}
+			b.mut.Unlock()
+			(&packets.Suback{
+				PacketID:   p.PacketID,
+				Reasons:    reasons,
+				Properties: &packets.Properties{},
+			}).WriteTo(conn)
+		case *packets.Publish:
+			if p.QoS == 1 {
+				(&packets.Puback{
+					PacketID:   p.PacketID,
+					Properties: &packets.Properties{},
+				}).WriteTo(conn)
+			}
+			b.mut.Lock()
+			for subConn, topics := range b.subs {
+				for _, topic := range topics {
+					if topicMatches(topic, p.Topic) {
+						(&packets.Publish{
+							Topic:      p.Topic,
+							Payload:    p.Payload,
+							Properties: p.Properties,
+						}).WriteTo(subConn)
+						break
+					}
+				}
+			}
+			b.mut.Unlock()
+		case *packets.Pingreq:
+			(&packets.Pingresp{}).WriteTo(conn)
+		case *packets.Disconnect:
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestIsV5(t *testing.T) {
+	for str, exp := range map[string]bool{
+		"":      false,
+		"3.1.1": false,
+		"5":     true,
+	} {
+		act, err := IsV5(str)
+		if err != nil {
+			t.Error(err)
+		}
+		if act != exp {
+			t.Errorf("Wrong result for '%v': %v != %v", str, act, exp)
+		}
+	}
+	if _, err := IsV5("4"); err == nil {
+		t.Error("Expected error from bad protocol version")
+	}
+}
+
+func TestConnectV5Options(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+
+	client, err := ConnectV5(context.Background(), V5Options{
+		URLs:           []string{"tcp://127.0.0.1:1", broker.url()},
+		ClientID:       "foo",
+		User:           "fooer",
+		Password:       "foopass",
+		CleanStart:     false,
+		SessionExpiry:  time.Minute,
+		KeepAlive:      time.Second * 30,
+		ConnectTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(&paho.Disconnect{})
+
+	connects := broker.getConnects()
+	if len(connects) != 1 {
+		t.Fatalf("Wrong count of connects: %v", len(connects))
+	}
+	cp := connects[0]
+	if exp, act := "foo", cp.ClientID; exp != act {
+		t.Errorf("Wrong client id: %v != %v", act, exp)
+	}
+	if exp, act := "fooer", cp.Username; exp != act {
+		t.Errorf("Wrong username: %v != %v", act, exp)
+	}
+	if exp, act := "foopass", string(cp.Password); exp != act {
+		t.Errorf("Wrong password: %v != %v", act, exp)
+	}
+	if cp.CleanStart {
+		t.Error("Expected clean start to be false")
+	}
+	if exp, act := byte(5), cp.ProtocolVersion; exp != act {
+		t.Errorf("Wrong protocol version: %v != %v", act, exp)
+	}
+	if exp, act := uint16(30), cp.KeepAlive; exp != act {
+		t.Errorf("Wrong keep alive: %v != %v", act, exp)
+	}
+	if cp.Properties == nil || cp.Properties.SessionExpiryInterval == nil {
+		t.Fatal("Expected session expiry interval")
+	}
+	if exp, act := uint32(60), *cp.Properties.SessionExpiryInterval; exp != act {
+		t.Errorf("Wrong session expiry interval: %v != %v", act, exp)
+	}
+}
+
+func TestConnectV5BadURL(t *testing.T) {
+	if _, err := ConnectV5(context.Background(), V5Options{
+		URLs: []string{"ws://localhost:1883"},
+	}); err == nil {
+		t.Error("Expected error from unsupported scheme")
+	}
+	if _, err := ConnectV5(context.Background(), V5Options{}); err == nil {
+		t.Error("Expected error from no urls")
+	}
+}
+
+func TestConnectV5SharedSubscription(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.close()
+
+	pubsChan := make(chan *paho.Publish, 10)
+	sub, err := ConnectV5(context.Background(), V5Options{
+		URLs:           []string{broker.url()},
+		ClientID:       "foo_sub",
+		ConnectTimeout: time.Second,
+		Router: paho.NewSingleHandlerRouter(func(p *paho.Publish) {
+			pubsChan <- p
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Disconnect(&paho.Disconnect{})
+
+	if _, err = sub.Subscribe(context.Background(), &paho.Subscribe{
+		Subscriptions: map[string]paho.SubscribeOptions{
+			"$share/foogroup/footopic": {QoS: 1},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := ConnectV5(context.Background(), V5Options{
+		URLs:           []string{broker.url()},
+		ClientID:       "foo_pub",
+		ConnectTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Disconnect(&paho.Disconnect{})
+
+	if _, err = pub.Publish(context.Background(), &paho.Publish{
+		QoS:     1,
+		Topic:   "footopic",
+		Payload: []byte("hello world"),
+		Properties: &paho.PublishProperties{
+			User: map[string]string{"foo": "bar"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case p := <-pubsChan:
+		if exp, act := "hello world", string(p.Payload); exp != act {
+			t.Errorf("Wrong payload: %v != %v", act, exp)
+		}
+		if p.Properties == nil {
+			t.Fatal("Expected properties")
+		}
+		if exp, act := "bar", p.Properties.User["foo"]; exp != act {
+			t.Errorf("Wrong user property: %v != %v", act, exp)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for message")
+	}
+}
+
+func TestConnectV5Done(t *testing.T) {
+	broker := newTestBroker(t)
+
+	client, err := ConnectV5(context.Background(), V5Options{
+		URLs:           []string{broker.url()},
+		ClientID:       "foo",
+		ConnectTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-client.Done():
+		t.Fatal("Expected client to be connected")
+	default:
+	}
+
+	broker.close()
+
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for lost connection")
+	}
+}
+
+func TestPingerStopBeforeStart(t *testing.T) {
+	p := newPinger(func(err error) {
+		t.Errorf("Unexpected ping failure: %v", err)
+	})
+	p.Stop()
+	p.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		p.Start(nil, time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for stopped pinger to exit")
+	}
+}
+
+func TestPingerTimeout(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := remote.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	errChan := make(chan error, 1)
+	p := newPinger(func(err error) {
+		errChan <- err
+	})
+	go p.Start(local, time.Millisecond*10)
+	defer p.Stop()
+
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Error("Expected error from unanswered ping")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for ping failure")
+	}
+}
+
+//------------------------------------------------------------------------------
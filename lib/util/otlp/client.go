@@ -0,0 +1,187 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+//------------------------------------------------------------------------------
+
+// ClientConfig contains the fields of a Client.
+type ClientConfig struct {
+	// Protocol is either grpc or http.
+	Protocol string
+
+	// Endpoint is the host and port of the collector when the protocol is grpc,
+	// and the full URL that requests are posted to when it is http.
+	Endpoint string
+
+	// GRPCMethod is the full name of the gRPC method called for each export.
+	GRPCMethod string
+
+	Headers map[string]string
+	TLS     btls.Config
+	Timeout time.Duration
+}
+
+// Client exports pre-serialised OTLP requests to a collector.
+type Client struct {
+	conf       ClientConfig
+	grpcConn   *grpc.ClientConn
+	httpClient *http.Client
+}
+
+// NewClient creates a new Client from a config.
+func NewClient(conf ClientConfig) (*Client, error) {
+	c := &Client{conf: conf}
+
+	switch conf.Protocol {
+	case "grpc":
+		dialOpt := grpc.WithInsecure()
+		if conf.TLS.Enabled {
+			tlsConf, err := conf.TLS.Get()
+			if err != nil {
+				return nil, err
+			}
+			dialOpt = grpc.WithTransportCredentials(credentials.NewTLS(tlsConf))
+		}
+		var err error
+		if c.grpcConn, err = grpc.Dial(conf.Endpoint, dialOpt); err != nil {
+			return nil, fmt.Errorf("failed to dial endpoint: %v", err)
+		}
+	case "http":
+		c.httpClient = &http.Client{Timeout: conf.Timeout}
+		if conf.TLS.Enabled {
+			tlsConf, err := conf.TLS.Get()
+			if err != nil {
+				return nil, err
+			}
+			c.httpClient.Transport = &http.Transport{TLSClientConfig: tlsConf}
+		}
+	default:
+		return nil, fmt.Errorf("protocol not recognised: %v", conf.Protocol)
+	}
+	return c, nil
+}
+
+// Export sends a serialised request to the collector.
+func (c *Client) Export(req []byte) error {
+	if c.grpcConn != nil {
+		return c.exportGRPC(req)
+	}
+	return c.exportHTTP(req)
+}
+
+func (c *Client) exportGRPC(req []byte) error {
+	ctx, done := context.WithTimeout(context.Background(), c.conf.Timeout)
+	defer done()
+
+	if len(c.conf.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.conf.Headers))
+	}
+
+	var res RawMessage
+	msg := RawMessage(req)
+	return c.grpcConn.Invoke(ctx, c.conf.GRPCMethod, &msg, &res, grpc.ForceCodec(RawCodec{}))
+}
+
+func (c *Client) exportHTTP(req []byte) error {
+	hReq, err := http.NewRequest("POST", c.conf.Endpoint, bytes.NewReader(req))
+	if err != nil {
+		return err
+	}
+	hReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.conf.Headers {
+		hReq.Header.Set(k, v)
+	}
+
+	res, err := c.httpClient.Do(hReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("unexpected status code %v: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// Close closes the connection to the collector.
+func (c *Client) Close() error {
+	if c.grpcConn != nil {
+		return c.grpcConn.Close()
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// RawMessage is a pre-serialised protobuf message.
+type RawMessage []byte
+
+// RawCodec is a gRPC codec that passes pre-serialised protobuf messages
+// through as they are.
+type RawCodec struct{}
+
+// Marshal returns the bytes of a *RawMessage.
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(*RawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type: %T", v)
+	}
+	return *m, nil
+}
+
+// Unmarshal copies data into a *RawMessage.
+func (RawCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(*RawMessage)
+	if !ok {
+		return fmt.Errorf("unexpected message type: %T", v)
+	}
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+// Name returns the name of the codec.
+func (RawCodec) Name() string {
+	return "proto"
+}
+
+// String returns the name of the codec.
+func (RawCodec) String() string {
+	return "proto"
+}
+
+//------------------------------------------------------------------------------
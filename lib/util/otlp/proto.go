@@ -0,0 +1,149 @@
+// Copyright (c) 2019 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, sub to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package otlp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+//------------------------------------------------------------------------------
+
+// ProtoBuf is a minimal encoder of the protobuf wire format, which is used in
+// order to serialise OTLP messages without generated code. Fields with zero
+// values are written regardless, which is valid for all fields used.
+type ProtoBuf struct {
+	B []byte
+}
+
+func (p *ProtoBuf) key(field, wireType uint64) {
+	p.B = AppendVarint(p.B, field<<3|wireType)
+}
+
+// Varint writes a varint encoded field.
+func (p *ProtoBuf) Varint(field, v uint64) {
+	p.key(field, 0)
+	p.B = AppendVarint(p.B, v)
+}
+
+// Fixed64 writes a fixed64 encoded field.
+func (p *ProtoBuf) Fixed64(field, v uint64) {
+	p.key(field, 1)
+	p.B = AppendFixed64(p.B, v)
+}
+
+// Double writes a double field.
+func (p *ProtoBuf) Double(field uint64, v float64) {
+	p.Fixed64(field, math.Float64bits(v))
+}
+
+// Message writes a length delimited field, such as an embedded message.
+func (p *ProtoBuf) Message(field uint64, b []byte) {
+	p.key(field, 2)
+	p.B = AppendVarint(p.B, uint64(len(b)))
+	p.B = append(p.B, b...)
+}
+
+// String writes a string field.
+func (p *ProtoBuf) String(field uint64, s string) {
+	p.Message(field, []byte(s))
+}
+
+// AppendVarint appends a varint encoded value to a slice of bytes.
+func AppendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// AppendFixed64 appends a fixed64 encoded value to a slice of bytes.
+func AppendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+//------------------------------------------------------------------------------
+
+// EncodeAttributes writes a map of string attributes as a repeated KeyValue
+// field of a message, sorted by key.
+func EncodeAttributes(p *ProtoBuf, field uint64, attributes map[string]string) {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		EncodeKeyValue(p, field, k, attributes[k])
+	}
+}
+
+// EncodeKeyValue writes an attribute as a KeyValue field of a message. Strings,
+// booleans, integers and floats are written with their respective AnyValue
+// types, and any other value is written as a string.
+func EncodeKeyValue(p *ProtoBuf, field uint64, key string, value interface{}) {
+	var any ProtoBuf
+	switch v := value.(type) {
+	case string:
+		any.String(1, v)
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		any.Varint(2, b)
+	case int:
+		any.Varint(3, uint64(v))
+	case int8:
+		any.Varint(3, uint64(v))
+	case int16:
+		any.Varint(3, uint64(v))
+	case int32:
+		any.Varint(3, uint64(v))
+	case int64:
+		any.Varint(3, uint64(v))
+	case uint:
+		any.Varint(3, uint64(v))
+	case uint8:
+		any.Varint(3, uint64(v))
+	case uint16:
+		any.Varint(3, uint64(v))
+	case uint32:
+		any.Varint(3, uint64(v))
+	case uint64:
+		any.Varint(3, v)
+	case float32:
+		any.Double(4, float64(v))
+	case float64:
+		any.Double(4, v)
+	default:
+		any.String(1, fmt.Sprintf("%v", v))
+	}
+
+	var kv ProtoBuf
+	kv.String(1, key)
+	kv.Message(2, any.B)
+	p.Message(field, kv.B)
+}
+
+//------------------------------------------------------------------------------
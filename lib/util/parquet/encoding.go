@@ -0,0 +1,261 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/bits"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+//------------------------------------------------------------------------------
+
+// Compression codecs.
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+)
+
+// Encodings.
+const (
+	encPlain         = 0
+	encPlainDict     = 2
+	encRLE           = 3
+	encRLEDictionary = 8
+)
+
+var errTruncated = errors.New("page data is truncated")
+
+func decompress(codec int, b []byte, size int) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return b, nil
+	case codecSnappy:
+		return snappy.Decode(make([]byte, 0, size), b)
+	case codecGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case codecZstd:
+		r, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return r.DecodeAll(b, make([]byte, 0, size))
+	}
+	return nil, fmt.Errorf("unsupported compression codec: %v", codec)
+}
+
+//------------------------------------------------------------------------------
+
+// decodeHybrid decodes n values of the RLE/bit-packed hybrid encoding with a
+// given bit width.
+func decodeHybrid(b []byte, bitWidth, n int) ([]int32, error) {
+	if bitWidth == 0 {
+		return make([]int32, n), nil
+	}
+	if bitWidth > 32 {
+		return nil, fmt.Errorf("invalid bit width: %v", bitWidth)
+	}
+	byteWidth := (bitWidth + 7) / 8
+	vals := make([]int32, 0, n)
+	pos := 0
+	for len(vals) < n {
+		header, l := binary.Uvarint(b[pos:])
+		if l <= 0 {
+			return nil, errTruncated
+		}
+		pos += l
+		if header&1 == 0 {
+			count := int(header >> 1)
+			if pos+byteWidth > len(b) {
+				return nil, errTruncated
+			}
+			var v uint32
+			for i := 0; i < byteWidth; i++ {
+				v |= uint32(b[pos+i]) << (8 * uint(i))
+			}
+			pos += byteWidth
+			for i := 0; i < count && len(vals) < n; i++ {
+				vals = append(vals, int32(v))
+			}
+			continue
+		}
+		count := int(header>>1) * 8
+		size := int(header>>1) * bitWidth
+		if pos+size > len(b) {
+			return nil, errTruncated
+		}
+		packed := b[pos : pos+size]
+		pos += size
+		for i := 0; i < count && len(vals) < n; i++ {
+			var v uint32
+			for j := 0; j < bitWidth; j++ {
+				bit := i*bitWidth + j
+				v |= uint32(packed[bit/8]>>(uint(bit)%8)&1) << uint(j)
+			}
+			vals = append(vals, int32(v))
+		}
+	}
+	return vals, nil
+}
+
+// readLevels decodes n definition or repetition levels of a v1 data page,
+// returning the levels and the remaining page data.
+func readLevels(b []byte, maxLevel, n int) ([]int32, []byte, error) {
+	if maxLevel == 0 {
+		return make([]int32, n), b, nil
+	}
+	if len(b) < 4 {
+		return nil, nil, errTruncated
+	}
+	l := int(binary.LittleEndian.Uint32(b))
+	if 4+l > len(b) {
+		return nil, nil, errTruncated
+	}
+	levels, err := decodeHybrid(b[4:4+l], bits.Len(uint(maxLevel)), n)
+	return levels, b[4+l:], err
+}
+
+//------------------------------------------------------------------------------
+
+// decodePlain decodes n values of a physical type with the PLAIN encoding.
+func decodePlain(b []byte, physical, typeLength, n int) ([]interface{}, error) {
+	vals := make([]interface{}, 0, n)
+	width := 0
+	switch physical {
+	case typeBoolean:
+		if (n+7)/8 > len(b) {
+			return nil, errTruncated
+		}
+		for i := 0; i < n; i++ {
+			vals = append(vals, b[i/8]>>(uint(i)%8)&1 == 1)
+		}
+		return vals, nil
+	case typeInt32, typeFloat:
+		width = 4
+	case typeInt64, typeDouble:
+		width = 8
+	case typeInt96:
+		width = 12
+	case typeFixedLenByteArray:
+		width = typeLength
+	case typeByteArray:
+		pos := 0
+		for i := 0; i < n; i++ {
+			if pos+4 > len(b) {
+				return nil, errTruncated
+			}
+			l := int(binary.LittleEndian.Uint32(b[pos:]))
+			pos += 4
+			if l < 0 || pos+l > len(b) {
+				return nil, errTruncated
+			}
+			vals = append(vals, b[pos:pos+l])
+			pos += l
+		}
+		return vals, nil
+	default:
+		return nil, fmt.Errorf("unsupported physical type: %v", physical)
+	}
+
+	if width*n > len(b) {
+		return nil, errTruncated
+	}
+	for i := 0; i < n; i++ {
+		v := b[i*width : (i+1)*width]
+		switch physical {
+		case typeInt32:
+			vals = append(vals, int32(binary.LittleEndian.Uint32(v)))
+		case typeInt64:
+			vals = append(vals, int64(binary.LittleEndian.Uint64(v)))
+		case typeFloat:
+			vals = append(vals, math.Float32frombits(binary.LittleEndian.Uint32(v)))
+		case typeDouble:
+			vals = append(vals, math.Float64frombits(binary.LittleEndian.Uint64(v)))
+		default:
+			vals = append(vals, v)
+		}
+	}
+	return vals, nil
+}
+
+// decodeValues decodes n non-null values of a data page.
+func decodeValues(b []byte, encoding int, col *node, dict []interface{}, n int) ([]interface{}, error) {
+	switch encoding {
+	case encPlain:
+		return decodePlain(b, col.physical, col.typeLength, n)
+	case encPlainDict, encRLEDictionary:
+		if dict == nil {
+			return nil, errors.New("dictionary encoded page without a dictionary")
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		if len(b) == 0 {
+			return nil, errTruncated
+		}
+		indexes, err := decodeHybrid(b[1:], int(b[0]), n)
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]interface{}, n)
+		for i, index := range indexes {
+			if index < 0 || int(index) >= len(dict) {
+				return nil, fmt.Errorf("dictionary index out of range: %v", index)
+			}
+			vals[i] = dict[index]
+		}
+		return vals, nil
+	case encRLE:
+		if col.physical != typeBoolean {
+			break
+		}
+		if len(b) < 4 {
+			return nil, errTruncated
+		}
+		bools, err := decodeHybrid(b[4:], 1, n)
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]interface{}, n)
+		for i, v := range bools {
+			vals[i] = v == 1
+		}
+		return vals, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding: %v", encoding)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,438 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+//------------------------------------------------------------------------------
+
+var magic = []byte("PAR1")
+
+// Page types.
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// Reader reads the rows of a Parquet file.
+type Reader struct {
+	r         io.ReaderAt
+	root      *node
+	columns   []*node
+	rowGroups []tStruct
+	numRows   int64
+
+	group int
+	rows  []map[string]interface{}
+}
+
+// NewReader parses the footer of a Parquet file of a given size.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < int64(len(magic)*2+4) {
+		return nil, errors.New("file is too small to be parquet")
+	}
+
+	tail := make([]byte, 8)
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return nil, fmt.Errorf("failed to read footer: %v", err)
+	}
+	if !bytes.Equal(tail[4:], magic) {
+		return nil, errors.New("file does not end with parquet magic bytes")
+	}
+	metaLen := int64(binary.LittleEndian.Uint32(tail))
+	if metaLen > size-int64(len(magic))-8 {
+		return nil, errors.New("footer length exceeds file size")
+	}
+
+	metaBytes := make([]byte, metaLen)
+	if _, err := r.ReadAt(metaBytes, size-8-metaLen); err != nil {
+		return nil, fmt.Errorf("failed to read footer: %v", err)
+	}
+	meta, _, err := readThrift(metaBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file metadata: %v", err)
+	}
+
+	rdr := &Reader{
+		r:       r,
+		numRows: meta.i64(3),
+	}
+	if rdr.root, rdr.columns, err = parseSchema(meta.list(2)); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %v", err)
+	}
+	for _, g := range meta.list(4) {
+		group, _ := g.(tStruct)
+		if group == nil {
+			return nil, errors.New("row group metadata is malformed")
+		}
+		if len(group.list(1)) != len(rdr.columns) {
+			return nil, fmt.Errorf("row group has %v columns but schema has %v", len(group.list(1)), len(rdr.columns))
+		}
+		rdr.rowGroups = append(rdr.rowGroups, group)
+	}
+	return rdr, nil
+}
+
+// NewReaderFromBytes parses a Parquet file held in memory.
+func NewReaderFromBytes(b []byte) (*Reader, error) {
+	return NewReader(bytes.NewReader(b), int64(len(b)))
+}
+
+// NumRows returns the total number of rows within the file.
+func (r *Reader) NumRows() int64 {
+	return r.numRows
+}
+
+// Next returns the next row of the file, or io.EOF once all rows have been
+// read.
+func (r *Reader) Next() (map[string]interface{}, error) {
+	for len(r.rows) == 0 {
+		if r.group >= len(r.rowGroups) {
+			return nil, io.EOF
+		}
+		rows, err := r.readRowGroup(r.rowGroups[r.group])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row group %v: %v", r.group, err)
+		}
+		r.group++
+		r.rows = rows
+	}
+	row := r.rows[0]
+	r.rows[0] = nil
+	r.rows = r.rows[1:]
+	return row, nil
+}
+
+//------------------------------------------------------------------------------
+
+// columnData contains the levels and values of a column chunk.
+type columnData struct {
+	reps, defs []int32
+	vals       []interface{}
+}
+
+func (r *Reader) readRowGroup(group tStruct) ([]map[string]interface{}, error) {
+	numRows := group.int(3)
+	rows := make([]map[string]interface{}, numRows)
+	for i := range rows {
+		rows[i] = map[string]interface{}{}
+	}
+
+	for i, c := range group.list(1) {
+		chunk, _ := c.(tStruct)
+		col := r.columns[i]
+		data, err := r.readColumnChunk(chunk.structField(3), col)
+		if err != nil {
+			return nil, fmt.Errorf("column %v: %v", col.name, err)
+		}
+		if err = assemble(rows, col, data); err != nil {
+			return nil, fmt.Errorf("column %v: %v", col.name, err)
+		}
+	}
+
+	for i, row := range rows {
+		rows[i] = shapeGroup(r.root, row)
+	}
+	return rows, nil
+}
+
+func (r *Reader) readColumnChunk(meta tStruct, col *node) (*columnData, error) {
+	if meta == nil {
+		return nil, errors.New("column chunk metadata is missing")
+	}
+	offset := meta.i64(9)
+	if meta.has(11) && meta.i64(11) > 0 && meta.i64(11) < offset {
+		offset = meta.i64(11)
+	}
+	size := meta.i64(7)
+	if size < 0 || offset < 0 {
+		return nil, errors.New("column chunk has an invalid size")
+	}
+	b := make([]byte, size)
+	if _, err := r.r.ReadAt(b, offset); err != nil {
+		return nil, fmt.Errorf("failed to read column chunk: %v", err)
+	}
+
+	codec := meta.int(4)
+	numValues := meta.i64(5)
+	data := &columnData{}
+	var dict []interface{}
+
+	for int64(len(data.defs)) < numValues {
+		if len(b) == 0 {
+			return nil, errTruncated
+		}
+		header, n, err := readThrift(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse page header: %v", err)
+		}
+		b = b[n:]
+		compressedSize := header.int(3)
+		if compressedSize < 0 || compressedSize > len(b) {
+			return nil, errTruncated
+		}
+		page := b[:compressedSize]
+		b = b[compressedSize:]
+		uncompressedSize := header.int(2)
+
+		switch header.int(1) {
+		case pageDictionary:
+			if page, err = decompress(codec, page, uncompressedSize); err != nil {
+				return nil, fmt.Errorf("failed to decompress page: %v", err)
+			}
+			dh := header.structField(7)
+			if dict, err = decodePlain(page, col.physical, col.typeLength, dh.int(1)); err != nil {
+				return nil, fmt.Errorf("failed to decode dictionary: %v", err)
+			}
+		case pageData:
+			if page, err = decompress(codec, page, uncompressedSize); err != nil {
+				return nil, fmt.Errorf("failed to decompress page: %v", err)
+			}
+			dh := header.structField(5)
+			count := dh.int(1)
+			var reps, defs []int32
+			if reps, page, err = readLevels(page, col.maxRep, count); err != nil {
+				return nil, fmt.Errorf("failed to decode repetition levels: %v", err)
+			}
+			if defs, page, err = readLevels(page, col.maxDef, count); err != nil {
+				return nil, fmt.Errorf("failed to decode definition levels: %v", err)
+			}
+			if err = data.appendPage(reps, defs, page, dh.int(2), col, dict); err != nil {
+				return nil, err
+			}
+		case pageDataV2:
+			dh := header.structField(8)
+			count := dh.int(1)
+			repLen, defLen := dh.int(6), dh.int(5)
+			if repLen < 0 || defLen < 0 || repLen+defLen > len(page) {
+				return nil, errTruncated
+			}
+			var reps, defs []int32
+			if reps, err = decodeV2Levels(page[:repLen], col.maxRep, count); err != nil {
+				return nil, fmt.Errorf("failed to decode repetition levels: %v", err)
+			}
+			if defs, err = decodeV2Levels(page[repLen:repLen+defLen], col.maxDef, count); err != nil {
+				return nil, fmt.Errorf("failed to decode definition levels: %v", err)
+			}
+			values := page[repLen+defLen:]
+			if !dh.has(7) || dh.bool(7) {
+				if values, err = decompress(codec, values, uncompressedSize-repLen-defLen); err != nil {
+					return nil, fmt.Errorf("failed to decompress page: %v", err)
+				}
+			}
+			if err = data.appendPage(reps, defs, values, dh.int(4), col, dict); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
+
+func decodeV2Levels(b []byte, maxLevel, n int) ([]int32, error) {
+	if maxLevel == 0 {
+		return make([]int32, n), nil
+	}
+	return decodeHybrid(b, bits.Len(uint(maxLevel)), n)
+}
+
+func (d *columnData) appendPage(reps, defs []int32, b []byte, encoding int, col *node, dict []interface{}) error {
+	nonNull := 0
+	for _, def := range defs {
+		if int(def) == col.maxDef {
+			nonNull++
+		}
+	}
+	vals, err := decodeValues(b, encoding, col, dict, nonNull)
+	if err != nil {
+		return fmt.Errorf("failed to decode values: %v", err)
+	}
+	for i, v := range vals {
+		vals[i] = col.convert(v)
+	}
+	d.reps = append(d.reps, reps...)
+	d.defs = append(d.defs, defs...)
+	d.vals = append(d.vals, vals...)
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// assemble writes the values of a column into the rows of a row group. Each
+// repeated field along the path of the column becomes an array, where the
+// index of each element is tracked from the repetition levels so that columns
+// sharing a repeated ancestor write into the same elements.
+func assemble(rows []map[string]interface{}, col *node, data *columnData) error {
+	indexes := make([]int, col.maxRep+1)
+	row, valIndex := -1, 0
+
+	for i, def := range data.defs {
+		rep := int(data.reps[i])
+		if rep == 0 {
+			row++
+			for j := range indexes {
+				indexes[j] = 0
+			}
+		} else {
+			indexes[rep]++
+			for j := rep + 1; j < len(indexes); j++ {
+				indexes[j] = 0
+			}
+		}
+		if row >= len(rows) {
+			return errors.New("column contains more rows than its row group")
+		}
+		if row < 0 {
+			return errors.New("column does not begin with a new row")
+		}
+
+		var value interface{}
+		if int(def) == col.maxDef {
+			value = data.vals[valIndex]
+			valIndex++
+		}
+
+		var cur interface{} = rows[row]
+		setDef, setRep := 0, 0
+		for _, n := range col.path {
+			if n.repetition != repRequired {
+				setDef++
+			}
+			obj := cur.(map[string]interface{})
+			if n.repetition == repRepeated {
+				setRep++
+				arr, _ := obj[n.name].([]interface{})
+				if int(def) < setDef {
+					if arr == nil {
+						obj[n.name] = []interface{}{}
+					}
+					break
+				}
+				for len(arr) <= indexes[setRep] {
+					var elem interface{}
+					if !n.isLeaf() {
+						elem = map[string]interface{}{}
+					}
+					arr = append(arr, elem)
+				}
+				obj[n.name] = arr
+				if n.isLeaf() {
+					arr[indexes[setRep]] = value
+				} else {
+					cur = arr[indexes[setRep]]
+				}
+				continue
+			}
+			if int(def) < setDef {
+				if _, exists := obj[n.name]; !exists {
+					obj[n.name] = nil
+				}
+				break
+			}
+			if n.isLeaf() {
+				obj[n.name] = value
+				continue
+			}
+			child, _ := obj[n.name].(map[string]interface{})
+			if child == nil {
+				child = map[string]interface{}{}
+				obj[n.name] = child
+			}
+			cur = child
+		}
+	}
+	if row != len(rows)-1 {
+		return fmt.Errorf("column contains %v rows but its row group has %v", row+1, len(rows))
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// shapeGroup converts an assembled group according to the LIST and MAP logical
+// types of its fields.
+func shapeGroup(n *node, obj map[string]interface{}) map[string]interface{} {
+	for _, child := range n.children {
+		if v, exists := obj[child.name]; exists {
+			obj[child.name] = shapeField(child, v)
+		}
+	}
+	return obj
+}
+
+func shapeField(n *node, v interface{}) interface{} {
+	if v == nil || n.isLeaf() {
+		return v
+	}
+	if arr, ok := v.([]interface{}); ok {
+		for i, elem := range arr {
+			arr[i] = shapeElement(n, elem)
+		}
+		return arr
+	}
+	return shapeElement(n, v)
+}
+
+func shapeElement(n *node, v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok || n.isLeaf() {
+		return v
+	}
+	if len(n.children) == 1 && n.children[0].repetition == repRepeated {
+		inner := n.children[0]
+		switch {
+		case n.isList():
+			arr, _ := obj[inner.name].([]interface{})
+			list := make([]interface{}, 0, len(arr))
+			for _, elem := range arr {
+				if elemObj, isObj := elem.(map[string]interface{}); isObj && len(inner.children) == 1 {
+					// Three level lists wrap each element within a group.
+					list = append(list, shapeField(inner.children[0], elemObj[inner.children[0].name]))
+				} else {
+					list = append(list, shapeElement(inner, elem))
+				}
+			}
+			return list
+		case n.isMap() && len(inner.children) == 2:
+			arr, _ := obj[inner.name].([]interface{})
+			m := make(map[string]interface{}, len(arr))
+			for _, elem := range arr {
+				kv, _ := elem.(map[string]interface{})
+				if kv == nil {
+					continue
+				}
+				key := kv[inner.children[0].name]
+				m[fmt.Sprintf("%v", key)] = shapeField(inner.children[1], kv[inner.children[1].name])
+			}
+			return m
+		}
+	}
+	return shapeGroup(n, obj)
+}
+
+//------------------------------------------------------------------------------
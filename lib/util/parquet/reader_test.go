@@ -0,0 +1,452 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+//------------------------------------------------------------------------------
+
+// tField is a field of a thrift struct to be encoded by the test writer.
+type tField struct {
+	id int16
+	v  interface{}
+}
+
+type tStructList [][]tField
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendZigzag(b []byte, v int64) []byte {
+	return appendUvarint(b, uint64((v<<1)^(v>>63)))
+}
+
+func encodeThrift(b []byte, fields []tField) []byte {
+	var last int16
+	for _, f := range fields {
+		var typ byte
+		var body []byte
+		switch t := f.v.(type) {
+		case bool:
+			typ = tFalse
+			if t {
+				typ = tTrue
+			}
+		case int32:
+			typ, body = tI32, appendZigzag(nil, int64(t))
+		case int64:
+			typ, body = tI64, appendZigzag(nil, t)
+		case string:
+			typ, body = tBinary, append(appendUvarint(nil, uint64(len(t))), t...)
+		case []tField:
+			typ, body = tStructT, encodeThrift(nil, t)
+		case []int32:
+			typ, body = tList, []byte{byte(len(t))<<4 | tI32}
+			for _, v := range t {
+				body = appendZigzag(body, int64(v))
+			}
+		case []string:
+			typ, body = tList, []byte{byte(len(t))<<4 | tBinary}
+			for _, v := range t {
+				body = append(appendUvarint(body, uint64(len(v))), v...)
+			}
+		case tStructList:
+			typ = tList
+			if len(t) < 15 {
+				body = []byte{byte(len(t))<<4 | tStructT}
+			} else {
+				body = appendUvarint([]byte{0xf0 | tStructT}, uint64(len(t)))
+			}
+			for _, v := range t {
+				body = encodeThrift(body, v)
+			}
+		}
+		b = append(b, byte(f.id-last)<<4|typ)
+		b = append(b, body...)
+		last = f.id
+	}
+	return append(b, tStop)
+}
+
+//------------------------------------------------------------------------------
+
+// testColumn describes the contents of a column chunk to be written.
+type testColumn struct {
+	path      []string
+	physical  int32
+	maxDef    int
+	maxRep    int
+	reps      []int32
+	defs      []int32
+	values    []byte
+	encoding  int32
+	dict      []byte
+	dictCount int
+	codec     int32
+	v2        bool
+}
+
+func bitWidth(maxLevel int) int {
+	w := 0
+	for ; maxLevel > 0; maxLevel >>= 1 {
+		w++
+	}
+	return w
+}
+
+// encodeLevels encodes levels as bit-packed runs of the hybrid encoding.
+func encodeLevels(levels []int32, width int) []byte {
+	groups := (len(levels) + 7) / 8
+	b := appendUvarint(nil, uint64(groups<<1|1))
+	packed := make([]byte, groups*width)
+	for i, l := range levels {
+		for j := 0; j < width; j++ {
+			bit := i*width + j
+			packed[bit/8] |= byte((l>>uint(j))&1) << (uint(bit) % 8)
+		}
+	}
+	return append(b, packed...)
+}
+
+func prefixLen(b []byte) []byte {
+	l := make([]byte, 4)
+	binary.LittleEndian.PutUint32(l, uint32(len(b)))
+	return append(l, b...)
+}
+
+func compressTest(codec int32, b []byte) []byte {
+	if codec == codecSnappy {
+		return snappy.Encode(nil, b)
+	}
+	return b
+}
+
+func writeTestFile(schema tStructList, numRows int64, cols []testColumn) []byte {
+	b := append([]byte{}, magic...)
+	var chunks tStructList
+	for _, c := range cols {
+		start := int64(len(b))
+		var dictOffset int64
+		if c.dict != nil {
+			dictOffset = start
+			page := compressTest(c.codec, c.dict)
+			b = encodeThrift(b, []tField{
+				{1, int32(pageDictionary)},
+				{2, int32(len(c.dict))},
+				{3, int32(len(page))},
+				{7, []tField{{1, int32(c.dictCount)}, {2, int32(encPlain)}}},
+			})
+			b = append(b, page...)
+		}
+		dataOffset := int64(len(b))
+
+		var reps, defs []byte
+		if c.maxRep > 0 {
+			reps = encodeLevels(c.reps, bitWidth(c.maxRep))
+		}
+		if c.maxDef > 0 {
+			defs = encodeLevels(c.defs, bitWidth(c.maxDef))
+		}
+		if c.v2 {
+			values := compressTest(c.codec, c.values)
+			b = encodeThrift(b, []tField{
+				{1, int32(pageDataV2)},
+				{2, int32(len(reps) + len(defs) + len(c.values))},
+				{3, int32(len(reps) + len(defs) + len(values))},
+				{8, []tField{
+					{1, int32(len(c.defs))},
+					{2, int32(0)},
+					{3, int32(numRows)},
+					{4, c.encoding},
+					{5, int32(len(defs))},
+					{6, int32(len(reps))},
+				}},
+			})
+			b = append(b, reps...)
+			b = append(b, defs...)
+			b = append(b, values...)
+		} else {
+			var raw []byte
+			if reps != nil {
+				raw = append(raw, prefixLen(reps)...)
+			}
+			if defs != nil {
+				raw = append(raw, prefixLen(defs)...)
+			}
+			raw = append(raw, c.values...)
+			page := compressTest(c.codec, raw)
+			b = encodeThrift(b, []tField{
+				{1, int32(pageData)},
+				{2, int32(len(raw))},
+				{3, int32(len(page))},
+				{5, []tField{
+					{1, int32(len(c.defs))},
+					{2, c.encoding},
+					{3, int32(encRLE)},
+					{4, int32(encRLE)},
+				}},
+			})
+			b = append(b, page...)
+		}
+
+		meta := []tField{
+			{1, c.physical},
+			{2, []int32{c.encoding}},
+			{3, c.path},
+			{4, c.codec},
+			{5, int64(len(c.defs))},
+			{6, int64(len(b)) - start},
+			{7, int64(len(b)) - start},
+			{9, dataOffset},
+		}
+		if c.dict != nil {
+			meta = append(meta, tField{11, dictOffset})
+		}
+		chunks = append(chunks, []tField{{2, start}, {3, meta}})
+	}
+
+	footer := encodeThrift(nil, []tField{
+		{1, int32(1)},
+		{2, schema},
+		{3, numRows},
+		{4, tStructList{{{1, chunks}, {2, int64(len(b))}, {3, numRows}}}},
+	})
+	b = append(b, footer...)
+	lenBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(lenBytes, uint32(len(footer)))
+	b = append(b, lenBytes...)
+	return append(b, magic...)
+}
+
+func plainInt32(vals ...int32) []byte {
+	var b []byte
+	for _, v := range vals {
+		b = append(b, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(b[len(b)-4:], uint32(v))
+	}
+	return b
+}
+
+func plainInt64(vals ...int64) []byte {
+	var b []byte
+	for _, v := range vals {
+		b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(b[len(b)-8:], uint64(v))
+	}
+	return b
+}
+
+func plainDouble(vals ...float64) []byte {
+	var b []byte
+	for _, v := range vals {
+		b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(b[len(b)-8:], math.Float64bits(v))
+	}
+	return b
+}
+
+func plainStrings(vals ...string) []byte {
+	var b []byte
+	for _, v := range vals {
+		b = append(b, prefixLen([]byte(v))...)
+	}
+	return b
+}
+
+func readAllRows(t *testing.T, file []byte) string {
+	t.Helper()
+	r, err := NewReaderFromBytes(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []interface{}
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if int64(len(rows)) != r.NumRows() {
+		t.Errorf("Wrong count of rows: %v != %v", len(rows), r.NumRows())
+	}
+	b, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+//------------------------------------------------------------------------------
+
+func TestReaderFlat(t *testing.T) {
+	schema := tStructList{
+		{{4, "schema"}, {5, int32(7)}},
+		{{1, int32(typeInt64)}, {3, int32(repRequired)}, {4, "id"}},
+		{{1, int32(typeByteArray)}, {3, int32(repOptional)}, {4, "name"}, {6, int32(convUTF8)}},
+		{{1, int32(typeInt32)}, {3, int32(repRequired)}, {4, "price"}, {6, int32(convDecimal)}, {7, int32(2)}, {8, int32(9)}},
+		{{1, int32(typeInt32)}, {3, int32(repRequired)}, {4, "day"}, {6, int32(convDate)}},
+		{{1, int32(typeInt64)}, {3, int32(repOptional)}, {4, "ts"}, {10, []tField{{8, []tField{{1, true}, {2, []tField{{1, []tField{}}}}}}}}},
+		{{1, int32(typeBoolean)}, {3, int32(repRequired)}, {4, "flag"}},
+		{{1, int32(typeDouble)}, {3, int32(repRequired)}, {4, "score"}},
+	}
+
+	file := writeTestFile(schema, 3, []testColumn{
+		{
+			path: []string{"id"}, physical: typeInt64,
+			reps: []int32{0, 0, 0}, defs: []int32{0, 0, 0},
+			values: plainInt64(1, 2, 3), encoding: encPlain, codec: codecSnappy,
+		},
+		{
+			path: []string{"name"}, physical: typeByteArray, maxDef: 1,
+			reps: []int32{0, 0, 0}, defs: []int32{1, 0, 1},
+			dict: plainStrings("foo", "bar"), dictCount: 2,
+			// Bit width 1 followed by a run of 2 bit-packed indexes.
+			values: []byte{1, 3, 0x01}, encoding: encRLEDictionary,
+		},
+		{
+			path: []string{"price"}, physical: typeInt32,
+			reps: []int32{0, 0, 0}, defs: []int32{0, 0, 0},
+			values: plainInt32(1050, -5, 0), encoding: encPlain, v2: true, codec: codecSnappy,
+		},
+		{
+			path: []string{"day"}, physical: typeInt32,
+			reps: []int32{0, 0, 0}, defs: []int32{0, 0, 0},
+			values: plainInt32(0, 18628, 1), encoding: encPlain,
+		},
+		{
+			path: []string{"ts"}, physical: typeInt64, maxDef: 1,
+			reps: []int32{0, 0, 0}, defs: []int32{0, 1, 0},
+			values: plainInt64(1609459200123), encoding: encPlain, v2: true,
+		},
+		{
+			path: []string{"flag"}, physical: typeBoolean,
+			reps: []int32{0, 0, 0}, defs: []int32{0, 0, 0},
+			values: []byte{0x05}, encoding: encPlain,
+		},
+		{
+			path: []string{"score"}, physical: typeDouble,
+			reps: []int32{0, 0, 0}, defs: []int32{0, 0, 0},
+			values: plainDouble(0.5, 1, -2.25), encoding: encPlain,
+		},
+	})
+
+	exp := `[` +
+		`{"day":"1970-01-01","flag":true,"id":1,"name":"bar","price":10.50,"score":0.5,"ts":null},` +
+		`{"day":"2021-01-01","flag":false,"id":2,"name":null,"price":-0.05,"score":1,"ts":"2021-01-01T00:00:00.123Z"},` +
+		`{"day":"1970-01-02","flag":true,"id":3,"name":"foo","price":0.00,"score":-2.25,"ts":null}` +
+		`]`
+	if act := readAllRows(t, file); act != exp {
+		t.Errorf("Wrong rows:\n%v\n!=\n%v", act, exp)
+	}
+}
+
+func TestReaderNested(t *testing.T) {
+	schema := tStructList{
+		{{4, "schema"}, {5, int32(3)}},
+		{{3, int32(repOptional)}, {4, "tags"}, {5, int32(1)}, {6, int32(convList)}},
+		{{3, int32(repRepeated)}, {4, "list"}, {5, int32(1)}},
+		{{1, int32(typeByteArray)}, {3, int32(repOptional)}, {4, "element"}, {6, int32(convUTF8)}},
+		{{3, int32(repRequired)}, {4, "loc"}, {5, int32(2)}},
+		{{1, int32(typeDouble)}, {3, int32(repRequired)}, {4, "lat"}},
+		{{1, int32(typeInt32)}, {3, int32(repOptional)}, {4, "n"}},
+		{{3, int32(repOptional)}, {4, "attrs"}, {5, int32(1)}, {6, int32(convMap)}},
+		{{3, int32(repRepeated)}, {4, "key_value"}, {5, int32(2)}},
+		{{1, int32(typeByteArray)}, {3, int32(repRequired)}, {4, "key"}, {6, int32(convUTF8)}},
+		{{1, int32(typeInt32)}, {3, int32(repOptional)}, {4, "value"}},
+	}
+
+	file := writeTestFile(schema, 4, []testColumn{
+		{
+			path: []string{"tags", "list", "element"}, physical: typeByteArray, maxDef: 3, maxRep: 1,
+			reps: []int32{0, 1, 0, 0, 0}, defs: []int32{3, 3, 0, 1, 2},
+			values: plainStrings("a", "b"), encoding: encPlain,
+		},
+		{
+			path: []string{"loc", "lat"}, physical: typeDouble,
+			reps: []int32{0, 0, 0, 0}, defs: []int32{0, 0, 0, 0},
+			values: plainDouble(1.5, 2, 3, 4), encoding: encPlain,
+		},
+		{
+			path: []string{"loc", "n"}, physical: typeInt32, maxDef: 1,
+			reps: []int32{0, 0, 0, 0}, defs: []int32{1, 0, 1, 0},
+			values: plainInt32(3, 5), encoding: encPlain, v2: true,
+		},
+		{
+			path: []string{"attrs", "key_value", "key"}, physical: typeByteArray, maxDef: 2, maxRep: 1,
+			reps: []int32{0, 1, 0, 0, 0}, defs: []int32{2, 2, 1, 0, 2},
+			values: plainStrings("x", "y", "z"), encoding: encPlain,
+		},
+		{
+			path: []string{"attrs", "key_value", "value"}, physical: typeInt32, maxDef: 3, maxRep: 1,
+			reps: []int32{0, 1, 0, 0, 0}, defs: []int32{3, 2, 1, 0, 3},
+			values: plainInt32(1, 7), encoding: encPlain,
+		},
+	})
+
+	exp := `[` +
+		`{"attrs":{"x":1,"y":null},"loc":{"lat":1.5,"n":3},"tags":["a","b"]},` +
+		`{"attrs":{},"loc":{"lat":2,"n":null},"tags":null},` +
+		`{"attrs":null,"loc":{"lat":3,"n":5},"tags":[]},` +
+		`{"attrs":{"z":7},"loc":{"lat":4,"n":null},"tags":[null]}` +
+		`]`
+	if act := readAllRows(t, file); act != exp {
+		t.Errorf("Wrong rows:\n%v\n!=\n%v", act, exp)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	if _, err := NewReaderFromBytes([]byte("not a parquet file")); err == nil {
+		t.Error("Expected error from bad file")
+	}
+
+	schema := tStructList{
+		{{4, "schema"}, {5, int32(1)}},
+		{{1, int32(typeInt64)}, {3, int32(repRequired)}, {4, "id"}},
+	}
+	file := writeTestFile(schema, 2, []testColumn{{
+		path: []string{"id"}, physical: typeInt64,
+		reps: []int32{0, 0}, defs: []int32{0, 0},
+		values: plainInt64(1), encoding: encPlain,
+	}})
+	r, err := NewReaderFromBytes(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Next(); err == nil {
+		t.Error("Expected error from truncated values")
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,360 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// Physical types.
+const (
+	typeBoolean           = 0
+	typeInt32             = 1
+	typeInt64             = 2
+	typeInt96             = 3
+	typeFloat             = 4
+	typeDouble            = 5
+	typeByteArray         = 6
+	typeFixedLenByteArray = 7
+)
+
+// Field repetition types.
+const (
+	repRequired = 0
+	repOptional = 1
+	repRepeated = 2
+)
+
+// Converted types, which are the legacy form of logical types.
+const (
+	convNone            = -1
+	convUTF8            = 0
+	convMap             = 1
+	convMapKeyValue     = 2
+	convList            = 3
+	convEnum            = 4
+	convDecimal         = 5
+	convDate            = 6
+	convTimeMillis      = 7
+	convTimeMicros      = 8
+	convTimestampMillis = 9
+	convTimestampMicros = 10
+	convUint8           = 11
+	convUint16          = 12
+	convUint32          = 13
+	convUint64          = 14
+	convJSON            = 19
+)
+
+// Kinds of value conversion derived from the logical or converted type of a
+// column.
+const (
+	kindRaw = iota
+	kindString
+	kindDecimal
+	kindDate
+	kindTime
+	kindTimestamp
+	kindUnsigned
+	kindUUID
+	kindJSON
+)
+
+//------------------------------------------------------------------------------
+
+// node is an element of a schema tree.
+type node struct {
+	name       string
+	physical   int
+	typeLength int
+	repetition int
+	converted  int
+	logical    tStruct
+	children   []*node
+
+	// Set for leaf nodes only.
+	path   []*node
+	maxDef int
+	maxRep int
+	kind   int
+	scale  int
+	unit   time.Duration
+}
+
+func (n *node) isLeaf() bool {
+	return n.children == nil
+}
+
+func (n *node) isList() bool {
+	return n.converted == convList || n.logical.has(3)
+}
+
+func (n *node) isMap() bool {
+	return n.converted == convMap || n.converted == convMapKeyValue || n.logical.has(2)
+}
+
+// parseSchema builds a schema tree from a flattened list of schema elements,
+// returning the root node and the leaf columns in order.
+func parseSchema(elements []interface{}) (*node, []*node, error) {
+	var leaves []*node
+	pos := 0
+
+	var build func(parents []*node, def, rep int) (*node, error)
+	build = func(parents []*node, def, rep int) (*node, error) {
+		if pos >= len(elements) {
+			return nil, errors.New("schema is truncated")
+		}
+		e, _ := elements[pos].(tStruct)
+		if e == nil {
+			return nil, errors.New("schema element is malformed")
+		}
+		pos++
+
+		n := &node{
+			name:       e.str(4),
+			physical:   -1,
+			typeLength: e.int(2),
+			repetition: e.int(3),
+			converted:  convNone,
+			logical:    e.structField(10),
+		}
+		if e.has(6) {
+			n.converted = e.int(6)
+		}
+		if len(parents) > 0 {
+			switch n.repetition {
+			case repOptional:
+				def++
+			case repRepeated:
+				def++
+				rep++
+			}
+		}
+
+		numChildren := e.int(5)
+		if numChildren == 0 && e.has(1) {
+			n.physical = e.int(1)
+			n.path = append(append([]*node{}, parents[1:]...), n)
+			n.maxDef, n.maxRep = def, rep
+			if err := n.resolveKind(e); err != nil {
+				return nil, fmt.Errorf("column %v: %v", n.name, err)
+			}
+			leaves = append(leaves, n)
+			return n, nil
+		}
+
+		n.children = []*node{}
+		childParents := append(append([]*node{}, parents...), n)
+		for i := 0; i < numChildren; i++ {
+			child, err := build(childParents, def, rep)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+		return n, nil
+	}
+
+	root, err := build(nil, 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pos != len(elements) {
+		return nil, nil, errors.New("schema contains unreachable elements")
+	}
+	return root, leaves, nil
+}
+
+// resolveKind determines how the values of a leaf column are converted from
+// its logical type, falling back to its converted type.
+func (n *node) resolveKind(e tStruct) error {
+	n.kind = kindRaw
+	if l := n.logical; l != nil {
+		switch {
+		case l.has(1), l.has(4):
+			n.kind = kindString
+		case l.has(5):
+			n.kind, n.scale = kindDecimal, l.structField(5).int(1)
+		case l.has(6):
+			n.kind = kindDate
+		case l.has(7):
+			n.kind, n.unit = kindTime, timeUnit(l.structField(7).structField(2))
+		case l.has(8):
+			n.kind, n.unit = kindTimestamp, timeUnit(l.structField(8).structField(2))
+		case l.has(10):
+			if t := l.structField(10); t.has(2) && !t.bool(2) {
+				n.kind = kindUnsigned
+			}
+		case l.has(12):
+			n.kind = kindJSON
+		case l.has(14):
+			n.kind = kindUUID
+		}
+		if n.kind != kindRaw {
+			return nil
+		}
+	}
+	switch n.converted {
+	case convUTF8, convEnum:
+		n.kind = kindString
+	case convJSON:
+		n.kind = kindJSON
+	case convDecimal:
+		n.kind, n.scale = kindDecimal, e.int(7)
+	case convDate:
+		n.kind = kindDate
+	case convTimeMillis:
+		n.kind, n.unit = kindTime, time.Millisecond
+	case convTimeMicros:
+		n.kind, n.unit = kindTime, time.Microsecond
+	case convTimestampMillis:
+		n.kind, n.unit = kindTimestamp, time.Millisecond
+	case convTimestampMicros:
+		n.kind, n.unit = kindTimestamp, time.Microsecond
+	case convUint8, convUint16, convUint32, convUint64:
+		n.kind = kindUnsigned
+	}
+	if n.physical == typeInt96 {
+		n.kind = kindTimestamp
+	}
+	if n.physical == typeFixedLenByteArray && n.typeLength <= 0 {
+		return errors.New("fixed length byte array has no length")
+	}
+	return nil
+}
+
+func timeUnit(u tStruct) time.Duration {
+	switch {
+	case u.has(2):
+		return time.Microsecond
+	case u.has(3):
+		return time.Nanosecond
+	}
+	return time.Millisecond
+}
+
+//------------------------------------------------------------------------------
+
+// convert transforms a raw decoded value of a leaf column into a value that
+// can be marshalled as JSON according to the logical type of the column.
+func (n *node) convert(v interface{}) interface{} {
+	switch n.kind {
+	case kindString:
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+	case kindJSON:
+		if b, ok := v.([]byte); ok {
+			var doc interface{}
+			if err := json.Unmarshal(b, &doc); err == nil {
+				return doc
+			}
+			return string(b)
+		}
+	case kindUUID:
+		if b, ok := v.([]byte); ok && len(b) == 16 {
+			h := hex.EncodeToString(b)
+			return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+		}
+	case kindUnsigned:
+		switch t := v.(type) {
+		case int32:
+			return uint32(t)
+		case int64:
+			return uint64(t)
+		}
+	case kindDate:
+		if d, ok := v.(int32); ok {
+			return time.Unix(int64(d)*86400, 0).UTC().Format("2006-01-02")
+		}
+	case kindTime:
+		var d time.Duration
+		switch t := v.(type) {
+		case int32:
+			d = time.Duration(t) * n.unit
+		case int64:
+			d = time.Duration(t) * n.unit
+		}
+		return time.Unix(0, 0).UTC().Add(d).Format("15:04:05.999999999")
+	case kindTimestamp:
+		switch t := v.(type) {
+		case int64:
+			return time.Unix(0, 0).UTC().Add(time.Duration(t) * n.unit).Format(time.RFC3339Nano)
+		case []byte:
+			if len(t) == 12 {
+				// INT96 timestamps are nanoseconds of the day followed by a
+				// Julian day number.
+				nanos := int64(binary.LittleEndian.Uint64(t[:8]))
+				days := int64(binary.LittleEndian.Uint32(t[8:])) - 2440588
+				return time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano)
+			}
+		}
+	case kindDecimal:
+		var i *big.Int
+		switch t := v.(type) {
+		case int32:
+			i = big.NewInt(int64(t))
+		case int64:
+			i = big.NewInt(t)
+		case []byte:
+			i = new(big.Int).SetBytes(t)
+			if len(t) > 0 && t[0]&0x80 != 0 {
+				// Big-endian two's complement.
+				i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(t)*8)))
+			}
+		}
+		if i != nil {
+			return json.Number(formatDecimal(i, n.scale))
+		}
+	}
+	switch t := v.(type) {
+	case []byte:
+		return string(t)
+	case float32:
+		return float64(t)
+	}
+	return v
+}
+
+func formatDecimal(i *big.Int, scale int) string {
+	s := new(big.Int).Abs(i).String()
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if i.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+//------------------------------------------------------------------------------
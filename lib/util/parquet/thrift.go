@@ -0,0 +1,334 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+//------------------------------------------------------------------------------
+
+// Types of the thrift compact protocol.
+const (
+	tStop    = 0
+	tTrue    = 1
+	tFalse   = 2
+	tByte    = 3
+	tI16     = 4
+	tI32     = 5
+	tI64     = 6
+	tDouble  = 7
+	tBinary  = 8
+	tList    = 9
+	tSet     = 10
+	tMap     = 11
+	tStructT = 12
+)
+
+var errThriftEOF = errors.New("unexpected end of thrift data")
+
+// tStruct is a decoded thrift struct keyed by field identifiers. Integers of
+// all widths are stored as int64, binaries as []byte, lists and sets as
+// []interface{} and nested structs as tStruct.
+type tStruct map[int16]interface{}
+
+func (s tStruct) i64(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s tStruct) int(id int16) int {
+	return int(s.i64(id))
+}
+
+func (s tStruct) has(id int16) bool {
+	_, exists := s[id]
+	return exists
+}
+
+func (s tStruct) str(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s tStruct) bool(id int16) bool {
+	v, _ := s[id].(bool)
+	return v
+}
+
+func (s tStruct) structField(id int16) tStruct {
+	v, _ := s[id].(tStruct)
+	return v
+}
+
+func (s tStruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+//------------------------------------------------------------------------------
+
+// thriftDecoder decodes thrift structs serialised with the compact protocol.
+type thriftDecoder struct {
+	b   []byte
+	pos int
+}
+
+func (d *thriftDecoder) byte() (byte, error) {
+	if d.pos >= len(d.b) {
+		return 0, errThriftEOF
+	}
+	c := d.b[d.pos]
+	d.pos++
+	return c, nil
+}
+
+func (d *thriftDecoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.b[d.pos:])
+	if n <= 0 {
+		return 0, errThriftEOF
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *thriftDecoder) varint() (int64, error) {
+	v, err := d.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (d *thriftDecoder) binary() ([]byte, error) {
+	l, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.b)-d.pos) < l {
+		return nil, errThriftEOF
+	}
+	v := d.b[d.pos : d.pos+int(l)]
+	d.pos += int(l)
+	return v, nil
+}
+
+func (d *thriftDecoder) value(typ byte) (interface{}, error) {
+	switch typ {
+	case tTrue:
+		return true, nil
+	case tFalse:
+		return false, nil
+	case tByte:
+		c, err := d.byte()
+		return int64(int8(c)), err
+	case tI16, tI32, tI64:
+		return d.varint()
+	case tDouble:
+		if len(d.b)-d.pos < 8 {
+			return nil, errThriftEOF
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.b[d.pos:]))
+		d.pos += 8
+		return v, nil
+	case tBinary:
+		return d.binary()
+	case tList, tSet:
+		return d.list()
+	case tMap:
+		return d.mapValue()
+	case tStructT:
+		return d.readStruct()
+	}
+	return nil, fmt.Errorf("unrecognised thrift type: %v", typ)
+}
+
+func (d *thriftDecoder) list() ([]interface{}, error) {
+	header, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	size := uint64(header >> 4)
+	if size == 15 {
+		if size, err = d.uvarint(); err != nil {
+			return nil, err
+		}
+	}
+	if size > uint64(len(d.b)-d.pos) {
+		return nil, errThriftEOF
+	}
+	elemType := header & 0x0f
+	l := make([]interface{}, 0, size)
+	for i := uint64(0); i < size; i++ {
+		var v interface{}
+		if elemType == tTrue || elemType == tFalse {
+			// Booleans within collections are encoded as a byte each.
+			var c byte
+			if c, err = d.byte(); err == nil {
+				v = c == tTrue
+			}
+		} else {
+			v, err = d.value(elemType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+	}
+	return l, nil
+}
+
+func (d *thriftDecoder) mapValue() ([][2]interface{}, error) {
+	size, err := d.uvarint()
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	if size > uint64(len(d.b)-d.pos) {
+		return nil, errThriftEOF
+	}
+	types, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	m := make([][2]interface{}, 0, size)
+	for i := uint64(0); i < size; i++ {
+		var kv [2]interface{}
+		if kv[0], err = d.value(types >> 4); err != nil {
+			return nil, err
+		}
+		if kv[1], err = d.value(types & 0x0f); err != nil {
+			return nil, err
+		}
+		m = append(m, kv)
+	}
+	return m, nil
+}
+
+func (d *thriftDecoder) readStruct() (tStruct, error) {
+	s := tStruct{}
+	var lastID int16
+	for {
+		header, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		typ := header & 0x0f
+		if typ == tStop {
+			return s, nil
+		}
+		id := lastID + int16(header>>4)
+		if header>>4 == 0 {
+			v, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		if s[id], err = d.value(typ); err != nil {
+			return nil, err
+		}
+		lastID = id
+	}
+}
+
+// readThrift decodes a thrift struct from the beginning of b, and returns the
+// struct along with the number of bytes consumed.
+func readThrift(b []byte) (tStruct, int, error) {
+	d := thriftDecoder{b: b}
+	s, err := d.readStruct()
+	return s, d.pos, err
+}
+
+//------------------------------------------------------------------------------
+
+// thriftEncoder serialises thrift structs with the compact protocol. Structs
+// are written by calling beginStruct, writing each field in ascending order of
+// identifier and then calling endStruct.
+type thriftEncoder struct {
+	b    []byte
+	last []int16
+}
+
+func (e *thriftEncoder) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.b = append(e.b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (e *thriftEncoder) varint(v int64) {
+	e.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (e *thriftEncoder) binary(v []byte) {
+	e.uvarint(uint64(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *thriftEncoder) fieldHeader(id int16, typ byte) {
+	last := &e.last[len(e.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.b = append(e.b, byte(delta)<<4|typ)
+	} else {
+		e.b = append(e.b, typ)
+		e.varint(int64(id))
+	}
+	*last = id
+}
+
+func (e *thriftEncoder) beginStruct() {
+	e.last = append(e.last, 0)
+}
+
+func (e *thriftEncoder) endStruct() {
+	e.b = append(e.b, tStop)
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *thriftEncoder) structField(id int16) {
+	e.fieldHeader(id, tStructT)
+	e.beginStruct()
+}
+
+func (e *thriftEncoder) listField(id int16, elemType byte, size int) {
+	e.fieldHeader(id, tList)
+	if size < 15 {
+		e.b = append(e.b, byte(size)<<4|elemType)
+	} else {
+		e.b = append(e.b, 0xf0|elemType)
+		e.uvarint(uint64(size))
+	}
+}
+
+func (e *thriftEncoder) i32(id int16, v int32) {
+	e.fieldHeader(id, tI32)
+	e.varint(int64(v))
+}
+
+func (e *thriftEncoder) i64(id int16, v int64) {
+	e.fieldHeader(id, tI64)
+	e.varint(v)
+}
+
+func (e *thriftEncoder) str(id int16, v string) {
+	e.fieldHeader(id, tBinary)
+	e.binary([]byte(v))
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,527 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+//------------------------------------------------------------------------------
+
+// Column describes a top level column of a Parquet file written by a Writer.
+// All columns are optional, and therefore a row missing a column or
+// containing a null value results in a null value.
+//
+// Supported types are boolean, int32, int64, float, double, bytes, string,
+// json and timestamp. Columns of the json type contain values serialised as
+// JSON documents, and columns of the timestamp type contain milliseconds since
+// the unix epoch, which are parsed from either RFC3339 strings or numbers of
+// milliseconds.
+type Column struct {
+	Name string
+	Type string
+}
+
+// columnType describes how the values of a column type are encoded.
+type columnType struct {
+	physical  int
+	converted int
+	encode    func(c *columnBuffer, v interface{}) error
+}
+
+var columnTypes = map[string]columnType{
+	"boolean":   {typeBoolean, convNone, encodeBoolean},
+	"int32":     {typeInt32, convNone, encodeInt32},
+	"int64":     {typeInt64, convNone, encodeInt64},
+	"float":     {typeFloat, convNone, encodeFloat},
+	"double":    {typeDouble, convNone, encodeDouble},
+	"bytes":     {typeByteArray, convNone, encodeBytes},
+	"string":    {typeByteArray, convUTF8, encodeBytes},
+	"json":      {typeByteArray, convJSON, encodeJSON},
+	"timestamp": {typeInt64, convTimestampMillis, encodeTimestamp},
+}
+
+var compressionCodecs = map[string]int{
+	"uncompressed": codecUncompressed,
+	"snappy":       codecSnappy,
+	"gzip":         codecGzip,
+	"zstd":         codecZstd,
+}
+
+//------------------------------------------------------------------------------
+
+// InferColumns derives a list of columns from a set of rows, where each key of
+// any row becomes a column, sorted by name. Booleans, strings and numbers
+// result in boolean, string and either int64 or double columns, and all other
+// values, or keys with values of mixed types, result in json columns. Keys that
+// only contain null values result in string columns.
+func InferColumns(rows []map[string]interface{}) []Column {
+	types := map[string]string{}
+	for _, row := range rows {
+		for k, v := range row {
+			t := inferType(v)
+			current, exists := types[k]
+			switch {
+			case !exists || current == "":
+				types[k] = t
+			case t == "" || t == current:
+			case (current == "int64" && t == "double") || (current == "double" && t == "int64"):
+				types[k] = "double"
+			default:
+				types[k] = "json"
+			}
+		}
+	}
+
+	columns := make([]Column, 0, len(types))
+	for k, t := range types {
+		if t == "" {
+			t = "string"
+		}
+		columns = append(columns, Column{Name: k, Type: t})
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].Name < columns[j].Name
+	})
+	return columns
+}
+
+func inferType(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int32, int64:
+		return "int64"
+	case float64:
+		if t == math.Trunc(t) && math.Abs(t) < 1<<53 {
+			return "int64"
+		}
+		return "double"
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return "int64"
+		}
+		return "double"
+	}
+	return "json"
+}
+
+//------------------------------------------------------------------------------
+
+// Writer encodes rows into Parquet files with a fixed list of columns. Each
+// file consists of a single row group where each column chunk is a single
+// PLAIN encoded data page.
+type Writer struct {
+	codec int
+
+	mut     sync.Mutex
+	columns []Column
+	types   []columnType
+}
+
+// NewWriter creates a writer of Parquet files with a list of columns and a
+// compression codec, which can be one of uncompressed, snappy, gzip or zstd.
+// When the list of columns is empty the columns are inferred from the rows of
+// the first call to Encode, and are then reused for all subsequent files.
+func NewWriter(columns []Column, compression string) (*Writer, error) {
+	codec, exists := compressionCodecs[compression]
+	if !exists {
+		return nil, fmt.Errorf("unrecognised compression codec: %v", compression)
+	}
+	w := &Writer{
+		codec: codec,
+	}
+	if len(columns) == 0 {
+		return w, nil
+	}
+	if err := w.setColumns(columns); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) setColumns(columns []Column) error {
+	if len(columns) == 0 {
+		return errors.New("at least one column must be specified")
+	}
+	var types []columnType
+	names := map[string]struct{}{}
+	for _, c := range columns {
+		if c.Name == "" {
+			return errors.New("column names must not be empty")
+		}
+		if _, exists := names[c.Name]; exists {
+			return fmt.Errorf("duplicate column name: %v", c.Name)
+		}
+		names[c.Name] = struct{}{}
+		t, exists := columnTypes[c.Type]
+		if !exists {
+			return fmt.Errorf("column %v: unrecognised type: %v", c.Name, c.Type)
+		}
+		types = append(types, t)
+	}
+	w.columns, w.types = columns, types
+	return nil
+}
+
+// Columns returns the columns of files written by the Writer, which is empty
+// when the columns have not yet been inferred.
+func (w *Writer) Columns() []Column {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return w.columns
+}
+
+// Encode writes a list of rows as a Parquet file.
+func (w *Writer) Encode(rows []map[string]interface{}) ([]byte, error) {
+	w.mut.Lock()
+	if w.columns == nil {
+		if err := w.setColumns(InferColumns(rows)); err != nil {
+			w.mut.Unlock()
+			return nil, fmt.Errorf("failed to infer columns: %v", err)
+		}
+	}
+	columns, types := w.columns, w.types
+	w.mut.Unlock()
+
+	b := append([]byte{}, magic...)
+
+	meta := &thriftEncoder{}
+	meta.beginStruct()
+	meta.i32(1, 1)
+	meta.listField(2, tStructT, len(columns)+1)
+	meta.beginStruct()
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.endStruct()
+	for i, c := range columns {
+		meta.beginStruct()
+		meta.i32(1, int32(types[i].physical))
+		meta.i32(3, repOptional)
+		meta.str(4, c.Name)
+		if types[i].converted != convNone {
+			meta.i32(6, int32(types[i].converted))
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, int64(len(rows)))
+	meta.listField(4, tStructT, 1)
+	meta.beginStruct()
+	meta.listField(1, tStructT, len(columns))
+
+	var totalSize int64
+	for i, c := range columns {
+		buf := &columnBuffer{
+			defs: make([]int32, 0, len(rows)),
+		}
+		for j, row := range rows {
+			v := row[c.Name]
+			if v == nil {
+				buf.defs = append(buf.defs, 0)
+				continue
+			}
+			if err := types[i].encode(buf, v); err != nil {
+				return nil, fmt.Errorf("column %v of row %v: %v", c.Name, j, err)
+			}
+			buf.defs = append(buf.defs, 1)
+		}
+
+		defs := encodeBitPacked(buf.defs, 1)
+		raw := make([]byte, 4, 4+len(defs)+len(buf.vals))
+		binary.LittleEndian.PutUint32(raw, uint32(len(defs)))
+		raw = append(append(raw, defs...), buf.vals...)
+		page, err := compress(w.codec, raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress column %v: %v", c.Name, err)
+		}
+
+		header := &thriftEncoder{}
+		header.beginStruct()
+		header.i32(1, pageData)
+		header.i32(2, int32(len(raw)))
+		header.i32(3, int32(len(page)))
+		header.structField(5)
+		header.i32(1, int32(len(rows)))
+		header.i32(2, encPlain)
+		header.i32(3, encRLE)
+		header.i32(4, encRLE)
+		header.endStruct()
+		header.endStruct()
+
+		offset := int64(len(b))
+		b = append(append(b, header.b...), page...)
+		uncompressedSize := int64(len(header.b) + len(raw))
+		compressedSize := int64(len(b)) - offset
+		totalSize += uncompressedSize
+
+		meta.beginStruct()
+		meta.i64(2, offset)
+		meta.structField(3)
+		meta.i32(1, int32(types[i].physical))
+		meta.listField(2, tI32, 2)
+		meta.varint(encPlain)
+		meta.varint(encRLE)
+		meta.listField(3, tBinary, 1)
+		meta.binary([]byte(c.Name))
+		meta.i32(4, int32(w.codec))
+		meta.i64(5, int64(len(rows)))
+		meta.i64(6, uncompressedSize)
+		meta.i64(7, compressedSize)
+		meta.i64(9, offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+
+	meta.i64(2, totalSize)
+	meta.i64(3, int64(len(rows)))
+	meta.endStruct()
+	meta.str(6, "benthos")
+	meta.endStruct()
+
+	b = append(b, meta.b...)
+	lenBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(lenBytes, uint32(len(meta.b)))
+	b = append(b, lenBytes...)
+	return append(b, magic...), nil
+}
+
+//------------------------------------------------------------------------------
+
+// columnBuffer accumulates the definition levels and PLAIN encoded non-null
+// values of a column chunk.
+type columnBuffer struct {
+	defs  []int32
+	vals  []byte
+	count int
+}
+
+func (c *columnBuffer) appendUint32(v uint32) {
+	c.vals = append(c.vals, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(c.vals[len(c.vals)-4:], v)
+	c.count++
+}
+
+func (c *columnBuffer) appendUint64(v uint64) {
+	c.vals = append(c.vals, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(c.vals[len(c.vals)-8:], v)
+	c.count++
+}
+
+func (c *columnBuffer) appendBytes(v []byte) {
+	c.appendUint32(uint32(len(v)))
+	c.vals = append(c.vals, v...)
+}
+
+func encodeBoolean(c *columnBuffer, v interface{}) error {
+	b, ok := v.(bool)
+	if !ok {
+		return fmt.Errorf("expected boolean value, got %T", v)
+	}
+	if c.count%8 == 0 {
+		c.vals = append(c.vals, 0)
+	}
+	if b {
+		c.vals[len(c.vals)-1] |= 1 << (uint(c.count) % 8)
+	}
+	c.count++
+	return nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch t := v.(type) {
+	case int:
+		return int64(t), nil
+	case int32:
+		return int64(t), nil
+	case int64:
+		return t, nil
+	case float64:
+		if t != math.Trunc(t) || math.Abs(t) > math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer", t)
+		}
+		return int64(t), nil
+	case json.Number:
+		return t.Int64()
+	}
+	return 0, fmt.Errorf("expected number value, got %T", v)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case int:
+		return float64(t), nil
+	case int32:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case float32:
+		return float64(t), nil
+	case float64:
+		return t, nil
+	case json.Number:
+		return t.Float64()
+	}
+	return 0, fmt.Errorf("expected number value, got %T", v)
+}
+
+func encodeInt32(c *columnBuffer, v interface{}) error {
+	i, err := toInt64(v)
+	if err != nil {
+		return err
+	}
+	if i < math.MinInt32 || i > math.MaxInt32 {
+		return fmt.Errorf("value %v overflows int32", i)
+	}
+	c.appendUint32(uint32(int32(i)))
+	return nil
+}
+
+func encodeInt64(c *columnBuffer, v interface{}) error {
+	i, err := toInt64(v)
+	if err != nil {
+		return err
+	}
+	c.appendUint64(uint64(i))
+	return nil
+}
+
+func encodeFloat(c *columnBuffer, v interface{}) error {
+	f, err := toFloat64(v)
+	if err != nil {
+		return err
+	}
+	c.appendUint32(math.Float32bits(float32(f)))
+	return nil
+}
+
+func encodeDouble(c *columnBuffer, v interface{}) error {
+	f, err := toFloat64(v)
+	if err != nil {
+		return err
+	}
+	c.appendUint64(math.Float64bits(f))
+	return nil
+}
+
+func encodeBytes(c *columnBuffer, v interface{}) error {
+	switch t := v.(type) {
+	case string:
+		c.appendBytes([]byte(t))
+	case []byte:
+		c.appendBytes(t)
+	default:
+		return fmt.Errorf("expected string value, got %T", v)
+	}
+	return nil
+}
+
+func encodeJSON(c *columnBuffer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.appendBytes(b)
+	return nil
+}
+
+func encodeTimestamp(c *columnBuffer, v interface{}) error {
+	var ms int64
+	switch t := v.(type) {
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return err
+		}
+		ms = ts.UnixNano() / int64(time.Millisecond)
+	case time.Time:
+		ms = t.UnixNano() / int64(time.Millisecond)
+	default:
+		var err error
+		if ms, err = toInt64(v); err != nil {
+			return err
+		}
+	}
+	c.appendUint64(uint64(ms))
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// encodeBitPacked encodes levels as a single bit-packed run of the hybrid
+// encoding.
+func encodeBitPacked(levels []int32, width int) []byte {
+	groups := (len(levels) + 7) / 8
+	var header [binary.MaxVarintLen64]byte
+	b := append([]byte{}, header[:binary.PutUvarint(header[:], uint64(groups<<1|1))]...)
+	packed := make([]byte, groups*width)
+	for i, l := range levels {
+		for j := 0; j < width; j++ {
+			bit := i*width + j
+			packed[bit/8] |= byte((l>>uint(j))&1) << (uint(bit) % 8)
+		}
+	}
+	return append(b, packed...)
+}
+
+func compress(codec int, b []byte) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return b, nil
+	case codecSnappy:
+		return snappy.Encode(nil, b), nil
+	case codecGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case codecZstd:
+		w, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Close()
+		return w.EncodeAll(b, nil), nil
+	}
+	return nil, fmt.Errorf("unsupported compression codec: %v", codec)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,197 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package parquet
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func parseRows(t *testing.T, docs ...string) []map[string]interface{} {
+	t.Helper()
+	rows := make([]map[string]interface{}, len(docs))
+	for i, d := range docs {
+		if err := json.Unmarshal([]byte(d), &rows[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rows
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: "int64"},
+		{Name: "count", Type: "int32"},
+		{Name: "name", Type: "string"},
+		{Name: "raw", Type: "bytes"},
+		{Name: "flag", Type: "boolean"},
+		{Name: "ratio", Type: "float"},
+		{Name: "score", Type: "double"},
+		{Name: "doc", Type: "json"},
+		{Name: "ts", Type: "timestamp"},
+	}
+	rows := parseRows(t,
+		`{"id":1,"count":10,"name":"foo","raw":"a","flag":true,"ratio":0.5,"score":1.25,"doc":{"a":[1,2]},"ts":"2021-01-01T00:00:00.123Z"}`,
+		`{"id":2,"name":null,"flag":false,"score":-3,"ts":1609459200000,"ignored":"yes"}`,
+		`{"id":-3,"count":-1,"name":"bar","flag":true,"doc":[true]}`,
+	)
+	exp := `[` +
+		`{"count":10,"doc":{"a":[1,2]},"flag":true,"id":1,"name":"foo","ratio":0.5,"raw":"a","score":1.25,"ts":"2021-01-01T00:00:00.123Z"},` +
+		`{"count":null,"doc":null,"flag":false,"id":2,"name":null,"ratio":null,"raw":null,"score":-3,"ts":"2021-01-01T00:00:00Z"},` +
+		`{"count":-1,"doc":[true],"flag":true,"id":-3,"name":"bar","ratio":null,"raw":null,"score":null,"ts":null}` +
+		`]`
+
+	for _, codec := range []string{"uncompressed", "snappy", "gzip", "zstd"} {
+		w, err := NewWriter(columns, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := w.Encode(rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := readAllRows(t, file); act != exp {
+			t.Errorf("Wrong rows with %v:\n%v\n!=\n%v", codec, act, exp)
+		}
+	}
+}
+
+func TestWriterManyBooleans(t *testing.T) {
+	w, err := NewWriter([]Column{{Name: "b", Type: "boolean"}}, "snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	exp := "["
+	for i := 0; i < 20; i++ {
+		if i%3 == 0 {
+			rows = append(rows, map[string]interface{}{})
+			exp += `{"b":null}`
+		} else {
+			rows = append(rows, map[string]interface{}{"b": i%2 == 0})
+			if i%2 == 0 {
+				exp += `{"b":true}`
+			} else {
+				exp += `{"b":false}`
+			}
+		}
+		if i < 19 {
+			exp += ","
+		}
+	}
+	exp += "]"
+	file, err := w.Encode(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act := readAllRows(t, file); act != exp {
+		t.Errorf("Wrong rows:\n%v\n!=\n%v", act, exp)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	badConfigs := map[string][]Column{
+		"bad type":    {{Name: "a", Type: "nope"}},
+		"empty name":  {{Name: "", Type: "string"}},
+		"duplicate":   {{Name: "a", Type: "string"}, {Name: "a", Type: "int64"}},
+		"bad codec":   {{Name: "a", Type: "string"}},
+		"good config": {{Name: "a", Type: "string"}},
+	}
+	for k, columns := range badConfigs {
+		codec := "snappy"
+		if k == "bad codec" {
+			codec = "lzo"
+		}
+		_, err := NewWriter(columns, codec)
+		if k == "good config" {
+			if err != nil {
+				t.Errorf("%v: %v", k, err)
+			}
+		} else if err == nil {
+			t.Errorf("%v: expected error", k)
+		}
+	}
+
+	w, err := NewWriter(nil, "snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Encode(parseRows(t, `{}`)); err == nil {
+		t.Error("Expected error from inferring no columns")
+	}
+
+	badRows := map[string]string{
+		"int64":     `{"a":1.5}`,
+		"int32":     `{"a":3000000000}`,
+		"boolean":   `{"a":"true"}`,
+		"string":    `{"a":5}`,
+		"double":    `{"a":"5"}`,
+		"timestamp": `{"a":"yesterday"}`,
+	}
+	for typ, row := range badRows {
+		w, err := NewWriter([]Column{{Name: "a", Type: typ}}, "uncompressed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Encode(parseRows(t, row)); err == nil {
+			t.Errorf("%v: expected error from row %v", typ, row)
+		}
+	}
+}
+
+func TestInferColumns(t *testing.T) {
+	rows := parseRows(t,
+		`{"id":1,"name":"foo","score":2,"tags":["a"],"flag":true,"nothing":null,"mixed":5}`,
+		`{"id":2,"score":2.5,"extra":{"a":"b"},"mixed":"five"}`,
+	)
+	exp := []Column{
+		{Name: "extra", Type: "json"},
+		{Name: "flag", Type: "boolean"},
+		{Name: "id", Type: "int64"},
+		{Name: "mixed", Type: "json"},
+		{Name: "name", Type: "string"},
+		{Name: "nothing", Type: "string"},
+		{Name: "score", Type: "double"},
+		{Name: "tags", Type: "json"},
+	}
+	if act := InferColumns(rows); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong columns: %v != %v", act, exp)
+	}
+
+	w, err := NewWriter(nil, "snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Encode(rows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Encode(parseRows(t, `{"new":"field"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if act := w.Columns(); !reflect.DeepEqual(act, exp) {
+		t.Errorf("Wrong writer columns: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,135 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pulsar
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+//------------------------------------------------------------------------------
+
+// TLSConfig contains configuration fields for connecting to brokers with a
+// pulsar+ssl:// URL.
+type TLSConfig struct {
+	RootCAsFile        string `json:"root_cas_file" yaml:"root_cas_file"`
+	InsecureSkipVerify bool   `json:"skip_cert_verify" yaml:"skip_cert_verify"`
+	ValidateHostname   bool   `json:"validate_hostname" yaml:"validate_hostname"`
+}
+
+// TokenAuthConfig contains configuration fields for authenticating with a JSON
+// Web Token.
+type TokenAuthConfig struct {
+	Enabled   bool   `json:"enabled" yaml:"enabled"`
+	Token     string `json:"token" yaml:"token"`
+	TokenFile string `json:"token_file" yaml:"token_file"`
+}
+
+// TLSAuthConfig contains configuration fields for authenticating with a client
+// certificate.
+type TLSAuthConfig struct {
+	Enabled  bool   `json:"enabled" yaml:"enabled"`
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+}
+
+// AuthConfig contains configuration params for the Pulsar auth strategies.
+type AuthConfig struct {
+	Token TokenAuthConfig `json:"token" yaml:"token"`
+	TLS   TLSAuthConfig   `json:"tls" yaml:"tls"`
+}
+
+// ClientConfig contains configuration fields for creating a Pulsar client.
+type ClientConfig struct {
+	URL  string     `json:"url" yaml:"url"`
+	TLS  TLSConfig  `json:"tls" yaml:"tls"`
+	Auth AuthConfig `json:"auth" yaml:"auth"`
+}
+
+// NewClientConfig creates a new ClientConfig with default values.
+func NewClientConfig() ClientConfig {
+	return ClientConfig{
+		URL: "pulsar://localhost:6650",
+		TLS: TLSConfig{
+			RootCAsFile:        "",
+			InsecureSkipVerify: false,
+			ValidateHostname:   false,
+		},
+		Auth: AuthConfig{
+			Token: TokenAuthConfig{
+				Enabled:   false,
+				Token:     "",
+				TokenFile: "",
+			},
+			TLS: TLSAuthConfig{
+				Enabled:  false,
+				CertFile: "",
+				KeyFile:  "",
+			},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Options returns the Pulsar client options described by the config, with the
+// client logging through the provided logger.
+func (c ClientConfig) Options(logger log.Modular) (pulsar.ClientOptions, error) {
+	opts := pulsar.ClientOptions{
+		URL:                        c.URL,
+		ConnectionTimeout:          time.Second * 5,
+		TLSTrustCertsFilePath:      c.TLS.RootCAsFile,
+		TLSAllowInsecureConnection: c.TLS.InsecureSkipVerify,
+		TLSValidateHostname:        c.TLS.ValidateHostname,
+		Logger:                     newLogger(logger),
+	}
+	if len(c.URL) == 0 {
+		return opts, errors.New("a url must be provided")
+	}
+
+	if c.Auth.Token.Enabled && c.Auth.TLS.Enabled {
+		return opts, errors.New("only one of token and tls auth can be enabled")
+	}
+	if c.Auth.Token.Enabled {
+		switch {
+		case len(c.Auth.Token.Token) > 0 && len(c.Auth.Token.TokenFile) > 0:
+			return opts, errors.New("only one of token and token_file can be set")
+		case len(c.Auth.Token.Token) > 0:
+			opts.Authentication = pulsar.NewAuthenticationToken(c.Auth.Token.Token)
+		case len(c.Auth.Token.TokenFile) > 0:
+			opts.Authentication = pulsar.NewAuthenticationTokenFromFile(c.Auth.Token.TokenFile)
+		default:
+			return opts, errors.New("token auth requires a token or token_file")
+		}
+	}
+	if c.Auth.TLS.Enabled {
+		if len(c.Auth.TLS.CertFile) == 0 || len(c.Auth.TLS.KeyFile) == 0 {
+			return opts, errors.New("tls auth requires both a cert_file and key_file")
+		}
+		opts.Authentication = pulsar.NewAuthenticationTLS(c.Auth.TLS.CertFile, c.Auth.TLS.KeyFile)
+	}
+	return opts, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,140 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+)
+
+//------------------------------------------------------------------------------
+
+func TestClientConfigOptions(t *testing.T) {
+	conf := NewClientConfig()
+	conf.TLS.RootCAsFile = "./ca.pem"
+	conf.TLS.ValidateHostname = true
+
+	opts, err := conf.Options(log.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "pulsar://localhost:6650", opts.URL; exp != act {
+		t.Errorf("Wrong url: %v != %v", act, exp)
+	}
+	if exp, act := "./ca.pem", opts.TLSTrustCertsFilePath; exp != act {
+		t.Errorf("Wrong root cas: %v != %v", act, exp)
+	}
+	if !opts.TLSValidateHostname {
+		t.Error("Expected hostname validation")
+	}
+	if opts.Authentication != nil {
+		t.Error("Expected no authentication")
+	}
+	if opts.Logger == nil {
+		t.Error("Expected logger")
+	}
+}
+
+func TestClientConfigAuth(t *testing.T) {
+	tests := map[string]struct {
+		fn      func(c *ClientConfig)
+		wantErr bool
+	}{
+		"token": {
+			fn: func(c *ClientConfig) {
+				c.Auth.Token.Enabled = true
+				c.Auth.Token.Token = "foo"
+			},
+		},
+		"token file": {
+			fn: func(c *ClientConfig) {
+				c.Auth.Token.Enabled = true
+				c.Auth.Token.TokenFile = "./token"
+			},
+		},
+		"tls": {
+			fn: func(c *ClientConfig) {
+				c.Auth.TLS.Enabled = true
+				c.Auth.TLS.CertFile = "./cert.pem"
+				c.Auth.TLS.KeyFile = "./key.pem"
+			},
+		},
+		"token and token file": {
+			fn: func(c *ClientConfig) {
+				c.Auth.Token.Enabled = true
+				c.Auth.Token.Token = "foo"
+				c.Auth.Token.TokenFile = "./token"
+			},
+			wantErr: true,
+		},
+		"missing token": {
+			fn: func(c *ClientConfig) {
+				c.Auth.Token.Enabled = true
+			},
+			wantErr: true,
+		},
+		"missing key file": {
+			fn: func(c *ClientConfig) {
+				c.Auth.TLS.Enabled = true
+				c.Auth.TLS.CertFile = "./cert.pem"
+			},
+			wantErr: true,
+		},
+		"token and tls": {
+			fn: func(c *ClientConfig) {
+				c.Auth.Token.Enabled = true
+				c.Auth.Token.Token = "foo"
+				c.Auth.TLS.Enabled = true
+				c.Auth.TLS.CertFile = "./cert.pem"
+				c.Auth.TLS.KeyFile = "./key.pem"
+			},
+			wantErr: true,
+		},
+		"missing url": {
+			fn: func(c *ClientConfig) {
+				c.URL = ""
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewClientConfig()
+		test.fn(&conf)
+		opts, err := conf.Options(log.Noop())
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: expected error", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if opts.Authentication == nil {
+			t.Errorf("%v: expected authentication", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pulsar
+
+import (
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	plog "github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+//------------------------------------------------------------------------------
+
+// logger adapts a Benthos logger to the Pulsar client logger interface. The
+// client logs connection lifecycle events at info level, which are demoted to
+// debug level here, and debug logs are demoted to trace.
+type logger struct {
+	l log.Modular
+}
+
+func newLogger(l log.Modular) plog.Logger {
+	return logger{l: l}
+}
+
+func (l logger) SubLogger(fields plog.Fields) plog.Logger {
+	return logger{l: l.withFields(fields)}
+}
+
+func (l logger) WithFields(fields plog.Fields) plog.Entry {
+	return logger{l: l.withFields(fields)}
+}
+
+func (l logger) WithField(name string, value interface{}) plog.Entry {
+	return l.WithFields(plog.Fields{name: value})
+}
+
+func (l logger) WithError(err error) plog.Entry {
+	return l.WithFields(plog.Fields{"error": err})
+}
+
+func (l logger) withFields(fields plog.Fields) log.Modular {
+	strFields := make(map[string]string, len(fields))
+	for k, v := range fields {
+		strFields[k] = fmt.Sprintf("%v", v)
+	}
+	return log.WithFields(l.l, strFields)
+}
+
+func (l logger) Debug(args ...interface{}) {
+	l.l.Traceln(fmt.Sprint(args...))
+}
+
+func (l logger) Info(args ...interface{}) {
+	l.l.Debugln(fmt.Sprint(args...))
+}
+
+func (l logger) Warn(args ...interface{}) {
+	l.l.Warnln(fmt.Sprint(args...))
+}
+
+func (l logger) Error(args ...interface{}) {
+	l.l.Errorln(fmt.Sprint(args...))
+}
+
+func (l logger) Debugf(format string, args ...interface{}) {
+	l.l.Tracef(format+"\n", args...)
+}
+
+func (l logger) Infof(format string, args ...interface{}) {
+	l.l.Debugf(format+"\n", args...)
+}
+
+func (l logger) Warnf(format string, args ...interface{}) {
+	l.l.Warnf(format+"\n", args...)
+}
+
+func (l logger) Errorf(format string, args ...interface{}) {
+	l.l.Errorf(format+"\n", args...)
+}
+
+//------------------------------------------------------------------------------
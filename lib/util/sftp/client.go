@@ -0,0 +1,560 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sftp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// Packet types of version 3 of the SSH File Transfer Protocol.
+const (
+	packetInit       = 1
+	packetVersion    = 2
+	packetOpen       = 3
+	packetClose      = 4
+	packetRead       = 5
+	packetWrite      = 6
+	packetOpendir    = 11
+	packetReaddir    = 12
+	packetRemove     = 13
+	packetMkdir      = 14
+	packetStat       = 17
+	packetRename     = 18
+	packetStatus     = 101
+	packetHandle     = 102
+	packetData       = 103
+	packetName       = 104
+	packetAttrs      = 105
+	packetExtended   = 200
+	protocolVer      = 3
+	flagRead         = 0x01
+	flagWrite        = 0x02
+	flagCreate       = 0x08
+	flagTruncate     = 0x10
+	attrSize         = 0x01
+	attrUIDGID       = 0x02
+	attrPerms        = 0x04
+	attrModTime      = 0x08
+	attrExtended     = 0x80000000
+	statusOK         = 0
+	statusEOF        = 1
+	statusNoFile     = 2
+	statusFailure    = 4
+	maxPacketBytes   = 1 << 18
+	chunkBytes       = 1 << 15
+	extPosixRename   = "posix-rename@openssh.com"
+	extPosixRenameV1 = "1"
+)
+
+// StatusError is returned when the server responds to a request with a status
+// other than success.
+type StatusError struct {
+	Code uint32
+	Msg  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sftp status %v: %v", e.Code, e.Msg)
+}
+
+// IsNotExist returns true if an error is the result of a path not existing.
+func IsNotExist(err error) bool {
+	serr, ok := err.(*StatusError)
+	return ok && serr.Code == statusNoFile
+}
+
+// FileInfo describes an entry of a remote directory.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// IsRegular returns true if the entry is a regular file.
+func (i FileInfo) IsRegular() bool {
+	// Permission bits follow the layout of POSIX st_mode, where regular files
+	// have the type 0100000.
+	return i.Mode&0170000 == 0100000
+}
+
+// IsDir returns true if the entry is a directory.
+func (i FileInfo) IsDir() bool {
+	return i.Mode&0170000 == 0040000
+}
+
+//------------------------------------------------------------------------------
+
+// Client is a client of an SFTP session.
+type Client struct {
+	mut        sync.Mutex
+	nextID     uint32
+	r          *bufio.Reader
+	w          io.Writer
+	closer     io.Closer
+	extensions map[string]string
+}
+
+// NewClient initialises an SFTP session over a stream, which is usually the
+// sftp subsystem of an SSH session. The closer is called when the client is
+// closed.
+func NewClient(r io.Reader, w io.Writer, closer io.Closer) (*Client, error) {
+	c := &Client{
+		r:          bufio.NewReader(r),
+		w:          w,
+		closer:     closer,
+		extensions: map[string]string{},
+	}
+	var b packet
+	if err := writePacket(c.w, packetInit, b.uint32(protocolVer)); err != nil {
+		return nil, err
+	}
+	typ, br, err := readPacket(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != packetVersion {
+		return nil, fmt.Errorf("unexpected sftp packet type during init: %v", typ)
+	}
+	if v := br.uint32(); v < protocolVer {
+		return nil, fmt.Errorf("unsupported sftp protocol version: %v", v)
+	}
+	for len(br.b) > 0 && br.err == nil {
+		name := br.string()
+		c.extensions[name] = br.string()
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+// packet builds the body of an SFTP packet.
+type packet []byte
+
+func (p *packet) uint32(v uint32) *packet {
+	*p = append(*p, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	return p
+}
+
+func (p *packet) uint64(v uint64) *packet {
+	return p.uint32(uint32(v >> 32)).uint32(uint32(v))
+}
+
+func (p *packet) string(v string) *packet {
+	p.uint32(uint32(len(v)))
+	*p = append(*p, v...)
+	return p
+}
+
+func writePacket(w io.Writer, typ byte, body *packet) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header, uint32(len(*body)+1))
+	header[4] = typ
+	if _, err := w.Write(append(header, *body...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readPacket(r io.Reader) (byte, *packetReader, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length == 0 || length > maxPacketBytes {
+		return 0, nil, fmt.Errorf("invalid sftp packet length: %v", length)
+	}
+	body := make([]byte, length-1)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[4], &packetReader{b: body}, nil
+}
+
+var errPacketShort = errors.New("sftp packet is too short")
+
+// packetReader reads fields from the body of an SFTP packet, where the first
+// error encountered is kept and all later reads return zero values.
+type packetReader struct {
+	b   []byte
+	err error
+}
+
+func (r *packetReader) next(n int) []byte {
+	if r.err != nil || n < 0 || len(r.b) < n {
+		if r.err == nil {
+			r.err = errPacketShort
+		}
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *packetReader) uint32() uint32 {
+	var v uint32
+	for _, c := range r.next(4) {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func (r *packetReader) uint64() uint64 {
+	return uint64(r.uint32())<<32 | uint64(r.uint32())
+}
+
+func (r *packetReader) string() string {
+	return string(r.next(int(r.uint32())))
+}
+
+func (r *packetReader) skipString() {
+	r.next(int(r.uint32()))
+}
+
+//------------------------------------------------------------------------------
+
+// request sends a request and returns the type and body of the response, with
+// the request ID removed.
+func (c *Client) request(typ byte, build func(p *packet)) (byte, *packetReader, error) {
+	c.nextID++
+	id := c.nextID
+
+	var p packet
+	p.uint32(id)
+	build(&p)
+	if err := writePacket(c.w, typ, &p); err != nil {
+		return 0, nil, err
+	}
+
+	rtyp, br, err := readPacket(c.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if rid := br.uint32(); br.err != nil || rid != id {
+		return 0, nil, fmt.Errorf("unexpected sftp response id: %v != %v", rid, id)
+	}
+	return rtyp, br, nil
+}
+
+func readStatus(r *packetReader) error {
+	code := r.uint32()
+	msg := r.string()
+	if r.err != nil {
+		return r.err
+	}
+	if code == statusOK {
+		return nil
+	}
+	if code == statusEOF {
+		return io.EOF
+	}
+	return &StatusError{Code: code, Msg: msg}
+}
+
+func readAttrs(r *packetReader, info *FileInfo) {
+	flags := r.uint32()
+	if flags&attrSize != 0 {
+		info.Size = int64(r.uint64())
+	}
+	if flags&attrUIDGID != 0 {
+		r.next(8)
+	}
+	if flags&attrPerms != 0 {
+		info.Mode = os.FileMode(r.uint32())
+	}
+	if flags&attrModTime != 0 {
+		r.next(4)
+		info.ModTime = time.Unix(int64(r.uint32()), 0)
+	}
+	if flags&attrExtended != 0 {
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			r.skipString()
+			r.skipString()
+		}
+	}
+}
+
+// expectStatus reads a status response, any other response type is an error.
+func expectStatus(typ byte, r *packetReader, err error) error {
+	if err != nil {
+		return err
+	}
+	if typ != packetStatus {
+		return fmt.Errorf("unexpected sftp packet type: %v", typ)
+	}
+	return readStatus(r)
+}
+
+// expectHandle reads a handle response.
+func expectHandle(typ byte, r *packetReader, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch typ {
+	case packetHandle:
+		h := r.string()
+		return h, r.err
+	case packetStatus:
+		if err = readStatus(r); err == nil {
+			err = errors.New("expected sftp handle")
+		}
+		return "", err
+	}
+	return "", fmt.Errorf("unexpected sftp packet type: %v", typ)
+}
+
+//------------------------------------------------------------------------------
+
+func (c *Client) closeHandle(handle string) error {
+	return expectStatus(c.request(packetClose, func(p *packet) {
+		p.string(handle)
+	}))
+}
+
+// ReadDir lists the entries of a remote directory.
+func (c *Client) ReadDir(dir string) ([]FileInfo, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	handle, err := expectHandle(c.request(packetOpendir, func(p *packet) {
+		p.string(dir)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []FileInfo
+	for {
+		typ, r, err := c.request(packetReaddir, func(p *packet) {
+			p.string(handle)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if typ != packetName {
+			if err = expectStatus(typ, r, nil); err == io.EOF {
+				break
+			}
+			if err == nil {
+				err = errors.New("expected sftp names")
+			}
+			c.closeHandle(handle)
+			return nil, err
+		}
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			info := FileInfo{Name: r.string()}
+			r.skipString()
+			readAttrs(r, &info)
+			if info.Name != "." && info.Name != ".." {
+				infos = append(infos, info)
+			}
+		}
+		if r.err != nil {
+			c.closeHandle(handle)
+			return nil, r.err
+		}
+	}
+	return infos, c.closeHandle(handle)
+}
+
+// Stat returns information about a remote path.
+func (c *Client) Stat(path string) (FileInfo, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	info := FileInfo{Name: path}
+	typ, r, err := c.request(packetStat, func(p *packet) {
+		p.string(path)
+	})
+	if err != nil {
+		return info, err
+	}
+	if typ != packetAttrs {
+		if err = expectStatus(typ, r, nil); err == nil {
+			err = errors.New("expected sftp attributes")
+		}
+		return info, err
+	}
+	readAttrs(r, &info)
+	return info, r.err
+}
+
+// ReadFile downloads the full contents of a remote file.
+func (c *Client) ReadFile(path string) ([]byte, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	handle, err := expectHandle(c.request(packetOpen, func(p *packet) {
+		p.string(path).uint32(flagRead).uint32(0)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for {
+		typ, r, err := c.request(packetRead, func(p *packet) {
+			p.string(handle).uint64(uint64(len(data))).uint32(chunkBytes)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if typ != packetData {
+			if err = expectStatus(typ, r, nil); err == io.EOF {
+				break
+			}
+			if err == nil {
+				err = errors.New("expected sftp data")
+			}
+			c.closeHandle(handle)
+			return nil, err
+		}
+		chunk := r.next(int(r.uint32()))
+		if r.err != nil {
+			c.closeHandle(handle)
+			return nil, r.err
+		}
+		data = append(data, chunk...)
+	}
+	return data, c.closeHandle(handle)
+}
+
+// WriteFile uploads the contents of a remote file, which is created if it does
+// not exist and truncated otherwise.
+func (c *Client) WriteFile(path string, data []byte) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	handle, err := expectHandle(c.request(packetOpen, func(p *packet) {
+		p.string(path).uint32(flagWrite | flagCreate | flagTruncate).uint32(0)
+	}))
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; offset < len(data); offset += chunkBytes {
+		end := offset + chunkBytes
+		if end > len(data) {
+			end = len(data)
+		}
+		if err = expectStatus(c.request(packetWrite, func(p *packet) {
+			p.string(handle).uint64(uint64(offset)).string(string(data[offset:end]))
+		})); err != nil {
+			c.closeHandle(handle)
+			return err
+		}
+	}
+	return c.closeHandle(handle)
+}
+
+// Remove deletes a remote file.
+func (c *Client) Remove(path string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return expectStatus(c.request(packetRemove, func(p *packet) {
+		p.string(path)
+	}))
+}
+
+// Rename moves a remote file, which fails if the target already exists.
+func (c *Client) Rename(from, to string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return expectStatus(c.request(packetRename, func(p *packet) {
+		p.string(from).string(to)
+	}))
+}
+
+// PosixRename moves a remote file and replaces the target if it already exists,
+// which is atomic on POSIX file systems. Servers that do not support the
+// posix-rename@openssh.com extension instead have the target removed before
+// the file is renamed.
+func (c *Client) PosixRename(from, to string) error {
+	c.mut.Lock()
+	if c.extensions[extPosixRename] == extPosixRenameV1 {
+		defer c.mut.Unlock()
+		return expectStatus(c.request(packetExtended, func(p *packet) {
+			p.string(extPosixRename).string(from).string(to)
+		}))
+	}
+	c.mut.Unlock()
+
+	if err := c.Remove(to); err != nil && !IsNotExist(err) {
+		return err
+	}
+	return c.Rename(from, to)
+}
+
+// Mkdir creates a remote directory.
+func (c *Client) Mkdir(dir string) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return expectStatus(c.request(packetMkdir, func(p *packet) {
+		p.string(dir).uint32(0)
+	}))
+}
+
+// MkdirAll creates a remote directory along with any parents that do not
+// exist.
+func (c *Client) MkdirAll(dir string) error {
+	info, err := c.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return &StatusError{Code: statusFailure, Msg: fmt.Sprintf("path %v exists and is not a directory", dir)}
+		}
+		return nil
+	}
+	if !IsNotExist(err) {
+		return err
+	}
+	if parent := path.Dir(dir); parent != dir && parent != "." && parent != "/" {
+		if err = c.MkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err = c.Mkdir(dir); err != nil {
+		// The directory may have been created concurrently.
+		if info, sErr := c.Stat(dir); sErr == nil && info.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Close ends the SFTP session.
+func (c *Client) Close() error {
+	return c.closer.Close()
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,177 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sftp
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/util/sftp/sftptest"
+)
+
+//------------------------------------------------------------------------------
+
+type testPipe struct {
+	io.Reader
+	io.Writer
+}
+
+func testClient(t *testing.T, s *sftptest.Server) *Client {
+	t.Helper()
+
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	go s.Serve(testPipe{Reader: sr, Writer: sw})
+
+	c, err := NewClient(cr, cw, cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientRead(t *testing.T) {
+	modTime := time.Unix(1577836800, 0)
+	bigFile := bytes.Repeat([]byte("abcdefgh"), chunkBytes/4)
+
+	s := sftptest.NewServer()
+	s.Put("/in/a.txt", "hello world", modTime)
+	s.Put("/in/b.bin", string(bigFile), modTime)
+
+	c := testClient(t, s)
+	defer c.Close()
+
+	infos, err := c.ReadDir("/in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []FileInfo{
+		{Name: "a.txt", Size: 11, Mode: 0100644, ModTime: modTime},
+		{Name: "b.bin", Size: int64(len(bigFile)), Mode: 0100644, ModTime: modTime},
+	}
+	if !reflect.DeepEqual(exp, infos) {
+		t.Errorf("Wrong directory entries: %v != %v", infos, exp)
+	}
+	if !infos[0].IsRegular() {
+		t.Error("Expected regular file")
+	}
+
+	if _, err = c.ReadDir("/nope"); !IsNotExist(err) {
+		t.Errorf("Expected does not exist error, got: %v", err)
+	}
+
+	data, err := c.ReadFile("/in/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "hello world", string(data); exp != act {
+		t.Errorf("Wrong file contents: %v != %v", act, exp)
+	}
+	if data, err = c.ReadFile("/in/b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bigFile, data) {
+		t.Errorf("Wrong file contents of length %v", len(data))
+	}
+	if _, err = c.ReadFile("/in/c.txt"); !IsNotExist(err) {
+		t.Errorf("Expected does not exist error, got: %v", err)
+	}
+
+	info, err := c.Stat("/in/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := int64(11), info.Size; exp != act {
+		t.Errorf("Wrong size: %v != %v", act, exp)
+	}
+	if info, err = c.Stat("/in"); err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Error("Expected directory")
+	}
+
+	if err = c.Rename("/in/a.txt", "/done/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Rename("/in/b.bin", "/done/a.txt"); err == nil {
+		t.Error("Expected error from renaming to existing file")
+	}
+	if err = c.Remove("/in/b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Remove("/in/b.bin"); !IsNotExist(err) {
+		t.Errorf("Expected does not exist error, got: %v", err)
+	}
+	if exp, act := []string{"/done/a.txt"}, s.Names(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong remaining files: %v != %v", act, exp)
+	}
+}
+
+func TestClientWrite(t *testing.T) {
+	bigFile := bytes.Repeat([]byte("abcdefgh"), chunkBytes/4)
+
+	for _, posixRename := range []bool{true, false} {
+		s := sftptest.NewServer()
+		s.PosixRename = posixRename
+		s.Put("/out/a.txt", "old", time.Now())
+
+		c := testClient(t, s)
+
+		if err := c.WriteFile("/nope/a.txt", []byte("foo")); !IsNotExist(err) {
+			t.Errorf("Expected does not exist error, got: %v", err)
+		}
+		if err := c.MkdirAll("/nope/deep"); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.MkdirAll("/nope/deep"); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.MkdirAll("/out/a.txt"); err == nil {
+			t.Error("Expected error from creating directory over a file")
+		}
+		if err := c.WriteFile("/nope/deep/a.txt", []byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.WriteFile("/out/.a.txt.tmp", bigFile); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.PosixRename("/out/.a.txt.tmp", "/out/a.txt"); err != nil {
+			t.Fatal(err)
+		}
+
+		if exp, act := []string{"/nope/deep/a.txt", "/out/a.txt"}, s.Names(); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong files: %v != %v", act, exp)
+		}
+		if data, _ := s.Get("/nope/deep/a.txt"); data != "foo" {
+			t.Errorf("Wrong file contents: %v", data)
+		}
+		if data, _ := s.Get("/out/a.txt"); data != string(bigFile) {
+			t.Errorf("Wrong file contents of length %v", len(data))
+		}
+		c.Close()
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,470 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package sftptest provides an in-memory SFTP server for testing components
+// that use the sftp package.
+package sftptest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+//------------------------------------------------------------------------------
+
+// Packet types and flags of version 3 of the SSH File Transfer Protocol.
+const (
+	packetInit     = 1
+	packetVersion  = 2
+	packetOpen     = 3
+	packetClose    = 4
+	packetRead     = 5
+	packetWrite    = 6
+	packetOpendir  = 11
+	packetReaddir  = 12
+	packetRemove   = 13
+	packetMkdir    = 14
+	packetStat     = 17
+	packetRename   = 18
+	packetStatus   = 101
+	packetHandle   = 102
+	packetData     = 103
+	packetName     = 104
+	packetAttrs    = 105
+	packetExtended = 200
+	flagWrite      = 0x02
+	attrSize       = 0x01
+	attrPerms      = 0x04
+	attrModTime    = 0x08
+	statusOK       = 0
+	statusEOF      = 1
+	statusNoFile   = 2
+	statusFailure  = 4
+	statusUnsup    = 8
+	posixRename    = "posix-rename@openssh.com"
+)
+
+type packet []byte
+
+func (p *packet) uint32(v uint32) *packet {
+	*p = append(*p, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	return p
+}
+
+func (p *packet) uint64(v uint64) *packet {
+	return p.uint32(uint32(v >> 32)).uint32(uint32(v))
+}
+
+func (p *packet) string(v string) *packet {
+	p.uint32(uint32(len(v)))
+	*p = append(*p, v...)
+	return p
+}
+
+func writePacket(w io.Writer, typ byte, body *packet) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header, uint32(len(*body)+1))
+	header[4] = typ
+	_, err := w.Write(append(header, *body...))
+	return err
+}
+
+// packetReader reads fields from the body of a packet, where reads past the
+// end return zero values.
+type packetReader []byte
+
+func readPacket(r io.Reader) (byte, *packetReader, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length == 0 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	body := packetReader(make([]byte, length-1))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header[4], &body, nil
+}
+
+func (r *packetReader) next(n int) []byte {
+	if n < 0 || len(*r) < n {
+		*r = nil
+		return nil
+	}
+	v := (*r)[:n]
+	*r = (*r)[n:]
+	return v
+}
+
+func (r *packetReader) uint32() uint32 {
+	var v uint32
+	for _, c := range r.next(4) {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func (r *packetReader) uint64() uint64 {
+	return uint64(r.uint32())<<32 | uint64(r.uint32())
+}
+
+func (r *packetReader) string() string {
+	return string(r.next(int(r.uint32())))
+}
+
+//------------------------------------------------------------------------------
+
+type file struct {
+	data    []byte
+	modTime time.Time
+}
+
+type writeHandle struct {
+	name string
+	data []byte
+}
+
+// Server serves a file system from memory over version 3 of the SSH File
+// Transfer Protocol.
+type Server struct {
+	// PosixRename determines whether the posix-rename@openssh.com extension is
+	// advertised to clients.
+	PosixRename bool
+
+	mut     sync.Mutex
+	files   map[string]file
+	dirs    map[string]struct{}
+	handles map[string]interface{}
+	nextH   int
+}
+
+// NewServer creates a new empty Server.
+func NewServer() *Server {
+	return &Server{
+		PosixRename: true,
+		files:       map[string]file{},
+		dirs:        map[string]struct{}{"/": {}},
+		handles:     map[string]interface{}{},
+	}
+}
+
+func (s *Server) addDirs(dir string) {
+	for ; dir != "/" && dir != "."; dir = path.Dir(dir) {
+		s.dirs[dir] = struct{}{}
+	}
+}
+
+// Put adds a file to the server along with its parent directories.
+func (s *Server) Put(name, data string, modTime time.Time) {
+	s.mut.Lock()
+	s.files[name] = file{data: []byte(data), modTime: modTime}
+	s.addDirs(path.Dir(name))
+	s.mut.Unlock()
+}
+
+// Get returns the contents of a file.
+func (s *Server) Get(name string) (string, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	f, exists := s.files[name]
+	return string(f.data), exists
+}
+
+// Names returns the sorted names of all files.
+func (s *Server) Names() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	var names []string
+	for k := range s.files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//------------------------------------------------------------------------------
+
+func fileAttrs(p *packet, f file) {
+	p.uint32(attrSize | attrPerms | attrModTime)
+	p.uint64(uint64(len(f.data))).uint32(0100644)
+	p.uint32(uint32(f.modTime.Unix())).uint32(uint32(f.modTime.Unix()))
+}
+
+func dirAttrs(p *packet) {
+	p.uint32(attrPerms).uint32(040755)
+}
+
+func status(code uint32) (byte, packet) {
+	var p packet
+	p.uint32(code).string("status " + strconv.Itoa(int(code))).string("")
+	return packetStatus, p
+}
+
+func (s *Server) newHandle(v interface{}) (byte, packet) {
+	s.nextH++
+	h := strconv.Itoa(s.nextH)
+	s.handles[h] = v
+	var p packet
+	p.string(h)
+	return packetHandle, p
+}
+
+// Serve runs an SFTP session over a stream until it is closed.
+func (s *Server) Serve(rw io.ReadWriter) error {
+	typ, _, err := readPacket(rw)
+	if err != nil {
+		return err
+	}
+	if typ != packetInit {
+		return io.ErrUnexpectedEOF
+	}
+	var v packet
+	v.uint32(3)
+	s.mut.Lock()
+	if s.PosixRename {
+		v.string(posixRename).string("1")
+	}
+	s.mut.Unlock()
+	if err = writePacket(rw, packetVersion, &v); err != nil {
+		return err
+	}
+
+	for {
+		typ, r, err := readPacket(rw)
+		if err != nil {
+			return err
+		}
+		id := r.uint32()
+		rtyp, p := s.handle(typ, r)
+		var out packet
+		out.uint32(id)
+		out = append(out, p...)
+		if err = writePacket(rw, rtyp, &out); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) rename(from, to string, replace bool) (byte, packet) {
+	f, exists := s.files[from]
+	if !exists {
+		return status(statusNoFile)
+	}
+	if _, exists = s.files[to]; exists && !replace {
+		return status(statusFailure)
+	}
+	if _, exists = s.dirs[path.Dir(to)]; !exists {
+		s.addDirs(path.Dir(to))
+	}
+	delete(s.files, from)
+	s.files[to] = f
+	return status(statusOK)
+}
+
+func (s *Server) handle(typ byte, r *packetReader) (byte, packet) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	switch typ {
+	case packetOpendir:
+		dir := r.string()
+		var names []string
+		for k := range s.files {
+			if path.Dir(k) == dir {
+				names = append(names, k)
+			}
+		}
+		if len(names) == 0 {
+			return status(statusNoFile)
+		}
+		sort.Strings(names)
+		return s.newHandle(names)
+	case packetReaddir:
+		h := r.string()
+		names, _ := s.handles[h].([]string)
+		if len(names) == 0 {
+			return status(statusEOF)
+		}
+		// Return a single entry per response to exercise multiple reads.
+		s.handles[h] = names[1:]
+		var p packet
+		p.uint32(1).string(path.Base(names[0])).string("")
+		fileAttrs(&p, s.files[names[0]])
+		return packetName, p
+	case packetOpen:
+		name := r.string()
+		if r.uint32()&flagWrite != 0 {
+			if _, exists := s.dirs[path.Dir(name)]; !exists {
+				return status(statusNoFile)
+			}
+			return s.newHandle(&writeHandle{name: name})
+		}
+		f, exists := s.files[name]
+		if !exists {
+			return status(statusNoFile)
+		}
+		return s.newHandle(f.data)
+	case packetRead:
+		data, _ := s.handles[r.string()].([]byte)
+		offset, length := int(r.uint64()), int(r.uint32())
+		if offset >= len(data) {
+			return status(statusEOF)
+		}
+		if offset+length > len(data) {
+			length = len(data) - offset
+		}
+		var p packet
+		p.string(string(data[offset : offset+length]))
+		return packetData, p
+	case packetWrite:
+		w, ok := s.handles[r.string()].(*writeHandle)
+		offset, data := int(r.uint64()), r.string()
+		if !ok || offset != len(w.data) {
+			return status(statusFailure)
+		}
+		w.data = append(w.data, data...)
+		return status(statusOK)
+	case packetClose:
+		h := r.string()
+		if w, ok := s.handles[h].(*writeHandle); ok {
+			s.files[w.name] = file{data: w.data, modTime: time.Now()}
+		}
+		delete(s.handles, h)
+		return status(statusOK)
+	case packetStat:
+		name := r.string()
+		if _, exists := s.dirs[name]; exists {
+			var p packet
+			dirAttrs(&p)
+			return packetAttrs, p
+		}
+		f, exists := s.files[name]
+		if !exists {
+			return status(statusNoFile)
+		}
+		var p packet
+		fileAttrs(&p, f)
+		return packetAttrs, p
+	case packetRemove:
+		name := r.string()
+		if _, exists := s.files[name]; !exists {
+			return status(statusNoFile)
+		}
+		delete(s.files, name)
+		return status(statusOK)
+	case packetMkdir:
+		dir := r.string()
+		if _, exists := s.dirs[path.Dir(dir)]; !exists {
+			return status(statusNoFile)
+		}
+		if _, exists := s.dirs[dir]; exists {
+			return status(statusFailure)
+		}
+		s.dirs[dir] = struct{}{}
+		return status(statusOK)
+	case packetRename:
+		return s.rename(r.string(), r.string(), false)
+	case packetExtended:
+		if r.string() == posixRename && s.PosixRename {
+			return s.rename(r.string(), r.string(), true)
+		}
+	}
+	return status(statusUnsup)
+}
+
+//------------------------------------------------------------------------------
+
+// ListenSSH starts an SSH server on a random local port that serves the sftp
+// subsystem from the Server until the returned listener is closed. A host key
+// is generated and added to the config.
+func (s *Server) ListenSSH(conf *ssh.ServerConfig) (net.Listener, error) {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		return nil, err
+	}
+	conf.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveSSH(conn, conf)
+		}
+	}()
+	return ln, nil
+}
+
+func (s *Server) serveSSH(conn net.Conn, conf *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, conf)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range chReqs {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && bytes.Equal(req.Payload[4:], []byte("sftp"))
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						s.Serve(ch)
+						ch.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+//------------------------------------------------------------------------------
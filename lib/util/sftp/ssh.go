@@ -0,0 +1,154 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+//------------------------------------------------------------------------------
+
+// Credentials contains the credentials used to authenticate with an SFTP
+// server.
+type Credentials struct {
+	Username       string `json:"username" yaml:"username"`
+	Password       string `json:"password" yaml:"password"`
+	PrivateKeyFile string `json:"private_key_file" yaml:"private_key_file"`
+	PrivateKeyPass string `json:"private_key_pass" yaml:"private_key_pass"`
+}
+
+// NewCredentials creates a new Credentials with default values.
+func NewCredentials() Credentials {
+	return Credentials{
+		Username:       "",
+		Password:       "",
+		PrivateKeyFile: "",
+		PrivateKeyPass: "",
+	}
+}
+
+// SSHConfig returns an SSH client config that authenticates with the
+// credentials, and verifies the host key of the server with a known hosts file
+// when one is specified.
+func (c Credentials) SSHConfig(knownHostsFile string, timeout time.Duration, log log.Modular) (*ssh.ClientConfig, error) {
+	conf := &ssh.ClientConfig{
+		User:    c.Username,
+		Timeout: timeout,
+	}
+	if len(c.PrivateKeyFile) > 0 {
+		keyBytes, err := ioutil.ReadFile(c.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %v", err)
+		}
+		var signer ssh.Signer
+		if len(c.PrivateKeyPass) > 0 {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyBytes, []byte(c.PrivateKeyPass))
+		} else {
+			signer, err = ssh.ParsePrivateKey(keyBytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %v", err)
+		}
+		conf.Auth = append(conf.Auth, ssh.PublicKeys(signer))
+	}
+	if len(c.Password) > 0 {
+		conf.Auth = append(conf.Auth, ssh.Password(c.Password))
+	}
+	if len(conf.Auth) == 0 {
+		return nil, errors.New("either a password or a private key file must be specified")
+	}
+	if len(knownHostsFile) > 0 {
+		var err error
+		if conf.HostKeyCallback, err = knownhosts.New(knownHostsFile); err != nil {
+			return nil, fmt.Errorf("failed to read known hosts file: %v", err)
+		}
+	} else {
+		log.Warnln("No known hosts file is configured, the host key of the SFTP server will not be verified")
+		conf.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+	return conf, nil
+}
+
+//------------------------------------------------------------------------------
+
+// session closes both the SSH session and connection of an SFTP client.
+type session struct {
+	session *ssh.Session
+	client  *ssh.Client
+}
+
+func (s session) Close() error {
+	s.session.Close()
+	return s.client.Close()
+}
+
+// Dial establishes an SSH connection to an address and starts an SFTP session
+// over the sftp subsystem.
+func Dial(ctx context.Context, address string, conf *ssh.ClientConfig) (*Client, error) {
+	dialer := net.Dialer{Timeout: conf.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, conf)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+
+	sshSession, err := sshClient.NewSession()
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	closer := session{session: sshSession, client: sshClient}
+
+	var w io.WriteCloser
+	var r io.Reader
+	if w, err = sshSession.StdinPipe(); err == nil {
+		if r, err = sshSession.StdoutPipe(); err == nil {
+			err = sshSession.RequestSubsystem("sftp")
+		}
+	}
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	client, err := NewClient(r, w, closer)
+	if err != nil {
+		closer.Close()
+		return nil, fmt.Errorf("failed to initialise sftp session: %v", err)
+	}
+	return client, nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+//------------------------------------------------------------------------------
+
+// ServerDocumentation is a markdown description of how and why to use server
+// TLS settings.
+const ServerDocumentation = `### TLS
+
+When ` + "`tls.enabled`" + ` is set to ` + "`true`" + ` connections are
+terminated with TLS using the certificate and private key found at
+` + "`tls.cert_file` and `tls.key_file`" + `.
+
+Clients can be required to present a certificate by setting
+` + "`tls.client_auth`" + ` to one of the following values:
+
+- ` + "`none`" + `: Client certificates are not requested (default).
+- ` + "`request`" + `: Client certificates are requested but not required.
+- ` + "`require`" + `: Client certificates are required but not verified.
+- ` + "`verify_if_given`" + `: Client certificates are verified if provided.
+- ` + "`require_and_verify`" + `: Client certificates are required and verified.
+
+Client certificates are verified against the certificate authorities found at
+` + "`tls.client_cas_file`" + `, or the system pool when left empty.`
+
+//------------------------------------------------------------------------------
+
+// ServerConfig contains configuration params for terminating TLS on a server.
+type ServerConfig struct {
+	Enabled       bool   `json:"enabled" yaml:"enabled"`
+	CertFile      string `json:"cert_file" yaml:"cert_file"`
+	KeyFile       string `json:"key_file" yaml:"key_file"`
+	ClientAuth    string `json:"client_auth" yaml:"client_auth"`
+	ClientCAsFile string `json:"client_cas_file" yaml:"client_cas_file"`
+}
+
+// NewServerConfig creates a new ServerConfig with default values.
+func NewServerConfig() ServerConfig {
+	return ServerConfig{
+		Enabled:       false,
+		CertFile:      "",
+		KeyFile:       "",
+		ClientAuth:    "none",
+		ClientCAsFile: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Get returns a valid *tls.Config based on the configuration values of
+// ServerConfig.
+func (c *ServerConfig) Get() (*tls.Config, error) {
+	if len(c.CertFile) == 0 || len(c.KeyFile) == 0 {
+		return nil, errors.New("both a cert_file and key_file must be specified")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientAuth tls.ClientAuthType
+	switch c.ClientAuth {
+	case "none", "":
+		clientAuth = tls.NoClientCert
+	case "request":
+		clientAuth = tls.RequestClientCert
+	case "require":
+		clientAuth = tls.RequireAnyClientCert
+	case "verify_if_given":
+		clientAuth = tls.VerifyClientCertIfGiven
+	case "require_and_verify":
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("client_auth not recognised: %v", c.ClientAuth)
+	}
+
+	var clientCAs *x509.CertPool
+	if len(c.ClientCAsFile) > 0 {
+		caCert, err := ioutil.ReadFile(c.ClientCAsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("failed to parse client_cas_file")
+		}
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+		ClientCAs:    clientCAs,
+	}, nil
+}
+
+//------------------------------------------------------------------------------
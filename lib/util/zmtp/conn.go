@@ -0,0 +1,309 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zmtp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+
+	greetingSize = 64
+
+	// maxFrameSize protects against peers declaring absurd frame sizes.
+	maxFrameSize = 1 << 30
+)
+
+var errMalformedCommand = errors.New("malformed command")
+
+// conn is a single ZMTP connection with a peer that has completed the
+// handshake.
+type conn struct {
+	nc       net.Conn
+	r        *bufio.Reader
+	peerType SocketType
+
+	wmut sync.Mutex
+	w    *bufio.Writer
+}
+
+// handshake exchanges greetings and READY commands with a peer over a new
+// network connection and checks that the socket types are compatible.
+func handshake(nc net.Conn, typ SocketType, timeout time.Duration) (*conn, error) {
+	c := &conn{
+		nc: nc,
+		r:  bufio.NewReader(nc),
+		w:  bufio.NewWriter(nc),
+	}
+
+	if timeout > 0 {
+		nc.SetDeadline(time.Now().Add(timeout))
+		defer nc.SetDeadline(time.Time{})
+	}
+
+	greeting := make([]byte, greetingSize)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3
+	greeting[11] = 0
+	copy(greeting[12:32], "NULL")
+	if _, err := c.w.Write(greeting); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(c.r, greeting); err != nil {
+		return nil, fmt.Errorf("failed to read greeting: %v", err)
+	}
+	if greeting[0] != 0xff || greeting[9]&0x01 == 0 {
+		return nil, errors.New("peer did not send a valid greeting")
+	}
+	if greeting[10] < 3 {
+		return nil, fmt.Errorf("unsupported peer protocol version: %v.%v", greeting[10], greeting[11])
+	}
+	if mech := string(bytes.TrimRight(greeting[12:32], "\x00")); mech != "NULL" {
+		return nil, fmt.Errorf("unsupported peer security mechanism: %v", mech)
+	}
+
+	if err := c.writeCommand("READY", writeProperties(map[string]string{
+		"Socket-Type": string(typ),
+	})); err != nil {
+		return nil, err
+	}
+
+	flags, body, err := c.readFrame()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ready command: %v", err)
+	}
+	if flags&flagCommand == 0 {
+		return nil, errors.New("expected ready command from peer")
+	}
+	name, data, err := parseCommand(body)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "READY":
+	case "ERROR":
+		return nil, fmt.Errorf("peer rejected handshake: %s", parseError(data))
+	default:
+		return nil, fmt.Errorf("expected ready command from peer, received: %v", name)
+	}
+
+	props, err := parseProperties(data)
+	if err != nil {
+		return nil, err
+	}
+	c.peerType = SocketType(props["Socket-Type"])
+	if !typ.compatible(c.peerType) {
+		return nil, fmt.Errorf("socket type %v is incompatible with peer type %v", typ, c.peerType)
+	}
+	return c, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (c *conn) writeFrame(body []byte, flags byte) error {
+	var header [9]byte
+	n := 2
+	if len(body) > 255 {
+		header[0] = flags | flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+		n = 9
+	} else {
+		header[0] = flags
+		header[1] = byte(len(body))
+	}
+	if _, err := c.w.Write(header[:n]); err != nil {
+		return err
+	}
+	_, err := c.w.Write(body)
+	return err
+}
+
+func (c *conn) writeCommand(name string, data []byte) error {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	body = append(body, data...)
+
+	c.wmut.Lock()
+	defer c.wmut.Unlock()
+	if err := c.writeFrame(body, flagCommand); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// writeMessage writes each part of a message as a frame.
+func (c *conn) writeMessage(parts [][]byte) error {
+	c.wmut.Lock()
+	defer c.wmut.Unlock()
+	for i, p := range parts {
+		var flags byte
+		if i < len(parts)-1 {
+			flags = flagMore
+		}
+		if err := c.writeFrame(p, flags); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) readFrame() (byte, []byte, error) {
+	flags, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var b [8]byte
+		if _, err = io.ReadFull(c.r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		var b byte
+		if b, err = c.r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame size %v exceeds limit", size)
+	}
+	body := make([]byte, size)
+	if _, err = io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// readMessage reads frames until a complete message is received. Heartbeats
+// are answered automatically and other commands are passed to onCommand.
+func (c *conn) readMessage(onCommand func(name string, data []byte)) ([][]byte, error) {
+	var parts [][]byte
+	for {
+		flags, body, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			name, data, err := parseCommand(body)
+			if err != nil {
+				return nil, err
+			}
+			switch name {
+			case "PING":
+				if len(data) < 2 {
+					return nil, errMalformedCommand
+				}
+				if err = c.writeCommand("PONG", data[2:]); err != nil {
+					return nil, err
+				}
+			case "PONG":
+			case "ERROR":
+				return nil, fmt.Errorf("peer sent error: %s", parseError(data))
+			default:
+				if onCommand != nil {
+					onCommand(name, data)
+				}
+			}
+			continue
+		}
+		parts = append(parts, body)
+		if flags&flagMore == 0 {
+			return parts, nil
+		}
+	}
+}
+
+func (c *conn) close() error {
+	return c.nc.Close()
+}
+
+//------------------------------------------------------------------------------
+
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) == 0 || len(body) < 1+int(body[0]) {
+		return "", nil, errMalformedCommand
+	}
+	n := int(body[0])
+	return string(body[1 : 1+n]), body[1+n:], nil
+}
+
+func parseError(data []byte) []byte {
+	if len(data) > 0 && len(data) >= 1+int(data[0]) {
+		return data[1 : 1+int(data[0])]
+	}
+	return data
+}
+
+func writeProperties(props map[string]string) []byte {
+	var buf bytes.Buffer
+	for k, v := range props {
+		buf.WriteByte(byte(len(k)))
+		buf.WriteString(k)
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(v)))
+		buf.Write(size[:])
+		buf.WriteString(v)
+	}
+	return buf.Bytes()
+}
+
+func parseProperties(data []byte) (map[string]string, error) {
+	props := map[string]string{}
+	for len(data) > 0 {
+		n := int(data[0])
+		if len(data) < 1+n+4 {
+			return nil, errMalformedCommand
+		}
+		name := string(data[1 : 1+n])
+		data = data[1+n:]
+		size := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(size) {
+			return nil, errMalformedCommand
+		}
+		props[name] = string(data[:size])
+		data = data[size:]
+	}
+	return props, nil
+}
+
+//------------------------------------------------------------------------------
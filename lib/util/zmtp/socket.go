@@ -0,0 +1,483 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zmtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+// SocketType is the type of a ZeroMQ socket, which determines the messaging
+// pattern it participates in.
+type SocketType string
+
+// Supported socket types.
+const (
+	PUB  SocketType = "PUB"
+	SUB  SocketType = "SUB"
+	PUSH SocketType = "PUSH"
+	PULL SocketType = "PULL"
+)
+
+func (t SocketType) compatible(peer SocketType) bool {
+	switch t {
+	case PUB:
+		return peer == SUB || peer == "XSUB"
+	case SUB:
+		return peer == PUB || peer == "XPUB"
+	case PUSH:
+		return peer == PULL
+	case PULL:
+		return peer == PUSH
+	}
+	return false
+}
+
+// ErrClosed is returned when attempting to use a socket that has been closed.
+var ErrClosed = errors.New("socket closed")
+
+const (
+	defaultHighWaterMark = 1000
+	handshakeTimeout     = time.Second * 10
+	minReconnectInterval = time.Millisecond * 100
+	maxReconnectInterval = time.Second * 5
+)
+
+//------------------------------------------------------------------------------
+
+type peer struct {
+	conn  *conn
+	subs  [][]byte
+	queue chan [][]byte
+}
+
+// Socket is a ZeroMQ socket that may be bound to and connected to any number
+// of endpoints. Connections to endpoints are established in the background
+// and are reestablished when lost.
+type Socket struct {
+	typ SocketType
+	hwm int
+
+	in  chan [][]byte
+	out chan [][]byte
+
+	mut       sync.Mutex
+	peers     map[*peer]struct{}
+	listeners []net.Listener
+	topics    [][]byte
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewSocket creates a new socket of a type. The high water mark is the
+// maximum number of messages queued by the socket, or by each peer of a PUB
+// socket, where zero results in a default of 1000.
+func NewSocket(typ SocketType, highWaterMark int) (*Socket, error) {
+	switch typ {
+	case PUB, SUB, PUSH, PULL:
+	default:
+		return nil, fmt.Errorf("unsupported socket type: %v", typ)
+	}
+	if highWaterMark <= 0 {
+		highWaterMark = defaultHighWaterMark
+	}
+	s := &Socket{
+		typ:    typ,
+		hwm:    highWaterMark,
+		peers:  map[*peer]struct{}{},
+		closed: make(chan struct{}),
+	}
+	switch typ {
+	case SUB, PULL:
+		s.in = make(chan [][]byte, highWaterMark)
+	case PUSH:
+		s.out = make(chan [][]byte, highWaterMark)
+	}
+	return s, nil
+}
+
+// parseEndpoint converts a ZeroMQ endpoint such as tcp://localhost:5555 or
+// ipc:///tmp/socket into a network and address.
+func parseEndpoint(endpoint string) (network, address string, err error) {
+	i := strings.Index(endpoint, "://")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid endpoint: %v", endpoint)
+	}
+	switch scheme, addr := endpoint[:i], endpoint[i+3:]; scheme {
+	case "tcp":
+		return "tcp", strings.Replace(addr, "*:", ":", 1), nil
+	case "ipc":
+		return "unix", addr, nil
+	default:
+		return "", "", fmt.Errorf("unsupported transport: %v", scheme)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (s *Socket) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+	}
+	return false
+}
+
+// Bind listens for peers on an endpoint.
+func (s *Socket) Bind(endpoint string) error {
+	network, address, err := parseEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	s.listeners = append(s.listeners, ln)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				if s.isClosed() {
+					return
+				}
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					time.Sleep(minReconnectInterval)
+					continue
+				}
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.handle(nc)
+			}()
+		}
+	}()
+	return nil
+}
+
+// Addrs returns the addresses of endpoints the socket is bound to.
+func (s *Socket) Addrs() []net.Addr {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for _, ln := range s.listeners {
+		addrs = append(addrs, ln.Addr())
+	}
+	return addrs
+}
+
+// Connect begins connecting to an endpoint in the background. The connection
+// is reestablished whenever it is lost until the socket is closed.
+func (s *Socket) Connect(endpoint string) error {
+	network, address, err := parseEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		dialer := net.Dialer{Timeout: handshakeTimeout}
+		interval := minReconnectInterval
+		for {
+			nc, err := dialer.Dial(network, address)
+			if err == nil {
+				if s.handle(nc) {
+					interval = minReconnectInterval
+				}
+			}
+			select {
+			case <-time.After(interval):
+			case <-s.closed:
+				return
+			}
+			if interval *= 2; interval > maxReconnectInterval {
+				interval = maxReconnectInterval
+			}
+		}
+	}()
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func (s *Socket) addPeer(c *conn) (*peer, bool) {
+	p := &peer{conn: c}
+	if s.typ == PUB {
+		p.queue = make(chan [][]byte, s.hwm)
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.isClosed() {
+		return nil, false
+	}
+	if s.typ == SUB {
+		for _, topic := range s.topics {
+			if err := c.writeMessage([][]byte{append([]byte{1}, topic...)}); err != nil {
+				return nil, false
+			}
+		}
+	}
+	s.peers[p] = struct{}{}
+	return p, true
+}
+
+func (s *Socket) removePeer(p *peer) {
+	s.mut.Lock()
+	delete(s.peers, p)
+	s.mut.Unlock()
+}
+
+// handle performs the handshake with a new peer and then services the
+// connection until it is lost, returning whether the handshake succeeded.
+func (s *Socket) handle(nc net.Conn) bool {
+	c, err := handshake(nc, s.typ, handshakeTimeout)
+	if err != nil {
+		nc.Close()
+		return false
+	}
+	defer c.close()
+
+	p, ok := s.addPeer(c)
+	if !ok {
+		return false
+	}
+	defer s.removePeer(p)
+
+	// Closing the connection when the socket closes unblocks pending reads.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.closed:
+			c.close()
+		case <-done:
+		}
+	}()
+
+	switch s.typ {
+	case SUB, PULL:
+		for {
+			msg, err := c.readMessage(nil)
+			if err != nil {
+				return true
+			}
+			select {
+			case s.in <- msg:
+			case <-s.closed:
+				return true
+			}
+		}
+	}
+
+	readErr := make(chan struct{})
+	go func() {
+		defer close(readErr)
+		for {
+			msg, err := c.readMessage(func(name string, data []byte) {
+				switch name {
+				case "SUBSCRIBE":
+					s.subscribePeer(p, data)
+				case "CANCEL":
+					s.unsubscribePeer(p, data)
+				}
+			})
+			if err != nil {
+				return
+			}
+			if s.typ == PUB && len(msg) == 1 && len(msg[0]) > 0 {
+				switch msg[0][0] {
+				case 1:
+					s.subscribePeer(p, msg[0][1:])
+				case 0:
+					s.unsubscribePeer(p, msg[0][1:])
+				}
+			}
+		}
+	}()
+
+	queue := s.out
+	if s.typ == PUB {
+		queue = p.queue
+	}
+	for {
+		select {
+		case msg := <-queue:
+			if err := c.writeMessage(msg); err != nil {
+				if s.typ == PUSH {
+					// Give the message to another peer.
+					select {
+					case s.out <- msg:
+					default:
+					}
+				}
+				return true
+			}
+		case <-readErr:
+			return true
+		case <-s.closed:
+			return true
+		}
+	}
+}
+
+func (s *Socket) subscribePeer(p *peer, topic []byte) {
+	s.mut.Lock()
+	p.subs = append(p.subs, append([]byte(nil), topic...))
+	s.mut.Unlock()
+}
+
+func (s *Socket) unsubscribePeer(p *peer, topic []byte) {
+	s.mut.Lock()
+	for i, sub := range p.subs {
+		if bytes.Equal(sub, topic) {
+			p.subs = append(p.subs[:i], p.subs[i+1:]...)
+			break
+		}
+	}
+	s.mut.Unlock()
+}
+
+//------------------------------------------------------------------------------
+
+// Subscribe adds a topic to a SUB socket, where messages with a first part
+// beginning with the topic are received. An empty topic matches all messages.
+func (s *Socket) Subscribe(topic []byte) error {
+	if s.typ != SUB {
+		return fmt.Errorf("cannot subscribe with socket type: %v", s.typ)
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.topics = append(s.topics, append([]byte(nil), topic...))
+	for p := range s.peers {
+		// Failures are detected and the subscriptions resent upon reconnect.
+		p.conn.writeMessage([][]byte{append([]byte{1}, topic...)})
+	}
+	return nil
+}
+
+// Recv blocks until a message is received by a PULL or SUB socket, or the
+// context is cancelled.
+func (s *Socket) Recv(ctx context.Context) ([][]byte, error) {
+	if s.in == nil {
+		return nil, fmt.Errorf("cannot receive with socket type: %v", s.typ)
+	}
+	select {
+	case msg := <-s.in:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.closed:
+		return nil, ErrClosed
+	}
+}
+
+// Send a message from a PUSH or PUB socket. A PUSH socket blocks until the
+// message is queued for a peer or the context is cancelled, whereas a PUB
+// socket delivers the message to each subscribed peer with room in its queue
+// and drops it otherwise.
+func (s *Socket) Send(ctx context.Context, msg [][]byte) error {
+	if len(msg) == 0 {
+		return errors.New("cannot send an empty message")
+	}
+	switch s.typ {
+	case PUSH:
+		select {
+		case s.out <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.closed:
+			return ErrClosed
+		}
+	case PUB:
+		if s.isClosed() {
+			return ErrClosed
+		}
+		s.mut.Lock()
+		defer s.mut.Unlock()
+		for p := range s.peers {
+			if !p.matches(msg[0]) {
+				continue
+			}
+			select {
+			case p.queue <- msg:
+			default:
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cannot send with socket type: %v", s.typ)
+}
+
+func (p *peer) matches(part []byte) bool {
+	for _, sub := range p.subs {
+		if bytes.HasPrefix(part, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close the socket, its listeners and all peer connections, and wait for
+// background goroutines to finish.
+func (s *Socket) Close() error {
+	s.closeOnce.Do(func() {
+		s.mut.Lock()
+		close(s.closed)
+		for _, ln := range s.listeners {
+			ln.Close()
+		}
+		s.mut.Unlock()
+	})
+	s.wg.Wait()
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,235 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zmtp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+func boundEndpoint(t *testing.T, s *Socket) string {
+	t.Helper()
+	addrs := s.Addrs()
+	if len(addrs) != 1 {
+		t.Fatalf("Wrong count of bound addresses: %v", len(addrs))
+	}
+	return "tcp://" + addrs[0].String()
+}
+
+func TestSocketPushPull(t *testing.T) {
+	push, err := NewSocket(PUSH, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer push.Close()
+	if err = push.Bind("tcp://127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	pull, err := NewSocket(PULL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pull.Close()
+	if err = pull.Connect(boundEndpoint(t, push)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	msgs := [][][]byte{
+		{[]byte("foo")},
+		{[]byte("bar"), []byte("baz"), {}},
+		{bytes.Repeat([]byte("x"), 1000)},
+	}
+	for _, msg := range msgs {
+		if err = push.Send(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, exp := range msgs {
+		act, err := pull.Recv(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong message: %q != %q", act, exp)
+		}
+	}
+}
+
+func TestSocketReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := "tcp://" + ln.Addr().String()
+	ln.Close()
+
+	pull, err := NewSocket(PULL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pull.Close()
+	if err = pull.Connect(endpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	for _, exp := range []string{"first", "second"} {
+		push, err := NewSocket(PUSH, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = push.Bind(endpoint); err != nil {
+			t.Fatal(err)
+		}
+		if err = push.Send(ctx, [][]byte{[]byte(exp)}); err != nil {
+			t.Fatal(err)
+		}
+		act, err := pull.Recv(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(act[0]) != exp {
+			t.Errorf("Wrong message: %s != %v", act[0], exp)
+		}
+		push.Close()
+	}
+}
+
+func TestSocketPubSub(t *testing.T) {
+	pub, err := NewSocket(PUB, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	if err = pub.Bind("tcp://127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := NewSocket(SUB, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if err = sub.Subscribe([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err = sub.Connect(boundEndpoint(t, pub)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	// Messages are dropped until the subscription reaches the publisher, so
+	// keep publishing until the first is received.
+	for {
+		if err = pub.Send(ctx, [][]byte{[]byte("bar ignored")}); err != nil {
+			t.Fatal(err)
+		}
+		if err = pub.Send(ctx, [][]byte{[]byte("foo first")}); err != nil {
+			t.Fatal(err)
+		}
+		rctx, rdone := context.WithTimeout(ctx, time.Millisecond*50)
+		msg, err := sub.Recv(rctx)
+		rdone()
+		if err == nil {
+			if exp, act := "foo first", string(msg[0]); exp != act {
+				t.Errorf("Wrong message: %v != %v", act, exp)
+			}
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("timed out waiting for subscription")
+		}
+	}
+}
+
+func TestSocketIncompatible(t *testing.T) {
+	push, err := NewSocket(PUSH, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer push.Close()
+	if err = push.Bind("tcp://127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+
+	nc, err := net.Dial("tcp", push.Addrs()[0].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	if _, err = handshake(nc, SUB, time.Second); err == nil {
+		t.Error("Expected error from incompatible socket types")
+	}
+}
+
+func TestSocketBadConfig(t *testing.T) {
+	if _, err := NewSocket("REQ", 0); err == nil {
+		t.Error("Expected error from unsupported type")
+	}
+
+	s, err := NewSocket(PULL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, e := range []string{"localhost:5555", "udp://localhost:5555"} {
+		if err = s.Connect(e); err == nil {
+			t.Errorf("Expected error from endpoint: %v", e)
+		}
+	}
+	if err = s.Send(context.Background(), [][]byte{[]byte("foo")}); err == nil {
+		t.Error("Expected error from sending with a PULL socket")
+	}
+}
+
+func TestProperties(t *testing.T) {
+	exp := map[string]string{
+		"Socket-Type": "PUSH",
+		"Identity":    "",
+	}
+	act, err := parseProperties(writeProperties(exp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong properties: %v != %v", act, exp)
+	}
+	if _, err = parseProperties([]byte{5, 'f', 'o'}); err == nil {
+		t.Error("Expected error from malformed properties")
+	}
+}
+
+//------------------------------------------------------------------------------